@@ -0,0 +1,84 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/btcsuite/btcd/connmgr"
+	"github.com/btcsuite/btcd/database"
+)
+
+// addedNodesKey is the key of the database metadata entry the nodes added with
+// the addnode RPC are saved under, so they are connected to again after a
+// restart.
+var addedNodesKey = []byte("addednodes")
+
+// addedNode houses a node which is connected to permanently, either because it
+// was added with the addnode RPC or configured with the addpeer or connect
+// options.
+type addedNode struct {
+	addr string
+
+	// connReq is the permanent connection request to the node.  It is nil
+	// when the address of a saved node could not be resolved on startup.
+	connReq *connmgr.ConnReq
+
+	// persist indicates whether the node is saved to the database.
+	// Configured nodes are added again on each start instead.
+	persist bool
+}
+
+// serializeAddedNodes returns the sorted addresses of the passed added nodes
+// which are persisted, serialized for storage in the database.
+func serializeAddedNodes(nodes map[string]*addedNode) ([]byte, error) {
+	addrs := make([]string, 0, len(nodes))
+	for addr, node := range nodes {
+		if node.persist {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	return json.Marshal(addrs)
+}
+
+// deserializeAddedNodes returns the addresses of the added nodes serialized by
+// serializeAddedNodes.
+func deserializeAddedNodes(serialized []byte) ([]string, error) {
+	var addrs []string
+	if err := json.Unmarshal(serialized, &addrs); err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// loadAddedNodes returns the addresses of the added nodes saved in the passed
+// database.  No addresses and no error are returned when none were saved.
+func loadAddedNodes(db database.DB) ([]string, error) {
+	var addrs []string
+	err := db.View(func(tx database.Tx) error {
+		serialized := tx.Metadata().Get(addedNodesKey)
+		if serialized == nil {
+			return nil
+		}
+		var err error
+		addrs, err = deserializeAddedNodes(serialized)
+		return err
+	})
+	return addrs, err
+}
+
+// saveAddedNodes saves the addresses of the passed added nodes which are
+// persisted to the passed database.
+func saveAddedNodes(db database.DB, nodes map[string]*addedNode) error {
+	serialized, err := serializeAddedNodes(nodes)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx database.Tx) error {
+		return tx.Metadata().Put(addedNodesKey, serialized)
+	})
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestAddedNodesSerialization ensures only the added nodes which are persisted
+// are serialized and that they round trip.
+func TestAddedNodesSerialization(t *testing.T) {
+	t.Parallel()
+
+	nodes := map[string]*addedNode{
+		"example.com:8333": {addr: "example.com:8333", persist: true},
+		"10.0.0.1:8333":    {addr: "10.0.0.1:8333", persist: true},
+		"10.0.0.2:8333":    {addr: "10.0.0.2:8333"},
+	}
+	serialized, err := serializeAddedNodes(nodes)
+	if err != nil {
+		t.Fatalf("serializeAddedNodes: unexpected error: %v", err)
+	}
+	addrs, err := deserializeAddedNodes(serialized)
+	if err != nil {
+		t.Fatalf("deserializeAddedNodes: unexpected error: %v", err)
+	}
+	want := []string{"10.0.0.1:8333", "example.com:8333"}
+	if !reflect.DeepEqual(addrs, want) {
+		t.Fatalf("deserializeAddedNodes: got %v, want %v", addrs, want)
+	}
+
+	if _, err := deserializeAddedNodes([]byte("{")); err == nil {
+		t.Fatal("deserializeAddedNodes: expected error for malformed data")
+	}
+}
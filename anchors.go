@@ -0,0 +1,60 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	// anchorsFilename is the name of the file in the data directory the
+	// anchors are saved to on shutdown.
+	anchorsFilename = "anchors.json"
+
+	// maxAnchors is the maximum number of anchors which are saved and
+	// connected to on startup.
+	maxAnchors = 2
+)
+
+// readAnchors returns the addresses saved in the anchors file at the passed
+// path and removes the file, so the same anchors are not reused after an
+// unclean shutdown.  No addresses and no error are returned when the file does
+// not exist.
+func readAnchors(path string) ([]string, error) {
+	serialized, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+	if err := json.Unmarshal(serialized, &addrs); err != nil {
+		return nil, err
+	}
+	if len(addrs) > maxAnchors {
+		addrs = addrs[:maxAnchors]
+	}
+	return addrs, nil
+}
+
+// writeAnchors saves up to maxAnchors of the passed addresses to the anchors
+// file at the passed path.
+func writeAnchors(path string, addrs []string) error {
+	if len(addrs) > maxAnchors {
+		addrs = addrs[:maxAnchors]
+	}
+	serialized, err := json.Marshal(addrs)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, serialized, 0600)
+}
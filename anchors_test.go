@@ -0,0 +1,50 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestAnchors ensures anchors are saved up to the maximum and the file is
+// removed once they have been read.
+func TestAnchors(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "anchors")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, anchorsFilename)
+
+	// No anchors are returned when the file doesn't exist.
+	addrs, err := readAnchors(path)
+	if err != nil || len(addrs) != 0 {
+		t.Fatalf("readAnchors: got %v, %v without a file", addrs, err)
+	}
+
+	saved := []string{"1.2.3.4:8333", "[2001:db8::1]:8333", "5.6.7.8:8333"}
+	if err := writeAnchors(path, saved); err != nil {
+		t.Fatalf("writeAnchors: %v", err)
+	}
+	addrs, err = readAnchors(path)
+	if err != nil {
+		t.Fatalf("readAnchors: %v", err)
+	}
+	if !reflect.DeepEqual(addrs, saved[:maxAnchors]) {
+		t.Fatalf("readAnchors: got %v, want %v", addrs,
+			saved[:maxAnchors])
+	}
+
+	// The anchors are only used once.
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("anchors file not removed after reading: %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// banListFilename is the name of the file in the data directory the
+	// bans are saved to.
+	banListFilename = "banlist.json"
+
+	// banReasonManual and banReasonMisbehaving are the reasons reported
+	// for bans added through the RPC server and for bans of peers whose
+	// ban score exceeded the threshold, respectively.
+	banReasonManual      = "manually added"
+	banReasonMisbehaving = "node misbehaving"
+)
+
+// The following are the offenses which increase the ban score of a peer.  The
+// score of each of them can be overridden with the --banscore option.
+const (
+	// offenseMemPool is a mempool request.  Its score decays over time so
+	// only bursts of requests result in a ban.
+	offenseMemPool = "mempool"
+
+	// offenseGetData is a getdata request for the maximum number of
+	// inventory vectors.  Smaller requests add a proportional score, which
+	// decays over time so only sustained bursts result in a ban.
+	offenseGetData = "getdata"
+
+	// offenseNoBloom is a bloom filter request from a peer which knows the
+	// server doesn't support bloom filtering.
+	offenseNoBloom = "nobloom"
+)
+
+// defaultOffenseScores are the default scores of the offenses.
+var defaultOffenseScores = map[string]uint32{
+	offenseMemPool: 33,
+	offenseGetData: 99,
+	offenseNoBloom: 100,
+}
+
+// errAlreadyBanned and errNotBanned are returned when adding a ban which
+// already exists and removing one which doesn't, respectively.
+var (
+	errAlreadyBanned = errors.New("IP/Subnet already banned")
+	errNotBanned     = errors.New("IP/Subnet is not banned")
+)
+
+// parseBanScores returns the offense scores with the passed overrides, which
+// are in the form <offense>=<score>, applied to the defaults.
+func parseBanScores(overrides []string) (map[string]uint32, error) {
+	scores := make(map[string]uint32, len(defaultOffenseScores))
+	for offense, score := range defaultOffenseScores {
+		scores[offense] = score
+	}
+	for _, override := range overrides {
+		parts := strings.Split(override, "=")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("ban score %q is not in the form "+
+				"<offense>=<score>", override)
+		}
+		if _, ok := scores[parts[0]]; !ok {
+			return nil, fmt.Errorf("unknown offense %q", parts[0])
+		}
+		score, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid score for offense "+
+				"%q: %v", parts[0], err)
+		}
+		scores[parts[0]] = uint32(score)
+	}
+	return scores, nil
+}
+
+// banTarget identifies the peers a ban applies to.  It is either a subnet, or
+// the host name of peers whose address is not an IP address, such as onion
+// addresses.
+type banTarget struct {
+	subnet *net.IPNet
+	host   string
+}
+
+// parseBanTarget returns the target described by the passed string, which is
+// either an IP address, a subnet in CIDR notation, or a host name.
+func parseBanTarget(s string) (*banTarget, error) {
+	if strings.Contains(s, "/") {
+		_, subnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		return &banTarget{subnet: subnet}, nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		mask := net.CIDRMask(bits, bits)
+		return &banTarget{subnet: &net.IPNet{IP: ip, Mask: mask}}, nil
+	}
+	if s == "" {
+		return nil, errors.New("empty address")
+	}
+	return &banTarget{host: s}, nil
+}
+
+// String returns the target as a subnet in CIDR notation or the host name.
+func (t *banTarget) String() string {
+	if t.subnet != nil {
+		return t.subnet.String()
+	}
+	return t.host
+}
+
+// matches returns whether the target applies to the passed host.
+func (t *banTarget) matches(host string) bool {
+	if t.subnet == nil {
+		return t.host == host
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && t.subnet.Contains(ip)
+}
+
+// banEntry describes a ban.  It is also the format bans are saved in.
+type banEntry struct {
+	Target  string `json:"target"`
+	Created int64  `json:"created"`
+	Until   int64  `json:"until"`
+	Reason  string `json:"reason"`
+
+	target *banTarget
+}
+
+// banManager keeps track of the banned peers and the scores of the offenses
+// which get peers banned.  The bans are saved to a file each time they are
+// changed so they persist across restarts.
+type banManager struct {
+	mtx    sync.Mutex
+	path   string
+	scores map[string]uint32
+	bans   map[string]*banEntry
+}
+
+// newBanManager returns a new ban manager which saves the bans to the passed
+// path and uses the passed offense scores.
+func newBanManager(path string, scores map[string]uint32) *banManager {
+	return &banManager{
+		path:   path,
+		scores: scores,
+		bans:   make(map[string]*banEntry),
+	}
+}
+
+// Load loads the bans saved by a previous instance, ignoring the ones which
+// expired by the passed time.  It is not an error for the file to not exist.
+func (b *banManager) Load(now time.Time) error {
+	serialized, err := ioutil.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var entries []*banEntry
+	if err := json.Unmarshal(serialized, &entries); err != nil {
+		return err
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	for _, entry := range entries {
+		if !now.Before(time.Unix(entry.Until, 0)) {
+			continue
+		}
+		target, err := parseBanTarget(entry.Target)
+		if err != nil {
+			return fmt.Errorf("invalid ban %q: %v", entry.Target, err)
+		}
+		entry.target = target
+		b.bans[target.String()] = entry
+	}
+	return nil
+}
+
+// save writes the bans to the file.  It must be called with the mutex held.
+func (b *banManager) save() error {
+	entries := make([]*banEntry, 0, len(b.bans))
+	for _, entry := range b.bans {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Target < entries[j].Target
+	})
+	serialized, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(b.path, serialized, 0600)
+}
+
+// OffenseScore returns the score of the passed offense.
+func (b *banManager) OffenseScore(offense string) uint32 {
+	return b.scores[offense]
+}
+
+// Ban bans the passed target from the passed time until the passed expiry for
+// the passed reason.  An existing ban of the same target which has not expired
+// yet results in errAlreadyBanned unless replace is set.
+//
+// This function is safe for concurrent access.
+func (b *banManager) Ban(target *banTarget, now, until time.Time, reason string, replace bool) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	key := target.String()
+	if entry, ok := b.bans[key]; ok && !replace &&
+		now.Before(time.Unix(entry.Until, 0)) {
+
+		return errAlreadyBanned
+	}
+	b.bans[key] = &banEntry{
+		Target:  key,
+		Created: now.Unix(),
+		Until:   until.Unix(),
+		Reason:  reason,
+		target:  target,
+	}
+	return b.save()
+}
+
+// Unban removes the ban of the passed target.  errNotBanned is returned when
+// there is no such ban.
+//
+// This function is safe for concurrent access.
+func (b *banManager) Unban(target *banTarget) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	key := target.String()
+	if _, ok := b.bans[key]; !ok {
+		return errNotBanned
+	}
+	delete(b.bans, key)
+	return b.save()
+}
+
+// Clear removes all bans.
+//
+// This function is safe for concurrent access.
+func (b *banManager) Clear() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.bans = make(map[string]*banEntry)
+	return b.save()
+}
+
+// IsBanned returns whether the passed host is banned at the passed time along
+// with the time the longest ban which applies to it expires.
+//
+// This function is safe for concurrent access.
+func (b *banManager) IsBanned(host string, now time.Time) (time.Time, bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	var until time.Time
+	for _, entry := range b.bans {
+		entryUntil := time.Unix(entry.Until, 0)
+		if !now.Before(entryUntil) || !entry.target.matches(host) {
+			continue
+		}
+		if entryUntil.After(until) {
+			until = entryUntil
+		}
+	}
+	return until, !until.IsZero()
+}
+
+// Bans returns the bans which have not expired by the passed time sorted by
+// their target.
+//
+// This function is safe for concurrent access.
+func (b *banManager) Bans(now time.Time) []banEntry {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	entries := make([]banEntry, 0, len(b.bans))
+	for _, entry := range b.bans {
+		if now.Before(time.Unix(entry.Until, 0)) {
+			entries = append(entries, *entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Target < entries[j].Target
+	})
+	return entries
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseBanScores ensures ban score overrides are applied to the defaults
+// and invalid overrides are rejected.
+func TestParseBanScores(t *testing.T) {
+	t.Parallel()
+
+	scores, err := parseBanScores([]string{"mempool=50", "nobloom=0"})
+	if err != nil {
+		t.Fatalf("parseBanScores: %v", err)
+	}
+	want := map[string]uint32{"mempool": 50, "getdata": 99, "nobloom": 0}
+	for offense, score := range want {
+		if scores[offense] != score {
+			t.Fatalf("offense %s: got score %d, want %d", offense,
+				scores[offense], score)
+		}
+	}
+
+	for _, override := range []string{"mempool", "bogus=1", "mempool=-1",
+		"getdata=1=2"} {
+
+		if _, err := parseBanScores([]string{override}); err == nil {
+			t.Fatalf("invalid override %q accepted", override)
+		}
+	}
+}
+
+// TestBanTarget ensures ban targets are parsed and matched against hosts as
+// expected.
+func TestBanTarget(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		target  string
+		str     string
+		matches []string
+		misses  []string
+	}{
+		{"1.2.3.4", "1.2.3.4/32", []string{"1.2.3.4"}, []string{"1.2.3.5"}},
+		{"10.1.2.3/8", "10.0.0.0/8", []string{"10.255.0.1"}, []string{"11.0.0.1"}},
+		{"2001:db8::1", "2001:db8::1/128", []string{"2001:db8::1"}, []string{"2001:db8::2"}},
+		{"2001:db8::/32", "2001:db8::/32", []string{"2001:db8:1::1"}, []string{"2001:db9::1"}},
+		{"abc.onion", "abc.onion", []string{"abc.onion"}, []string{"abd.onion", "1.2.3.4"}},
+	}
+	for _, test := range tests {
+		target, err := parseBanTarget(test.target)
+		if err != nil {
+			t.Fatalf("parseBanTarget(%q): %v", test.target, err)
+		}
+		if target.String() != test.str {
+			t.Fatalf("parseBanTarget(%q): got %s, want %s",
+				test.target, target, test.str)
+		}
+		for _, host := range test.matches {
+			if !target.matches(host) {
+				t.Fatalf("%s does not match %s", target, host)
+			}
+		}
+		for _, host := range test.misses {
+			if target.matches(host) {
+				t.Fatalf("%s matches %s", target, host)
+			}
+		}
+	}
+
+	for _, target := range []string{"", "10.0.0.0/33", "1.2.3/8"} {
+		if _, err := parseBanTarget(target); err == nil {
+			t.Fatalf("invalid target %q accepted", target)
+		}
+	}
+}
+
+// TestBanManager ensures bans apply until they expire and persist across
+// restarts.
+func TestBanManager(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "banmanager")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, banListFilename)
+
+	mustParse := func(s string) *banTarget {
+		target, err := parseBanTarget(s)
+		if err != nil {
+			t.Fatalf("parseBanTarget(%q): %v", s, err)
+		}
+		return target
+	}
+
+	now := time.Unix(1500000000, 0)
+	b := newBanManager(path, defaultOffenseScores)
+	if err := b.Load(now); err != nil {
+		t.Fatalf("Load without a file: %v", err)
+	}
+	if b.OffenseScore(offenseNoBloom) != 100 {
+		t.Fatalf("unexpected nobloom score %d",
+			b.OffenseScore(offenseNoBloom))
+	}
+
+	subnet := mustParse("10.0.0.0/8")
+	err = b.Ban(subnet, now, now.Add(time.Hour), banReasonManual, false)
+	if err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	err = b.Ban(subnet, now, now.Add(time.Hour), banReasonManual, false)
+	if err != errAlreadyBanned {
+		t.Fatalf("Ban: got %v, want %v", err, errAlreadyBanned)
+	}
+	host := mustParse("10.1.1.1")
+	err = b.Ban(host, now, now.Add(2*time.Hour), banReasonMisbehaving, true)
+	if err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+
+	// The longest ban which applies to a host is reported.
+	until, banned := b.IsBanned("10.1.1.1", now)
+	if !banned || !until.Equal(now.Add(2*time.Hour)) {
+		t.Fatalf("IsBanned: got %v, %v", until, banned)
+	}
+	if _, banned := b.IsBanned("10.2.2.2", now.Add(time.Hour)); banned {
+		t.Fatal("IsBanned: expired ban applied")
+	}
+	if _, banned := b.IsBanned("11.0.0.1", now); banned {
+		t.Fatal("IsBanned: ban applied outside of subnet")
+	}
+
+	// The bans which have not expired are reloaded after a restart.
+	b = newBanManager(path, defaultOffenseScores)
+	if err := b.Load(now.Add(time.Hour)); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	bans := b.Bans(now.Add(time.Hour))
+	if len(bans) != 1 || bans[0].Target != "10.1.1.1/32" ||
+		bans[0].Reason != banReasonMisbehaving ||
+		bans[0].Created != now.Unix() {
+
+		t.Fatalf("unexpected bans after reload: %+v", bans)
+	}
+
+	if err := b.Unban(subnet); err != errNotBanned {
+		t.Fatalf("Unban: got %v, want %v", err, errNotBanned)
+	}
+	if err := b.Unban(host); err != nil {
+		t.Fatalf("Unban: %v", err)
+	}
+	if len(b.Bans(now)) != 0 {
+		t.Fatal("ban not removed")
+	}
+
+	err = b.Ban(subnet, now, now.Add(time.Hour), banReasonManual, false)
+	if err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	if err := b.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	b = newBanManager(path, defaultOffenseScores)
+	if err := b.Load(now); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(b.Bans(now)) != 0 {
+		t.Fatal("bans not cleared")
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// blockBatchCompressionNone is the compression of block batches which
+	// are not compressed.
+	blockBatchCompressionNone = "none"
+
+	// blockBatchCompressionGzip is the compression of block batches which
+	// are compressed with gzip.
+	blockBatchCompressionGzip = "gzip"
+
+	// maxBlockBatchBlocks is the maximum number of blocks returned by a
+	// single getblocksbatch request.
+	maxBlockBatchBlocks = 1000
+
+	// maxBlockBatchBytes is the number of bytes of serialized blocks after
+	// which no more blocks are added to the batch returned by a single
+	// getblocksbatch request.  A batch always includes at least one block,
+	// so it may exceed this by up to the size of a block.
+	maxBlockBatchBytes = 32 * 1024 * 1024
+)
+
+// blockBatchWriter serializes blocks into the framing used by getblocksbatch,
+// which is each serialized block prefixed by its length as a little-endian
+// uint32, and optionally compresses the result as a whole.
+type blockBatchWriter struct {
+	buf    bytes.Buffer
+	w      io.Writer
+	closer io.Closer
+	blocks int
+	size   int
+}
+
+// newBlockBatchWriter returns a block batch writer which applies the passed
+// compression.  An error is returned when the compression is not supported.
+func newBlockBatchWriter(compression string) (*blockBatchWriter, error) {
+	bw := &blockBatchWriter{}
+	switch compression {
+	case blockBatchCompressionNone:
+		bw.w = &bw.buf
+	case blockBatchCompressionGzip:
+		zw := gzip.NewWriter(&bw.buf)
+		bw.w, bw.closer = zw, zw
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+	return bw, nil
+}
+
+// WriteBlock appends the passed serialized block to the batch.
+func (bw *blockBatchWriter) WriteBlock(serializedBlock []byte) error {
+	var lenBytes [4]byte
+	binary.LittleEndian.PutUint32(lenBytes[:], uint32(len(serializedBlock)))
+	if _, err := bw.w.Write(lenBytes[:]); err != nil {
+		return err
+	}
+	if _, err := bw.w.Write(serializedBlock); err != nil {
+		return err
+	}
+	bw.blocks++
+	bw.size += len(lenBytes) + len(serializedBlock)
+	return nil
+}
+
+// Blocks returns the number of blocks written to the batch.
+func (bw *blockBatchWriter) Blocks() int {
+	return bw.blocks
+}
+
+// Size returns the size of the framed blocks written to the batch before
+// compression.
+func (bw *blockBatchWriter) Size() int {
+	return bw.size
+}
+
+// Bytes finishes the batch and returns it.  No more blocks may be written
+// afterwards.
+func (bw *blockBatchWriter) Bytes() ([]byte, error) {
+	if bw.closer != nil {
+		if err := bw.closer.Close(); err != nil {
+			return nil, err
+		}
+		bw.closer = nil
+	}
+	return bw.buf.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// readBlockBatch returns the serialized blocks framed in the passed
+// uncompressed block batch.
+func readBlockBatch(r io.Reader) ([][]byte, error) {
+	var blocks [][]byte
+	for {
+		var lenBytes [4]byte
+		_, err := io.ReadFull(r, lenBytes[:])
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		block := make([]byte, binary.LittleEndian.Uint32(lenBytes[:]))
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+// TestBlockBatchWriter ensures blocks are framed by their length and the batch
+// is compressed as requested.
+func TestBlockBatchWriter(t *testing.T) {
+	t.Parallel()
+
+	blocks := [][]byte{{0x01, 0x02, 0x03}, {}, bytes.Repeat([]byte{0xaa}, 1000)}
+	for _, compression := range []string{blockBatchCompressionNone,
+		blockBatchCompressionGzip} {
+
+		bw, err := newBlockBatchWriter(compression)
+		if err != nil {
+			t.Fatalf("%s: unable to create writer: %v", compression, err)
+		}
+		for _, block := range blocks {
+			if err := bw.WriteBlock(block); err != nil {
+				t.Fatalf("%s: unable to write block: %v", compression,
+					err)
+			}
+		}
+		if bw.Blocks() != len(blocks) || bw.Size() != 1003+4*len(blocks) {
+			t.Fatalf("%s: got %d blocks of %d bytes", compression,
+				bw.Blocks(), bw.Size())
+		}
+		batch, err := bw.Bytes()
+		if err != nil {
+			t.Fatalf("%s: unable to finish batch: %v", compression, err)
+		}
+
+		var r io.Reader = bytes.NewReader(batch)
+		if compression == blockBatchCompressionGzip {
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				t.Fatalf("batch is not gzip compressed: %v", err)
+			}
+			uncompressed, err := ioutil.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("unable to decompress batch: %v", err)
+			}
+			if len(uncompressed) != bw.Size() {
+				t.Fatalf("got %d uncompressed bytes, want %d",
+					len(uncompressed), bw.Size())
+			}
+			r = bytes.NewReader(uncompressed)
+		}
+		got, err := readBlockBatch(r)
+		if err != nil {
+			t.Fatalf("%s: unable to read batch: %v", compression, err)
+		}
+		if len(got) != len(blocks) {
+			t.Fatalf("%s: got %d blocks, want %d", compression,
+				len(got), len(blocks))
+		}
+		for i := range blocks {
+			if !bytes.Equal(got[i], blocks[i]) {
+				t.Fatalf("%s: block %d mismatch", compression, i)
+			}
+		}
+	}
+
+	if _, err := newBlockBatchWriter("lz4"); err == nil {
+		t.Fatal("unsupported compression accepted")
+	}
+}
@@ -5,6 +5,9 @@
 package blockchain
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/btcsuite/btcd/database"
 	"github.com/btcsuite/btcutil"
 )
@@ -18,6 +21,8 @@ import (
 // The flags modify the behavior of this function as follows:
 //  - BFDryRun: The block index will not be updated and no accept notification
 //    will be sent since the block is not being accepted.
+//  - BFCheckpointFastAdd: BFFastAdd is set when the block extends the main
+//    chain and is known to lead to a checkpoint by AddCheckpointHeaders.
 //
 // The flags are also passed to checkBlockContext and connectBestChain.  See
 // their documentation for how the flags modify their behavior.
@@ -35,12 +40,44 @@ func (b *BlockChain) maybeAcceptBlock(block *btcutil.Block, flags BehaviorFlags)
 	}
 	block.SetHeight(blockHeight)
 
+	// Reject blocks which build on a block that was marked invalid.
+	if prevNode != nil && prevNode.status.KnownInvalid() {
+		str := fmt.Sprintf("previous block %s is known to be invalid",
+			prevNode.hash)
+		return false, ruleError(ErrInvalidAncestorBlock, str)
+	}
+
+	// Reject blocks which were marked invalid while they were not loaded
+	// into the block index.
+	if b.index.UnloadedStatus(block.Hash()).KnownInvalid() {
+		str := fmt.Sprintf("block %s was marked invalid", block.Hash())
+		return false, ruleError(ErrInvalidatedBlock, str)
+	}
+
+	// Blocks which extend the main chain and are known to lead to a
+	// checkpoint are connected without most of the contextual checks when
+	// requested since the checkpoint already establishes their validity.
+	// Being at or below the height of a checkpoint is not enough, since
+	// nothing else ties the block to it.
+	if flags&BFCheckpointFastAdd == BFCheckpointFastAdd && prevNode != nil &&
+		prevNode == b.bestChain.Tip() {
+
+		if _, ok := b.checkpointAncestors[*block.Hash()]; ok {
+			flags |= BFFastAdd
+			if !dryRun {
+				delete(b.checkpointAncestors, *block.Hash())
+			}
+		}
+	}
+
 	// The block must pass all of the validation rules which depend on the
 	// position of the block within the block chain.
+	contextStart := time.Now()
 	err := b.checkBlockContext(block, prevNode, flags)
 	if err != nil {
 		return false, err
 	}
+	b.timings.since(PhaseContextChecks, contextStart)
 
 	// Insert the block into the database if it's not already there.  Even
 	// though it is possible the block will ultimately fail to connect, it
@@ -51,11 +88,18 @@ func (b *BlockChain) maybeAcceptBlock(block *btcutil.Block, flags BehaviorFlags)
 	// expensive connection logic.  It also has some other nice properties
 	// such as making blocks that never become part of the main chain or
 	// blocks that fail to connect available for further analysis.
-	err = b.db.Update(func(dbTx database.Tx) error {
-		return dbMaybeStoreBlock(dbTx, block)
-	})
-	if err != nil {
-		return false, err
+	//
+	// Blocks which extend the main chain are instead stored by the same
+	// database transaction which connects them, so the block data and the
+	// chain state it produces are committed atomically.
+	extendsMainChain := prevNode != nil && prevNode == b.bestChain.Tip()
+	if !extendsMainChain {
+		err = b.db.Update(func(dbTx database.Tx) error {
+			return dbMaybeStoreBlock(dbTx, block)
+		})
+		if err != nil {
+			return false, err
+		}
 	}
 
 	// Create a new block node for the block and add it to the in-memory
@@ -81,6 +125,18 @@ func (b *BlockChain) maybeAcceptBlock(block *btcutil.Block, flags BehaviorFlags)
 	// also handles validation of the transaction scripts.
 	isMainChain, err := b.connectBestChain(newNode, block, flags)
 	if err != nil {
+		// Store blocks which extend the main chain but fail to connect
+		// so they are available for further analysis like any other
+		// block that fails to connect.
+		if extendsMainChain && !dryRun {
+			storeErr := b.db.Update(func(dbTx database.Tx) error {
+				return dbMaybeStoreBlock(dbTx, block)
+			})
+			if storeErr != nil {
+				log.Warnf("Unable to store block %v: %v",
+					block.Hash(), storeErr)
+			}
+		}
 		return false, err
 	}
 
@@ -16,6 +16,24 @@ import (
 	"github.com/btcsuite/btcd/wire"
 )
 
+// blockStatus is a bit field representing the validation state of a block.
+type blockStatus byte
+
+const (
+	// statusValidateFailed indicates the block was marked invalid.
+	statusValidateFailed blockStatus = 1 << iota
+
+	// statusInvalidAncestor indicates one of the ancestors of the block
+	// was marked invalid.
+	statusInvalidAncestor
+)
+
+// KnownInvalid returns whether the block or one of its ancestors is known to be
+// invalid.
+func (status blockStatus) KnownInvalid() bool {
+	return status&(statusValidateFailed|statusInvalidAncestor) != 0
+}
+
 // blockNode represents a block within the block chain and is primarily used to
 // aid in selecting the best chain to be the main chain.  The main chain is
 // stored into the block database.
@@ -49,6 +67,10 @@ type blockNode struct {
 	nonce      uint32
 	timestamp  int64
 	merkleRoot chainhash.Hash
+
+	// status is a bit field representing the validation state of the
+	// block.  It must only be accessed with the chain state lock held.
+	status blockStatus
 }
 
 // initBlockNode initializes a block node from the given header and height.  The
@@ -181,6 +203,20 @@ type blockIndex struct {
 
 	sync.RWMutex
 	index map[chainhash.Hash]*blockNode
+
+	// tips houses the nodes which have no children in the index.  It is
+	// used to find the descendants of a node without visiting every node.
+	// Removing a node makes its parent a tip again even when it has other
+	// children, so it might also contain some nodes which are not tips.
+	tips map[*blockNode]struct{}
+
+	// unloaded houses the stored statuses of the blocks which are not in
+	// the index, such as side chain blocks after a restart.
+	unloaded map[chainhash.Hash]blockStatus
+
+	// dirty houses the statuses of the blocks which changed since the
+	// index was last flushed to the database.
+	dirty map[chainhash.Hash]blockStatus
 }
 
 // newBlockIndex returns a new empty instance of a block index.  The index will
@@ -191,6 +227,9 @@ func newBlockIndex(db database.DB, chainParams *chaincfg.Params) *blockIndex {
 		db:          db,
 		chainParams: chainParams,
 		index:       make(map[chainhash.Hash]*blockNode),
+		tips:        make(map[*blockNode]struct{}),
+		unloaded:    make(map[chainhash.Hash]blockStatus),
+		dirty:       make(map[chainhash.Hash]blockStatus),
 	}
 }
 
@@ -222,6 +261,8 @@ func (bi *blockIndex) LookupNode(hash *chainhash.Hash) *blockNode {
 func (bi *blockIndex) AddNode(node *blockNode) {
 	bi.Lock()
 	bi.index[node.hash] = node
+	bi.tips[node] = struct{}{}
+	delete(bi.tips, node.parent)
 	bi.Unlock()
 }
 
@@ -233,5 +274,148 @@ func (bi *blockIndex) AddNode(node *blockNode) {
 func (bi *blockIndex) RemoveNode(node *blockNode) {
 	bi.Lock()
 	delete(bi.index, node.hash)
+	delete(bi.tips, node)
+	if node.parent != nil {
+		bi.tips[node.parent] = struct{}{}
+	}
+	bi.Unlock()
+}
+
+// Descendants returns all of the nodes in the index which descend from the
+// provided node.  Only the branches leading to the tips above the height of the
+// node are walked, and each node is visited at most once.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) Descendants(node *blockNode) []*blockNode {
+	bi.RLock()
+	defer bi.RUnlock()
+
+	// descends records whether the nodes visited so far descend from the
+	// provided node, so the walks from tips sharing a branch stop where
+	// they meet.
+	descends := make(map[*blockNode]bool)
+	var descendants []*blockNode
+	for tip := range bi.tips {
+		var path []*blockNode
+		n := tip
+		for n != nil && n.height > node.height {
+			if _, ok := descends[n]; ok {
+				break
+			}
+			path = append(path, n)
+			n = n.parent
+		}
+
+		isDescendant := n == node
+		if n != nil && n.height > node.height {
+			isDescendant = descends[n]
+		}
+		for _, pathNode := range path {
+			descends[pathNode] = isDescendant
+			if isDescendant {
+				descendants = append(descendants, pathNode)
+			}
+		}
+	}
+	return descendants
+}
+
+// SetStatusFlags sets the provided status flags of the node and marks it dirty
+// when its stored status changes.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (bi *blockIndex) SetStatusFlags(node *blockNode, flags blockStatus) {
+	bi.Lock()
+	bi.setStatus(node, node.status|flags)
+	bi.Unlock()
+}
+
+// UnsetStatusFlags clears the provided status flags of the node and marks it
+// dirty when its stored status changes.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (bi *blockIndex) UnsetStatusFlags(node *blockNode, flags blockStatus) {
+	bi.Lock()
+	bi.setStatus(node, node.status&^flags)
+	bi.Unlock()
+}
+
+// setStatus sets the status of the node.  Only the statusValidateFailed flag is
+// stored since the statusInvalidAncestor flags are derived from it when the
+// blocks are loaded.
+//
+// This function MUST be called with the block index lock held (for writes).
+func (bi *blockIndex) setStatus(node *blockNode, status blockStatus) {
+	stored := status & statusValidateFailed
+	if node.status&statusValidateFailed != stored {
+		bi.dirty[node.hash] = stored
+	}
+	node.status = status
+}
+
+// setUnloadedStatuses sets the stored statuses of the blocks which are not in
+// the index.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) setUnloadedStatuses(statuses map[chainhash.Hash]blockStatus) {
+	bi.Lock()
+	bi.unloaded = statuses
 	bi.Unlock()
 }
+
+// UnloadedStatus returns the stored status of the block with the provided hash
+// when it is not in the index.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) UnloadedStatus(hash *chainhash.Hash) blockStatus {
+	bi.RLock()
+	status := bi.unloaded[*hash]
+	bi.RUnlock()
+	return status
+}
+
+// ClearUnloadedStatus removes the stored status of the block with the provided
+// hash when it is not in the index.  It returns whether there was one.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) ClearUnloadedStatus(hash *chainhash.Hash) bool {
+	bi.Lock()
+	defer bi.Unlock()
+
+	if _, ok := bi.unloaded[*hash]; !ok {
+		return false
+	}
+	delete(bi.unloaded, *hash)
+	bi.dirty[*hash] = 0
+	return true
+}
+
+// flushToDB writes the statuses which changed since the last flush to the
+// database.  They are kept dirty when the write fails so the next flush
+// retries them.
+//
+// This function is safe for concurrent access.
+func (bi *blockIndex) flushToDB() error {
+	bi.Lock()
+	defer bi.Unlock()
+
+	if len(bi.dirty) == 0 {
+		return nil
+	}
+	err := bi.db.Update(func(dbTx database.Tx) error {
+		for hash, status := range bi.dirty {
+			hash := hash
+			err := dbPutBlockStatus(dbTx, &hash, status)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	bi.dirty = make(map[chainhash.Hash]blockStatus)
+	return nil
+}
@@ -100,6 +100,8 @@ type BlockChain struct {
 	sigCache            *txscript.SigCache
 	indexManager        IndexManager
 	hashCache           *txscript.HashCache
+	undoDepth           int32
+	maxReorgDepth       int32
 
 	// The following fields are calculated based upon the provided chain
 	// parameters.  They are also set when the instance is created and
@@ -137,6 +139,21 @@ type BlockChain struct {
 	nextCheckpoint *chaincfg.Checkpoint
 	checkpointNode *blockNode
 
+	// checkpointAncestors holds the hashes of the blocks which are known
+	// to lead to a checkpoint by AddCheckpointHeaders and have not been
+	// connected yet.  It is protected by the chain lock.
+	checkpointAncestors map[chainhash.Hash]struct{}
+
+	// undoPruneHeight is the height below which the spend journal entries
+	// of the main chain blocks were pruned.  It is protected by the chain
+	// lock.
+	undoPruneHeight int32
+
+	// pendingReorgs tracks the competing chains which were not switched to
+	// because the reorganization would exceed the maximum depth, keyed by
+	// the hash of their fork point.  It is protected by the chain lock.
+	pendingReorgs map[chainhash.Hash]*pendingReorg
+
 	// The state is used as a fairly efficient way to cache information
 	// about the current best chain state that is returned to callers when
 	// requested.  It operates on the principle of MVCC such that any time a
@@ -183,6 +200,14 @@ type BlockChain struct {
 	// certain blockchain events.
 	notificationsLock sync.RWMutex
 	notifications     []NotificationCallback
+
+	// timings tracks the time spent in each phase of block validation.  It
+	// has its own lock.
+	timings validationTimings
+
+	// txCounts tracks the number of transactions of the most recent main
+	// chain blocks for the transaction statistics.  It has its own lock.
+	txCounts txCountTracker
 }
 
 // HaveBlock returns whether or not the chain instance has the block represented
@@ -589,10 +614,26 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block, view *U
 	state := newBestState(node, blockSize, blockWeight, numTxns,
 		curTotalTxns+numTxns, node.CalcPastMedianTime())
 
-	// Atomically insert info into the database.
+	// Atomically insert info into the database.  The time spent updating
+	// the optional indexes is tracked separately from the time spent
+	// flushing the rest of the changes.
+	undoPruneHeight := b.undoPruneTarget(node.height)
+	var indexTime time.Duration
+	flushStart := time.Now()
 	err := b.db.Update(func(dbTx database.Tx) error {
+		// Store the block if it's not already there.  The database
+		// appends the block data to the block files before committing
+		// the metadata which references it and discards any data which
+		// was appended without being committed when it is opened, so
+		// the block, utxo set, spend journal, and index updates are all
+		// committed together, even across an unclean shutdown.
+		err := dbMaybeStoreBlock(dbTx, block)
+		if err != nil {
+			return err
+		}
+
 		// Update best block state.
-		err := dbPutBestState(dbTx, state, node.workSum)
+		err = dbPutBestState(dbTx, state, node.workSum)
 		if err != nil {
 			return err
 		}
@@ -619,11 +660,22 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block, view *U
 			return err
 		}
 
+		// Prune the spend journal entries of the blocks which are now
+		// deeper than the configured undo depth.
+		if undoPruneHeight > b.undoPruneHeight {
+			err := b.dbPruneSpendJournal(dbTx, node, undoPruneHeight)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Allow the index manager to call each of the currently active
 		// optional indexes with the block being connected so they can
 		// update themselves accordingly.
 		if b.indexManager != nil {
+			indexStart := time.Now()
 			err := b.indexManager.ConnectBlock(dbTx, block, view)
+			indexTime = time.Since(indexStart)
 			if err != nil {
 				return err
 			}
@@ -634,10 +686,16 @@ func (b *BlockChain) connectBlock(node *blockNode, block *btcutil.Block, view *U
 	if err != nil {
 		return err
 	}
+	if b.indexManager != nil {
+		b.timings.record(PhaseIndexUpdate, indexTime)
+	}
+	b.timings.record(PhaseDBFlush, time.Since(flushStart)-indexTime)
 
 	// Prune fully spent entries and mark all entries in the view unmodified
 	// now that the modifications have been committed to the database.
 	view.commit()
+	b.undoPruneHeight = undoPruneHeight
+	b.txCounts.connect(node.height, numTxns)
 
 	// This node is now the end of the best chain.
 	b.bestChain.SetTip(node)
@@ -744,6 +802,7 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *btcutil.Block, view
 	// Prune fully spent entries and mark all entries in the view unmodified
 	// now that the modifications have been committed to the database.
 	view.commit()
+	b.txCounts.disconnect(node.height)
 
 	// This node's parent is now the end of the best chain.
 	b.bestChain.SetTip(node.parent)
@@ -800,6 +859,19 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List, flags
 	detachSpentTxOuts := make([][]spentTxOut, 0, detachNodes.Len())
 	attachBlocks := make([]*btcutil.Block, 0, attachNodes.Len())
 
+	// The blocks can only be disconnected while their spend journal entries
+	// are available.
+	if detachNodes.Len() > 0 {
+		oldest := detachNodes.Back().Value.(*blockNode)
+		if oldest.height < b.undoPruneHeight {
+			str := fmt.Sprintf("reorganization would disconnect "+
+				"block %v at height %d, but the spend journal "+
+				"entries of the blocks below height %d were pruned",
+				oldest.hash, oldest.height, b.undoPruneHeight)
+			return ruleError(ErrUndoDataPruned, str)
+		}
+	}
+
 	// Disconnect all of the blocks back to the point of the fork.  This
 	// entails loading the blocks and their associated spent txos from the
 	// database and using that information to unspend all of the spent txos
@@ -953,13 +1025,37 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List, flags
 	}
 
 	// Log the point where the chain forked and old and new best chain
-	// heads.
-	firstAttachNode := attachNodes.Front().Value.(*blockNode)
-	firstDetachNode := detachNodes.Front().Value.(*blockNode)
-	lastAttachNode := attachNodes.Back().Value.(*blockNode)
-	log.Infof("REORGANIZE: Chain forks at %v", firstAttachNode.parent.hash)
-	log.Infof("REORGANIZE: Old best chain head was %v", firstDetachNode.hash)
-	log.Infof("REORGANIZE: New best chain head is %v", lastAttachNode.hash)
+	// heads.  There are no blocks to attach when the chain is rolled back
+	// to an earlier block.
+	var fork *blockNode
+	if attachNodes.Len() > 0 {
+		fork = attachNodes.Front().Value.(*blockNode).parent
+	} else {
+		fork = detachNodes.Back().Value.(*blockNode).parent
+	}
+	log.Infof("REORGANIZE: Chain forks at %v", fork.hash)
+	if detachNodes.Len() > 0 {
+		firstDetachNode := detachNodes.Front().Value.(*blockNode)
+		log.Infof("REORGANIZE: Old best chain head was %v",
+			firstDetachNode.hash)
+	}
+	log.Infof("REORGANIZE: New best chain head is %v",
+		b.bestChain.Tip().hash)
+
+	// Notify the caller of the reorganization as a whole now that all of
+	// the blocks have been disconnected and connected.  Merely extending
+	// the chain is not a reorganization.
+	if detachNodes.Len() == 0 {
+		return nil
+	}
+	b.chainLock.Unlock()
+	b.sendNotification(NTReorganization, &Reorganization{
+		ForkHash:           fork.hash,
+		ForkHeight:         fork.height,
+		DisconnectedBlocks: detachBlocks,
+		ConnectedBlocks:    attachBlocks,
+	})
+	b.chainLock.Lock()
 
 	return nil
 }
@@ -1011,10 +1107,12 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 		// utxos, spend them, and add the new utxos being created by
 		// this block.
 		if fastAdd {
+			fetchStart := time.Now()
 			err := view.fetchInputUtxos(b.db, block)
 			if err != nil {
 				return false, err
 			}
+			b.timings.since(PhaseUtxoFetch, fetchStart)
 			err = view.connectTransactions(block, &stxos)
 			if err != nil {
 				return false, err
@@ -1057,6 +1155,12 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 		return false, nil
 	}
 
+	// Hold back the reorganization when it would disconnect more blocks
+	// than the configured maximum depth until the operator approves it.
+	if !dryRun && !b.reorgAllowed(node) {
+		return false, nil
+	}
+
 	// We're extending (or creating) a side chain and the cumulative work
 	// for this new side chain is more than the old best chain, so this side
 	// chain needs to become the main chain.  In order to accomplish that,
@@ -1498,6 +1602,24 @@ type Config struct {
 	// This field can be nil if the caller is not interested in using a
 	// signature cache.
 	HashCache *txscript.HashCache
+
+	// UndoDepth is the number of the most recent main chain blocks for
+	// which the spend journal entries, which are needed to disconnect the
+	// blocks, are kept.  The entries of older blocks are pruned, so the
+	// chain can't be reorganized deeper than this depth.  Once pruned, the
+	// entries are not restored by increasing the depth.
+	//
+	// This field must either be zero, which keeps all entries, or at least
+	// MinUndoDepth.
+	UndoDepth int32
+
+	// MaxReorgDepth is the maximum number of main chain blocks a
+	// reorganization may disconnect.  Deeper reorganizations are held back
+	// until they are approved with ApproveReorg, which protects against a
+	// competing chain silently replacing a large part of the main chain.
+	//
+	// This field can be zero to allow reorganizations of any depth.
+	MaxReorgDepth int32
 }
 
 // New returns a BlockChain instance using the provided configuration details.
@@ -1512,6 +1634,10 @@ func New(config *Config) (*BlockChain, error) {
 	if config.TimeSource == nil {
 		return nil, AssertError("blockchain.New timesource is nil")
 	}
+	if config.UndoDepth != 0 && config.UndoDepth < MinUndoDepth {
+		return nil, AssertError("blockchain.New undo depth is less " +
+			"than the minimum")
+	}
 
 	// Generate a checkpoint by height map from the provided checkpoints
 	// and assert the provided checkpoints are sorted by height as required.
@@ -1548,12 +1674,16 @@ func New(config *Config) (*BlockChain, error) {
 		blocksPerRetarget:   int32(targetTimespan / targetTimePerBlock),
 		index:               newBlockIndex(config.DB, params),
 		hashCache:           config.HashCache,
+		undoDepth:           config.UndoDepth,
+		maxReorgDepth:       config.MaxReorgDepth,
 		bestChain:           newChainView(nil),
 		orphans:             make(map[chainhash.Hash]*orphanBlock),
 		prevOrphans:         make(map[chainhash.Hash][]*orphanBlock),
+		pendingReorgs:       make(map[chainhash.Hash]*pendingReorg),
 		warningCaches:       newThresholdCaches(vbNumBits),
 		deploymentCaches:    newThresholdCaches(chaincfg.DefinedDeployments),
 	}
+	b.txCounts.size = b.DefaultChainTxStatsWindow()
 
 	// Initialize the chain state from the passed database.  When the db
 	// does not yet contain any chain state, both it and the chain state
@@ -1562,6 +1692,12 @@ func New(config *Config) (*BlockChain, error) {
 		return nil, err
 	}
 
+	// Load the height the spend journal is pruned to and prune the entries
+	// exceeding the configured depth.
+	if err := b.initUndoPruning(config.Interrupt); err != nil {
+		return nil, err
+	}
+
 	// Initialize and catch up all of the currently active optional indexes
 	// as needed.
 	if config.IndexManager != nil {
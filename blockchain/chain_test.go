@@ -11,6 +11,7 @@ import (
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 )
@@ -111,6 +112,174 @@ func TestHaveBlock(t *testing.T) {
 	}
 }
 
+// TestCheckpointFastAdd ensures blocks which are known to lead to a checkpoint
+// skip the contextual checks when BFCheckpointFastAdd is set while blocks after
+// it, and blocks whose headers were never linked to it, are still fully
+// validated.
+func TestCheckpointFastAdd(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("checkpointfastadd",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// Use a checkpoint at block 3 so only block 4 is fully validated.
+	chain.checkpoints = []chaincfg.Checkpoint{
+		{Height: 3, Hash: blocks[3].Hash()},
+	}
+	chain.checkpointsByHeight = map[int32]*chaincfg.Checkpoint{
+		3: &chain.checkpoints[0],
+	}
+
+	// The coinbase maturity is intentionally left at the default so the
+	// test blocks, which spend immature coinbases, are only accepted when
+	// the checks are skipped.  Being below the checkpoint is not enough
+	// on its own.
+	_, _, err = chain.ProcessBlock(blocks[1], BFCheckpointFastAdd)
+	if err != nil {
+		t.Fatalf("ProcessBlock fail on block 1: %v", err)
+	}
+	_, _, err = chain.ProcessBlock(blocks[2], BFCheckpointFastAdd|BFDryRun)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrImmatureSpend {
+		t.Fatalf("ProcessBlock: unexpected error for block not known "+
+			"to lead to the checkpoint: %v", err)
+	}
+
+	// Only the headers which lead to the checkpoint are marked.
+	var headers []wire.BlockHeader
+	for i := len(blocks) - 1; i > 0; i-- {
+		headers = append(headers, blocks[i].MsgBlock().Header)
+	}
+	orphan := blocks[2].MsgBlock().Header
+	orphan.Nonce++
+	headers = append(headers, orphan)
+	if n := chain.AddCheckpointHeaders(headers[1:2]); n != 0 {
+		t.Fatalf("AddCheckpointHeaders: marked %d blocks from a broken "+
+			"chain", n)
+	}
+	if n := chain.AddCheckpointHeaders(headers); n != 2 {
+		t.Fatalf("AddCheckpointHeaders: marked %d blocks, want 2", n)
+	}
+
+	for i := 2; i <= 3; i++ {
+		isMainChain, _, err := chain.ProcessBlock(blocks[i],
+			BFCheckpointFastAdd)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v", i, err)
+		}
+		if !isMainChain {
+			t.Fatalf("ProcessBlock: block %v is not in the main chain", i)
+		}
+	}
+	_, _, err = chain.ProcessBlock(blocks[4], BFCheckpointFastAdd)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrImmatureSpend {
+		t.Fatalf("ProcessBlock: unexpected error for block after the "+
+			"checkpoint: %v", err)
+	}
+}
+
+// TestConnectStoresBlock ensures blocks which extend the main chain are stored
+// when they are connected as well as when they fail to connect.
+func TestConnectStoresBlock(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("connectstoresblock",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// The second block spends the immature coinbase of the first block, so
+	// it fails to connect with the default coinbase maturity.
+	if _, _, err := chain.ProcessBlock(blocks[1], BFNone); err != nil {
+		t.Fatalf("ProcessBlock fail on block 1: %v", err)
+	}
+	_, _, err = chain.ProcessBlock(blocks[2], BFNone)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrImmatureSpend {
+		t.Fatalf("ProcessBlock: unexpected error for block 2: %v", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		err := chain.db.View(func(dbTx database.Tx) error {
+			hasBlock, err := dbTx.HasBlock(blocks[i].Hash())
+			if err == nil && !hasBlock {
+				t.Errorf("block %d was not stored", i)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatalf("HasBlock: unexpected error: %v", err)
+		}
+	}
+	if !chain.MainChainHasBlock(blocks[1].Hash()) ||
+		chain.MainChainHasBlock(blocks[2].Hash()) {
+
+		t.Fatal("unexpected main chain after connecting blocks")
+	}
+}
+
+// TestForEachUtxoEntry ensures the utxo set can be walked in pages.
+func TestForEachUtxoEntry(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("foreachutxoentry",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	if _, _, err := chain.ProcessBlock(blocks[1], BFNone); err != nil {
+		t.Fatalf("ProcessBlock fail on block 1: %v", err)
+	}
+	coinbaseHash := blocks[1].Transactions()[0].Hash()
+
+	// An empty page must only return where to resume.
+	var visited []chainhash.Hash
+	visit := func(txHash *chainhash.Hash, entry *UtxoEntry) error {
+		if len(entry.UnspentOutputs()) == 0 {
+			t.Errorf("entry for %v has no unspent outputs", txHash)
+		}
+		visited = append(visited, *txHash)
+		return nil
+	}
+	next, best, err := chain.ForEachUtxoEntry(nil, 0, visit)
+	if err != nil {
+		t.Fatalf("ForEachUtxoEntry: unexpected error: %v", err)
+	}
+	if next == nil || *next != *coinbaseHash || len(visited) != 0 {
+		t.Fatalf("ForEachUtxoEntry: unexpected empty page - next %v, "+
+			"visited %v", next, visited)
+	}
+	if best.Height != 1 {
+		t.Fatalf("ForEachUtxoEntry: unexpected best height %d",
+			best.Height)
+	}
+
+	// Resuming must visit the remaining entry and reach the end.
+	next, _, err = chain.ForEachUtxoEntry(next, 10, visit)
+	if err != nil {
+		t.Fatalf("ForEachUtxoEntry: unexpected error: %v", err)
+	}
+	if next != nil || len(visited) != 1 || visited[0] != *coinbaseHash {
+		t.Fatalf("ForEachUtxoEntry: unexpected page - next %v, "+
+			"visited %v", next, visited)
+	}
+}
+
 // TestCalcSequenceLock tests the LockTimeToSequence function, and the
 // CalcSequenceLock method of a Chain instance. The tests exercise several
 // combinations of inputs to the CalcSequenceLock function in order to ensure
@@ -0,0 +1,137 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// ComparedChain describes one of the chains compared by CompareChains.
+type ComparedChain struct {
+	// Hash and Height identify the block the chain ends at.
+	Hash   chainhash.Hash
+	Height int32
+
+	// Work is the total amount of work in the chain up to and including
+	// the block.
+	Work *big.Int
+
+	// BranchLength and BranchWork are the number of blocks and the amount
+	// of work in the chain after the fork point.
+	BranchLength int32
+	BranchWork   *big.Int
+
+	// MainChain indicates whether the block is in the main chain.
+	MainChain bool
+}
+
+// ChainComparison describes how the chains ending at two blocks relate to each
+// other.
+type ChainComparison struct {
+	// ForkHash and ForkHeight identify the last block the chains have in
+	// common.  When one of the blocks is an ancestor of the other, it is
+	// the fork point itself.
+	ForkHash   chainhash.Hash
+	ForkHeight int32
+
+	// ForkWork is the total amount of work in the chain up to and
+	// including the fork point.
+	ForkWork *big.Int
+
+	// Chains describes the compared chains in the order the blocks were
+	// passed.
+	Chains [2]ComparedChain
+}
+
+// UnknownBlockError identifies an error when the hash of a block which is not in
+// the block index is passed to a function which requires a known block.
+type UnknownBlockError chainhash.Hash
+
+// Error returns the error as a human-readable string and satisfies the error
+// interface.
+func (e UnknownBlockError) Error() string {
+	return fmt.Sprintf("block %s is not known", chainhash.Hash(e))
+}
+
+// lookupKnownNode returns the block node for the passed hash or an error of type
+// UnknownBlockError when the block is not in the block index.
+func (b *BlockChain) lookupKnownNode(hash *chainhash.Hash) (*blockNode, error) {
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		return nil, UnknownBlockError(*hash)
+	}
+	return node, nil
+}
+
+// ChainWork returns the total amount of work in the chain up to and including
+// the block with the given hash.  The block does not have to be in the main
+// chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ChainWork(hash *chainhash.Hash) (*big.Int, error) {
+	node, err := b.lookupKnownNode(hash)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).Set(node.workSum), nil
+}
+
+// findCommonAncestor returns the last block the chains ending at the passed
+// nodes have in common or nil if there is none.
+func findCommonAncestor(a, b *blockNode) *blockNode {
+	if a.height > b.height {
+		a = a.Ancestor(b.height)
+	} else if b.height > a.height {
+		b = b.Ancestor(a.height)
+	}
+	for a != b {
+		a = a.parent
+		b = b.parent
+	}
+	return a
+}
+
+// CompareChains returns how the chains ending at the blocks with the given
+// hashes relate to each other, that is their fork point along with the length
+// and work of each chain.  Neither block has to be in the main chain, which
+// makes it possible to monitor forks without calculating the chain work on the
+// client side.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CompareChains(hash1, hash2 *chainhash.Hash) (*ChainComparison, error) {
+	node1, err := b.lookupKnownNode(hash1)
+	if err != nil {
+		return nil, err
+	}
+	node2, err := b.lookupKnownNode(hash2)
+	if err != nil {
+		return nil, err
+	}
+	fork := findCommonAncestor(node1, node2)
+	if fork == nil {
+		return nil, fmt.Errorf("blocks %s and %s have no common "+
+			"ancestor", hash1, hash2)
+	}
+
+	comparison := &ChainComparison{
+		ForkHash:   fork.hash,
+		ForkHeight: fork.height,
+		ForkWork:   new(big.Int).Set(fork.workSum),
+	}
+	for i, node := range []*blockNode{node1, node2} {
+		comparison.Chains[i] = ComparedChain{
+			Hash:         node.hash,
+			Height:       node.height,
+			Work:         new(big.Int).Set(node.workSum),
+			BranchLength: node.height - fork.height,
+			BranchWork:   new(big.Int).Sub(node.workSum, fork.workSum),
+			MainChain:    b.bestChain.Contains(node),
+		}
+	}
+	return comparison, nil
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// TestCompareChains ensures the fork point, lengths, and work of two chains
+// are reported as expected.
+func TestCompareChains(t *testing.T) {
+	// Construct a synthetic block chain with a block index consisting of
+	// the following structure, where the side chain blocks are harder.
+	// 	genesis -> 1 -> 2 -> 3 -> 4 -> 5
+	// 	                \-> 3a -> 4a
+	params := &chaincfg.MainNetParams
+	chain := newFakeChain(params)
+	blockTime := time.Unix(params.GenesisBlock.Header.Timestamp.Unix(), 0)
+	mainNodes := make([]*blockNode, 0, 5)
+	node := chain.bestChain.Genesis()
+	for i := 0; i < 5; i++ {
+		blockTime = blockTime.Add(time.Minute)
+		node = newFakeNode(node, 1, params.PowLimitBits, blockTime)
+		chain.index.AddNode(node)
+		mainNodes = append(mainNodes, node)
+	}
+	chain.bestChain.SetTip(node)
+	const sideBits = 0x1c0fffff
+	sideNodes := make([]*blockNode, 0, 2)
+	node = mainNodes[1]
+	for i := 0; i < 2; i++ {
+		blockTime = blockTime.Add(time.Minute)
+		node = newFakeNode(node, 1, sideBits, blockTime)
+		chain.index.AddNode(node)
+		sideNodes = append(sideNodes, node)
+	}
+
+	mainWork := CalcWork(params.PowLimitBits)
+	sideWork := CalcWork(sideBits)
+	mulWork := func(work *big.Int, n int64) *big.Int {
+		return new(big.Int).Mul(work, big.NewInt(n))
+	}
+
+	// The chain work of a block includes the work of the genesis block.
+	work, err := chain.ChainWork(&mainNodes[4].hash)
+	if err != nil {
+		t.Fatalf("ChainWork: unexpected error: %v", err)
+	}
+	if want := mulWork(mainWork, 6); work.Cmp(want) != 0 {
+		t.Fatalf("ChainWork: got %v, want %v", work, want)
+	}
+
+	comparison, err := chain.CompareChains(&mainNodes[4].hash,
+		&sideNodes[1].hash)
+	if err != nil {
+		t.Fatalf("CompareChains: unexpected error: %v", err)
+	}
+	if comparison.ForkHash != mainNodes[1].hash ||
+		comparison.ForkHeight != 2 ||
+		comparison.ForkWork.Cmp(mulWork(mainWork, 3)) != 0 {
+
+		t.Fatalf("CompareChains: unexpected fork point %v (height %d, "+
+			"work %v)", comparison.ForkHash, comparison.ForkHeight,
+			comparison.ForkWork)
+	}
+	tests := []struct {
+		hash         chainhash.Hash
+		height       int32
+		branchLength int32
+		branchWork   *big.Int
+		mainChain    bool
+	}{
+		{mainNodes[4].hash, 5, 3, mulWork(mainWork, 3), true},
+		{sideNodes[1].hash, 4, 2, mulWork(sideWork, 2), false},
+	}
+	for i, test := range tests {
+		got := comparison.Chains[i]
+		wantWork := new(big.Int).Add(comparison.ForkWork, test.branchWork)
+		if got.Hash != test.hash || got.Height != test.height ||
+			got.BranchLength != test.branchLength ||
+			got.BranchWork.Cmp(test.branchWork) != 0 ||
+			got.Work.Cmp(wantWork) != 0 ||
+			got.MainChain != test.mainChain {
+
+			t.Fatalf("CompareChains: unexpected chain #%d: %+v", i, got)
+		}
+	}
+
+	// A block which is an ancestor of the other is the fork point itself.
+	comparison, err = chain.CompareChains(&mainNodes[0].hash,
+		&sideNodes[0].hash)
+	if err != nil {
+		t.Fatalf("CompareChains: unexpected error: %v", err)
+	}
+	if comparison.ForkHash != mainNodes[0].hash ||
+		comparison.Chains[0].BranchLength != 0 ||
+		comparison.Chains[1].BranchLength != 2 {
+
+		t.Fatalf("CompareChains: unexpected comparison with ancestor "+
+			"%+v", comparison)
+	}
+
+	// Unknown blocks are rejected.
+	var unknown chainhash.Hash
+	if _, err := chain.CompareChains(&unknown, &mainNodes[0].hash); err == nil {
+		t.Fatal("CompareChains: expected error for unknown block")
+	}
+	if _, err := chain.ChainWork(&unknown); err == nil {
+		t.Fatal("ChainWork: expected error for unknown block")
+	}
+}
@@ -39,6 +39,15 @@ var (
 	// unspent transaction output set.
 	utxoSetBucketName = []byte("utxoset")
 
+	// undoPruneHeightKeyName is the name of the db key used to store the
+	// height below which the spend journal entries of the main chain
+	// blocks were pruned.
+	undoPruneHeightKeyName = []byte("undopruneheight")
+
+	// blockStatusBucketName is the name of the db bucket used to house the
+	// status of the blocks which were marked invalid.
+	blockStatusBucketName = []byte("blockstatus")
+
 	// byteOrder is the preferred byte order used for serializing numeric
 	// fields for storage in the database.
 	byteOrder = binary.LittleEndian
@@ -455,6 +464,32 @@ func dbRemoveSpendJournalEntry(dbTx database.Tx, blockHash *chainhash.Hash) erro
 	return spendBucket.Delete(blockHash[:])
 }
 
+// dbFetchUndoPruneHeight uses an existing database transaction to fetch the
+// height below which the spend journal entries of the main chain blocks were
+// pruned.  Zero is returned when no entries were pruned.
+func dbFetchUndoPruneHeight(dbTx database.Tx) (int32, error) {
+	serialized := dbTx.Metadata().Get(undoPruneHeightKeyName)
+	if serialized == nil {
+		return 0, nil
+	}
+	if len(serialized) != 4 {
+		return 0, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt undo prune height",
+		}
+	}
+	return int32(byteOrder.Uint32(serialized)), nil
+}
+
+// dbPutUndoPruneHeight uses an existing database transaction to store the
+// height below which the spend journal entries of the main chain blocks were
+// pruned.
+func dbPutUndoPruneHeight(dbTx database.Tx, height int32) error {
+	var serialized [4]byte
+	byteOrder.PutUint32(serialized[:], uint32(height))
+	return dbTx.Metadata().Put(undoPruneHeightKeyName, serialized[:])
+}
+
 // -----------------------------------------------------------------------------
 // The unspent transaction output (utxo) set consists of an entry for each
 // transaction which contains a utxo serialized using a format that is highly
@@ -918,6 +953,58 @@ func dbRemoveBlockIndex(dbTx database.Tx, hash *chainhash.Hash, height int32) er
 	return heightIndex.Delete(serializedHeight[:])
 }
 
+// -----------------------------------------------------------------------------
+// The block status bucket houses an entry for every block which was marked
+// invalid with InvalidateBlock, keyed by the hash of the block.  Only the marks
+// which were set explicitly are stored since the marks of the descendants are
+// derived from them when the blocks are loaded.  The bucket is created when
+// the first entry is stored, so it does not exist in older databases.
+//
+// The serialized format for values in the block status bucket is:
+//   <status>
+//
+//   Field      Type     Size
+//   status     uint8    1 byte
+// -----------------------------------------------------------------------------
+
+// dbPutBlockStatus uses an existing database transaction to update the stored
+// status of the block with the provided hash.  The entry is removed when the
+// status is zero.
+func dbPutBlockStatus(dbTx database.Tx, hash *chainhash.Hash, status blockStatus) error {
+	bucket, err := dbTx.Metadata().CreateBucketIfNotExists(
+		blockStatusBucketName)
+	if err != nil {
+		return err
+	}
+	if status == 0 {
+		return bucket.Delete(hash[:])
+	}
+	return bucket.Put(hash[:], []byte{byte(status)})
+}
+
+// dbFetchBlockStatuses uses an existing database transaction to retrieve the
+// stored status of all of the blocks which have one.
+func dbFetchBlockStatuses(dbTx database.Tx) (map[chainhash.Hash]blockStatus, error) {
+	statuses := make(map[chainhash.Hash]blockStatus)
+	bucket := dbTx.Metadata().Bucket(blockStatusBucketName)
+	if bucket == nil {
+		return statuses, nil
+	}
+	err := bucket.ForEach(func(k, v []byte) error {
+		if len(k) != chainhash.HashSize || len(v) != 1 {
+			return database.Error{
+				ErrorCode:   database.ErrCorruption,
+				Description: "corrupt block status entry",
+			}
+		}
+		var hash chainhash.Hash
+		copy(hash[:], k)
+		statuses[hash] = blockStatus(v[0])
+		return nil
+	})
+	return statuses, err
+}
+
 // dbFetchHeightByHash uses an existing database transaction to retrieve the
 // height for the provided hash from the index.
 func dbFetchHeightByHash(dbTx database.Tx, hash *chainhash.Hash) (int32, error) {
@@ -1151,6 +1238,10 @@ func (b *BlockChain) initChainState() error {
 		// for them versus a whole bunch of little ones to reduce
 		// pressure on the GC.
 		log.Infof("Loading block index.  This might take a while...")
+		statuses, err := dbFetchBlockStatuses(dbTx)
+		if err != nil {
+			return err
+		}
 		bestHeight := int32(state.height)
 		blockNodes := make([]blockNode, bestHeight+1)
 		var tip *blockNode
@@ -1169,6 +1260,11 @@ func (b *BlockChain) initChainState() error {
 				node.workSum = node.workSum.Add(tip.workSum,
 					node.workSum)
 			}
+			node.status = statuses[node.hash]
+			delete(statuses, node.hash)
+			if tip != nil && tip.status.KnownInvalid() {
+				node.status |= statusInvalidAncestor
+			}
 			b.index.AddNode(node)
 
 			// This node is now the end of the best chain.
@@ -1188,6 +1284,11 @@ func (b *BlockChain) initChainState() error {
 		}
 		b.bestChain.SetTip(tip)
 
+		// The remaining statuses belong to side chain blocks, which are
+		// not loaded, so they are kept to reject the blocks when they
+		// are received again.
+		b.index.setUnloadedStatuses(statuses)
+
 		// Load the raw block bytes for the best block.
 		blockBytes, err := dbTx.FetchBlock(&state.hash)
 		if err != nil {
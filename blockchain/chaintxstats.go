@@ -0,0 +1,217 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// chainTxStatsWindowTime is the amount of time covered by the default window
+// of blocks the transaction statistics are calculated over.
+const chainTxStatsWindowTime = time.Hour * 24 * 30
+
+// ChainTxStats describes the transactions of the main chain up to a block and
+// the rate they were added at over a window of blocks ending with it.
+type ChainTxStats struct {
+	// Time is the timestamp of the final block of the window.
+	Time time.Time
+
+	// TxCount is the total number of transactions in the main chain up to
+	// and including the final block of the window.
+	TxCount uint64
+
+	// FinalHash and FinalHeight identify the final block of the window.
+	FinalHash   chainhash.Hash
+	FinalHeight int32
+
+	// WindowBlockCount is the number of blocks in the window.
+	WindowBlockCount int32
+
+	// WindowTxCount is the number of transactions in the window.
+	WindowTxCount uint64
+
+	// WindowInterval is the time elapsed between the median times of the
+	// block before the window and the final block of the window.  The
+	// median times are used since, unlike the timestamps, they can't go
+	// back in time.
+	WindowInterval time.Duration
+}
+
+// txCountTracker keeps the number of transactions of the most recent main chain
+// blocks, so the transaction statistics over the default window don't need to
+// load the blocks.  Counts of older blocks are loaded from the database as
+// needed without being kept.  It has its own lock since counts are added while
+// the statistics are calculated with the chain lock held for reads.
+type txCountTracker struct {
+	mtx    sync.Mutex
+	size   int32
+	counts map[int32]uint64
+}
+
+// connect records the number of transactions of the block connected at the
+// passed height to the main chain and forgets the counts which are no longer
+// tracked.
+//
+// This function is safe for concurrent access.
+func (t *txCountTracker) connect(height int32, numTxns uint64) {
+	t.mtx.Lock()
+	if t.counts == nil {
+		t.counts = make(map[int32]uint64)
+	}
+	t.counts[height] = numTxns
+	delete(t.counts, height-t.size)
+	t.mtx.Unlock()
+}
+
+// disconnect forgets the number of transactions of the block disconnected at
+// the passed height from the main chain.
+//
+// This function is safe for concurrent access.
+func (t *txCountTracker) disconnect(height int32) {
+	t.mtx.Lock()
+	delete(t.counts, height)
+	t.mtx.Unlock()
+}
+
+// count returns the number of transactions of the passed main chain block,
+// which ends the main chain at the passed height.  The count is loaded from the
+// database when it is not tracked and kept when the block is recent enough.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (t *txCountTracker) count(db database.DB, node *blockNode, tipHeight int32) (uint64, error) {
+	t.mtx.Lock()
+	numTxns, ok := t.counts[node.height]
+	t.mtx.Unlock()
+	if ok {
+		return numTxns, nil
+	}
+
+	err := db.View(func(dbTx database.Tx) error {
+		var err error
+		numTxns, err = dbFetchBlockTxCount(dbTx, &node.hash)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if node.height > tipHeight-t.size {
+		t.mtx.Lock()
+		if t.counts == nil {
+			t.counts = make(map[int32]uint64)
+		}
+		t.counts[node.height] = numTxns
+		t.mtx.Unlock()
+	}
+	return numTxns, nil
+}
+
+// dbFetchBlockTxCount uses an existing database transaction to load the number
+// of transactions of the block with the passed hash without loading the entire
+// block.
+func dbFetchBlockTxCount(dbTx database.Tx, hash *chainhash.Hash) (uint64, error) {
+	// The transaction count directly follows the block header.  A block
+	// is always larger than the header plus a maximum size count.
+	region, err := dbTx.FetchBlockRegion(&database.BlockRegion{
+		Hash:   hash,
+		Offset: wire.MaxBlockHeaderPayload,
+		Len:    wire.MaxVarIntPayload,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return wire.ReadVarInt(bytes.NewReader(region), 0)
+}
+
+// DefaultChainTxStatsWindow returns the number of blocks the transaction
+// statistics are calculated over by default, which is the number of blocks
+// expected in a month.  It is also the largest window allowed.
+func (b *BlockChain) DefaultChainTxStatsWindow() int32 {
+	return int32(chainTxStatsWindowTime / b.chainParams.TargetTimePerBlock)
+}
+
+// ChainTxStats returns the total number of transactions in the main chain up to
+// the block with the passed hash, or the current best block when it is nil, and
+// the rate transactions were added at over the passed number of blocks ending
+// with that block.  A negative window selects the number of blocks expected in
+// a month, limited to the blocks available.  The window must be smaller than
+// the height of the final block and can't be larger than the default window.
+//
+// Calculating the statistics is fast for recent blocks.  The further the final
+// block is from the best block, the more blocks are loaded from the database.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ChainTxStats(window int32, hash *chainhash.Hash) (*ChainTxStats, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	tip := b.bestChain.Tip()
+	final := tip
+	if hash != nil {
+		final = b.index.LookupNode(hash)
+		if final == nil || !b.bestChain.Contains(final) {
+			str := fmt.Sprintf("block %s is not in the main chain",
+				hash)
+			return nil, errNotInMainChain(str)
+		}
+	}
+
+	maxWindow := b.DefaultChainTxStatsWindow()
+	if window < 0 {
+		window = maxWindow
+		if window > final.height-1 {
+			window = final.height - 1
+		}
+		if window < 0 {
+			window = 0
+		}
+	} else if window > 0 && window >= final.height {
+		return nil, fmt.Errorf("invalid window of %d blocks for a "+
+			"block at height %d", window, final.height)
+	} else if window > maxWindow {
+		return nil, fmt.Errorf("invalid window of %d blocks exceeds "+
+			"the maximum of %d blocks", window, maxWindow)
+	}
+
+	// Determine the total number of transactions up to the final block by
+	// removing the transactions of the blocks after it from the total of
+	// the best block.
+	txCount := b.stateSnapshot.TotalTxns
+	for n := tip; n != final; n = n.parent {
+		numTxns, err := b.txCounts.count(b.db, n, tip.height)
+		if err != nil {
+			return nil, err
+		}
+		txCount -= numTxns
+	}
+
+	var windowTxCount uint64
+	n := final
+	for i := int32(0); i < window; i++ {
+		numTxns, err := b.txCounts.count(b.db, n, tip.height)
+		if err != nil {
+			return nil, err
+		}
+		windowTxCount += numTxns
+		n = n.parent
+	}
+
+	return &ChainTxStats{
+		Time:             time.Unix(final.timestamp, 0),
+		TxCount:          txCount,
+		FinalHash:        final.hash,
+		FinalHeight:      final.height,
+		WindowBlockCount: window,
+		WindowTxCount:    windowTxCount,
+		WindowInterval:   final.CalcPastMedianTime().Sub(n.CalcPastMedianTime()),
+	}, nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestChainTxStats ensures the transaction statistics of the main chain are
+// calculated correctly from tracked and loaded transaction counts, including
+// after a reorganization.
+func TestChainTxStats(t *testing.T) {
+	// Load up blocks such that a side chain overtakes the main chain.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a -> 4a -> 5a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+		"blk_4A.dat.bz2",
+		"blk_5A.dat.bz2",
+	}
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	chain, teardownFunc, err := chainSetup("chaintxstats",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	// checkStats ensures the statistics over the passed window ending with
+	// the passed block match the passed main chain blocks, which start
+	// with the genesis block and end with the final block.
+	checkStats := func(window int32, hash *chainhash.Hash, mainChain []*btcutil.Block, wantWindow int32) {
+		stats, err := chain.ChainTxStats(window, hash)
+		if err != nil {
+			t.Fatalf("ChainTxStats(%d, %v): unexpected error: %v",
+				window, hash, err)
+		}
+
+		final := mainChain[len(mainChain)-1]
+		start := mainChain[len(mainChain)-1-int(wantWindow)]
+		var txCount, windowTxCount uint64
+		for i, block := range mainChain {
+			numTxns := uint64(len(block.Transactions()))
+			txCount += numTxns
+			if i >= len(mainChain)-int(wantWindow) {
+				windowTxCount += numTxns
+			}
+		}
+		finalTime := final.MsgBlock().Header.Timestamp
+		interval := chain.index.LookupNode(final.Hash()).CalcPastMedianTime().
+			Sub(chain.index.LookupNode(start.Hash()).CalcPastMedianTime())
+		want := ChainTxStats{
+			Time:             time.Unix(finalTime.Unix(), 0),
+			TxCount:          txCount,
+			FinalHash:        *final.Hash(),
+			FinalHeight:      int32(len(mainChain) - 1),
+			WindowBlockCount: wantWindow,
+			WindowTxCount:    windowTxCount,
+			WindowInterval:   interval,
+		}
+		if *stats != want {
+			t.Fatalf("ChainTxStats(%d, %v): got %+v, want %+v",
+				window, hash, *stats, want)
+		}
+	}
+
+	for i := 1; i <= 4; i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	// The default window is limited to the blocks available.
+	checkStats(-1, nil, blocks[:5], 3)
+	checkStats(0, nil, blocks[:5], 0)
+	checkStats(1, blocks[2].Hash(), blocks[:3], 1)
+
+	// The counts must be loaded from the database when they are not
+	// tracked.
+	chain.txCounts.counts = nil
+	checkStats(2, blocks[3].Hash(), blocks[:4], 2)
+
+	// The window must be smaller than the height of the final block.
+	if _, err := chain.ChainTxStats(2, blocks[2].Hash()); err == nil {
+		t.Fatal("ChainTxStats with a window reaching the genesis " +
+			"block unexpectedly succeeded")
+	}
+
+	// Reorganize to the side chain, which must replace the counts of the
+	// disconnected blocks.
+	for i := 5; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+	sideChain := append(append([]*btcutil.Block(nil), blocks[:3]...),
+		blocks[5:]...)
+	checkStats(-1, nil, sideChain, 4)
+
+	// The window can't exceed the default window, which is shortened to
+	// two blocks here.
+	chain.chainParams.TargetTimePerBlock = chainTxStatsWindowTime / 2
+	checkStats(-1, nil, sideChain, 2)
+	if _, err := chain.ChainTxStats(3, nil); err == nil {
+		t.Fatal("ChainTxStats with a window larger than the default " +
+			"window unexpectedly succeeded")
+	}
+}
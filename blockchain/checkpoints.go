@@ -11,6 +11,7 @@ import (
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 )
 
@@ -55,6 +56,64 @@ func (b *BlockChain) LatestCheckpoint() *chaincfg.Checkpoint {
 	return &b.checkpoints[len(b.checkpoints)-1]
 }
 
+// AddCheckpointHeaders marks the blocks with the passed headers which lead from
+// the current best block to the most recent checkpoint they reach as known
+// ancestors of that checkpoint.  Those blocks are connected as if BFFastAdd was
+// set when they are processed with BFCheckpointFastAdd.  The headers may be in
+// any order and may include blocks which do not lead to a checkpoint, which are
+// ignored.  It returns the number of blocks which were marked.
+//
+// Since the hash of every header commits to the one before it, an unbroken
+// chain of headers from the best block to the hash of a checkpoint is the chain
+// the checkpoint establishes, so no other checks are needed.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) AddCheckpointHeaders(headers []wire.BlockHeader) int {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	prevHashes := make(map[chainhash.Hash]chainhash.Hash, len(headers))
+	for i := range headers {
+		prevHashes[headers[i].BlockHash()] = headers[i].PrevBlock
+	}
+
+	tip := b.bestChain.Tip()
+	for i := len(b.checkpoints) - 1; i >= 0; i-- {
+		checkpoint := &b.checkpoints[i]
+		if checkpoint.Height <= tip.height {
+			break
+		}
+
+		// Walk back from the checkpoint until the height of the best
+		// block and ensure the chain ends at it.
+		ancestors := make([]chainhash.Hash, 0, checkpoint.Height-tip.height)
+		hash := *checkpoint.Hash
+		for height := checkpoint.Height; height > tip.height; height-- {
+			prevHash, ok := prevHashes[hash]
+			if !ok {
+				break
+			}
+			ancestors = append(ancestors, hash)
+			hash = prevHash
+		}
+		if len(ancestors) != int(checkpoint.Height-tip.height) ||
+			hash != tip.hash {
+
+			continue
+		}
+
+		if b.checkpointAncestors == nil {
+			b.checkpointAncestors = make(map[chainhash.Hash]struct{},
+				len(ancestors))
+		}
+		for _, hash := range ancestors {
+			b.checkpointAncestors[hash] = struct{}{}
+		}
+		return len(ancestors)
+	}
+	return 0
+}
+
 // verifyCheckpoint returns whether the passed block height and hash combination
 // match the checkpoint data.  It also returns true if there is no checkpoint
 // data for the passed block height.
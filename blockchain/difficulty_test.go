@@ -7,6 +7,10 @@ package blockchain
 import (
 	"math/big"
 	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
 )
 
 // TestBigToCompact ensures BigToCompact converts big integers to the expected
@@ -73,3 +77,104 @@ func TestCalcWork(t *testing.T) {
 		}
 	}
 }
+
+// TestBIP0094 ensures difficulty retargets on networks which enforce BIP0094
+// are based on the first block of the retarget period and that time warp
+// attacks are rejected.
+func TestBIP0094(t *testing.T) {
+	// Construct a synthetic block chain on the test network (version 4)
+	// whose second retarget period consists of blocks with a difficulty
+	// above the minimum except for the last block which applies the
+	// special minimum difficulty rule.
+	params := chaincfg.TestNet4Params
+	chain := newFakeChain(&params)
+	const periodBits = 0x1c0fffff
+	blockTime := params.GenesisBlock.Header.Timestamp
+	node := chain.bestChain.Genesis()
+	blocksPerRetarget := chain.blocksPerRetarget
+	for height := int32(1); height < blocksPerRetarget*2; height++ {
+		bits := params.PowLimitBits
+		blockTime = blockTime.Add(params.TargetTimePerBlock)
+		switch {
+		case height == blocksPerRetarget*2-1:
+			blockTime = blockTime.Add(params.MinDiffReductionTime)
+		case height >= blocksPerRetarget:
+			bits = periodBits
+		}
+		node = newFakeNode(node, 4, bits, blockTime)
+		chain.index.AddNode(node)
+	}
+	chain.bestChain.SetTip(node)
+	firstNode := node.RelativeAncestor(blocksPerRetarget - 1)
+	if firstNode.bits != periodBits {
+		t.Fatalf("unexpected bits of first block in period %08x",
+			firstNode.bits)
+	}
+
+	// The retarget is based on the difficulty of the first block of the
+	// period rather than the minimum difficulty of the last block.
+	actualTimespan := node.timestamp - firstNode.timestamp
+	targetTimespan := int64(params.TargetTimespan / time.Second)
+	wantTarget := new(big.Int).Mul(CompactToBig(periodBits),
+		big.NewInt(actualTimespan))
+	wantTarget.Div(wantTarget, big.NewInt(targetTimespan))
+	wantBits := BigToCompact(wantTarget)
+	newBlockTime := blockTime.Add(params.TargetTimePerBlock)
+	gotBits, err := chain.calcNextRequiredDifficulty(node, newBlockTime)
+	if err != nil {
+		t.Fatalf("calcNextRequiredDifficulty: unexpected error: %v", err)
+	}
+	if gotBits != wantBits {
+		t.Fatalf("calcNextRequiredDifficulty: got %08x, want %08x",
+			gotBits, wantBits)
+	}
+
+	// Without BIP0094 the minimum difficulty of the last block is the
+	// basis of the retarget.
+	params.EnforceBIP94 = false
+	gotBits, err = chain.calcNextRequiredDifficulty(node, newBlockTime)
+	if err != nil {
+		t.Fatalf("calcNextRequiredDifficulty: unexpected error: %v", err)
+	}
+	if gotBits != params.PowLimitBits {
+		t.Fatalf("calcNextRequiredDifficulty without BIP0094: got "+
+			"%08x, want %08x", gotBits, params.PowLimitBits)
+	}
+	params.EnforceBIP94 = true
+
+	// The first block of a retarget period may not be more than the
+	// allowed time warp before the previous block.
+	tests := []struct {
+		offset  int64
+		wantErr bool
+	}{
+		{-maxTimeWarpSeconds - 1, true},
+		{-maxTimeWarpSeconds, false},
+		{0, false},
+	}
+	for _, test := range tests {
+		header := wire.BlockHeader{
+			Version:   4,
+			PrevBlock: node.hash,
+			Timestamp: time.Unix(node.timestamp+test.offset, 0),
+		}
+		header.Bits, err = chain.calcNextRequiredDifficulty(node,
+			header.Timestamp)
+		if err != nil {
+			t.Fatalf("calcNextRequiredDifficulty: unexpected "+
+				"error: %v", err)
+		}
+		err = chain.checkBlockHeaderContext(&header, node, BFNone)
+		if test.wantErr {
+			if !isRuleErrorCode(err, ErrTimewarpAttack) {
+				t.Fatalf("offset %d: expected ErrTimewarpAttack, "+
+					"got %v", test.offset, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("offset %d: unexpected error: %v", test.offset,
+				err)
+		}
+	}
+}
@@ -212,6 +212,30 @@ const (
 	// included in the block's coinbase transaction doesn't match the
 	// manually computed witness commitment.
 	ErrWitnessCommitmentMismatch
+
+	// ErrBadSignetSolution indicates that a block on a signet does not
+	// commit to a solution which satisfies the block challenge of the
+	// network.
+	ErrBadSignetSolution
+
+	// ErrTimewarpAttack indicates that the timestamp of the first block of
+	// a difficulty retarget period is too far before the timestamp of the
+	// previous block on a network which prevents time warp attacks.
+	ErrTimewarpAttack
+
+	// ErrInvalidAncestorBlock indicates that a block builds on a block
+	// which was marked invalid or one of its descendants.
+	ErrInvalidAncestorBlock
+
+	// ErrInvalidatedBlock indicates that a block was marked invalid before
+	// it was received, such as a side chain block which was invalidated
+	// before a restart.
+	ErrInvalidatedBlock
+
+	// ErrUndoDataPruned indicates that a reorganization would disconnect a
+	// block whose spend journal entry, which is needed to disconnect it,
+	// was pruned.
+	ErrUndoDataPruned
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -257,6 +281,11 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrUnexpectedWitness:         "ErrUnexpectedWitness",
 	ErrInvalidWitnessCommitment:  "ErrInvalidWitnessCommitment",
 	ErrWitnessCommitmentMismatch: "ErrWitnessCommitmentMismatch",
+	ErrBadSignetSolution:         "ErrBadSignetSolution",
+	ErrTimewarpAttack:            "ErrTimewarpAttack",
+	ErrInvalidAncestorBlock:      "ErrInvalidAncestorBlock",
+	ErrInvalidatedBlock:          "ErrInvalidatedBlock",
+	ErrUndoDataPruned:            "ErrUndoDataPruned",
 }
 
 // String returns the ErrorCode as a human-readable name.
@@ -52,6 +52,11 @@ func TestErrorCodeStringer(t *testing.T) {
 		{ErrBadCoinbaseHeight, "ErrBadCoinbaseHeight"},
 		{ErrScriptMalformed, "ErrScriptMalformed"},
 		{ErrScriptValidation, "ErrScriptValidation"},
+		{ErrBadSignetSolution, "ErrBadSignetSolution"},
+		{ErrTimewarpAttack, "ErrTimewarpAttack"},
+		{ErrInvalidAncestorBlock, "ErrInvalidAncestorBlock"},
+		{ErrInvalidatedBlock, "ErrInvalidatedBlock"},
+		{ErrUndoDataPruned, "ErrUndoDataPruned"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
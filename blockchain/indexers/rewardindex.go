@@ -0,0 +1,232 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// rewardIndexName is the human-readable name for the index.
+	rewardIndexName = "block reward index"
+
+	// rewardEntrySize is the size of a serialized block reward entry.
+	rewardEntrySize = 16
+)
+
+var (
+	// rewardIndexKey is the key of the block reward index and the db
+	// bucket used to house it.
+	rewardIndexKey = []byte("blockrewardidx")
+)
+
+// -----------------------------------------------------------------------------
+// The block reward index consists of an entry for every block in the main
+// chain which holds the total fees paid by the transactions of the block and
+// the total value of the outputs of its coinbase.  The fees can only be
+// calculated with the outputs the transactions spend, so keeping them in an
+// index saves looking up every input of a block again when it is queried.
+//
+// The keys in the block reward bucket are the 32-byte hashes of the blocks and
+// the serialized format for the values is:
+//
+//   <fees><coinbase value>
+//
+//   Field           Type              Size
+//   fees            uint64            8 bytes
+//   coinbase value  uint64            8 bytes
+//   -----
+//   Total: 16 bytes
+// -----------------------------------------------------------------------------
+
+// BlockReward describes the amounts in satoshi a block pays its miner.
+type BlockReward struct {
+	// Fees is the total of the fees paid by the transactions of the block.
+	Fees int64
+
+	// Coinbase is the total value of the outputs of the coinbase.  It is
+	// less than the subsidy plus the fees when the miner did not claim the
+	// full reward.
+	Coinbase int64
+}
+
+// serializeBlockReward returns the passed block reward serialized according to
+// the format described above.
+func serializeBlockReward(reward *BlockReward) []byte {
+	serialized := make([]byte, rewardEntrySize)
+	byteOrder.PutUint64(serialized[0:8], uint64(reward.Fees))
+	byteOrder.PutUint64(serialized[8:16], uint64(reward.Coinbase))
+	return serialized
+}
+
+// deserializeBlockReward decodes the passed serialized block reward according
+// to the format described above.
+func deserializeBlockReward(serialized []byte) (*BlockReward, error) {
+	if len(serialized) != rewardEntrySize {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt block reward entry "+
+				"of %d bytes", len(serialized)),
+		}
+	}
+	return &BlockReward{
+		Fees:     int64(byteOrder.Uint64(serialized[0:8])),
+		Coinbase: int64(byteOrder.Uint64(serialized[8:16])),
+	}, nil
+}
+
+// calcBlockReward calculates the reward of the passed block using the passed
+// view to look up the outputs its transactions spend.
+func calcBlockReward(block *btcutil.Block, view *blockchain.UtxoViewpoint) (*BlockReward, error) {
+	var reward BlockReward
+	for txIdx, tx := range block.Transactions() {
+		var outputs int64
+		for _, txOut := range tx.MsgTx().TxOut {
+			outputs += txOut.Value
+		}
+
+		// Coinbases do not reference any inputs.  Since the block is
+		// required to have already gone through full validation, it has
+		// already been proven on the first transaction in the block is
+		// a coinbase.
+		if txIdx == 0 {
+			reward.Coinbase = outputs
+			continue
+		}
+
+		var inputs int64
+		for _, txIn := range tx.MsgTx().TxIn {
+			// Unlike the address index, a missing input can not be
+			// ignored since it would result in a wrong fee.
+			origin := &txIn.PreviousOutPoint
+			entry := view.LookupEntry(&origin.Hash)
+			if entry == nil {
+				return nil, fmt.Errorf("missing input %v for "+
+					"transaction %v", origin, tx.Hash())
+			}
+			inputs += entry.AmountByIndex(origin.Index)
+		}
+		reward.Fees += inputs - outputs
+	}
+	return &reward, nil
+}
+
+// RewardIndex implements an index of the fees and coinbase values of the blocks
+// in the main chain by their hash.
+type RewardIndex struct {
+	db database.DB
+}
+
+// Ensure the RewardIndex type implements the Indexer interface.
+var _ Indexer = (*RewardIndex)(nil)
+
+// Ensure the RewardIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*RewardIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *RewardIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *RewardIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *RewardIndex) Key() []byte {
+	return rewardIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *RewardIndex) Name() string {
+	return rewardIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the block reward
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *RewardIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(rewardIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the reward of the block.
+//
+// This is part of the Indexer interface.
+func (idx *RewardIndex) ConnectBlock(dbTx database.Tx, block *btcutil.Block, view *blockchain.UtxoViewpoint) error {
+	reward, err := calcBlockReward(block, view)
+	if err != nil {
+		return err
+	}
+	bucket := dbTx.Metadata().Bucket(rewardIndexKey)
+	return bucket.Put(block.Hash()[:], serializeBlockReward(reward))
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the reward of the
+// block.
+//
+// This is part of the Indexer interface.
+func (idx *RewardIndex) DisconnectBlock(dbTx database.Tx, block *btcutil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(rewardIndexKey)
+	return bucket.Delete(block.Hash()[:])
+}
+
+// BlockReward returns the reward of the block with the provided hash from the
+// block reward index.  When there is no entry for the provided hash, nil will be
+// returned for both the reward and the error.
+//
+// This function is safe for concurrent access.
+func (idx *RewardIndex) BlockReward(hash *chainhash.Hash) (*BlockReward, error) {
+	var reward *BlockReward
+	err := idx.db.View(func(dbTx database.Tx) error {
+		serialized := dbTx.Metadata().Bucket(rewardIndexKey).Get(hash[:])
+		if serialized == nil {
+			return nil
+		}
+
+		var err error
+		reward, err = deserializeBlockReward(serialized)
+		return err
+	})
+	return reward, err
+}
+
+// NewRewardIndex returns a new instance of an indexer that is used to create a
+// mapping of the hashes of all blocks in the main chain to the fees they
+// collect and the value of their coinbase.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewRewardIndex(db database.DB) *RewardIndex {
+	return &RewardIndex{db: db}
+}
+
+// DropRewardIndex drops the block reward index from the provided database if it
+// exists.
+func DropRewardIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, rewardIndexKey, rewardIndexName, interrupt)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestBlockReward ensures the rewards of blocks are calculated from the outputs
+// their transactions spend and survive the round trip through serialization.
+func TestBlockReward(t *testing.T) {
+	t.Parallel()
+
+	// Create a view with a previous transaction paying 3000 and 2000.
+	prevTx := btcutil.NewTx(&wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{{Value: 3000}, {Value: 2000}},
+	})
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(prevTx, 1)
+
+	// Spend both outputs with fees of 100 and 400.
+	coinbase := &wire.MsgTx{
+		TxIn:  []*wire.TxIn{{}},
+		TxOut: []*wire.TxOut{{Value: 5000}, {Value: 250}},
+	}
+	spend1 := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Hash: *prevTx.Hash()},
+		}},
+		TxOut: []*wire.TxOut{{Value: 2900}},
+	}
+	spend2 := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Hash: *prevTx.Hash(),
+				Index: 1},
+		}},
+		TxOut: []*wire.TxOut{{Value: 1000}, {Value: 600}},
+	}
+	block := btcutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, spend1, spend2},
+	})
+
+	reward, err := calcBlockReward(block, view)
+	if err != nil {
+		t.Fatalf("calcBlockReward: %v", err)
+	}
+	want := &BlockReward{Fees: 500, Coinbase: 5250}
+	if !reflect.DeepEqual(reward, want) {
+		t.Fatalf("calcBlockReward: got %+v, want %+v", reward, want)
+	}
+
+	got, err := deserializeBlockReward(serializeBlockReward(reward))
+	if err != nil {
+		t.Fatalf("deserializeBlockReward: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("deserializeBlockReward: got %+v, want %+v", got, want)
+	}
+	if _, err := deserializeBlockReward(make([]byte, 8)); err == nil {
+		t.Fatal("deserializeBlockReward: expected error for short entry")
+	}
+
+	// Blocks spending outputs missing from the view are rejected.
+	_, err = calcBlockReward(block, blockchain.NewUtxoViewpoint())
+	if err == nil {
+		t.Fatal("calcBlockReward: expected error for missing input")
+	}
+}
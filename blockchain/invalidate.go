@@ -0,0 +1,173 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// setDescendantsStatus sets or clears the passed status flags of all of the
+// known descendants of the passed node.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) setDescendantsStatus(node *blockNode, flags blockStatus, set bool) {
+	for _, n := range b.index.Descendants(node) {
+		if set {
+			b.index.SetStatusFlags(n, flags)
+		} else {
+			b.index.UnsetStatusFlags(n, flags)
+		}
+	}
+}
+
+// bestValidNode returns the node with the most cumulative work among the known
+// blocks which are not known to be invalid.  The current tip of the main chain
+// is preferred over other nodes with the same work.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) bestValidNode() *blockNode {
+	b.index.RLock()
+	defer b.index.RUnlock()
+
+	best := b.bestChain.Tip()
+	for _, n := range b.index.index {
+		if n.status.KnownInvalid() || n.workSum.Cmp(best.workSum) <= 0 {
+			continue
+		}
+		best = n
+	}
+	return best
+}
+
+// activateBestValidChain reorganizes the chain to the known chain with the most
+// cumulative work which does not contain blocks known to be invalid.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) activateBestValidChain() error {
+	best := b.bestValidNode()
+	if best == b.bestChain.Tip() {
+		return nil
+	}
+	if !b.reorgAllowed(best) {
+		return nil
+	}
+
+	detachNodes, attachNodes := b.getReorganizeNodes(best)
+	log.Infof("REORGANIZE: Block %v is the best valid chain head", best.hash)
+	return b.reorganizeChain(detachNodes, attachNodes, BFNone)
+}
+
+// InvalidateBlock marks the block with the passed hash, and thereby all of its
+// descendants, as invalid.  When the block is in the main chain, it is
+// disconnected along with all of the blocks after it, and the chain is then
+// reorganized to the remaining chain with the most cumulative work.  Blocks
+// which build on an invalidated block are rejected.
+//
+// The invalid mark of the block is stored in the database, so the block stays
+// invalid across restarts.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) InvalidateBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node, err := b.lookupKnownNode(hash)
+	if err != nil {
+		return err
+	}
+	if node.parent == nil {
+		return fmt.Errorf("the genesis block can't be invalidated")
+	}
+
+	// Mark the block and its descendants before rolling the main chain
+	// back.  The chain state lock is released while the disconnected
+	// blocks are notified, so this keeps them from being connected again
+	// in the meantime.  The marks which were not already set are kept so
+	// they can be removed when the chain can't be rolled back.
+	wasInvalid := node.status&statusValidateFailed != 0
+	b.index.SetStatusFlags(node, statusValidateFailed)
+	var marked []*blockNode
+	for _, n := range b.index.Descendants(node) {
+		if n.status&statusInvalidAncestor == 0 {
+			b.index.SetStatusFlags(n, statusInvalidAncestor)
+			marked = append(marked, n)
+		}
+	}
+
+	// Roll the main chain back to the parent of the block when it is in the
+	// main chain.
+	if b.bestChain.Contains(node) {
+		detachNodes, attachNodes := b.getReorganizeNodes(node.parent)
+		log.Infof("REORGANIZE: Block %v was invalidated", node.hash)
+		err := b.reorganizeChain(detachNodes, attachNodes, BFNone)
+		if err != nil {
+			if !wasInvalid {
+				b.index.UnsetStatusFlags(node, statusValidateFailed)
+			}
+			for _, n := range marked {
+				b.index.UnsetStatusFlags(n, statusInvalidAncestor)
+			}
+			return err
+		}
+	}
+
+	// The mark is stored once the block is no longer in the main chain so
+	// the stored chain never contains a block marked invalid.
+	if err := b.index.flushToDB(); err != nil {
+		return err
+	}
+
+	// Switch to a side chain with more work than the remaining main chain
+	// when there is one.  Failing to do so leaves the chain at the parent
+	// of the invalidated block, which is still a valid chain.
+	if err := b.activateBestValidChain(); err != nil {
+		log.Warnf("Unable to activate the best valid chain after "+
+			"invalidating block %v: %v", node.hash, err)
+	}
+
+	return nil
+}
+
+// ReconsiderBlock removes the invalid marks set by InvalidateBlock from the
+// block with the passed hash as well as from its ancestors and descendants.
+// The chain is then reorganized to the known chain with the most cumulative
+// work, which validates the blocks again when they are connected.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ReconsiderBlock(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	// Blocks which were marked invalid before a restart are not loaded
+	// into the block index when they are not in the main chain, so only
+	// their stored mark is removed for them to be accepted again.
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		if !b.index.ClearUnloadedStatus(hash) {
+			return UnknownBlockError(*hash)
+		}
+		return b.index.flushToDB()
+	}
+
+	// Clear the marks of the block and its descendants.  The ancestors of
+	// the block must be cleared as well for it to become valid again,
+	// along with the descendants of those which were invalidated.
+	flags := statusValidateFailed | statusInvalidAncestor
+	b.index.UnsetStatusFlags(node, flags)
+	b.setDescendantsStatus(node, flags, false)
+	for n := node.parent; n != nil; n = n.parent {
+		if n.status&statusValidateFailed != 0 {
+			b.index.UnsetStatusFlags(n, flags)
+			b.setDescendantsStatus(n, statusInvalidAncestor, false)
+		}
+	}
+	if err := b.index.flushToDB(); err != nil {
+		return err
+	}
+
+	return b.activateBestValidChain()
+}
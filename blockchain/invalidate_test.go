@@ -0,0 +1,208 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestInvalidateBlock ensures invalidating and reconsidering blocks moves the
+// main chain as expected and that blocks building on invalidated blocks are
+// rejected.
+func TestInvalidateBlock(t *testing.T) {
+	// Load up blocks such that there is a side chain which has more work
+	// than the main chain.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a -> 4a -> 5a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+		"blk_4A.dat.bz2",
+		"blk_5A.dat.bz2",
+	}
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	chain, teardownFunc, err := chainSetup("invalidateblock",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	processBlock := func(i int) {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+	checkTip := func(what string, i int) {
+		tip := chain.BestSnapshot().Hash
+		if tip != *blocks[i].Hash() {
+			t.Fatalf("%s: got best block %v, want %v", what, tip,
+				blocks[i].Hash())
+		}
+	}
+
+	// Invalidating a block in the main chain must roll the chain back to
+	// its parent and reject blocks which build on it.
+	for i := 1; i <= 3; i++ {
+		processBlock(i)
+	}
+	if err := chain.InvalidateBlock(blocks[3].Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: %v", err)
+	}
+	checkTip("invalidate block 3", 2)
+	_, _, err = chain.ProcessBlock(blocks[4], BFNone)
+	if rerr, ok := err.(RuleError); !ok ||
+		rerr.ErrorCode != ErrInvalidAncestorBlock {
+
+		t.Fatalf("ProcessBlock on invalid ancestor: got error %v, "+
+			"want %v", err, ErrInvalidAncestorBlock)
+	}
+
+	// Reconsidering the block must connect it again so the blocks which
+	// build on it are accepted.
+	if err := chain.ReconsiderBlock(blocks[3].Hash()); err != nil {
+		t.Fatalf("ReconsiderBlock: %v", err)
+	}
+	checkTip("reconsider block 3", 3)
+	processBlock(4)
+	checkTip("process block 4", 4)
+
+	// Connect the side chain which overtakes the main chain, then
+	// invalidate its first block so the chain moves back to the original
+	// main chain.
+	for i := 5; i < len(blocks); i++ {
+		processBlock(i)
+	}
+	checkTip("process side chain", 7)
+	if err := chain.InvalidateBlock(blocks[5].Hash()); err != nil {
+		t.Fatalf("InvalidateBlock: %v", err)
+	}
+	checkTip("invalidate block 3a", 4)
+
+	// Reconsidering a descendant must also clear its invalid ancestors.
+	if err := chain.ReconsiderBlock(blocks[6].Hash()); err != nil {
+		t.Fatalf("ReconsiderBlock: %v", err)
+	}
+	checkTip("reconsider block 4a", 7)
+
+	// The genesis block can't be invalidated.
+	if err := chain.InvalidateBlock(blocks[0].Hash()); err == nil {
+		t.Fatal("InvalidateBlock on the genesis block unexpectedly " +
+			"succeeded")
+	}
+}
+
+// TestInvalidateBlockRestart ensures the invalid marks are kept when the chain
+// is loaded again from the database, including for side chain blocks which are
+// not loaded until they are received again.
+func TestInvalidateBlockRestart(t *testing.T) {
+	// Load up blocks such that there is a side chain which has more work
+	// than the main chain.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a -> 4a -> 5a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+		"blk_4A.dat.bz2",
+		"blk_5A.dat.bz2",
+	}
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	chain, teardownFunc, err := chainSetup("invalidateblockrestart",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	// Invalidate the first block of the side chain and a block of the main
+	// chain so the chain is at block 2 before the restart.
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+	for _, i := range []int{5, 3} {
+		if err := chain.InvalidateBlock(blocks[i].Hash()); err != nil {
+			t.Fatalf("InvalidateBlock on block %v: %v", i, err)
+		}
+	}
+
+	// Load the chain again from the same database.
+	chain, err = New(&Config{
+		DB:          chain.db,
+		ChainParams: chain.chainParams,
+		TimeSource:  NewMedianTime(),
+		SigCache:    txscript.NewSigCache(1000),
+	})
+	if err != nil {
+		t.Fatalf("Failed to load chain instance: %v", err)
+	}
+	chain.TstSetCoinbaseMaturity(1)
+	if tip := chain.BestSnapshot().Hash; tip != *blocks[2].Hash() {
+		t.Fatalf("got best block %v after restart, want %v", tip,
+			blocks[2].Hash())
+	}
+
+	// The invalidated blocks must be rejected when they are received
+	// again.
+	for _, i := range []int{3, 5} {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if rerr, ok := err.(RuleError); !ok ||
+			rerr.ErrorCode != ErrInvalidatedBlock {
+
+			t.Fatalf("ProcessBlock on invalidated block %v: got "+
+				"error %v, want %v", i, err, ErrInvalidatedBlock)
+		}
+	}
+
+	// Reconsidering a block which was never received is an error, while
+	// reconsidering the invalidated blocks allows them to be connected.
+	unknown := *blocks[2].Hash()
+	unknown[0] ^= 0xff
+	err = chain.ReconsiderBlock(&unknown)
+	if _, ok := err.(UnknownBlockError); !ok {
+		t.Fatalf("ReconsiderBlock on unknown block: got error %v, want "+
+			"UnknownBlockError", err)
+	}
+	for _, i := range []int{3, 5} {
+		if err := chain.ReconsiderBlock(blocks[i].Hash()); err != nil {
+			t.Fatalf("ReconsiderBlock on block %v: %v", i, err)
+		}
+	}
+	for i := 3; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+	if tip := chain.BestSnapshot().Hash; tip != *blocks[7].Hash() {
+		t.Fatalf("got best block %v after reconsidering, want %v", tip,
+			blocks[7].Hash())
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 )
 
@@ -154,6 +155,70 @@ func BuildMerkleTreeStore(transactions []*btcutil.Tx, witness bool) []*chainhash
 	return merkles
 }
 
+// CalcMerkleRoot computes the merkle root over the passed transactions.  When
+// witness is true the root is computed over the witness transaction ids with
+// the coinbase wtxid treated as all zeroes, as required for the witness
+// commitment.  The zero hash is returned for an empty transaction list.
+func CalcMerkleRoot(transactions []*btcutil.Tx, witness bool) chainhash.Hash {
+	if len(transactions) == 0 {
+		return chainhash.Hash{}
+	}
+
+	merkles := BuildMerkleTreeStore(transactions, witness)
+	return *merkles[len(merkles)-1]
+}
+
+// CalcWitnessCommitment returns the witness commitment for the passed
+// transactions and witness nonce.  The commitment is of the form:
+// SHA256(SHA256(witness root || witness nonce)), where the witness root is the
+// merkle root of the wtxids of the transactions with the first transaction
+// assumed to be the coinbase.
+func CalcWitnessCommitment(transactions []*btcutil.Tx, witnessNonce []byte) []byte {
+	witnessMerkleRoot := CalcMerkleRoot(transactions, true)
+
+	var witnessPreimage [chainhash.HashSize * 2]byte
+	copy(witnessPreimage[:], witnessMerkleRoot[:])
+	copy(witnessPreimage[chainhash.HashSize:], witnessNonce)
+
+	return chainhash.DoubleHashB(witnessPreimage[:])
+}
+
+// WitnessCommitmentScript returns the public key script of a coinbase output
+// carrying the passed witness commitment.  The script is of the form:
+// OP_RETURN OP_DATA_36 {0xaa21a9ed || witnessCommitment}.
+func WitnessCommitmentScript(witnessCommitment []byte) []byte {
+	script := make([]byte, 0, len(WitnessMagicBytes)+len(witnessCommitment))
+	script = append(script, WitnessMagicBytes...)
+	return append(script, witnessCommitment...)
+}
+
+// AddWitnessCommitment adds a witness commitment for the passed transactions
+// to the coinbase transaction, which must be the first transaction in the
+// list.  The coinbase witness is set to a nonce of all zeroes and an
+// OP_RETURN output carrying the commitment is appended to its outputs.  The
+// computed commitment is returned.
+//
+// Since the coinbase transaction is modified, any cached hashes of the passed
+// coinbase will no longer be valid for the regular merkle root.  Callers are
+// expected to compute the merkle root of the block after adding the
+// commitment.
+func AddWitnessCommitment(coinbaseTx *btcutil.Tx, transactions []*btcutil.Tx) []byte {
+	// The witness of the coinbase transaction MUST be exactly 32-bytes
+	// of all zeroes.
+	var witnessNonce [CoinbaseWitnessDataLen]byte
+	coinbaseTx.MsgTx().TxIn[0].Witness = wire.TxWitness{witnessNonce[:]}
+
+	// Create the OP_RETURN carrying the witness commitment as an
+	// additional output within the coinbase.
+	witnessCommitment := CalcWitnessCommitment(transactions, witnessNonce[:])
+	coinbaseTx.MsgTx().AddTxOut(&wire.TxOut{
+		Value:    0,
+		PkScript: WitnessCommitmentScript(witnessCommitment),
+	})
+
+	return witnessCommitment
+}
+
 // ExtractWitnessCommitment attempts to locate, and return the witness
 // commitment for a block. The witness commitment is of the form:
 // SHA256(witness root || witness nonce). The function additionally returns a
@@ -246,14 +311,8 @@ func ValidateWitnessCommitment(blk *btcutil.Block) error {
 	// the extracted witnessCommitment is equal to:
 	// SHA256(witnessMerkleRoot || witnessNonce). Where witnessNonce is the
 	// coinbase transaction's only witness item.
-	witnessMerkleTree := BuildMerkleTreeStore(blk.Transactions(), true)
-	witnessMerkleRoot := witnessMerkleTree[len(witnessMerkleTree)-1]
-
-	var witnessPreimage [chainhash.HashSize * 2]byte
-	copy(witnessPreimage[:], witnessMerkleRoot[:])
-	copy(witnessPreimage[chainhash.HashSize:], witnessNonce)
-
-	computedCommitment := chainhash.DoubleHashB(witnessPreimage[:])
+	computedCommitment := CalcWitnessCommitment(blk.Transactions(),
+		witnessNonce)
 	if !bytes.Equal(computedCommitment, witnessCommitment) {
 		str := fmt.Sprintf("witness commitment does not match: "+
 			"computed %v, coinbase includes %v", computedCommitment,
@@ -5,8 +5,11 @@
 package blockchain
 
 import (
+	"bytes"
 	"testing"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 )
 
@@ -21,3 +24,57 @@ func TestMerkle(t *testing.T) {
 			"got %v, want %v", calculatedMerkleRoot, wantMerkle)
 	}
 }
+
+// TestCalcMerkleRoot tests the CalcMerkleRoot API.
+func TestCalcMerkleRoot(t *testing.T) {
+	block := btcutil.NewBlock(&Block100000)
+	merkleRoot := CalcMerkleRoot(block.Transactions(), false)
+	if !merkleRoot.IsEqual(&Block100000.Header.MerkleRoot) {
+		t.Errorf("CalcMerkleRoot: merkle root mismatch - got %v, "+
+			"want %v", merkleRoot, Block100000.Header.MerkleRoot)
+	}
+
+	var zeroHash chainhash.Hash
+	if root := CalcMerkleRoot(nil, false); root != zeroHash {
+		t.Errorf("CalcMerkleRoot: unexpected root for empty list - "+
+			"got %v, want %v", root, zeroHash)
+	}
+}
+
+// TestAddWitnessCommitment ensures a witness commitment added to a coinbase
+// by AddWitnessCommitment can be located and passes validation.
+func TestAddWitnessCommitment(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Block100000.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	var msgBlock wire.MsgBlock
+	if err := msgBlock.Deserialize(&buf); err != nil {
+		t.Fatalf("Deserialize: unexpected error: %v", err)
+	}
+	msgBlock.Transactions[1].TxIn[0].Witness = wire.TxWitness{{0x01}}
+	block := btcutil.NewBlock(&msgBlock)
+	txns := block.Transactions()
+
+	commitment := AddWitnessCommitment(txns[0], txns)
+	extracted, found := ExtractWitnessCommitment(txns[0])
+	if !found {
+		t.Fatalf("ExtractWitnessCommitment: commitment not found")
+	}
+	if !bytes.Equal(extracted, commitment) {
+		t.Fatalf("ExtractWitnessCommitment: commitment mismatch - "+
+			"got %x, want %x", extracted, commitment)
+	}
+	if err := ValidateWitnessCommitment(btcutil.NewBlock(&msgBlock)); err != nil {
+		t.Fatalf("ValidateWitnessCommitment: unexpected error: %v", err)
+	}
+
+	// Altering the witness of a non-coinbase transaction must invalidate
+	// the commitment.
+	msgBlock.Transactions[1].TxIn[0].Witness = wire.TxWitness{{0x02}}
+	err := ValidateWitnessCommitment(btcutil.NewBlock(&msgBlock))
+	if !isRuleErrorCode(err, ErrWitnessCommitmentMismatch) {
+		t.Fatalf("ValidateWitnessCommitment: unexpected error - got "+
+			"%v, want %v", err, ErrWitnessCommitmentMismatch)
+	}
+}
@@ -6,6 +6,9 @@ package blockchain
 
 import (
 	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
 )
 
 // NotificationType represents the type of a notification message.
@@ -29,6 +32,11 @@ const (
 	// NTBlockDisconnected indicates the associated block was disconnected
 	// from the main chain.
 	NTBlockDisconnected
+
+	// NTReorganization indicates the main chain was reorganized.  It is
+	// sent once the reorganization is complete, after the NTBlockDisconnected
+	// and NTBlockConnected notifications for the individual blocks.
+	NTReorganization
 )
 
 // notificationTypeStrings is a map of notification types back to their constant
@@ -37,6 +45,7 @@ var notificationTypeStrings = map[NotificationType]string{
 	NTBlockAccepted:     "NTBlockAccepted",
 	NTBlockConnected:    "NTBlockConnected",
 	NTBlockDisconnected: "NTBlockDisconnected",
+	NTReorganization:    "NTReorganization",
 }
 
 // String returns the NotificationType in human-readable form.
@@ -53,11 +62,31 @@ func (n NotificationType) String() string {
 // 	- NTBlockAccepted:     *btcutil.Block
 // 	- NTBlockConnected:    *btcutil.Block
 // 	- NTBlockDisconnected: *btcutil.Block
+// 	- NTReorganization:    *Reorganization
 type Notification struct {
 	Type NotificationType
 	Data interface{}
 }
 
+// Reorganization describes a reorganization of the main chain.  It is the data
+// of an NTReorganization notification.
+type Reorganization struct {
+	// ForkHash and ForkHeight identify the last block the old and new main
+	// chains have in common.
+	ForkHash   chainhash.Hash
+	ForkHeight int32
+
+	// DisconnectedBlocks are the blocks removed from the main chain in the
+	// order they were disconnected, so starting with the old best block.
+	DisconnectedBlocks []*btcutil.Block
+
+	// ConnectedBlocks are the blocks added to the main chain in the order
+	// they were connected, so ending with the new best block.  It is empty
+	// when the main chain was rolled back to the fork point because a
+	// block was invalidated.
+	ConnectedBlocks []*btcutil.Block
+}
+
 // Subscribe to block chain notifications. Registers a callback to be executed
 // when various events take place. See the documentation on Notification and
 // NotificationType for details on the types and contents of notifications.
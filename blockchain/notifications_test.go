@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
 )
 
 // TestNotifications ensures that notification callbacks are fired on events.
@@ -49,3 +50,82 @@ func TestNotifications(t *testing.T) {
 			"times, found %d", numSubscribers, notificationCount)
 	}
 }
+
+// TestReorganizationNotification ensures a reorganization of the main chain is
+// notified along with the fork point and the disconnected and connected
+// blocks.
+func TestReorganizationNotification(t *testing.T) {
+	// Load up blocks such that a side chain overtakes the main chain.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a -> 4a -> 5a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+		"blk_4A.dat.bz2",
+		"blk_5A.dat.bz2",
+	}
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	chain, teardownFunc, err := chainSetup("reorgnotification",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	var reorgs []*Reorganization
+	var disconnected int
+	chain.Subscribe(func(notification *Notification) {
+		switch notification.Type {
+		case NTBlockDisconnected:
+			disconnected++
+		case NTReorganization:
+			// The individual blocks must have been notified first.
+			if disconnected != 2 {
+				t.Errorf("reorganization notified after %d "+
+					"disconnected blocks, want 2", disconnected)
+			}
+			reorgs = append(reorgs, notification.Data.(*Reorganization))
+		}
+	})
+
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	if len(reorgs) != 1 {
+		t.Fatalf("got %d reorganization notifications, want 1",
+			len(reorgs))
+	}
+	reorg := reorgs[0]
+	if reorg.ForkHash != *blocks[2].Hash() || reorg.ForkHeight != 2 {
+		t.Fatalf("got fork point %v (height %d), want %v (height 2)",
+			reorg.ForkHash, reorg.ForkHeight, blocks[2].Hash())
+	}
+	checkBlocks := func(what string, got, want []*btcutil.Block) {
+		if len(got) != len(want) {
+			t.Fatalf("got %d %s blocks, want %d", len(got), what,
+				len(want))
+		}
+		for i := range want {
+			if *got[i].Hash() != *want[i].Hash() {
+				t.Fatalf("%s block %d: got %v, want %v", what, i,
+					got[i].Hash(), want[i].Hash())
+			}
+		}
+	}
+	checkBlocks("disconnected", reorg.DisconnectedBlocks,
+		[]*btcutil.Block{blocks[4], blocks[3]})
+	checkBlocks("connected", reorg.ConnectedBlocks, blocks[5:])
+}
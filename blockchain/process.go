@@ -34,6 +34,15 @@ const (
 	// without modifying the current state.
 	BFDryRun
 
+	// BFCheckpointFastAdd may be set to indicate that blocks which extend
+	// the main chain and were found to lead to a checkpoint by
+	// AddCheckpointHeaders are to be treated as if BFFastAdd was set.
+	// This is intended for rebuilding the chain from a local copy of the
+	// blocks, where the validity of the blocks up to the checkpoint is
+	// established by the checkpoint itself once their headers are known
+	// to link to it.  All other blocks are fully validated.
+	BFCheckpointFastAdd
+
 	// BFNone is a convenience value to specifically indicate no flags.
 	BFNone BehaviorFlags = 0
 )
@@ -171,11 +180,25 @@ func (b *BlockChain) ProcessBlock(block *btcutil.Block, flags BehaviorFlags) (bo
 	}
 
 	// Perform preliminary sanity checks on the block and its transactions.
+	headerStart := time.Now()
 	err = checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource, flags)
 	if err != nil {
 		return false, false, err
 	}
 
+	// Blocks on a signet must additionally be signed by a solution to the
+	// challenge of the network.  Like the proof of work, the solution is
+	// not checked when the caller has requested to skip the proof of work
+	// checks.
+	if len(b.chainParams.SignetChallenge) > 0 &&
+		flags&BFNoPoWCheck != BFNoPoWCheck {
+
+		err := checkSignetBlockSolution(block, b.chainParams)
+		if err != nil {
+			return false, false, err
+		}
+	}
+
 	// Find the previous checkpoint and perform some additional checks based
 	// on the checkpoint.  This provides a few nice properties such as
 	// preventing old side chain blocks before the last checkpoint,
@@ -215,6 +238,7 @@ func (b *BlockChain) ProcessBlock(block *btcutil.Block, flags BehaviorFlags) (bo
 			}
 		}
 	}
+	b.timings.since(PhaseHeaderChecks, headerStart)
 
 	// Handle orphan blocks.
 	prevHash := &blockHeader.PrevBlock
@@ -0,0 +1,170 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// pendingReorg tracks a side chain with more work than the main chain which was
+// not switched to because the reorganization would disconnect more blocks than
+// allowed.
+type pendingReorg struct {
+	tip       *blockNode
+	firstSeen time.Time
+}
+
+// PendingReorg describes a reorganization which is waiting to be approved with
+// ApproveReorg because it would disconnect more main chain blocks than the
+// configured maximum reorganization depth.
+type PendingReorg struct {
+	// ForkHash and ForkHeight identify the last block the competing chain
+	// has in common with the main chain.
+	ForkHash   chainhash.Hash
+	ForkHeight int32
+
+	// TipHash and TipHeight identify the block the competing chain with the
+	// most work ends at.
+	TipHash   chainhash.Hash
+	TipHeight int32
+
+	// Detach and Attach are the number of blocks the reorganization would
+	// disconnect from and connect to the main chain.
+	Detach int32
+	Attach int32
+
+	// TipWork and MainWork are the total amounts of work in the competing
+	// chain and the main chain.
+	TipWork  *big.Int
+	MainWork *big.Int
+
+	// FirstSeen is the time the reorganization was first held back.
+	FirstSeen time.Time
+}
+
+// reorgAllowed returns whether the main chain may be reorganized to the passed
+// node without the approval of the operator.  When it may not, the competing
+// chain is recorded so it can be queried with PendingReorgs and approved with
+// ApproveReorg.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) reorgAllowed(node *blockNode) bool {
+	if b.maxReorgDepth <= 0 {
+		return true
+	}
+	tip := b.bestChain.Tip()
+	fork := b.bestChain.FindFork(node)
+	detach := tip.height - fork.height
+	if detach <= b.maxReorgDepth {
+		return true
+	}
+
+	pending, ok := b.pendingReorgs[fork.hash]
+	if !ok {
+		pending = &pendingReorg{tip: node, firstSeen: time.Now()}
+		b.pendingReorgs[fork.hash] = pending
+	} else if node.workSum.Cmp(pending.tip.workSum) > 0 {
+		pending.tip = node
+	}
+
+	log.Warnf("REORGANIZE HELD: Block %v at height %d would reorganize "+
+		"the chain from the fork at height %d/block %v, disconnecting "+
+		"%d blocks (max %d) and connecting %d blocks with chain work "+
+		"%v (main chain work %v).  Use the approvereorg RPC to allow "+
+		"the reorganization", node.hash, node.height, fork.height,
+		fork.hash, detach, b.maxReorgDepth, node.height-fork.height,
+		node.workSum, tip.workSum)
+	return false
+}
+
+// prunePendingReorgs removes the pending reorganizations whose competing chain
+// no longer has more work than the main chain, is known to be invalid, or no
+// longer forks from the main chain.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) prunePendingReorgs() {
+	tip := b.bestChain.Tip()
+	for forkHash, pending := range b.pendingReorgs {
+		if pending.tip.workSum.Cmp(tip.workSum) <= 0 ||
+			pending.tip.status.KnownInvalid() ||
+			b.bestChain.Contains(pending.tip) {
+
+			delete(b.pendingReorgs, forkHash)
+		}
+	}
+}
+
+// PendingReorgs returns the reorganizations which are waiting to be approved
+// with ApproveReorg, sorted by the work of the competing chain, highest first.
+// It is always empty when no maximum reorganization depth is configured.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) PendingReorgs() []PendingReorg {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	b.prunePendingReorgs()
+	tip := b.bestChain.Tip()
+	reorgs := make([]PendingReorg, 0, len(b.pendingReorgs))
+	for _, pending := range b.pendingReorgs {
+		node := pending.tip
+		fork := b.bestChain.FindFork(node)
+		reorgs = append(reorgs, PendingReorg{
+			ForkHash:   fork.hash,
+			ForkHeight: fork.height,
+			TipHash:    node.hash,
+			TipHeight:  node.height,
+			Detach:     tip.height - fork.height,
+			Attach:     node.height - fork.height,
+			TipWork:    new(big.Int).Set(node.workSum),
+			MainWork:   new(big.Int).Set(tip.workSum),
+			FirstSeen:  pending.firstSeen,
+		})
+	}
+	sort.Slice(reorgs, func(i, j int) bool {
+		return reorgs[i].TipWork.Cmp(reorgs[j].TipWork) > 0
+	})
+	return reorgs
+}
+
+// ApproveReorg reorganizes the main chain to the competing chain of a pending
+// reorganization even though it disconnects more blocks than the configured
+// maximum reorganization depth.  The passed hash identifies any block of the
+// competing chain after the fork point, and the chain is reorganized to the
+// block the competing chain with the most work ends at.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ApproveReorg(hash *chainhash.Hash) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node, err := b.lookupKnownNode(hash)
+	if err != nil {
+		return err
+	}
+	b.prunePendingReorgs()
+	fork := b.bestChain.FindFork(node)
+	pending, ok := b.pendingReorgs[fork.hash]
+	if !ok || node == fork || pending.tip.Ancestor(node.height) != node {
+		return fmt.Errorf("block %v is not part of a pending "+
+			"reorganization", hash)
+	}
+
+	tip := pending.tip
+	detachNodes, attachNodes := b.getReorganizeNodes(tip)
+	log.Infof("REORGANIZE: Block %v was approved to reorganize the "+
+		"chain from the fork at height %d/block %v", tip.hash,
+		fork.height, fork.hash)
+	if err := b.reorganizeChain(detachNodes, attachNodes, BFNone); err != nil {
+		return err
+	}
+	b.prunePendingReorgs()
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestReorgGuard ensures reorganizations deeper than the maximum depth are held
+// back until they are approved.
+func TestReorgGuard(t *testing.T) {
+	// Load up blocks such that there is a side chain which has more work
+	// than the main chain.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a -> 4a -> 5a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+		"blk_4A.dat.bz2",
+		"blk_5A.dat.bz2",
+	}
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	chain, teardownFunc, err := chainSetup("reorgguard",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+	chain.maxReorgDepth = 1
+
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	// Block 5a would disconnect blocks 3 and 4, so the chain must stay at
+	// block 4 and report the competing chain.
+	if tip := chain.BestSnapshot().Hash; tip != *blocks[4].Hash() {
+		t.Fatalf("got best block %v, want %v", tip, blocks[4].Hash())
+	}
+	reorgs := chain.PendingReorgs()
+	if len(reorgs) != 1 {
+		t.Fatalf("PendingReorgs: got %d reorganizations, want 1",
+			len(reorgs))
+	}
+	reorg := reorgs[0]
+	if reorg.ForkHash != *blocks[2].Hash() || reorg.ForkHeight != 2 ||
+		reorg.TipHash != *blocks[7].Hash() || reorg.TipHeight != 5 ||
+		reorg.Detach != 2 || reorg.Attach != 3 {
+
+		t.Fatalf("PendingReorgs: unexpected reorganization %+v", reorg)
+	}
+
+	// Blocks of the main chain can't be approved.
+	if err := chain.ApproveReorg(blocks[3].Hash()); err == nil {
+		t.Fatal("ApproveReorg on a main chain block unexpectedly " +
+			"succeeded")
+	}
+
+	// Approving any block of the competing chain switches to its tip.
+	if err := chain.ApproveReorg(blocks[5].Hash()); err != nil {
+		t.Fatalf("ApproveReorg: %v", err)
+	}
+	if tip := chain.BestSnapshot().Hash; tip != *blocks[7].Hash() {
+		t.Fatalf("got best block %v, want %v", tip, blocks[7].Hash())
+	}
+	if reorgs := chain.PendingReorgs(); len(reorgs) != 0 {
+		t.Fatalf("PendingReorgs: got %d reorganizations after "+
+			"approval, want 0", len(reorgs))
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
@@ -21,6 +23,7 @@ type txValidateItem struct {
 	txIn      *wire.TxIn
 	tx        *btcutil.Tx
 	sigHashes *txscript.TxSigHashes
+	stats     *txscript.ExecutionStats
 }
 
 // txValidator provides a type which asynchronously validates transaction
@@ -118,6 +121,12 @@ out:
 				break out
 			}
 
+			// Record the resources used executing the script
+			// pair when requested.
+			if txVI.stats != nil {
+				*txVI.stats = vm.Stats()
+			}
+
 			// Validation succeeded.
 			v.sendResult(nil)
 
@@ -185,6 +194,26 @@ func (v *txValidator) Validate(items []*txValidateItem) error {
 	return nil
 }
 
+// addSigHashes adds the partial sighashes for the passed transaction to the
+// hash cache if they are not already present.  When taproot is active
+// according to the passed flags, the taproot sighashes, which commit to the
+// outputs referenced by the transaction, are also computed using the passed
+// view.  Cached sighashes which were computed without them are replaced.
+func addSigHashes(hashCache *txscript.HashCache, tx *btcutil.Tx,
+	utxoView *UtxoViewpoint, flags txscript.ScriptFlags) {
+
+	taprootActive := flags&txscript.ScriptVerifyTaproot == txscript.ScriptVerifyTaproot
+	sigHashes, found := hashCache.GetSigHashes(tx.Hash())
+	switch {
+	case found && (!taprootActive || sigHashes.Taproot != nil):
+		return
+	case taprootActive:
+		hashCache.AddSigHashesWithPrevOuts(tx.MsgTx(), utxoView)
+	default:
+		hashCache.AddSigHashes(tx.MsgTx())
+	}
+}
+
 // newTxValidator returns a new instance of txValidator to be used for
 // validating transaction scripts asynchronously.
 func newTxValidator(utxoView *UtxoViewpoint, flags txscript.ScriptFlags,
@@ -213,9 +242,8 @@ func ValidateTransactionScripts(tx *btcutil.Tx, utxoView *UtxoViewpoint,
 	// If the hashcache doesn't yet has the sighash midstate for this
 	// transaction, then we'll compute them now so we can re-use them
 	// amongst all worker validation goroutines.
-	if segwitActive && tx.MsgTx().HasWitness() &&
-		!hashCache.ContainsHashes(tx.Hash()) {
-		hashCache.AddSigHashes(tx.MsgTx())
+	if segwitActive && tx.MsgTx().HasWitness() {
+		addSigHashes(hashCache, tx, utxoView, flags)
 	}
 
 	var cachedHashes *txscript.TxSigHashes
@@ -254,13 +282,18 @@ func ValidateTransactionScripts(tx *btcutil.Tx, utxoView *UtxoViewpoint,
 
 // checkBlockScripts executes and validates the scripts for all transactions in
 // the passed block using multiple goroutines.
+//
+// When txStats is not nil, it must have an entry for each transaction in the
+// block, and the resources used executing the scripts of each transaction are
+// added to its entry.
 func checkBlockScripts(block *btcutil.Block, utxoView *UtxoViewpoint,
 	scriptFlags txscript.ScriptFlags, sigCache *txscript.SigCache,
-	hashCache *txscript.HashCache) error {
+	hashCache *txscript.HashCache, txStats []txscript.ExecutionStats) error {
 
 	// First determine if segwit is active according to the scriptFlags. If
 	// it isn't then we don't need to interact with the HashCache.
 	segwitActive := scriptFlags&txscript.ScriptVerifyWitness == txscript.ScriptVerifyWitness
+	taprootActive := scriptFlags&txscript.ScriptVerifyTaproot == txscript.ScriptVerifyTaproot
 
 	// Collect all of the transaction inputs and required information for
 	// validation for all transactions in the block into a single slice.
@@ -269,7 +302,17 @@ func checkBlockScripts(block *btcutil.Block, utxoView *UtxoViewpoint,
 		numInputs += len(tx.MsgTx().TxIn)
 	}
 	txValItems := make([]*txValidateItem, 0, numInputs)
-	for _, tx := range block.Transactions() {
+
+	// Each input records its statistics separately since the inputs are
+	// validated concurrently.  They are added to the statistics of their
+	// transactions once all of the inputs are validated.
+	var inputStats []txscript.ExecutionStats
+	var inputTxIdxs []int
+	if txStats != nil {
+		inputStats = make([]txscript.ExecutionStats, numInputs)
+		inputTxIdxs = make([]int, 0, numInputs)
+	}
+	for txIdx, tx := range block.Transactions() {
 		hash := tx.Hash()
 
 		// If the HashCache is present, and it doesn't yet contain the
@@ -277,16 +320,17 @@ func checkBlockScripts(block *btcutil.Block, utxoView *UtxoViewpoint,
 		// sighashes for the transaction. This allows us to take
 		// advantage of the potential speed savings due to the new
 		// digest algorithm (BIP0143).
-		if segwitActive && tx.HasWitness() && hashCache != nil &&
-			!hashCache.ContainsHashes(hash) {
-
-			hashCache.AddSigHashes(tx.MsgTx())
+		if segwitActive && tx.HasWitness() && hashCache != nil {
+			addSigHashes(hashCache, tx, utxoView, scriptFlags)
 		}
 
 		var cachedHashes *txscript.TxSigHashes
 		if segwitActive && tx.HasWitness() {
 			if hashCache != nil {
 				cachedHashes, _ = hashCache.GetSigHashes(hash)
+			} else if taprootActive {
+				cachedHashes = txscript.NewTxSigHashesWithPrevOuts(
+					tx.MsgTx(), utxoView)
 			} else {
 				cachedHashes = txscript.NewTxSigHashes(tx.MsgTx())
 			}
@@ -304,6 +348,10 @@ func checkBlockScripts(block *btcutil.Block, utxoView *UtxoViewpoint,
 				tx:        tx,
 				sigHashes: cachedHashes,
 			}
+			if txStats != nil {
+				txVI.stats = &inputStats[len(txValItems)]
+				inputTxIdxs = append(inputTxIdxs, txIdx)
+			}
 			txValItems = append(txValItems, txVI)
 		}
 	}
@@ -315,6 +363,9 @@ func checkBlockScripts(block *btcutil.Block, utxoView *UtxoViewpoint,
 		return err
 	}
 	elapsed := time.Since(start)
+	for i, txIdx := range inputTxIdxs {
+		txStats[txIdx].Add(&inputStats[i])
+	}
 
 	log.Tracef("block %v took %v to verify", block.Hash(), elapsed)
 
@@ -331,3 +382,125 @@ func checkBlockScripts(block *btcutil.Block, utxoView *UtxoViewpoint,
 
 	return nil
 }
+
+// TxScriptStats describes the resources used executing the scripts of the
+// inputs of a transaction.
+type TxScriptStats struct {
+	Hash  chainhash.Hash
+	Stats txscript.ExecutionStats
+}
+
+// BlockScriptStats describes the resources used executing the scripts of a
+// block.
+type BlockScriptStats struct {
+	// Total holds the statistics of all of the scripts in the block.
+	Total txscript.ExecutionStats
+
+	// Transactions holds the statistics of each transaction in the block
+	// except the coinbase.
+	Transactions []TxScriptStats
+}
+
+// BlockScriptStats executes the scripts of the main chain block with the passed
+// hash again and returns the resources used doing so.  The outputs spent by the
+// block are loaded from the spend journal, so the stats are not available for
+// blocks whose entries were pruned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BlockScriptStats(hash *chainhash.Hash) (*BlockScriptStats, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil || !b.bestChain.Contains(node) {
+		str := fmt.Sprintf("block %s is not in the main chain", hash)
+		return nil, errNotInMainChain(str)
+	}
+
+	// The genesis block has no spend journal entry and nothing to execute.
+	if node.parent == nil {
+		return &BlockScriptStats{Transactions: []TxScriptStats{}}, nil
+	}
+	if node.height < b.undoPruneHeight {
+		return nil, fmt.Errorf("the spend journal entry of block %s was "+
+			"pruned", hash)
+	}
+
+	var block *btcutil.Block
+	var stxos []spentTxOut
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		block, err = dbFetchBlockByNode(dbTx, node)
+		if err != nil {
+			return err
+		}
+
+		// The spend journal only records the version of the transaction
+		// containing a spent output along with its final spent output,
+		// so provide placeholder entries for the others since the
+		// version does not affect the execution of the scripts.
+		versions := NewUtxoViewpoint()
+		for _, tx := range block.Transactions()[1:] {
+			for _, txIn := range tx.MsgTx().TxIn {
+				versions.entries[txIn.PreviousOutPoint.Hash] =
+					newUtxoEntry(0, false, 0)
+			}
+		}
+		stxos, err = dbFetchSpendJournalEntry(dbTx, block, versions)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Restore the outputs spent by the block into a view.  The spent
+	// txouts are in the order the block spends them.
+	view := NewUtxoViewpoint()
+	view.SetBestHash(&node.parent.hash)
+	stxoIdx := 0
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			stxo := &stxos[stxoIdx]
+			stxoIdx++
+
+			originHash := &txIn.PreviousOutPoint.Hash
+			entry := view.entries[*originHash]
+			if entry == nil {
+				entry = newUtxoEntry(stxo.version, stxo.isCoinBase,
+					stxo.height)
+				view.entries[*originHash] = entry
+			}
+			entry.sparseOutputs[txIn.PreviousOutPoint.Index] = &utxoOutput{
+				compressed: stxo.compressed,
+				amount:     stxo.amount,
+				pkScript:   stxo.pkScript,
+			}
+		}
+	}
+
+	scriptFlags, err := b.blockScriptFlags(node, &block.MsgBlock().Header)
+	if err != nil {
+		return nil, err
+	}
+
+	// Execute the scripts without the signature cache so every signature
+	// is verified as it was when the block was first validated.
+	transactions := block.Transactions()
+	txStats := make([]txscript.ExecutionStats, len(transactions))
+	err = checkBlockScripts(block, view, scriptFlags, nil, nil, txStats)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &BlockScriptStats{
+		Transactions: make([]TxScriptStats, 0, len(transactions)-1),
+	}
+	for i, tx := range transactions[1:] {
+		stats.Total.Add(&txStats[i+1])
+		stats.Transactions = append(stats.Transactions, TxScriptStats{
+			Hash:  *tx.Hash(),
+			Stats: txStats[i+1],
+		})
+	}
+	return stats, nil
+}
@@ -9,6 +9,8 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 )
 
@@ -41,9 +43,88 @@ func TestCheckBlockScripts(t *testing.T) {
 	}
 
 	scriptFlags := txscript.ScriptBip16
-	err = checkBlockScripts(blocks[0], view, scriptFlags, nil, nil)
+	err = checkBlockScripts(blocks[0], view, scriptFlags, nil, nil,
+		nil)
 	if err != nil {
 		t.Errorf("Transaction script validation failed: %v\n", err)
 		return
 	}
+
+	// Validate the scripts again while metering them and ensure every
+	// transaction except the coinbase executed scripts.
+	txStats := make([]txscript.ExecutionStats, len(blocks[0].Transactions()))
+	err = checkBlockScripts(blocks[0], view, scriptFlags, nil, nil,
+		txStats)
+	if err != nil {
+		t.Errorf("Transaction script validation failed: %v\n", err)
+		return
+	}
+	if txStats[0] != (txscript.ExecutionStats{}) {
+		t.Errorf("coinbase has script statistics %+v", txStats[0])
+	}
+	for i, stats := range txStats[1:] {
+		if stats.Opcodes == 0 || stats.StackPeak == 0 || stats.SigOps == 0 {
+			t.Errorf("transaction %d has unexpected script "+
+				"statistics %+v", i+1, stats)
+		}
+	}
+}
+
+// TestBlockScriptStats ensures the scripts of main chain blocks are metered
+// using the outputs they spent from the spend journal.
+func TestBlockScriptStats(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v\n", err)
+	}
+	chain, teardownFunc, err := chainSetup("blockscriptstats",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	for i, block := range blocks {
+		stats, err := chain.BlockScriptStats(block.Hash())
+		if err != nil {
+			t.Fatalf("BlockScriptStats #%d: unexpected error: %v", i,
+				err)
+		}
+		txns := block.Transactions()
+		if len(stats.Transactions) != len(txns)-1 {
+			t.Fatalf("BlockScriptStats #%d: got %d transactions, "+
+				"want %d", i, len(stats.Transactions), len(txns)-1)
+		}
+		var total txscript.ExecutionStats
+		for j, txStats := range stats.Transactions {
+			if txStats.Hash != *txns[j+1].Hash() {
+				t.Fatalf("BlockScriptStats #%d: transaction %d "+
+					"is %v, want %v", i, j, txStats.Hash,
+					txns[j+1].Hash())
+			}
+			if txStats.Stats.SigOps == 0 {
+				t.Fatalf("BlockScriptStats #%d: transaction %d "+
+					"verified no signatures", i, j)
+			}
+			total.Add(&txStats.Stats)
+		}
+		if stats.Total != total {
+			t.Fatalf("BlockScriptStats #%d: got total %+v, want %+v",
+				i, stats.Total, total)
+		}
+	}
+
+	// Blocks which are not in the main chain are rejected.
+	_, err = chain.BlockScriptStats(&chainhash.Hash{})
+	if err == nil {
+		t.Fatal("BlockScriptStats: expected error for unknown block")
+	}
 }
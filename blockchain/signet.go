@@ -0,0 +1,280 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// signetScriptFlags are the script flags used to validate the block solution
+// of a signet block against the challenge of the network as defined by
+// BIP0325.
+const signetScriptFlags = txscript.ScriptBip16 |
+	txscript.ScriptVerifyWitness |
+	txscript.ScriptVerifyDERSignatures |
+	txscript.ScriptStrictMultiSig
+
+var (
+	// signetHeader is the prefix of the data push in the witness
+	// commitment output of the coinbase transaction which contains the
+	// block solution of a signet block.
+	signetHeader = []byte{0xec, 0xc7, 0xda, 0xa2}
+
+	// errSignetParse is returned when the signet commitment of a block can
+	// not be parsed.
+	errSignetParse = errors.New("malformed signet commitment")
+)
+
+// writeSignetPush writes the passed data to the passed buffer as a data push
+// which always uses a length prefix.  This mirrors the serialization of the
+// reference implementation so the modified witness commitment hashes the same
+// and intentionally differs from the canonical pushes of the script builder.
+func writeSignetPush(buf *bytes.Buffer, data []byte) {
+	dataLen := len(data)
+	switch {
+	case dataLen < txscript.OP_PUSHDATA1:
+		buf.WriteByte(byte(dataLen))
+	case dataLen <= 0xff:
+		buf.WriteByte(txscript.OP_PUSHDATA1)
+		buf.WriteByte(byte(dataLen))
+	case dataLen <= 0xffff:
+		var b [2]byte
+		binary.LittleEndian.PutUint16(b[:], uint16(dataLen))
+		buf.WriteByte(txscript.OP_PUSHDATA2)
+		buf.Write(b[:])
+	default:
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], uint32(dataLen))
+		buf.WriteByte(txscript.OP_PUSHDATA4)
+		buf.Write(b[:])
+	}
+	buf.Write(data)
+}
+
+// extractSignetSolution removes the block solution from the passed witness
+// commitment script.  It returns the solution along with the commitment
+// script with the solution stripped from it.  A nil solution is returned
+// along with the unmodified script when there is no solution.
+//
+// Parsing stops at the first malformed opcode without an error in the same way
+// as the reference implementation.
+func extractSignetSolution(script []byte) ([]byte, []byte) {
+	var solution []byte
+	var stripped bytes.Buffer
+out:
+	for offset := 0; offset < len(script); {
+		opcode := script[offset]
+		offset++
+
+		// Determine the length of the data pushed by the opcode, if
+		// any.
+		var dataLen int
+		switch {
+		case opcode < txscript.OP_PUSHDATA1:
+			dataLen = int(opcode)
+		case opcode == txscript.OP_PUSHDATA1:
+			if offset+1 > len(script) {
+				break out
+			}
+			dataLen = int(script[offset])
+			offset++
+		case opcode == txscript.OP_PUSHDATA2:
+			if offset+2 > len(script) {
+				break out
+			}
+			dataLen = int(binary.LittleEndian.Uint16(script[offset:]))
+			offset += 2
+		case opcode == txscript.OP_PUSHDATA4:
+			if offset+4 > len(script) {
+				break out
+			}
+			dataLen = int(binary.LittleEndian.Uint32(script[offset:]))
+			offset += 4
+		}
+		if dataLen < 0 || dataLen > len(script)-offset {
+			break out
+		}
+		data := script[offset : offset+dataLen]
+		offset += dataLen
+
+		// Opcodes and empty pushes are kept as is.
+		if len(data) == 0 {
+			stripped.WriteByte(opcode)
+			continue
+		}
+
+		// The first push which starts with the signet header and has
+		// additional data contains the solution.  Only the header is
+		// left in the stripped commitment.
+		if solution == nil && len(data) > len(signetHeader) &&
+			bytes.HasPrefix(data, signetHeader) {
+
+			solution = data[len(signetHeader):]
+			data = data[:len(signetHeader)]
+		}
+		writeSignetPush(&stripped, data)
+	}
+	if solution == nil {
+		return nil, script
+	}
+	return solution, stripped.Bytes()
+}
+
+// parseSignetSolution parses the passed block solution which consists of a
+// serialized signature script followed by a serialized witness.  All of the
+// solution must be consumed.
+func parseSignetSolution(solution []byte) ([]byte, wire.TxWitness, error) {
+	r := bytes.NewReader(solution)
+	sigScript, err := wire.ReadVarBytes(r, 0, wire.MaxBlockPayload,
+		"signet signature script")
+	if err != nil {
+		return nil, nil, errSignetParse
+	}
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil || count > wire.MaxBlockPayload {
+		return nil, nil, errSignetParse
+	}
+	witness := make(wire.TxWitness, 0, count)
+	for i := uint64(0); i < count; i++ {
+		item, err := wire.ReadVarBytes(r, 0, wire.MaxBlockPayload,
+			"signet witness item")
+		if err != nil {
+			return nil, nil, errSignetParse
+		}
+		witness = append(witness, item)
+	}
+	if r.Len() != 0 {
+		return nil, nil, errSignetParse
+	}
+	return sigScript, witness, nil
+}
+
+// signetTxs returns the virtual transactions used to validate the block
+// solution of the passed signet block against the passed challenge as
+// defined by BIP0325.  The first transaction spends an output locked by the
+// challenge which commits to the block with its solution removed, while the
+// second spends it using the solution.
+func signetTxs(block *btcutil.Block, challenge []byte) (*wire.MsgTx, *wire.MsgTx, error) {
+	msgBlock := block.MsgBlock()
+	if len(msgBlock.Transactions) == 0 {
+		return nil, nil, errSignetParse
+	}
+
+	// Locate the witness commitment output of the coinbase transaction in
+	// the same way as ExtractWitnessCommitment.
+	coinbase := msgBlock.Transactions[0].Copy()
+	commitmentIdx := -1
+	for i := len(coinbase.TxOut) - 1; i >= 0; i-- {
+		pkScript := coinbase.TxOut[i].PkScript
+		if len(pkScript) >= CoinbaseWitnessPkScriptLength &&
+			bytes.HasPrefix(pkScript, WitnessMagicBytes) {
+
+			commitmentIdx = i
+			break
+		}
+	}
+	if commitmentIdx == -1 {
+		return nil, nil, errSignetParse
+	}
+
+	// Remove the solution from the commitment and parse it.  Blocks
+	// without a solution are treated as providing an empty signature
+	// script and witness which is only useful for trivial challenges.
+	txOut := coinbase.TxOut[commitmentIdx]
+	solution, pkScript := extractSignetSolution(txOut.PkScript)
+	var sigScript []byte
+	var witness wire.TxWitness
+	if solution != nil {
+		var err error
+		sigScript, witness, err = parseSignetSolution(solution)
+		if err != nil {
+			return nil, nil, err
+		}
+		txOut.PkScript = pkScript
+	}
+
+	// Calculate the merkle root of the block with the solution removed
+	// from the coinbase transaction.
+	txns := make([]*btcutil.Tx, 0, len(msgBlock.Transactions))
+	txns = append(txns, btcutil.NewTx(coinbase))
+	for _, tx := range msgBlock.Transactions[1:] {
+		txns = append(txns, btcutil.NewTx(tx))
+	}
+	merkles := BuildMerkleTreeStore(txns, false)
+	merkleRoot := merkles[len(merkles)-1]
+
+	// The signature script of the spent transaction commits to the
+	// version, previous block, modified merkle root, and timestamp of the
+	// block.
+	header := &msgBlock.Header
+	var blockData bytes.Buffer
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(header.Version))
+	blockData.Write(b[:])
+	blockData.Write(header.PrevBlock[:])
+	blockData.Write(merkleRoot[:])
+	binary.LittleEndian.PutUint32(b[:], uint32(header.Timestamp.Unix()))
+	blockData.Write(b[:])
+	toSpendSigScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_0).AddData(blockData.Bytes()).Script()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	toSpend := &wire.MsgTx{
+		Version: 0,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+			SignatureScript:  toSpendSigScript,
+		}},
+		TxOut: []*wire.TxOut{{PkScript: challenge}},
+	}
+	toSign := &wire.MsgTx{
+		Version: 0,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Hash: toSpend.TxHash()},
+			SignatureScript:  sigScript,
+			Witness:          witness,
+		}},
+		TxOut: []*wire.TxOut{{PkScript: []byte{txscript.OP_RETURN}}},
+	}
+	return toSpend, toSign, nil
+}
+
+// checkSignetBlockSolution ensures the solution of the passed block satisfies
+// the signet challenge of the passed chain parameters as defined by BIP0325.
+// The genesis block is exempt since it can not commit to a solution.
+func checkSignetBlockSolution(block *btcutil.Block, params *chaincfg.Params) error {
+	if block.Hash().IsEqual(params.GenesisHash) {
+		return nil
+	}
+
+	_, toSign, err := signetTxs(block, params.SignetChallenge)
+	if err != nil {
+		str := fmt.Sprintf("block %v has an invalid signet solution: %v",
+			block.Hash(), err)
+		return ruleError(ErrBadSignetSolution, str)
+	}
+
+	vm, err := txscript.NewEngine(params.SignetChallenge, toSign, 0,
+		signetScriptFlags, nil, nil, 0)
+	if err == nil {
+		err = vm.Execute()
+	}
+	if err != nil {
+		str := fmt.Sprintf("block %v signet solution does not satisfy "+
+			"the challenge: %v", block.Hash(), err)
+		return ruleError(ErrBadSignetSolution, str)
+	}
+	return nil
+}
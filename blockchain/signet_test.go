@@ -0,0 +1,159 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// signetTestBlock returns a block with a witness commitment which carries the
+// passed signet solution.  No solution is added when it is nil.
+func signetTestBlock(solution []byte) *wire.MsgBlock {
+	commitment := append([]byte(nil), WitnessMagicBytes...)
+	commitment = append(commitment, make([]byte, 32)...)
+	if solution != nil {
+		push := append(append([]byte(nil), signetHeader...), solution...)
+		commitment, _ = txscript.NewScriptBuilder().AddOps(commitment).
+			AddData(push).Script()
+	}
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  []byte{txscript.OP_1, txscript.OP_0},
+		Sequence:         wire.MaxTxInSequenceNum,
+		Witness:          wire.TxWitness{make([]byte, 32)},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, []byte{txscript.OP_TRUE}))
+	coinbase.AddTxOut(wire.NewTxOut(0, commitment))
+
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   0x20000000,
+			PrevBlock: *chaincfg.SigNetParams.GenesisHash,
+			Timestamp: time.Unix(1598918500, 0),
+			Bits:      0x1e0377ae,
+		},
+		Transactions: []*wire.MsgTx{coinbase},
+	}
+	block.Header.MerkleRoot = coinbase.TxHash()
+	return block
+}
+
+// signetTestParams returns signet parameters with the passed challenge.
+func signetTestParams(challenge []byte) *chaincfg.Params {
+	params := chaincfg.CustomSignetParams(challenge, nil)
+	return &params
+}
+
+// TestSignetBlockSolution ensures block solutions are validated against the
+// challenge of the signet.
+func TestSignetBlockSolution(t *testing.T) {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	challenge, err := txscript.NewScriptBuilder().
+		AddData(privKey.PubKey().SerializeCompressed()).
+		AddOp(txscript.OP_CHECKSIG).Script()
+	if err != nil {
+		t.Fatalf("unable to build challenge: %v", err)
+	}
+	params := signetTestParams(challenge)
+
+	// The solution is not part of the signed data, so sign the block with
+	// an empty placeholder solution and then replace it.
+	_, toSign, err := signetTxs(btcutil.NewBlock(signetTestBlock([]byte{0,
+		0})), challenge)
+	if err != nil {
+		t.Fatalf("signetTxs: unexpected error: %v", err)
+	}
+	sig, err := txscript.RawTxInSignature(toSign, 0, challenge,
+		txscript.SigHashAll, privKey)
+	if err != nil {
+		t.Fatalf("RawTxInSignature: unexpected error: %v", err)
+	}
+	sigScript, err := txscript.NewScriptBuilder().AddData(sig).Script()
+	if err != nil {
+		t.Fatalf("unable to build signature script: %v", err)
+	}
+	var solution bytes.Buffer
+	wire.WriteVarBytes(&solution, 0, sigScript)
+	wire.WriteVarInt(&solution, 0, 0)
+
+	block := signetTestBlock(solution.Bytes())
+	err = checkSignetBlockSolution(btcutil.NewBlock(block), params)
+	if err != nil {
+		t.Fatalf("checkSignetBlockSolution: unexpected error: %v", err)
+	}
+
+	// The genesis block is exempt from the check.
+	err = checkSignetBlockSolution(btcutil.NewBlock(
+		chaincfg.SigNetParams.GenesisBlock), &chaincfg.SigNetParams)
+	if err != nil {
+		t.Fatalf("checkSignetBlockSolution: unexpected error for "+
+			"genesis block: %v", err)
+	}
+
+	// Modifying the committed block data must invalidate the solution.
+	tampered := signetTestBlock(solution.Bytes())
+	tampered.Header.Timestamp = tampered.Header.Timestamp.Add(time.Second)
+
+	// A solution with trailing data must be rejected.
+	trailing := signetTestBlock(append(solution.Bytes(), 0))
+
+	// A block without a witness commitment can not carry a solution.
+	noCommitment := signetTestBlock(nil)
+	noCommitment.Transactions[0].TxOut = noCommitment.Transactions[0].TxOut[:1]
+
+	// A block without a solution can not satisfy the challenge.
+	noSolution := signetTestBlock(nil)
+
+	tests := []struct {
+		name  string
+		block *wire.MsgBlock
+	}{
+		{"tampered timestamp", tampered},
+		{"trailing solution data", trailing},
+		{"no witness commitment", noCommitment},
+		{"no solution", noSolution},
+	}
+	for _, test := range tests {
+		err := checkSignetBlockSolution(btcutil.NewBlock(test.block),
+			params)
+		if !isRuleErrorCode(err, ErrBadSignetSolution) {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+
+	// A trivial challenge is satisfied by a block without a solution, but
+	// still requires a witness commitment.
+	params = signetTestParams([]byte{txscript.OP_TRUE})
+	err = checkSignetBlockSolution(btcutil.NewBlock(noSolution), params)
+	if err != nil {
+		t.Fatalf("checkSignetBlockSolution: unexpected error for "+
+			"trivial challenge: %v", err)
+	}
+	err = checkSignetBlockSolution(btcutil.NewBlock(noCommitment), params)
+	if !isRuleErrorCode(err, ErrBadSignetSolution) {
+		t.Fatalf("checkSignetBlockSolution: unexpected error for "+
+			"trivial challenge without commitment: %v", err)
+	}
+}
+
+// isRuleErrorCode returns whether the passed error is a RuleError with the
+// passed error code.
+func isRuleErrorCode(err error, c ErrorCode) bool {
+	rerr, ok := err.(RuleError)
+	return ok && rerr.ErrorCode == c
+}
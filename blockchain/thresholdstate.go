@@ -83,6 +83,10 @@ type thresholdConditionChecker interface {
 	// state retarget window.
 	MinerConfirmationWindow() uint32
 
+	// MinActivationHeight is the height of the first block at which a
+	// locked in rule change may become active.
+	MinActivationHeight() uint32
+
 	// Condition returns whether or not the rule change activation condition
 	// has been met.  This typically involves checking whether or not the
 	// bit assocaited with the condition is set, but can be more complex as
@@ -208,9 +212,15 @@ func (b *BlockChain) thresholdState(prevNode *blockNode, checker thresholdCondit
 
 		case ThresholdStarted:
 			// The deployment of the rule change fails if it expires
-			// before it is accepted and locked in.
+			// before it is accepted and locked in.  Deployments
+			// with a minimum activation height follow the Speedy
+			// Trial rules of BIP0341 instead, which give locking in
+			// precedence over expiring, so the votes of the final
+			// window are counted before they expire.
 			medianTime := prevNode.CalcPastMedianTime()
-			if uint64(medianTime.Unix()) >= checker.EndTime() {
+			expired := uint64(medianTime.Unix()) >= checker.EndTime()
+			speedyTrial := checker.MinActivationHeight() != 0
+			if expired && !speedyTrial {
 				state = ThresholdFailed
 				break
 			}
@@ -238,12 +248,17 @@ func (b *BlockChain) thresholdState(prevNode *blockNode, checker thresholdCondit
 			// activation threshold.
 			if count >= checker.RuleChangeActivationThreshold() {
 				state = ThresholdLockedIn
+			} else if expired {
+				state = ThresholdFailed
 			}
 
 		case ThresholdLockedIn:
 			// The new rule becomes active when its previous state
-			// was locked in.
-			state = ThresholdActive
+			// was locked in and the minimum activation height, if
+			// any, has been reached.
+			if uint32(prevNode.height+1) >= checker.MinActivationHeight() {
+				state = ThresholdActive
+			}
 
 		// Nothing to do if the previous state is active or failed since
 		// they are both terminal states.
@@ -301,7 +316,13 @@ func (b *BlockChain) deploymentState(prevNode *blockNode, deploymentID uint32) (
 		return ThresholdFailed, DeploymentError(deploymentID)
 	}
 
+	// Deployments which are always active do not go through the voting
+	// process.
 	deployment := &b.chainParams.Deployments[deploymentID]
+	if deployment.AlwaysActive {
+		return ThresholdActive, nil
+	}
+
 	checker := deploymentChecker{deployment: deployment, chain: b}
 	cache := &b.deploymentCaches[deploymentID]
 
@@ -327,6 +348,9 @@ func (b *BlockChain) initThresholdCaches() error {
 	}
 	for id := 0; id < len(b.chainParams.Deployments); id++ {
 		deployment := &b.chainParams.Deployments[id]
+		if deployment.AlwaysActive {
+			continue
+		}
 		cache := &b.deploymentCaches[id]
 		checker := deploymentChecker{deployment: deployment, chain: b}
 		_, err := b.thresholdState(prevNode, checker, cache)
@@ -6,7 +6,9 @@ package blockchain
 
 import (
 	"testing"
+	"time"
 
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
@@ -132,3 +134,166 @@ nextTest:
 		}
 	}
 }
+
+// testConditionChecker is a thresholdConditionChecker with configurable
+// parameters whose condition is met by the blocks with the lowest version bit
+// set.
+type testConditionChecker struct {
+	beginTime           uint64
+	endTime             uint64
+	threshold           uint32
+	window              uint32
+	minActivationHeight uint32
+}
+
+func (c testConditionChecker) BeginTime() uint64                     { return c.beginTime }
+func (c testConditionChecker) EndTime() uint64                       { return c.endTime }
+func (c testConditionChecker) RuleChangeActivationThreshold() uint32 { return c.threshold }
+func (c testConditionChecker) MinerConfirmationWindow() uint32       { return c.window }
+func (c testConditionChecker) MinActivationHeight() uint32           { return c.minActivationHeight }
+func (c testConditionChecker) Condition(node *blockNode) (bool, error) {
+	return node.version&1 != 0, nil
+}
+
+// TestThresholdStateSpeedyTrial ensures deployments lock in once the threshold
+// of their confirmation window is met, only become active once their minimum
+// activation height is reached, and that deployments with a minimum activation
+// height lock in rather than fail when the threshold is met in the window in
+// which they expire, as defined by the Speedy Trial rules of BIP0341.
+func TestThresholdStateSpeedyTrial(t *testing.T) {
+	// The deployment is started in the second window, blocks 10 to 19,
+	// and the blocks in it signal as given by each test.  Each block is
+	// ten minutes after its parent, so the median time of block 19 is the
+	// time of block 14.
+	const window = 10
+	chain := newFakeChain(&chaincfg.RegressionNetParams)
+	genesis := chain.bestChain.Genesis()
+	baseTime := time.Unix(genesis.timestamp, 0)
+	blockTime := func(height int32) time.Time {
+		return baseTime.Add(time.Duration(height) * 10 * time.Minute)
+	}
+	buildChain := func(numSignals int32) []*blockNode {
+		nodes := []*blockNode{genesis}
+		for height := int32(1); height < 4*window; height++ {
+			var version int32
+			if height >= window && height < window+numSignals {
+				version = 1
+			}
+			nodes = append(nodes, newFakeNode(nodes[height-1],
+				version, 0, blockTime(height)))
+		}
+		return nodes
+	}
+
+	noTimeout := uint64(blockTime(100).Unix())
+	timeoutInWindow := uint64(blockTime(14).Unix())
+	tests := []struct {
+		name                string
+		numSignals          int32
+		endTime             uint64
+		minActivationHeight uint32
+		want                map[int32]ThresholdState
+	}{{
+		name:       "90% threshold met",
+		numSignals: 9,
+		endTime:    noTimeout,
+		want: map[int32]ThresholdState{
+			9:  ThresholdStarted,
+			19: ThresholdLockedIn,
+			29: ThresholdActive,
+		},
+	}, {
+		name:       "90% threshold missed",
+		numSignals: 8,
+		endTime:    noTimeout,
+		want: map[int32]ThresholdState{
+			19: ThresholdStarted,
+			29: ThresholdStarted,
+		},
+	}, {
+		name:                "minimum activation height",
+		numSignals:          9,
+		endTime:             noTimeout,
+		minActivationHeight: 40,
+		want: map[int32]ThresholdState{
+			19: ThresholdLockedIn,
+			29: ThresholdLockedIn,
+			39: ThresholdActive,
+		},
+	}, {
+		name:                "lock in at timeout with min activation height",
+		numSignals:          9,
+		endTime:             timeoutInWindow,
+		minActivationHeight: 40,
+		want: map[int32]ThresholdState{
+			19: ThresholdLockedIn,
+			29: ThresholdLockedIn,
+			39: ThresholdActive,
+		},
+	}, {
+		name:                "fail at timeout with min activation height",
+		numSignals:          8,
+		endTime:             timeoutInWindow,
+		minActivationHeight: 40,
+		want: map[int32]ThresholdState{
+			19: ThresholdFailed,
+			39: ThresholdFailed,
+		},
+	}, {
+		name:       "fail at timeout without min activation height",
+		numSignals: 9,
+		endTime:    timeoutInWindow,
+		want: map[int32]ThresholdState{
+			19: ThresholdFailed,
+			39: ThresholdFailed,
+		},
+	}}
+	for _, test := range tests {
+		nodes := buildChain(test.numSignals)
+		checker := testConditionChecker{
+			endTime:             test.endTime,
+			threshold:           9,
+			window:              window,
+			minActivationHeight: test.minActivationHeight,
+		}
+		for height, want := range test.want {
+			cache := newThresholdCaches(1)[0]
+			got, err := chain.thresholdState(nodes[height], checker,
+				&cache)
+			if err != nil {
+				t.Fatalf("%s: thresholdState: unexpected error: %v",
+					test.name, err)
+			}
+			if got != want {
+				t.Errorf("%s: got state %v after block %d, want %v",
+					test.name, got, height, want)
+			}
+		}
+	}
+}
+
+// TestDeploymentThreshold ensures the activation threshold of a deployment
+// falls back to the one of the chain parameters unless it defines its own.
+func TestDeploymentThreshold(t *testing.T) {
+	params := chaincfg.MainNetParams
+	chain := newFakeChain(&params)
+	tests := []struct {
+		name         string
+		deploymentID int
+		want         uint32
+	}{
+		{"segwit", chaincfg.DeploymentSegwit, 1916},
+		{"taproot", chaincfg.DeploymentTaproot, 1815},
+	}
+	for _, test := range tests {
+		checker := deploymentChecker{
+			deployment: &params.Deployments[test.deploymentID],
+			chain:      chain,
+		}
+		got := checker.RuleChangeActivationThreshold()
+		if got != test.want {
+			t.Errorf("%s: got threshold %d, want %d", test.name, got,
+				test.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"github.com/btcsuite/btcd/database"
+)
+
+const (
+	// MinUndoDepth is the minimum number of the most recent main chain
+	// blocks for which the spend journal entries must be kept when they
+	// are pruned.
+	MinUndoDepth = 288
+
+	// undoPruneBatchSize is the maximum number of spend journal entries
+	// which are removed in a single database transaction while catching
+	// up with the configured undo depth.
+	undoPruneBatchSize = 2000
+)
+
+// undoPruneTarget returns the height below which the spend journal entries
+// must be pruned once the main chain ends at the passed height.  The target is
+// limited to undoPruneBatchSize entries above the current prune height.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) undoPruneTarget(tipHeight int32) int32 {
+	target := tipHeight - b.undoDepth + 1
+	if b.undoDepth <= 0 || target < b.undoPruneHeight {
+		return b.undoPruneHeight
+	}
+	if target-b.undoPruneHeight > undoPruneBatchSize {
+		return b.undoPruneHeight + undoPruneBatchSize
+	}
+	return target
+}
+
+// dbPruneSpendJournal uses an existing database transaction to remove the spend
+// journal entries of the main chain blocks ending with the passed node from the
+// current prune height up to, but not including, the passed height, and stores
+// the new prune height.  The caller must update the prune height of the chain
+// once the transaction is committed.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) dbPruneSpendJournal(dbTx database.Tx, tip *blockNode, height int32) error {
+	for h := b.undoPruneHeight; h < height; h++ {
+		node := tip.Ancestor(h)
+		if err := dbRemoveSpendJournalEntry(dbTx, &node.hash); err != nil {
+			return err
+		}
+	}
+	return dbPutUndoPruneHeight(dbTx, height)
+}
+
+// initUndoPruning loads the height below which the spend journal entries were
+// pruned and, when the entries are pruned, removes the entries which exceed the
+// configured depth.  This is only a significant amount of work the first time
+// the entries are pruned.  The remaining entries are pruned as blocks are
+// connected when the passed interrupt channel is closed.
+func (b *BlockChain) initUndoPruning(interrupt <-chan struct{}) error {
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		b.undoPruneHeight, err = dbFetchUndoPruneHeight(dbTx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	tip := b.bestChain.Tip()
+	if b.undoDepth > 0 && tip.height-b.undoDepth+1-b.undoPruneHeight >
+		undoPruneBatchSize {
+
+		log.Infof("Pruning spend journal entries below height %d",
+			tip.height-b.undoDepth+1)
+	}
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		default:
+		}
+
+		height := b.undoPruneTarget(tip.height)
+		if height == b.undoPruneHeight {
+			return nil
+		}
+		err := b.db.Update(func(dbTx database.Tx) error {
+			return b.dbPruneSpendJournal(dbTx, tip, height)
+		})
+		if err != nil {
+			return err
+		}
+		b.undoPruneHeight = height
+	}
+}
+
+// UndoPruneHeight returns the height below which the spend journal entries of
+// the main chain blocks, which are needed to disconnect them, were pruned.  The
+// chain can't be reorganized to a block below the height less one.  Zero is
+// returned when no entries were pruned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UndoPruneHeight() int32 {
+	b.chainLock.RLock()
+	height := b.undoPruneHeight
+	b.chainLock.RUnlock()
+	return height
+}
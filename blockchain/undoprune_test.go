@@ -0,0 +1,152 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestUndoPruning ensures spend journal entries are pruned below the configured
+// depth and that reorganizations which would need pruned entries are refused.
+func TestUndoPruning(t *testing.T) {
+	// Load up blocks such that a side chain overtakes the main chain.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a -> 4a -> 5a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+		"blk_4A.dat.bz2",
+		"blk_5A.dat.bz2",
+	}
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	chain, teardownFunc, err := chainSetup("undopruning",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	// checkPruned ensures the spend journal entries of the main chain
+	// blocks up to the passed tip are pruned exactly below the passed
+	// height.
+	checkPruned := func(height int32, tip int) {
+		if got := chain.UndoPruneHeight(); got != height {
+			t.Fatalf("got undo prune height %d, want %d", got, height)
+		}
+		err := chain.db.View(func(dbTx database.Tx) error {
+			stored, err := dbFetchUndoPruneHeight(dbTx)
+			if err != nil {
+				return err
+			}
+			if stored != height {
+				t.Fatalf("got stored undo prune height %d, want %d",
+					stored, height)
+			}
+
+			spendBucket := dbTx.Metadata().Bucket(spendJournalBucketName)
+			for i := 1; i <= tip; i++ {
+				hash := blocks[i].Hash()
+				have := spendBucket.Get(hash[:]) != nil
+				if have != (int32(i) >= height) {
+					t.Fatalf("block %d: spend journal entry "+
+						"present %v with undo prune height %d",
+						i, have, height)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected database error: %v", err)
+		}
+	}
+
+	processBlock := func(i int) {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+	initPruning := func(depth int32) {
+		chain.chainLock.Lock()
+		chain.undoDepth = depth
+		err := chain.initUndoPruning(nil)
+		chain.chainLock.Unlock()
+		if err != nil {
+			t.Fatalf("initUndoPruning: unexpected error: %v", err)
+		}
+	}
+
+	// Connect part of the main chain without pruning, then enable pruning
+	// to catch up as if the chain was loaded with an undo depth configured.
+	for i := 1; i <= 3; i++ {
+		processBlock(i)
+	}
+	checkPruned(0, 3)
+	initPruning(2)
+	checkPruned(2, 3)
+
+	// Connecting a block prunes the entry which exceeds the depth.
+	processBlock(4)
+	checkPruned(3, 4)
+
+	// Reduce the depth so the entry the reorganization to the side chain
+	// needs is pruned as well.  The side chain must then be refused while
+	// the main chain stays in place.
+	initPruning(1)
+	checkPruned(4, 4)
+	for i := 5; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if i < len(blocks)-1 {
+			if err != nil {
+				t.Fatalf("ProcessBlock fail on block %v: %v\n", i,
+					err)
+			}
+			continue
+		}
+		if rerr, ok := err.(RuleError); !ok ||
+			rerr.ErrorCode != ErrUndoDataPruned {
+
+			t.Fatalf("ProcessBlock on deep reorganization: got "+
+				"error %v, want %v", err, ErrUndoDataPruned)
+		}
+	}
+	if tip := chain.BestSnapshot().Hash; tip != *blocks[4].Hash() {
+		t.Fatalf("got best block %v, want %v", tip, blocks[4].Hash())
+	}
+	checkPruned(4, 4)
+
+	// Invalidating a block whose entry was pruned must fail and leave the
+	// block and its descendants without invalid marks.
+	err = chain.InvalidateBlock(blocks[3].Hash())
+	if rerr, ok := err.(RuleError); !ok ||
+		rerr.ErrorCode != ErrUndoDataPruned {
+
+		t.Fatalf("InvalidateBlock on pruned block: got error %v, want %v",
+			err, ErrUndoDataPruned)
+	}
+	for i := 3; i <= 4; i++ {
+		node := chain.index.LookupNode(blocks[i].Hash())
+		if node.status != 0 {
+			t.Fatalf("block %d: got status %v after failed "+
+				"invalidation, want 0", i, node.status)
+		}
+	}
+	if tip := chain.BestSnapshot().Hash; tip != *blocks[4].Hash() {
+		t.Fatalf("got best block %v, want %v", tip, blocks[4].Hash())
+	}
+}
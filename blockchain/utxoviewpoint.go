@@ -6,10 +6,12 @@ package blockchain
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 )
 
@@ -152,6 +154,21 @@ func (entry *UtxoEntry) PkScriptByIndex(outputIndex uint32) []byte {
 	return output.pkScript
 }
 
+// UnspentOutputs returns the indexes of the unspent outputs of the transaction
+// the utxo entry represents in ascending order.
+func (entry *UtxoEntry) UnspentOutputs() []uint32 {
+	indexes := make([]uint32, 0, len(entry.sparseOutputs))
+	for outputIndex, output := range entry.sparseOutputs {
+		if !output.spent {
+			indexes = append(indexes, outputIndex)
+		}
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i] < indexes[j]
+	})
+	return indexes
+}
+
 // Clone returns a deep copy of the utxo entry.
 func (entry *UtxoEntry) Clone() *UtxoEntry {
 	if entry == nil {
@@ -223,6 +240,24 @@ func (view *UtxoViewpoint) LookupEntry(txHash *chainhash.Hash) *UtxoEntry {
 	return entry
 }
 
+// FetchPrevOutput returns the output referenced by the passed outpoint or nil
+// when it is not available in the view.  Outputs which have been spent within
+// the view are still returned since the transactions spending them must be
+// able to commit to them.
+//
+// This is part of the txscript.PrevOutputFetcher interface.
+func (view *UtxoViewpoint) FetchPrevOutput(op wire.OutPoint) *wire.TxOut {
+	entry := view.LookupEntry(&op.Hash)
+	if entry == nil {
+		return nil
+	}
+	pkScript := entry.PkScriptByIndex(op.Index)
+	if pkScript == nil {
+		return nil
+	}
+	return wire.NewTxOut(entry.AmountByIndex(op.Index), pkScript)
+}
+
 // AddTxOuts adds all outputs in the passed transaction which are not provably
 // unspendable to the view.  When the view already has entries for any of the
 // outputs, they are simply marked unspent.  All fields will be updated for
@@ -630,3 +665,67 @@ func (b *BlockChain) FetchUtxoEntry(txHash *chainhash.Hash) (*UtxoEntry, error)
 
 	return entry, nil
 }
+
+// ForEachUtxoEntry calls the provided function with the hash and utxo entry of
+// each transaction in the main chain utxo set in ascending order of the hashes,
+// starting with the first hash which is greater than or equal to the passed
+// start hash, or the beginning of the set when it is nil.  No more than the
+// passed maximum number of entries are visited, which allows large utxo sets to
+// be walked in pages without holding the chain lock for long.
+//
+// The hash to pass as the start of the next page is returned, or nil when the
+// end of the utxo set was reached, along with the best chain state the entries
+// reflect.  Since the chain may change between pages, callers walking the set
+// in several pages should compare the best states of the pages.  Iteration is
+// stopped and the error returned when the provided function returns an error.
+//
+// This function is safe for concurrent access however the entries passed to the
+// provided function are NOT.
+func (b *BlockChain) ForEachUtxoEntry(start *chainhash.Hash, maxEntries int,
+	fn func(txHash *chainhash.Hash, entry *UtxoEntry) error) (*chainhash.Hash, *BestState, error) {
+
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	var next *chainhash.Hash
+	err := b.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(utxoSetBucketName).Cursor()
+		ok := cursor.First()
+		if start != nil {
+			ok = cursor.Seek(start[:])
+		}
+		for numEntries := 0; ok; ok = cursor.Next() {
+			var txHash chainhash.Hash
+			copy(txHash[:], cursor.Key())
+			if numEntries >= maxEntries {
+				next = &txHash
+				return nil
+			}
+
+			entry, err := deserializeUtxoEntry(cursor.Value())
+			if err != nil {
+				// Ensure any deserialization errors are returned
+				// as database corruption errors.
+				if isDeserializeErr(err) {
+					return database.Error{
+						ErrorCode: database.ErrCorruption,
+						Description: fmt.Sprintf("corrupt "+
+							"utxo entry for %v: %v",
+							txHash, err),
+					}
+				}
+				return err
+			}
+			if err := fn(&txHash, entry); err != nil {
+				return err
+			}
+			numEntries++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return next, b.stateSnapshot, nil
+}
@@ -34,13 +34,19 @@ const (
 	// used to calculate the median time used to validate block timestamps.
 	medianTimeBlocks = 11
 
+	// maxTimeWarpSeconds is the maximum number of seconds the timestamp of
+	// the first block of a retarget period is allowed to be before the
+	// timestamp of its parent on networks which enforce BIP0094.
+	maxTimeWarpSeconds = 600
+
 	// serializedHeightVersion is the block version which changed block
 	// coinbases to start with the serialized block height.
 	serializedHeightVersion = 2
 
-	// baseSubsidy is the starting subsidy amount for mined blocks.  This
-	// value is halved every SubsidyHalvingInterval blocks.
-	baseSubsidy = 50 * btcutil.SatoshiPerBitcoin
+	// defaultBaseSubsidy is the subsidy of the blocks before the first
+	// reduction on networks whose parameters leave BaseSubsidy unset,
+	// which is the 50 bitcoin of the default networks.
+	defaultBaseSubsidy = 50 * btcutil.SatoshiPerBitcoin
 )
 
 var (
@@ -186,20 +192,47 @@ func isBIP0030Node(node *blockNode) bool {
 // newly generated blocks awards as well as validating the coinbase for blocks
 // has the expected value.
 //
-// The subsidy is halved every SubsidyReductionInterval blocks.  Mathematically
-// this is: baseSubsidy / 2^(height/SubsidyReductionInterval)
+// The subsidy starts at BaseSubsidy and is halved every SubsidyReductionInterval
+// blocks.  Mathematically this is:
+// BaseSubsidy / 2^(height/SubsidyReductionInterval)
+//
+// A zero BaseSubsidy, such as in parameters created outside of chaincfg which
+// predate the field, is treated as the 50 bitcoin of the default networks.
 //
 // At the target block generation rate for the main network, this is
 // approximately every 4 years.
 func CalcBlockSubsidy(height int32, chainParams *chaincfg.Params) int64 {
+	baseSubsidy := chainParams.BaseSubsidy
+	if baseSubsidy == 0 {
+		baseSubsidy = defaultBaseSubsidy
+	}
 	if chainParams.SubsidyReductionInterval == 0 {
 		return baseSubsidy
 	}
 
-	// Equivalent to: baseSubsidy / 2^(height/subsidyHalvingInterval)
+	// Equivalent to: BaseSubsidy / 2^(height/SubsidyReductionInterval)
 	return baseSubsidy >> uint(height/chainParams.SubsidyReductionInterval)
 }
 
+// CalcNextHalvingHeight returns the height of the first block after the block
+// at the provided height which has a lower subsidy, or -1 when the subsidy
+// never decreases again because the network does not reduce it or it already
+// reached zero.
+func CalcNextHalvingHeight(height int32, chainParams *chaincfg.Params) int32 {
+	interval := chainParams.SubsidyReductionInterval
+	if interval == 0 || CalcBlockSubsidy(height, chainParams) == 0 {
+		return -1
+	}
+
+	// Calculate with 64-bit values since the halving after the final
+	// reduction interval which fits into a 32-bit height does not.
+	next := (int64(height)/int64(interval) + 1) * int64(interval)
+	if next > math.MaxInt32 {
+		return -1
+	}
+	return int32(next)
+}
+
 // CheckTransactionSanity performs some preliminary checks on a transaction to
 // ensure it is sane.  These checks are context free.
 func CheckTransactionSanity(tx *btcutil.Tx) error {
@@ -674,6 +707,25 @@ func (b *BlockChain) checkBlockHeaderContext(header *wire.BlockHeader, prevNode
 			str = fmt.Sprintf(str, header.Timestamp, medianTime)
 			return ruleError(ErrTimeTooOld, str)
 		}
+
+		// Ensure the timestamp of the first block of a retarget period
+		// is not too far before the timestamp of the last block of the
+		// previous period on networks which prevent time warp attacks
+		// as defined by BIP0094.
+		if b.chainParams.EnforceBIP94 &&
+			(prevNode.height+1)%b.blocksPerRetarget == 0 {
+
+			minTime := prevNode.timestamp - maxTimeWarpSeconds
+			if header.Timestamp.Unix() < minTime {
+				str := "block timestamp of %v is more than %d " +
+					"seconds before the timestamp %v of the " +
+					"previous block"
+				str = fmt.Sprintf(str, header.Timestamp,
+					maxTimeWarpSeconds,
+					time.Unix(prevNode.timestamp, 0))
+				return ruleError(ErrTimewarpAttack, str)
+			}
+		}
 	}
 
 	// The height of this block is one more than the referenced previous
@@ -974,6 +1026,66 @@ func CheckTransactionInputs(tx *btcutil.Tx, txHeight int32, utxoView *UtxoViewpo
 	return txFeeInSatoshi, nil
 }
 
+// blockScriptFlags returns the script flags to enforce when validating the
+// scripts of the block represented by the passed node and header.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) blockScriptFlags(node *blockNode, blockHeader *wire.BlockHeader) (txscript.ScriptFlags, error) {
+	// Blocks created after the BIP0016 activation time need to have the
+	// pay-to-script-hash checks enabled.
+	var scriptFlags txscript.ScriptFlags
+	if node.timestamp >= txscript.Bip16Activation.Unix() {
+		scriptFlags |= txscript.ScriptBip16
+	}
+
+	// Enforce DER signatures for block versions 3+ once the historical
+	// activation threshold has been reached.  This is part of BIP0066.
+	if blockHeader.Version >= 3 && node.height >= b.chainParams.BIP0066Height {
+		scriptFlags |= txscript.ScriptVerifyDERSignatures
+	}
+
+	// Enforce CHECKLOCKTIMEVERIFY for block versions 4+ once the historical
+	// activation threshold has been reached.  This is part of BIP0065.
+	if blockHeader.Version >= 4 && node.height >= b.chainParams.BIP0065Height {
+		scriptFlags |= txscript.ScriptVerifyCheckLockTimeVerify
+	}
+
+	// Enforce CHECKSEQUENCEVERIFY during all block validation checks once
+	// the soft-fork deployment is fully active.
+	csvState, err := b.deploymentState(node.parent, chaincfg.DeploymentCSV)
+	if err != nil {
+		return 0, err
+	}
+	if csvState == ThresholdActive {
+		scriptFlags |= txscript.ScriptVerifyCheckSequenceVerify
+	}
+
+	// Enforce the segwit soft-fork package once the soft-fork has shifted
+	// into the "active" version bits state.
+	segwitState, err := b.deploymentState(node.parent,
+		chaincfg.DeploymentSegwit)
+	if err != nil {
+		return 0, err
+	}
+	if segwitState == ThresholdActive {
+		scriptFlags |= txscript.ScriptVerifyWitness
+		scriptFlags |= txscript.ScriptStrictMultiSig
+	}
+
+	// Enforce the taproot soft-fork package once the soft-fork has
+	// shifted into the "active" version bits state.
+	taprootState, err := b.deploymentState(node.parent,
+		chaincfg.DeploymentTaproot)
+	if err != nil {
+		return 0, err
+	}
+	if taprootState == ThresholdActive {
+		scriptFlags |= txscript.ScriptVerifyTaproot
+	}
+
+	return scriptFlags, nil
+}
+
 // checkConnectBlock performs several checks to confirm connecting the passed
 // block to the chain represented by the passed view does not violate any rules.
 // In addition, the passed view is updated to spend all of the referenced
@@ -1041,10 +1153,12 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block, vi
 	//
 	// These utxo entries are needed for verification of things such as
 	// transaction inputs, counting pay-to-script-hashes, and scripts.
+	fetchStart := time.Now()
 	err := view.fetchInputUtxos(b.db, block)
 	if err != nil {
 		return err
 	}
+	b.timings.since(PhaseUtxoFetch, fetchStart)
 
 	// BIP0016 describes a pay-to-script-hash type that is considered a
 	// "standard" type.  The rules for this BIP only apply to transactions
@@ -1158,46 +1272,20 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block, vi
 		runScripts = false
 	}
 
-	// Blocks created after the BIP0016 activation time need to have the
-	// pay-to-script-hash checks enabled.
-	var scriptFlags txscript.ScriptFlags
-	if enforceBIP0016 {
-		scriptFlags |= txscript.ScriptBip16
-	}
-
-	// Enforce DER signatures for block versions 3+ once the historical
-	// activation threshold has been reached.  This is part of BIP0066.
-	blockHeader := &block.MsgBlock().Header
-	if blockHeader.Version >= 3 && node.height >= b.chainParams.BIP0066Height {
-		scriptFlags |= txscript.ScriptVerifyDERSignatures
-	}
-
-	// Enforce CHECKLOCKTIMEVERIFY for block versions 4+ once the historical
-	// activation threshold has been reached.  This is part of BIP0065.
-	if blockHeader.Version >= 4 && node.height >= b.chainParams.BIP0065Height {
-		scriptFlags |= txscript.ScriptVerifyCheckLockTimeVerify
-	}
-
-	// Enforce CHECKSEQUENCEVERIFY during all block validation checks once
-	// the soft-fork deployment is fully active.
-	csvState, err := b.deploymentState(node.parent, chaincfg.DeploymentCSV)
+	// Determine the script flags to enforce for the block.
+	scriptFlags, err := b.blockScriptFlags(node, &block.MsgBlock().Header)
 	if err != nil {
 		return err
 	}
-	if csvState == ThresholdActive {
-		// If the CSV soft-fork is now active, then modify the
-		// scriptFlags to ensure that the CSV op code is properly
-		// validated during the script checks bleow.
-		scriptFlags |= txscript.ScriptVerifyCheckSequenceVerify
 
+	// If the CSV soft-fork is active, enforce the relative sequence number
+	// based lock-times within the inputs of all transactions in this
+	// candidate block.
+	if scriptFlags&txscript.ScriptVerifyCheckSequenceVerify != 0 {
 		// We obtain the MTP of the *previous* block in order to
 		// determine if transactions in the current block are final.
 		medianTime := node.parent.CalcPastMedianTime()
 
-		// Additionally, if the CSV soft-fork package is now active,
-		// then we also enforce the relative sequence number based
-		// lock-times within the inputs of all transactions in this
-		// candidate block.
 		for _, tx := range block.Transactions() {
 			// A transaction can only be included within a block
 			// once the sequence locks of *all* its inputs are
@@ -1217,23 +1305,18 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *btcutil.Block, vi
 		}
 	}
 
-	// Enforce the segwit soft-fork package once the soft-fork has shifted
-	// into the "active" version bits state.
-	if enforceSegWit {
-		scriptFlags |= txscript.ScriptVerifyWitness
-		scriptFlags |= txscript.ScriptStrictMultiSig
-	}
-
 	// Now that the inexpensive checks are done and have passed, verify the
 	// transactions are actually allowed to spend the coins by running the
 	// expensive ECDSA signature check scripts.  Doing this last helps
 	// prevent CPU exhaustion attacks.
 	if runScripts {
+		scriptStart := time.Now()
 		err := checkBlockScripts(block, view, scriptFlags, b.sigCache,
-			b.hashCache)
+			b.hashCache, nil)
 		if err != nil {
 			return err
 		}
+		b.timings.since(PhaseScriptValidation, scriptStart)
 	}
 
 	// Update the best hash for view to include this block since all of its
@@ -1267,3 +1350,55 @@ func (b *BlockChain) CheckConnectBlock(block *btcutil.Block) error {
 	view.SetBestHash(&prevNode.hash)
 	return b.checkConnectBlock(newNode, block, view, nil)
 }
+
+// CheckHeaders performs the checks on the passed headers, which must connect
+// to each other, that don't require the full blocks.  The first header must
+// extend a block in the block index.  The checks include ensuring the proof of
+// work, difficulty, and timestamps are valid in relation to the previous
+// headers, and that the headers match the checkpoints.  The headers are not
+// added to the block index.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CheckHeaders(headers []wire.BlockHeader) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if len(headers) == 0 {
+		return nil
+	}
+	prevNode, err := b.lookupKnownNode(&headers[0].PrevBlock)
+	if err != nil {
+		return err
+	}
+	if prevNode.status.KnownInvalid() {
+		str := fmt.Sprintf("previous block %s is known to be invalid",
+			prevNode.hash)
+		return ruleError(ErrInvalidAncestorBlock, str)
+	}
+
+	for i := range headers {
+		header := &headers[i]
+		if header.PrevBlock != prevNode.hash {
+			return fmt.Errorf("header %v does not connect to the "+
+				"previous header %v", header.BlockHash(),
+				prevNode.hash)
+		}
+		err = checkBlockHeaderSanity(header, b.chainParams.PowLimit,
+			b.timeSource, BFNone)
+		if err != nil {
+			return err
+		}
+		err = b.checkBlockHeaderContext(header, prevNode, BFNone)
+		if err != nil {
+			return err
+		}
+
+		// The node is linked to its parent so the context of the next
+		// header can be checked, but it is not added to the index.
+		node := newBlockNode(header, prevNode.height+1)
+		node.parent = prevNode
+		node.workSum.Add(prevNode.workSum, node.workSum)
+		prevNode = node
+	}
+	return nil
+}
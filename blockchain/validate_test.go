@@ -83,6 +83,78 @@ func TestCheckConnectBlock(t *testing.T) {
 	}
 }
 
+// TestCheckHeaders ensures CheckHeaders accepts a valid header chain extending
+// a known block and rejects the headers which are invalid in its context.
+func TestCheckHeaders(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	chain, teardownFunc, err := chainSetup("checkheaders", &params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// solveHeader returns the passed header with a nonce satisfying its
+	// bits, and nextHeader a solved header extending the passed one.
+	solveHeader := func(header wire.BlockHeader) wire.BlockHeader {
+		target := CompactToBig(header.Bits)
+		for {
+			hash := header.BlockHash()
+			if HashToBig(&hash).Cmp(target) <= 0 {
+				return header
+			}
+			header.Nonce++
+		}
+	}
+	nextHeader := func(prev *wire.BlockHeader) wire.BlockHeader {
+		return solveHeader(wire.BlockHeader{
+			Version:   4,
+			PrevBlock: prev.BlockHash(),
+			Timestamp: prev.Timestamp.Add(time.Minute),
+			Bits:      params.PowLimitBits,
+		})
+	}
+	genesis := params.GenesisBlock.Header
+	h1 := nextHeader(&genesis)
+	h2 := nextHeader(&h1)
+	if err := chain.CheckHeaders([]wire.BlockHeader{h1, h2}); err != nil {
+		t.Fatalf("CheckHeaders: unexpected error: %v", err)
+	}
+
+	// Headers with the wrong difficulty or a timestamp which is not after
+	// the median time of the previous headers are rejected.
+	badBits := h2
+	badBits.Bits = params.PowLimitBits - 1
+	badBits = solveHeader(badBits)
+	badTime := h2
+	badTime.Timestamp = genesis.Timestamp
+	badTime = solveHeader(badTime)
+	tests := []struct {
+		name    string
+		headers []wire.BlockHeader
+		code    ErrorCode
+	}{
+		{"unexpected difficulty", []wire.BlockHeader{h1, badBits},
+			ErrUnexpectedDifficulty},
+		{"timestamp too old", []wire.BlockHeader{h1, badTime},
+			ErrTimeTooOld},
+	}
+	for _, test := range tests {
+		err := chain.CheckHeaders(test.headers)
+		if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != test.code {
+			t.Errorf("%s: CheckHeaders: got error %v, want %v",
+				test.name, err, test.code)
+		}
+	}
+
+	// Headers which don't extend a known block or each other are rejected.
+	if _, ok := chain.CheckHeaders([]wire.BlockHeader{h2}).(UnknownBlockError); !ok {
+		t.Error("CheckHeaders: expected error for unknown previous block")
+	}
+	if err := chain.CheckHeaders([]wire.BlockHeader{h1, h1}); err == nil {
+		t.Error("CheckHeaders: expected error for unconnected headers")
+	}
+}
+
 // TestCheckBlockSanity tests the CheckBlockSanity function to ensure it works
 // as expected.
 func TestCheckBlockSanity(t *testing.T) {
@@ -169,6 +241,85 @@ func TestCheckSerializedHeight(t *testing.T) {
 	}
 }
 
+// TestCalcBlockSubsidy ensures the subsidy follows the base subsidy and
+// reduction interval of the network.
+func TestCalcBlockSubsidy(t *testing.T) {
+	customParams := chaincfg.RegressionNetParams
+	customParams.BaseSubsidy = 1000
+	customParams.SubsidyReductionInterval = 10
+	noReduction := customParams
+	noReduction.SubsidyReductionInterval = 0
+	unsetBase := chaincfg.MainNetParams
+	unsetBase.BaseSubsidy = 0
+
+	tests := []struct {
+		name   string
+		params *chaincfg.Params
+		height int32
+		want   int64
+	}{
+		{"genesis", &chaincfg.MainNetParams, 0, 5000000000},
+		{"second era", &chaincfg.MainNetParams, 210000, 2500000000},
+		{"exhausted", &chaincfg.MainNetParams, 64 * 210000, 0},
+		{"custom base", &customParams, 9, 1000},
+		{"custom halving", &customParams, 25, 250},
+		{"custom exhausted", &customParams, 100, 0},
+		{"no reduction", &noReduction, 1000000, 1000},
+		{"unset base", &unsetBase, 0, 5000000000},
+		{"unset base halving", &unsetBase, 210000, 2500000000},
+	}
+
+	for _, test := range tests {
+		got := CalcBlockSubsidy(test.height, test.params)
+		if got != test.want {
+			t.Errorf("CalcBlockSubsidy (%s): got %d, want %d",
+				test.name, got, test.want)
+		}
+	}
+
+	// The next halving is based on the configured schedule as well.
+	if got := CalcNextHalvingHeight(25, &customParams); got != 30 {
+		t.Errorf("CalcNextHalvingHeight (custom): got %d, want 30", got)
+	}
+	if got := CalcNextHalvingHeight(100, &customParams); got != -1 {
+		t.Errorf("CalcNextHalvingHeight (custom exhausted): got %d, "+
+			"want -1", got)
+	}
+}
+
+// TestCalcNextHalvingHeight ensures the height of the next subsidy reduction is
+// calculated correctly, including for networks whose subsidy never decreases.
+func TestCalcNextHalvingHeight(t *testing.T) {
+	noReduction := chaincfg.RegressionNetParams
+	noReduction.SubsidyReductionInterval = 0
+	longInterval := chaincfg.RegressionNetParams
+	longInterval.SubsidyReductionInterval = 1 << 30
+
+	tests := []struct {
+		name   string
+		params *chaincfg.Params
+		height int32
+		want   int32
+	}{
+		{"genesis", &chaincfg.MainNetParams, 0, 210000},
+		{"last before halving", &chaincfg.MainNetParams, 209999, 210000},
+		{"first after halving", &chaincfg.MainNetParams, 210000, 420000},
+		{"short interval", &chaincfg.RegressionNetParams, 151, 300},
+		{"subsidy exhausted", &chaincfg.MainNetParams, 64 * 210000, -1},
+		{"last reduction", &chaincfg.MainNetParams, 32 * 210000, 33 * 210000},
+		{"beyond max height", &longInterval, math.MaxInt32 - 1, -1},
+		{"no reduction", &noReduction, 1000, -1},
+	}
+
+	for _, test := range tests {
+		got := CalcNextHalvingHeight(test.height, test.params)
+		if got != test.want {
+			t.Errorf("CalcNextHalvingHeight (%s): got %d, want %d",
+				test.name, got, test.want)
+		}
+	}
+}
+
 // Block100000 defines block 100,000 of the block chain.  It is used to
 // test Block operations.
 var Block100000 = wire.MsgBlock{
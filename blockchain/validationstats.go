@@ -0,0 +1,171 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// validationStatsWindow is the number of most recent samples kept for each
+// validation phase when calculating the rolling validation statistics.
+const validationStatsWindow = 1000
+
+// ValidationPhase identifies a phase of block validation which is timed
+// separately.
+type ValidationPhase int
+
+// These constants define the timed phases of block validation.
+const (
+	// PhaseHeaderChecks is the time spent on the context free sanity
+	// checks and the checkpoint checks of a block.
+	PhaseHeaderChecks ValidationPhase = iota
+
+	// PhaseContextChecks is the time spent on the checks of a block which
+	// depend on its position within the block chain, such as the
+	// difficulty, median time, and finalized transaction checks.
+	PhaseContextChecks
+
+	// PhaseUtxoFetch is the time spent loading the utxos referenced by the
+	// inputs of a block.
+	PhaseUtxoFetch
+
+	// PhaseScriptValidation is the time spent validating the scripts of a
+	// block.  It is only recorded for blocks whose scripts are validated.
+	PhaseScriptValidation
+
+	// PhaseIndexUpdate is the time spent updating the optional indexes when
+	// a block is connected.  It is only recorded when indexes are enabled.
+	PhaseIndexUpdate
+
+	// PhaseDBFlush is the time spent writing the best state, utxo set,
+	// and spend journal changes of a block to the database, including the
+	// commit of the database transaction.  It excludes the index updates.
+	PhaseDBFlush
+
+	// numValidationPhases is the maximum validation phase.  It is only used
+	// for bounds checking.
+	numValidationPhases
+)
+
+// validationPhaseStrings is a map of validation phases back to their constant
+// names for pretty printing.
+var validationPhaseStrings = map[ValidationPhase]string{
+	PhaseHeaderChecks:     "headerchecks",
+	PhaseContextChecks:    "contextchecks",
+	PhaseUtxoFetch:        "utxofetch",
+	PhaseScriptValidation: "scriptvalidation",
+	PhaseIndexUpdate:      "indexupdate",
+	PhaseDBFlush:          "dbflush",
+}
+
+// String returns the ValidationPhase as a human-readable name.
+func (p ValidationPhase) String() string {
+	if s := validationPhaseStrings[p]; s != "" {
+		return s
+	}
+	return "unknown"
+}
+
+// ValidationPhaseStats houses the rolling timing statistics of a validation
+// phase over the most recent samples.
+type ValidationPhaseStats struct {
+	Phase   ValidationPhase
+	Samples int
+	Mean    time.Duration
+	P50     time.Duration
+	P90     time.Duration
+	P99     time.Duration
+	Max     time.Duration
+}
+
+// validationTimings keeps a rolling window of durations for each validation
+// phase.  It has its own lock so the statistics can be queried without the
+// chain lock while blocks are processed.
+type validationTimings struct {
+	mtx     sync.Mutex
+	samples [numValidationPhases][]time.Duration
+	next    [numValidationPhases]int
+}
+
+// record adds a sample for the passed phase, replacing the oldest sample once
+// the window is full.
+//
+// This function is safe for concurrent access.
+func (t *validationTimings) record(phase ValidationPhase, d time.Duration) {
+	t.mtx.Lock()
+	if len(t.samples[phase]) < validationStatsWindow {
+		t.samples[phase] = append(t.samples[phase], d)
+	} else {
+		t.samples[phase][t.next[phase]] = d
+	}
+	t.next[phase] = (t.next[phase] + 1) % validationStatsWindow
+	t.mtx.Unlock()
+}
+
+// since records the time elapsed since the passed start time for the passed
+// phase.
+//
+// This function is safe for concurrent access.
+func (t *validationTimings) since(phase ValidationPhase, start time.Time) {
+	t.record(phase, time.Since(start))
+}
+
+// percentile returns the duration at the passed percentile of the passed
+// sorted durations using the nearest-rank method.
+func percentile(sorted []time.Duration, pct int) time.Duration {
+	rank := (pct*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// stats returns the rolling statistics for every validation phase.
+//
+// This function is safe for concurrent access.
+func (t *validationTimings) stats() []ValidationPhaseStats {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	stats := make([]ValidationPhaseStats, 0, numValidationPhases)
+	for phase := ValidationPhase(0); phase < numValidationPhases; phase++ {
+		ps := ValidationPhaseStats{
+			Phase:   phase,
+			Samples: len(t.samples[phase]),
+		}
+		if ps.Samples == 0 {
+			stats = append(stats, ps)
+			continue
+		}
+
+		sorted := make([]time.Duration, ps.Samples)
+		copy(sorted, t.samples[phase])
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i] < sorted[j]
+		})
+		var total time.Duration
+		for _, d := range sorted {
+			total += d
+		}
+		ps.Mean = total / time.Duration(ps.Samples)
+		ps.P50 = percentile(sorted, 50)
+		ps.P90 = percentile(sorted, 90)
+		ps.P99 = percentile(sorted, 99)
+		ps.Max = sorted[len(sorted)-1]
+		stats = append(stats, ps)
+	}
+	return stats
+}
+
+// ValidationStats returns rolling timing statistics for each phase of block
+// validation over the most recently processed blocks.  The statistics are
+// intended to help localize performance regressions in block processing.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ValidationStats() []ValidationPhaseStats {
+	return b.timings.stats()
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+	"time"
+)
+
+// TestValidationTimings ensures the rolling validation statistics are
+// calculated over the most recent samples of each phase.
+func TestValidationTimings(t *testing.T) {
+	t.Parallel()
+
+	var timings validationTimings
+
+	// Fill the window for the script validation phase with samples from
+	// 1ms to the window size in ms after recording a larger sample which
+	// must be evicted once the window is full.
+	timings.record(PhaseScriptValidation, time.Hour)
+	for i := 1; i <= validationStatsWindow; i++ {
+		timings.record(PhaseScriptValidation,
+			time.Duration(i)*time.Millisecond)
+	}
+	timings.record(PhaseDBFlush, 5*time.Millisecond)
+
+	stats := timings.stats()
+	if len(stats) != int(numValidationPhases) {
+		t.Fatalf("unexpected number of phases - got %d, want %d",
+			len(stats), numValidationPhases)
+	}
+	for _, ps := range stats {
+		var want ValidationPhaseStats
+		switch ps.Phase {
+		case PhaseScriptValidation:
+			want = ValidationPhaseStats{
+				Phase:   PhaseScriptValidation,
+				Samples: validationStatsWindow,
+				Mean:    500500 * time.Microsecond,
+				P50:     500 * time.Millisecond,
+				P90:     900 * time.Millisecond,
+				P99:     990 * time.Millisecond,
+				Max:     1000 * time.Millisecond,
+			}
+		case PhaseDBFlush:
+			want = ValidationPhaseStats{
+				Phase:   PhaseDBFlush,
+				Samples: 1,
+				Mean:    5 * time.Millisecond,
+				P50:     5 * time.Millisecond,
+				P90:     5 * time.Millisecond,
+				P99:     5 * time.Millisecond,
+				Max:     5 * time.Millisecond,
+			}
+		default:
+			want = ValidationPhaseStats{Phase: ps.Phase}
+		}
+		if ps != want {
+			t.Errorf("%v: unexpected stats - got %+v, want %+v",
+				ps.Phase, ps, want)
+		}
+	}
+}
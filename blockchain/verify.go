@@ -0,0 +1,323 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// maxVerifyViewEntries is the maximum number of utxo entries VerifyChain keeps
+// in memory while disconnecting blocks for the deeper verification levels.
+// Blocks beyond the point the limit is reached are only verified with the
+// shallower levels.
+const maxVerifyViewEntries = 500000
+
+// VerifyError identifies the first inconsistency found by VerifyChain along
+// with the block it was found in.
+type VerifyError struct {
+	Hash        chainhash.Hash
+	Height      int32
+	Description string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e VerifyError) Error() string {
+	return fmt.Sprintf("block %v (height %d): %s", e.Hash, e.Height,
+		e.Description)
+}
+
+// verifyError creates a VerifyError for the passed block node.
+func verifyError(node *blockNode, format string, args ...interface{}) VerifyError {
+	return VerifyError{
+		Hash:        node.hash,
+		Height:      node.height,
+		Description: fmt.Sprintf(format, args...),
+	}
+}
+
+// verifiedBlock houses a block VerifyChain disconnected along with its spend
+// journal entry so it can be reconnected afterwards.
+type verifiedBlock struct {
+	node     *blockNode
+	block    *btcutil.Block
+	undoData []byte
+}
+
+// VerifyChain checks the integrity of the most recent depth blocks of the main
+// chain, or the entire main chain when depth is not positive, at the passed
+// level of thoroughness.  Each level includes the checks of the levels below
+// it:
+//
+//  - 0: The blocks are loaded from the database
+//  - 1: The blocks pass the context free sanity checks
+//  - 2: The blocks are disconnected from the utxo set in memory using their
+//       spend journal entries, which must be present and consistent with
+//       the blocks
+//  - 3: Before being disconnected, the outputs created by each block must be
+//       unspent in the utxo set unless spent within the block, and the
+//       outputs it spends must not be
+//  - 4: The disconnected blocks are connected again with full validation,
+//       the spend journal entries they produce must match the stored ones,
+//       and the resulting utxos must match the utxo set in the database
+//
+// Since the disconnected state is kept in memory, levels 2 and above are only
+// applied to as many of the most recent blocks as fit within a fixed limit.
+// They are also not applied to blocks whose spend journal entries were pruned.
+// Nothing is written to the database.
+//
+// A VerifyError which identifies the block is returned for the first
+// inconsistency found, while other errors indicate the verification could not
+// be performed.
+//
+// This function is safe for concurrent access.  The chain is locked for the
+// duration of the verification.
+func (b *BlockChain) VerifyChain(level, depth int32) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	tip := b.bestChain.Tip()
+	var finishHeight int32
+	if depth > 0 && tip.height-depth > 0 {
+		finishHeight = tip.height - depth
+	}
+	log.Infof("Verifying chain for %d blocks at level %d",
+		tip.height-finishHeight, level)
+
+	view := NewUtxoViewpoint()
+	view.SetBestHash(&tip.hash)
+	disconnecting := level >= 2
+	var disconnected []verifiedBlock
+	for node := tip; node != nil && node.height > finishHeight; node = node.parent {
+		var block *btcutil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByNode(dbTx, node)
+			return err
+		})
+		if err != nil {
+			return verifyError(node, "unable to load block: %v", err)
+		}
+
+		if level >= 1 {
+			err := CheckBlockSanity(block, b.chainParams.PowLimit,
+				b.timeSource)
+			if err != nil {
+				return verifyError(node, "block is not sane: %v",
+					err)
+			}
+		}
+
+		if !disconnecting {
+			continue
+		}
+		if len(view.entries) > maxVerifyViewEntries {
+			log.Infof("Limiting verification above level 1 to the "+
+				"%d most recent blocks", len(disconnected))
+			disconnecting = false
+			continue
+		}
+		if node.height < b.undoPruneHeight {
+			log.Infof("Limiting verification above level 1 to the "+
+				"blocks from height %d since the spend journal "+
+				"entries of the blocks below it were pruned",
+				b.undoPruneHeight)
+			disconnecting = false
+			continue
+		}
+
+		if level >= 3 && !isBIP0030Node(node) {
+			if err := b.verifyBlockUtxos(node, block, view); err != nil {
+				return err
+			}
+		}
+
+		// Disconnect the block using its spend journal entry.
+		if err := view.fetchInputUtxos(b.db, block); err != nil {
+			return err
+		}
+		var undoData []byte
+		var stxos []spentTxOut
+		err = b.db.View(func(dbTx database.Tx) error {
+			spendBucket := dbTx.Metadata().Bucket(spendJournalBucketName)
+			undoData = append([]byte(nil),
+				spendBucket.Get(block.Hash()[:])...)
+
+			var err error
+			stxos, err = dbFetchSpendJournalEntry(dbTx, block, view)
+			return err
+		})
+		if err != nil {
+			return verifyError(node, "invalid spend journal entry: %v",
+				err)
+		}
+		if err := view.disconnectTransactions(block, stxos); err != nil {
+			return verifyError(node, "unable to disconnect block: %v",
+				err)
+		}
+		if level >= 4 {
+			disconnected = append(disconnected, verifiedBlock{
+				node:     node,
+				block:    block,
+				undoData: undoData,
+			})
+		}
+	}
+
+	// Connect the disconnected blocks again in order, which must result in
+	// the same spend journal entries and utxo set.
+	for i := len(disconnected) - 1; i >= 0; i-- {
+		vb := &disconnected[i]
+		var stxos []spentTxOut
+		err := b.checkConnectBlock(vb.node, vb.block, view, &stxos)
+		if err != nil {
+			return verifyError(vb.node, "unable to reconnect block: %v",
+				err)
+		}
+		if !bytes.Equal(serializeSpendJournalEntry(stxos), vb.undoData) {
+			return verifyError(vb.node, "spend journal entry does not "+
+				"match the outputs spent by the block")
+		}
+	}
+	if len(disconnected) > 0 {
+		if err := b.verifyViewMatchesUtxoSet(view); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Chain verify completed successfully")
+	return nil
+}
+
+// verifyBlockUtxos ensures the passed view, which must reflect the state of the
+// utxo set right after the passed block was connected, contains the outputs
+// created by the block except those spent within the block, and does not
+// contain the outputs the block spends.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) verifyBlockUtxos(node *blockNode, block *btcutil.Block, view *UtxoViewpoint) error {
+	// Determine the outputs spent within the block and load the entries
+	// for the transactions of the block and the outputs it spends.
+	blockTxns := make(map[chainhash.Hash]struct{})
+	txSet := make(map[chainhash.Hash]struct{})
+	for _, tx := range block.Transactions() {
+		blockTxns[*tx.Hash()] = struct{}{}
+		txSet[*tx.Hash()] = struct{}{}
+	}
+	spentInBlock := make(map[wire.OutPoint]struct{})
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			prevOut := txIn.PreviousOutPoint
+			if _, ok := blockTxns[prevOut.Hash]; ok {
+				spentInBlock[prevOut] = struct{}{}
+			}
+			txSet[prevOut.Hash] = struct{}{}
+		}
+	}
+	if err := view.fetchUtxos(b.db, txSet); err != nil {
+		return err
+	}
+
+	for txIdx, tx := range block.Transactions() {
+		entry := view.LookupEntry(tx.Hash())
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			outpoint := wire.OutPoint{Hash: *tx.Hash(),
+				Index: uint32(txOutIdx)}
+			if _, ok := spentInBlock[outpoint]; ok ||
+				txscript.IsUnspendable(txOut.PkScript) {
+
+				continue
+			}
+
+			if entry == nil || entry.IsOutputSpent(outpoint.Index) {
+				return verifyError(node, "output %v created by "+
+					"the block is missing from the utxo set",
+					outpoint)
+			}
+			if entry.BlockHeight() != node.height ||
+				entry.IsCoinBase() != (txIdx == 0) ||
+				entry.AmountByIndex(outpoint.Index) != txOut.Value ||
+				!bytes.Equal(entry.PkScriptByIndex(outpoint.Index),
+					txOut.PkScript) {
+
+				return verifyError(node, "utxo set entry for "+
+					"output %v does not match the block",
+					outpoint)
+			}
+		}
+
+		if txIdx == 0 {
+			continue
+		}
+		for _, txIn := range tx.MsgTx().TxIn {
+			prevOut := txIn.PreviousOutPoint
+			if _, ok := spentInBlock[prevOut]; ok {
+				continue
+			}
+			entry := view.LookupEntry(&prevOut.Hash)
+			if entry != nil && !entry.IsOutputSpent(prevOut.Index) {
+				return verifyError(node, "output %v spent by the "+
+					"block is still in the utxo set", prevOut)
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifyViewMatchesUtxoSet ensures every entry of the passed view, which must
+// reflect the state of the utxo set as of the end of the main chain, matches
+// the corresponding entry of the utxo set in the database.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) verifyViewMatchesUtxoSet(view *UtxoViewpoint) error {
+	tip := b.bestChain.Tip()
+	if !view.BestHash().IsEqual(&tip.hash) {
+		return verifyError(tip, "reconnected blocks end at %v",
+			view.BestHash())
+	}
+
+	return b.db.View(func(dbTx database.Tx) error {
+		for txHash, entry := range view.entries {
+			dbEntry, err := dbFetchUtxoEntry(dbTx, &txHash)
+			if err != nil {
+				return verifyError(tip, "unable to load utxo set "+
+					"entry for %v: %v", txHash, err)
+			}
+
+			var unspent, dbUnspent []uint32
+			if entry != nil {
+				unspent = entry.UnspentOutputs()
+			}
+			if dbEntry != nil {
+				dbUnspent = dbEntry.UnspentOutputs()
+			}
+			if len(unspent) != len(dbUnspent) {
+				return verifyError(tip, "utxo set entry for %v "+
+					"has %d unspent outputs instead of %d",
+					txHash, len(dbUnspent), len(unspent))
+			}
+			for i, outputIndex := range unspent {
+				if dbUnspent[i] != outputIndex ||
+					dbEntry.AmountByIndex(outputIndex) !=
+						entry.AmountByIndex(outputIndex) ||
+					!bytes.Equal(dbEntry.PkScriptByIndex(outputIndex),
+						entry.PkScriptByIndex(outputIndex)) {
+
+					return verifyError(tip, "utxo set entry "+
+						"for %v does not match the "+
+						"reconnected blocks", txHash)
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/database"
+)
+
+// TestVerifyChain ensures VerifyChain accepts a consistent chain at every level
+// and reports the block of the first inconsistency it finds.
+func TestVerifyChain(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("verifychain",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	for i := 1; i < len(blocks); i++ {
+		if _, _, err := chain.ProcessBlock(blocks[i], BFNone); err != nil {
+			t.Fatalf("ProcessBlock fail on block %d: %v", i, err)
+		}
+	}
+
+	for level := int32(0); level <= 4; level++ {
+		for _, depth := range []int32{0, 2} {
+			if err := chain.VerifyChain(level, depth); err != nil {
+				t.Fatalf("VerifyChain(%d, %d): unexpected error: %v",
+					level, depth, err)
+			}
+		}
+	}
+
+	// checkVerifyError ensures verification at the passed level fails for
+	// the block at the passed height.
+	checkVerifyError := func(desc string, level int32, height int32) {
+		err := chain.VerifyChain(level, 0)
+		verr, ok := err.(VerifyError)
+		if !ok {
+			t.Fatalf("%s: did not receive verify error - got %v",
+				desc, err)
+		}
+		if verr.Height != height || verr.Hash != *blocks[height].Hash() {
+			t.Fatalf("%s: unexpected block in error %v", desc, verr)
+		}
+	}
+
+	// Remove the utxos created by the coinbase of the final block, which
+	// level 3 must detect.
+	update := func(fn func(meta database.Bucket) error) {
+		err := chain.db.Update(func(dbTx database.Tx) error {
+			return fn(dbTx.Metadata())
+		})
+		if err != nil {
+			t.Fatalf("Update: unexpected error: %v", err)
+		}
+	}
+	coinbaseHash := blocks[4].Transactions()[0].Hash()
+	var savedUtxo []byte
+	update(func(meta database.Bucket) error {
+		utxoBucket := meta.Bucket(utxoSetBucketName)
+		savedUtxo = append([]byte(nil), utxoBucket.Get(coinbaseHash[:])...)
+		return utxoBucket.Delete(coinbaseHash[:])
+	})
+	if err := chain.VerifyChain(2, 0); err != nil {
+		t.Fatalf("VerifyChain(2, 0): unexpected error: %v", err)
+	}
+	checkVerifyError("missing utxo", 3, 4)
+	update(func(meta database.Bucket) error {
+		return meta.Bucket(utxoSetBucketName).Put(coinbaseHash[:],
+			savedUtxo)
+	})
+
+	// Remove the spend journal entry of the second block, which level 2
+	// must detect.
+	update(func(meta database.Bucket) error {
+		return meta.Bucket(spendJournalBucketName).Delete(
+			blocks[2].Hash()[:])
+	})
+	if err := chain.VerifyChain(1, 0); err != nil {
+		t.Fatalf("VerifyChain(1, 0): unexpected error: %v", err)
+	}
+	checkVerifyError("missing spend journal entry", 2, 2)
+}
@@ -65,6 +65,19 @@ func GetTransactionWeight(tx *btcutil.Tx) int64 {
 	return int64((baseSize * (WitnessScaleFactor - 1)) + totalSize)
 }
 
+// GetTransactionVirtualSize computes the virtual size of a given transaction.
+// A transaction's virtual size is based off its weight, creating a discount for
+// any witness data it contains, proportional to the current WitnessScaleFactor
+// value.  It is the size used for fee rates and size based policy limits.
+func GetTransactionVirtualSize(tx *btcutil.Tx) int64 {
+	// vSize := (weight(tx) + 3) / 4
+	//       := (((baseSize * 3) + totalSize) + 3) / 4
+	// We add 3 here as a way to compute the ceiling of the prior arithmetic
+	// to 4. The division by 4 creates a discount for wit witness data.
+	return (GetTransactionWeight(tx) + (WitnessScaleFactor - 1)) /
+		WitnessScaleFactor
+}
+
 // GetSigOpCost returns the unified sig op cost for the passed transaction
 // respecting current active soft-forks which modified sig op cost counting.
 // The unified sig op cost for a transaction is computed as the sum of: the
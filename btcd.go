@@ -17,6 +17,7 @@ import (
 
 	"github.com/btcsuite/btcd/blockchain/indexers"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/database/ffldb"
 	"github.com/btcsuite/btcd/limits"
 )
 
@@ -87,6 +88,21 @@ func btcdMain(serverChan chan<- *server) error {
 		defer pprof.StopCPUProfile()
 	}
 
+	// Serve metrics if requested.  The server is started before the block
+	// database is loaded so the progress of a recovery can be monitored.
+	var metrics *metricsServer
+	if cfg.MetricsListen != "" {
+		var err error
+		metrics, err = newMetricsServer(cfg.MetricsListen)
+		if err != nil {
+			btcdLog.Errorf("Unable to start metrics server on %v: %v",
+				cfg.MetricsListen, err)
+			return err
+		}
+		metrics.Start()
+		defer metrics.Stop()
+	}
+
 	// Perform upgrades to btcd as new versions require it.
 	if err := doUpgrades(); err != nil {
 		btcdLog.Errorf("%v", err)
@@ -115,6 +131,19 @@ func btcdMain(serverChan chan<- *server) error {
 		return nil
 	}
 
+	// Reprocess any blocks stored after a restored metadata snapshot was
+	// taken.  This is done on every start so an interrupted replay resumes.
+	if cfg.DbType == "ffldb" {
+		err := replayBlocks(db, metadataReplayDir(), interrupt)
+		if err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+		if interruptRequested(interrupt) {
+			return nil
+		}
+	}
+
 	// Drop indexes and exit if requested.
 	//
 	// NOTE: The order is important here because dropping the tx index also
@@ -127,6 +156,14 @@ func btcdMain(serverChan chan<- *server) error {
 
 		return nil
 	}
+	if cfg.DropRewardIndex {
+		if err := indexers.DropRewardIndex(db, interrupt); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
 	if cfg.DropTxIndex {
 		if err := indexers.DropTxIndex(db, interrupt); err != nil {
 			btcdLog.Errorf("%v", err)
@@ -152,10 +189,24 @@ func btcdMain(serverChan chan<- *server) error {
 		srvrLog.Infof("Server shutdown complete")
 	}()
 	server.Start()
+	if metrics != nil {
+		metrics.SetServer(server)
+	}
 	if serverChan != nil {
 		serverChan <- server
 	}
 
+	// Replay the trace when requested.  The node keeps running afterwards
+	// so the resulting state can be inspected via RPC.
+	if cfg.ReplayTrace != "" {
+		err := replayTrace(server.traceReplayConfig(), cfg.ReplayTrace,
+			interrupt)
+		if err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+	}
+
 	// Wait until the interrupt signal is received from an OS signal or
 	// shutdown is requested through one of the subsystems such as the RPC
 	// server.
@@ -202,6 +253,17 @@ func blockDbPath(dbType string) string {
 	return dbPath
 }
 
+// blockDbArgs returns the arguments used to open or create the block database
+// at the passed path.  The directory which houses the oldest block files is
+// only passed when it is configured since it is specific to ffldb.
+func blockDbArgs(dbPath string) []interface{} {
+	args := []interface{}{dbPath, activeNetParams.Net}
+	if cfg.ColdBlockDir != "" {
+		args = append(args, false, cfg.ColdBlockDir)
+	}
+	return args
+}
+
 // warnMultipeDBs shows a warning if multiple block database types are detected.
 // This is not a situation most users want.  It is handy for development however
 // to support multiple side-by-side databases.
@@ -262,8 +324,48 @@ func loadBlockDB() (database.DB, error) {
 	// each run, so remove it now if it already exists.
 	removeRegressionDB(dbPath)
 
-	btcdLog.Infof("Loading block database from '%s'", dbPath)
-	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	// Restore the metadata from a snapshot if requested.  The blocks stored
+	// after the snapshot was taken are reprocessed once the database is
+	// loaded.
+	if cfg.RestoreMetadata {
+		err := restoreMetadata(dbPath, metadataBackupDir(),
+			metadataReplayDir())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Check all of the stored blocks for damage when requested since only
+	// the most recently stored one is checked when the database is opened.
+	var db database.DB
+	var err error
+	if cfg.VerifyBlockFiles && fileExists(dbPath) {
+		btcdLog.Infof("Verifying the stored blocks in '%s'", dbPath)
+		err = ffldb.VerifyBlockFiles(dbPath, cfg.ColdBlockDir,
+			activeNetParams.Net)
+	}
+
+	if err == nil {
+		btcdLog.Infof("Loading block database from '%s'", dbPath)
+		db, err = database.Open(cfg.DbType, blockDbArgs(dbPath)...)
+	}
+
+	// Repair the database and try again when it is corrupted and automatic
+	// recovery is enabled.  The blocks which have to be reprocessed are
+	// handled once the database is loaded.
+	if dbErr, ok := err.(database.Error); ok && cfg.AutoRecover &&
+		dbErr.ErrorCode == database.ErrCorruption {
+
+		btcdLog.Warnf("Block database corruption detected: %v", err)
+		btcdLog.Infof("Attempting automatic recovery")
+		if err := recoverBlockDB(dbPath, metadataBackupDir(),
+			metadataReplayDir()); err != nil {
+
+			return nil, fmt.Errorf("automatic recovery failed: %v",
+				err)
+		}
+		db, err = database.Open(cfg.DbType, blockDbArgs(dbPath)...)
+	}
 	if err != nil {
 		// Return the error if it's not because the database doesn't
 		// exist.
@@ -278,7 +380,7 @@ func loadBlockDB() (database.DB, error) {
 		if err != nil {
 			return nil, err
 		}
-		db, err = database.Create(cfg.DbType, dbPath, activeNetParams.Net)
+		db, err = database.Create(cfg.DbType, blockDbArgs(dbPath)...)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,234 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcec
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// These constants define the lengths of serialized BIP0340 signatures and
+// x-only public keys.
+const (
+	SchnorrSigBytesLen    = 64
+	SchnorrPubKeyBytesLen = 32
+
+	schnorrAuxRandBytesLen   = 32
+	schnorrScalarBytesLen    = 32
+	schnorrFieldElemBytesLen = 32
+)
+
+var (
+	// bip340ChallengeTag, bip340AuxTag and bip340NonceTag are the tags
+	// used for the tagged hashes defined by BIP0340.
+	bip340ChallengeTag = []byte("BIP0340/challenge")
+	bip340AuxTag       = []byte("BIP0340/aux")
+	bip340NonceTag     = []byte("BIP0340/nonce")
+)
+
+// SchnorrSignature is a type representing a BIP0340 schnorr signature.  R is
+// the x coordinate of the nonce point and S is the signature scalar.
+type SchnorrSignature struct {
+	R *big.Int
+	S *big.Int
+}
+
+// taggedHash returns sha256(sha256(tag) || sha256(tag) || msgs...) as defined
+// by BIP0340.
+func taggedHash(tag []byte, msgs ...[]byte) []byte {
+	tagHash := sha256.Sum256(tag)
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, msg := range msgs {
+		h.Write(msg)
+	}
+	return h.Sum(nil)
+}
+
+// ParseSchnorrSignature parses a 64-byte BIP0340 signature.  An error is
+// returned when the signature is not 64 bytes, the R value is not a valid
+// field element, or the S value is not less than the group order.
+func ParseSchnorrSignature(sig []byte) (*SchnorrSignature, error) {
+	if len(sig) != SchnorrSigBytesLen {
+		return nil, errors.New("malformed schnorr signature: wrong size")
+	}
+
+	curve := S256()
+	r := new(big.Int).SetBytes(sig[:32])
+	if r.Cmp(curve.P) >= 0 {
+		return nil, errors.New("schnorr signature R is not a field " +
+			"element")
+	}
+	s := new(big.Int).SetBytes(sig[32:])
+	if s.Cmp(curve.N) >= 0 {
+		return nil, errors.New("schnorr signature S is >= curve.N")
+	}
+	return &SchnorrSignature{R: r, S: s}, nil
+}
+
+// Serialize returns the 64-byte BIP0340 encoding of the signature.
+func (sig *SchnorrSignature) Serialize() []byte {
+	b := make([]byte, 0, SchnorrSigBytesLen)
+	b = paddedAppend(schnorrFieldElemBytesLen, b, sig.R.Bytes())
+	return paddedAppend(schnorrScalarBytesLen, b, sig.S.Bytes())
+}
+
+// ParseSchnorrPubKey parses a 32-byte x-only public key as defined by BIP0340.
+// The returned public key is the point with the provided x coordinate and an
+// even y coordinate.
+func ParseSchnorrPubKey(pubKey []byte) (*PublicKey, error) {
+	if len(pubKey) != SchnorrPubKeyBytesLen {
+		return nil, errors.New("malformed schnorr public key: wrong " +
+			"size")
+	}
+
+	curve := S256()
+	x := new(big.Int).SetBytes(pubKey)
+	if x.Cmp(curve.P) >= 0 {
+		return nil, errors.New("schnorr public key x coordinate is " +
+			"not a field element")
+	}
+	y, err := decompressPoint(curve, x, false)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("schnorr public key is not on the curve")
+	}
+	return &PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// SerializeSchnorrPubKey returns the 32-byte x-only encoding of the passed
+// public key as defined by BIP0340.
+func SerializeSchnorrPubKey(pubKey *PublicKey) []byte {
+	b := make([]byte, 0, SchnorrPubKeyBytesLen)
+	return paddedAppend(SchnorrPubKeyBytesLen, b, pubKey.X.Bytes())
+}
+
+// schnorrChallenge returns the BIP0340 challenge e = int(hash(r || P || m))
+// mod n for the passed values.
+func schnorrChallenge(r []byte, pubKey *PublicKey, hash []byte) *big.Int {
+	var buf [96]byte
+	copy(buf[:32], r)
+	copy(buf[32:64], SerializeSchnorrPubKey(pubKey))
+	copy(buf[64:], hash)
+	e := new(big.Int).SetBytes(taggedHash(bip340ChallengeTag, buf[:]))
+	return e.Mod(e, S256().N)
+}
+
+// Verify returns whether or not the signature is a valid BIP0340 signature of
+// the passed 32-byte hash by the passed public key.  Only the x coordinate of
+// the public key is used, per the x-only public key semantics of BIP0340.
+func (sig *SchnorrSignature) Verify(hash []byte, pubKey *PublicKey) bool {
+	if len(hash) != 32 || sig.R == nil || sig.S == nil {
+		return false
+	}
+	curve := S256()
+	if sig.R.Cmp(curve.P) >= 0 || sig.S.Cmp(curve.N) >= 0 {
+		return false
+	}
+
+	// Use the point with an even y coordinate for the public key.
+	px, py := pubKey.X, pubKey.Y
+	if isOdd(py) {
+		py = new(big.Int).Sub(curve.P, py)
+	}
+
+	rBytes := make([]byte, 0, schnorrFieldElemBytesLen)
+	rBytes = paddedAppend(schnorrFieldElemBytesLen, rBytes, sig.R.Bytes())
+	e := schnorrChallenge(rBytes, pubKey, hash)
+
+	// R = s*G - e*P
+	sgx, sgy := curve.ScalarBaseMult(sig.S.Bytes())
+	epx, epy := curve.ScalarMult(px, py, e.Bytes())
+	if epx.Sign() != 0 || epy.Sign() != 0 {
+		epy = new(big.Int).Sub(curve.P, epy)
+	}
+	rx, ry := curve.Add(sgx, sgy, epx, epy)
+
+	// Fail when R is the point at infinity, has an odd y coordinate, or
+	// does not have the expected x coordinate.
+	if rx.Sign() == 0 && ry.Sign() == 0 {
+		return false
+	}
+	if isOdd(ry) {
+		return false
+	}
+	return rx.Cmp(sig.R) == 0
+}
+
+// SignSchnorr produces a BIP0340 signature of the passed 32-byte hash using the
+// private key.  The auxiliary random data must either be nil, in which case it
+// is read from a cryptographically secure source, or exactly 32 bytes.
+// Providing a fixed value results in deterministic signatures, which is useful
+// for testing.
+func SignSchnorr(privKey *PrivateKey, hash []byte, auxRand []byte) (*SchnorrSignature, error) {
+	if len(hash) != 32 {
+		return nil, errors.New("schnorr signing requires a 32-byte hash")
+	}
+	if auxRand == nil {
+		auxRand = make([]byte, schnorrAuxRandBytesLen)
+		if _, err := rand.Read(auxRand); err != nil {
+			return nil, err
+		}
+	}
+	if len(auxRand) != schnorrAuxRandBytesLen {
+		return nil, errors.New("schnorr auxiliary random data must " +
+			"be 32 bytes")
+	}
+
+	curve := S256()
+	d := new(big.Int).Set(privKey.D)
+	if d.Sign() == 0 || d.Cmp(curve.N) >= 0 {
+		return nil, errors.New("invalid private key")
+	}
+	pubKey := privKey.PubKey()
+	if isOdd(pubKey.Y) {
+		d.Sub(curve.N, d)
+	}
+
+	// t = bytes(d) xor hash(BIP0340/aux, a)
+	t := make([]byte, 0, schnorrScalarBytesLen)
+	t = paddedAppend(schnorrScalarBytesLen, t, d.Bytes())
+	auxHash := taggedHash(bip340AuxTag, auxRand)
+	for i := range t {
+		t[i] ^= auxHash[i]
+	}
+
+	// k' = int(hash(BIP0340/nonce, t || bytes(P) || m)) mod n
+	var nonceInput [96]byte
+	copy(nonceInput[:32], t)
+	copy(nonceInput[32:64], SerializeSchnorrPubKey(pubKey))
+	copy(nonceInput[64:], hash)
+	k := new(big.Int).SetBytes(taggedHash(bip340NonceTag, nonceInput[:]))
+	k.Mod(k, curve.N)
+	if k.Sign() == 0 {
+		return nil, errors.New("generated schnorr nonce is zero")
+	}
+
+	// Negate the nonce when R has an odd y coordinate.
+	rx, ry := curve.ScalarBaseMult(k.Bytes())
+	if isOdd(ry) {
+		k.Sub(curve.N, k)
+	}
+
+	// s = (k + e*d) mod n
+	rBytes := make([]byte, 0, schnorrFieldElemBytesLen)
+	rBytes = paddedAppend(schnorrFieldElemBytesLen, rBytes, rx.Bytes())
+	e := schnorrChallenge(rBytes, pubKey, hash)
+	s := new(big.Int).Mul(e, d)
+	s.Add(s, k)
+	s.Mod(s, curve.N)
+
+	sig := &SchnorrSignature{R: rx, S: s}
+	if !sig.Verify(hash, pubKey) {
+		return nil, errors.New("generated schnorr signature does not " +
+			"verify")
+	}
+	return sig, nil
+}
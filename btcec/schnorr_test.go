@@ -0,0 +1,147 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcec
+
+import (
+	"bytes"
+	"testing"
+)
+
+// schnorrSignTests are the signing test vectors from BIP0340.
+var schnorrSignTests = []struct {
+	secKey  string
+	pubKey  string
+	auxRand string
+	msg     string
+	sig     string
+}{
+	{
+		secKey:  "0000000000000000000000000000000000000000000000000000000000000003",
+		pubKey:  "f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9",
+		auxRand: "0000000000000000000000000000000000000000000000000000000000000000",
+		msg:     "0000000000000000000000000000000000000000000000000000000000000000",
+		sig:     "e907831f80848d1069a5371b402410364bdf1c5f8307b0084c55f1ce2dca821525f66a4a85ea8b71e482a74f382d2ce5ebeee8fdb2172f477df4900d310536c0",
+	},
+	{
+		secKey:  "b7e151628aed2a6abf7158809cf4f3c762e7160f38b4da56a784d9045190cfef",
+		pubKey:  "dff1d77f2a671c5f36183726db2341be58feae1da2deced843240f7b502ba659",
+		auxRand: "0000000000000000000000000000000000000000000000000000000000000001",
+		msg:     "243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c89",
+		sig:     "6896bd60eeae296db48a229ff71dfe071bde413e6d43f917dc8dcf8c78de33418906d11ac976abccb20b091292bff4ea897efcb639ea871cfa95f6de339e4b0a",
+	},
+	{
+		secKey:  "c90fdaa22168c234c4c6628b80dc1cd129024e088a67cc74020bbea63b14e5c9",
+		pubKey:  "dd308afec5777e13121fa72b9cc1b7cc0139715309b086c960e18fd969774eb8",
+		auxRand: "c87aa53824b4d7ae2eb035a2b5bbbccc080e76cdc6d1692c4b0b62d798e6d906",
+		msg:     "7e2d58d8b3bcdf1abadec7829054f90dda9805aab56c77333024b9d0a508b75c",
+		sig:     "5831aaeed7b44bb74e5eab94ba9d4294c49bcf2a60728d8b4c200f50dd313c1bab745879a5ad954a72c45a91c3a51d3c7adea98d82f8481e0e1e03674a6f3fb7",
+	},
+	{
+		secKey:  "0b432b2677937381aef05bb02a66ecd012773062cf3fa2549e44f58ed2401710",
+		pubKey:  "25d1dff95105f5253c4022f628a996ad3a0d95fbf21d468a1b33f8c160d8f517",
+		auxRand: "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		msg:     "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		sig:     "7eb0509757e246f19449885651611cb965ecc1a187dd51b64fda1edc9637d5ec97582b9cb13db3933705b32ba982af5af25fd78881ebb32771fc5922efc66ea3",
+	},
+}
+
+// TestSchnorrSign ensures signing produces the BIP0340 test vector signatures
+// and that the signatures verify.
+func TestSchnorrSign(t *testing.T) {
+	for i, test := range schnorrSignTests {
+		privKey, _ := PrivKeyFromBytes(S256(), decodeHex(test.secKey))
+		pubKeyBytes := SerializeSchnorrPubKey(privKey.PubKey())
+		if !bytes.Equal(pubKeyBytes, decodeHex(test.pubKey)) {
+			t.Errorf("#%d: unexpected public key - got %x, want %s",
+				i, pubKeyBytes, test.pubKey)
+			continue
+		}
+
+		msg := decodeHex(test.msg)
+		sig, err := SignSchnorr(privKey, msg, decodeHex(test.auxRand))
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(sig.Serialize(), decodeHex(test.sig)) {
+			t.Errorf("#%d: unexpected signature - got %x, want %s",
+				i, sig.Serialize(), test.sig)
+			continue
+		}
+
+		pubKey, err := ParseSchnorrPubKey(pubKeyBytes)
+		if err != nil {
+			t.Errorf("#%d: unable to parse public key: %v", i, err)
+			continue
+		}
+		parsedSig, err := ParseSchnorrSignature(decodeHex(test.sig))
+		if err != nil {
+			t.Errorf("#%d: unable to parse signature: %v", i, err)
+			continue
+		}
+		if !parsedSig.Verify(msg, pubKey) {
+			t.Errorf("#%d: signature does not verify", i)
+		}
+	}
+}
+
+// TestSchnorrVerifyInvalid ensures invalid signatures, public keys and
+// messages are rejected.
+func TestSchnorrVerifyInvalid(t *testing.T) {
+	test := schnorrSignTests[1]
+	msg := decodeHex(test.msg)
+	pubKey, err := ParseSchnorrPubKey(decodeHex(test.pubKey))
+	if err != nil {
+		t.Fatalf("unable to parse public key: %v", err)
+	}
+	sigBytes := decodeHex(test.sig)
+
+	// Modified message.
+	badMsg := make([]byte, len(msg))
+	copy(badMsg, msg)
+	badMsg[0] ^= 0x01
+	sig, _ := ParseSchnorrSignature(sigBytes)
+	if sig.Verify(badMsg, pubKey) {
+		t.Error("signature verified for modified message")
+	}
+
+	// Modified signature.
+	badSig := make([]byte, len(sigBytes))
+	copy(badSig, sigBytes)
+	badSig[63] ^= 0x01
+	sig, _ = ParseSchnorrSignature(badSig)
+	if sig.Verify(msg, pubKey) {
+		t.Error("modified signature verified")
+	}
+
+	// Signature from a different key.
+	otherKey, _ := ParseSchnorrPubKey(decodeHex(schnorrSignTests[2].pubKey))
+	sig, _ = ParseSchnorrSignature(sigBytes)
+	if sig.Verify(msg, otherKey) {
+		t.Error("signature verified for wrong public key")
+	}
+
+	// R equal to the field size and S equal to the group order must be
+	// rejected when parsing.
+	rIsP := append(decodeHex("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f"),
+		sigBytes[32:]...)
+	if _, err := ParseSchnorrSignature(rIsP); err == nil {
+		t.Error("signature with R == p parsed")
+	}
+	sIsN := append(append([]byte{}, sigBytes[:32]...),
+		decodeHex("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")...)
+	if _, err := ParseSchnorrSignature(sIsN); err == nil {
+		t.Error("signature with S == n parsed")
+	}
+	if _, err := ParseSchnorrSignature(sigBytes[:63]); err == nil {
+		t.Error("short signature parsed")
+	}
+
+	// Public key which is not on the curve.
+	notOnCurve := decodeHex("eefdea4cdb677750a420fee807eacf21eb9898ae79b9768766e4faa04a2d4a34")
+	if _, err := ParseSchnorrPubKey(notOnCurve); err == nil {
+		t.Error("public key not on the curve parsed")
+	}
+}
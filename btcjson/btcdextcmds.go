@@ -44,6 +44,34 @@ func NewNodeCmd(subCmd NodeSubCmd, target string, connectSubCmd *string) *NodeCm
 	}
 }
 
+// ApproveReorgCmd defines the approvereorg JSON-RPC command.
+type ApproveReorgCmd struct {
+	BlockHash string
+}
+
+// NewApproveReorgCmd returns a new instance which can be used to issue an
+// approvereorg JSON-RPC command.
+func NewApproveReorgCmd(blockHash string) *ApproveReorgCmd {
+	return &ApproveReorgCmd{
+		BlockHash: blockHash,
+	}
+}
+
+// CompareChainsCmd defines the comparechains JSON-RPC command.
+type CompareChainsCmd struct {
+	Hash1 string
+	Hash2 string
+}
+
+// NewCompareChainsCmd returns a new instance which can be used to issue a
+// comparechains JSON-RPC command.
+func NewCompareChainsCmd(hash1, hash2 string) *CompareChainsCmd {
+	return &CompareChainsCmd{
+		Hash1: hash1,
+		Hash2: hash2,
+	}
+}
+
 // DebugLevelCmd defines the debuglevel JSON-RPC command.  This command is not a
 // standard Bitcoin command.  It is an extension for btcd.
 type DebugLevelCmd struct {
@@ -59,6 +87,119 @@ func NewDebugLevelCmd(levelSpec string) *DebugLevelCmd {
 	}
 }
 
+// FlushCacheCmd defines the flushcache JSON-RPC command.
+type FlushCacheCmd struct{}
+
+// NewFlushCacheCmd returns a new instance which can be used to issue a
+// flushcache JSON-RPC command.
+func NewFlushCacheCmd() *FlushCacheCmd {
+	return &FlushCacheCmd{}
+}
+
+// GetBlockRewardsCmd defines the getblockrewards JSON-RPC command.  The end
+// height defaults to the start height.
+type GetBlockRewardsCmd struct {
+	StartHeight int32
+	EndHeight   *int32
+	Verbose     *bool `jsonrpcdefault:"false"`
+}
+
+// NewGetBlockRewardsCmd returns a new instance which can be used to issue a
+// getblockrewards JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetBlockRewardsCmd(startHeight int32, endHeight *int32, verbose *bool) *GetBlockRewardsCmd {
+	return &GetBlockRewardsCmd{
+		StartHeight: startHeight,
+		EndHeight:   endHeight,
+		Verbose:     verbose,
+	}
+}
+
+// GetBlockSubsidyCmd defines the getblocksubsidy JSON-RPC command.  The height
+// defaults to the height of the next block.
+type GetBlockSubsidyCmd struct {
+	Height *int32
+}
+
+// NewGetBlockSubsidyCmd returns a new instance which can be used to issue a
+// getblocksubsidy JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetBlockSubsidyCmd(height *int32) *GetBlockSubsidyCmd {
+	return &GetBlockSubsidyCmd{
+		Height: height,
+	}
+}
+
+// GetBlockStatsCmd defines the getblockstats JSON-RPC command.
+type GetBlockStatsCmd struct {
+	Hash    string
+	Verbose *bool `jsonrpcdefault:"false"`
+}
+
+// NewGetBlockStatsCmd returns a new instance which can be used to issue a
+// getblockstats JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetBlockStatsCmd(hash string, verbose *bool) *GetBlockStatsCmd {
+	return &GetBlockStatsCmd{
+		Hash:    hash,
+		Verbose: verbose,
+	}
+}
+
+// GetPendingReorgsCmd defines the getpendingreorgs JSON-RPC command.
+type GetPendingReorgsCmd struct{}
+
+// NewGetPendingReorgsCmd returns a new instance which can be used to issue a
+// getpendingreorgs JSON-RPC command.
+func NewGetPendingReorgsCmd() *GetPendingReorgsCmd {
+	return &GetPendingReorgsCmd{}
+}
+
+// GetRebroadcastSetCmd defines the getrebroadcastset JSON-RPC command.
+type GetRebroadcastSetCmd struct{}
+
+// NewGetRebroadcastSetCmd returns a new instance which can be used to issue a
+// getrebroadcastset JSON-RPC command.
+func NewGetRebroadcastSetCmd() *GetRebroadcastSetCmd {
+	return &GetRebroadcastSetCmd{}
+}
+
+// AccelerateTxCmd defines the acceleratetx JSON-RPC command.  A boost of zero
+// removes the registration of the transaction.  The expiry is in seconds.
+type AccelerateTxCmd struct {
+	TxID   string
+	Boost  uint32
+	Expiry *int64 `jsonrpcdefault:"86400"`
+}
+
+// NewAccelerateTxCmd returns a new instance which can be used to issue an
+// acceleratetx JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewAccelerateTxCmd(txID string, boost uint32, expiry *int64) *AccelerateTxCmd {
+	return &AccelerateTxCmd{
+		TxID:   txID,
+		Boost:  boost,
+		Expiry: expiry,
+	}
+}
+
+// ListAcceleratedTxsCmd defines the listacceleratedtxs JSON-RPC command.
+type ListAcceleratedTxsCmd struct{}
+
+// NewListAcceleratedTxsCmd returns a new instance which can be used to issue a
+// listacceleratedtxs JSON-RPC command.
+func NewListAcceleratedTxsCmd() *ListAcceleratedTxsCmd {
+	return &ListAcceleratedTxsCmd{}
+}
+
 // GenerateCmd defines the generate JSON-RPC command.
 type GenerateCmd struct {
 	NumBlocks uint32
@@ -72,6 +213,41 @@ func NewGenerateCmd(numBlocks uint32) *GenerateCmd {
 	}
 }
 
+// GenerateToAddressCmd defines the generatetoaddress JSON-RPC command.
+type GenerateToAddressCmd struct {
+	NumBlocks uint32
+	Address   string
+}
+
+// NewGenerateToAddressCmd returns a new instance which can be used to issue a
+// generatetoaddress JSON-RPC command.
+func NewGenerateToAddressCmd(numBlocks uint32, address string) *GenerateToAddressCmd {
+	return &GenerateToAddressCmd{
+		NumBlocks: numBlocks,
+		Address:   address,
+	}
+}
+
+// GenerateBlockCmd defines the generateblock JSON-RPC command.  Each of the
+// transactions is either the id of a transaction in the memory pool or a
+// hex-encoded raw transaction.
+type GenerateBlockCmd struct {
+	Output       string
+	Transactions *[]string
+}
+
+// NewGenerateBlockCmd returns a new instance which can be used to issue a
+// generateblock JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGenerateBlockCmd(output string, transactions *[]string) *GenerateBlockCmd {
+	return &GenerateBlockCmd{
+		Output:       output,
+		Transactions: transactions,
+	}
+}
+
 // GetBestBlockCmd defines the getbestblock JSON-RPC command.
 type GetBestBlockCmd struct{}
 
@@ -111,6 +287,66 @@ func NewGetHeadersCmd(blockLocators []string, hashStop string) *GetHeadersCmd {
 	}
 }
 
+// GetPeerServicesCmd defines the getpeerservices JSON-RPC command.
+type GetPeerServicesCmd struct {
+	Addr    string
+	Timeout *int32 `jsonrpcdefault:"10"`
+}
+
+// NewGetPeerServicesCmd returns a new instance which can be used to issue a
+// getpeerservices JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetPeerServicesCmd(addr string, timeout *int32) *GetPeerServicesCmd {
+	return &GetPeerServicesCmd{
+		Addr:    addr,
+		Timeout: timeout,
+	}
+}
+
+// GetTxTimeLocksCmd defines the gettxtimelocks JSON-RPC command.
+type GetTxTimeLocksCmd struct {
+	HexTx string
+}
+
+// NewGetTxTimeLocksCmd returns a new instance which can be used to issue a
+// gettxtimelocks JSON-RPC command.
+func NewGetTxTimeLocksCmd(hexTx string) *GetTxTimeLocksCmd {
+	return &GetTxTimeLocksCmd{
+		HexTx: hexTx,
+	}
+}
+
+// GetUtxoStatsCmd defines the getutxostats JSON-RPC command.
+type GetUtxoStatsCmd struct {
+	Cursor           *string
+	MaxEntries       *int32 `jsonrpcdefault:"100000"`
+	HeightBucketSize *int32 `jsonrpcdefault:"10000"`
+}
+
+// NewGetUtxoStatsCmd returns a new instance which can be used to issue a
+// getutxostats JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetUtxoStatsCmd(cursor *string, maxEntries, heightBucketSize *int32) *GetUtxoStatsCmd {
+	return &GetUtxoStatsCmd{
+		Cursor:           cursor,
+		MaxEntries:       maxEntries,
+		HeightBucketSize: heightBucketSize,
+	}
+}
+
+// GetValidationStatsCmd defines the getvalidationstats JSON-RPC command.
+type GetValidationStatsCmd struct{}
+
+// NewGetValidationStatsCmd returns a new instance which can be used to issue a
+// getvalidationstats JSON-RPC command.
+func NewGetValidationStatsCmd() *GetValidationStatsCmd {
+	return &GetValidationStatsCmd{}
+}
+
 // VersionCmd defines the version JSON-RPC command.
 //
 // NOTE: This is a btcsuite extension ported from
@@ -128,11 +364,27 @@ func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
 
+	MustRegisterCmd("acceleratetx", (*AccelerateTxCmd)(nil), flags)
+	MustRegisterCmd("approvereorg", (*ApproveReorgCmd)(nil), flags)
+	MustRegisterCmd("comparechains", (*CompareChainsCmd)(nil), flags)
 	MustRegisterCmd("debuglevel", (*DebugLevelCmd)(nil), flags)
 	MustRegisterCmd("node", (*NodeCmd)(nil), flags)
+	MustRegisterCmd("flushcache", (*FlushCacheCmd)(nil), flags)
 	MustRegisterCmd("generate", (*GenerateCmd)(nil), flags)
+	MustRegisterCmd("generateblock", (*GenerateBlockCmd)(nil), flags)
+	MustRegisterCmd("generatetoaddress", (*GenerateToAddressCmd)(nil), flags)
 	MustRegisterCmd("getbestblock", (*GetBestBlockCmd)(nil), flags)
+	MustRegisterCmd("getblockrewards", (*GetBlockRewardsCmd)(nil), flags)
+	MustRegisterCmd("getblockstats", (*GetBlockStatsCmd)(nil), flags)
+	MustRegisterCmd("getblocksubsidy", (*GetBlockSubsidyCmd)(nil), flags)
 	MustRegisterCmd("getcurrentnet", (*GetCurrentNetCmd)(nil), flags)
 	MustRegisterCmd("getheaders", (*GetHeadersCmd)(nil), flags)
+	MustRegisterCmd("getpeerservices", (*GetPeerServicesCmd)(nil), flags)
+	MustRegisterCmd("getpendingreorgs", (*GetPendingReorgsCmd)(nil), flags)
+	MustRegisterCmd("getrebroadcastset", (*GetRebroadcastSetCmd)(nil), flags)
+	MustRegisterCmd("gettxtimelocks", (*GetTxTimeLocksCmd)(nil), flags)
+	MustRegisterCmd("getutxostats", (*GetUtxoStatsCmd)(nil), flags)
+	MustRegisterCmd("getvalidationstats", (*GetValidationStatsCmd)(nil), flags)
+	MustRegisterCmd("listacceleratedtxs", (*ListAcceleratedTxsCmd)(nil), flags)
 	MustRegisterCmd("version", (*VersionCmd)(nil), flags)
 }
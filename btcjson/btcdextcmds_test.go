@@ -30,6 +30,127 @@ func TestBtcdExtCmds(t *testing.T) {
 		marshalled   string
 		unmarshalled interface{}
 	}{
+		{
+			name: "approvereorg",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("approvereorg", "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewApproveReorgCmd("123")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"approvereorg","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.ApproveReorgCmd{
+				BlockHash: "123",
+			},
+		},
+		{
+			name: "getpendingreorgs",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getpendingreorgs")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetPendingReorgsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getpendingreorgs","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetPendingReorgsCmd{},
+		},
+		{
+			name: "comparechains",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("comparechains", "123", "456")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCompareChainsCmd("123", "456")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"comparechains","params":["123","456"],"id":1}`,
+			unmarshalled: &btcjson.CompareChainsCmd{
+				Hash1: "123",
+				Hash2: "456",
+			},
+		},
+		{
+			name: "getblockrewards",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockrewards", 100)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockRewardsCmd(100, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockrewards","params":[100],"id":1}`,
+			unmarshalled: &btcjson.GetBlockRewardsCmd{
+				StartHeight: 100,
+				Verbose:     btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getblockrewards range verbose",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockrewards", 100, 200, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockRewardsCmd(100,
+					btcjson.Int32(200), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockrewards","params":[100,200,true],"id":1}`,
+			unmarshalled: &btcjson.GetBlockRewardsCmd{
+				StartHeight: 100,
+				EndHeight:   btcjson.Int32(200),
+				Verbose:     btcjson.Bool(true),
+			},
+		},
+		{
+			name: "getblocksubsidy",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblocksubsidy")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockSubsidyCmd(nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getblocksubsidy","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetBlockSubsidyCmd{},
+		},
+		{
+			name: "getblocksubsidy height",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblocksubsidy", 210000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockSubsidyCmd(btcjson.Int32(210000))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblocksubsidy","params":[210000],"id":1}`,
+			unmarshalled: &btcjson.GetBlockSubsidyCmd{
+				Height: btcjson.Int32(210000),
+			},
+		},
+		{
+			name: "getblockstats",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockstats", "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockStatsCmd("123", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockstats","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.GetBlockStatsCmd{
+				Hash:    "123",
+				Verbose: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getblockstats verbose",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockstats", "123", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockStatsCmd("123",
+					btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockstats","params":["123",true],"id":1}`,
+			unmarshalled: &btcjson.GetBlockStatsCmd{
+				Hash:    "123",
+				Verbose: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "debuglevel",
 			newCmd: func() (interface{}, error) {
@@ -114,6 +235,56 @@ func TestBtcdExtCmds(t *testing.T) {
 				NumBlocks: 1,
 			},
 		},
+		{
+			name: "generatetoaddress",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generatetoaddress", 2,
+					"SMJ12qn9jNCCXJnTYRz5Yu9ZenERqvYwfg")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateToAddressCmd(2,
+					"SMJ12qn9jNCCXJnTYRz5Yu9ZenERqvYwfg")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generatetoaddress","params":[2,"SMJ12qn9jNCCXJnTYRz5Yu9ZenERqvYwfg"],"id":1}`,
+			unmarshalled: &btcjson.GenerateToAddressCmd{
+				NumBlocks: 2,
+				Address:   "SMJ12qn9jNCCXJnTYRz5Yu9ZenERqvYwfg",
+			},
+		},
+		{
+			name: "generateblock",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generateblock",
+					"SMJ12qn9jNCCXJnTYRz5Yu9ZenERqvYwfg")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateBlockCmd(
+					"SMJ12qn9jNCCXJnTYRz5Yu9ZenERqvYwfg", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generateblock","params":["SMJ12qn9jNCCXJnTYRz5Yu9ZenERqvYwfg"],"id":1}`,
+			unmarshalled: &btcjson.GenerateBlockCmd{
+				Output:       "SMJ12qn9jNCCXJnTYRz5Yu9ZenERqvYwfg",
+				Transactions: nil,
+			},
+		},
+		{
+			name: "generateblock optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generateblock",
+					"SMJ12qn9jNCCXJnTYRz5Yu9ZenERqvYwfg",
+					[]string{"0123", "4567"})
+			},
+			staticCmd: func() interface{} {
+				txs := []string{"0123", "4567"}
+				return btcjson.NewGenerateBlockCmd(
+					"SMJ12qn9jNCCXJnTYRz5Yu9ZenERqvYwfg", &txs)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generateblock","params":["SMJ12qn9jNCCXJnTYRz5Yu9ZenERqvYwfg",["0123","4567"]],"id":1}`,
+			unmarshalled: &btcjson.GenerateBlockCmd{
+				Output:       "SMJ12qn9jNCCXJnTYRz5Yu9ZenERqvYwfg",
+				Transactions: &[]string{"0123", "4567"},
+			},
+		},
 		{
 			name: "getbestblock",
 			newCmd: func() (interface{}, error) {
@@ -176,6 +347,153 @@ func TestBtcdExtCmds(t *testing.T) {
 				HashStop: "000000000000000000ba33b33e1fad70b69e234fc24414dd47113bff38f523f7",
 			},
 		},
+		{
+			name: "getpeerservices",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getpeerservices", "127.0.0.1:8333")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetPeerServicesCmd("127.0.0.1:8333", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getpeerservices","params":["127.0.0.1:8333"],"id":1}`,
+			unmarshalled: &btcjson.GetPeerServicesCmd{
+				Addr:    "127.0.0.1:8333",
+				Timeout: btcjson.Int32(10),
+			},
+		},
+		{
+			name: "getpeerservices optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getpeerservices", "127.0.0.1:8333", 30)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetPeerServicesCmd("127.0.0.1:8333",
+					btcjson.Int32(30))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getpeerservices","params":["127.0.0.1:8333",30],"id":1}`,
+			unmarshalled: &btcjson.GetPeerServicesCmd{
+				Addr:    "127.0.0.1:8333",
+				Timeout: btcjson.Int32(30),
+			},
+		},
+		{
+			name: "gettxtimelocks",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("gettxtimelocks", "0100")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetTxTimeLocksCmd("0100")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxtimelocks","params":["0100"],"id":1}`,
+			unmarshalled: &btcjson.GetTxTimeLocksCmd{
+				HexTx: "0100",
+			},
+		},
+		{
+			name: "getutxostats",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getutxostats")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetUtxoStatsCmd(nil, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getutxostats","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetUtxoStatsCmd{
+				MaxEntries:       btcjson.Int32(100000),
+				HeightBucketSize: btcjson.Int32(10000),
+			},
+		},
+		{
+			name: "getutxostats optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getutxostats", "00ff", 500, 2016)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetUtxoStatsCmd(btcjson.String("00ff"),
+					btcjson.Int32(500), btcjson.Int32(2016))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getutxostats","params":["00ff",500,2016],"id":1}`,
+			unmarshalled: &btcjson.GetUtxoStatsCmd{
+				Cursor:           btcjson.String("00ff"),
+				MaxEntries:       btcjson.Int32(500),
+				HeightBucketSize: btcjson.Int32(2016),
+			},
+		},
+		{
+			name: "flushcache",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("flushcache")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewFlushCacheCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"flushcache","params":[],"id":1}`,
+			unmarshalled: &btcjson.FlushCacheCmd{},
+		},
+		{
+			name: "getrebroadcastset",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getrebroadcastset")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetRebroadcastSetCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getrebroadcastset","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetRebroadcastSetCmd{},
+		},
+		{
+			name: "acceleratetx",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("acceleratetx", "123", 2)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAccelerateTxCmd("123", 2, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"acceleratetx","params":["123",2],"id":1}`,
+			unmarshalled: &btcjson.AccelerateTxCmd{
+				TxID:   "123",
+				Boost:  2,
+				Expiry: btcjson.Int64(86400),
+			},
+		},
+		{
+			name: "acceleratetx optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("acceleratetx", "123", 2, 600)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAccelerateTxCmd("123", 2,
+					btcjson.Int64(600))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"acceleratetx","params":["123",2,600],"id":1}`,
+			unmarshalled: &btcjson.AccelerateTxCmd{
+				TxID:   "123",
+				Boost:  2,
+				Expiry: btcjson.Int64(600),
+			},
+		},
+		{
+			name: "listacceleratedtxs",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listacceleratedtxs")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListAcceleratedTxsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"listacceleratedtxs","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListAcceleratedTxsCmd{},
+		},
+		{
+			name: "getvalidationstats",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getvalidationstats")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetValidationStatsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getvalidationstats","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetValidationStatsCmd{},
+		},
 		{
 			name: "version",
 			newCmd: func() (interface{}, error) {
@@ -18,3 +18,242 @@ type VersionResult struct {
 	Prerelease    string `json:"prerelease"`
 	BuildMetadata string `json:"buildmetadata"`
 }
+
+// GenerateBlockResult models the data returned from the generateblock command.
+type GenerateBlockResult struct {
+	Hash string `json:"hash"`
+}
+
+// ComparedChainResult models one of the chains compared by the comparechains
+// command.  The chain work fields are hex-encoded.
+type ComparedChainResult struct {
+	Hash         string `json:"hash"`
+	Height       int32  `json:"height"`
+	ChainWork    string `json:"chainwork"`
+	BranchLength int32  `json:"branchlength"`
+	BranchWork   string `json:"branchwork"`
+	MainChain    bool   `json:"mainchain"`
+}
+
+// CompareChainsResult models the data returned from the comparechains command.
+// The chain work fields are hex-encoded, and the relative work is the work of
+// the first chain minus the work of the second one, prefixed with a minus sign
+// when it is negative.
+type CompareChainsResult struct {
+	ForkHash      string              `json:"forkhash"`
+	ForkHeight    int32               `json:"forkheight"`
+	ForkChainWork string              `json:"forkchainwork"`
+	Chain1        ComparedChainResult `json:"chain1"`
+	Chain2        ComparedChainResult `json:"chain2"`
+	RelativeWork  string              `json:"relativework"`
+}
+
+// ScriptStatsResult models the resources used executing scripts as returned by
+// the getblockstats command.
+type ScriptStatsResult struct {
+	Opcodes   int `json:"opcodes"`
+	StackPeak int `json:"stackpeak"`
+	SigOps    int `json:"sigops"`
+	HashOps   int `json:"hashops"`
+}
+
+// TxScriptStatsResult models the resources used executing the scripts of a
+// transaction as returned by the getblockstats command.
+type TxScriptStatsResult struct {
+	TxID    string            `json:"txid"`
+	Scripts ScriptStatsResult `json:"scripts"`
+}
+
+// GetBlockStatsResult models the data returned from the getblockstats command.
+// The statistics of the individual transactions are only included when
+// requested.
+type GetBlockStatsResult struct {
+	Hash         string                `json:"hash"`
+	Height       int32                 `json:"height"`
+	Txs          int                   `json:"txs"`
+	Inputs       int                   `json:"inputs"`
+	Outputs      int                   `json:"outputs"`
+	Size         int                   `json:"size"`
+	Weight       int64                 `json:"weight"`
+	Scripts      ScriptStatsResult     `json:"scripts"`
+	Transactions []TxScriptStatsResult `json:"transactions,omitempty"`
+}
+
+// CoinbaseOutputResult models a coinbase output returned by the
+// getblockrewards command.  The value is in satoshis.
+type CoinbaseOutputResult struct {
+	N            uint32             `json:"n"`
+	Value        int64              `json:"value"`
+	ScriptPubKey ScriptPubKeyResult `json:"scriptPubKey"`
+}
+
+// BlockRewardsResult models a block returned by the getblockrewards command.
+// All amounts are in satoshis so the rewards of many blocks can be summed
+// exactly.  The unclaimed amount is the part of the subsidy and fees the
+// coinbase does not pay out.  The coinbase outputs are only included when
+// requested.
+type BlockRewardsResult struct {
+	Hash      string                 `json:"hash"`
+	Height    int32                  `json:"height"`
+	Time      int64                  `json:"time"`
+	Subsidy   int64                  `json:"subsidy"`
+	Fees      int64                  `json:"fees"`
+	Coinbase  int64                  `json:"coinbase"`
+	Unclaimed int64                  `json:"unclaimed"`
+	Outputs   []CoinbaseOutputResult `json:"outputs,omitempty"`
+}
+
+// GetBlockSubsidyResult models the data returned by the getblocksubsidy
+// command.  All amounts are in satoshis.  The next halving is omitted when the
+// subsidy never decreases again.  The fees are those of the block at the height
+// when it is in the main chain, and the expected fees the average fees of the
+// most recent blocks otherwise.  Both require the block reward index.
+type GetBlockSubsidyResult struct {
+	Height            int32  `json:"height"`
+	Subsidy           int64  `json:"subsidy"`
+	Halvings          int32  `json:"halvings"`
+	HalvingInterval   int32  `json:"halvinginterval"`
+	NextHalvingHeight *int32 `json:"nexthalvingheight,omitempty"`
+	NextSubsidy       *int64 `json:"nextsubsidy,omitempty"`
+	Fees              *int64 `json:"fees,omitempty"`
+	ExpectedFees      *int64 `json:"expectedfees,omitempty"`
+}
+
+// PendingReorgResult models a reorganization returned by the getpendingreorgs
+// command.  The time it was first held back is in seconds since 1 Jan 1970
+// GMT.
+type PendingReorgResult struct {
+	ForkHash      string `json:"forkhash"`
+	ForkHeight    int32  `json:"forkheight"`
+	TipHash       string `json:"tiphash"`
+	TipHeight     int32  `json:"tipheight"`
+	Detach        int32  `json:"detach"`
+	Attach        int32  `json:"attach"`
+	TipChainWork  string `json:"tipchainwork"`
+	MainChainWork string `json:"mainchainwork"`
+	FirstSeen     int64  `json:"firstseen"`
+}
+
+// RebroadcastTxResult models a transaction returned by the getrebroadcastset
+// command.  The times are in seconds since 1 Jan 1970 GMT.
+type RebroadcastTxResult struct {
+	TxID          string `json:"txid"`
+	Added         int64  `json:"added"`
+	LastBroadcast int64  `json:"lastbroadcast"`
+	Broadcasts    int    `json:"broadcasts"`
+}
+
+// AcceleratedTxResult models a transaction returned by the listacceleratedtxs
+// command.  The expiration time is in seconds since 1 Jan 1970 GMT.
+type AcceleratedTxResult struct {
+	TxID      string `json:"txid"`
+	Boost     uint32 `json:"boost"`
+	Expires   int64  `json:"expires"`
+	InMempool bool   `json:"inmempool"`
+}
+
+// FlushCacheResult models the data returned from the flushcache command.  The
+// best block is the most recent block which is known to have been made durable
+// by the flush.  The duration is in milliseconds.
+type FlushCacheResult struct {
+	Hash     string `json:"hash"`
+	Height   int32  `json:"height"`
+	Duration int64  `json:"duration"`
+}
+
+// ValidationPhaseStatsResult models the timing statistics of a block
+// validation phase returned by the getvalidationstats command.  All durations
+// are in microseconds.
+type ValidationPhaseStatsResult struct {
+	Phase   string `json:"phase"`
+	Samples int    `json:"samples"`
+	Mean    int64  `json:"mean"`
+	P50     int64  `json:"p50"`
+	P90     int64  `json:"p90"`
+	P99     int64  `json:"p99"`
+	Max     int64  `json:"max"`
+}
+
+// GetValidationStatsResult models the data returned from the
+// getvalidationstats command.
+type GetValidationStatsResult struct {
+	Phases []ValidationPhaseStatsResult `json:"phases"`
+}
+
+// GetPeerServicesResult models the data returned from the getpeerservices
+// command.  All durations are in milliseconds.
+type GetPeerServicesResult struct {
+	Addr                 string   `json:"addr"`
+	Services             string   `json:"services"`
+	ServiceNames         []string `json:"servicenames"`
+	Version              uint32   `json:"version"`
+	SubVer               string   `json:"subver"`
+	StartingHeight       int32    `json:"startingheight"`
+	RelayTxes            bool     `json:"relaytxes"`
+	TimeOffset           int64    `json:"timeoffset"`
+	ConnectTime          float64  `json:"connecttime"`
+	HandshakeTime        float64  `json:"handshaketime"`
+	PingTime             float64  `json:"pingtime"`
+	Messages             []string `json:"messages"`
+	CompactBlocks        bool     `json:"compactblocks"`
+	CompactBlockVersions []uint64 `json:"compactblockversions"`
+	CFilters             bool     `json:"cfilters"`
+}
+
+// ScriptTimeLockResult models a time lock opcode found in a script spent by a
+// transaction input returned by the gettxtimelocks command.  The value is only
+// set when it is pushed right before the opcode.
+type ScriptTimeLockResult struct {
+	Script    string `json:"script"`
+	Opcode    string `json:"opcode"`
+	Value     *int64 `json:"value,omitempty"`
+	Type      string `json:"type"`
+	Satisfied bool   `json:"satisfied"`
+}
+
+// TxInTimeLocksResult models the time locks of a transaction input returned by
+// the gettxtimelocks command.
+type TxInTimeLocksResult struct {
+	Txid             string                 `json:"txid"`
+	Vout             uint32                 `json:"vout"`
+	Sequence         uint32                 `json:"sequence"`
+	PrevOutHeight    *int32                 `json:"prevoutheight,omitempty"`
+	RelativeLockType string                 `json:"relativelocktype"`
+	RelativeLock     int64                  `json:"relativelock"`
+	ScriptLocks      []ScriptTimeLockResult `json:"scriptlocks"`
+}
+
+// GetTxTimeLocksResult models the data returned from the gettxtimelocks
+// command.
+type GetTxTimeLocksResult struct {
+	Txid            string                `json:"txid"`
+	LockTime        uint32                `json:"locktime"`
+	LockTimeType    string                `json:"locktimetype"`
+	Inputs          []TxInTimeLocksResult `json:"inputs"`
+	FinalHeight     int32                 `json:"finalheight"`
+	FinalMedianTime int64                 `json:"finalmediantime"`
+	Final           bool                  `json:"final"`
+}
+
+// UtxoStatsBucket models a group of unspent transaction outputs returned by
+// the getutxostats command.  The amount is in satoshis.
+type UtxoStatsBucket struct {
+	Bucket  string `json:"bucket"`
+	Outputs int64  `json:"outputs"`
+	Amount  int64  `json:"amount"`
+}
+
+// GetUtxoStatsResult models the data returned from the getutxostats command.
+// All amounts are in satoshis so the results of several pages can be summed
+// exactly.
+type GetUtxoStatsResult struct {
+	BestHash     string            `json:"besthash"`
+	Height       int32             `json:"height"`
+	Transactions int64             `json:"transactions"`
+	Outputs      int64             `json:"outputs"`
+	Amount       int64             `json:"amount"`
+	ScriptTypes  []UtxoStatsBucket `json:"scripttypes"`
+	Values       []UtxoStatsBucket `json:"values"`
+	Heights      []UtxoStatsBucket `json:"heights"`
+	Cursor       string            `json:"cursor,omitempty"`
+}
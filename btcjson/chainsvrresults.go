@@ -20,6 +20,7 @@ type GetBlockHeaderVerboseResult struct {
 	Nonce         uint64  `json:"nonce"`
 	Bits          string  `json:"bits"`
 	Difficulty    float64 `json:"difficulty"`
+	ChainWork     string  `json:"chainwork"`
 	PreviousHash  string  `json:"previousblockhash,omitempty"`
 	NextHash      string  `json:"nextblockhash,omitempty"`
 }
@@ -43,6 +44,7 @@ type GetBlockVerboseResult struct {
 	Nonce         uint32        `json:"nonce"`
 	Bits          string        `json:"bits"`
 	Difficulty    float64       `json:"difficulty"`
+	ChainWork     string        `json:"chainwork"`
 	PreviousHash  string        `json:"previousblockhash"`
 	NextHash      string        `json:"nextblockhash,omitempty"`
 }
@@ -109,6 +111,8 @@ type GetBlockChainInfoResult struct {
 	VerificationProgress float64                             `json:"verificationprogress,omitempty"`
 	Pruned               bool                                `json:"pruned"`
 	PruneHeight          int32                               `json:"pruneheight,omitempty"`
+	UndoPruned           bool                                `json:"undopruned"`
+	UndoPruneHeight      int32                               `json:"undopruneheight,omitempty"`
 	ChainWork            string                              `json:"chainwork,omitempty"`
 	SoftForks            []*SoftForkDescription              `json:"softforks"`
 	Bip9SoftForks        map[string]*Bip9SoftForkDescription `json:"bip9_softforks"`
@@ -173,6 +177,20 @@ type GetBlockTemplateResult struct {
 	RejectReasion string   `json:"reject-reason,omitempty"`
 }
 
+// GetChainTxStatsResult models the data returned from the getchaintxstats
+// command.  The window transaction count and interval are omitted for an empty
+// window, and the transaction rate when the window interval is not positive.
+type GetChainTxStatsResult struct {
+	Time                   int64    `json:"time"`
+	TxCount                uint64   `json:"txcount"`
+	WindowFinalBlockHash   string   `json:"window_final_block_hash"`
+	WindowFinalBlockHeight int32    `json:"window_final_block_height"`
+	WindowBlockCount       int32    `json:"window_block_count"`
+	WindowTxCount          *uint64  `json:"window_tx_count,omitempty"`
+	WindowInterval         *int64   `json:"window_interval,omitempty"`
+	TxRate                 *float64 `json:"txrate,omitempty"`
+}
+
 // GetMempoolEntryResult models the data returned from the getmempoolentry
 // command.
 type GetMempoolEntryResult struct {
@@ -257,6 +275,23 @@ type GetPeerInfoResult struct {
 	BanScore       int32   `json:"banscore"`
 	FeeFilter      int64   `json:"feefilter"`
 	SyncNode       bool    `json:"syncnode"`
+	BlockRelayOnly bool    `json:"blockrelayonly"`
+
+	// The rate limits are in bytes per second and the throttled durations
+	// are the total number of seconds transfers were delayed by them.
+	MaxUploadRate     int64   `json:"maxuploadrate,omitempty"`
+	MaxDownloadRate   int64   `json:"maxdownloadrate,omitempty"`
+	UploadThrottled   float64 `json:"uploadthrottled"`
+	DownloadThrottled float64 `json:"downloadthrottled"`
+}
+
+// ListBannedResult models the data returned for each ban from the listbanned
+// command.
+type ListBannedResult struct {
+	Address     string `json:"address"`
+	BannedUntil int64  `json:"banned_until"`
+	BanCreated  int64  `json:"ban_created"`
+	BanReason   string `json:"ban_reason"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -265,6 +300,7 @@ type GetPeerInfoResult struct {
 type GetRawMempoolVerboseResult struct {
 	Size             int32    `json:"size"`
 	Vsize            int32    `json:"vsize"`
+	Weight           int32    `json:"weight"`
 	Fee              float64  `json:"fee"`
 	Time             int64    `json:"time"`
 	Height           int64    `json:"height"`
@@ -295,9 +331,21 @@ type GetTxOutResult struct {
 
 // GetNetTotalsResult models the data returned from the getnettotals command.
 type GetNetTotalsResult struct {
-	TotalBytesRecv uint64 `json:"totalbytesrecv"`
-	TotalBytesSent uint64 `json:"totalbytessent"`
-	TimeMillis     int64  `json:"timemillis"`
+	TotalBytesRecv uint64             `json:"totalbytesrecv"`
+	TotalBytesSent uint64             `json:"totalbytessent"`
+	TimeMillis     int64              `json:"timemillis"`
+	UploadTarget   UploadTargetResult `json:"uploadtarget"`
+}
+
+// UploadTargetResult models the state of the upload target returned as part
+// of the getnettotals command.
+type UploadTargetResult struct {
+	Timeframe             int64  `json:"timeframe"`
+	Target                uint64 `json:"target"`
+	TargetReached         bool   `json:"target_reached"`
+	ServeHistoricalBlocks bool   `json:"serve_historical_blocks"`
+	BytesLeftInCycle      uint64 `json:"bytes_left_in_cycle"`
+	TimeLeftInCycle       int64  `json:"time_left_in_cycle"`
 }
 
 // ScriptSig models a signature script.  It is defined separately since it only
@@ -461,7 +509,10 @@ type Vout struct {
 	ScriptPubKey ScriptPubKeyResult `json:"scriptPubKey"`
 }
 
-// GetMiningInfoResult models the data from the getmininginfo command.
+// GetMiningInfoResult models the data from the getmininginfo command.  The next
+// halving fields are a btcd extension which are omitted when the subsidy never
+// decreases again.  The time of the next halving is estimated from the target
+// block spacing and in seconds since 1 Jan 1970 GMT.
 type GetMiningInfoResult struct {
 	Blocks             int64   `json:"blocks"`
 	CurrentBlockSize   uint64  `json:"currentblocksize"`
@@ -475,6 +526,9 @@ type GetMiningInfoResult struct {
 	NetworkHashPS      int64   `json:"networkhashps"`
 	PooledTx           uint64  `json:"pooledtx"`
 	TestNet            bool    `json:"testnet"`
+	NextHalvingHeight  int32   `json:"nexthalvingheight,omitempty"`
+	BlocksUntilHalving int32   `json:"blocksuntilhalving,omitempty"`
+	NextHalvingTime    int64   `json:"nexthalvingtime,omitempty"`
 }
 
 // GetWorkResult models the data from the getwork command.
@@ -506,6 +560,7 @@ type TxRawResult struct {
 	Hash          string `json:"hash,omitempty"`
 	Size          int32  `json:"size,omitempty"`
 	Vsize         int32  `json:"vsize,omitempty"`
+	Weight        int32  `json:"weight,omitempty"`
 	Version       int32  `json:"version"`
 	LockTime      uint32 `json:"locktime"`
 	Vin           []Vin  `json:"vin"`
@@ -537,6 +592,10 @@ type SearchRawTransactionsResult struct {
 // TxRawDecodeResult models the data from the decoderawtransaction command.
 type TxRawDecodeResult struct {
 	Txid     string `json:"txid"`
+	Hash     string `json:"hash"`
+	Size     int32  `json:"size"`
+	Vsize    int32  `json:"vsize"`
+	Weight   int32  `json:"weight"`
 	Version  int32  `json:"version"`
 	Locktime uint32 `json:"locktime"`
 	Vin      []Vin  `json:"vin"`
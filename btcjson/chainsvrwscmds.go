@@ -221,11 +221,243 @@ func NewRescanBlocksCmd(blockHashes []string) *RescanBlocksCmd {
 	return &RescanBlocksCmd{BlockHashes: blockHashes}
 }
 
+// RescanBlockchainCmd defines the rescanblockchain JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+type RescanBlockchainCmd struct {
+	// Descriptors is a list of output script descriptors or raw
+	// hex-encoded output scripts to scan for.
+	Descriptors []string
+	StartHeight *int32
+	StopHeight  *int32
+}
+
+// NewRescanBlockchainCmd returns a new instance which can be used to issue a
+// rescanblockchain JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func NewRescanBlockchainCmd(descriptors []string, startHeight, stopHeight *int32) *RescanBlockchainCmd {
+	return &RescanBlockchainCmd{
+		Descriptors: descriptors,
+		StartHeight: startHeight,
+		StopHeight:  stopHeight,
+	}
+}
+
+// AbortRescanCmd defines the abortrescan JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+type AbortRescanCmd struct{}
+
+// NewAbortRescanCmd returns a new instance which can be used to issue an
+// abortrescan JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func NewAbortRescanCmd() *AbortRescanCmd {
+	return &AbortRescanCmd{}
+}
+
+// NotifyChainEventsCmd defines the notifychainevents JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+type NotifyChainEventsCmd struct {
+	// LastBlock is the hash of the last block the client has processed.
+	// The stream starts at the current best block when it is nil.
+	LastBlock *string
+
+	// Sequence is the sequence number of the last event the client has
+	// processed.  The first event sent has the following sequence number.
+	Sequence      *uint64 `jsonrpcdefault:"0"`
+	IncludeBlocks *bool   `jsonrpcdefault:"false"`
+}
+
+// NewNotifyChainEventsCmd returns a new instance which can be used to issue a
+// notifychainevents JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func NewNotifyChainEventsCmd(lastBlock *string, sequence *uint64, includeBlocks *bool) *NotifyChainEventsCmd {
+	return &NotifyChainEventsCmd{
+		LastBlock:     lastBlock,
+		Sequence:      sequence,
+		IncludeBlocks: includeBlocks,
+	}
+}
+
+// AckChainEventsCmd defines the ackchainevents JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+type AckChainEventsCmd struct {
+	Sequence uint64
+}
+
+// NewAckChainEventsCmd returns a new instance which can be used to issue an
+// ackchainevents JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func NewAckChainEventsCmd(sequence uint64) *AckChainEventsCmd {
+	return &AckChainEventsCmd{Sequence: sequence}
+}
+
+// StopNotifyChainEventsCmd defines the stopnotifychainevents JSON-RPC
+// command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+type StopNotifyChainEventsCmd struct{}
+
+// NewStopNotifyChainEventsCmd returns a new instance which can be used to
+// issue a stopnotifychainevents JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func NewStopNotifyChainEventsCmd() *StopNotifyChainEventsCmd {
+	return &StopNotifyChainEventsCmd{}
+}
+
+// BlocksBatchRequest describes the blocks requested by getblocksbatch.  Either
+// the hashes of the blocks or the height of the first main chain block along
+// with the number of blocks must be provided.
+type BlocksBatchRequest struct {
+	Hashes      []string `json:"hashes,omitempty"`
+	StartHeight *int32   `json:"startheight,omitempty"`
+	Count       *int32   `json:"count,omitempty"`
+}
+
+// GetBlocksBatchCmd defines the getblocksbatch JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+type GetBlocksBatchCmd struct {
+	Request     BlocksBatchRequest
+	Compression *string `jsonrpcdefault:"\"none\""`
+}
+
+// NewGetBlocksBatchCmd returns a new instance which can be used to issue a
+// getblocksbatch JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func NewGetBlocksBatchCmd(request BlocksBatchRequest, compression *string) *GetBlocksBatchCmd {
+	return &GetBlocksBatchCmd{
+		Request:     request,
+		Compression: compression,
+	}
+}
+
+// FetchBlocksRawCmd defines the fetchblocksraw JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+type FetchBlocksRawCmd struct {
+	StartHeight int32
+	Count       int32
+	Window      *int32 `jsonrpcdefault:"16"`
+}
+
+// NewFetchBlocksRawCmd returns a new instance which can be used to issue a
+// fetchblocksraw JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func NewFetchBlocksRawCmd(startHeight, count int32, window *int32) *FetchBlocksRawCmd {
+	return &FetchBlocksRawCmd{
+		StartHeight: startHeight,
+		Count:       count,
+		Window:      window,
+	}
+}
+
+// AckFetchBlocksRawCmd defines the ackfetchblocksraw JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+type AckFetchBlocksRawCmd struct {
+	Height int32
+}
+
+// NewAckFetchBlocksRawCmd returns a new instance which can be used to issue an
+// ackfetchblocksraw JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func NewAckFetchBlocksRawCmd(height int32) *AckFetchBlocksRawCmd {
+	return &AckFetchBlocksRawCmd{Height: height}
+}
+
+// StopFetchBlocksRawCmd defines the stopfetchblocksraw JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+type StopFetchBlocksRawCmd struct{}
+
+// NewStopFetchBlocksRawCmd returns a new instance which can be used to issue a
+// stopfetchblocksraw JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func NewStopFetchBlocksRawCmd() *StopFetchBlocksRawCmd {
+	return &StopFetchBlocksRawCmd{}
+}
+
+// NotifyMempoolEventsCmd defines the notifymempoolevents JSON-RPC command.
+type NotifyMempoolEventsCmd struct {
+	FeeHistogram *bool `jsonrpcdefault:"true"`
+}
+
+// NewNotifyMempoolEventsCmd returns a new instance which can be used to issue a
+// notifymempoolevents JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewNotifyMempoolEventsCmd(feeHistogram *bool) *NotifyMempoolEventsCmd {
+	return &NotifyMempoolEventsCmd{
+		FeeHistogram: feeHistogram,
+	}
+}
+
+// StopNotifyMempoolEventsCmd defines the stopnotifymempoolevents JSON-RPC
+// command.
+type StopNotifyMempoolEventsCmd struct{}
+
+// NewStopNotifyMempoolEventsCmd returns a new instance which can be used to
+// issue a stopnotifymempoolevents JSON-RPC command.
+func NewStopNotifyMempoolEventsCmd() *StopNotifyMempoolEventsCmd {
+	return &StopNotifyMempoolEventsCmd{}
+}
+
+// NotifyReorgCmd defines the notifyreorg JSON-RPC command.
+type NotifyReorgCmd struct{}
+
+// NewNotifyReorgCmd returns a new instance which can be used to issue a
+// notifyreorg JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func NewNotifyReorgCmd() *NotifyReorgCmd {
+	return &NotifyReorgCmd{}
+}
+
+// StopNotifyReorgCmd defines the stopnotifyreorg JSON-RPC command.
+type StopNotifyReorgCmd struct{}
+
+// NewStopNotifyReorgCmd returns a new instance which can be used to issue a
+// stopnotifyreorg JSON-RPC command.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func NewStopNotifyReorgCmd() *StopNotifyReorgCmd {
+	return &StopNotifyReorgCmd{}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets.
 	flags := UFWebsocketOnly
 
 	MustRegisterCmd("authenticate", (*AuthenticateCmd)(nil), flags)
+	MustRegisterCmd("getblocksbatch", (*GetBlocksBatchCmd)(nil), flags)
+	MustRegisterCmd("fetchblocksraw", (*FetchBlocksRawCmd)(nil), flags)
+	MustRegisterCmd("ackfetchblocksraw", (*AckFetchBlocksRawCmd)(nil), flags)
+	MustRegisterCmd("stopfetchblocksraw", (*StopFetchBlocksRawCmd)(nil), flags)
 	MustRegisterCmd("loadtxfilter", (*LoadTxFilterCmd)(nil), flags)
 	MustRegisterCmd("notifyblocks", (*NotifyBlocksCmd)(nil), flags)
 	MustRegisterCmd("notifynewtransactions", (*NotifyNewTransactionsCmd)(nil), flags)
@@ -238,4 +470,13 @@ func init() {
 	MustRegisterCmd("stopnotifyreceived", (*StopNotifyReceivedCmd)(nil), flags)
 	MustRegisterCmd("rescan", (*RescanCmd)(nil), flags)
 	MustRegisterCmd("rescanblocks", (*RescanBlocksCmd)(nil), flags)
+	MustRegisterCmd("rescanblockchain", (*RescanBlockchainCmd)(nil), flags)
+	MustRegisterCmd("abortrescan", (*AbortRescanCmd)(nil), flags)
+	MustRegisterCmd("notifychainevents", (*NotifyChainEventsCmd)(nil), flags)
+	MustRegisterCmd("ackchainevents", (*AckChainEventsCmd)(nil), flags)
+	MustRegisterCmd("stopnotifychainevents", (*StopNotifyChainEventsCmd)(nil), flags)
+	MustRegisterCmd("notifymempoolevents", (*NotifyMempoolEventsCmd)(nil), flags)
+	MustRegisterCmd("stopnotifymempoolevents", (*StopNotifyMempoolEventsCmd)(nil), flags)
+	MustRegisterCmd("notifyreorg", (*NotifyReorgCmd)(nil), flags)
+	MustRegisterCmd("stopnotifyreorg", (*StopNotifyReorgCmd)(nil), flags)
 }
@@ -227,6 +227,253 @@ func TestChainSvrWsCmds(t *testing.T) {
 				BlockHashes: []string{"0000000000000000000000000000000000000000000000000000000000000123"},
 			},
 		},
+		{
+			name: "rescanblockchain",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("rescanblockchain", `["raw(deadbeef)#89f8spxm"]`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewRescanBlockchainCmd([]string{"raw(deadbeef)#89f8spxm"}, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"rescanblockchain","params":[["raw(deadbeef)#89f8spxm"]],"id":1}`,
+			unmarshalled: &btcjson.RescanBlockchainCmd{
+				Descriptors: []string{"raw(deadbeef)#89f8spxm"},
+			},
+		},
+		{
+			name: "rescanblockchain optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("rescanblockchain", `["deadbeef"]`, 100, 200)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewRescanBlockchainCmd([]string{"deadbeef"},
+					btcjson.Int32(100), btcjson.Int32(200))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"rescanblockchain","params":[["deadbeef"],100,200],"id":1}`,
+			unmarshalled: &btcjson.RescanBlockchainCmd{
+				Descriptors: []string{"deadbeef"},
+				StartHeight: btcjson.Int32(100),
+				StopHeight:  btcjson.Int32(200),
+			},
+		},
+		{
+			name: "abortrescan",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("abortrescan")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAbortRescanCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"abortrescan","params":[],"id":1}`,
+			unmarshalled: &btcjson.AbortRescanCmd{},
+		},
+		{
+			name: "notifychainevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifychainevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyChainEventsCmd(nil, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifychainevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyChainEventsCmd{
+				Sequence:      btcjson.Uint64(0),
+				IncludeBlocks: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "notifychainevents optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifychainevents", "123", 10, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyChainEventsCmd(btcjson.String("123"),
+					btcjson.Uint64(10), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifychainevents","params":["123",10,true],"id":1}`,
+			unmarshalled: &btcjson.NotifyChainEventsCmd{
+				LastBlock:     btcjson.String("123"),
+				Sequence:      btcjson.Uint64(10),
+				IncludeBlocks: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "ackchainevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("ackchainevents", 10)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAckChainEventsCmd(10)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"ackchainevents","params":[10],"id":1}`,
+			unmarshalled: &btcjson.AckChainEventsCmd{Sequence: 10},
+		},
+		{
+			name: "stopnotifychainevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifychainevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyChainEventsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifychainevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyChainEventsCmd{},
+		},
+		{
+			name: "notifymempoolevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifymempoolevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyMempoolEventsCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifymempoolevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyMempoolEventsCmd{
+				FeeHistogram: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "notifymempoolevents optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifymempoolevents", false)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyMempoolEventsCmd(btcjson.Bool(false))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifymempoolevents","params":[false],"id":1}`,
+			unmarshalled: &btcjson.NotifyMempoolEventsCmd{
+				FeeHistogram: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "stopnotifymempoolevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifymempoolevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyMempoolEventsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifymempoolevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyMempoolEventsCmd{},
+		},
+		{
+			name: "notifyreorg",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifyreorg")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyReorgCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifyreorg","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyReorgCmd{},
+		},
+		{
+			name: "stopnotifyreorg",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifyreorg")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyReorgCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifyreorg","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyReorgCmd{},
+		},
+		{
+			name: "getblocksbatch",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblocksbatch",
+					`{"startheight":100,"count":10}`)
+			},
+			staticCmd: func() interface{} {
+				request := btcjson.BlocksBatchRequest{
+					StartHeight: btcjson.Int32(100),
+					Count:       btcjson.Int32(10),
+				}
+				return btcjson.NewGetBlocksBatchCmd(request, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblocksbatch","params":[{"startheight":100,"count":10}],"id":1}`,
+			unmarshalled: &btcjson.GetBlocksBatchCmd{
+				Request: btcjson.BlocksBatchRequest{
+					StartHeight: btcjson.Int32(100),
+					Count:       btcjson.Int32(10),
+				},
+				Compression: btcjson.String("none"),
+			},
+		},
+		{
+			name: "getblocksbatch hashes",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblocksbatch",
+					`{"hashes":["123"]}`, "gzip")
+			},
+			staticCmd: func() interface{} {
+				request := btcjson.BlocksBatchRequest{
+					Hashes: []string{"123"},
+				}
+				return btcjson.NewGetBlocksBatchCmd(request,
+					btcjson.String("gzip"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblocksbatch","params":[{"hashes":["123"]},"gzip"],"id":1}`,
+			unmarshalled: &btcjson.GetBlocksBatchCmd{
+				Request: btcjson.BlocksBatchRequest{
+					Hashes: []string{"123"},
+				},
+				Compression: btcjson.String("gzip"),
+			},
+		},
+		{
+			name: "fetchblocksraw",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("fetchblocksraw", 100, 1000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewFetchBlocksRawCmd(100, 1000, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"fetchblocksraw","params":[100,1000],"id":1}`,
+			unmarshalled: &btcjson.FetchBlocksRawCmd{
+				StartHeight: 100,
+				Count:       1000,
+				Window:      btcjson.Int32(16),
+			},
+		},
+		{
+			name: "fetchblocksraw optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("fetchblocksraw", 100, 1000, 64)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewFetchBlocksRawCmd(100, 1000,
+					btcjson.Int32(64))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"fetchblocksraw","params":[100,1000,64],"id":1}`,
+			unmarshalled: &btcjson.FetchBlocksRawCmd{
+				StartHeight: 100,
+				Count:       1000,
+				Window:      btcjson.Int32(64),
+			},
+		},
+		{
+			name: "ackfetchblocksraw",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("ackfetchblocksraw", 115)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAckFetchBlocksRawCmd(115)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"ackfetchblocksraw","params":[115],"id":1}`,
+			unmarshalled: &btcjson.AckFetchBlocksRawCmd{Height: 115},
+		},
+		{
+			name: "stopfetchblocksraw",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopfetchblocksraw")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopFetchBlocksRawCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopfetchblocksraw","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopFetchBlocksRawCmd{},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -61,6 +61,16 @@ const (
 	// NOTE: Deprecated. Not used with rescanblocks command.
 	RescanProgressNtfnMethod = "rescanprogress"
 
+	// RescanBlockchainProgressNtfnMethod is the method used for
+	// notifications from the chain server that a rescanblockchain
+	// operation that is underway has made progress.
+	RescanBlockchainProgressNtfnMethod = "rescanblockchainprogress"
+
+	// ChainEventNtfnMethod is the method used for notifications from the
+	// chain server that a block has been connected to or disconnected from
+	// the chain followed by a notifychainevents stream.
+	ChainEventNtfnMethod = "chainevent"
+
 	// TxAcceptedNtfnMethod is the method used for notifications from the
 	// chain server that a transaction has been accepted into the mempool.
 	TxAcceptedNtfnMethod = "txaccepted"
@@ -75,6 +85,27 @@ const (
 	// from the chain server that inform a client that a transaction that
 	// matches the loaded filter was accepted by the mempool.
 	RelevantTxAcceptedNtfnMethod = "relevanttxaccepted"
+
+	// TxRemovedNtfnMethod is the method used for notifications from the
+	// chain server that a transaction has been removed from the mempool.
+	TxRemovedNtfnMethod = "txremoved"
+
+	// MempoolFeeHistogramNtfnMethod is the method used for the periodic
+	// notifications from the chain server of the distribution of the fee
+	// rates of the transactions in the mempool.
+	MempoolFeeHistogramNtfnMethod = "mempoolfeehistogram"
+
+	// ReorganizationNtfnMethod is the method used for notifications from
+	// the chain server that the main chain has been reorganized.
+	ReorganizationNtfnMethod = "reorganization"
+
+	// ServerShutdownNtfnMethod is the method used for notifications from
+	// the chain server that it is shutting down.
+	ServerShutdownNtfnMethod = "servershutdown"
+
+	// FetchBlocksRawDoneNtfnMethod is the method used for notifications
+	// from the chain server that a fetchblocksraw stream has ended.
+	FetchBlocksRawDoneNtfnMethod = "fetchblocksrawdone"
 )
 
 // BlockConnectedNtfn defines the blockconnected JSON-RPC notification.
@@ -245,6 +276,59 @@ func NewRescanProgressNtfn(hash string, height int32, time int64) *RescanProgres
 	}
 }
 
+// RescanBlockchainProgressNtfn defines the rescanblockchainprogress JSON-RPC
+// notification.
+type RescanBlockchainProgressNtfn struct {
+	Hash       string
+	Height     int32
+	StopHeight int32
+	Time       int64
+}
+
+// NewRescanBlockchainProgressNtfn returns a new instance which can be used to
+// issue a rescanblockchainprogress JSON-RPC notification.
+func NewRescanBlockchainProgressNtfn(hash string, height, stopHeight int32, time int64) *RescanBlockchainProgressNtfn {
+	return &RescanBlockchainProgressNtfn{
+		Hash:       hash,
+		Height:     height,
+		StopHeight: stopHeight,
+		Time:       time,
+	}
+}
+
+// These constants define the types of events sent with the chainevent
+// notification.
+const (
+	// ChainEventConnected indicates a block has been connected.
+	ChainEventConnected = "connected"
+
+	// ChainEventDisconnected indicates a block has been disconnected.
+	ChainEventDisconnected = "disconnected"
+)
+
+// ChainEventNtfn defines the chainevent JSON-RPC notification.
+type ChainEventNtfn struct {
+	Sequence uint64
+	Event    string
+	Hash     string
+	Height   int32
+	PrevHash string
+	Block    *string
+}
+
+// NewChainEventNtfn returns a new instance which can be used to issue a
+// chainevent JSON-RPC notification.
+func NewChainEventNtfn(sequence uint64, event, hash string, height int32, prevHash string, block *string) *ChainEventNtfn {
+	return &ChainEventNtfn{
+		Sequence: sequence,
+		Event:    event,
+		Hash:     hash,
+		Height:   height,
+		PrevHash: prevHash,
+		Block:    block,
+	}
+}
+
 // TxAcceptedNtfn defines the txaccepted JSON-RPC notification.
 type TxAcceptedNtfn struct {
 	TxID   string
@@ -285,6 +369,109 @@ func NewRelevantTxAcceptedNtfn(txHex string) *RelevantTxAcceptedNtfn {
 	return &RelevantTxAcceptedNtfn{Transaction: txHex}
 }
 
+// TxRemovedNtfn defines the txremoved JSON-RPC notification.
+type TxRemovedNtfn struct {
+	TxID   string
+	Reason string
+}
+
+// NewTxRemovedNtfn returns a new instance which can be used to issue a
+// txremoved JSON-RPC notification.
+func NewTxRemovedNtfn(txHash, reason string) *TxRemovedNtfn {
+	return &TxRemovedNtfn{
+		TxID:   txHash,
+		Reason: reason,
+	}
+}
+
+// FeeHistogramBucket describes the transactions in the mempool paying a fee
+// rate from FeeRate, in satoshi per kilobyte, up to the fee rate of the next
+// bucket.
+type FeeHistogramBucket struct {
+	FeeRate int64 `json:"feerate"`
+	Count   int   `json:"count"`
+	Size    int64 `json:"size"`
+}
+
+// MempoolFeeHistogramNtfn defines the mempoolfeehistogram JSON-RPC
+// notification.
+type MempoolFeeHistogramNtfn struct {
+	Time    int64
+	Buckets []FeeHistogramBucket
+}
+
+// NewMempoolFeeHistogramNtfn returns a new instance which can be used to issue
+// a mempoolfeehistogram JSON-RPC notification.
+func NewMempoolFeeHistogramNtfn(time int64, buckets []FeeHistogramBucket) *MempoolFeeHistogramNtfn {
+	return &MempoolFeeHistogramNtfn{
+		Time:    time,
+		Buckets: buckets,
+	}
+}
+
+// ReorganizationNtfn defines the reorganization JSON-RPC notification.
+type ReorganizationNtfn struct {
+	ForkHash     string
+	ForkHeight   int32
+	Disconnected []string
+	Connected    []string
+	ReturnedTxs  []string
+}
+
+// NewReorganizationNtfn returns a new instance which can be used to issue a
+// reorganization JSON-RPC notification.
+func NewReorganizationNtfn(forkHash string, forkHeight int32, disconnected,
+	connected, returnedTxs []string) *ReorganizationNtfn {
+
+	return &ReorganizationNtfn{
+		ForkHash:     forkHash,
+		ForkHeight:   forkHeight,
+		Disconnected: disconnected,
+		Connected:    connected,
+		ReturnedTxs:  returnedTxs,
+	}
+}
+
+// ServerShutdownNtfn defines the servershutdown JSON-RPC notification.  The
+// estimated downtime is in seconds, where zero means it is unknown.
+type ServerShutdownNtfn struct {
+	BestHash          string
+	BestHeight        int32
+	EstimatedDowntime int64
+}
+
+// NewServerShutdownNtfn returns a new instance which can be used to issue a
+// servershutdown JSON-RPC notification.
+func NewServerShutdownNtfn(bestHash string, bestHeight int32,
+	estimatedDowntime int64) *ServerShutdownNtfn {
+
+	return &ServerShutdownNtfn{
+		BestHash:          bestHash,
+		BestHeight:        bestHeight,
+		EstimatedDowntime: estimatedDowntime,
+	}
+}
+
+// FetchBlocksRawDoneNtfn defines the fetchblocksrawdone JSON-RPC notification.
+// The error is empty when all of the requested blocks were sent.
+type FetchBlocksRawDoneNtfn struct {
+	StartHeight int32
+	Count       int32
+	Error       string
+}
+
+// NewFetchBlocksRawDoneNtfn returns a new instance which can be used to issue a
+// fetchblocksrawdone JSON-RPC notification.
+func NewFetchBlocksRawDoneNtfn(startHeight, count int32,
+	err string) *FetchBlocksRawDoneNtfn {
+
+	return &FetchBlocksRawDoneNtfn{
+		StartHeight: startHeight,
+		Count:       count,
+		Error:       err,
+	}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets and are
 	// notifications.
@@ -298,7 +485,14 @@ func init() {
 	MustRegisterCmd(RedeemingTxNtfnMethod, (*RedeemingTxNtfn)(nil), flags)
 	MustRegisterCmd(RescanFinishedNtfnMethod, (*RescanFinishedNtfn)(nil), flags)
 	MustRegisterCmd(RescanProgressNtfnMethod, (*RescanProgressNtfn)(nil), flags)
+	MustRegisterCmd(RescanBlockchainProgressNtfnMethod, (*RescanBlockchainProgressNtfn)(nil), flags)
+	MustRegisterCmd(ChainEventNtfnMethod, (*ChainEventNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedNtfnMethod, (*TxAcceptedNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedVerboseNtfnMethod, (*TxAcceptedVerboseNtfn)(nil), flags)
 	MustRegisterCmd(RelevantTxAcceptedNtfnMethod, (*RelevantTxAcceptedNtfn)(nil), flags)
+	MustRegisterCmd(TxRemovedNtfnMethod, (*TxRemovedNtfn)(nil), flags)
+	MustRegisterCmd(MempoolFeeHistogramNtfnMethod, (*MempoolFeeHistogramNtfn)(nil), flags)
+	MustRegisterCmd(ReorganizationNtfnMethod, (*ReorganizationNtfn)(nil), flags)
+	MustRegisterCmd(ServerShutdownNtfnMethod, (*ServerShutdownNtfn)(nil), flags)
+	MustRegisterCmd(FetchBlocksRawDoneNtfnMethod, (*FetchBlocksRawDoneNtfn)(nil), flags)
 }
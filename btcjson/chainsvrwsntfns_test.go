@@ -168,6 +168,39 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Time:   12345678,
 			},
 		},
+		{
+			name: "rescanblockchainprogress",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("rescanblockchainprogress", "123", 100000, 200000, 12345678)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewRescanBlockchainProgressNtfn("123", 100000, 200000, 12345678)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"rescanblockchainprogress","params":["123",100000,200000,12345678],"id":null}`,
+			unmarshalled: &btcjson.RescanBlockchainProgressNtfn{
+				Hash:       "123",
+				Height:     100000,
+				StopHeight: 200000,
+				Time:       12345678,
+			},
+		},
+		{
+			name: "chainevent",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("chainevent", 7, "connected", "123", 100000, "456")
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewChainEventNtfn(7, btcjson.ChainEventConnected, "123", 100000, "456", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"chainevent","params":[7,"connected","123",100000,"456"],"id":null}`,
+			unmarshalled: &btcjson.ChainEventNtfn{
+				Sequence: 7,
+				Event:    btcjson.ChainEventConnected,
+				Hash:     "123",
+				Height:   100000,
+				PrevHash: "456",
+			},
+		},
 		{
 			name: "txaccepted",
 			newNtfn: func() (interface{}, error) {
@@ -225,6 +258,92 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Transaction: "001122",
 			},
 		},
+		{
+			name: "txremoved",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("txremoved", "123", "expiry")
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewTxRemovedNtfn("123", "expiry")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"txremoved","params":["123","expiry"],"id":null}`,
+			unmarshalled: &btcjson.TxRemovedNtfn{
+				TxID:   "123",
+				Reason: "expiry",
+			},
+		},
+		{
+			name: "mempoolfeehistogram",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("mempoolfeehistogram", 123456789,
+					`[{"feerate":1000,"count":2,"size":500}]`)
+			},
+			staticNtfn: func() interface{} {
+				buckets := []btcjson.FeeHistogramBucket{
+					{FeeRate: 1000, Count: 2, Size: 500},
+				}
+				return btcjson.NewMempoolFeeHistogramNtfn(123456789, buckets)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"mempoolfeehistogram","params":[123456789,[{"feerate":1000,"count":2,"size":500}]],"id":null}`,
+			unmarshalled: &btcjson.MempoolFeeHistogramNtfn{
+				Time: 123456789,
+				Buckets: []btcjson.FeeHistogramBucket{
+					{FeeRate: 1000, Count: 2, Size: 500},
+				},
+			},
+		},
+		{
+			name: "reorganization",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("reorganization", "000fork", 99,
+					`["000old"]`, `["000new1","000new2"]`,
+					`["123abc"]`)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewReorganizationNtfn("000fork", 99,
+					[]string{"000old"},
+					[]string{"000new1", "000new2"},
+					[]string{"123abc"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"reorganization","params":["000fork",99,["000old"],["000new1","000new2"],["123abc"]],"id":null}`,
+			unmarshalled: &btcjson.ReorganizationNtfn{
+				ForkHash:     "000fork",
+				ForkHeight:   99,
+				Disconnected: []string{"000old"},
+				Connected:    []string{"000new1", "000new2"},
+				ReturnedTxs:  []string{"123abc"},
+			},
+		},
+		{
+			name: "servershutdown",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("servershutdown", "123", 100000, 600)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewServerShutdownNtfn("123", 100000, 600)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"servershutdown","params":["123",100000,600],"id":null}`,
+			unmarshalled: &btcjson.ServerShutdownNtfn{
+				BestHash:          "123",
+				BestHeight:        100000,
+				EstimatedDowntime: 600,
+			},
+		},
+		{
+			name: "fetchblocksrawdone",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("fetchblocksrawdone", 100, 1000, "")
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewFetchBlocksRawDoneNtfn(100, 1000, "")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"fetchblocksrawdone","params":[100,1000,""],"id":null}`,
+			unmarshalled: &btcjson.FetchBlocksRawDoneNtfn{
+				StartHeight: 100,
+				Count:       1000,
+				Error:       "",
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -19,3 +19,50 @@ type RescannedBlock struct {
 	Hash         string   `json:"hash"`
 	Transactions []string `json:"transactions"`
 }
+
+// RescanBlockchainResult models the data returned from the rescanblockchain
+// command.
+type RescanBlockchainResult struct {
+	StartHeight  int32         `json:"start_height"`
+	StopHeight   int32         `json:"stop_height"`
+	Aborted      bool          `json:"aborted"`
+	Transactions []RescannedTx `json:"transactions"`
+}
+
+// RescannedTx describes a transaction found by the rescanblockchain command
+// that either pays to or spends an output paying to one of the scanned output
+// scripts.
+type RescannedTx struct {
+	TxID        string `json:"txid"`
+	BlockHash   string `json:"blockhash"`
+	BlockHeight int32  `json:"blockheight"`
+	Hex         string `json:"hex"`
+}
+
+// NotifyChainEventsResult models the data returned from the notifychainevents
+// command.  It describes the block the stream of chain events starts from.
+type NotifyChainEventsResult struct {
+	Hash     string `json:"hash"`
+	Height   int32  `json:"height"`
+	Sequence uint64 `json:"sequence"`
+}
+
+// FetchBlocksRawResult models the data returned from the fetchblocksraw
+// command.  The blocks are streamed as binary frames following the reply.
+type FetchBlocksRawResult struct {
+	StartHeight int32 `json:"startheight"`
+	Count       int32 `json:"count"`
+	Window      int32 `json:"window"`
+}
+
+// GetBlocksBatchResult models the data returned from the getblocksbatch
+// command.  Data holds the requested blocks, each serialized and prefixed by
+// its length as a little-endian uint32, compressed with the reported
+// compression and then base64 encoded.
+type GetBlocksBatchResult struct {
+	Count       int    `json:"count"`
+	NextHeight  *int32 `json:"nextheight,omitempty"`
+	Compression string `json:"compression"`
+	Size        int    `json:"size"`
+	Data        string `json:"data"`
+}
@@ -134,3 +134,31 @@ func TestDoubleHashFuncs(t *testing.T) {
 		}
 	}
 }
+
+// TestTaggedHash ensures the BIP0340 tagged hash function works as expected
+// including when the message is split across multiple arguments.
+func TestTaggedHash(t *testing.T) {
+	tests := []struct {
+		tag  string
+		msgs []string
+		out  string
+	}{
+		{"TapLeaf", nil, "5212c288a377d1f8164962a5a13429f9ba6a7b84e59776a52c6637df2106facb"},
+		{"BIP0340/challenge", []string{"abc"}, "770a5b7e7c304bbcc3ea107343ff951dd404312ef418db0c3b94e2ebfbb50087"},
+		{"TapTweak", []string{"abcdef"}, "948c3b60770b2e776602f6f8bd03352e102d2b69ece37d3e0a6dc1ed72f4bca6"},
+		{"TapTweak", []string{"ab", "", "cdef"}, "948c3b60770b2e776602f6f8bd03352e102d2b69ece37d3e0a6dc1ed72f4bca6"},
+	}
+
+	for _, test := range tests {
+		msgs := make([][]byte, 0, len(test.msgs))
+		for _, msg := range test.msgs {
+			msgs = append(msgs, []byte(msg))
+		}
+		h := fmt.Sprintf("%x", TaggedHash([]byte(test.tag), msgs...)[:])
+		if h != test.out {
+			t.Errorf("TaggedHash(%q, %q) = %s, want %s", test.tag,
+				test.msgs, h, test.out)
+			continue
+		}
+	}
+}
@@ -5,6 +5,9 @@
 package chaincfg
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"math"
 	"math/big"
@@ -38,8 +41,22 @@ var (
 	// simNetPowLimit is the highest proof of work value a Bitcoin block
 	// can have for the simulation test network.  It is the value 2^255 - 1.
 	simNetPowLimit = new(big.Int).Sub(new(big.Int).Lsh(bigOne, 255), bigOne)
+
+	// testNet4PowLimit is the highest proof of work value a Bitcoin block
+	// can have for the test network (version 4).  It is the value
+	// 2^224 - 1.
+	testNet4PowLimit = new(big.Int).Sub(new(big.Int).Lsh(bigOne, 224), bigOne)
+
+	// sigNetPowLimit is the highest proof of work value a Bitcoin block
+	// can have for the signet test network.  It is the value
+	// 0x0377ae * 2^208.
+	sigNetPowLimit = new(big.Int).Lsh(big.NewInt(0x0377ae), 208)
 )
 
+// bitcoinBaseSubsidy is the subsidy in satoshi of the blocks before the first
+// reduction on the default networks, which is 50 bitcoin.
+const bitcoinBaseSubsidy = 50 * 1e8
+
 // Checkpoint identifies a known good point in the block chain.  Using
 // checkpoints allows a few optimizations for old blocks during initial download
 // and also prevents forks from old blocks.
@@ -76,6 +93,25 @@ type ConsensusDeployment struct {
 	// ExpireTime is the median block time after which the attempted
 	// deployment expires.
 	ExpireTime uint64
+
+	// MinActivationHeight is the height of the first block at which the
+	// deployment may become active.  A deployment which is locked in
+	// before this height remains locked in until it is reached.  Zero
+	// means the deployment becomes active in the period following the one
+	// in which it was locked in.
+	MinActivationHeight uint32
+
+	// Threshold is the number of blocks in a miner confirmation window
+	// which must signal for the deployment in order to lock it in.  Zero
+	// means the RuleChangeActivationThreshold of the chain parameters is
+	// used.
+	Threshold uint32
+
+	// AlwaysActive indicates the deployment is active from the genesis
+	// block onwards without any voting.  This is used by networks such as
+	// signet which start out with the rule change already in effect.  The
+	// remaining fields are ignored when it is set.
+	AlwaysActive bool
 }
 
 // Constants that define the deployment offset in the deployments field of the
@@ -96,6 +132,11 @@ const (
 	// includes the deployment of BIPS 141, 142, 144, 145, 147 and 173.
 	DeploymentSegwit
 
+	// DeploymentTaproot defines the rule change deployment ID for the
+	// Taproot (+Schnorr) soft-fork package.  The taproot package includes
+	// the deployment of BIPS 340, 341 and 342.
+	DeploymentTaproot
+
 	// NOTE: DefinedDeployments must always come last since it is used to
 	// determine how many defined deployments there currently are.
 
@@ -144,8 +185,14 @@ type Params struct {
 	// coins (coinbase transactions) can be spent.
 	CoinbaseMaturity uint16
 
+	// BaseSubsidy is the subsidy in satoshi of the blocks before the first
+	// reduction.  Zero means the 50 bitcoin of the default networks is
+	// used, so parameters which do not set it keep the bitcoin subsidy.
+	BaseSubsidy int64
+
 	// SubsidyReductionInterval is the interval of blocks before the subsidy
-	// is reduced.
+	// is reduced.  The subsidy is halved at every multiple of the interval
+	// and is never reduced when it is zero.
 	SubsidyReductionInterval int32
 
 	// TargetTimespan is the desired amount of time that should elapse
@@ -175,6 +222,15 @@ type Params struct {
 	// NOTE: This only applies if ReduceMinDifficulty is true.
 	MinDiffReductionTime time.Duration
 
+	// EnforceBIP94 defines whether the network enforces the consensus
+	// rules of BIP0094 which fix the quirks of the minimum difficulty rule
+	// on test networks.  Difficulty retargets are based on the difficulty
+	// of the first block of the retarget period rather than the last one,
+	// which might have been a minimum difficulty block, and the first block
+	// of a retarget period must not have a timestamp more than ten minutes
+	// before its parent in order to prevent time warp attacks.
+	EnforceBIP94 bool
+
 	// GenerateSupported specifies whether or not CPU mining is allowed.
 	GenerateSupported bool
 
@@ -219,6 +275,11 @@ type Params struct {
 	// BIP44 coin type used in the hierarchical deterministic path for
 	// address generation.
 	HDCoinType uint32
+
+	// SignetChallenge is the script which the signet solution committed to
+	// by every block after the genesis block must satisfy as defined by
+	// BIP0325.  It is nil for networks which are not signets.
+	SignetChallenge []byte
 }
 
 // MainNetParams defines the network parameters for the main Bitcoin network.
@@ -244,6 +305,7 @@ var MainNetParams = Params{
 	BIP0065Height:            388381, // 000000000000000004c2b624ed5d7756c508d90fd0da2c7c679febfa6c4735f0
 	BIP0066Height:            363725, // 00000000000000000379eaa19dce8c9b722d46ae6a57c2f1a988119488b50931
 	CoinbaseMaturity:         100,
+	BaseSubsidy:              bitcoinBaseSubsidy,
 	SubsidyReductionInterval: 210000,
 	TargetTimespan:           time.Hour * 24 * 14, // 14 days
 	TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
@@ -296,6 +358,13 @@ var MainNetParams = Params{
 			StartTime:  1479168000, // November 15, 2016 UTC
 			ExpireTime: 1510704000, // November 15, 2017 UTC.
 		},
+		DeploymentTaproot: {
+			BitNumber:           2,
+			StartTime:           1619222400, // April 24th, 2021 UTC.
+			ExpireTime:          1628640000, // August 11th, 2021 UTC.
+			MinActivationHeight: 709632,
+			Threshold:           1815, // 90% of MinerConfirmationWindow
+		},
 	},
 
 	// Mempool parameters
@@ -339,6 +408,7 @@ var RegressionNetParams = Params{
 	BIP0034Height:            100000000, // Not active - Permit ver 1 blocks
 	BIP0065Height:            1351,      // Used by regression tests
 	BIP0066Height:            1251,      // Used by regression tests
+	BaseSubsidy:              bitcoinBaseSubsidy,
 	SubsidyReductionInterval: 150,
 	TargetTimespan:           time.Hour * 24 * 14, // 14 days
 	TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
@@ -372,6 +442,11 @@ var RegressionNetParams = Params{
 			StartTime:  0,             // Always available for vote
 			ExpireTime: math.MaxInt64, // Never expires.
 		},
+		DeploymentTaproot: {
+			BitNumber:  2,
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires.
+		},
 	},
 
 	// Mempool parameters
@@ -418,6 +493,7 @@ var TestNet3Params = Params{
 	BIP0065Height:            581885, // 00000000007f6655f22f98e72ed80d8b06dc761d5da09df0fa1dc4be4f861eb6
 	BIP0066Height:            330776, // 000000002104c8c45e99a8853285a3b592602a3ccde2b832481da85e9e4ba182
 	CoinbaseMaturity:         100,
+	BaseSubsidy:              bitcoinBaseSubsidy,
 	SubsidyReductionInterval: 210000,
 	TargetTimespan:           time.Hour * 24 * 14, // 14 days
 	TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
@@ -463,6 +539,96 @@ var TestNet3Params = Params{
 			StartTime:  1462060800, // May 1, 2016 UTC
 			ExpireTime: 1493596800, // May 1, 2017 UTC.
 		},
+		DeploymentTaproot: {
+			BitNumber:  2,
+			StartTime:  1619222400, // April 24th, 2021 UTC.
+			ExpireTime: 1628640000, // August 11th, 2021 UTC.
+		},
+	},
+
+	// Mempool parameters
+	RelayNonStdTxs: true,
+
+	// Human-readable part for Bech32 encoded segwit addresses, as defined in
+	// BIP 173.
+	Bech32HRPSegwit: "tb", // always tb for test net
+
+	// Address encoding magics
+	PubKeyHashAddrID:        0x6f, // starts with m or n
+	ScriptHashAddrID:        0xc4, // starts with 2
+	WitnessPubKeyHashAddrID: 0x03, // starts with QW
+	WitnessScriptHashAddrID: 0x28, // starts with T7n
+	PrivateKeyID:            0xef, // starts with 9 (uncompressed) or c (compressed)
+
+	// BIP32 hierarchical deterministic extended key magics
+	HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94}, // starts with tprv
+	HDPublicKeyID:  [4]byte{0x04, 0x35, 0x87, 0xcf}, // starts with tpub
+
+	// BIP44 coin type used in the hierarchical deterministic path for
+	// address generation.
+	HDCoinType: 1,
+}
+
+// TestNet4Params defines the network parameters for the test Bitcoin network
+// (version 4).  Not to be confused with the regression test network, this
+// network is sometimes simply called "testnet4".  It replaces the test network
+// (version 3) whose difficulty had become unusable due to exploitation of the
+// minimum difficulty rule, which is why it enforces the rules of BIP0094.
+var TestNet4Params = Params{
+	Name:        "testnet4",
+	Net:         wire.TestNet4,
+	DefaultPort: "48333",
+	DNSSeeds: []DNSSeed{
+		{"seed.testnet4.bitcoin.sprovoost.nl", true},
+		{"seed.testnet4.wiz.biz", true},
+	},
+
+	// Chain parameters
+	GenesisBlock:             &testNet4GenesisBlock,
+	GenesisHash:              &testNet4GenesisHash,
+	PowLimit:                 testNet4PowLimit,
+	PowLimitBits:             0x1d00ffff,
+	BIP0034Height:            1,
+	BIP0065Height:            1,
+	BIP0066Height:            1,
+	CoinbaseMaturity:         100,
+	BaseSubsidy:              bitcoinBaseSubsidy,
+	SubsidyReductionInterval: 210000,
+	TargetTimespan:           time.Hour * 24 * 14, // 14 days
+	TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
+	RetargetAdjustmentFactor: 4,                   // 25% less, 400% more
+	ReduceMinDifficulty:      true,
+	MinDiffReductionTime:     time.Minute * 20, // TargetTimePerBlock * 2
+	EnforceBIP94:             true,
+	GenerateSupported:        false,
+
+	// Checkpoints ordered from oldest to newest.
+	Checkpoints: nil,
+
+	// Consensus rule change deployments.
+	//
+	// The miner confirmation window is defined as:
+	//   target proof of work timespan / target proof of work spacing
+	RuleChangeActivationThreshold: 1512, // 75% of MinerConfirmationWindow
+	MinerConfirmationWindow:       2016,
+	Deployments: [DefinedDeployments]ConsensusDeployment{
+		DeploymentTestDummy: {
+			BitNumber:  28,
+			StartTime:  math.MaxInt64, // Never available for vote
+			ExpireTime: math.MaxInt64, // Never expires
+		},
+		DeploymentCSV: {
+			BitNumber:    0,
+			AlwaysActive: true,
+		},
+		DeploymentSegwit: {
+			BitNumber:    1,
+			AlwaysActive: true,
+		},
+		DeploymentTaproot: {
+			BitNumber:    2,
+			AlwaysActive: true,
+		},
 	},
 
 	// Mempool parameters
@@ -510,6 +676,7 @@ var SimNetParams = Params{
 	BIP0065Height:            0, // Always active on simnet
 	BIP0066Height:            0, // Always active on simnet
 	CoinbaseMaturity:         100,
+	BaseSubsidy:              bitcoinBaseSubsidy,
 	SubsidyReductionInterval: 210000,
 	TargetTimespan:           time.Hour * 24 * 14, // 14 days
 	TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
@@ -543,6 +710,11 @@ var SimNetParams = Params{
 			StartTime:  0,             // Always available for vote
 			ExpireTime: math.MaxInt64, // Never expires.
 		},
+		DeploymentTaproot: {
+			BitNumber:  2,
+			StartTime:  0,             // Always available for vote
+			ExpireTime: math.MaxInt64, // Never expires.
+		},
 	},
 
 	// Mempool parameters
@@ -568,6 +740,118 @@ var SimNetParams = Params{
 	HDCoinType: 115, // ASCII for s
 }
 
+// DefaultSignetChallenge is the block challenge of the public default signet.
+// It is a 1-of-2 bare multisig script.
+var DefaultSignetChallenge = hexDecode("512103ad5e0edad18cb1f0fc0d28a3d4f1" +
+	"f3e445640337489abb10404f2d1e086be430210359ef5021964fe22d6f8e05b2463c" +
+	"9540ce96883fe3b278760f048f5189f2e6c452ae")
+
+// DefaultSignetDNSSeeds is the list of DNS seeds for the public default signet.
+var DefaultSignetDNSSeeds = []DNSSeed{
+	{"seed.signet.bitcoin.sprovoost.nl", false},
+	{"seed.signet.achownodes.xyz", false},
+}
+
+// SigNetParams defines the network parameters for the public default signet
+// test network.
+var SigNetParams = CustomSignetParams(DefaultSignetChallenge,
+	DefaultSignetDNSSeeds)
+
+// CustomSignetParams returns the network parameters for a signet test network
+// with the passed block challenge and DNS seeds.  Signet is a test network
+// where, in addition to proof of work, every block must be signed by the
+// parties able to satisfy the block challenge as defined by BIP0325.
+//
+// The magic bytes of the network are derived from the block challenge, so
+// signets with different challenges are distinct networks.  The passed
+// challenge must not be modified after calling this function.
+func CustomSignetParams(challenge []byte, dnsSeeds []DNSSeed) Params {
+	// The network magic is the first four bytes of the double sha256 of
+	// the challenge serialized as variable length bytes.
+	var buf bytes.Buffer
+	_ = wire.WriteVarBytes(&buf, 0, challenge)
+	magic := chainhash.DoubleHashB(buf.Bytes())
+	net := wire.BitcoinNet(binary.LittleEndian.Uint32(magic[:4]))
+
+	return Params{
+		Name:        "signet",
+		Net:         net,
+		DefaultPort: "38333",
+		DNSSeeds:    dnsSeeds,
+
+		// Chain parameters
+		GenesisBlock:             &sigNetGenesisBlock,
+		GenesisHash:              &sigNetGenesisHash,
+		PowLimit:                 sigNetPowLimit,
+		PowLimitBits:             0x1e0377ae,
+		BIP0034Height:            1,
+		BIP0065Height:            1,
+		BIP0066Height:            1,
+		CoinbaseMaturity:         100,
+		BaseSubsidy:              bitcoinBaseSubsidy,
+		SubsidyReductionInterval: 210000,
+		TargetTimespan:           time.Hour * 24 * 14, // 14 days
+		TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
+		RetargetAdjustmentFactor: 4,                   // 25% less, 400% more
+		ReduceMinDifficulty:      false,
+		MinDiffReductionTime:     time.Minute * 20, // TargetTimePerBlock * 2
+		GenerateSupported:        false,
+
+		// Checkpoints ordered from oldest to newest.
+		Checkpoints: nil,
+
+		// Consensus rule change deployments.
+		//
+		// The miner confirmation window is defined as:
+		//   target proof of work timespan / target proof of work spacing
+		RuleChangeActivationThreshold: 1815, // 90% of MinerConfirmationWindow
+		MinerConfirmationWindow:       2016,
+		Deployments: [DefinedDeployments]ConsensusDeployment{
+			DeploymentTestDummy: {
+				BitNumber:  28,
+				StartTime:  math.MaxInt64, // Never available for vote
+				ExpireTime: math.MaxInt64, // Never expires
+			},
+			DeploymentCSV: {
+				BitNumber:    0,
+				AlwaysActive: true,
+			},
+			DeploymentSegwit: {
+				BitNumber:    1,
+				AlwaysActive: true,
+			},
+			DeploymentTaproot: {
+				BitNumber:    2,
+				AlwaysActive: true,
+			},
+		},
+
+		// Mempool parameters
+		RelayNonStdTxs: true,
+
+		// Human-readable part for Bech32 encoded segwit addresses, as
+		// defined in BIP 173.
+		Bech32HRPSegwit: "tb", // always tb for test net
+
+		// Address encoding magics
+		PubKeyHashAddrID:        0x6f, // starts with m or n
+		ScriptHashAddrID:        0xc4, // starts with 2
+		WitnessPubKeyHashAddrID: 0x03, // starts with QW
+		WitnessScriptHashAddrID: 0x28, // starts with T7n
+		PrivateKeyID:            0xef, // starts with 9 (uncompressed) or c (compressed)
+
+		// BIP32 hierarchical deterministic extended key magics
+		HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94}, // starts with tprv
+		HDPublicKeyID:  [4]byte{0x04, 0x35, 0x87, 0xcf}, // starts with tpub
+
+		// BIP44 coin type used in the hierarchical deterministic path for
+		// address generation.
+		HDCoinType: 1,
+
+		SignetChallenge: challenge,
+	}
+}
+
 var (
 	// ErrDuplicateNet describes an error where the parameters for a Bitcoin
 	// network could not be set due to the network already being a standard
@@ -693,10 +977,23 @@ func newHashFromStr(hexStr string) *chainhash.Hash {
 	return hash
 }
 
+// hexDecode decodes the passed hex string and returns the resulting bytes.  It
+// panics if an error occurs.  This is only used in the package variable
+// definitions with hard-coded, and therefore, known good, hex strings.
+func hexDecode(hexStr string) []byte {
+	b, err := hex.DecodeString(hexStr)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
 func init() {
 	// Register all default networks when the package is initialized.
 	mustRegister(&MainNetParams)
 	mustRegister(&TestNet3Params)
 	mustRegister(&RegressionNetParams)
 	mustRegister(&SimNetParams)
+	mustRegister(&SigNetParams)
+	mustRegister(&TestNet4Params)
 }
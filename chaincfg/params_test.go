@@ -4,7 +4,11 @@
 
 package chaincfg
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
 
 // TestInvalidHashStr ensures the newShaHashFromStr function panics when used to
 // with an invalid hash string.
@@ -33,3 +37,21 @@ func TestMustRegisterPanic(t *testing.T) {
 	// Intentionally try to register duplicate params to force a panic.
 	mustRegister(&MainNetParams)
 }
+
+// TestSigNetMagic ensures the network magic of a signet is derived from its
+// block challenge.
+func TestSigNetMagic(t *testing.T) {
+	if SigNetParams.Net != wire.SigNet {
+		t.Fatalf("unexpected default signet magic - got %v, want %v",
+			SigNetParams.Net, wire.SigNet)
+	}
+
+	// A signet with a custom challenge must be a distinct network.
+	custom := CustomSignetParams([]byte{0x51}, nil)
+	if custom.Net == wire.SigNet {
+		t.Fatal("custom signet uses the default signet magic")
+	}
+	if !custom.GenesisHash.IsEqual(SigNetParams.GenesisHash) {
+		t.Fatal("custom signet uses a different genesis block")
+	}
+}
@@ -0,0 +1,433 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// jsonUint32 is a uint32 which may be specified in a network definition either
+// as a JSON number or as a string in any base accepted by strconv.ParseUint
+// with a base of zero, such as "0xd9b4bef9".  Magic values are typically
+// written in hexadecimal, which JSON numbers do not support.
+type jsonUint32 uint32
+
+// UnmarshalJSON decodes the value from either a JSON number or string.
+func (u *jsonUint32) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		s = string(data)
+	}
+	v, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return fmt.Errorf("invalid 32-bit unsigned integer %s", data)
+	}
+	*u = jsonUint32(v)
+	return nil
+}
+
+// jsonDuration is a time.Duration which is specified in a network definition
+// as a string accepted by time.ParseDuration, such as "10m".
+type jsonDuration time.Duration
+
+// UnmarshalJSON decodes the duration from a JSON string.
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(v)
+	return nil
+}
+
+// jsonHex is a byte slice which is specified in a network definition as a hex
+// encoded string.
+type jsonHex []byte
+
+// UnmarshalJSON decodes the bytes from a hex encoded JSON string.
+func (h *jsonHex) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid hex string %s", data)
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*h = b
+	return nil
+}
+
+// genesisDefinition describes the genesis block of a network definition.  The
+// coinbase transaction of the main network genesis block is used when the
+// coinbase signature and public key scripts are not specified, which is the
+// case for all of the default test networks.
+type genesisDefinition struct {
+	Version           int32      `json:"version"`
+	Timestamp         int64      `json:"timestamp"`
+	Bits              jsonUint32 `json:"bits"`
+	Nonce             jsonUint32 `json:"nonce"`
+	CoinbaseSigScript jsonHex    `json:"coinbaseSigScript"`
+	CoinbasePkScript  jsonHex    `json:"coinbasePkScript"`
+	CoinbaseValue     int64      `json:"coinbaseValue"`
+
+	// Hash is the expected hash of the resulting genesis block.  It is
+	// optional and only used to catch mistakes in the definition.
+	Hash string `json:"hash"`
+}
+
+// deploymentDefinition describes a consensus rule change deployment of a
+// network definition.
+type deploymentDefinition struct {
+	BitNumber           uint8  `json:"bitNumber"`
+	StartTime           uint64 `json:"startTime"`
+	ExpireTime          uint64 `json:"expireTime"`
+	MinActivationHeight uint32 `json:"minActivationHeight"`
+	Threshold           uint32 `json:"threshold"`
+	AlwaysActive        bool   `json:"alwaysActive"`
+}
+
+// checkpointDefinition describes a checkpoint of a network definition.
+type checkpointDefinition struct {
+	Height int32  `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// dnsSeedDefinition describes a DNS seed of a network definition.
+type dnsSeedDefinition struct {
+	Host         string `json:"host"`
+	HasFiltering bool   `json:"hasFiltering"`
+}
+
+// networkDefinition is the JSON representation of a network definition loaded
+// by LoadParams.  See the LoadParams documentation for details.
+type networkDefinition struct {
+	Name        string              `json:"name"`
+	Net         jsonUint32          `json:"net"`
+	DefaultPort string              `json:"defaultPort"`
+	DNSSeeds    []dnsSeedDefinition `json:"dnsSeeds"`
+
+	Genesis      *genesisDefinition `json:"genesis"`
+	PowLimitBits jsonUint32         `json:"powLimitBits"`
+
+	BIP0034Height            int32        `json:"bip0034Height"`
+	BIP0065Height            int32        `json:"bip0065Height"`
+	BIP0066Height            int32        `json:"bip0066Height"`
+	CoinbaseMaturity         uint16       `json:"coinbaseMaturity"`
+	BaseSubsidy              *int64       `json:"baseSubsidy"`
+	SubsidyReductionInterval int32        `json:"subsidyReductionInterval"`
+	TargetTimespan           jsonDuration `json:"targetTimespan"`
+	TargetTimePerBlock       jsonDuration `json:"targetTimePerBlock"`
+	RetargetAdjustmentFactor int64        `json:"retargetAdjustmentFactor"`
+	ReduceMinDifficulty      bool         `json:"reduceMinDifficulty"`
+	MinDiffReductionTime     jsonDuration `json:"minDiffReductionTime"`
+	EnforceBIP94             bool         `json:"enforceBIP94"`
+	GenerateSupported        bool         `json:"generateSupported"`
+
+	Checkpoints []checkpointDefinition `json:"checkpoints"`
+
+	RuleChangeActivationThreshold uint32                          `json:"ruleChangeActivationThreshold"`
+	MinerConfirmationWindow       uint32                          `json:"minerConfirmationWindow"`
+	Deployments                   map[string]deploymentDefinition `json:"deployments"`
+
+	RelayNonStdTxs bool `json:"relayNonStdTxs"`
+
+	Bech32HRPSegwit         string  `json:"bech32HRPSegwit"`
+	PubKeyHashAddrID        byte    `json:"pubKeyHashAddrID"`
+	ScriptHashAddrID        byte    `json:"scriptHashAddrID"`
+	PrivateKeyID            byte    `json:"privateKeyID"`
+	WitnessPubKeyHashAddrID byte    `json:"witnessPubKeyHashAddrID"`
+	WitnessScriptHashAddrID byte    `json:"witnessScriptHashAddrID"`
+	HDPrivateKeyID          jsonHex `json:"hdPrivateKeyID"`
+	HDPublicKeyID           jsonHex `json:"hdPublicKeyID"`
+	HDCoinType              uint32  `json:"hdCoinType"`
+
+	SignetChallenge jsonHex `json:"signetChallenge"`
+}
+
+// deploymentNames maps the names used for the deployments of a network
+// definition to their deployment IDs.
+var deploymentNames = map[string]int{
+	"testdummy": DeploymentTestDummy,
+	"csv":       DeploymentCSV,
+	"segwit":    DeploymentSegwit,
+	"taproot":   DeploymentTaproot,
+}
+
+// compactToBig converts a compact representation of a whole number N to a big
+// integer.  It is the same as blockchain.CompactToBig which can not be used
+// here since the blockchain package depends on this one.
+func compactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	isNegative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+
+	var bn *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		bn = big.NewInt(int64(mantissa))
+	} else {
+		bn = big.NewInt(int64(mantissa))
+		bn.Lsh(bn, 8*(exponent-3))
+	}
+	if isNegative {
+		bn = bn.Neg(bn)
+	}
+	return bn
+}
+
+// block returns the genesis block described by the definition.
+func (g *genesisDefinition) block() *wire.MsgBlock {
+	coinbase := genesisCoinbaseTx.Copy()
+	if g.CoinbaseSigScript != nil || g.CoinbasePkScript != nil {
+		coinbase.TxIn[0].SignatureScript = g.CoinbaseSigScript
+		coinbase.TxOut[0].PkScript = g.CoinbasePkScript
+		coinbase.TxOut[0].Value = g.CoinbaseValue
+	}
+	return &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    g.Version,
+			MerkleRoot: coinbase.TxHash(),
+			Timestamp:  time.Unix(g.Timestamp, 0),
+			Bits:       uint32(g.Bits),
+			Nonce:      uint32(g.Nonce),
+		},
+		Transactions: []*wire.MsgTx{coinbase},
+	}
+}
+
+// LoadParams reads a JSON network definition from the passed reader and
+// returns the network parameters it describes.  This allows applications to
+// run a private network derived from Bitcoin without modifying this package.
+// The returned parameters are not registered.  See RegisterFromFile.
+//
+// The definition is a JSON object whose keys are the names of the Params
+// fields with a lowercase first letter, such as "defaultPort" and
+// "bip0034Height", with the following exceptions and encodings:
+//
+//   - "net" and "powLimitBits" are 32-bit integers which may also be given as
+//     strings, such as "0xd9b4bef9", and "powLimit" is derived from the latter
+//   - durations are strings such as "336h" or "10m"
+//   - "baseSubsidy" is the subsidy in satoshi and defaults to the 50 bitcoin
+//     of the default networks when omitted
+//   - "hdPrivateKeyID", "hdPublicKeyID", and "signetChallenge" are hex strings
+//   - "dnsSeeds" is a list of {"host", "hasFiltering"} objects and
+//     "checkpoints" is a list of {"height", "hash"} objects
+//   - "deployments" is an object keyed by "testdummy", "csv", "segwit", and
+//     "taproot" with the ConsensusDeployment fields.  Deployments which are
+//     not listed never activate
+//   - "genesis" is an object with the "version", "timestamp", "bits", and
+//     "nonce" of the genesis block header along with its optional coinbase
+//     "coinbaseSigScript", "coinbasePkScript", and "coinbaseValue".  The
+//     coinbase of the main network genesis block is used when the scripts
+//     are omitted.  An optional "hash" is checked against the resulting
+//     genesis block hash
+func LoadParams(r io.Reader) (*Params, error) {
+	var def networkDefinition
+	if err := json.NewDecoder(r).Decode(&def); err != nil {
+		return nil, fmt.Errorf("unable to decode network definition: %v",
+			err)
+	}
+
+	// Ensure the fields without sensible zero values are set and that
+	// the name does not clash with the default networks since it is
+	// commonly used to name data directories.
+	switch {
+	case def.Name == "":
+		return nil, fmt.Errorf("network definition has no name")
+	case def.Name == MainNetParams.Name ||
+		def.Name == TestNet3Params.Name ||
+		def.Name == RegressionNetParams.Name ||
+		def.Name == SimNetParams.Name ||
+		def.Name == SigNetParams.Name ||
+		def.Name == TestNet4Params.Name:
+		return nil, fmt.Errorf("network name %q is reserved for a "+
+			"default network", def.Name)
+	case def.Net == 0:
+		return nil, fmt.Errorf("network definition has no net magic")
+	case def.Genesis == nil:
+		return nil, fmt.Errorf("network definition has no genesis block")
+	case def.PowLimitBits == 0:
+		return nil, fmt.Errorf("network definition has no proof of " +
+			"work limit")
+	case def.BaseSubsidy != nil && *def.BaseSubsidy <= 0:
+		return nil, fmt.Errorf("network definition base subsidy must " +
+			"be positive")
+	case def.SubsidyReductionInterval < 0:
+		return nil, fmt.Errorf("network definition subsidy reduction " +
+			"interval must not be negative")
+	case def.TargetTimespan <= 0 || def.TargetTimePerBlock <= 0:
+		return nil, fmt.Errorf("network definition target timespan " +
+			"and time per block must be positive")
+	case def.RetargetAdjustmentFactor <= 0:
+		return nil, fmt.Errorf("network definition retarget " +
+			"adjustment factor must be positive")
+	case def.MinerConfirmationWindow == 0 ||
+		def.RuleChangeActivationThreshold > def.MinerConfirmationWindow:
+		return nil, fmt.Errorf("network definition rule change " +
+			"activation threshold must not exceed a non-zero miner " +
+			"confirmation window")
+	case def.Bech32HRPSegwit == "":
+		return nil, fmt.Errorf("network definition has no bech32 " +
+			"human-readable part")
+	case len(def.HDPrivateKeyID) != 4 || len(def.HDPublicKeyID) != 4:
+		return nil, fmt.Errorf("network definition hd key ids must " +
+			"be 4 bytes")
+	}
+	if port, err := strconv.ParseUint(def.DefaultPort, 10, 16); err != nil ||
+		port == 0 {
+
+		return nil, fmt.Errorf("invalid default port %q",
+			def.DefaultPort)
+	}
+	powLimit := compactToBig(uint32(def.PowLimitBits))
+	if powLimit.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid proof of work limit %08x",
+			uint32(def.PowLimitBits))
+	}
+
+	genesis := def.Genesis.block()
+	genesisHash := genesis.BlockHash()
+	if def.Genesis.Hash != "" {
+		wantHash, err := chainhash.NewHashFromStr(def.Genesis.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid genesis hash: %v", err)
+		}
+		if genesisHash != *wantHash {
+			return nil, fmt.Errorf("genesis block hash %v does not "+
+				"match the expected hash %v", genesisHash,
+				wantHash)
+		}
+	}
+
+	baseSubsidy := int64(bitcoinBaseSubsidy)
+	if def.BaseSubsidy != nil {
+		baseSubsidy = *def.BaseSubsidy
+	}
+
+	params := &Params{
+		Name:                          def.Name,
+		Net:                           wire.BitcoinNet(def.Net),
+		DefaultPort:                   def.DefaultPort,
+		GenesisBlock:                  genesis,
+		GenesisHash:                   &genesisHash,
+		PowLimit:                      powLimit,
+		PowLimitBits:                  uint32(def.PowLimitBits),
+		BIP0034Height:                 def.BIP0034Height,
+		BIP0065Height:                 def.BIP0065Height,
+		BIP0066Height:                 def.BIP0066Height,
+		CoinbaseMaturity:              def.CoinbaseMaturity,
+		BaseSubsidy:                   baseSubsidy,
+		SubsidyReductionInterval:      def.SubsidyReductionInterval,
+		TargetTimespan:                time.Duration(def.TargetTimespan),
+		TargetTimePerBlock:            time.Duration(def.TargetTimePerBlock),
+		RetargetAdjustmentFactor:      def.RetargetAdjustmentFactor,
+		ReduceMinDifficulty:           def.ReduceMinDifficulty,
+		MinDiffReductionTime:          time.Duration(def.MinDiffReductionTime),
+		EnforceBIP94:                  def.EnforceBIP94,
+		GenerateSupported:             def.GenerateSupported,
+		RuleChangeActivationThreshold: def.RuleChangeActivationThreshold,
+		MinerConfirmationWindow:       def.MinerConfirmationWindow,
+		RelayNonStdTxs:                def.RelayNonStdTxs,
+		Bech32HRPSegwit:               def.Bech32HRPSegwit,
+		PubKeyHashAddrID:              def.PubKeyHashAddrID,
+		ScriptHashAddrID:              def.ScriptHashAddrID,
+		PrivateKeyID:                  def.PrivateKeyID,
+		WitnessPubKeyHashAddrID:       def.WitnessPubKeyHashAddrID,
+		WitnessScriptHashAddrID:       def.WitnessScriptHashAddrID,
+		HDCoinType:                    def.HDCoinType,
+		SignetChallenge:               def.SignetChallenge,
+	}
+	copy(params.HDPrivateKeyID[:], def.HDPrivateKeyID)
+	copy(params.HDPublicKeyID[:], def.HDPublicKeyID)
+
+	for _, seed := range def.DNSSeeds {
+		params.DNSSeeds = append(params.DNSSeeds, DNSSeed{
+			Host:         seed.Host,
+			HasFiltering: seed.HasFiltering,
+		})
+	}
+
+	// Checkpoints must be ordered from oldest to newest.
+	for i, checkpoint := range def.Checkpoints {
+		hash, err := chainhash.NewHashFromStr(checkpoint.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid checkpoint hash: %v", err)
+		}
+		if i > 0 && checkpoint.Height <= def.Checkpoints[i-1].Height {
+			return nil, fmt.Errorf("checkpoint at height %d is not "+
+				"after the previous checkpoint", checkpoint.Height)
+		}
+		params.Checkpoints = append(params.Checkpoints, Checkpoint{
+			Height: checkpoint.Height,
+			Hash:   hash,
+		})
+	}
+
+	// Deployments which are not defined never become active.
+	for id := range params.Deployments {
+		params.Deployments[id] = ConsensusDeployment{
+			BitNumber:  uint8(id),
+			StartTime:  math.MaxInt64,
+			ExpireTime: math.MaxInt64,
+		}
+	}
+	for name, deployment := range def.Deployments {
+		id, ok := deploymentNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown deployment %q", name)
+		}
+		if deployment.Threshold > def.MinerConfirmationWindow {
+			return nil, fmt.Errorf("deployment %q threshold must "+
+				"not exceed the miner confirmation window", name)
+		}
+		params.Deployments[id] = ConsensusDeployment{
+			BitNumber:           deployment.BitNumber,
+			StartTime:           deployment.StartTime,
+			ExpireTime:          deployment.ExpireTime,
+			MinActivationHeight: deployment.MinActivationHeight,
+			Threshold:           deployment.Threshold,
+			AlwaysActive:        deployment.AlwaysActive,
+		}
+	}
+
+	return params, nil
+}
+
+// RegisterFromFile loads the JSON network definition in the file at the passed
+// path with LoadParams and registers the resulting network parameters in the
+// same way as Register.  This may error with ErrDuplicateNet if the network
+// magic is already registered.
+func RegisterFromFile(path string) (*Params, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	params, err := LoadParams(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := Register(params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"io/ioutil"
+	"math"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testNetworkDefinition is a network definition which uses the genesis block
+// of the regression test network.
+const testNetworkDefinition = `{
+	"name": "privnet",
+	"net": "0xfeedbeef",
+	"defaultPort": "28444",
+	"dnsSeeds": [{"host": "seed.privnet.example.com", "hasFiltering": true}],
+	"genesis": {
+		"version": 1,
+		"timestamp": 1296688602,
+		"bits": "0x207fffff",
+		"nonce": 2,
+		"hash": "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206"
+	},
+	"powLimitBits": "0x207fffff",
+	"bip0034Height": 1,
+	"coinbaseMaturity": 10,
+	"subsidyReductionInterval": 150,
+	"targetTimespan": "24h",
+	"targetTimePerBlock": "1m",
+	"retargetAdjustmentFactor": 4,
+	"reduceMinDifficulty": true,
+	"minDiffReductionTime": "2m",
+	"generateSupported": true,
+	"checkpoints": [{"height": 1, "hash": "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206"}],
+	"ruleChangeActivationThreshold": 108,
+	"minerConfirmationWindow": 144,
+	"deployments": {
+		"segwit": {"bitNumber": 1, "alwaysActive": true},
+		"csv": {"bitNumber": 0, "startTime": 0, "expireTime": 1000}
+	},
+	"relayNonStdTxs": true,
+	"bech32HRPSegwit": "pn",
+	"pubKeyHashAddrID": 56,
+	"scriptHashAddrID": 57,
+	"privateKeyID": 58,
+	"hdPrivateKeyID": "0a0b0c0d",
+	"hdPublicKeyID": "0a0b0c0e",
+	"hdCoinType": 1
+}`
+
+// TestLoadParams ensures network definitions are decoded into the expected
+// network parameters and that invalid definitions are rejected.
+func TestLoadParams(t *testing.T) {
+	params, err := LoadParams(strings.NewReader(testNetworkDefinition))
+	if err != nil {
+		t.Fatalf("LoadParams: unexpected error: %v", err)
+	}
+
+	if params.Net != wire.BitcoinNet(0xfeedbeef) {
+		t.Errorf("unexpected net %v", params.Net)
+	}
+	if *params.GenesisHash != *RegressionNetParams.GenesisHash {
+		t.Errorf("unexpected genesis hash %v", params.GenesisHash)
+	}
+	if !reflect.DeepEqual(params.GenesisBlock, RegressionNetParams.GenesisBlock) {
+		t.Errorf("unexpected genesis block %v", params.GenesisBlock)
+	}
+	// The proof of work limit is derived from its compact form, so it is
+	// lower than the limit of the regression test network.
+	wantPowLimit := new(big.Int).Lsh(big.NewInt(0x7fffff), 232)
+	if params.PowLimit.Cmp(wantPowLimit) != 0 {
+		t.Errorf("unexpected proof of work limit %064x", params.PowLimit)
+	}
+	if params.TargetTimePerBlock != time.Minute ||
+		params.MinDiffReductionTime != 2*time.Minute {
+
+		t.Errorf("unexpected durations %v %v", params.TargetTimePerBlock,
+			params.MinDiffReductionTime)
+	}
+	if params.BaseSubsidy != bitcoinBaseSubsidy {
+		t.Errorf("unexpected default base subsidy %d", params.BaseSubsidy)
+	}
+	def := strings.Replace(testNetworkDefinition, `"subsidyReductionInterval"`,
+		`"baseSubsidy": 2500000000, "subsidyReductionInterval"`, 1)
+	subsidyParams, err := LoadParams(strings.NewReader(def))
+	if err != nil {
+		t.Fatalf("LoadParams: unexpected error: %v", err)
+	}
+	if subsidyParams.BaseSubsidy != 2500000000 {
+		t.Errorf("unexpected base subsidy %d", subsidyParams.BaseSubsidy)
+	}
+	wantSeeds := []DNSSeed{{"seed.privnet.example.com", true}}
+	if !reflect.DeepEqual(params.DNSSeeds, wantSeeds) {
+		t.Errorf("unexpected DNS seeds %v", params.DNSSeeds)
+	}
+	if len(params.Checkpoints) != 1 || params.Checkpoints[0].Height != 1 {
+		t.Errorf("unexpected checkpoints %v", params.Checkpoints)
+	}
+	if params.HDPrivateKeyID != [4]byte{0x0a, 0x0b, 0x0c, 0x0d} {
+		t.Errorf("unexpected hd private key id %x", params.HDPrivateKeyID)
+	}
+
+	wantDeployments := [DefinedDeployments]ConsensusDeployment{
+		DeploymentTestDummy: {
+			BitNumber:  DeploymentTestDummy,
+			StartTime:  math.MaxInt64,
+			ExpireTime: math.MaxInt64,
+		},
+		DeploymentCSV:    {BitNumber: 0, ExpireTime: 1000},
+		DeploymentSegwit: {BitNumber: 1, AlwaysActive: true},
+		DeploymentTaproot: {
+			BitNumber:  DeploymentTaproot,
+			StartTime:  math.MaxInt64,
+			ExpireTime: math.MaxInt64,
+		},
+	}
+	if params.Deployments != wantDeployments {
+		t.Errorf("unexpected deployments %+v", params.Deployments)
+	}
+
+	tests := []struct {
+		name    string
+		replace []string
+	}{
+		{"no name", []string{`"name": "privnet"`, `"name": ""`}},
+		{"reserved name", []string{`"name": "privnet"`, `"name": "regtest"`}},
+		{"bad magic", []string{`"0xfeedbeef"`, `"0xfeedbeefee"`}},
+		{"bad port", []string{`"28444"`, `"port"`}},
+		{"bad duration", []string{`"24h"`, `"1 day"`}},
+		{"negative subsidy", []string{`"subsidyReductionInterval"`,
+			`"baseSubsidy": -1, "subsidyReductionInterval"`}},
+		{"zero subsidy", []string{`"subsidyReductionInterval"`,
+			`"baseSubsidy": 0, "subsidyReductionInterval"`}},
+		{"negative subsidy interval", []string{
+			`"subsidyReductionInterval": 150`,
+			`"subsidyReductionInterval": -150`}},
+		{"genesis mismatch", []string{`"nonce": 2`, `"nonce": 3`}},
+		{"unknown deployment", []string{`"segwit":`, `"segwit2x":`}},
+		{"bad hd key id", []string{`"0a0b0c0e"`, `"0a0b0c"`}},
+		{"threshold above window", []string{`"minerConfirmationWindow": 144`,
+			`"minerConfirmationWindow": 100`}},
+		{"not json", []string{`{`, `[`}},
+	}
+	for _, test := range tests {
+		def := strings.Replace(testNetworkDefinition, test.replace[0],
+			test.replace[1], 1)
+		if _, err := LoadParams(strings.NewReader(def)); err == nil {
+			t.Errorf("%s: LoadParams did not return an error", test.name)
+		}
+	}
+}
+
+// TestRegisterFromFile ensures network definitions loaded from a file are
+// registered like the default networks.
+func TestRegisterFromFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "chainparams")
+	if err != nil {
+		t.Fatalf("TempFile: unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	def := strings.Replace(testNetworkDefinition, `"0xfeedbeef"`,
+		`"0xfeedbeee"`, 1)
+	if _, err := f.WriteString(def); err != nil {
+		t.Fatalf("WriteString: unexpected error: %v", err)
+	}
+	f.Close()
+
+	params, err := RegisterFromFile(f.Name())
+	if err != nil {
+		t.Fatalf("RegisterFromFile: unexpected error: %v", err)
+	}
+	if params.Name != "privnet" {
+		t.Errorf("unexpected name %q", params.Name)
+	}
+	if !IsBech32SegwitPrefix("pn1") || !IsPubKeyHashAddrID(56) {
+		t.Error("network definition encoding magics were not registered")
+	}
+	if _, err := RegisterFromFile(f.Name()); err != ErrDuplicateNet {
+		t.Errorf("RegisterFromFile: unexpected error for duplicate "+
+			"network: %v", err)
+	}
+}
@@ -73,6 +73,16 @@ func TestRegister(t *testing.T) {
 					params: &SimNetParams,
 					err:    ErrDuplicateNet,
 				},
+				{
+					name:   "duplicate signet",
+					params: &SigNetParams,
+					err:    ErrDuplicateNet,
+				},
+				{
+					name:   "duplicate testnet4",
+					params: &TestNet4Params,
+					err:    ErrDuplicateNet,
+				},
 			},
 			p2pkhMagics: []magicTest{
 				{
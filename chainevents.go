@@ -0,0 +1,273 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// maxUnackedChainEvents is the maximum number of chain events which are sent
+// to a client before it must acknowledge them with ackchainevents.
+const maxUnackedChainEvents = 64
+
+// errChainEventStreamStopped is returned when a chain event can not be sent
+// since the stream has been stopped.
+var errChainEventStreamStopped = errors.New("chain event stream stopped")
+
+// chainEventSource provides the chain state a chain event stream follows.  It
+// is satisfied by *blockchain.BlockChain.
+type chainEventSource interface {
+	BestSnapshot() *blockchain.BestState
+	MainChainHasBlock(hash *chainhash.Hash) bool
+	BlockHashByHeight(height int32) (*chainhash.Hash, error)
+	BlockHeightByHash(hash *chainhash.Hash) (int32, error)
+	FetchHeader(hash *chainhash.Hash) (wire.BlockHeader, error)
+}
+
+// chainEventStream delivers the blocks connected to and disconnected from the
+// main chain to an external consumer, such as an indexer, in the order they
+// must be applied to mirror the chain exactly.
+//
+// Rather than forwarding chain notifications, the stream keeps track of the
+// tip of the chain as seen by the consumer and, every time the chain changes,
+// sends the events needed to move that tip to the current best block.  A
+// disconnect event is always for the consumer's tip and a connect event always
+// extends it, so the consumer's view of the chain stays consistent across
+// reorganizations, restarts, and notifications racing with the catch up.
+//
+// Every event has a sequence number and at most maxUnackedChainEvents events
+// are outstanding before the consumer acknowledges them, which keeps slow
+// consumers from falling arbitrarily far behind in their send queue.
+type chainEventStream struct {
+	chain         chainEventSource
+	fetchBlock    func(hash *chainhash.Hash) (*btcutil.Block, error)
+	send          func(ntfn *btcjson.ChainEventNtfn) error
+	includeBlocks bool
+
+	mtx       sync.Mutex
+	tipHash   chainhash.Hash
+	tipHeight int32
+	lastSent  uint64
+	lastAcked uint64
+
+	// signal is notified when the chain changes or events are acknowledged.
+	signal chan struct{}
+	quit   chan struct{}
+	stop   sync.Once
+}
+
+// newChainEventStream returns a chain event stream which starts at the block
+// with the passed hash, or the current best block when it is nil, and numbers
+// events following the passed sequence number.  The block must be known, but
+// need not be part of the main chain.
+func newChainEventStream(chain chainEventSource,
+	fetchBlock func(*chainhash.Hash) (*btcutil.Block, error),
+	send func(*btcjson.ChainEventNtfn) error, startHash *chainhash.Hash,
+	sequence uint64, includeBlocks bool) (*chainEventStream, error) {
+
+	s := &chainEventStream{
+		chain:         chain,
+		fetchBlock:    fetchBlock,
+		send:          send,
+		includeBlocks: includeBlocks,
+		lastSent:      sequence,
+		lastAcked:     sequence,
+		signal:        make(chan struct{}, 1),
+		quit:          make(chan struct{}),
+	}
+	if startHash == nil {
+		best := chain.BestSnapshot()
+		s.tipHash = best.Hash
+		s.tipHeight = best.Height
+		return s, nil
+	}
+
+	// The height of a block which is not part of the main chain is found
+	// by walking back to the main chain.
+	s.tipHash = *startHash
+	hash := *startHash
+	var depth int32
+	for !chain.MainChainHasBlock(&hash) {
+		header, err := chain.FetchHeader(&hash)
+		if err != nil {
+			return nil, fmt.Errorf("block %v is not known", hash)
+		}
+		hash = header.PrevBlock
+		depth++
+	}
+	height, err := chain.BlockHeightByHash(&hash)
+	if err != nil {
+		return nil, err
+	}
+	s.tipHeight = height + depth
+	return s, nil
+}
+
+// Tip returns the hash and height of the last block the stream has moved the
+// consumer to along with the sequence number of the last event sent.
+//
+// This function is safe for concurrent access.
+func (s *chainEventStream) Tip() (chainhash.Hash, int32, uint64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.tipHash, s.tipHeight, s.lastSent
+}
+
+// Notify wakes the stream up to send any events needed to catch up with the
+// chain.  It must be called whenever the chain changes.
+//
+// This function is safe for concurrent access.
+func (s *chainEventStream) Notify() {
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Ack acknowledges that the consumer has processed all events up to and
+// including the passed sequence number.  Acknowledgements for events which
+// have not been sent are rejected, while stale acknowledgements are ignored.
+//
+// This function is safe for concurrent access.
+func (s *chainEventStream) Ack(sequence uint64) error {
+	s.mtx.Lock()
+	if sequence > s.lastSent {
+		s.mtx.Unlock()
+		return fmt.Errorf("event %d has not been sent", sequence)
+	}
+	if sequence > s.lastAcked {
+		s.lastAcked = sequence
+	}
+	s.mtx.Unlock()
+
+	s.Notify()
+	return nil
+}
+
+// Stop stops the stream.  No more events are sent once it returns.
+//
+// This function is safe for concurrent access.
+func (s *chainEventStream) Stop() {
+	s.stop.Do(func() {
+		close(s.quit)
+	})
+}
+
+// nextEvent returns the type of the next event needed to move the consumer's
+// tip towards the best block along with the block it is for.  A nil block is
+// returned when the consumer is caught up.  This is also the case when the
+// chain changes while the event is determined, in which case the stream is
+// notified again once the change is complete.
+//
+// This function MUST be called with the stream lock held.
+func (s *chainEventStream) nextEvent() (string, *btcutil.Block, error) {
+	// Blocks which are no longer part of the main chain are disconnected
+	// first.
+	if !s.chain.MainChainHasBlock(&s.tipHash) {
+		block, err := s.fetchBlock(&s.tipHash)
+		if err != nil {
+			return "", nil, err
+		}
+		return btcjson.ChainEventDisconnected, block, nil
+	}
+
+	best := s.chain.BestSnapshot()
+	if s.tipHeight >= best.Height {
+		return "", nil, nil
+	}
+	hash, err := s.chain.BlockHashByHeight(s.tipHeight + 1)
+	if err != nil {
+		return "", nil, nil
+	}
+	block, err := s.fetchBlock(hash)
+	if err != nil {
+		return "", nil, err
+	}
+	if block.MsgBlock().Header.PrevBlock != s.tipHash {
+		return "", nil, nil
+	}
+	return btcjson.ChainEventConnected, block, nil
+}
+
+// sendEvents sends events until the consumer is caught up with the chain or
+// too many events are awaiting acknowledgement.
+func (s *chainEventStream) sendEvents() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for s.lastSent-s.lastAcked < maxUnackedChainEvents {
+		select {
+		case <-s.quit:
+			return errChainEventStreamStopped
+		default:
+		}
+
+		event, block, err := s.nextEvent()
+		if err != nil || block == nil {
+			return err
+		}
+
+		// Determine the height of the block and the consumer's tip
+		// after applying the event.
+		header := &block.MsgBlock().Header
+		height := s.tipHeight + 1
+		tipHash, tipHeight := *block.Hash(), height
+		if event == btcjson.ChainEventDisconnected {
+			height = s.tipHeight
+			tipHash, tipHeight = header.PrevBlock, s.tipHeight-1
+		}
+
+		var blockHex *string
+		if s.includeBlocks {
+			blockBytes, err := block.Bytes()
+			if err != nil {
+				return err
+			}
+			str := hex.EncodeToString(blockBytes)
+			blockHex = &str
+		}
+		ntfn := btcjson.NewChainEventNtfn(s.lastSent+1, event,
+			block.Hash().String(), height, header.PrevBlock.String(),
+			blockHex)
+		if err := s.send(ntfn); err != nil {
+			return err
+		}
+		s.lastSent = ntfn.Sequence
+		s.tipHash = tipHash
+		s.tipHeight = tipHeight
+	}
+	return nil
+}
+
+// Run sends events to the consumer until the stream is stopped or the passed
+// quit channel is closed.  It must be run as a goroutine.
+func (s *chainEventStream) Run(quit <-chan struct{}) {
+	for {
+		err := s.sendEvents()
+		if err == errChainEventStreamStopped || err == ErrClientQuit {
+			return
+		}
+		if err != nil {
+			rpcsLog.Errorf("Unable to send chain event: %v", err)
+		}
+
+		select {
+		case <-s.signal:
+		case <-s.quit:
+			return
+		case <-quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// fakeEventChain is a chain event source backed by a set of known blocks and
+// the hashes of the main chain indexed by height.
+type fakeEventChain struct {
+	blocks map[chainhash.Hash]*wire.MsgBlock
+	main   []chainhash.Hash
+}
+
+// extend adds a block on top of the passed block and returns its hash.  The
+// nonce distinguishes blocks with the same parent.
+func (c *fakeEventChain) extend(prev chainhash.Hash, nonce uint32) chainhash.Hash {
+	block := &wire.MsgBlock{Header: wire.BlockHeader{
+		PrevBlock: prev,
+		Nonce:     nonce,
+	}}
+	hash := block.BlockHash()
+	c.blocks[hash] = block
+	return hash
+}
+
+// setMain makes the chain ending with the passed block the main chain.
+func (c *fakeEventChain) setMain(tip chainhash.Hash) {
+	var hashes []chainhash.Hash
+	for hash := tip; ; {
+		hashes = append([]chainhash.Hash{hash}, hashes...)
+		block := c.blocks[hash]
+		if block.Header.PrevBlock == (chainhash.Hash{}) {
+			break
+		}
+		hash = block.Header.PrevBlock
+	}
+	c.main = hashes
+}
+
+func (c *fakeEventChain) BestSnapshot() *blockchain.BestState {
+	height := int32(len(c.main) - 1)
+	return &blockchain.BestState{Hash: c.main[height], Height: height}
+}
+
+func (c *fakeEventChain) MainChainHasBlock(hash *chainhash.Hash) bool {
+	_, err := c.BlockHeightByHash(hash)
+	return err == nil
+}
+
+func (c *fakeEventChain) BlockHashByHeight(height int32) (*chainhash.Hash, error) {
+	if height < 0 || int(height) >= len(c.main) {
+		return nil, errors.New("no block at height")
+	}
+	hash := c.main[height]
+	return &hash, nil
+}
+
+func (c *fakeEventChain) BlockHeightByHash(hash *chainhash.Hash) (int32, error) {
+	for height := range c.main {
+		if c.main[height] == *hash {
+			return int32(height), nil
+		}
+	}
+	return 0, errors.New("block not in main chain")
+}
+
+func (c *fakeEventChain) FetchHeader(hash *chainhash.Hash) (wire.BlockHeader, error) {
+	block, ok := c.blocks[*hash]
+	if !ok {
+		return wire.BlockHeader{}, errors.New("block not found")
+	}
+	return block.Header, nil
+}
+
+func (c *fakeEventChain) fetchBlock(hash *chainhash.Hash) (*btcutil.Block, error) {
+	block, ok := c.blocks[*hash]
+	if !ok {
+		return nil, errors.New("block not found")
+	}
+	return btcutil.NewBlock(block), nil
+}
+
+// TestChainEventStream ensures chain event streams catch up with the chain,
+// follow reorganizations, and respect the acknowledgement window.
+func TestChainEventStream(t *testing.T) {
+	t.Parallel()
+
+	chain := &fakeEventChain{blocks: make(map[chainhash.Hash]*wire.MsgBlock)}
+	genesis := chain.extend(chainhash.Hash{}, 0)
+	a1 := chain.extend(genesis, 0)
+	a2 := chain.extend(a1, 0)
+	a3 := chain.extend(a2, 0)
+	chain.setMain(a3)
+
+	var sent []*btcjson.ChainEventNtfn
+	send := func(ntfn *btcjson.ChainEventNtfn) error {
+		sent = append(sent, ntfn)
+		return nil
+	}
+	stream, err := newChainEventStream(chain, chain.fetchBlock, send,
+		&genesis, 10, false)
+	if err != nil {
+		t.Fatalf("newChainEventStream: unexpected error: %v", err)
+	}
+
+	// checkEvents ensures the events sent since the last call match the
+	// expected events.
+	type event struct {
+		event  string
+		hash   chainhash.Hash
+		height int32
+	}
+	nextSeq := uint64(11)
+	checkEvents := func(desc string, want []event) {
+		if err := stream.sendEvents(); err != nil {
+			t.Fatalf("%s: sendEvents: unexpected error: %v", desc, err)
+		}
+		if len(sent) != len(want) {
+			t.Fatalf("%s: got %d events, want %d", desc, len(sent),
+				len(want))
+		}
+		for i, ntfn := range sent {
+			if ntfn.Sequence != nextSeq || ntfn.Event != want[i].event ||
+				ntfn.Hash != want[i].hash.String() ||
+				ntfn.Height != want[i].height {
+
+				t.Fatalf("%s: event %d: got %+v, want %+v (seq %d)",
+					desc, i, ntfn, want[i], nextSeq)
+			}
+			nextSeq++
+		}
+		sent = nil
+	}
+
+	connected, disconnected := btcjson.ChainEventConnected,
+		btcjson.ChainEventDisconnected
+	checkEvents("catch up", []event{
+		{connected, a1, 1},
+		{connected, a2, 2},
+		{connected, a3, 3},
+	})
+	checkEvents("caught up", nil)
+
+	// Reorganize to a longer chain forking after a1.
+	b2 := chain.extend(a1, 1)
+	b3 := chain.extend(b2, 1)
+	b4 := chain.extend(b3, 1)
+	chain.setMain(b4)
+	checkEvents("reorg", []event{
+		{disconnected, a3, 3},
+		{disconnected, a2, 2},
+		{connected, b2, 2},
+		{connected, b3, 3},
+		{connected, b4, 4},
+	})
+	if hash, height, seq := stream.Tip(); hash != b4 || height != 4 ||
+		seq != 18 {
+
+		t.Fatalf("Tip: got %v, %d, %d", hash, height, seq)
+	}
+
+	// Acknowledging events which were not sent must fail.
+	if err := stream.Ack(19); err == nil {
+		t.Fatal("Ack: did not return an error for unsent event")
+	}
+	if err := stream.Ack(18); err != nil {
+		t.Fatalf("Ack: unexpected error: %v", err)
+	}
+
+	// A stream may start from a block which is no longer in the main chain.
+	stream, err = newChainEventStream(chain, chain.fetchBlock, send, &a3,
+		0, false)
+	if err != nil {
+		t.Fatalf("newChainEventStream: unexpected error: %v", err)
+	}
+	if hash, height, _ := stream.Tip(); hash != a3 || height != 3 {
+		t.Fatalf("Tip: got %v, %d for side chain start", hash, height)
+	}
+	nextSeq = 1
+	checkEvents("side chain start", []event{
+		{disconnected, a3, 3},
+		{disconnected, a2, 2},
+		{connected, b2, 2},
+		{connected, b3, 3},
+		{connected, b4, 4},
+	})
+
+	// Unknown blocks can not be streamed from.
+	unknown := chainhash.Hash{0x01}
+	_, err = newChainEventStream(chain, chain.fetchBlock, send, &unknown,
+		0, false)
+	if err == nil {
+		t.Fatal("newChainEventStream: did not return an error for " +
+			"unknown block")
+	}
+
+	// No more than maxUnackedChainEvents are sent before they are
+	// acknowledged.
+	tip := b4
+	for i := 0; i < maxUnackedChainEvents+5; i++ {
+		tip = chain.extend(tip, 2)
+	}
+	chain.setMain(tip)
+	stream, err = newChainEventStream(chain, chain.fetchBlock, send, &b4,
+		0, true)
+	if err != nil {
+		t.Fatalf("newChainEventStream: unexpected error: %v", err)
+	}
+	if err := stream.sendEvents(); err != nil {
+		t.Fatalf("sendEvents: unexpected error: %v", err)
+	}
+	if len(sent) != maxUnackedChainEvents {
+		t.Fatalf("got %d events before acknowledgement, want %d",
+			len(sent), maxUnackedChainEvents)
+	}
+	if sent[0].Block == nil {
+		t.Fatal("event does not include the requested block")
+	}
+	sent = nil
+	if err := stream.Ack(maxUnackedChainEvents); err != nil {
+		t.Fatalf("Ack: unexpected error: %v", err)
+	}
+	if err := stream.sendEvents(); err != nil {
+		t.Fatalf("sendEvents: unexpected error: %v", err)
+	}
+	if len(sent) != 5 {
+		t.Fatalf("got %d events after acknowledgement, want 5", len(sent))
+	}
+	if hash, _, _ := stream.Tip(); hash != tip {
+		t.Fatalf("Tip: got %v, want %v", hash, tip)
+	}
+}
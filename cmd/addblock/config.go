@@ -43,6 +43,7 @@ type config struct {
 	TxIndex        bool   `long:"txindex" description:"Build a full hash-based transaction index which makes all transactions available via the getrawtransaction RPC"`
 	AddrIndex      bool   `long:"addrindex" description:"Build a full address-based transaction index which makes the searchrawtransactions RPC available"`
 	Progress       int    `short:"p" long:"progress" description:"Show a progress message each time this number of seconds have passed -- Use 0 to disable progress announcements"`
+	FullValidation bool   `long:"fullvalidation" description:"Fully validate all blocks instead of skipping most checks for blocks which lead to a checkpoint"`
 }
 
 // filesExists reports whether the named file or directory exists.
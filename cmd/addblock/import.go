@@ -86,6 +86,37 @@ func (bi *blockImporter) readBlock() ([]byte, error) {
 	return serializedBlock, nil
 }
 
+// readHeaders reads the headers of all of the blocks in the input file and
+// rewinds it afterwards.  Reading stops at the first record which can't be
+// read since readBlock reports the error once the blocks themselves are read.
+func (bi *blockImporter) readHeaders() ([]wire.BlockHeader, error) {
+	var headers []wire.BlockHeader
+	for {
+		var net, blockLen uint32
+		if err := binary.Read(bi.r, binary.LittleEndian, &net); err != nil {
+			break
+		}
+		err := binary.Read(bi.r, binary.LittleEndian, &blockLen)
+		if err != nil || blockLen < wire.MaxBlockHeaderPayload {
+			break
+		}
+		var header wire.BlockHeader
+		if err := header.Deserialize(bi.r); err != nil {
+			break
+		}
+		headers = append(headers, header)
+
+		skip := int64(blockLen - wire.MaxBlockHeaderPayload)
+		if _, err := bi.r.Seek(skip, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := bi.r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
 // processBlock potentially imports the block into the database.  It first
 // deserializes the raw block while checking for errors.  Already known blocks
 // are skipped and orphan blocks are considered errors.  Finally, it runs the
@@ -128,9 +159,14 @@ func (bi *blockImporter) processBlock(serializedBlock []byte) (bool, error) {
 	}
 
 	// Ensure the blocks follows all of the chain rules and match up to the
-	// known checkpoints.
-	isMainChain, isOrphan, err := bi.chain.ProcessBlock(block,
-		blockchain.BFFastAdd)
+	// known checkpoints.  Unless full validation is requested, the blocks
+	// whose headers link to a checkpoint are only checked against the
+	// checkpoints since they establish the validity of those blocks.
+	flags := blockchain.BFCheckpointFastAdd
+	if cfg.FullValidation {
+		flags = blockchain.BFNone
+	}
+	isMainChain, isOrphan, err := bi.chain.ProcessBlock(block, flags)
 	if err != nil {
 		return false, err
 	}
@@ -333,6 +369,7 @@ func newBlockImporter(db database.DB, r io.ReadSeeker) (*blockImporter, error) {
 	chain, err := blockchain.New(&blockchain.Config{
 		DB:           db,
 		ChainParams:  activeNetParams,
+		Checkpoints:  activeNetParams.Checkpoints,
 		TimeSource:   blockchain.NewMedianTime(),
 		IndexManager: indexManager,
 	})
@@ -340,7 +377,7 @@ func newBlockImporter(db database.DB, r io.ReadSeeker) (*blockImporter, error) {
 		return nil, err
 	}
 
-	return &blockImporter{
+	bi := &blockImporter{
 		db:           db,
 		r:            r,
 		processQueue: make(chan []byte, 2),
@@ -349,5 +386,18 @@ func newBlockImporter(db database.DB, r io.ReadSeeker) (*blockImporter, error) {
 		quit:         make(chan struct{}),
 		chain:        chain,
 		lastLogTime:  time.Now(),
-	}, nil
+	}
+
+	// Find the blocks in the file which lead to a checkpoint, so they can
+	// skip most of the checks, unless full validation is requested.
+	if !cfg.FullValidation {
+		headers, err := bi.readHeaders()
+		if err != nil {
+			return nil, err
+		}
+		n := chain.AddCheckpointHeaders(headers)
+		log.Infof("Found %d blocks leading to a checkpoint", n)
+	}
+
+	return bi, nil
 }
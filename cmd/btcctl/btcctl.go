@@ -2,8 +2,6 @@ package main
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -56,6 +54,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Expand the command alias when one was specified.  The arguments
+	// following the alias are appended to those it expands to.
+	if expansion, ok := cfg.aliases[args[0]]; ok {
+		args = append(append([]string(nil), expansion...), args[1:]...)
+	}
+
 	// Ensure the specified method identifies a valid registered command and
 	// is one of the usable types.
 	method := args[0]
@@ -133,35 +137,34 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Send the JSON-RPC request to the server using the user-specified
-	// connection configuration.
-	result, err := sendPostRequest(marshalledJSON, cfg)
+	// Create the formatter for the result according to the user-specified
+	// format.
+	format, err := newResultFormatter(cfg.Format)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintf(os.Stderr, "Invalid format: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Choose how to display the result based on its type.
-	strResult := string(result)
-	if strings.HasPrefix(strResult, "{") || strings.HasPrefix(strResult, "[") {
-		var dst bytes.Buffer
-		if err := json.Indent(&dst, result, "", "  "); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to format result: %v",
-				err)
-			os.Exit(1)
+	// Send the JSON-RPC request to the server using the user-specified
+	// connection configuration and format the result for display.
+	run := func() (string, error) {
+		result, err := sendPostRequest(marshalledJSON, cfg)
+		if err != nil {
+			return "", err
 		}
-		fmt.Println(dst.String())
+		return format(result)
+	}
 
-	} else if strings.HasPrefix(strResult, `"`) {
-		var str string
-		if err := json.Unmarshal(result, &str); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to unmarshal result: %v",
-				err)
-			os.Exit(1)
-		}
-		fmt.Println(str)
+	// Keep running the command and display the changes to the result when
+	// requested.
+	if cfg.Watch > 0 {
+		watch(cfg.Watch, strings.Join(args, " "), run)
+	}
 
-	} else if strResult != "null" {
-		fmt.Println(strResult)
+	output, err := run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+	fmt.Print(output)
 }
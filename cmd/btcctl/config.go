@@ -5,6 +5,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -12,6 +13,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcutil"
@@ -92,26 +95,102 @@ func listCommands() {
 //
 // See loadConfig for details on the configuration load process.
 type config struct {
-	ShowVersion   bool   `short:"V" long:"version" description:"Display version information and exit"`
-	ListCommands  bool   `short:"l" long:"listcommands" description:"List all of the supported commands and exit"`
-	ConfigFile    string `short:"C" long:"configfile" description:"Path to configuration file"`
-	RPCUser       string `short:"u" long:"rpcuser" description:"RPC username"`
-	RPCPassword   string `short:"P" long:"rpcpass" default-mask:"-" description:"RPC password"`
-	RPCServer     string `short:"s" long:"rpcserver" description:"RPC server to connect to"`
-	RPCCert       string `short:"c" long:"rpccert" description:"RPC server certificate chain for validation"`
-	NoTLS         bool   `long:"notls" description:"Disable TLS"`
-	Proxy         string `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
-	ProxyUser     string `long:"proxyuser" description:"Username for proxy server"`
-	ProxyPass     string `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
-	TestNet3      bool   `long:"testnet" description:"Connect to testnet"`
-	SimNet        bool   `long:"simnet" description:"Connect to the simulation test network"`
-	TLSSkipVerify bool   `long:"skipverify" description:"Do not verify tls certificates (not recommended!)"`
-	Wallet        bool   `long:"wallet" description:"Connect to wallet"`
+	ShowVersion   bool          `short:"V" long:"version" description:"Display version information and exit"`
+	ListCommands  bool          `short:"l" long:"listcommands" description:"List all of the supported commands and exit"`
+	ConfigFile    string        `short:"C" long:"configfile" description:"Path to configuration file"`
+	RPCUser       string        `short:"u" long:"rpcuser" description:"RPC username"`
+	RPCPassword   string        `short:"P" long:"rpcpass" default-mask:"-" description:"RPC password"`
+	RPCServer     string        `short:"s" long:"rpcserver" description:"RPC server to connect to"`
+	RPCCert       string        `short:"c" long:"rpccert" description:"RPC server certificate chain for validation"`
+	NoTLS         bool          `long:"notls" description:"Disable TLS"`
+	Proxy         string        `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
+	ProxyUser     string        `long:"proxyuser" description:"Username for proxy server"`
+	ProxyPass     string        `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
+	TestNet3      bool          `long:"testnet" description:"Connect to testnet"`
+	TestNet4      bool          `long:"testnet4" description:"Connect to testnet4"`
+	SimNet        bool          `long:"simnet" description:"Connect to the simulation test network"`
+	SigNet        bool          `long:"signet" description:"Connect to signet"`
+	TLSSkipVerify bool          `long:"skipverify" description:"Do not verify tls certificates (not recommended!)"`
+	Wallet        bool          `long:"wallet" description:"Connect to wallet"`
+	Aliases       []string      `long:"alias" description:"Define a command alias in the form name=command [args...] -- Arguments given after the alias are appended, and arguments containing spaces may be quoted (may be specified multiple times)"`
+	Format        string        `short:"f" long:"format" description:"Display the result through a Go template (e.g. '{{.blocks}}') or only the parts selected by a JSONPath expression starting with $ (e.g. '$.peers[*].addr')"`
+	Watch         time.Duration `short:"w" long:"watch" description:"Run the command repeatedly at the given interval and display the changes to the result (e.g. 10s)"`
+
+	// aliases maps the names of the aliases defined with the alias option
+	// to the arguments they expand to.
+	aliases map[string][]string
+}
+
+// splitAliasArgs splits the passed alias definition into arguments separated by
+// whitespace.  Arguments may be enclosed in single or double quotes to include
+// whitespace.
+func splitAliasArgs(def string) ([]string, error) {
+	var args []string
+	var arg bytes.Buffer
+	var quote rune
+	inArg := false
+	for _, r := range def {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", def)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// parseAliases parses the passed alias definitions of the form
+// name=command [args...] into a map of the names to their arguments.  Aliases
+// may not shadow registered commands.
+func parseAliases(defs []string) (map[string][]string, error) {
+	aliases := make(map[string][]string, len(defs))
+	for _, def := range defs {
+		parts := strings.SplitN(def, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, fmt.Errorf("alias %q is not of the form "+
+				"name=command [args...]", def)
+		}
+		if _, err := btcjson.MethodUsageFlags(name); err == nil {
+			return nil, fmt.Errorf("alias %q shadows the command "+
+				"of the same name", name)
+		}
+		args, err := splitAliasArgs(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("alias %q: %v", name, err)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("alias %q has no command", name)
+		}
+		aliases[name] = args
+	}
+	return aliases, nil
 }
 
 // normalizeAddress returns addr with the passed default port appended if
 // there is not already a port specified.
-func normalizeAddress(addr string, useTestNet3, useSimNet, useWallet bool) string {
+func normalizeAddress(addr string, useTestNet3, useTestNet4, useSimNet,
+	useSigNet, useWallet bool) string {
+
 	_, _, err := net.SplitHostPort(addr)
 	if err != nil {
 		var defaultPort string
@@ -122,12 +201,22 @@ func normalizeAddress(addr string, useTestNet3, useSimNet, useWallet bool) strin
 			} else {
 				defaultPort = "18334"
 			}
+		case useTestNet4:
+			if useWallet {
+				defaultPort = "48332"
+			} else {
+				defaultPort = "48334"
+			}
 		case useSimNet:
 			if useWallet {
 				defaultPort = "18554"
 			} else {
 				defaultPort = "18556"
 			}
+		case useSigNet:
+			// The wallet and btcd share the same RPC port on
+			// signet.
+			defaultPort = "38332"
 		default:
 			if useWallet {
 				defaultPort = "8332"
@@ -251,17 +340,40 @@ func loadConfig() (*config, []string, error) {
 	if cfg.TestNet3 {
 		numNets++
 	}
+	if cfg.TestNet4 {
+		numNets++
+	}
 	if cfg.SimNet {
 		numNets++
 	}
+	if cfg.SigNet {
+		numNets++
+	}
 	if numNets > 1 {
-		str := "%s: The testnet and simnet params can't be used " +
-			"together -- choose one of the two"
+		str := "%s: The testnet, testnet4, simnet, and signet params " +
+			"can't be used together -- choose one of the four"
 		err := fmt.Errorf(str, "loadConfig")
 		fmt.Fprintln(os.Stderr, err)
 		return nil, nil, err
 	}
 
+	// Parse the command aliases.
+	cfg.aliases, err = parseAliases(cfg.Aliases)
+	if err != nil {
+		err := fmt.Errorf("%s: %v", "loadConfig", err)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+
+	// The watch interval may not be negative.
+	if cfg.Watch < 0 {
+		str := "%s: The watch interval may not be negative -- " +
+			"parsed [%v]"
+		err := fmt.Errorf(str, "loadConfig", cfg.Watch)
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
+
 	// Override the RPC certificate if the --wallet flag was specified and
 	// the user did not specify one.
 	if cfg.Wallet && cfg.RPCCert == defaultRPCCertFile {
@@ -274,7 +386,7 @@ func loadConfig() (*config, []string, error) {
 	// Add default port to RPC server based on --testnet and --wallet flags
 	// if needed.
 	cfg.RPCServer = normalizeAddress(cfg.RPCServer, cfg.TestNet3,
-		cfg.SimNet, cfg.Wallet)
+		cfg.TestNet4, cfg.SimNet, cfg.SigNet, cfg.Wallet)
 
 	return &cfg, remainingArgs, nil
 }
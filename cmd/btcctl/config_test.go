@@ -0,0 +1,90 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestSplitAliasArgs ensures alias definitions are split into arguments on
+// whitespace outside of quotes.
+func TestSplitAliasArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		def   string
+		want  []string
+		isErr bool
+	}{
+		{def: "getbestblock", want: []string{"getbestblock"}},
+		{def: "  getblock\t 0  ", want: []string{"getblock", "0"}},
+		{
+			def: `searchrawtransactions "1 2" '3 "4"' a"b c"d`,
+			want: []string{"searchrawtransactions", "1 2", `3 "4"`,
+				"ab cd"},
+		},
+		{def: `getblock ""`, want: []string{"getblock", ""}},
+		{def: "", want: nil},
+		{def: `getblock "0`, isErr: true},
+		{def: `getblock '0`, isErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := splitAliasArgs(test.def)
+		if test.isErr {
+			if err == nil {
+				t.Errorf("splitAliasArgs(%q): expected error",
+					test.def)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitAliasArgs(%q): unexpected error: %v",
+				test.def, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitAliasArgs(%q): got %q, want %q", test.def,
+				got, test.want)
+		}
+	}
+}
+
+// TestParseAliases ensures alias definitions are parsed into the arguments
+// they expand to and invalid definitions are rejected.
+func TestParseAliases(t *testing.T) {
+	t.Parallel()
+
+	aliases, err := parseAliases([]string{
+		"tip=getbestblock",
+		" first = getblockhash 0",
+		"raw=getblock 'a b' false",
+	})
+	if err != nil {
+		t.Fatalf("parseAliases: unexpected error: %v", err)
+	}
+	want := map[string][]string{
+		"tip":   {"getbestblock"},
+		"first": {"getblockhash", "0"},
+		"raw":   {"getblock", "a b", "false"},
+	}
+	if !reflect.DeepEqual(aliases, want) {
+		t.Fatalf("parseAliases: got %q, want %q", aliases, want)
+	}
+
+	invalid := []string{
+		"getbestblock",
+		"=getbestblock",
+		"tip=",
+		"getinfo=getbestblock",
+		`tip=getblock "0`,
+	}
+	for _, def := range invalid {
+		if _, err := parseAliases([]string{def}); err == nil {
+			t.Errorf("parseAliases(%q): expected error", def)
+		}
+	}
+}
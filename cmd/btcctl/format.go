@@ -0,0 +1,258 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// jsonPathPrefix is the prefix which distinguishes JSONPath expressions from Go
+// templates in the format option.
+const jsonPathPrefix = "$"
+
+// resultFormatter formats the JSON-encoded results returned by the RPC server
+// for display.
+type resultFormatter func(result []byte) (string, error)
+
+// newResultFormatter returns a formatter for the passed format option.  Formats
+// starting with jsonPathPrefix are JSONPath expressions selecting parts of the
+// result, any other non-empty format is a Go template executed on the result,
+// and an empty format displays the whole result.
+func newResultFormatter(format string) (resultFormatter, error) {
+	if format == "" {
+		return formatResult, nil
+	}
+
+	if strings.HasPrefix(format, jsonPathPrefix) {
+		steps, err := parseJSONPath(format)
+		if err != nil {
+			return nil, err
+		}
+		return func(result []byte) (string, error) {
+			value, err := decodeResult(result)
+			if err != nil {
+				return "", err
+			}
+			var buf bytes.Buffer
+			for _, v := range selectJSONPath(steps, value) {
+				str, err := formatValue(v)
+				if err != nil {
+					return "", err
+				}
+				buf.WriteString(str)
+				buf.WriteByte('\n')
+			}
+			return buf.String(), nil
+		}, nil
+	}
+
+	funcs := template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}
+	tmpl, err := template.New("format").Funcs(funcs).Parse(format)
+	if err != nil {
+		return nil, err
+	}
+	return func(result []byte) (string, error) {
+		value, err := decodeResult(result)
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, value); err != nil {
+			return "", err
+		}
+		if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+		return buf.String(), nil
+	}, nil
+}
+
+// formatResult formats the passed result according to its type.  Objects and
+// arrays are indented, strings are unquoted, and null results are omitted.
+func formatResult(result []byte) (string, error) {
+	strResult := string(result)
+	switch {
+	case strings.HasPrefix(strResult, "{") || strings.HasPrefix(strResult, "["):
+		var dst bytes.Buffer
+		if err := json.Indent(&dst, result, "", "  "); err != nil {
+			return "", fmt.Errorf("failed to format result: %v", err)
+		}
+		return dst.String() + "\n", nil
+
+	case strings.HasPrefix(strResult, `"`):
+		var str string
+		if err := json.Unmarshal(result, &str); err != nil {
+			return "", fmt.Errorf("failed to unmarshal result: %v",
+				err)
+		}
+		return str + "\n", nil
+
+	case strResult != "null":
+		return strResult + "\n", nil
+	}
+	return "", nil
+}
+
+// decodeResult decodes the passed result into generic values.  Numbers are
+// kept as json.Number, so large amounts and heights are displayed exactly.
+func decodeResult(result []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(result))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal result: %v", err)
+	}
+	return value, nil
+}
+
+// formatValue formats a value selected from a result.  Strings and numbers are
+// displayed as is, and other values as indented JSON.
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	}
+	b, err := json.MarshalIndent(value, "", "  ")
+	return string(b), err
+}
+
+// jsonPathStep is a single step of a parsed JSONPath expression.  It selects
+// either the member with the given name of objects, the element with the given
+// index of arrays, or all members or elements when the wildcard is set.
+type jsonPathStep struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseJSONPath parses the passed JSONPath expression.  The supported subset
+// consists of the root $ followed by any number of .name, .*, ['name'], [n],
+// where a negative n counts from the end of the array, and [*] steps.
+func parseJSONPath(expr string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(expr, jsonPathPrefix) {
+		return nil, fmt.Errorf("JSONPath %q does not start with %s",
+			expr, jsonPathPrefix)
+	}
+
+	var steps []jsonPathStep
+	rest := expr[len(jsonPathPrefix):]
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			rest = rest[end:]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("JSONPath %q has an "+
+					"empty or recursive member step", expr)
+			case "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			default:
+				steps = append(steps, jsonPathStep{name: name})
+			}
+
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("JSONPath %q has an "+
+					"unterminated [", expr)
+			}
+			sel := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case sel == "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			case len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') &&
+				sel[len(sel)-1] == sel[0]:
+				steps = append(steps, jsonPathStep{
+					name: sel[1 : len(sel)-1],
+				})
+			default:
+				index, err := strconv.Atoi(sel)
+				if err != nil {
+					return nil, fmt.Errorf("JSONPath %q has "+
+						"an invalid selector [%s]", expr,
+						sel)
+				}
+				steps = append(steps, jsonPathStep{
+					index:   index,
+					isIndex: true,
+				})
+			}
+
+		default:
+			return nil, fmt.Errorf("JSONPath %q has an unexpected "+
+				"character %q", expr, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+// selectJSONPath returns the values the passed steps select from the passed
+// value.  Steps which do not apply, such as a member of an array or an index
+// out of range, select nothing.
+func selectJSONPath(steps []jsonPathStep, value interface{}) []interface{} {
+	values := []interface{}{value}
+	for _, step := range steps {
+		var next []interface{}
+		for _, v := range values {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					// Go maps are unordered, so the members
+					// are selected in the order of their
+					// names to keep the output stable.
+					names := make([]string, 0, len(v))
+					for name := range v {
+						names = append(names, name)
+					}
+					sort.Strings(names)
+					for _, name := range names {
+						next = append(next, v[name])
+					}
+				} else if member, ok := v[step.name]; ok &&
+					!step.isIndex {
+
+					next = append(next, member)
+				}
+
+			case []interface{}:
+				switch {
+				case step.wildcard:
+					next = append(next, v...)
+				case step.isIndex:
+					index := step.index
+					if index < 0 {
+						index += len(v)
+					}
+					if index >= 0 && index < len(v) {
+						next = append(next, v[index])
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
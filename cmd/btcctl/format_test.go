@@ -0,0 +1,114 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseJSONPath ensures the supported JSONPath expressions are parsed into
+// the expected steps and unsupported ones are rejected.
+func TestParseJSONPath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		expr  string
+		want  []jsonPathStep
+		isErr bool
+	}{
+		{expr: "$", want: nil},
+		{
+			expr: "$.peers[*].addr",
+			want: []jsonPathStep{
+				{name: "peers"},
+				{wildcard: true},
+				{name: "addr"},
+			},
+		},
+		{
+			expr: "$['bytes sent'][-1].*",
+			want: []jsonPathStep{
+				{name: "bytes sent"},
+				{index: -1, isIndex: true},
+				{wildcard: true},
+			},
+		},
+		{expr: "peers", isErr: true},
+		{expr: "$..addr", isErr: true},
+		{expr: "$.peers[0", isErr: true},
+		{expr: "$.peers[x]", isErr: true},
+		{expr: "$peers", isErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := parseJSONPath(test.expr)
+		if test.isErr {
+			if err == nil {
+				t.Errorf("parseJSONPath(%q): expected error",
+					test.expr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseJSONPath(%q): unexpected error: %v",
+				test.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseJSONPath(%q): got %+v, want %+v",
+				test.expr, got, test.want)
+		}
+	}
+}
+
+// TestSelectJSONPath ensures JSONPath expressions select the expected parts of
+// a result and format them for display.
+func TestSelectJSONPath(t *testing.T) {
+	t.Parallel()
+
+	result := []byte(`{"blocks":500000,"peers":[` +
+		`{"addr":"127.0.0.1:8333","id":1},` +
+		`{"addr":"[::1]:8333","id":2}],` +
+		`"version":{"minor":1,"major":0}}`)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: "$.blocks", want: "500000\n"},
+		{expr: "$.peers[*].addr", want: "127.0.0.1:8333\n[::1]:8333\n"},
+		{expr: "$.peers[-1].id", want: "2\n"},
+		{expr: "$['peers'][0]['addr']", want: "127.0.0.1:8333\n"},
+		{expr: "$.version.*", want: "0\n1\n"},
+		{expr: "$.peers[2].addr", want: ""},
+		{expr: "$.peers.addr", want: ""},
+		{expr: "$.blocks[0]", want: ""},
+		{expr: "$.missing", want: ""},
+		{
+			expr: "$.peers[1]",
+			want: "{\n  \"addr\": \"[::1]:8333\",\n  \"id\": 2\n}\n",
+		},
+	}
+
+	for _, test := range tests {
+		formatter, err := newResultFormatter(test.expr)
+		if err != nil {
+			t.Errorf("newResultFormatter(%q): unexpected error: %v",
+				test.expr, err)
+			continue
+		}
+		got, err := formatter(result)
+		if err != nil {
+			t.Errorf("format %q: unexpected error: %v", test.expr,
+				err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("format %q: got %q, want %q", test.expr, got,
+				test.want)
+		}
+	}
+}
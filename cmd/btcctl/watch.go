@@ -0,0 +1,122 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxDiffCells is the maximum size of the table diffLines builds to find the
+// longest common subsequence of the lines which differ.  Larger changes are
+// shown as all of the differing old lines removed and new lines added.
+const maxDiffCells = 1 << 20
+
+// diffLines returns the lines which differ between the passed old and new text
+// prefixed with "- " when they were removed and "+ " when they were added.
+// Lines both texts have in common, as determined by their longest common
+// subsequence, are omitted.
+func diffLines(oldText, newText string) []string {
+	oldLines := strings.Split(strings.TrimSuffix(oldText, "\n"), "\n")
+	newLines := strings.Split(strings.TrimSuffix(newText, "\n"), "\n")
+
+	// Results usually only change in a few places, so the lines at the
+	// start and end which are the same are skipped before the table is
+	// built.
+	for len(oldLines) > 0 && len(newLines) > 0 && oldLines[0] == newLines[0] {
+		oldLines, newLines = oldLines[1:], newLines[1:]
+	}
+	for len(oldLines) > 0 && len(newLines) > 0 &&
+		oldLines[len(oldLines)-1] == newLines[len(newLines)-1] {
+
+		oldLines = oldLines[:len(oldLines)-1]
+		newLines = newLines[:len(newLines)-1]
+	}
+
+	var diff []string
+	if (len(oldLines)+1)*(len(newLines)+1) > maxDiffCells {
+		for _, line := range oldLines {
+			diff = append(diff, "- "+line)
+		}
+		for _, line := range newLines {
+			diff = append(diff, "+ "+line)
+		}
+		return diff
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of the
+	// old lines starting at i and the new lines starting at j.
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			switch {
+			case oldLines[i] == newLines[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) &&
+			oldLines[i] == newLines[j]:
+			i++
+			j++
+		case i < len(oldLines) && (j == len(newLines) ||
+			lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "- "+oldLines[i])
+			i++
+		default:
+			diff = append(diff, "+ "+newLines[j])
+			j++
+		}
+	}
+	return diff
+}
+
+// watch runs the passed function every interval and prints its output the
+// first time and the lines which changed afterwards, each preceded by the time
+// and the passed title.  Errors are printed as well without stopping, so a
+// temporarily unreachable server does not end the watch.  It never returns.
+func watch(interval time.Duration, title string, run func() (string, error)) {
+	var prev string
+	var prevErr error
+	first := true
+	for {
+		now := time.Now().Format("15:04:05")
+		output, err := run()
+		switch {
+		case err != nil:
+			if prevErr == nil || err.Error() != prevErr.Error() {
+				fmt.Fprintf(os.Stderr, "[%s] %s: %v\n", now, title,
+					err)
+			}
+
+		case first:
+			fmt.Printf("[%s] %s\n%s", now, title, output)
+			prev, first = output, false
+
+		case output != prev:
+			fmt.Printf("[%s] %s\n", now, title)
+			for _, line := range diffLines(prev, output) {
+				fmt.Println(line)
+			}
+			prev = output
+		}
+		prevErr = err
+
+		time.Sleep(interval)
+	}
+}
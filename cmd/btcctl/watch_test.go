@@ -0,0 +1,91 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestDiffLines ensures the lines which differ between two outputs are
+// reported as removed and added while the common lines are omitted.
+func TestDiffLines(t *testing.T) {
+	t.Parallel()
+
+	// manyLines returns n numbered lines with the passed prefix.
+	manyLines := func(prefix string, n int) []string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("%s%d", prefix, i)
+		}
+		return lines
+	}
+	prefixed := func(prefix string, lines []string) []string {
+		result := make([]string, len(lines))
+		for i, line := range lines {
+			result[i] = prefix + line
+		}
+		return result
+	}
+	oldMany := manyLines("old", 1100)
+	newMany := manyLines("new", 1100)
+
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+		want    []string
+	}{
+		{
+			name:    "unchanged",
+			oldText: "a\nb\nc\n",
+			newText: "a\nb\nc\n",
+			want:    nil,
+		},
+		{
+			name:    "changed line",
+			oldText: "blocks 10\npeers 8\n",
+			newText: "blocks 11\npeers 8\n",
+			want:    []string{"- blocks 10", "+ blocks 11"},
+		},
+		{
+			name:    "added and removed lines",
+			oldText: "a\nb\nc\nd\n",
+			newText: "a\nc\nd\ne\n",
+			want:    []string{"- b", "+ e"},
+		},
+		{
+			name:    "changes between common lines",
+			oldText: "a\nx\nb\ny\nc\n",
+			newText: "a\nb\nz\nc\n",
+			want:    []string{"- x", "- y", "+ z"},
+		},
+		{
+			name:    "missing trailing newline",
+			oldText: "a\nb",
+			newText: "a\nb\n",
+			want:    nil,
+		},
+		{
+			name: "too large for the table",
+			oldText: "first\n" + strings.Join(oldMany, "\n") +
+				"\nlast\n",
+			newText: "first\n" + strings.Join(newMany, "\n") +
+				"\nlast\n",
+			want: append(prefixed("- ", oldMany),
+				prefixed("+ ", newMany)...),
+		},
+	}
+
+	for _, test := range tests {
+		got := diffLines(test.oldText, test.newText)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: diffLines: got %q, want %q", test.name,
+				got, test.want)
+		}
+	}
+}
@@ -8,10 +8,12 @@ import (
 	"bufio"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -27,6 +29,7 @@ import (
 	"github.com/btcsuite/btcd/database"
 	_ "github.com/btcsuite/btcd/database/ffldb"
 	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/policy"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/go-socks/socks"
 	flags "github.com/jessevdk/go-flags"
@@ -39,13 +42,19 @@ const (
 	defaultLogDirname            = "logs"
 	defaultLogFilename           = "btcd.log"
 	defaultMaxPeers              = 125
+	defaultBlockRelayPeers       = 2
 	defaultBanDuration           = time.Hour * 24
 	defaultBanThreshold          = 100
 	defaultConnectTimeout        = time.Second * 30
 	defaultMaxRPCClients         = 10
 	defaultMaxRPCWebsockets      = 25
 	defaultMaxRPCConcurrentReqs  = 20
+	defaultRPCShutdownGrace      = time.Second * 10
 	defaultDbType                = "ffldb"
+	defaultMetaBackups           = 2
+	defaultWebhookOutboxSize     = 64
+	defaultWebhookOutboxAge      = time.Hour * 24 * 7
+	minMetaBackupInterval        = time.Minute
 	defaultFreeTxRelayLimit      = 15.0
 	defaultBlockMinSize          = 0
 	defaultBlockMaxSize          = 750000
@@ -58,6 +67,8 @@ const (
 	defaultGenerate              = false
 	defaultMaxOrphanTransactions = 100
 	defaultMaxOrphanTxSize       = 100000
+	defaultMaxMempool            = 300
+	defaultMempoolExpiry         = time.Hour * 336
 	defaultSigCacheMaxSize       = 100000
 	sampleConfigFilename         = "sample-btcd.conf"
 	defaultTxIndex               = false
@@ -100,10 +111,15 @@ type config struct {
 	DisableListen        bool          `long:"nolisten" description:"Disable listening for incoming connections -- NOTE: Listening is automatically disabled if the --connect or --proxy options are used without also specifying listen interfaces via --listen"`
 	Listeners            []string      `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 8333, testnet: 18333)"`
 	MaxPeers             int           `long:"maxpeers" description:"Max number of inbound and outbound peers"`
+	BlockRelayPeers      int           `long:"blockrelaypeers" description:"Number of additional outbound block-relay-only peers, which neither relay transactions nor addresses and are reconnected to after a restart (0 to disable)"`
 	DisableBanning       bool          `long:"nobanning" description:"Disable banning of misbehaving peers"`
 	BanDuration          time.Duration `long:"banduration" description:"How long to ban misbehaving peers.  Valid time units are {s, m, h}.  Minimum 1 second"`
 	BanThreshold         uint32        `long:"banthreshold" description:"Maximum allowed ban score before disconnecting and banning misbehaving peers."`
+	BanScores            []string      `long:"banscore" description:"Override the score an offense adds to the ban score of a peer -- Format: '<offense>=<score>' with offenses {getdata, mempool, nobloom}"`
 	Whitelists           []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
+	MaxUploadTarget      uint64        `long:"maxuploadtarget" description:"Try to keep outbound traffic under the given target in MiB per 24h by refusing to serve historical blocks to peers which are not whitelisted once it is reached (0 for no limit)"`
+	MaxPeerUpload        int64         `long:"maxpeerupload" description:"Max rate in KiB/s at which blocks are sent to each peer which is not whitelisted (0 for no limit)"`
+	MaxPeerDownload      int64         `long:"maxpeerdownload" description:"Max rate in KiB/s at which data is received from each peer which is not whitelisted (0 for no limit)"`
 	RPCUser              string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
 	RPCPass              string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCLimitUser         string        `long:"rpclimituser" description:"Username for limited RPC connections"`
@@ -114,6 +130,8 @@ type config struct {
 	RPCMaxClients        int           `long:"rpcmaxclients" description:"Max number of RPC clients for standard connections"`
 	RPCMaxWebsockets     int           `long:"rpcmaxwebsockets" description:"Max number of RPC websocket connections"`
 	RPCMaxConcurrentReqs int           `long:"rpcmaxconcurrentreqs" description:"Max number of concurrent RPC requests that may be processed concurrently"`
+	RPCShutdownGrace     time.Duration `long:"rpcshutdowngrace" description:"Max amount of time given to in-flight websocket rescans to stop and report the last block they processed when shutting down"`
+	ShutdownDowntime     time.Duration `long:"shutdowndowntime" description:"Estimated downtime announced to websocket clients when shutting down, such as for a planned restart -- 0 announces an unknown downtime"`
 	RPCQuirks            bool          `long:"rpcquirks" description:"Mirror some JSON-RPC quirks of Bitcoin Core -- NOTE: Discouraged unless interoperability issues need to be worked around"`
 	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
 	DisableTLS           bool          `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
@@ -127,20 +145,48 @@ type config struct {
 	OnionProxyPass       string        `long:"onionpass" default-mask:"-" description:"Password for onion proxy server"`
 	NoOnion              bool          `long:"noonion" description:"Disable connecting to tor hidden services"`
 	TorIsolation         bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
+	TorControl           string        `long:"torcontrol" description:"Tor control port used to request new circuits when outbound connections through the proxy keep failing (eg. 127.0.0.1:9051)"`
+	TorControlPass       string        `long:"torcontrolpass" default-mask:"-" description:"Password for the Tor control port"`
 	TestNet3             bool          `long:"testnet" description:"Use the test network"`
+	TestNet4             bool          `long:"testnet4" description:"Use the test network (version 4)"`
 	RegressionTest       bool          `long:"regtest" description:"Use the regression test network"`
 	SimNet               bool          `long:"simnet" description:"Use the simulation test network"`
+	SigNet               bool          `long:"signet" description:"Use the signet test network"`
+	SigNetChallenge      string        `long:"signetchallenge" description:"Hex encoded block challenge script of a custom signet test network (default: the public signet challenge)"`
+	SigNetSeedNodes      []string      `long:"signetseednode" description:"Add a DNS seed for the signet test network -- the public signet seeds are only used when no custom challenge is given"`
+	ChainParams          string        `long:"chainparams" description:"Use the custom network defined by the given JSON network definition file"`
 	AddCheckpoints       []string      `long:"addcheckpoint" description:"Add a custom checkpoint.  Format: '<height>:<hash>'"`
 	DisableCheckpoints   bool          `long:"nocheckpoints" description:"Disable built-in checkpoints.  Don't do this unless you know what you're doing."`
 	DbType               string        `long:"dbtype" description:"Database backend to use for the Block Chain"`
+	MetaBackupInterval   time.Duration `long:"metabackupinterval" description:"Interval at which snapshots of the block database metadata are taken -- Use 0 to disable snapshots.  Valid time units are {s, m, h}.  Minimum 1 minute"`
+	MetaBackups          int           `long:"metabackups" description:"Number of block database metadata snapshots to keep"`
+	UndoDepth            int32         `long:"undodepth" description:"Prune the undo data needed to disconnect blocks for blocks deeper than the given number of blocks, which refuses deeper reorganizations -- Use 0 to keep all undo data.  Minimum 288"`
+	MaxReorgDepth        int32         `long:"maxreorgdepth" description:"Hold back reorganizations which disconnect more than the given number of blocks until they are approved with the approvereorg RPC -- Use 0 to allow reorganizations of any depth"`
+	RestoreMetadata      bool          `long:"restoremetadata" description:"Restore the block database metadata from the most recent usable snapshot on start up and reprocess the blocks stored since it was taken"`
+	AutoRecover          bool          `long:"autorecover" description:"Automatically repair the block database when corruption is detected on start up by restoring the most recent usable metadata snapshot, or by rebuilding it from the stored blocks when there is none -- Only the most recently stored block is checked for damage unless --verifyblockfiles is set"`
+	VerifyBlockFiles     bool          `long:"verifyblockfiles" description:"Verify the checksums of all stored blocks on start up rather than only the most recently stored one -- This reads the entire block database"`
+	RecoveryPeers        []string      `long:"recoverypeer" description:"Fetch the best header chain from the specified peer before reprocessing the blocks left by a block database recovery and skip the stored blocks which are on a stale fork of it"`
+	ColdBlockDir         string        `long:"coldblockdir" description:"Directory which houses the oldest block files of the block database, such as a slow or read-only network mount -- The recent block files and all new blocks are kept in the data directory"`
 	Profile              string        `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
 	CPUProfile           string        `long:"cpuprofile" description:"Write CPU profile to the specified file"`
+	RecordTrace          string        `long:"recordtrace" description:"Record the blocks and transactions received from peers, the mined blocks, and the RPC calls which change the chain or memory pool to the specified trace file -- Start recording with an empty data directory for the trace to be replayable"`
+	ReplayTrace          string        `long:"replaytrace" description:"Replay the specified trace file recorded with --recordtrace on start up without connecting to any peers -- Use an empty data directory"`
+	MetricsListen        string        `long:"metricslisten" description:"Serve Prometheus metrics at /metrics on the given interface/port (default port: 9332) -- NOTE the metrics are not authenticated"`
+	Webhooks             []string      `long:"webhook" description:"POST a JSON notification to the given http or https URL for every block connected to or disconnected from the main chain -- Notifications are queued on disk while the endpoint is unavailable"`
+	WebhookOutboxSize    int           `long:"webhookoutboxsize" description:"Max size in megabytes of the notifications queued for each webhook -- The oldest notifications are dropped when it is exceeded"`
+	WebhookOutboxAge     time.Duration `long:"webhookoutboxage" description:"Max amount of time a notification is queued for a webhook before it is dropped -- 0 disables the limit"`
 	DebugLevel           string        `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
 	Upnp                 bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
 	MinRelayTxFee        float64       `long:"minrelaytxfee" description:"The minimum transaction fee in BTC/kB to be considered a non-zero fee."`
+	DustRelayFee         float64       `long:"dustrelayfee" description:"The fee rate in BTC/kB used to determine whether a transaction output is dust -- Defaults to the minrelaytxfee"`
+	DataCarrierSize      int           `long:"datacarriersize" description:"Max number of bytes of data carried by a standard null data (OP_RETURN) output"`
+	DataCarrierMultiPush bool          `long:"datacarriermultipush" description:"Treat null data (OP_RETURN) outputs which carry their data in multiple pushes as standard"`
+	RejectBareMultisig   bool          `long:"rejectbaremultisig" description:"Reject transactions with bare (not pay-to-script-hash) multi-signature outputs as non-standard"`
 	FreeTxRelayLimit     float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
 	NoRelayPriority      bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
 	MaxOrphanTxs         int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
+	MaxMempool           int           `long:"maxmempool" description:"Max virtual size of the transaction memory pool in megabytes -- The transactions paying the lowest fees are evicted when it is exceeded -- 0 disables the limit"`
+	MempoolExpiry        time.Duration `long:"mempoolexpiry" description:"Max amount of time a transaction may stay in the memory pool before it is evicted -- 0 disables expiry"`
 	Generate             bool          `long:"generate" description:"Generate (mine) bitcoins using the CPU"`
 	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
 	BlockMinSize         uint32        `long:"blockminsize" description:"Mininum block size in bytes to be used when creating a block"`
@@ -148,23 +194,33 @@ type config struct {
 	BlockMinWeight       uint32        `long:"blockminweight" description:"Mininum block weight to be used when creating a block"`
 	BlockMaxWeight       uint32        `long:"blockmaxweight" description:"Maximum block weight to be used when creating a block"`
 	BlockPrioritySize    uint32        `long:"blockprioritysize" description:"Size in bytes for high-priority/low-fee transactions when creating a block"`
+	BlockLockTime        bool          `long:"blocklocktime" description:"Set the lock time of the coinbase transaction to the current height when creating a block, as wallets do to discourage fee sniping"`
 	UserAgentComments    []string      `long:"uacomment" description:"Comment to add to the user agent -- See BIP 14 for more information."`
 	NoPeerBloomFilters   bool          `long:"nopeerbloomfilters" description:"Disable bloom filtering support"`
+	ZstdBlocks           bool          `long:"zstdblocks" description:"Advertise support for zstd compressed blocks and exchange blocks compressed with whitelisted peers which support them too"`
 	SigCacheMaxSize      uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
 	BlocksOnly           bool          `long:"blocksonly" description:"Do not accept transactions from remote peers."`
 	TxIndex              bool          `long:"txindex" description:"Maintain a full hash-based transaction index which makes all transactions available via the getrawtransaction RPC"`
 	DropTxIndex          bool          `long:"droptxindex" description:"Deletes the hash-based transaction index from the database on start up and then exits."`
 	AddrIndex            bool          `long:"addrindex" description:"Maintain a full address-based transaction index which makes the searchrawtransactions RPC available"`
 	DropAddrIndex        bool          `long:"dropaddrindex" description:"Deletes the address-based transaction index from the database on start up and then exits."`
+	RewardIndex          bool          `long:"rewardindex" description:"Maintain an index of the fees and coinbase value of every block which makes the getblockrewards RPC available"`
+	DropRewardIndex      bool          `long:"droprewardindex" description:"Deletes the block reward index from the database on start up and then exits."`
 	RelayNonStd          bool          `long:"relaynonstd" description:"Relay non-standard transactions regardless of the default settings for the active network."`
 	RejectNonStd         bool          `long:"rejectnonstd" description:"Reject non-standard transactions regardless of the default settings for the active network."`
+	RequireLockTime      bool          `long:"requirelocktime" description:"Only accept and relay new transactions with an anti-fee-sniping lock time, which is a block height no more than 100 blocks below the current height, even when relaying non-standard transactions"`
+	PolicyExceptTxs      []string      `long:"policyexcepttx" description:"Accept and relay the transaction with the given hash even when it is not standard, as long as it is valid"`
+	PolicyExceptScripts  []string      `long:"policyexceptscript" description:"Accept and relay transactions which pay to or spend an output script matching the given template even when they are not standard, as long as they are valid -- Templates use the decodescript asm format where <data> matches any pushed data"`
 	lookup               func(string) ([]net.IP, error)
 	oniondial            func(string, string, time.Duration) (net.Conn, error)
 	dial                 func(string, string, time.Duration) (net.Conn, error)
 	addCheckpoints       []chaincfg.Checkpoint
 	miningAddrs          []btcutil.Address
 	minRelayTxFee        btcutil.Amount
+	standardPolicy       *policy.Policy
 	whitelists           []*net.IPNet
+	policyExceptions     *mempool.PolicyExceptions
+	banScores            map[string]uint32
 }
 
 // serviceOptions defines the configuration options for the daemon as a service on
@@ -401,17 +457,23 @@ func loadConfig() (*config, []string, error) {
 		ConfigFile:           defaultConfigFile,
 		DebugLevel:           defaultLogLevel,
 		MaxPeers:             defaultMaxPeers,
+		BlockRelayPeers:      defaultBlockRelayPeers,
 		BanDuration:          defaultBanDuration,
 		BanThreshold:         defaultBanThreshold,
 		RPCMaxClients:        defaultMaxRPCClients,
 		RPCMaxWebsockets:     defaultMaxRPCWebsockets,
 		RPCMaxConcurrentReqs: defaultMaxRPCConcurrentReqs,
+		RPCShutdownGrace:     defaultRPCShutdownGrace,
 		DataDir:              defaultDataDir,
 		LogDir:               defaultLogDir,
 		DbType:               defaultDbType,
+		MetaBackups:          defaultMetaBackups,
+		WebhookOutboxSize:    defaultWebhookOutboxSize,
+		WebhookOutboxAge:     defaultWebhookOutboxAge,
 		RPCKey:               defaultRPCKeyFile,
 		RPCCert:              defaultRPCCertFile,
 		MinRelayTxFee:        mempool.DefaultMinRelayTxFee.ToBTC(),
+		DataCarrierSize:      policy.DefaultMaxDataCarrierSize,
 		FreeTxRelayLimit:     defaultFreeTxRelayLimit,
 		BlockMinSize:         defaultBlockMinSize,
 		BlockMaxSize:         defaultBlockMaxSize,
@@ -419,6 +481,8 @@ func loadConfig() (*config, []string, error) {
 		BlockMaxWeight:       defaultBlockMaxWeight,
 		BlockPrioritySize:    mempool.DefaultBlockPrioritySize,
 		MaxOrphanTxs:         defaultMaxOrphanTransactions,
+		MaxMempool:           defaultMaxMempool,
+		MempoolExpiry:        defaultMempoolExpiry,
 		SigCacheMaxSize:      defaultSigCacheMaxSize,
 		Generate:             defaultGenerate,
 		TxIndex:              defaultTxIndex,
@@ -530,6 +594,10 @@ func loadConfig() (*config, []string, error) {
 		numNets++
 		activeNetParams = &testNet3Params
 	}
+	if cfg.TestNet4 {
+		numNets++
+		activeNetParams = &testNet4Params
+	}
 	if cfg.RegressionTest {
 		numNets++
 		activeNetParams = &regressionNetParams
@@ -540,15 +608,84 @@ func loadConfig() (*config, []string, error) {
 		activeNetParams = &simNetParams
 		cfg.DisableDNSSeed = true
 	}
+	if cfg.SigNet {
+		numNets++
+		activeNetParams = &sigNetParams
+	}
+	if cfg.ChainParams != "" {
+		numNets++
+		chainParams, err := chaincfg.RegisterFromFile(
+			cleanAndExpandPath(cfg.ChainParams))
+		if err != nil {
+			str := "%s: Unable to load the network definition: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+
+		// Custom networks use the port following the peer-to-peer
+		// port for RPC by default.
+		port, _ := strconv.Atoi(chainParams.DefaultPort)
+		activeNetParams = &params{
+			Params:  chainParams,
+			rpcPort: strconv.Itoa(port + 1),
+		}
+	}
 	if numNets > 1 {
-		str := "%s: The testnet, regtest, segnet, and simnet params " +
-			"can't be used together -- choose one of the four"
+		str := "%s: The testnet, testnet4, regtest, signet, simnet, " +
+			"and chainparams params can't be used together -- " +
+			"choose one of the six"
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
 		return nil, nil, err
 	}
 
+	// A custom signet challenge or seed nodes define a different signet
+	// network, so create its parameters from the default signet
+	// parameters.
+	if cfg.SigNetChallenge != "" || len(cfg.SigNetSeedNodes) > 0 {
+		if !cfg.SigNet {
+			str := "%s: The signetchallenge and signetseednode " +
+				"options may only be used with signet"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+
+		challenge := chaincfg.DefaultSignetChallenge
+		seeds := chaincfg.DefaultSignetDNSSeeds
+		if cfg.SigNetChallenge != "" {
+			var err error
+			challenge, err = hex.DecodeString(cfg.SigNetChallenge)
+			if err != nil || len(challenge) == 0 {
+				str := "%s: The signetchallenge option must be " +
+					"a non-empty hex encoded script"
+				err := fmt.Errorf(str, funcName)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+
+			// The seeds of the public signet are not part of a
+			// custom signet.
+			seeds = nil
+		}
+		if len(cfg.SigNetSeedNodes) > 0 {
+			seeds = make([]chaincfg.DNSSeed, 0,
+				len(cfg.SigNetSeedNodes))
+			for _, host := range cfg.SigNetSeedNodes {
+				seeds = append(seeds, chaincfg.DNSSeed{Host: host})
+			}
+		}
+		sigNetParams := chaincfg.CustomSignetParams(challenge, seeds)
+		activeNetParams = &params{
+			Params:  &sigNetParams,
+			rpcPort: activeNetParams.rpcPort,
+		}
+	}
+
 	// Set the default policy for relaying non-standard transactions
 	// according to the default of the active network. The set
 	// configuration value takes precedence over the default value for the
@@ -569,6 +706,33 @@ func loadConfig() (*config, []string, error) {
 	}
 	cfg.RelayNonStd = relayNonStd
 
+	// Parse the transactions and script templates which are accepted
+	// regardless of the standardness rules.
+	if len(cfg.PolicyExceptTxs) > 0 || len(cfg.PolicyExceptScripts) > 0 {
+		txHashes := make([]chainhash.Hash, 0, len(cfg.PolicyExceptTxs))
+		for _, txid := range cfg.PolicyExceptTxs {
+			txHash, err := chainhash.NewHashFromStr(txid)
+			if err != nil {
+				str := "%s: The policyexcepttx option must be " +
+					"a transaction hash -- parsed [%s]"
+				err := fmt.Errorf(str, funcName, txid)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+			txHashes = append(txHashes, *txHash)
+		}
+		cfg.policyExceptions, err = mempool.NewPolicyExceptions(
+			txHashes, cfg.PolicyExceptScripts)
+		if err != nil {
+			str := "%s: Invalid policyexceptscript option -- %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// Append the network type to the data directory so it is "namespaced"
 	// per network.  In addition to the block database, there are other
 	// pieces of data that are saved to disk such as address manager state.
@@ -611,6 +775,109 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Metadata snapshots are only supported by the ffldb database.
+	if (cfg.MetaBackupInterval != 0 || cfg.RestoreMetadata ||
+		cfg.AutoRecover || cfg.VerifyBlockFiles) && cfg.DbType != "ffldb" {
+
+		str := "%s: The metabackupinterval, restoremetadata, " +
+			"autorecover, and verifyblockfiles options are only " +
+			"supported by the ffldb database type"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Keeping the oldest block files in a separate directory is only
+	// supported by the ffldb database, and the directory must exist.
+	if cfg.ColdBlockDir != "" {
+		if cfg.DbType != "ffldb" {
+			str := "%s: The coldblockdir option is only " +
+				"supported by the ffldb database type"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.ColdBlockDir = cleanAndExpandPath(cfg.ColdBlockDir)
+		if fi, err := os.Stat(cfg.ColdBlockDir); err != nil || !fi.IsDir() {
+			str := "%s: The coldblockdir option must be an " +
+				"existing directory -- parsed [%s]"
+			err := fmt.Errorf(str, funcName, cfg.ColdBlockDir)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+	if cfg.MetaBackupInterval != 0 &&
+		cfg.MetaBackupInterval < minMetaBackupInterval {
+
+		str := "%s: The metabackupinterval option must be 0 or at " +
+			"least %v -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, minMetaBackupInterval,
+			cfg.MetaBackupInterval)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.MetaBackups < 1 {
+		str := "%s: The metabackups option must be at least 1 -- " +
+			"parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MetaBackups)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.UndoDepth != 0 && cfg.UndoDepth < blockchain.MinUndoDepth {
+		str := "%s: The undodepth option must be 0 or at least %d " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, blockchain.MinUndoDepth,
+			cfg.UndoDepth)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.MaxReorgDepth < 0 {
+		str := "%s: The maxreorgdepth option may not be negative " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MaxReorgDepth)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// A node replaying a trace must only process the events of the trace,
+	// so it does not connect to any peers or mine blocks on its own.
+	if cfg.RecordTrace != "" {
+		cfg.RecordTrace = cleanAndExpandPath(cfg.RecordTrace)
+	}
+	if cfg.ReplayTrace != "" {
+		var conflict string
+		switch {
+		case cfg.RecordTrace != "":
+			conflict = "--recordtrace"
+		case len(cfg.ConnectPeers) > 0:
+			conflict = "--connect"
+		case len(cfg.AddPeers) > 0:
+			conflict = "--addpeer"
+		case cfg.Generate:
+			conflict = "--generate"
+		}
+		if conflict != "" {
+			str := "%s: the --replaytrace and %s options can not " +
+				"be used together"
+			err := fmt.Errorf(str, funcName, conflict)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.ReplayTrace = cleanAndExpandPath(cfg.ReplayTrace)
+		cfg.DisableListen = true
+		cfg.DisableDNSSeed = true
+	}
+
 	// Validate profile port number
 	if cfg.Profile != "" {
 		profilePort, err := strconv.Atoi(cfg.Profile)
@@ -623,6 +890,43 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	// Add the default port to the metrics listen address if needed.
+	if cfg.MetricsListen != "" {
+		cfg.MetricsListen = normalizeAddress(cfg.MetricsListen,
+			defaultMetricsPort)
+	}
+
+	// Validate the webhook URLs and outbox limits.
+	for _, webhook := range cfg.Webhooks {
+		u, err := url.Parse(webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+			u.Host == "" {
+
+			str := "%s: The webhook option must be an http or https " +
+				"URL -- parsed [%v]"
+			err := fmt.Errorf(str, funcName, webhook)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+	if cfg.WebhookOutboxSize < 1 {
+		str := "%s: The webhookoutboxsize option may not be less than " +
+			"1 -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.WebhookOutboxSize)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.WebhookOutboxAge < 0 {
+		str := "%s: The webhookoutboxage option may not be negative " +
+			"-- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.WebhookOutboxAge)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Don't allow ban durations that are too short.
 	if cfg.BanDuration < time.Second {
 		str := "%s: The banduration option may not be less than 1s -- parsed [%v]"
@@ -632,6 +936,37 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Apply the ban score overrides to the default offense scores.
+	cfg.banScores, err = parseBanScores(cfg.BanScores)
+	if err != nil {
+		str := "%s: Invalid banscore option -- %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Don't allow a negative number of block-relay-only peers.
+	if cfg.BlockRelayPeers < 0 {
+		str := "%s: The blockrelaypeers option may not be negative " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.BlockRelayPeers)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Don't allow negative peer rate limits.
+	if cfg.MaxPeerUpload < 0 || cfg.MaxPeerDownload < 0 {
+		str := "%s: The maxpeerupload and maxpeerdownload options may " +
+			"not be negative -- parsed [%d] and [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MaxPeerUpload,
+			cfg.MaxPeerDownload)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Validate any given whitelisted IP addresses and networks.
 	if len(cfg.Whitelists) > 0 {
 		var ip net.IP
@@ -756,6 +1091,33 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Create the standardness policy from the relay options.  The dust
+	// relay fee follows the minrelaytxfee unless it is set explicitly.
+	cfg.standardPolicy = policy.New()
+	cfg.standardPolicy.MinRelayTxFee = cfg.minRelayTxFee
+	cfg.standardPolicy.DustRelayFee = cfg.minRelayTxFee
+	if cfg.DustRelayFee != 0 {
+		cfg.standardPolicy.DustRelayFee, err = btcutil.NewAmount(
+			cfg.DustRelayFee)
+		if err != nil {
+			str := "%s: invalid dustrelayfee: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+	cfg.standardPolicy.MaxDataCarrierSize = cfg.DataCarrierSize
+	cfg.standardPolicy.PermitBareMultisig = !cfg.RejectBareMultisig
+	cfg.standardPolicy.PermitMultiPushDataCarrier = cfg.DataCarrierMultiPush
+	if err := cfg.standardPolicy.Validate(); err != nil {
+		str := "%s: invalid relay policy: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the max block size to a sane value.
 	if cfg.BlockMaxSize < blockMaxSizeMin || cfg.BlockMaxSize >
 		blockMaxSizeMax {
@@ -792,6 +1154,24 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The memory pool size limit and expiry may not be negative.
+	if cfg.MaxMempool < 0 {
+		str := "%s: The maxmempool option may not be less than 0 " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MaxMempool)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.MempoolExpiry < 0 {
+		str := "%s: The mempoolexpiry option may not be less than 0 " +
+			"-- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.MempoolExpiry)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the block priority and minimum block sizes to max block size.
 	cfg.BlockPrioritySize = minUint32(cfg.BlockPrioritySize, cfg.BlockMaxSize)
 	cfg.BlockMinSize = minUint32(cfg.BlockMinSize, cfg.BlockMaxSize)
@@ -814,6 +1194,15 @@ func loadConfig() (*config, []string, error) {
 		cfg.BlockMaxWeight = cfg.BlockMaxSize * blockchain.WitnessScaleFactor
 	}
 
+	// Block templates are only limited by weight, so scale the minimum
+	// block size to a weight in the same way when only it is set.
+	if cfg.BlockMinSize != defaultBlockMinSize &&
+		cfg.BlockMinWeight == defaultBlockMinWeight {
+
+		cfg.BlockMinWeight = minUint32(cfg.BlockMinSize*
+			blockchain.WitnessScaleFactor, cfg.BlockMaxWeight)
+	}
+
 	// Look for illegal characters in the user agent comments.
 	for _, uaComment := range cfg.UserAgentComments {
 		if strings.ContainsAny(uaComment, "/:()") {
@@ -858,6 +1247,28 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// --rewardindex and --droprewardindex do not mix.
+	if cfg.RewardIndex && cfg.DropRewardIndex {
+		err := fmt.Errorf("%s: the --rewardindex and --droprewardindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --rewardindex and --droptxindex do not mix.
+	if cfg.RewardIndex && cfg.DropTxIndex {
+		err := fmt.Errorf("%s: the --rewardindex and --droptxindex "+
+			"options may not be activated at the same time "+
+			"because the block reward index relies on the "+
+			"transaction index",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Check mining addresses are valid and saved parsed versions.
 	cfg.miningAddrs = make([]btcutil.Address, 0, len(cfg.MiningAddrs))
 	for _, strAddr := range cfg.MiningAddrs {
@@ -936,6 +1347,8 @@ func loadConfig() (*config, []string, error) {
 		activeNetParams.DefaultPort)
 	cfg.ConnectPeers = normalizeAddresses(cfg.ConnectPeers,
 		activeNetParams.DefaultPort)
+	cfg.RecoveryPeers = normalizeAddresses(cfg.RecoveryPeers,
+		activeNetParams.DefaultPort)
 
 	// --noonion and --onion do not mix.
 	if cfg.NoOnion && cfg.OnionProxy != "" {
@@ -966,6 +1379,27 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The Tor control port is only used to refresh the circuits of the
+	// proxy, so it requires either proxy or onion proxy to be set.
+	if cfg.TorControl != "" {
+		if cfg.Proxy == "" && cfg.OnionProxy == "" {
+			str := "%s: Tor control port requires either proxy or " +
+				"onionproxy to be set"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		_, _, err := net.SplitHostPort(cfg.TorControl)
+		if err != nil {
+			str := "%s: Tor control port '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, cfg.TorControl, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// Setup dial and DNS resolution (lookup) functions depending on the
 	// specified options.  The default is to use the standard
 	// net.DialTimeout function as well as the system DNS resolver.  When a
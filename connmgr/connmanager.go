@@ -40,15 +40,18 @@ var (
 // ConnState represents the state of the requested connection.
 type ConnState uint8
 
-// ConnState can be either pending, established, disconnected or failed.  When
-// a new connection is requested, it is attempted and categorized as
-// established or failed depending on the connection result.  An established
-// connection which was disconnected is categorized as disconnected.
+// ConnState can be either pending, established, disconnected, failed or
+// canceled.  When a new connection is requested, it is attempted and
+// categorized as established or failed depending on the connection result.  An
+// established connection which was disconnected is categorized as
+// disconnected.  A pending connection which was removed before it was
+// established is categorized as canceled.
 const (
 	ConnPending ConnState = iota
 	ConnEstablished
 	ConnDisconnected
 	ConnFailed
+	ConnCanceled
 )
 
 // ConnReq is the connection request to a network address. If permanent, the
@@ -60,6 +63,13 @@ type ConnReq struct {
 	Addr      net.Addr
 	Permanent bool
 
+	// BlockRelayOnly indicates the connection must only be used to relay
+	// blocks, which means transactions and addresses are neither relayed
+	// nor requested over it.  Such connections are not observable by
+	// watching transaction and address propagation, which makes it harder
+	// to find and take over all of the connections of a node.
+	BlockRelayOnly bool
+
 	conn       net.Conn
 	state      ConnState
 	stateMtx   sync.RWMutex
@@ -123,6 +133,17 @@ type Config struct {
 	// maintain. Defaults to 8.
 	TargetOutbound uint32
 
+	// TargetBlockRelayOnly is the number of outbound block-relay-only
+	// network connections to maintain in addition to TargetOutbound.
+	// Defaults to 0.
+	TargetBlockRelayOnly uint32
+
+	// Anchors are the addresses the first block-relay-only connections are
+	// made to when starting.  Typically, they are the addresses of the
+	// block-relay-only peers the node was connected to when it last shut
+	// down, so an attacker can't replace all of them by restarting it.
+	Anchors []net.Addr
+
 	// RetryDuration is the duration to wait before retrying connection
 	// requests. Defaults to 5s.
 	RetryDuration time.Duration
@@ -143,6 +164,13 @@ type Config struct {
 	Dial func(net.Addr) (net.Conn, error)
 }
 
+// registerPending is used to register a pending connection attempt.  Only
+// connections which were registered as pending are accepted once established
+// so that removing a pending connection request cancels it.
+type registerPending struct {
+	c *ConnReq
+}
+
 // handleConnected is used to queue a successful connection.
 type handleConnected struct {
 	c    *ConnReq
@@ -177,9 +205,11 @@ type ConnManager struct {
 
 // handleFailedConn handles a connection failed due to a disconnect or any
 // other failure. If permanent, it retries the connection after the configured
-// retry duration. Otherwise, if required, it makes a new connection request.
-// After maxFailedConnectionAttempts new connections will be retried after the
-// configured retry duration.
+// retry duration, increasing the wait with each retry up to maxRetryDuration.
+// Permanent connections keep their own backoff and don't affect the failed
+// attempts of the regular outbound connections.  Otherwise, if required, it
+// makes a new connection request.  After maxFailedConnectionAttempts new
+// connections will be retried after the configured retry duration.
 func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
@@ -201,10 +231,10 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 				"-- retrying connection in: %v", maxFailedAttempts,
 				cm.cfg.RetryDuration)
 			time.AfterFunc(cm.cfg.RetryDuration, func() {
-				cm.NewConnReq()
+				cm.newConnReq(c.BlockRelayOnly)
 			})
 		} else {
-			go cm.NewConnReq()
+			go cm.newConnReq(c.BlockRelayOnly)
 		}
 	}
 }
@@ -214,23 +244,52 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 //
 // The connection handler makes sure that we maintain a pool of active outbound
 // connections so that we remain connected to the network.  Connection requests
-// are processed and mapped by their assigned ids.
+// are processed and mapped by their assigned ids.  Permanent connections are
+// maintained in addition to the pool, so they don't take up any of the slots
+// of the regular outbound connections.
 func (cm *ConnManager) connHandler() {
-	conns := make(map[uint64]*ConnReq, cm.cfg.TargetOutbound)
+	var (
+		// pending holds all registered connection requests that have
+		// yet to succeed, including permanent requests waiting to be
+		// retried.
+		pending = make(map[uint64]*ConnReq)
+
+		// conns represents the set of all actively connected peers.
+		conns = make(map[uint64]*ConnReq, cm.cfg.TargetOutbound)
+	)
 out:
 	for {
 		select {
 		case req := <-cm.requests:
 			switch msg := req.(type) {
 
+			case registerPending:
+				connReq := msg.c
+				connReq.updateState(ConnPending)
+				pending[connReq.id] = connReq
+
 			case handleConnected:
 				connReq := msg.c
+				if _, ok := pending[connReq.id]; !ok {
+					// The request was removed while the
+					// connection was being made.
+					if msg.conn != nil {
+						msg.conn.Close()
+					}
+					log.Debugf("Ignoring connection for "+
+						"canceled connreq=%v", connReq)
+					continue
+				}
+				delete(pending, connReq.id)
+
 				connReq.updateState(ConnEstablished)
 				connReq.conn = msg.conn
 				conns[connReq.id] = connReq
 				log.Debugf("Connected to %v", connReq)
 				connReq.retryCount = 0
-				cm.failedAttempts = 0
+				if !connReq.Permanent {
+					cm.failedAttempts = 0
+				}
 
 				if cm.cfg.OnConnection != nil {
 					go cm.cfg.OnConnection(connReq, msg.conn)
@@ -249,15 +308,49 @@ out:
 						go cm.cfg.OnDisconnection(connReq)
 					}
 
-					if uint32(len(conns)) < cm.cfg.TargetOutbound && msg.retry {
+					if !msg.retry {
+						continue
+					}
+
+					// Permanent connections are always retried
+					// while regular outbound connections are only
+					// replaced when there are too few of them.
+					if connReq.Permanent {
+						pending[connReq.id] = connReq
+						cm.handleFailedConn(connReq)
+						continue
+					}
+					target := cm.cfg.TargetOutbound +
+						cm.cfg.TargetBlockRelayOnly
+					if countRegular(conns) < target {
 						cm.handleFailedConn(connReq)
 					}
+				} else if connReq, ok := pending[msg.id]; ok {
+					// Removing a pending request cancels it,
+					// including any retries of permanent
+					// requests.  Pending requests are already
+					// being retried otherwise.
+					if msg.retry {
+						continue
+					}
+					connReq.updateState(ConnCanceled)
+					delete(pending, msg.id)
+					log.Debugf("Canceled pending connection to %v",
+						connReq)
 				} else {
 					log.Errorf("Unknown connection: %d", msg.id)
 				}
 
 			case handleFailed:
 				connReq := msg.c
+				if _, ok := pending[connReq.id]; !ok {
+					log.Debugf("Ignoring connection failure for "+
+						"canceled connreq=%v", connReq)
+					continue
+				}
+				if !connReq.Permanent {
+					delete(pending, connReq.id)
+				}
 				connReq.updateState(ConnFailed)
 				log.Debugf("Failed to connect to %v: %v", connReq, msg.err)
 				cm.handleFailedConn(connReq)
@@ -272,9 +365,40 @@ out:
 	log.Trace("Connection handler done")
 }
 
+// countRegular returns the number of the passed connections which are not
+// permanent.
+func countRegular(conns map[uint64]*ConnReq) uint32 {
+	var count uint32
+	for _, connReq := range conns {
+		if !connReq.Permanent {
+			count++
+		}
+	}
+	return count
+}
+
+// register assigns an id to the passed connection request and registers it as
+// pending with the connection handler.  It returns false when the connection
+// manager was stopped in the meantime.
+func (cm *ConnManager) register(c *ConnReq) bool {
+	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
+	select {
+	case cm.requests <- registerPending{c}:
+		return true
+	case <-cm.quit:
+		return false
+	}
+}
+
 // NewConnReq creates a new connection request and connects to the
 // corresponding address.
 func (cm *ConnManager) NewConnReq() {
+	cm.newConnReq(false)
+}
+
+// newConnReq creates a new connection request, which is block-relay-only when
+// requested, and connects to the corresponding address.
+func (cm *ConnManager) newConnReq(blockRelayOnly bool) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
 	}
@@ -282,8 +406,10 @@ func (cm *ConnManager) NewConnReq() {
 		return
 	}
 
-	c := &ConnReq{}
-	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
+	c := &ConnReq{BlockRelayOnly: blockRelayOnly}
+	if !cm.register(c) {
+		return
+	}
 
 	addr, err := cm.cfg.GetNewAddress()
 	if err != nil {
@@ -303,7 +429,11 @@ func (cm *ConnManager) Connect(c *ConnReq) {
 		return
 	}
 	if atomic.LoadUint64(&c.id) == 0 {
-		atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
+		if !cm.register(c) {
+			return
+		}
+	} else if c.State() == ConnCanceled {
+		return
 	}
 	log.Debugf("Attempting to connect to %v", c)
 	conn, err := cm.cfg.Dial(c.Addr)
@@ -325,7 +455,8 @@ func (cm *ConnManager) Disconnect(id uint64) {
 }
 
 // Remove removes the connection corresponding to the given connection
-// id from known connections.
+// id from known connections.  Removing a connection request which is still
+// pending, such as a permanent request waiting to be retried, cancels it.
 func (cm *ConnManager) Remove(id uint64) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
@@ -373,9 +504,21 @@ func (cm *ConnManager) Start() {
 		}
 	}
 
-	for i := atomic.LoadUint64(&cm.connReqCount); i < uint64(cm.cfg.TargetOutbound); i++ {
+	for i := uint32(0); i < cm.cfg.TargetOutbound; i++ {
 		go cm.NewConnReq()
 	}
+
+	// The block-relay-only connections are made to the anchors first.
+	for i := 0; i < int(cm.cfg.TargetBlockRelayOnly); i++ {
+		if i < len(cm.cfg.Anchors) {
+			go cm.Connect(&ConnReq{
+				Addr:           cm.cfg.Anchors[i],
+				BlockRelayOnly: true,
+			})
+			continue
+		}
+		go cm.newConnReq(true)
+	}
 }
 
 // Wait blocks until the connection manager halts gracefully.
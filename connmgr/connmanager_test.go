@@ -215,6 +215,82 @@ func TestTargetOutbound(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestTargetBlockRelayOnly tests the target number of block-relay-only
+// outbound connections.
+//
+// We connect to the anchors first and then to new addresses until the target
+// is reached.  Disconnecting a block-relay-only connection must replace it with
+// another block-relay-only connection.
+func TestTargetBlockRelayOnly(t *testing.T) {
+	anchor := &net.TCPAddr{
+		IP:   net.ParseIP("127.0.0.1"),
+		Port: 18556,
+	}
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound:       2,
+		TargetBlockRelayOnly: 2,
+		Anchors:              []net.Addr{anchor},
+		RetryDuration:        time.Millisecond,
+		Dial:                 mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+
+	var blockRelayOnly []*ConnReq
+	var anchored bool
+	for i := 0; i < 4; i++ {
+		c := <-connected
+		if !c.BlockRelayOnly {
+			continue
+		}
+		blockRelayOnly = append(blockRelayOnly, c)
+		if c.Addr.String() == anchor.String() {
+			if c.Permanent {
+				t.Fatalf("anchor connection is permanent")
+			}
+			anchored = true
+		}
+	}
+	if len(blockRelayOnly) != 2 {
+		t.Fatalf("block-relay-only connections: got %d, want 2",
+			len(blockRelayOnly))
+	}
+	if !anchored {
+		t.Fatalf("anchor was not connected")
+	}
+
+	select {
+	case c := <-connected:
+		t.Fatalf("target block-relay-only: got unexpected connection - %v",
+			c.Addr)
+	case <-time.After(time.Millisecond):
+		break
+	}
+
+	cmgr.Disconnect(blockRelayOnly[0].ID())
+	select {
+	case c := <-connected:
+		if !c.BlockRelayOnly {
+			t.Fatalf("replacement connection is not block-relay-only")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("block-relay-only connection was not replaced")
+	}
+	cmgr.Stop()
+}
+
 // TestRetryPermanent tests that permanent connection requests are retried.
 //
 // We make a permanent connection request using Connect, disconnect it using
@@ -348,6 +424,116 @@ func TestMaxRetryDuration(t *testing.T) {
 	}
 }
 
+// TestRemovePending tests that removing a permanent connection request which
+// is waiting to be retried cancels it.
+func TestRemovePending(t *testing.T) {
+	dials := make(chan struct{}, 100)
+	errDialer := func(addr net.Addr) (net.Conn, error) {
+		dials <- struct{}{}
+		return nil, errors.New("network down")
+	}
+	cmgr, err := New(&Config{
+		RetryDuration:  time.Millisecond,
+		TargetOutbound: 1,
+		Dial:           errDialer,
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	cr := &ConnReq{
+		Addr: &net.TCPAddr{
+			IP:   net.ParseIP("127.0.0.1"),
+			Port: 18555,
+		},
+		Permanent: true,
+	}
+	cmgr.Start()
+	go cmgr.Connect(cr)
+
+	// Wait for the request to be retried before removing it.
+	<-dials
+	<-dials
+	cmgr.Remove(cr.ID())
+
+	// Allow for the removal to be handled and for a dial which was already
+	// in progress, after which the request must not be retried anymore.
+	time.Sleep(10 * time.Millisecond)
+	if gotState := cr.State(); gotState != ConnCanceled {
+		t.Fatalf("remove pending: want state %v, got state %v",
+			ConnCanceled, gotState)
+	}
+	for len(dials) > 0 {
+		<-dials
+	}
+	select {
+	case <-dials:
+		t.Fatal("remove pending: canceled request was retried")
+	case <-time.After(10 * time.Millisecond):
+	}
+	cmgr.Stop()
+	cmgr.Wait()
+}
+
+// TestPermanentOutsideTarget tests that permanent connections don't count
+// toward the target number of outbound connections.
+func TestPermanentOutsideTarget(t *testing.T) {
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound: 1,
+		Dial:           mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.2"),
+				Port: 18555,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+
+	cr := &ConnReq{
+		Addr: &net.TCPAddr{
+			IP:   net.ParseIP("127.0.0.1"),
+			Port: 18555,
+		},
+		Permanent: true,
+	}
+	go cmgr.Connect(cr)
+	cmgr.Start()
+
+	// Both the permanent and the regular outbound connection are made.
+	var regular *ConnReq
+	for i := 0; i < 2; i++ {
+		c := <-connected
+		if !c.Permanent {
+			regular = c
+		}
+	}
+	if regular == nil {
+		t.Fatal("permanent outside target: no regular connection")
+	}
+
+	// Disconnecting the regular connection replaces it even though the
+	// permanent connection remains.
+	cmgr.Disconnect(regular.ID())
+	select {
+	case c := <-connected:
+		if c.Permanent || c.ID() == regular.ID() {
+			t.Fatalf("permanent outside target: unexpected "+
+				"connection %v", c)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("permanent outside target: regular connection was " +
+			"not replaced")
+	}
+	cmgr.Stop()
+}
+
 // TestNetworkFailure tests that the connection manager handles a network
 // failure gracefully.
 func TestNetworkFailure(t *testing.T) {
@@ -0,0 +1,120 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// torControlTimeout is the maximum amount of time allowed for a
+	// complete exchange with the Tor control port.
+	torControlTimeout = 10 * time.Second
+
+	// torControlReplyOK is the status code of a successful reply from the
+	// Tor control port.
+	torControlReplyOK = "250"
+)
+
+// ErrTorInvalidControlReply indicates the Tor control port returned a reply in
+// an unexpected format.
+var ErrTorInvalidControlReply = errors.New("invalid tor control reply")
+
+// TorController is a minimal client for the Tor control protocol.  It only
+// supports the commands needed to request that Tor switches to clean circuits,
+// which is used to recover from misbehaving or congested circuits when
+// outbound connections are made through Tor.
+type TorController struct {
+	addr     string
+	password string
+}
+
+// NewTorController returns a new controller for the Tor control port at the
+// passed address.  The password is used for HashedControlPassword
+// authentication and may be empty when the control port does not require
+// authentication.
+func NewTorController(addr, password string) *TorController {
+	return &TorController{addr: addr, password: password}
+}
+
+// quoteTorControlString returns the passed string as a quoted string as defined
+// by the Tor control protocol.
+func quoteTorControlString(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
+
+// torControlCommand sends the passed command to the Tor control port and reads
+// its reply.  An error is returned when the reply does not indicate success.
+func torControlCommand(conn net.Conn, r *bufio.Reader, cmd string) error {
+	if _, err := conn.Write([]byte(cmd + "\r\n")); err != nil {
+		return err
+	}
+
+	// Replies consist of one or more lines of the form <code><sep><text>
+	// where the separator of the final line is a space.
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) < 4 {
+			return ErrTorInvalidControlReply
+		}
+		code, sep, text := line[:3], line[3], line[4:]
+		if code != torControlReplyOK {
+			return fmt.Errorf("tor control command %q failed: %s %s",
+				strings.Fields(cmd)[0], code, text)
+		}
+		switch sep {
+		case ' ':
+			return nil
+		case '-':
+			continue
+		default:
+			return ErrTorInvalidControlReply
+		}
+	}
+}
+
+// NewCircuits authenticates with the Tor control port and signals Tor to use
+// clean circuits for all new connections (SIGNAL NEWNYM).  Existing connections
+// are not affected.  Note that Tor rate limits this signal, although it
+// acknowledges it regardless.
+func (tc *TorController) NewCircuits() error {
+	conn, err := net.DialTimeout("tcp", tc.addr, torControlTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(torControlTimeout)); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	auth := "AUTHENTICATE"
+	if tc.password != "" {
+		auth += " " + quoteTorControlString(tc.password)
+	}
+	if err := torControlCommand(conn, r, auth); err != nil {
+		return err
+	}
+	if err := torControlCommand(conn, r, "SIGNAL NEWNYM"); err != nil {
+		return err
+	}
+
+	// The connection is closed regardless of the reply to QUIT, so there
+	// is no need to wait for it.
+	_, _ = conn.Write([]byte("QUIT\r\n"))
+	log.Debugf("Requested new tor circuits via %s", tc.addr)
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+// serveTorControl runs a fake Tor control port on the passed listener which
+// accepts a single connection, only accepts the passed password, and sends the
+// commands it receives on the returned channel.
+func serveTorControl(l net.Listener, password string) <-chan string {
+	cmds := make(chan string, 10)
+	go func() {
+		defer close(cmds)
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			cmds <- cmd
+
+			switch {
+			case strings.HasPrefix(cmd, "AUTHENTICATE"):
+				want := "AUTHENTICATE " + quoteTorControlString(password)
+				if cmd != want {
+					conn.Write([]byte("515 Authentication failed: " +
+						"Password did not match\r\n"))
+					return
+				}
+				conn.Write([]byte("250 OK\r\n"))
+
+			case cmd == "SIGNAL NEWNYM":
+				conn.Write([]byte("250-NEWNYM accepted\r\n250 OK\r\n"))
+
+			case cmd == "QUIT":
+				conn.Write([]byte("250 closing connection\r\n"))
+				return
+
+			default:
+				conn.Write([]byte("510 Unrecognized command\r\n"))
+			}
+		}
+	}()
+	return cmds
+}
+
+// TestTorControllerNewCircuits ensures the Tor controller authenticates and
+// requests new circuits, and that failed authentication is reported.
+func TestTorControllerNewCircuits(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+		wantCmds []string
+	}{
+		{
+			name:     "success",
+			password: `pass"word\`,
+			wantCmds: []string{
+				`AUTHENTICATE "pass\"word\\"`,
+				"SIGNAL NEWNYM",
+				"QUIT",
+			},
+		},
+		{
+			name:     "bad password",
+			password: "wrong",
+			wantErr:  true,
+			wantCmds: []string{`AUTHENTICATE "wrong"`},
+		},
+	}
+
+	for _, test := range tests {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("%s: unable to listen: %v", test.name, err)
+		}
+		cmds := serveTorControl(l, `pass"word\`)
+
+		tc := NewTorController(l.Addr().String(), test.password)
+		err = tc.NewCircuits()
+		if test.wantErr != (err != nil) {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+
+		var gotCmds []string
+		for cmd := range cmds {
+			gotCmds = append(gotCmds, cmd)
+		}
+		l.Close()
+
+		if strings.Join(gotCmds, "|") != strings.Join(test.wantCmds, "|") {
+			t.Errorf("%s: unexpected commands - got %q, want %q",
+				test.name, gotCmds, test.wantCmds)
+		}
+	}
+}
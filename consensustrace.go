@@ -0,0 +1,582 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/netsync"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// traceFileVersion is the version of the trace file format written by
+	// the trace recorder.
+	traceFileVersion = 2
+
+	// traceMinerSource is the source of the trace records of the blocks
+	// mined by the node itself, such as by the CPU miner or the generate
+	// RPCs.
+	traceMinerSource = "miner"
+
+	// traceRPCSource is the source of the trace records of RPC calls.
+	traceRPCSource = "rpc"
+)
+
+// traceFileMagic identifies a trace file.  It is followed by the version of the
+// file format and the bitcoin network the trace was recorded on, both as
+// little-endian uint32s.
+var traceFileMagic = [8]byte{'b', 't', 'c', 'd', 't', 'r', 'c', 'e'}
+
+// traceRecordType identifies the kind of event a trace record holds.
+type traceRecordType uint8
+
+// These constants define the kinds of events which are recorded in traces.
+const (
+	// traceBlock is a block received from a peer or mined by the node.
+	// The payload is the serialized block and the flags are the behavior
+	// flags it was processed with.
+	traceBlock traceRecordType = 1
+
+	// traceTx is a transaction received from a peer.  The payload is the
+	// serialized transaction and the tag is the one it was processed
+	// with.
+	traceTx traceRecordType = 2
+
+	// traceRPC is an RPC call which changes the chain or memory pool.  The
+	// payload is the JSON-RPC request.
+	traceRPC traceRecordType = 3
+)
+
+// traceRecordTypeStrings is a map of trace record types back to their constant
+// names for pretty printing.
+var traceRecordTypeStrings = map[traceRecordType]string{
+	traceBlock: "block",
+	traceTx:    "tx",
+	traceRPC:   "rpc",
+}
+
+// String returns the traceRecordType as a human-readable name.
+func (t traceRecordType) String() string {
+	if s := traceRecordTypeStrings[t]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown traceRecordType (%d)", uint8(t))
+}
+
+// traceRPCMethods are the RPC methods which change the chain or memory pool
+// and are therefore recorded in traces.  The methods which mine blocks are not
+// included since mining is not deterministic.  The blocks they mine are
+// recorded instead.
+var traceRPCMethods = map[string]struct{}{
+	"approvereorg":       {},
+	"invalidateblock":    {},
+	"reconsiderblock":    {},
+	"sendrawtransaction": {},
+	"submitblock":        {},
+}
+
+// traceRecord is a single event of a trace.  The time is the adjusted time of
+// the node when the event was recorded, which is what the chain validates the
+// timestamps of blocks against.  The flags and tag are only used by the block
+// and transaction records respectively and are zero otherwise.
+//
+// The serialized format of a record is:
+//
+//   <type><time><flags><tag><source><payload>
+//
+//   Field      Type      Size
+//   type       uint8     1
+//   time       int64     8 (seconds since 1 Jan 1970 GMT)
+//   flags      uint32    4
+//   tag        uint64    8
+//   source     string    variable (peer address, "miner", or "rpc")
+//   payload    []byte    variable
+//
+// The source and payload are serialized as a variable length integer followed
+// by their bytes.
+type traceRecord struct {
+	recordType traceRecordType
+	time       time.Time
+	flags      blockchain.BehaviorFlags
+	tag        mempool.Tag
+	source     string
+	payload    []byte
+}
+
+// traceRecordFixedSize is the size of the fields of a serialized trace record
+// which precede the source and payload.
+const traceRecordFixedSize = 1 + 8 + 4 + 8
+
+// writeTraceHeader writes the header which starts every trace file recorded on
+// the passed network.
+func writeTraceHeader(w io.Writer, net wire.BitcoinNet) error {
+	var header [16]byte
+	copy(header[:], traceFileMagic[:])
+	binary.LittleEndian.PutUint32(header[8:], traceFileVersion)
+	binary.LittleEndian.PutUint32(header[12:], uint32(net))
+	_, err := w.Write(header[:])
+	return err
+}
+
+// readTraceHeader reads the header of a trace file and returns the network it
+// was recorded on.
+func readTraceHeader(r io.Reader) (wire.BitcoinNet, error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, errors.New("not a trace file")
+	}
+	if !bytes.Equal(header[:8], traceFileMagic[:]) {
+		return 0, errors.New("not a trace file")
+	}
+	version := binary.LittleEndian.Uint32(header[8:])
+	if version != traceFileVersion {
+		return 0, fmt.Errorf("unsupported trace file version %d",
+			version)
+	}
+	return wire.BitcoinNet(binary.LittleEndian.Uint32(header[12:])), nil
+}
+
+// writeTraceRecord writes the passed record to w.
+func writeTraceRecord(w io.Writer, record *traceRecord) error {
+	var buf [traceRecordFixedSize]byte
+	buf[0] = byte(record.recordType)
+	binary.LittleEndian.PutUint64(buf[1:], uint64(record.time.Unix()))
+	binary.LittleEndian.PutUint32(buf[9:], uint32(record.flags))
+	binary.LittleEndian.PutUint64(buf[13:], uint64(record.tag))
+	if _, err := w.Write(buf[:]); err != nil {
+		return err
+	}
+	if err := wire.WriteVarString(w, 0, record.source); err != nil {
+		return err
+	}
+	return wire.WriteVarBytes(w, 0, record.payload)
+}
+
+// readTraceRecord reads the next record from r.  It returns io.EOF when there
+// are no more records and io.ErrUnexpectedEOF when the trace ends within a
+// record, such as when the recording node crashed while writing it.
+func readTraceRecord(r io.Reader) (*traceRecord, error) {
+	var buf [traceRecordFixedSize]byte
+	if _, err := io.ReadFull(r, buf[:1]); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, buf[1:]); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	record := &traceRecord{
+		recordType: traceRecordType(buf[0]),
+		time:       time.Unix(int64(binary.LittleEndian.Uint64(buf[1:])), 0),
+		flags:      blockchain.BehaviorFlags(binary.LittleEndian.Uint32(buf[9:])),
+		tag:        mempool.Tag(binary.LittleEndian.Uint64(buf[13:])),
+	}
+
+	var err error
+	record.source, err = wire.ReadVarString(r, 0)
+	if err == nil {
+		record.payload, err = wire.ReadVarBytes(r, 0,
+			wire.MaxMessagePayload, "trace record payload")
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// traceRecorder records the events which change the chain or memory pool of
+// the node to a trace file, so they can be replayed against another node with
+// replayTrace to reproduce its state exactly.  These are the blocks and
+// transactions received from peers, the blocks mined by the node, and the RPC
+// calls in traceRPCMethods.  The blocks and transactions received from peers
+// are recorded by the sync manager once they passed its checks, right before
+// they are processed, so the ones it ignores are not recorded.
+//
+// Each record is written to the file as soon as it is recorded, so a trace is
+// complete up to the event which made the node crash.  Recording stops with an
+// error logged when the file can't be written.
+type traceRecorder struct {
+	timeSource blockchain.MedianTimeSource
+
+	mtx  sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+// Ensure the traceRecorder type implements the netsync.Recorder interface.
+var _ netsync.Recorder = (*traceRecorder)(nil)
+
+// record timestamps the passed record and writes it to the trace file.
+func (r *traceRecorder) record(record *traceRecord) {
+	record.time = r.timeSource.AdjustedTime()
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.file == nil {
+		return
+	}
+	err := writeTraceRecord(r.w, record)
+	if err == nil {
+		err = r.w.Flush()
+	}
+	if err != nil {
+		srvrLog.Errorf("Unable to write trace record: %v -- "+
+			"recording stopped", err)
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+// RecordBlock records a block received from the passed source which is about
+// to be processed with the passed behavior flags.
+//
+// This function is safe for concurrent access and is part of the
+// netsync.Recorder interface.
+func (r *traceRecorder) RecordBlock(source string, block *btcutil.Block, flags blockchain.BehaviorFlags) {
+	serializedBlock, err := block.Bytes()
+	if err != nil {
+		srvrLog.Errorf("Unable to serialize block %v for the trace: %v",
+			block.Hash(), err)
+		return
+	}
+	r.record(&traceRecord{
+		recordType: traceBlock,
+		flags:      flags,
+		source:     source,
+		payload:    serializedBlock,
+	})
+}
+
+// RecordTx records a transaction received from the passed source which is
+// about to be processed with the passed tag.
+//
+// This function is safe for concurrent access and is part of the
+// netsync.Recorder interface.
+func (r *traceRecorder) RecordTx(source string, tx *btcutil.Tx, tag mempool.Tag) {
+	var buf bytes.Buffer
+	buf.Grow(tx.MsgTx().SerializeSize())
+	if err := tx.MsgTx().Serialize(&buf); err != nil {
+		srvrLog.Errorf("Unable to serialize transaction %v for the "+
+			"trace: %v", tx.Hash(), err)
+		return
+	}
+	r.record(&traceRecord{
+		recordType: traceTx,
+		tag:        tag,
+		source:     source,
+		payload:    buf.Bytes(),
+	})
+}
+
+// recordRPC records an RPC call when its method is one of traceRPCMethods.
+func (r *traceRecorder) recordRPC(method string, cmd interface{}) {
+	if _, ok := traceRPCMethods[method]; !ok {
+		return
+	}
+	request, err := btcjson.MarshalCmd(nil, cmd)
+	if err != nil {
+		srvrLog.Errorf("Unable to marshal %s RPC call for the trace: %v",
+			method, err)
+		return
+	}
+	r.record(&traceRecord{
+		recordType: traceRPC,
+		source:     traceRPCSource,
+		payload:    request,
+	})
+}
+
+// processMinedBlock returns a function which records the blocks mined by the
+// node before processing them with the passed function.
+func (r *traceRecorder) processMinedBlock(processBlock func(*btcutil.Block, blockchain.BehaviorFlags) (bool, error)) func(*btcutil.Block, blockchain.BehaviorFlags) (bool, error) {
+	return func(block *btcutil.Block, flags blockchain.BehaviorFlags) (bool, error) {
+		r.RecordBlock(traceMinerSource, block, flags)
+		return processBlock(block, flags)
+	}
+}
+
+// Close stops recording and closes the trace file.
+func (r *traceRecorder) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// newTraceRecorder returns a recorder which writes to the trace file at the
+// passed path, timestamping the records with the passed time source.  Records
+// are appended when the file already holds a trace of the same network, so a
+// trace covers restarts of the node.
+func newTraceRecorder(path string, net wire.BitcoinNet, timeSource blockchain.MedianTimeSource) (*traceRecorder, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if fi.Size() == 0 {
+		err = writeTraceHeader(file, net)
+	} else {
+		var traceNet wire.BitcoinNet
+		traceNet, err = readTraceHeader(file)
+		if err == nil && traceNet != net {
+			err = fmt.Errorf("trace was recorded on network %v",
+				traceNet)
+		}
+		if err == nil {
+			_, err = file.Seek(0, io.SeekEnd)
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("unable to record to trace file %s: %v",
+			path, err)
+	}
+
+	return &traceRecorder{
+		timeSource: timeSource,
+		file:       file,
+		w:          bufio.NewWriter(file),
+	}, nil
+}
+
+// traceClock is the time source of a node replaying a trace.  It reports the
+// adjusted time the recording node had when it recorded the event which is
+// being replayed, so blocks are validated against the same time as when they
+// were recorded.
+//
+// It implements the blockchain.MedianTimeSource interface.
+type traceClock struct {
+	mtx sync.Mutex
+	now time.Time
+}
+
+// Ensure the traceClock type implements the blockchain.MedianTimeSource
+// interface.
+var _ blockchain.MedianTimeSource = (*traceClock)(nil)
+
+// AdjustedTime returns the time of the trace record being replayed.
+//
+// This function is safe for concurrent access and is part of the
+// blockchain.MedianTimeSource interface.
+func (c *traceClock) AdjustedTime() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+// AddTimeSample ignores the time samples of peers since the time is determined
+// by the trace.
+//
+// This function is safe for concurrent access and is part of the
+// blockchain.MedianTimeSource interface.
+func (c *traceClock) AddTimeSample(id string, timeVal time.Time) {}
+
+// Offset always returns 0 since the time is determined by the trace.
+//
+// This function is safe for concurrent access and is part of the
+// blockchain.MedianTimeSource interface.
+func (c *traceClock) Offset() time.Duration {
+	return 0
+}
+
+// set sets the time reported by the clock.
+func (c *traceClock) set(now time.Time) {
+	c.mtx.Lock()
+	c.now = now
+	c.mtx.Unlock()
+}
+
+// newTraceClock returns a clock which reports the current time until it is set
+// to the time of the first replayed record.
+func newTraceClock() *traceClock {
+	return &traceClock{now: time.Unix(time.Now().Unix(), 0)}
+}
+
+// traceReplayConfig is a descriptor containing the functions a trace is
+// replayed with, which process its events on the node replaying it.
+type traceReplayConfig struct {
+	// Net is the bitcoin network the node replaying the trace is on.
+	Net wire.BitcoinNet
+
+	// Clock is the time source of the node replaying the trace.  It is set
+	// to the time of each record before the record is replayed.
+	Clock *traceClock
+
+	// Chain is the chain the trace is replayed against.  Its best block is
+	// logged once the trace was replayed.
+	Chain *blockchain.BlockChain
+
+	// ProcessBlock processes a block with the passed behavior flags.
+	ProcessBlock func(*btcutil.Block, blockchain.BehaviorFlags) (bool, error)
+
+	// ProcessTransaction processes a transaction with the passed tag.
+	ProcessTransaction func(*btcutil.Tx, mempool.Tag) error
+
+	// ProcessRPC processes an RPC call.  It will be nil when the RPC
+	// server is disabled, in which case the RPC calls can't be replayed.
+	ProcessRPC func(*parsedRPCCmd, <-chan struct{}) error
+}
+
+// traceReplayConfig returns the configuration to replay a trace against the
+// server, which must have been created with a traceClock and must not be
+// connected to any peers.
+func (s *server) traceReplayConfig() *traceReplayConfig {
+	cfg := &traceReplayConfig{
+		Net:          s.chainParams.Net,
+		Clock:        s.traceClock,
+		Chain:        s.chain,
+		ProcessBlock: s.syncManager.ProcessBlock,
+		ProcessTransaction: func(tx *btcutil.Tx, tag mempool.Tag) error {
+			acceptedTxs, err := s.txMemPool.ProcessTransaction(tx,
+				true, true, tag)
+			if err != nil {
+				return err
+			}
+			s.AnnounceNewTransactions(acceptedTxs)
+			return nil
+		},
+	}
+	if s.rpcServer != nil {
+		cfg.ProcessRPC = func(cmd *parsedRPCCmd, closeChan <-chan struct{}) error {
+			_, err := s.rpcServer.standardCmdResult(cmd, closeChan)
+			return err
+		}
+	}
+	return cfg
+}
+
+// replayTrace replays the trace file at the passed path with the passed
+// configuration.  The events are processed in the order they were recorded,
+// each one after the previous one was fully processed, and the blocks and
+// transactions with the same behavior flags and tags as when they were
+// recorded.  Events which fail, such as rejected blocks, are logged and do not
+// stop the replay since reproducing them is usually the point.
+//
+// An error is returned when the trace can't be read or does not belong to the
+// network of the configuration.  The replay stops early when an interrupt is
+// requested.
+func replayTrace(cfg *traceReplayConfig, path string, interrupt <-chan struct{}) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	net, err := readTraceHeader(r)
+	if err != nil {
+		return fmt.Errorf("unable to replay trace file %s: %v", path, err)
+	}
+	if net != cfg.Net {
+		return fmt.Errorf("unable to replay trace file %s: it was "+
+			"recorded on network %v", path, net)
+	}
+
+	btcdLog.Infof("Replaying trace file %s", path)
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	var replayed, failed uint64
+	for !interruptRequested(interrupt) {
+		record, err := readTraceRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF {
+			btcdLog.Warnf("Trace file ends with a truncated record " +
+				"which is skipped")
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read trace record %d: %v",
+				replayed+1, err)
+		}
+		replayed++
+
+		cfg.Clock.set(record.time)
+		procErr, err := replayTraceRecord(cfg, record, closeChan)
+		if err != nil {
+			return fmt.Errorf("unable to replay trace record %d: %v",
+				replayed, err)
+		}
+		if procErr != nil {
+			failed++
+			btcdLog.Infof("Trace record %d (%v from %s at %v) "+
+				"failed: %v", replayed, record.recordType,
+				record.source, record.time, procErr)
+		}
+	}
+
+	best := cfg.Chain.BestSnapshot()
+	btcdLog.Infof("Replayed %d trace records (%d failed), best block %v "+
+		"(height %d)", replayed, failed, best.Hash, best.Height)
+	return nil
+}
+
+// replayTraceRecord processes the event of the passed trace record with the
+// passed configuration.  The processing error is the result of processing it,
+// such as a rule violation, while the other error is returned when the record
+// can't be replayed at all.
+func replayTraceRecord(cfg *traceReplayConfig, record *traceRecord, closeChan <-chan struct{}) (procErr error, err error) {
+	switch record.recordType {
+	case traceBlock:
+		block, err := btcutil.NewBlockFromBytes(record.payload)
+		if err != nil {
+			return nil, err
+		}
+		// Blocks which are received out of order are held as orphans
+		// by the chain until their parent is replayed, just like when
+		// they were recorded.
+		_, err = cfg.ProcessBlock(block, record.flags)
+		return err, nil
+
+	case traceTx:
+		tx, err := btcutil.NewTxFromBytes(record.payload)
+		if err != nil {
+			return nil, err
+		}
+		return cfg.ProcessTransaction(tx, record.tag), nil
+
+	case traceRPC:
+		if cfg.ProcessRPC == nil {
+			return nil, errors.New("the RPC server must be " +
+				"enabled to replay RPC calls")
+		}
+		var request btcjson.Request
+		if err := json.Unmarshal(record.payload, &request); err != nil {
+			return nil, err
+		}
+		cmd := parseCmd(&request)
+		if cmd.err != nil {
+			return nil, cmd.err
+		}
+		return cfg.ProcessRPC(cmd, closeChan), nil
+	}
+
+	return nil, fmt.Errorf("unknown record type %d", record.recordType)
+}
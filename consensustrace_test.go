@@ -0,0 +1,325 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blockchain/fullblocktests"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestTraceRecorder ensures the recorded events are read back in order with
+// the time of the recording node, the events which do not change the chain or
+// memory pool are not recorded, and recording continues an existing trace.
+func TestTraceRecorder(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "tracerecorder")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "trace.dat")
+
+	clock := newTraceClock()
+	clock.set(time.Unix(1500000000, 0))
+	net := chaincfg.SimNetParams.Net
+	recorder, err := newTraceRecorder(path, net, clock)
+	if err != nil {
+		t.Fatalf("newTraceRecorder: %v", err)
+	}
+
+	block := btcutil.NewBlock(chaincfg.SimNetParams.GenesisBlock)
+	tx := block.Transactions()[0]
+	submitCmd := btcjson.NewSubmitBlockCmd("00", nil)
+	recorder.RecordBlock("127.0.0.1:18555", block, blockchain.BFFastAdd)
+	clock.set(time.Unix(1500000600, 0))
+	recorder.RecordTx("127.0.0.1:18555", tx, 7)
+	recorder.recordRPC("getinfo", btcjson.NewGetInfoCmd())
+	recorder.recordRPC("submitblock", submitCmd)
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Recording again appends to the trace, but only on the same network.
+	if _, err := newTraceRecorder(path, chaincfg.MainNetParams.Net,
+		clock); err == nil {
+
+		t.Fatal("newTraceRecorder unexpectedly accepted a trace of " +
+			"another network")
+	}
+	recorder, err = newTraceRecorder(path, net, clock)
+	if err != nil {
+		t.Fatalf("newTraceRecorder: %v", err)
+	}
+	recorder.processMinedBlock(func(*btcutil.Block, blockchain.BehaviorFlags) (bool, error) {
+		return false, nil
+	})(block, blockchain.BFNoPoWCheck)
+	recorder.Close()
+
+	serializedBlock, _ := block.Bytes()
+	var serializedTx bytes.Buffer
+	tx.MsgTx().Serialize(&serializedTx)
+	want := []traceRecord{
+		{traceBlock, time.Unix(1500000000, 0), blockchain.BFFastAdd, 0,
+			"127.0.0.1:18555", serializedBlock},
+		{traceTx, time.Unix(1500000600, 0), 0, 7, "127.0.0.1:18555",
+			serializedTx.Bytes()},
+		{traceRPC, time.Unix(1500000600, 0), 0, 0, traceRPCSource, nil},
+		{traceBlock, time.Unix(1500000600, 0), blockchain.BFNoPoWCheck,
+			0, traceMinerSource, serializedBlock},
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unable to open trace: %v", err)
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+	traceNet, err := readTraceHeader(r)
+	if err != nil || traceNet != net {
+		t.Fatalf("readTraceHeader: got %v (err %v), want %v", traceNet,
+			err, net)
+	}
+	for i, wantRecord := range want {
+		record, err := readTraceRecord(r)
+		if err != nil {
+			t.Fatalf("readTraceRecord #%d: %v", i, err)
+		}
+		if record.recordType != wantRecord.recordType ||
+			!record.time.Equal(wantRecord.time) ||
+			record.source != wantRecord.source {
+
+			t.Fatalf("readTraceRecord #%d: got %v from %s at %v, "+
+				"want %v from %s at %v", i, record.recordType,
+				record.source, record.time,
+				wantRecord.recordType, wantRecord.source,
+				wantRecord.time)
+		}
+		if record.flags != wantRecord.flags ||
+			record.tag != wantRecord.tag {
+
+			t.Fatalf("readTraceRecord #%d: got flags %v and tag "+
+				"%d, want flags %v and tag %d", i, record.flags,
+				record.tag, wantRecord.flags, wantRecord.tag)
+		}
+
+		// The RPC calls are compared once they are parsed since the
+		// JSON encoding is not part of the format.
+		if record.recordType == traceRPC {
+			var request btcjson.Request
+			err := json.Unmarshal(record.payload, &request)
+			if err != nil {
+				t.Fatalf("readTraceRecord #%d: invalid request: "+
+					"%v", i, err)
+			}
+			cmd := parseCmd(&request)
+			if cmd.err != nil || cmd.method != "submitblock" ||
+				!reflect.DeepEqual(cmd.cmd, submitCmd) {
+
+				t.Fatalf("readTraceRecord #%d: got %s %+v "+
+					"(err %v), want submitblock %+v", i,
+					cmd.method, cmd.cmd, cmd.err, submitCmd)
+			}
+			continue
+		}
+		if !bytes.Equal(record.payload, wantRecord.payload) {
+			t.Fatalf("readTraceRecord #%d: mismatched payload", i)
+		}
+	}
+	if _, err := readTraceRecord(r); err != io.EOF {
+		t.Fatalf("readTraceRecord at the end: got %v, want %v", err,
+			io.EOF)
+	}
+}
+
+// TestReadTraceRecordTruncated ensures a record which is cut off is reported as
+// an unexpected end of the trace wherever it ends.
+func TestReadTraceRecordTruncated(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	err := writeTraceRecord(&buf, &traceRecord{
+		recordType: traceTx,
+		time:       time.Unix(1500000000, 0),
+		tag:        1,
+		source:     "127.0.0.1:8333",
+		payload:    []byte{0x01, 0x02, 0x03},
+	})
+	if err != nil {
+		t.Fatalf("writeTraceRecord: %v", err)
+	}
+	serialized := buf.Bytes()
+
+	for i := 1; i < len(serialized); i++ {
+		_, err := readTraceRecord(bytes.NewReader(serialized[:i]))
+		if err != io.ErrUnexpectedEOF {
+			t.Fatalf("readTraceRecord with %d of %d bytes: got %v, "+
+				"want %v", i, len(serialized), err,
+				io.ErrUnexpectedEOF)
+		}
+	}
+
+	// Files which are not traces are rejected.
+	_, err = readTraceHeader(bytes.NewReader(serialized))
+	if err == nil {
+		t.Fatal("readTraceHeader unexpectedly accepted a record")
+	}
+	var header bytes.Buffer
+	writeTraceHeader(&header, wire.TestNet3)
+	traceNet, err := readTraceHeader(&header)
+	if err != nil || traceNet != wire.TestNet3 {
+		t.Fatalf("readTraceHeader: got %v (err %v), want %v", traceNet,
+			err, wire.TestNet3)
+	}
+}
+
+// newTraceTestChain returns a chain on the regression test network backed by a
+// new database in the passed directory and using the passed time source, along
+// with a function which closes the database.
+func newTraceTestChain(t *testing.T, dir string, timeSource blockchain.MedianTimeSource) (*blockchain.BlockChain, func()) {
+	params := &chaincfg.RegressionNetParams
+	db, err := database.Create("ffldb", dir, params.Net)
+	if err != nil {
+		t.Fatalf("unable to create db: %v", err)
+	}
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: params,
+		TimeSource:  timeSource,
+		SigCache:    txscript.NewSigCache(1000),
+	})
+	if err != nil {
+		db.Close()
+		t.Fatalf("unable to create chain: %v", err)
+	}
+	return chain, func() { db.Close() }
+}
+
+// TestTraceReplay ensures replaying a recorded trace against a fresh chain
+// processes the blocks and transactions with the flags and tags they were
+// recorded with and reproduces the state of the recording chain.
+func TestTraceReplay(t *testing.T) {
+	// The replay logs its progress, but the tests don't set up the log
+	// rotator the loggers write to.
+	setLogLevels("off")
+	defer setLogLevels("info")
+
+	dir, err := ioutil.TempDir("", "tracereplay")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "trace.dat")
+
+	// Record the blocks generated by the full block tests, which include
+	// rejected, orphan, and side chain blocks, while processing them.  The
+	// blocks extending the main chain are fast added so the flags matter.
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+	timeSource := blockchain.NewMedianTime()
+	recordChain, closeDB := newTraceTestChain(t,
+		filepath.Join(dir, "record"), timeSource)
+	defer closeDB()
+	net := chaincfg.RegressionNetParams.Net
+	recorder, err := newTraceRecorder(path, net, timeSource)
+	if err != nil {
+		t.Fatalf("newTraceRecorder: %v", err)
+	}
+	var recordedFlags []blockchain.BehaviorFlags
+	for _, testInstances := range tests {
+		for _, item := range testInstances {
+			var msgBlock *wire.MsgBlock
+			flags := blockchain.BFNone
+			switch item := item.(type) {
+			case fullblocktests.AcceptedBlock:
+				msgBlock = item.Block
+				if item.IsMainChain {
+					flags = blockchain.BFFastAdd
+				}
+			case fullblocktests.RejectedBlock:
+				msgBlock = item.Block
+			case fullblocktests.OrphanOrRejectedBlock:
+				msgBlock = item.Block
+			default:
+				continue
+			}
+			block := btcutil.NewBlock(msgBlock)
+			recorder.RecordBlock("127.0.0.1:18444", block, flags)
+			recordedFlags = append(recordedFlags, flags)
+			recordChain.ProcessBlock(block, flags)
+		}
+	}
+	tx := btcutil.NewTx(chaincfg.RegressionNetParams.GenesisBlock.Transactions[0])
+	recorder.RecordTx("127.0.0.1:18444", tx, 7)
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Replay the trace against a fresh chain.
+	clock := newTraceClock()
+	replayChain, closeDB := newTraceTestChain(t,
+		filepath.Join(dir, "replay"), clock)
+	defer closeDB()
+	var replayedFlags []blockchain.BehaviorFlags
+	var replayedTags []mempool.Tag
+	cfg := &traceReplayConfig{
+		Net:   net,
+		Clock: clock,
+		Chain: replayChain,
+		ProcessBlock: func(block *btcutil.Block, flags blockchain.BehaviorFlags) (bool, error) {
+			replayedFlags = append(replayedFlags, flags)
+			_, isOrphan, err := replayChain.ProcessBlock(block, flags)
+			return isOrphan, err
+		},
+		ProcessTransaction: func(tx *btcutil.Tx, tag mempool.Tag) error {
+			replayedTags = append(replayedTags, tag)
+			return nil
+		},
+	}
+	if err := replayTrace(cfg, path, nil); err != nil {
+		t.Fatalf("replayTrace: %v", err)
+	}
+
+	if !reflect.DeepEqual(replayedFlags, recordedFlags) {
+		t.Fatalf("replayTrace: replayed %d blocks with flags different "+
+			"from the %d recorded", len(replayedFlags),
+			len(recordedFlags))
+	}
+	if !reflect.DeepEqual(replayedTags, []mempool.Tag{7}) {
+		t.Fatalf("replayTrace: got tags %v, want [7]", replayedTags)
+	}
+	recordBest := recordChain.BestSnapshot()
+	replayBest := replayChain.BestSnapshot()
+	if replayBest.Hash != recordBest.Hash ||
+		replayBest.Height != recordBest.Height ||
+		replayBest.TotalTxns != recordBest.TotalTxns {
+
+		t.Fatalf("replayTrace: got best block %v (height %d, %d txns), "+
+			"want %v (height %d, %d txns)", replayBest.Hash,
+			replayBest.Height, replayBest.TotalTxns, recordBest.Hash,
+			recordBest.Height, recordBest.TotalTxns)
+	}
+}
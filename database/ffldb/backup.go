@@ -0,0 +1,411 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains support for taking snapshots of the metadata database and
+// restoring them.  Since the flat files that house the blocks are append-only,
+// a snapshot of the metadata along with the flat files is enough to restore a
+// database to the state it was in when the snapshot was taken.
+
+package ffldb
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/goleveldb/leveldb"
+	"github.com/btcsuite/goleveldb/leveldb/opt"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+)
+
+// snapshotBatchSize is the approximate number of bytes of keys and values
+// written to a metadata snapshot per leveldb batch.
+const snapshotBatchSize = 4 * 1024 * 1024 // 4 MiB
+
+// SnapshotMetadata writes a consistent copy of the metadata of the provided
+// database, which must be an ffldb database, to a new leveldb database at the
+// provided path.  The snapshot reflects the state of the database as of the
+// most recently committed transaction at the time it is taken.
+//
+// The copy is first written to a temporary path next to the provided path and
+// then renamed, so the snapshot either exists in full or not at all.
+func SnapshotMetadata(idb database.DB, destPath string) error {
+	pdb, isFfldb := idb.(*db)
+	if !isFfldb {
+		str := "metadata snapshots are only supported by ffldb databases"
+		return makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+
+	tx, err := pdb.begin(false)
+	if err != nil {
+		return err
+	}
+	defer tx.close()
+
+	// The snapshot may reference block data which has been written to the
+	// flat files, but not yet synced since the metadata has not been
+	// flushed.  Sync it now so a snapshot never references block data which
+	// could be lost in an unclean shutdown.
+	if err := pdb.store.syncBlocks(); err != nil {
+		return err
+	}
+
+	tmpPath := destPath + ".tmp"
+	_ = os.RemoveAll(tmpPath)
+	opts := opt.Options{
+		ErrorIfExist: true,
+		Strict:       opt.DefaultStrict,
+		Compression:  opt.NoCompression,
+	}
+	ldb, err := leveldb.OpenFile(tmpPath, &opts)
+	if err != nil {
+		return convertErr(err.Error(), err)
+	}
+
+	// Copy all keys in batches.
+	iter := tx.snapshot.NewIterator(&util.Range{})
+	batch := new(leveldb.Batch)
+	var batchBytes int
+	for ok := iter.First(); ok; ok = iter.Next() {
+		key, value := iter.Key(), iter.Value()
+		batch.Put(key, value)
+		batchBytes += len(key) + len(value)
+		if batchBytes < snapshotBatchSize {
+			continue
+		}
+		if err = ldb.Write(batch, nil); err != nil {
+			break
+		}
+		batch.Reset()
+		batchBytes = 0
+	}
+	iter.Release()
+	if err == nil {
+		err = ldb.Write(batch, nil)
+	}
+	if closeErr := ldb.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.RemoveAll(tmpPath)
+		str := fmt.Sprintf("failed to write metadata snapshot: %v", err)
+		return convertErr(str, err)
+	}
+
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		_ = os.RemoveAll(tmpPath)
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	return nil
+}
+
+// Flush forces the database cache of the provided database, which must be an
+// ffldb database, to be flushed to the metadata database and the block files
+// to be synced to disk.  It blocks new write transactions until it is done and
+// only returns once all previously committed transactions are durable, so the
+// files of the database can be copied or snapshotted at the filesystem level
+// in a consistent state for as long as no further writes are made.
+func Flush(idb database.DB) error {
+	pdb, isFfldb := idb.(*db)
+	if !isFfldb {
+		str := "flushing is only supported by ffldb databases"
+		return makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+
+	// Grab the write lock to ensure no write transaction is active or
+	// started while flushing, along with a read lock to prevent the
+	// database from being closed.
+	pdb.writeLock.Lock()
+	defer pdb.writeLock.Unlock()
+	pdb.closeLock.RLock()
+	defer pdb.closeLock.RUnlock()
+	if pdb.closed {
+		return makeDbErr(database.ErrDbNotOpen, errDbNotOpenStr, nil)
+	}
+
+	// The leveldb transaction used to flush the cache syncs the tables and
+	// manifest it writes, so the metadata is durable once it returns.  The
+	// block files are synced by the flush even when the cache is empty.
+	return pdb.cache.flush()
+}
+
+// snapshotWriteCursor returns the block file write cursor stored in the
+// metadata snapshot at the provided path.  It also serves to ensure the
+// snapshot is usable since it is opened the same way as the metadata database.
+func snapshotWriteCursor(snapshotPath string) (uint32, uint32, error) {
+	opts := opt.Options{
+		ErrorIfMissing: true,
+		ReadOnly:       true,
+		Strict:         opt.DefaultStrict,
+	}
+	ldb, err := leveldb.OpenFile(snapshotPath, &opts)
+	if err != nil {
+		return 0, 0, convertErr(err.Error(), err)
+	}
+	defer ldb.Close()
+
+	writeRow, err := ldb.Get(bucketizedKey(metadataBucketID,
+		writeLocKeyName), nil)
+	if err != nil {
+		str := "metadata snapshot does not contain a write cursor"
+		return 0, 0, makeDbErr(database.ErrCorruption, str, err)
+	}
+	if len(writeRow) != 12 {
+		str := "metadata snapshot write cursor is malformed"
+		return 0, 0, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	return deserializeWriteRow(writeRow)
+}
+
+// copyFile copies the file at the source path to the destination path and
+// syncs it to disk.
+func copyFile(srcPath, destPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dest, src); err != nil {
+		dest.Close()
+		return err
+	}
+	if err := dest.Sync(); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
+}
+
+// RestoreMetadata replaces the metadata of the ffldb database at the provided
+// path with the metadata snapshot at the provided snapshot path.  The database
+// must not be open.
+//
+// Any block data written to the flat files after the snapshot was taken is not
+// referenced by the restored metadata, so it is moved to flat files in the
+// provided replay path before the metadata is replaced.  The moved blocks can
+// be read with a BlockFileScanner for the replay path and must be processed
+// again to bring the database back up to date.  The final block record in the
+// replay path might be incomplete when the database was not shut down cleanly.
+//
+// The snapshot is checked before the database is modified, so ErrCorruption is
+// returned without making any changes when the snapshot is not usable or refers
+// to block data which does not exist.  The previous metadata is kept next to
+// the restored metadata with an .old suffix.
+//
+// The oldest flat files are in the provided cold path when it is not empty.
+// Since they are never modified, snapshots which were taken before the final
+// block in the cold path was written are not usable.
+func RestoreMetadata(dbPath, coldPath, snapshotPath, replayPath string) error {
+	curFileNum, curOffset, err := snapshotWriteCursor(snapshotPath)
+	if err != nil {
+		return err
+	}
+
+	// Ensure the snapshot references all of the cold block data.
+	flatFiles := newBlockFiles(dbPath, coldPath)
+	coldFileNum, coldOffset := flatFiles.coldEnd()
+	if curFileNum < coldFileNum || (curFileNum == coldFileNum &&
+		curOffset < coldOffset) {
+
+		str := fmt.Sprintf("metadata snapshot claims file %d, offset "+
+			"%d, but the cold block files end at file %d, offset "+
+			"%d", curFileNum, curOffset, coldFileNum, coldOffset)
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	// Ensure the block data referenced by the snapshot exists.
+	lastFile, lastFileLen := flatFiles.scan()
+	if lastFile < int(curFileNum) || (lastFile == int(curFileNum) &&
+		lastFileLen < curOffset) {
+
+		str := fmt.Sprintf("metadata snapshot claims file %d, offset "+
+			"%d, but block data ends at file %d, offset %d",
+			curFileNum, curOffset, lastFile, lastFileLen)
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	// Replace the metadata with a copy of the snapshot, so the snapshot
+	// remains available should the restored metadata become corrupted
+	// again.
+	metadataPath := filepath.Join(dbPath, metadataDbName)
+	newPath := metadataPath + ".new"
+	_ = os.RemoveAll(newPath)
+	if err := os.MkdirAll(newPath, 0700); err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	files, err := ioutil.ReadDir(snapshotPath)
+	if err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		err := copyFile(filepath.Join(snapshotPath, file.Name()),
+			filepath.Join(newPath, file.Name()))
+		if err != nil {
+			str := fmt.Sprintf("failed to copy metadata snapshot: %v",
+				err)
+			return makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+	}
+	if err := moveMetadataAside(dbPath); err != nil {
+		return err
+	}
+	if err := os.Rename(newPath, metadataPath); err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+
+	// Move the block data written after the snapshot was taken to the
+	// replay path.
+	return moveBlocksToReplay(flatFiles, curFileNum, curOffset, lastFile,
+		replayPath)
+}
+
+// ResetMetadata discards the metadata of the ffldb database at the provided
+// path, which must not be open, and moves all of its block data to flat files in
+// the provided replay path.  This is the last resort when the metadata can't be
+// restored from a snapshot.  The database must then be created again, after
+// which the moved blocks can be read with a BlockFileScanner for the replay
+// path and processed again to rebuild it.
+//
+// The previous metadata is kept next to the database path with an .old suffix.
+//
+// The database can't be rebuilt while its oldest flat files are in the provided
+// cold path, since the files are never modified, so an error is returned
+// without making any changes when it contains any.
+func ResetMetadata(dbPath, coldPath, replayPath string) error {
+	files := newBlockFiles(dbPath, coldPath)
+	if files.numCold > 0 {
+		str := fmt.Sprintf("unable to rebuild the database while the "+
+			"cold block path %q contains block files -- move them "+
+			"back to %q first", coldPath, dbPath)
+		return makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+
+	if err := moveMetadataAside(dbPath); err != nil {
+		return err
+	}
+	lastFile, _ := files.scan()
+	err := moveBlocksToReplay(files, 0, 0, lastFile, replayPath)
+	if err != nil {
+		return err
+	}
+
+	// The first file is left empty, so remove it as well.
+	err = os.Remove(blockFilePath(dbPath, 0))
+	if err != nil && !os.IsNotExist(err) {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	return nil
+}
+
+// moveMetadataAside renames the metadata of the database at the provided path
+// so it has an .old suffix, replacing any metadata previously moved aside.
+// Nothing is done when the metadata does not exist.
+func moveMetadataAside(dbPath string) error {
+	metadataPath := filepath.Join(dbPath, metadataDbName)
+	oldPath := metadataPath + ".old"
+	_ = os.RemoveAll(oldPath)
+	if err := os.Rename(metadataPath, oldPath); err != nil &&
+		!os.IsNotExist(err) {
+
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	return nil
+}
+
+// moveBlocksToReplay moves the block data in the provided flat files which
+// follows the provided write cursor position to flat files in the provided
+// replay path, after any which are already there.  The remainder of the file
+// the write cursor is in is copied and truncated, while later files up to and
+// including the provided last file are moved as a whole.  The write cursor must
+// not be before the end of the cold files.
+func moveBlocksToReplay(files *blockFiles, curFileNum, curOffset uint32,
+	lastFile int, replayPath string) error {
+
+	if err := os.MkdirAll(replayPath, 0700); err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	replayFileNum, _ := scanBlockFiles(replayPath)
+	nextReplayPath := func() string {
+		replayFileNum++
+		return blockFilePath(replayPath, uint32(replayFileNum))
+	}
+	if !files.isCold(curFileNum) {
+		err := moveFileTail(files.path(curFileNum), curOffset,
+			nextReplayPath)
+		if err != nil {
+			str := fmt.Sprintf("failed to move block data to "+
+				"replay: %v", err)
+			return makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+	}
+	for fileNum := curFileNum + 1; int(fileNum) <= lastFile; fileNum++ {
+		err := os.Rename(files.path(fileNum), nextReplayPath())
+		if err != nil {
+			str := fmt.Sprintf("failed to move block data to "+
+				"replay: %v", err)
+			return makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+	}
+
+	return nil
+}
+
+// moveFileTail moves the data of the file at the provided path which follows
+// the provided offset to a new file at the path returned by the provided
+// function and truncates the file to the offset.  Nothing is done when there
+// is no data after the offset.
+func moveFileTail(filePath string, offset uint32, destPath func() string) error {
+	file, err := os.OpenFile(filePath, os.O_RDWR, 0600)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	st, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if st.Size() <= int64(offset) {
+		return nil
+	}
+
+	dest, err := os.OpenFile(destPath(), os.O_WRONLY|os.O_CREATE|
+		os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	tail := io.NewSectionReader(file, int64(offset),
+		st.Size()-int64(offset))
+	if _, err := io.Copy(dest, tail); err != nil {
+		dest.Close()
+		return err
+	}
+	if err := dest.Sync(); err != nil {
+		dest.Close()
+		return err
+	}
+	if err := dest.Close(); err != nil {
+		return err
+	}
+
+	if err := file.Truncate(int64(offset)); err != nil {
+		return err
+	}
+	return file.Sync()
+}
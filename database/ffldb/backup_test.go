@@ -0,0 +1,331 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is part of the ffldb package rather than the ffldb_test package as
+// it provides whitebox testing.
+
+package ffldb
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestMetadataSnapshot ensures restoring a metadata snapshot rolls the database
+// back to the state it was in when the snapshot was taken and moves the blocks
+// stored afterwards to the replay path in the order they were written.
+func TestMetadataSnapshot(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "ffldb-snapshottest")
+	_ = os.RemoveAll(testDir)
+	defer os.RemoveAll(testDir)
+	dbPath := filepath.Join(testDir, "db")
+	snapshotPath := filepath.Join(testDir, "snapshot")
+	replayPath := filepath.Join(testDir, "replay")
+
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		idb.Close()
+		t.Fatalf("Unable to load blocks from test data: %v", err)
+	}
+
+	// Use a small maximum file size so the blocks stored after the snapshot
+	// span several flat files.
+	idb.(*db).store.maxBlockFileSize = 8192
+	storeBlocks := func(blocks []*btcutil.Block, key string) error {
+		return idb.Update(func(tx database.Tx) error {
+			for _, block := range blocks {
+				if err := tx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			return tx.Metadata().Put([]byte(key), []byte(key))
+		})
+	}
+	split := len(blocks) / 2
+	if err := storeBlocks(blocks[:split], "before"); err != nil {
+		idb.Close()
+		t.Fatalf("Failed to store blocks: %v", err)
+	}
+	if err := SnapshotMetadata(idb, snapshotPath); err != nil {
+		idb.Close()
+		t.Fatalf("SnapshotMetadata: unexpected error: %v", err)
+	}
+	if err := storeBlocks(blocks[split:], "after"); err != nil {
+		idb.Close()
+		t.Fatalf("Failed to store blocks: %v", err)
+	}
+	if err := idb.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+
+	// Restoring a snapshot which does not exist must not modify the
+	// database.
+	err = RestoreMetadata(dbPath, "", filepath.Join(testDir, "noexist"),
+		replayPath)
+	if err == nil {
+		t.Fatal("RestoreMetadata: did not return an error for missing " +
+			"snapshot")
+	}
+	if err := RestoreMetadata(dbPath, "", snapshotPath, replayPath); err != nil {
+		t.Fatalf("RestoreMetadata: unexpected error: %v", err)
+	}
+
+	idb, err = database.Open(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to open restored database: %v", err)
+	}
+	defer idb.Close()
+	err = idb.View(func(tx database.Tx) error {
+		for i, block := range blocks {
+			hasBlock, err := tx.HasBlock(block.Hash())
+			if err != nil {
+				return err
+			}
+			if hasBlock != (i < split) {
+				t.Errorf("HasBlock #%d: got %v, want %v", i,
+					hasBlock, i < split)
+			}
+		}
+		if tx.Metadata().Get([]byte("before")) == nil ||
+			tx.Metadata().Get([]byte("after")) != nil {
+
+			t.Error("unexpected metadata after restore")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+
+	// The blocks stored after the snapshot must be in the replay path.
+	scanner := NewBlockFileScanner(replayPath, blockDataNet)
+	defer scanner.Close()
+	for i, block := range blocks[split:] {
+		sb, err := scanner.Next()
+		if err != nil {
+			t.Fatalf("Next #%d: unexpected error: %v", i, err)
+		}
+		if sb.Hash != *block.Hash() {
+			t.Fatalf("Next #%d: unexpected hash - got %v, want %v",
+				i, sb.Hash, block.Hash())
+		}
+	}
+	if _, err := scanner.Next(); err != io.EOF {
+		t.Fatalf("Next: unexpected error at end - got %v, want %v",
+			err, io.EOF)
+	}
+}
+
+// TestFlush ensures flushing writes the cached metadata to the underlying
+// leveldb database and fails once the database is closed.
+func TestFlush(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(os.TempDir(), "ffldb-flushtest")
+	_ = os.RemoveAll(dbPath)
+	defer os.RemoveAll(dbPath)
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+
+	key := []byte("flushkey")
+	err = idb.Update(func(tx database.Tx) error {
+		return tx.Metadata().Put(key, key)
+	})
+	if err != nil {
+		idb.Close()
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+	pdb := idb.(*db)
+	if pdb.cache.cachedKeys.Len() == 0 {
+		idb.Close()
+		t.Fatal("metadata was not cached")
+	}
+
+	if err := Flush(idb); err != nil {
+		idb.Close()
+		t.Fatalf("Flush: unexpected error: %v", err)
+	}
+	if pdb.cache.cachedKeys.Len() != 0 {
+		idb.Close()
+		t.Fatal("cache not empty after flush")
+	}
+	ldbKey := bucketizedKey(metadataBucketID, key)
+	if _, err := pdb.cache.ldb.Get(ldbKey, nil); err != nil {
+		idb.Close()
+		t.Fatalf("metadata not in leveldb after flush: %v", err)
+	}
+
+	if err := idb.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+	checkDbError(t, "Flush", Flush(idb), database.ErrDbNotOpen)
+}
+
+// TestCorruptionDetection ensures opening a database with damaged block data or
+// missing metadata reports corruption and that resetting the metadata moves all
+// blocks to the replay path.
+func TestCorruptionDetection(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "ffldb-corruptiontest")
+	_ = os.RemoveAll(testDir)
+	defer os.RemoveAll(testDir)
+	dbPath := filepath.Join(testDir, "db")
+	replayPath := filepath.Join(testDir, "replay")
+
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		idb.Close()
+		t.Fatalf("Unable to load blocks from test data: %v", err)
+	}
+	idb.(*db).store.maxBlockFileSize = 8192
+	err = idb.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	idb.Close()
+	if err != nil {
+		t.Fatalf("Failed to store blocks: %v", err)
+	}
+
+	// flipLastByte flips the bits of the final byte of the most recent flat
+	// file, which is part of the checksum of the last block.
+	lastFile, fileLen := scanBlockFiles(dbPath)
+	flipLastByte := func() {
+		f, err := os.OpenFile(blockFilePath(dbPath, uint32(lastFile)),
+			os.O_RDWR, 0600)
+		if err != nil {
+			t.Fatalf("OpenFile: unexpected error: %v", err)
+		}
+		defer f.Close()
+		var b [1]byte
+		if _, err := f.ReadAt(b[:], int64(fileLen)-1); err != nil {
+			t.Fatalf("ReadAt: unexpected error: %v", err)
+		}
+		b[0] = ^b[0]
+		if _, err := f.WriteAt(b[:], int64(fileLen)-1); err != nil {
+			t.Fatalf("WriteAt: unexpected error: %v", err)
+		}
+	}
+
+	// A damaged last block must be detected, and the failed open must not
+	// leave the database locked.
+	flipLastByte()
+	_, err = database.Open(dbType, dbPath, blockDataNet)
+	if !checkDbError(t, "Open damaged block", err, database.ErrCorruption) {
+		return
+	}
+	err = VerifyBlockFiles(dbPath, "", blockDataNet)
+	if err != nil {
+		t.Fatalf("VerifyBlockFiles: unexpected error for damaged "+
+			"final block: %v", err)
+	}
+	flipLastByte()
+	idb, err = database.Open(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to open repaired database: %v", err)
+	}
+	idb.Close()
+
+	// Damage to an earlier block goes unnoticed when the database is
+	// opened, but must be detected by a full verification.
+	flipFirstFileByte := func() {
+		path := blockFilePath(dbPath, 0)
+		st, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat: unexpected error: %v", err)
+		}
+		f, err := os.OpenFile(path, os.O_RDWR, 0600)
+		if err != nil {
+			t.Fatalf("OpenFile: unexpected error: %v", err)
+		}
+		defer f.Close()
+		var b [1]byte
+		if _, err := f.ReadAt(b[:], st.Size()-1); err != nil {
+			t.Fatalf("ReadAt: unexpected error: %v", err)
+		}
+		b[0] = ^b[0]
+		if _, err := f.WriteAt(b[:], st.Size()-1); err != nil {
+			t.Fatalf("WriteAt: unexpected error: %v", err)
+		}
+	}
+	flipFirstFileByte()
+	idb, err = database.Open(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to open database with damaged earlier "+
+			"block: %v", err)
+	}
+	idb.Close()
+	err = VerifyBlockFiles(dbPath, "", blockDataNet)
+	if !checkDbError(t, "VerifyBlockFiles damaged block", err,
+		database.ErrCorruption) {
+
+		return
+	}
+	flipFirstFileByte()
+	if err := VerifyBlockFiles(dbPath, "", blockDataNet); err != nil {
+		t.Fatalf("VerifyBlockFiles: unexpected error: %v", err)
+	}
+
+	// Missing metadata must be detected when block data exists.
+	metadataPath := filepath.Join(dbPath, metadataDbName)
+	if err := os.Rename(metadataPath, metadataPath+".moved"); err != nil {
+		t.Fatalf("Rename: unexpected error: %v", err)
+	}
+	_, err = database.Open(dbType, dbPath, blockDataNet)
+	if !checkDbError(t, "Open missing metadata", err,
+		database.ErrCorruption) {
+
+		return
+	}
+
+	// Resetting the metadata must leave an empty database path behind and
+	// move all blocks to the replay path.
+	if err := ResetMetadata(dbPath, "", replayPath); err != nil {
+		t.Fatalf("ResetMetadata: unexpected error: %v", err)
+	}
+	_, err = database.Open(dbType, dbPath, blockDataNet)
+	if !checkDbError(t, "Open after reset", err,
+		database.ErrDbDoesNotExist) {
+
+		return
+	}
+	var numBlocks int
+	err = ForEachBlock(replayPath, blockDataNet, BlockLocation{},
+		func(sb *ScannedBlock, _ func() ([]byte, error)) error {
+			if sb.Hash != *blocks[numBlocks].Hash() {
+				t.Fatalf("unexpected replay block #%d", numBlocks)
+			}
+			numBlocks++
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("ForEachBlock: unexpected error: %v", err)
+	}
+	if numBlocks != len(blocks) {
+		t.Fatalf("replay path has %d blocks, want %d", numBlocks,
+			len(blocks))
+	}
+}
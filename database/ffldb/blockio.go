@@ -111,6 +111,10 @@ type blockStore struct {
 	// basePath is the base path used for the flat block files and metadata.
 	basePath string
 
+	// files locates the flat block files, which may be split between the
+	// base path and a cold directory.
+	files *blockFiles
+
 	// maxBlockFileSize is the maximum size for each file used to store
 	// blocks.  It is defined on the store so the whitebox tests can
 	// override the value.
@@ -174,6 +178,10 @@ type blockStore struct {
 	openFileFunc      func(fileNum uint32) (*lockableFile, error)
 	openWriteFileFunc func(fileNum uint32) (filer, error)
 	deleteFileFunc    func(fileNum uint32) error
+
+	// stats houses the I/O counters of the database.  It is shared with
+	// the database cache.
+	stats *ioStats
 }
 
 // blockLocation identifies a particular block file and location.
@@ -232,7 +240,7 @@ func (s *blockStore) openWriteFile(fileNum uint32) (filer, error) {
 	// The current block file needs to be read-write so it is possible to
 	// append to it.  Also, it shouldn't be part of the least recently used
 	// file.
-	filePath := blockFilePath(s.basePath, fileNum)
+	filePath := s.files.path(fileNum)
 	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0666)
 	if err != nil {
 		str := fmt.Sprintf("failed to open file %q: %v", filePath, err)
@@ -251,7 +259,7 @@ func (s *blockStore) openWriteFile(fileNum uint32) (filer, error) {
 // for WRITES.
 func (s *blockStore) openFile(fileNum uint32) (*lockableFile, error) {
 	// Open the appropriate file as read-only.
-	filePath := blockFilePath(s.basePath, fileNum)
+	filePath := s.files.path(fileNum)
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, makeDbErr(database.ErrDriverSpecific, err.Error(),
@@ -299,7 +307,14 @@ func (s *blockStore) openFile(fileNum uint32) (*lockableFile, error) {
 // must already be closed and it is the responsibility of the caller to do any
 // other state cleanup necessary.
 func (s *blockStore) deleteFile(fileNum uint32) error {
-	filePath := blockFilePath(s.basePath, fileNum)
+	// Files in the cold directory are never modified.
+	if s.files.isCold(fileNum) {
+		str := fmt.Sprintf("refusing to delete cold block file %d",
+			fileNum)
+		return makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+
+	filePath := s.files.path(fileNum)
 	if err := os.Remove(filePath); err != nil {
 		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
 	}
@@ -407,7 +422,8 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 	fullLen := blockLen + 12
 
 	// Move to the next block file if adding the new block would exceed the
-	// max allowed size for the current block file.  Also detect overflow
+	// max allowed size for the current block file or the current file is in
+	// the cold directory, which is never written to.  Also detect overflow
 	// to be paranoid, even though it isn't possible currently, numbers
 	// might change in the future to make it possible.
 	//
@@ -417,7 +433,9 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 	// a time.
 	wc := s.writeCursor
 	finalOffset := wc.curOffset + fullLen
-	if finalOffset < wc.curOffset || finalOffset > s.maxBlockFileSize {
+	if finalOffset < wc.curOffset || finalOffset > s.maxBlockFileSize ||
+		s.files.isCold(wc.curFileNum) {
+
 		// This is done under the write cursor lock since the curFileNum
 		// field is accessed elsewhere by readers.
 		//
@@ -484,6 +502,7 @@ func (s *blockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
 		return blockLocation{}, err
 	}
 
+	s.stats.recordBlockWrite(fullLen)
 	loc := blockLocation{
 		blockFileNum: wc.curFileNum,
 		fileOffset:   origOffset,
@@ -682,6 +701,12 @@ func (s *blockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
 		}
 	}
 
+	// Files in the cold directory are never written to, so there is
+	// nothing to truncate when the rollback point is in one.
+	if s.files.isCold(wc.curFileNum) {
+		return
+	}
+
 	// Open the file for the current write cursor if needed.
 	wc.curFile.Lock()
 	if wc.curFile.file == nil {
@@ -701,6 +726,7 @@ func (s *blockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
 			wc.curFileNum, err)
 		return
 	}
+	s.stats.recordTruncation()
 
 	// Sync the file to disk.
 	err := wc.curFile.file.Sync()
@@ -718,9 +744,16 @@ func (s *blockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
 // to detect unexpected shutdowns in the middle of writes so the block files
 // can be reconciled.
 func scanBlockFiles(dbPath string) (int, uint32) {
+	return scanBlockFilesFrom(dbPath, 0)
+}
+
+// scanBlockFilesFrom searches the passed directory for the consecutive flat
+// block files starting with the passed file number and returns the number and
+// length of the last one.  The number is -1 when the first file doesn't exist.
+func scanBlockFilesFrom(dbPath string, firstFile uint32) (int, uint32) {
 	lastFile := -1
 	fileLen := uint32(0)
-	for i := 0; ; i++ {
+	for i := int(firstFile); ; i++ {
 		filePath := blockFilePath(dbPath, uint32(i))
 		st, err := os.Stat(filePath)
 		if err != nil {
@@ -737,12 +770,14 @@ func scanBlockFiles(dbPath string) (int, uint32) {
 }
 
 // newBlockStore returns a new block store with the current block file number
-// and offset set and all fields initialized.
-func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
+// and offset set and all fields initialized.  The oldest flat files are read
+// from the passed cold path when it is not empty.
+func newBlockStore(basePath, coldPath string, network wire.BitcoinNet) *blockStore {
 	// Look for the end of the latest block to file to determine what the
 	// write cursor position is from the viewpoing of the block files on
 	// disk.
-	fileNum, fileOff := scanBlockFiles(basePath)
+	files := newBlockFiles(basePath, coldPath)
+	fileNum, fileOff := files.scan()
 	if fileNum == -1 {
 		fileNum = 0
 		fileOff = 0
@@ -751,6 +786,7 @@ func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
 	store := &blockStore{
 		network:          network,
 		basePath:         basePath,
+		files:            files,
 		maxBlockFileSize: maxBlockFileSize,
 		openBlockFiles:   make(map[uint32]*lockableFile),
 		openBlocksLRU:    list.New(),
@@ -761,6 +797,7 @@ func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
 			curFileNum: uint32(fileNum),
 			curOffset:  fileOff,
 		},
+		stats: &ioStats{},
 	}
 	store.openFileFunc = store.openFile
 	store.openWriteFileFunc = store.openWriteFile
@@ -0,0 +1,94 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the support for keeping the oldest flat block files in a
+// separate directory from the recent ones.
+
+package ffldb
+
+import (
+	"os"
+)
+
+// blockFiles locates the flat block files of a database.  The files are
+// normally all stored in the database directory, however the oldest ones may
+// be moved to a separate cold directory, such as a network mount or another
+// slow or read-only volume, so only the recent files, which are read the most
+// and are the only ones written to, take up fast local storage.  This allows
+// cheap archival nodes which keep every block.
+//
+// The cold directory holds the files numbered from zero up to, but not
+// including, numCold, and the database directory holds the files from numCold
+// onwards.  Files in the cold directory are never modified, so new blocks are
+// always written to a file in the database directory.
+type blockFiles struct {
+	hotPath  string
+	coldPath string
+	numCold  uint32
+}
+
+// newBlockFiles returns the flat block files of the database at the passed path
+// with the oldest files in the passed cold path.  All files are in the database
+// path when the cold path is empty.
+func newBlockFiles(hotPath, coldPath string) *blockFiles {
+	files := &blockFiles{hotPath: hotPath, coldPath: coldPath}
+	if coldPath != "" {
+		lastFile, _ := scanBlockFilesFrom(coldPath, 0)
+		files.numCold = uint32(lastFile + 1)
+	}
+	return files
+}
+
+// isCold returns whether the passed flat file number refers to a file in the
+// cold directory.
+func (f *blockFiles) isCold(fileNum uint32) bool {
+	return fileNum < f.numCold
+}
+
+// path returns the file path for the passed flat file number.
+func (f *blockFiles) path(fileNum uint32) string {
+	if f.isCold(fileNum) {
+		return blockFilePath(f.coldPath, fileNum)
+	}
+	return blockFilePath(f.hotPath, fileNum)
+}
+
+// coldEnd returns the file number and offset of the end of the data in the cold
+// directory.  Block data before it can't be modified.  It is zero when there
+// are no cold files.
+func (f *blockFiles) coldEnd() (uint32, uint32) {
+	if f.numCold == 0 {
+		return 0, 0
+	}
+	fileNum := f.numCold - 1
+	st, err := os.Stat(f.path(fileNum))
+	if err != nil {
+		return fileNum, 0
+	}
+	return fileNum, uint32(st.Size())
+}
+
+// scan searches both directories for the most recent flat file and returns its
+// number and length.  The number is -1 when there are no files.
+func (f *blockFiles) scan() (int, uint32) {
+	lastFile, fileLen := scanBlockFilesFrom(f.hotPath, f.numCold)
+	if lastFile == -1 && f.numCold > 0 {
+		fileNum, fileLen := f.coldEnd()
+		return int(fileNum), fileLen
+	}
+	return lastFile, fileLen
+}
+
+// shadowed returns the number of files in the database directory which are
+// superseded by a file in the cold directory, typically because they were
+// copied rather than moved there.  They are never read.
+func (f *blockFiles) shadowed() int {
+	var n int
+	for fileNum := uint32(0); fileNum < f.numCold; fileNum++ {
+		if fileExists(blockFilePath(f.hotPath, fileNum)) {
+			n++
+		}
+	}
+	return n
+}
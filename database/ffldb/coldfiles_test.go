@@ -0,0 +1,174 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is part of the ffldb package rather than the ffldb_test package as
+// it provides whitebox testing.
+
+package ffldb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestColdBlockFiles ensures the oldest flat files of a database may be kept in
+// a separate cold directory, which is read from but never modified, and that
+// the tools which read the flat files directly are aware of it.
+func TestColdBlockFiles(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "ffldb-coldtest")
+	_ = os.RemoveAll(testDir)
+	defer os.RemoveAll(testDir)
+	dbPath := filepath.Join(testDir, "db")
+	coldPath := filepath.Join(testDir, "cold")
+	replayPath := filepath.Join(testDir, "replay")
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("Unable to load blocks from test data: %v", err)
+	}
+	storeBlocks := func(idb database.DB, blocks []*btcutil.Block) {
+		// Use a small maximum file size so the blocks span several flat
+		// files.
+		idb.(*db).store.maxBlockFileSize = 8192
+		err := idb.Update(func(tx database.Tx) error {
+			for _, block := range blocks {
+				if err := tx.StoreBlock(block); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			idb.Close()
+			t.Fatalf("Failed to store blocks: %v", err)
+		}
+	}
+	split := len(blocks) / 2
+
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	storeBlocks(idb, blocks[:split])
+	idb.Close()
+
+	// Move all of the flat files, including the one which was written to
+	// last, to the cold directory.
+	if err := os.MkdirAll(coldPath, 0700); err != nil {
+		t.Fatalf("MkdirAll: unexpected error: %v", err)
+	}
+	lastFile, _ := scanBlockFiles(dbPath)
+	if lastFile < 1 {
+		t.Fatalf("blocks were stored in %d files, want several",
+			lastFile+1)
+	}
+	coldData := make(map[uint32][]byte)
+	for fileNum := uint32(0); int(fileNum) <= lastFile; fileNum++ {
+		err := os.Rename(blockFilePath(dbPath, fileNum),
+			blockFilePath(coldPath, fileNum))
+		if err != nil {
+			t.Fatalf("Rename: unexpected error: %v", err)
+		}
+		f, err := os.Open(blockFilePath(coldPath, fileNum))
+		if err != nil {
+			t.Fatalf("Open: unexpected error: %v", err)
+		}
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("ReadFrom: unexpected error: %v", err)
+		}
+		coldData[fileNum] = buf.Bytes()
+	}
+
+	// The database can't be opened without the cold directory since the
+	// metadata references block data which is missing.
+	_, err = database.Open(dbType, dbPath, blockDataNet)
+	if !checkDbError(t, "Open without cold path", err,
+		database.ErrCorruption) {
+
+		return
+	}
+
+	// Store the remaining blocks with the cold directory and ensure they
+	// are written to the database directory while all blocks remain
+	// readable.
+	idb, err = database.Open(dbType, dbPath, blockDataNet, false, coldPath)
+	if err != nil {
+		t.Fatalf("Failed to open database with cold path: %v", err)
+	}
+	storeBlocks(idb, blocks[split:])
+	err = idb.View(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if _, err := tx.FetchBlock(block.Hash()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	idb.Close()
+	if err != nil {
+		t.Fatalf("FetchBlock: unexpected error: %v", err)
+	}
+	for fileNum, data := range coldData {
+		f, err := os.Open(blockFilePath(coldPath, fileNum))
+		if err != nil {
+			t.Fatalf("Open: unexpected error: %v", err)
+		}
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("ReadFrom: unexpected error: %v", err)
+		}
+		if !bytes.Equal(buf.Bytes(), data) {
+			t.Fatalf("cold block file %d was modified", fileNum)
+		}
+		if fileExists(blockFilePath(dbPath, fileNum)) {
+			t.Fatalf("cold block file %d was written to the "+
+				"database path", fileNum)
+		}
+	}
+
+	// Reading the flat files directly must see the blocks in both
+	// directories in the order they were written.
+	var numBlocks int
+	err = ForEachSplitBlock(dbPath, coldPath, blockDataNet, BlockLocation{},
+		func(sb *ScannedBlock, _ func() ([]byte, error)) error {
+			if sb.Hash != *blocks[numBlocks].Hash() {
+				t.Fatalf("unexpected block #%d", numBlocks)
+			}
+			numBlocks++
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("ForEachSplitBlock: unexpected error: %v", err)
+	}
+	if numBlocks != len(blocks) {
+		t.Fatalf("found %d blocks, want %d", numBlocks, len(blocks))
+	}
+
+	// The database can't be rebuilt or created again while the cold
+	// directory holds block files.
+	err = ResetMetadata(dbPath, coldPath, replayPath)
+	if !checkDbError(t, "ResetMetadata", err, database.ErrDriverSpecific) {
+		return
+	}
+	if !fileExists(filepath.Join(dbPath, metadataDbName)) {
+		t.Fatal("ResetMetadata: metadata was removed")
+	}
+	_, err = database.Create(dbType, filepath.Join(testDir, "new"),
+		blockDataNet, false, coldPath)
+	if !checkDbError(t, "Create", err, database.ErrDriverSpecific) {
+		return
+	}
+}
@@ -1788,6 +1788,7 @@ type db struct {
 	writeLock sync.Mutex   // Limit to one write transaction at a time.
 	closeLock sync.RWMutex // Make database close block while txns active.
 	closed    bool         // Is the database closed?
+	readOnly  bool         // Is the database open read-only?
 	store     *blockStore  // Handles read/writing blocks to flat files.
 	cache     *dbCache     // Cache layer which wraps underlying leveldb DB.
 }
@@ -1810,6 +1811,13 @@ func (db *db) Type() string {
 // which is used by the managed transaction code while the database method
 // returns the interface.
 func (db *db) begin(writable bool) (*transaction, error) {
+	// Writable transactions are not allowed when the database is open
+	// read-only.
+	if writable && db.readOnly {
+		str := "database is open read-only"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
 	// Whenever a new writable transaction is started, grab the write lock
 	// to ensure only a single write transaction can be active at the same
 	// time.  This lock will not be released until the transaction is
@@ -2040,15 +2048,49 @@ func initDB(ldb *leveldb.DB) error {
 
 // openDB opens the database at the provided path.  database.ErrDbDoesNotExist
 // is returned if the database doesn't exist and the create flag is not set.
-func openDB(dbPath string, network wire.BitcoinNet, create bool) (database.DB, error) {
+//
+// When the read-only flag is set, the metadata is opened read-only, which only
+// requires a shared lock, the flat files are never modified, and all writable
+// transactions are rejected.  The create and read-only flags are mutually
+// exclusive.
+//
+// The oldest flat block files are read from the provided cold path when it is
+// not empty.  See blockFiles for details.
+func openDB(dbPath, coldPath string, network wire.BitcoinNet, create, readOnly bool) (database.DB, error) {
 	// Error if the database doesn't exist and the create flag is not set.
 	metadataDbPath := filepath.Join(dbPath, metadataDbName)
 	dbExists := fileExists(metadataDbPath)
+	files := newBlockFiles(dbPath, coldPath)
 	if !create && !dbExists {
+		// The metadata has been lost when block files exist without
+		// it, so don't mistake that for a database which was never
+		// created.
+		if lastFile, fileLen := files.scan(); lastFile > 0 ||
+			fileLen > 0 {
+
+			str := fmt.Sprintf("metadata %q does not exist, but "+
+				"block data does", metadataDbPath)
+			log.Warnf("***Database corruption detected***: %v", str)
+			return nil, makeDbErr(database.ErrCorruption, str, nil)
+		}
+
 		str := fmt.Sprintf("database %q does not exist", metadataDbPath)
 		return nil, makeDbErr(database.ErrDbDoesNotExist, str, nil)
 	}
 
+	// A new database writes its blocks starting with the first flat file,
+	// so it can't be created with existing cold files, which are never
+	// modified.
+	if create && files.numCold > 0 {
+		str := fmt.Sprintf("cold block path %q already contains block "+
+			"files", coldPath)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+	if n := files.shadowed(); n > 0 {
+		log.Warnf("Ignoring %d block files in %q which are also in the "+
+			"cold block path %q", n, dbPath, coldPath)
+	}
+
 	// Ensure the full path to the database exists.
 	if !dbExists {
 		// The error can be ignored here since the call to
@@ -2060,6 +2102,7 @@ func openDB(dbPath string, network wire.BitcoinNet, create bool) (database.DB, e
 	// Open the metadata database (will create it if needed).
 	opts := opt.Options{
 		ErrorIfExist: create,
+		ReadOnly:     readOnly,
 		Strict:       opt.DefaultStrict,
 		Compression:  opt.NoCompression,
 		Filter:       filter.NewBloomFilter(10),
@@ -2074,11 +2117,18 @@ func openDB(dbPath string, network wire.BitcoinNet, create bool) (database.DB, e
 	// according to the data that is actually on disk.  Also create the
 	// database cache which wraps the underlying leveldb database to provide
 	// write caching.
-	store := newBlockStore(dbPath, network)
+	store := newBlockStore(dbPath, coldPath, network)
 	cache := newDbCache(ldb, store, defaultCacheSize, defaultFlushSecs)
-	pdb := &db{store: store, cache: cache}
+	pdb := &db{store: store, cache: cache, readOnly: readOnly}
 
 	// Perform any reconciliation needed between the block and metadata as
-	// well as database initialization, if needed.
-	return reconcileDB(pdb, create)
+	// well as database initialization, if needed.  Close the metadata on
+	// failure so the database can be repaired without restarting the
+	// process.
+	rdb, err := reconcileDB(pdb, create)
+	if err != nil {
+		_ = ldb.Close()
+		return nil, err
+	}
+	return rdb, nil
 }
@@ -487,6 +487,9 @@ func (c *dbCache) commitTreaps(pendingKeys, pendingRemove TreapForEacher) error
 // This function MUST be called with the database write lock held.
 func (c *dbCache) flush() error {
 	c.lastFlush = time.Now()
+	defer func() {
+		c.store.stats.recordCacheFlush(time.Since(c.lastFlush))
+	}()
 
 	// Sync the current write file associated with the block store.  This is
 	// necessary before writing the metadata to prevent the case where the
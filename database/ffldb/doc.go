@@ -25,5 +25,46 @@ database path as a string and the block network:
 	if err != nil {
 		// Handle error
 	}
+
+Open also accepts an optional third parameter which opens the database
+read-only when true.  A read-only database rejects writable transactions and
+never modifies the flat files, which makes it suitable for analysis tools:
+
+	db, err := database.Open("ffldb", "path/to/database", wire.MainNet, true)
+	if err != nil {
+		// Handle error
+	}
+
+Block Iteration
+
+The leveldb metadata can only be opened by a single process for writing, so
+tools which need to walk the blocks of a database that is in use, such as the
+database of a running node, can use ForEachBlock instead.  It reads the flat
+files directly without taking any locks:
+
+	err := ffldb.ForEachBlock("path/to/database", wire.MainNet,
+		ffldb.BlockLocation{}, func(sb *ffldb.ScannedBlock,
+			readBlock func() ([]byte, error)) error {
+
+		// Inspect sb.Header or load the block with readBlock.
+		return nil
+	})
+
+Cold Block Files
+
+The oldest flat files of a database may be moved to a separate cold directory,
+such as a slow or read-only network mount, so only the recent files take up fast
+local storage.  The cold directory must hold a run of files starting with the
+first one, and the database directory the files which follow them.  Files in the
+cold directory are never modified and new blocks are always written to the
+database directory.  The cold directory is passed as the optional fourth
+argument when opening the database, and to NewSplitBlockFileScanner and
+ForEachSplitBlock when reading the flat files directly:
+
+	db, err := database.Open("ffldb", "path/to/database", wire.MainNet,
+		false, "path/to/cold")
+	if err != nil {
+		// Handle error
+	}
 */
 package ffldb
@@ -18,49 +18,85 @@ const (
 	dbType = "ffldb"
 )
 
-// parseArgs parses the arguments from the database Open/Create methods.
-func parseArgs(funcName string, args ...interface{}) (string, wire.BitcoinNet, error) {
-	if len(args) != 2 {
-		return "", 0, fmt.Errorf("invalid arguments to %s.%s -- "+
-			"expected database path and block network", dbType,
-			funcName)
+// dbArgs houses the arguments of the database Open/Create methods.
+type dbArgs struct {
+	dbPath   string
+	network  wire.BitcoinNet
+	readOnly bool
+	coldPath string
+}
+
+// parseArgs parses the arguments from the database Open/Create methods.  The
+// optional third argument requests the database be opened read-only and the
+// optional fourth argument is the path of the directory which houses the
+// oldest flat block files.
+func parseArgs(funcName string, args ...interface{}) (*dbArgs, error) {
+	if len(args) < 2 || len(args) > 4 {
+		return nil, fmt.Errorf("invalid arguments to %s.%s -- "+
+			"expected database path, block network, and optional "+
+			"read-only flag and cold block path", dbType, funcName)
 	}
 
-	dbPath, ok := args[0].(string)
+	var parsed dbArgs
+	var ok bool
+	parsed.dbPath, ok = args[0].(string)
 	if !ok {
-		return "", 0, fmt.Errorf("first argument to %s.%s is invalid -- "+
-			"expected database path string", dbType, funcName)
+		return nil, fmt.Errorf("first argument to %s.%s is "+
+			"invalid -- expected database path string", dbType,
+			funcName)
 	}
 
-	network, ok := args[1].(wire.BitcoinNet)
+	parsed.network, ok = args[1].(wire.BitcoinNet)
 	if !ok {
-		return "", 0, fmt.Errorf("second argument to %s.%s is invalid -- "+
-			"expected block network", dbType, funcName)
+		return nil, fmt.Errorf("second argument to %s.%s is "+
+			"invalid -- expected block network", dbType, funcName)
 	}
 
-	return dbPath, network, nil
+	if len(args) >= 3 {
+		parsed.readOnly, ok = args[2].(bool)
+		if !ok {
+			return nil, fmt.Errorf("third argument to "+
+				"%s.%s is invalid -- expected read-only flag",
+				dbType, funcName)
+		}
+	}
+
+	if len(args) == 4 {
+		parsed.coldPath, ok = args[3].(string)
+		if !ok {
+			return nil, fmt.Errorf("fourth argument to "+
+				"%s.%s is invalid -- expected cold block path "+
+				"string", dbType, funcName)
+		}
+	}
+
+	return &parsed, nil
 }
 
 // openDBDriver is the callback provided during driver registration that opens
 // an existing database for use.
 func openDBDriver(args ...interface{}) (database.DB, error) {
-	dbPath, network, err := parseArgs("Open", args...)
+	a, err := parseArgs("Open", args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return openDB(dbPath, network, false)
+	return openDB(a.dbPath, a.coldPath, a.network, false, a.readOnly)
 }
 
 // createDBDriver is the callback provided during driver registration that
 // creates, initializes, and opens a database for use.
 func createDBDriver(args ...interface{}) (database.DB, error) {
-	dbPath, network, err := parseArgs("Create", args...)
+	a, err := parseArgs("Create", args...)
 	if err != nil {
 		return nil, err
 	}
+	if a.readOnly {
+		return nil, fmt.Errorf("%s.Create does not support read-only "+
+			"databases", dbType)
+	}
 
-	return openDB(dbPath, network, true)
+	return openDB(a.dbPath, a.coldPath, a.network, true, false)
 }
 
 // useLogger is the callback provided during driver registration that sets the
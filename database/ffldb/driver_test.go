@@ -37,8 +37,9 @@ func TestCreateOpenFail(t *testing.T) {
 	// Ensure that attempting to open a database with the wrong number of
 	// parameters returns the expected error.
 	wantErr := fmt.Errorf("invalid arguments to %s.Open -- expected "+
-		"database path and block network", dbType)
-	_, err = database.Open(dbType, 1, 2, 3)
+		"database path, block network, and optional read-only flag and "+
+		"cold block path", dbType)
+	_, err = database.Open(dbType, 1, 2, 3, 4, 5)
 	if err.Error() != wantErr.Error() {
 		t.Errorf("Open: did not receive expected error - got %v, "+
 			"want %v", err, wantErr)
@@ -70,8 +71,9 @@ func TestCreateOpenFail(t *testing.T) {
 	// Ensure that attempting to create a database with the wrong number of
 	// parameters returns the expected error.
 	wantErr = fmt.Errorf("invalid arguments to %s.Create -- expected "+
-		"database path and block network", dbType)
-	_, err = database.Create(dbType, 1, 2, 3)
+		"database path, block network, and optional read-only flag and "+
+		"cold block path", dbType)
+	_, err = database.Create(dbType, 1, 2, 3, 4, 5)
 	if err.Error() != wantErr.Error() {
 		t.Errorf("Create: did not receive expected error - got %v, "+
 			"want %v", err, wantErr)
@@ -254,6 +256,98 @@ func TestPersistence(t *testing.T) {
 	}
 }
 
+// TestReadOnly ensures a database opened read-only can be read, rejects
+// writable transactions, and leaves block data after the write cursor intact.
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database with a block in it.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-readonlytest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	genesisBlock := btcutil.NewBlock(chaincfg.MainNetParams.GenesisBlock)
+	genesisHash := chaincfg.MainNetParams.GenesisHash
+	err = db.Update(func(tx database.Tx) error {
+		return tx.StoreBlock(genesisBlock)
+	})
+	db.Close()
+	if err != nil {
+		t.Errorf("Update: unexpected error: %v", err)
+		return
+	}
+
+	// Ensure creating a read-only database fails.
+	wantErr := fmt.Errorf("%s.Create does not support read-only "+
+		"databases", dbType)
+	_, err = database.Create(dbType, dbPath+"-new", blockDataNet, true)
+	if err == nil || err.Error() != wantErr.Error() {
+		t.Errorf("Create: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Append data after the write cursor as if another block were in the
+	// middle of being written.
+	blockFile := filepath.Join(dbPath, fmt.Sprintf("%09d.fdb", 0))
+	f, err := os.OpenFile(blockFile, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Errorf("OpenFile: unexpected error: %v", err)
+		return
+	}
+	_, err = f.Write([]byte{0x01, 0x02, 0x03})
+	f.Close()
+	if err != nil {
+		t.Errorf("Write: unexpected error: %v", err)
+		return
+	}
+	fi, err := os.Stat(blockFile)
+	if err != nil {
+		t.Errorf("Stat: unexpected error: %v", err)
+		return
+	}
+	wantSize := fi.Size()
+
+	db, err = database.Open(dbType, dbPath, blockDataNet, true)
+	if err != nil {
+		t.Errorf("Failed to open test database read-only (%s) %v",
+			dbType, err)
+		return
+	}
+	defer db.Close()
+
+	err = db.View(func(tx database.Tx) error {
+		_, err := tx.FetchBlock(genesisHash)
+		return err
+	})
+	if err != nil {
+		t.Errorf("View: unexpected error: %v", err)
+		return
+	}
+
+	err = db.Update(func(tx database.Tx) error {
+		return nil
+	})
+	if !checkDbError(t, "Update read-only", err,
+		database.ErrTxNotWritable) {
+		return
+	}
+
+	fi, err = os.Stat(blockFile)
+	if err != nil {
+		t.Errorf("Stat: unexpected error: %v", err)
+		return
+	}
+	if fi.Size() != wantSize {
+		t.Errorf("block file was modified - got size %d, want %d",
+			fi.Size(), wantSize)
+	}
+}
+
 // TestInterface performs all interfaces tests for this database driver.
 func TestInterface(t *testing.T) {
 	t.Parallel()
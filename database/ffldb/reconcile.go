@@ -7,8 +7,11 @@ package ffldb
 import (
 	"fmt"
 	"hash/crc32"
+	"io"
+	"os"
 
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
 )
 
 // The serialized write cursor location format is:
@@ -75,6 +78,23 @@ func reconcileDB(pdb *db, create bool) (database.DB, error) {
 		return nil, err
 	}
 
+	// The files in the cold directory are never modified, so the metadata
+	// must reference all of their data.  Otherwise, the cold files were
+	// moved there from a different database or the metadata is out of
+	// date, and the cold data can't be rolled back to match it.
+	files := pdb.store.files
+	coldFileNum, coldOffset := files.coldEnd()
+	if curFileNum < coldFileNum || (curFileNum == coldFileNum &&
+		curOffset < coldOffset) {
+
+		str := fmt.Sprintf("metadata claims file %d, offset %d, but "+
+			"the cold block files in %q end at file %d, offset %d",
+			curFileNum, curOffset, files.coldPath, coldFileNum,
+			coldOffset)
+		log.Warnf("***Database corruption detected***: %v", str)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+
 	// When the write cursor position found by scanning the block files on
 	// disk is AFTER the position the metadata believes to be true, truncate
 	// the files on disk to match the metadata.  This can be a fairly common
@@ -82,10 +102,24 @@ func reconcileDB(pdb *db, create bool) (database.DB, error) {
 	// the middle of being written.  Since the metadata isn't updated until
 	// after the block data is written, this is effectively just a rollback
 	// to the known good point before the unclean shutdown.
+	//
+	// The files are left untouched when the database is open read-only.
+	// The data after the write cursor is not referenced by the metadata,
+	// so it is never read, and it might belong to a write that is still in
+	// progress by the process which has the database open for writes.
 	wc := pdb.store.writeCursor
 	if wc.curFileNum > curFileNum || (wc.curFileNum == curFileNum &&
 		wc.curOffset > curOffset) {
 
+		if pdb.readOnly {
+			log.Debugf("Metadata claims file %d, offset %d. Block "+
+				"data is at file %d, offset %d -- ignoring "+
+				"data after the write cursor since the "+
+				"database is open read-only", curFileNum,
+				curOffset, wc.curFileNum, wc.curOffset)
+			return pdb, nil
+		}
+
 		log.Info("Detected unclean shutdown - Repairing...")
 		log.Debugf("Metadata claims file %d, offset %d. Block data is "+
 			"at file %d, offset %d", curFileNum, curOffset,
@@ -113,5 +147,104 @@ func reconcileDB(pdb *db, create bool) (database.DB, error) {
 		return nil, makeDbErr(database.ErrCorruption, str, nil)
 	}
 
+	// Ensure the most recently written block is intact.  The write cursor
+	// must fall at the end of a block record and the record must match its
+	// checksum, otherwise the block data has been damaged since it was
+	// written.
+	if err := verifyLastBlock(pdb.store, curFileNum, curOffset); err != nil {
+		log.Warnf("***Database corruption detected***: %v", err)
+		return nil, err
+	}
+
+	// Likewise, ensure the final block in the cold directory is intact when
+	// the write cursor is past it, so a cold directory which was copied
+	// incompletely is detected.
+	if files.numCold > 0 && (curFileNum > files.numCold ||
+		(curFileNum == files.numCold && curOffset > 0)) {
+
+		err := verifyLastBlock(pdb.store, files.numCold, 0)
+		if err != nil {
+			log.Warnf("***Database corruption detected***: %v", err)
+			return nil, err
+		}
+	}
+
 	return pdb, nil
 }
+
+// verifyLastBlock ensures the block record which ends at the provided write
+// cursor position exists and matches its checksum.  ErrCorruption is returned
+// when it does not.  Since block records never span flat files, the record
+// ending at offset zero of a file is the final record of the previous file.
+func verifyLastBlock(store *blockStore, curFileNum, curOffset uint32) error {
+	fileNum, endOffset := curFileNum, int64(curOffset)
+	if endOffset == 0 {
+		if fileNum == 0 {
+			return nil
+		}
+		fileNum--
+		st, err := os.Stat(store.files.path(fileNum))
+		if err != nil {
+			str := fmt.Sprintf("failed to stat block file %d: %v",
+				fileNum, err)
+			return makeDbErr(database.ErrCorruption, str, err)
+		}
+		endOffset = st.Size()
+	}
+
+	// Walk the records of the file up to the write cursor.  Records are not
+	// read past it since the data after it is not referenced by the
+	// metadata.
+	scanner := NewSplitBlockFileScanner(store.files.hotPath,
+		store.files.coldPath, store.network)
+	defer scanner.Close()
+	scanner.Seek(fileNum, 0)
+	var last *ScannedBlock
+	for last == nil || int64(last.Offset)+int64(last.BlockLen)+
+		recordOverhead < endOffset {
+
+		sb, err := scanner.Next()
+		if err == io.EOF || (err == nil && sb.FileNum != fileNum) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		last = sb
+	}
+	if last == nil || int64(last.Offset)+int64(last.BlockLen)+
+		recordOverhead != endOffset {
+
+		str := fmt.Sprintf("write cursor at file %d, offset %d does "+
+			"not fall at the end of a block record", curFileNum,
+			curOffset)
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+	_, err := scanner.ReadBlock()
+	return err
+}
+
+// VerifyBlockFiles ensures every block record stored in the flat files of the
+// database at the provided path matches its checksum.  The oldest flat files
+// are read from the provided cold path when it is not empty.  Opening the
+// database only verifies the most recently written block, so this detects
+// damage to the earlier blocks as well, at the cost of reading all of them.
+// ErrCorruption is returned for the first damaged record.
+//
+// The final record is left to the check done when the database is opened,
+// since it may have been written only partially before an unclean shutdown, in
+// which case it is not referenced by the metadata.
+func VerifyBlockFiles(dbPath, coldPath string, network wire.BitcoinNet) error {
+	var pendingErr error
+	err := ForEachSplitBlock(dbPath, coldPath, network, BlockLocation{},
+		func(sb *ScannedBlock, readBlock func() ([]byte, error)) error {
+			// Another record follows the damaged one, so it is
+			// not the final record.
+			if pendingErr != nil {
+				return pendingErr
+			}
+			_, pendingErr = readBlock()
+			return nil
+		})
+	return err
+}
@@ -0,0 +1,328 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains a sequential reader for the flat files that house the
+// actual blocks.  Unlike the block store, it does not rely on the block index
+// in the metadata and instead walks the raw block records in the order they
+// were written.
+
+package ffldb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// recordHeaderSize is the number of bytes that precede the serialized
+	// block in each block record.  It consists of 4 bytes for the network
+	// followed by 4 bytes for the block length.
+	recordHeaderSize = 8
+
+	// recordOverhead is the total number of bytes each block record adds
+	// to the serialized block.  This is the record header plus 4 bytes for
+	// the trailing checksum.
+	recordOverhead = recordHeaderSize + 4
+
+	// blockHeaderSize is the number of bytes in a serialized block header.
+	blockHeaderSize = 80
+)
+
+// BlockLocation identifies the position of a block record within the flat
+// files of a database.
+type BlockLocation struct {
+	// FileNum and Offset identify the flat file and offset within it at
+	// which the block record starts.
+	FileNum uint32
+	Offset  uint32
+}
+
+// ScannedBlock describes a single block record found in the flat files by a
+// BlockFileScanner.
+type ScannedBlock struct {
+	// BlockLocation is the location of the block record.
+	BlockLocation
+
+	// BlockLen is the length of the serialized block excluding the record
+	// overhead.
+	BlockLen uint32
+
+	// Hash and Header are the hash and header of the block.
+	Hash   chainhash.Hash
+	Header wire.BlockHeader
+}
+
+// BlockFileScanner provides sequential read-only access to the block records
+// stored in the flat files of a database.  Only the block headers are read
+// while advancing through the records, so skipping uninteresting blocks is
+// cheap.  The full block data for the current record may be loaded with
+// ReadBlock.
+//
+// The scanner opens the flat files directly and does not take any locks, so it
+// is safe to use against the files of a database that is currently open.  Since
+// the most recent record of such a database may still be in the process of
+// being written, callers scanning a live database should stop once they have
+// seen the blocks they are interested in rather than relying on reaching the
+// end of the files.
+type BlockFileScanner struct {
+	files   *blockFiles
+	network wire.BitcoinNet
+
+	// file is the currently open flat file and fileLen is its length at the
+	// time it was opened.
+	file    *os.File
+	fileNum uint32
+	fileLen int64
+
+	// offset is the offset of the next record in the current file.
+	offset uint32
+
+	// current is the most recent record returned by Next.
+	current *ScannedBlock
+
+	// truncated is set when the most recent call to Next failed because
+	// the record extends past the end of its file.
+	truncated bool
+}
+
+// NewBlockFileScanner returns a scanner positioned at the first record of the
+// first flat file in the provided database path.  The network is used to
+// ensure the records belong to the expected network.
+func NewBlockFileScanner(dbPath string, network wire.BitcoinNet) *BlockFileScanner {
+	return NewSplitBlockFileScanner(dbPath, "", network)
+}
+
+// NewSplitBlockFileScanner returns a scanner positioned at the first record of
+// the first flat file of a database whose oldest flat files are kept in the
+// provided cold path, separately from the recent ones in the database path.
+// It is equivalent to NewBlockFileScanner when the cold path is empty.
+func NewSplitBlockFileScanner(dbPath, coldPath string, network wire.BitcoinNet) *BlockFileScanner {
+	return &BlockFileScanner{
+		files:   newBlockFiles(dbPath, coldPath),
+		network: network,
+	}
+}
+
+// NumFiles returns the number of flat files of the database.
+func (s *BlockFileScanner) NumFiles() uint32 {
+	lastFile, _ := s.files.scan()
+	return uint32(lastFile + 1)
+}
+
+// Seek positions the scanner so the next call to Next returns the record at the
+// provided file number and offset.  The offset must refer to the start of a
+// block record.
+func (s *BlockFileScanner) Seek(fileNum, offset uint32) {
+	if s.file != nil && s.fileNum != fileNum {
+		_ = s.file.Close()
+		s.file = nil
+	}
+	s.fileNum = fileNum
+	s.offset = offset
+	s.current = nil
+}
+
+// openCurrentFile opens the flat file the scanner is positioned at when it is
+// not already open.  It returns false when the file does not exist.
+func (s *BlockFileScanner) openCurrentFile() (bool, error) {
+	if s.file != nil {
+		return true, nil
+	}
+
+	filePath := s.files.path(s.fileNum)
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, makeDbErr(database.ErrDriverSpecific, err.Error(),
+			err)
+	}
+	st, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return false, makeDbErr(database.ErrDriverSpecific, err.Error(),
+			err)
+	}
+	s.file = file
+	s.fileLen = st.Size()
+	return true, nil
+}
+
+// Next advances the scanner to the next block record and returns it.  It
+// returns io.EOF once there are no more records.  A record which extends past
+// the end of its file or belongs to a different network results in an
+// ErrCorruption error.
+func (s *BlockFileScanner) Next() (*ScannedBlock, error) {
+	s.truncated = false
+	for {
+		exists, err := s.openCurrentFile()
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			s.current = nil
+			return nil, io.EOF
+		}
+
+		// Move on to the next file once the end of the current one is
+		// reached.
+		if int64(s.offset) >= s.fileLen {
+			_ = s.file.Close()
+			s.file = nil
+			s.fileNum++
+			s.offset = 0
+			continue
+		}
+		break
+	}
+
+	// Read the record header along with the block header that follows it.
+	var buf [recordHeaderSize + blockHeaderSize]byte
+	if int64(s.offset)+int64(len(buf)) > s.fileLen {
+		s.truncated = true
+		str := fmt.Sprintf("truncated block record in file %d at "+
+			"offset %d", s.fileNum, s.offset)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	if _, err := s.file.ReadAt(buf[:], int64(s.offset)); err != nil {
+		str := fmt.Sprintf("failed to read block record in file %d "+
+			"at offset %d: %v", s.fileNum, s.offset, err)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	serializedNet := byteOrder.Uint32(buf[0:4])
+	if serializedNet != uint32(s.network) {
+		str := fmt.Sprintf("block record in file %d at offset %d is "+
+			"for the wrong network - got %d, want %d", s.fileNum,
+			s.offset, serializedNet, uint32(s.network))
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	blockLen := byteOrder.Uint32(buf[4:8])
+	fullLen := int64(blockLen) + recordOverhead
+	if blockLen < blockHeaderSize || int64(s.offset)+fullLen > s.fileLen {
+		s.truncated = blockLen >= blockHeaderSize
+		str := fmt.Sprintf("truncated block record in file %d at "+
+			"offset %d", s.fileNum, s.offset)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	headerBytes := buf[recordHeaderSize:]
+	sb := &ScannedBlock{
+		BlockLocation: BlockLocation{FileNum: s.fileNum, Offset: s.offset},
+		BlockLen:      blockLen,
+		Hash:          chainhash.DoubleHashH(headerBytes),
+	}
+	if err := sb.Header.Deserialize(bytes.NewReader(headerBytes)); err != nil {
+		str := fmt.Sprintf("failed to deserialize block header in "+
+			"file %d at offset %d: %v", s.fileNum, s.offset, err)
+		return nil, makeDbErr(database.ErrCorruption, str, err)
+	}
+
+	s.offset += uint32(fullLen)
+	s.current = sb
+	return sb, nil
+}
+
+// ReadBlock returns the serialized block for the record most recently returned
+// by Next.  The integrity of the data is verified against the checksum stored
+// in the record.
+func (s *BlockFileScanner) ReadBlock() ([]byte, error) {
+	sb := s.current
+	if sb == nil || s.file == nil {
+		str := "no current block record to read"
+		return nil, makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+
+	serializedData := make([]byte, sb.BlockLen+recordOverhead)
+	n, err := s.file.ReadAt(serializedData, int64(sb.Offset))
+	if err != nil {
+		str := fmt.Sprintf("failed to read block %s from file %d, "+
+			"offset %d: %v", sb.Hash, sb.FileNum, sb.Offset, err)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	serializedChecksum := binary.BigEndian.Uint32(serializedData[n-4:])
+	calculatedChecksum := crc32.Checksum(serializedData[:n-4], castagnoli)
+	if serializedChecksum != calculatedChecksum {
+		str := fmt.Sprintf("block data for block %s checksum "+
+			"does not match - got %x, want %x", sb.Hash,
+			calculatedChecksum, serializedChecksum)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	return serializedData[recordHeaderSize : n-4], nil
+}
+
+// Close closes any flat file held open by the scanner.
+func (s *BlockFileScanner) Close() error {
+	s.current = nil
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// inLastFile returns whether the scanner is positioned in the most recent flat
+// file, which is the one new block records are appended to.
+func (s *BlockFileScanner) inLastFile() bool {
+	_, err := os.Stat(s.files.path(s.fileNum + 1))
+	return os.IsNotExist(err)
+}
+
+// ForEachBlock calls the provided function with each block record stored in the
+// flat files of the database at the provided path, in the order they were
+// written, starting with the record at the provided location.  The function is
+// also passed a function which loads the full serialized block of the record,
+// so blocks which are not of interest are skipped without reading them.
+// Iteration stops when the provided function returns an error, which is then
+// returned.
+//
+// Like BlockFileScanner, it neither takes any locks nor modifies the files, so
+// it is safe to use against the files of a database which is open elsewhere,
+// such as by a running node.  An incomplete record at the end of the most
+// recent file, which is typically a block still being written, ends the
+// iteration rather than resulting in an error.
+func ForEachBlock(dbPath string, network wire.BitcoinNet, start BlockLocation,
+	fn func(sb *ScannedBlock, readBlock func() ([]byte, error)) error) error {
+
+	return ForEachSplitBlock(dbPath, "", network, start, fn)
+}
+
+// ForEachSplitBlock is the same as ForEachBlock for a database whose oldest flat
+// files are kept in the provided cold path, separately from the recent ones in
+// the database path.
+func ForEachSplitBlock(dbPath, coldPath string, network wire.BitcoinNet,
+	start BlockLocation, fn func(sb *ScannedBlock,
+		readBlock func() ([]byte, error)) error) error {
+
+	scanner := NewSplitBlockFileScanner(dbPath, coldPath, network)
+	defer scanner.Close()
+	scanner.Seek(start.FileNum, start.Offset)
+	for {
+		sb, err := scanner.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if scanner.truncated && scanner.inLastFile() {
+				return nil
+			}
+			return err
+		}
+		if err := fn(sb, scanner.ReadBlock); err != nil {
+			return err
+		}
+	}
+}
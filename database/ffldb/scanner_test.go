@@ -0,0 +1,220 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is part of the ffldb package rather than the ffldb_test package as
+// it provides whitebox testing.
+
+package ffldb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/database"
+)
+
+// TestBlockFileScanner ensures the flat file scanner returns every stored block
+// in the order it was written, including across multiple flat files, and that
+// the raw block data it loads matches the stored blocks.
+func TestBlockFileScanner(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-scannertest")
+	_ = os.RemoveAll(dbPath)
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer idb.Close()
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("Unable to load blocks from test data: %v", err)
+	}
+
+	// Use a small maximum file size so the blocks are spread across
+	// several flat files.
+	store := idb.(*db).store
+	store.maxBlockFileSize = 8192
+	err = idb.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to store blocks: %v", err)
+	}
+
+	scanner := NewBlockFileScanner(dbPath, blockDataNet)
+	defer scanner.Close()
+	if scanner.NumFiles() < 2 {
+		t.Fatalf("Expected blocks to span multiple files, got %d",
+			scanner.NumFiles())
+	}
+
+	for i, block := range blocks {
+		sb, err := scanner.Next()
+		if err != nil {
+			t.Fatalf("Next #%d: unexpected error: %v", i, err)
+		}
+		if sb.Hash != *block.Hash() {
+			t.Fatalf("Next #%d: unexpected hash - got %v, want %v",
+				i, sb.Hash, block.Hash())
+		}
+		if sb.Header.BlockHash() != sb.Hash {
+			t.Fatalf("Next #%d: header does not match hash", i)
+		}
+
+		gotBytes, err := scanner.ReadBlock()
+		if err != nil {
+			t.Fatalf("ReadBlock #%d: unexpected error: %v", i, err)
+		}
+		wantBytes, _ := block.Bytes()
+		if !bytes.Equal(gotBytes, wantBytes) {
+			t.Fatalf("ReadBlock #%d: block bytes mismatch", i)
+		}
+	}
+	if _, err := scanner.Next(); err != io.EOF {
+		t.Fatalf("Next: unexpected error at end - got %v, want %v",
+			err, io.EOF)
+	}
+
+	// Seeking to a previously returned record must return it again.
+	scanner.Seek(0, 0)
+	sb, err := scanner.Next()
+	if err != nil {
+		t.Fatalf("Next after seek: unexpected error: %v", err)
+	}
+	if sb.Hash != *blocks[0].Hash() {
+		t.Fatalf("Next after seek: unexpected hash - got %v, want %v",
+			sb.Hash, blocks[0].Hash())
+	}
+
+	// A scanner for the wrong network must report corruption.
+	wrongNet := NewBlockFileScanner(dbPath, blockDataNet+1)
+	defer wrongNet.Close()
+	_, err = wrongNet.Next()
+	if !checkDbError(t, "Next wrong network", err, database.ErrCorruption) {
+		return
+	}
+}
+
+// TestForEachBlock ensures ForEachBlock visits every stored block starting at
+// the requested location, stops when the callback returns an error, and treats
+// an incomplete record at the end of the most recent file as the end of data.
+func TestForEachBlock(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-foreachblocktest")
+	_ = os.RemoveAll(dbPath)
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer idb.Close()
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("Unable to load blocks from test data: %v", err)
+	}
+	store := idb.(*db).store
+	store.maxBlockFileSize = 8192
+	err = idb.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to store blocks: %v", err)
+	}
+
+	// collect returns the locations of the blocks visited starting at the
+	// passed location after ensuring they match the stored blocks.
+	collect := func(desc string, start BlockLocation, first int) []BlockLocation {
+		var locs []BlockLocation
+		err := ForEachBlock(dbPath, blockDataNet, start,
+			func(sb *ScannedBlock, readBlock func() ([]byte, error)) error {
+				i := first + len(locs)
+				if i >= len(blocks) || sb.Hash != *blocks[i].Hash() {
+					t.Fatalf("%s: unexpected block %v at #%d",
+						desc, sb.Hash, i)
+				}
+				gotBytes, err := readBlock()
+				if err != nil {
+					return err
+				}
+				wantBytes, _ := blocks[i].Bytes()
+				if !bytes.Equal(gotBytes, wantBytes) {
+					t.Fatalf("%s: block bytes mismatch at #%d",
+						desc, i)
+				}
+				locs = append(locs, sb.BlockLocation)
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", desc, err)
+		}
+		return locs
+	}
+
+	locs := collect("from start", BlockLocation{}, 0)
+	if len(locs) != len(blocks) {
+		t.Fatalf("from start: visited %d blocks, want %d", len(locs),
+			len(blocks))
+	}
+	start := len(blocks) / 2
+	if got := collect("from middle", locs[start], start); len(got) !=
+		len(blocks)-start {
+
+		t.Fatalf("from middle: visited %d blocks, want %d", len(got),
+			len(blocks)-start)
+	}
+
+	// Errors returned by the callback stop the iteration.
+	stopErr := errors.New("stop")
+	var visited int
+	err = ForEachBlock(dbPath, blockDataNet, BlockLocation{},
+		func(*ScannedBlock, func() ([]byte, error)) error {
+			visited++
+			return stopErr
+		})
+	if err != stopErr || visited != 1 {
+		t.Fatalf("ForEachBlock: got %v after %d blocks, want %v after 1",
+			err, visited, stopErr)
+	}
+
+	// A partially written record at the end of the most recent file must
+	// end the iteration without an error.
+	lastLoc := locs[len(locs)-1]
+	lastFile := blockFilePath(dbPath, lastLoc.FileNum)
+	f, err := os.OpenFile(lastFile, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("OpenFile: unexpected error: %v", err)
+	}
+	var partial [12]byte
+	byteOrder.PutUint32(partial[0:4], uint32(blockDataNet))
+	byteOrder.PutUint32(partial[4:8], 1000)
+	_, err = f.Write(partial[:])
+	f.Close()
+	if err != nil {
+		t.Fatalf("Write: unexpected error: %v", err)
+	}
+	if got := collect("partial tail", lastLoc, len(blocks)-1); len(got) != 1 {
+		t.Fatalf("partial tail: visited %d blocks, want 1", len(got))
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/database"
+)
+
+// ioStats houses counters for the I/O performed by the database since it was
+// opened.  The fields must only be accessed atomically.
+type ioStats struct {
+	cacheFlushes         uint64
+	cacheFlushNanos      uint64
+	lastCacheFlushNanos  uint64
+	blocksWritten        uint64
+	blockBytesWritten    uint64
+	blockFileTruncations uint64
+}
+
+// recordCacheFlush records a flush of the database cache which took the passed
+// duration.
+func (s *ioStats) recordCacheFlush(d time.Duration) {
+	atomic.AddUint64(&s.cacheFlushes, 1)
+	atomic.AddUint64(&s.cacheFlushNanos, uint64(d))
+	atomic.StoreUint64(&s.lastCacheFlushNanos, uint64(d))
+}
+
+// recordBlockWrite records a block record of the passed length written to the
+// flat files.
+func (s *ioStats) recordBlockWrite(n uint32) {
+	atomic.AddUint64(&s.blocksWritten, 1)
+	atomic.AddUint64(&s.blockBytesWritten, uint64(n))
+}
+
+// recordTruncation records the truncation of a flat file.
+func (s *ioStats) recordTruncation() {
+	atomic.AddUint64(&s.blockFileTruncations, 1)
+}
+
+// Stats describes the I/O performed by an ffldb database since it was opened.
+type Stats struct {
+	// CacheFlushes is the number of times the database cache was flushed
+	// to the metadata database.  CacheFlushTime is the total time spent
+	// flushing and LastCacheFlushTime the time the most recent flush took.
+	CacheFlushes       uint64
+	CacheFlushTime     time.Duration
+	LastCacheFlushTime time.Duration
+
+	// BlocksWritten and BlockBytesWritten are the number of block records
+	// and bytes written to the flat files.
+	BlocksWritten     uint64
+	BlockBytesWritten uint64
+
+	// BlockFileTruncations is the number of times a flat file was
+	// truncated to roll back a failed transaction.
+	BlockFileTruncations uint64
+}
+
+// IOStats returns the I/O statistics of the provided database, which must be
+// an ffldb database.
+func IOStats(idb database.DB) (*Stats, error) {
+	pdb, isFfldb := idb.(*db)
+	if !isFfldb {
+		str := "I/O statistics are only supported by ffldb databases"
+		return nil, makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+
+	s := pdb.store.stats
+	return &Stats{
+		CacheFlushes:         atomic.LoadUint64(&s.cacheFlushes),
+		CacheFlushTime:       time.Duration(atomic.LoadUint64(&s.cacheFlushNanos)),
+		LastCacheFlushTime:   time.Duration(atomic.LoadUint64(&s.lastCacheFlushNanos)),
+		BlocksWritten:        atomic.LoadUint64(&s.blocksWritten),
+		BlockBytesWritten:    atomic.LoadUint64(&s.blockBytesWritten),
+		BlockFileTruncations: atomic.LoadUint64(&s.blockFileTruncations),
+	}, nil
+}
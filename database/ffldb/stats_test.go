@@ -0,0 +1,88 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is part of the ffldb package rather than the ffldb_test package as
+// it provides whitebox testing.
+
+package ffldb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/database"
+)
+
+// TestIOStats ensures the I/O statistics count the block writes, cache flushes,
+// and flat file truncations of the database.
+func TestIOStats(t *testing.T) {
+	t.Parallel()
+
+	dbPath := filepath.Join(os.TempDir(), "ffldb-iostatstest")
+	_ = os.RemoveAll(dbPath)
+	defer os.RemoveAll(dbPath)
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer idb.Close()
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("Unable to load blocks from test data: %v", err)
+	}
+
+	err = idb.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to store blocks: %v", err)
+	}
+	var wantBytes uint64
+	for _, block := range blocks {
+		serialized, err := block.Bytes()
+		if err != nil {
+			t.Fatalf("Failed to serialize block: %v", err)
+		}
+		wantBytes += uint64(len(serialized)) + 12
+	}
+	if err := Flush(idb); err != nil {
+		t.Fatalf("Flush: unexpected error: %v", err)
+	}
+
+	// Roll back the flat file to the start to force a truncation.
+	pdb := idb.(*db)
+	pdb.store.handleRollback(0, 0)
+
+	stats, err := IOStats(idb)
+	if err != nil {
+		t.Fatalf("IOStats: unexpected error: %v", err)
+	}
+	if stats.BlocksWritten != uint64(len(blocks)) ||
+		stats.BlockBytesWritten != wantBytes {
+
+		t.Fatalf("IOStats: got %d blocks and %d bytes written, want "+
+			"%d blocks and %d bytes", stats.BlocksWritten,
+			stats.BlockBytesWritten, len(blocks), wantBytes)
+	}
+	if stats.CacheFlushes == 0 || stats.CacheFlushTime <
+		stats.LastCacheFlushTime {
+
+		t.Fatalf("IOStats: unexpected cache flush statistics %+v", stats)
+	}
+	if stats.BlockFileTruncations != 1 {
+		t.Fatalf("IOStats: got %d truncations, want 1",
+			stats.BlockFileTruncations)
+	}
+
+	checkDbError(t, "IOStats", func() error {
+		_, err := IOStats(nil)
+		return err
+	}(), database.ErrDriverSpecific)
+}
@@ -34,13 +34,28 @@ Application Options:
       --listen=             Add an interface/port to listen for connections
                             (default all interfaces port: 8333, testnet: 18333)
       --maxpeers=           Max number of inbound and outbound peers (125)
+      --blockrelaypeers=    Number of additional outbound block-relay-only peers,
+                            which neither relay transactions nor addresses and
+                            are reconnected to after a restart (0 to disable)
+                            (2)
       --nobanning           Disable banning of misbehaving peers
       --banduration=        How long to ban misbehaving peers.  Valid time units
                             are {s, m, h}.  Minimum 1 second (24h0m0s)
       --banthreshold=       Maximum allowed ban score before disconnecting and
                             banning misbehaving peers.
+      --banscore=           Override the score an offense adds to the ban score
+                            of a peer -- Format: '<offense>=<score>' with
+                            offenses {getdata, mempool, nobloom}
       --whitelist=          Add an IP network or IP that will not be banned.
                             (eg. 192.168.1.0/24 or ::1)
+      --maxuploadtarget=    Try to keep outbound traffic under the given target
+                            in MiB per 24h by refusing to serve historical
+                            blocks to peers which are not whitelisted once it
+                            is reached (0 for no limit)
+      --maxpeerupload=      Max rate in KiB/s at which blocks are sent to each
+                            peer which is not whitelisted (0 for no limit)
+      --maxpeerdownload=    Max rate in KiB/s at which data is received from
+                            each peer which is not whitelisted (0 for no limit)
   -u, --rpcuser=            Username for RPC connections
   -P, --rpcpass=            Password for RPC connections
       --rpclimituser=       Username for limited RPC connections
@@ -52,6 +67,12 @@ Application Options:
       --rpcmaxclients=      Max number of RPC clients for standard connections
                             (10)
       --rpcmaxwebsockets=   Max number of RPC websocket connections (25)
+      --rpcshutdowngrace=   Max amount of time given to in-flight websocket
+                            rescans to stop and report the last block they
+                            processed when shutting down (10s)
+      --shutdowndowntime=   Estimated downtime announced to websocket clients
+                            when shutting down, such as for a planned restart
+                            -- 0 announces an unknown downtime
       --rpcquirks           Mirror some JSON-RPC quirks of Bitcoin Core -- NOTE:
                             Discouraged unless interoperability issues need to
                             be worked around
@@ -73,18 +94,87 @@ Application Options:
       --noonion             Disable connecting to tor hidden services
       --torisolation        Enable Tor stream isolation by randomizing user
                             credentials for each connection.
+      --torcontrol=         Tor control port used to request new circuits when
+                            outbound connections through the proxy keep
+                            failing (eg. 127.0.0.1:9051)
+      --torcontrolpass=     Password for the Tor control port
       --testnet             Use the test network
+      --testnet4            Use the test network (version 4)
       --regtest             Use the regression test network
       --simnet              Use the simulation test network
+      --signet              Use the signet test network
+      --signetchallenge=    Hex encoded block challenge script of a custom
+                            signet test network (default: the public signet
+                            challenge)
+      --signetseednode=     Add a DNS seed for the signet test network -- the
+                            public signet seeds are only used when no custom
+                            challenge is given
+      --chainparams=        Use the custom network defined by the given JSON
+                            network definition file
       --addcheckpoint=      Add a custom checkpoint.  Format: '<height>:<hash>'
       --nocheckpoints       Disable built-in checkpoints.  Don't do this unless
                             you know what you're doing.
       --uacomment=          Comment to add to the user agent --
                             See BIP 14 for more information.
       --dbtype=             Database backend to use for the Block Chain (ffldb)
+      --metabackupinterval= Interval at which snapshots of the block database
+                            metadata are taken -- Use 0 to disable snapshots.
+                            Valid time units are {s, m, h}.  Minimum 1 minute
+      --metabackups=        Number of block database metadata snapshots to keep
+                            (2)
+      --undodepth=          Prune the undo data needed to disconnect blocks for
+                            blocks deeper than the given number of blocks, which
+                            refuses deeper reorganizations -- Use 0 to keep all
+                            undo data.  Minimum 288
+      --maxreorgdepth=      Hold back reorganizations which disconnect more than
+                            the given number of blocks until they are approved
+                            with the approvereorg RPC -- Use 0 to allow
+                            reorganizations of any depth
+      --restoremetadata     Restore the block database metadata from the most
+                            recent usable snapshot on start up and reprocess
+                            the blocks stored since it was taken
+      --autorecover         Automatically repair the block database when
+                            corruption is detected on start up by restoring
+                            the most recent usable metadata snapshot, or by
+                            rebuilding it from the stored blocks when there is
+                            none -- Only the most recently stored block is
+                            checked for damage unless --verifyblockfiles is
+                            set
+      --verifyblockfiles    Verify the checksums of all stored blocks on start
+                            up rather than only the most recently stored one
+                            -- This reads the entire block database
+      --recoverypeer=       Fetch the best header chain from the specified peer
+                            before reprocessing the blocks left by a block
+                            database recovery and skip the stored blocks which
+                            are on a stale fork of it
+      --coldblockdir=       Directory which houses the oldest block files of the
+                            block database, such as a slow or read-only network
+                            mount -- The recent block files and all new blocks
+                            are kept in the data directory
       --profile=            Enable HTTP profiling on given port -- NOTE port
                             must be between 1024 and 65536
       --cpuprofile=         Write CPU profile to the specified file
+      --recordtrace=        Record the blocks and transactions received from
+                            peers, the mined blocks, and the RPC calls which
+                            change the chain or memory pool to the specified
+                            trace file -- Start recording with an empty data
+                            directory for the trace to be replayable
+      --replaytrace=        Replay the specified trace file recorded with
+                            --recordtrace on start up without connecting to any
+                            peers -- Use an empty data directory
+      --metricslisten=      Serve Prometheus metrics at /metrics on the given
+                            interface/port (default port: 9332) -- NOTE the
+                            metrics are not authenticated
+      --webhook=            POST a JSON notification to the given http or https
+                            URL for every block connected to or disconnected
+                            from the main chain -- Notifications are queued on
+                            disk while the endpoint is unavailable
+      --webhookoutboxsize=  Max size in megabytes of the notifications queued
+                            for each webhook -- The oldest notifications are
+                            dropped when it is exceeded (64)
+      --webhookoutboxage=   Max amount of time a notification is queued for a
+                            webhook before it is dropped -- 0 disables the limit
+                            (168h0m0s)
   -d, --debuglevel=         Logging level for all subsystems {trace, debug,
                             info, warn, error, critical} -- You may also specify
                             <subsystem>=<level>,<subsystem2>=<level>,... to set
@@ -93,6 +183,17 @@ Application Options:
       --upnp                Use UPnP to map our listening port outside of NAT
       --minrelaytxfee=      The minimum transaction fee in BTC/kB to be
                             considered a non-zero fee.
+      --dustrelayfee=       The fee rate in BTC/kB used to determine whether a
+                            transaction output is dust -- Defaults to the
+                            minrelaytxfee
+      --datacarriersize=    Max number of bytes of data carried by a standard
+                            null data (OP_RETURN) output (80)
+      --datacarriermultipush
+                            Treat null data (OP_RETURN) outputs which carry
+                            their data in multiple pushes as standard
+      --rejectbaremultisig  Reject transactions with bare (not
+                            pay-to-script-hash) multi-signature outputs as
+                            non-standard
       --limitfreerelay=     Limit relay of transactions with no transaction fee
                             to the given amount in thousands of bytes per
                             minute (15)
@@ -100,6 +201,13 @@ Application Options:
                             high priority for relaying
       --maxorphantx=        Max number of orphan transactions to keep in memory
                             (100)
+      --maxmempool=         Max virtual size of the transaction memory pool in
+                            megabytes -- The transactions paying the lowest fees
+                            are evicted when it is exceeded -- 0 disables the
+                            limit (300)
+      --mempoolexpiry=      Max amount of time a transaction may stay in the
+                            memory pool before it is evicted -- 0 disables
+                            expiry (336h0m0s)
       --generate            Generate (mine) bitcoins using the CPU
       --miningaddr=         Add the specified payment address to the list of
                             addresses to use for generated blocks -- At least
@@ -111,7 +219,13 @@ Application Options:
                             a block (750000)
       --blockprioritysize=  Size in bytes for high-priority/low-fee transactions
                             when creating a block (50000)
+      --blocklocktime       Set the lock time of the coinbase transaction to the
+                            current height when creating a block, as wallets do
+                            to discourage fee sniping
       --nopeerbloomfilters  Disable bloom filtering support.
+      --zstdblocks          Advertise support for zstd compressed blocks and
+                            exchange blocks compressed with whitelisted peers
+                            which support them too
       --sigcachemaxsize=    The maximum number of entries in the signature
                             verification cache.
       --blocksonly          Do not accept transactions from remote peers.
@@ -119,6 +233,18 @@ Application Options:
                             default settings for the active network.
       --rejectnonstd        Reject non-standard transactions regardless of the
                             default settings for the active network.
+      --requirelocktime     Only accept and relay new transactions with an
+                            anti-fee-sniping lock time, which is a block height
+                            no more than 100 blocks below the current height,
+                            even when relaying non-standard transactions
+      --policyexcepttx=     Accept and relay the transaction with the given hash
+                            even when it is not standard, as long as it is
+                            valid
+      --policyexceptscript= Accept and relay transactions which pay to or spend
+                            an output script matching the given template even
+                            when they are not standard, as long as they are
+                            valid -- Templates use the decodescript asm format
+                            where <data> matches any pushed data
 
 Help Options:
   -h, --help           Show this help message
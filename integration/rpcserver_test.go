@@ -9,6 +9,7 @@ package integration
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"runtime/debug"
@@ -16,6 +17,9 @@ import (
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/integration/rpctest"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
 )
 
 func testGetBestBlock(r *rpctest.Harness, t *testing.T) {
@@ -94,10 +98,111 @@ func testGetBlockHash(r *rpctest.Harness, t *testing.T) {
 	}
 }
 
+func testGenerateToAddress(r *rpctest.Harness, t *testing.T) {
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("Unable to generate address: %v", err)
+	}
+	generatedBlockHashes, err := r.Node.GenerateToAddress(2, addr)
+	if err != nil {
+		t.Fatalf("Call to `generatetoaddress` failed: %v", err)
+	}
+	if len(generatedBlockHashes) != 2 {
+		t.Fatalf("Generated %d blocks, wanted 2",
+			len(generatedBlockHashes))
+	}
+
+	// The coinbase of each block should pay to the address.
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("Unable to create script: %v", err)
+	}
+	for _, hash := range generatedBlockHashes {
+		block, err := r.Node.GetBlock(hash)
+		if err != nil {
+			t.Fatalf("Call to `getblock` failed: %v", err)
+		}
+		coinbaseOut := block.Transactions[0].TxOut[0]
+		if !bytes.Equal(coinbaseOut.PkScript, pkScript) {
+			t.Fatalf("Coinbase of block %v pays to script %x, "+
+				"wanted %x", hash, coinbaseOut.PkScript, pkScript)
+		}
+	}
+}
+
+func testGenerateBlock(r *rpctest.Harness, t *testing.T) {
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("Unable to generate address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("Unable to create script: %v", err)
+	}
+
+	// Create a transaction which is not in the mempool and include it
+	// in the generated block as a raw transaction.
+	tx, err := r.CreateTransaction([]*wire.TxOut{
+		wire.NewTxOut(btcutil.SatoshiPerBitcoin, pkScript),
+	}, 10)
+	if err != nil {
+		t.Fatalf("Unable to create transaction: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatalf("Unable to serialize transaction: %v", err)
+	}
+	blockHash, err := r.Node.GenerateBlock(addr,
+		[]string{hex.EncodeToString(buf.Bytes())})
+	if err != nil {
+		t.Fatalf("Call to `generateblock` failed: %v", err)
+	}
+
+	// The block should consist of the coinbase and the transaction.
+	block, err := r.Node.GetBlock(blockHash)
+	if err != nil {
+		t.Fatalf("Call to `getblock` failed: %v", err)
+	}
+	if len(block.Transactions) != 2 {
+		t.Fatalf("Generated block has %d transactions, wanted 2",
+			len(block.Transactions))
+	}
+	if block.Transactions[1].TxHash() != tx.TxHash() {
+		t.Fatalf("Generated block includes transaction %v, wanted "+
+			"%v", block.Transactions[1].TxHash(), tx.TxHash())
+	}
+
+	// Including the same transaction again must fail since its inputs are
+	// spent now.
+	_, err = r.Node.GenerateBlock(addr,
+		[]string{hex.EncodeToString(buf.Bytes())})
+	if err == nil {
+		t.Fatalf("Call to `generateblock` with a double spend " +
+			"succeeded")
+	}
+
+	// Transactions which are not sane, such as one spending the same
+	// output twice, must be rejected as well.
+	insane := tx.Copy()
+	insane.TxIn = append(insane.TxIn, insane.TxIn[0])
+	buf.Reset()
+	if err := insane.Serialize(&buf); err != nil {
+		t.Fatalf("Unable to serialize transaction: %v", err)
+	}
+	_, err = r.Node.GenerateBlock(addr,
+		[]string{hex.EncodeToString(buf.Bytes())})
+	if err == nil {
+		t.Fatalf("Call to `generateblock` with duplicate inputs " +
+			"succeeded")
+	}
+}
+
 var rpcTestCases = []rpctest.HarnessTestCase{
 	testGetBestBlock,
 	testGetBlockCount,
 	testGetBlockHash,
+	testGenerateToAddress,
+	testGenerateBlock,
 }
 
 var primaryHarness *rpctest.Harness
@@ -0,0 +1,169 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// poolAncestors returns the transactions in the main pool the passed
+// transaction depends on, directly or indirectly.  The walk stops as soon as
+// more than the passed limit of ancestors are found, in which case false is
+// returned.  A negative limit means no limit.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) poolAncestors(tx *btcutil.Tx, limit int) (map[chainhash.Hash]*TxDesc, bool) {
+	ancestors := make(map[chainhash.Hash]*TxDesc)
+	stack := []*btcutil.Tx{tx}
+	for len(stack) > 0 {
+		tx := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, txIn := range tx.MsgTx().TxIn {
+			hash := txIn.PreviousOutPoint.Hash
+			if _, ok := ancestors[hash]; ok {
+				continue
+			}
+			desc, ok := mp.pool[hash]
+			if !ok {
+				continue
+			}
+			if limit >= 0 && len(ancestors) >= limit {
+				return ancestors, false
+			}
+			ancestors[hash] = desc
+			stack = append(stack, desc.Tx)
+		}
+	}
+	return ancestors, true
+}
+
+// poolDescendants returns the transactions in the main pool which depend on
+// the passed transaction, directly or indirectly.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) poolDescendants(tx *btcutil.Tx) map[chainhash.Hash]*TxDesc {
+	descendants := make(map[chainhash.Hash]*TxDesc)
+	stack := []*btcutil.Tx{tx}
+	for len(stack) > 0 {
+		tx := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		prevOut := wire.OutPoint{Hash: *tx.Hash()}
+		for i := range tx.MsgTx().TxOut {
+			prevOut.Index = uint32(i)
+			redeemer, ok := mp.outpoints[prevOut]
+			if !ok {
+				continue
+			}
+			hash := *redeemer.Hash()
+			if _, ok := descendants[hash]; ok {
+				continue
+			}
+			desc, ok := mp.pool[hash]
+			if !ok {
+				continue
+			}
+			descendants[hash] = desc
+			stack = append(stack, redeemer)
+		}
+	}
+	return descendants
+}
+
+// checkPackageLimits returns an error when adding the passed transaction to the
+// main pool would give it more unconfirmed ancestors, or give one of them more
+// unconfirmed descendants, than the policy allows.  The limits include the
+// transaction itself.  Since every transaction in the main pool was checked
+// against them, they also bound the work of maintaining the aggregates.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkPackageLimits(tx *btcutil.Tx) error {
+	limits := &mp.cfg.Policy
+	ancestors, ok := mp.poolAncestors(tx, limits.MaxAncestorCount-1)
+	if !ok {
+		str := fmt.Sprintf("transaction %v has more than the limit "+
+			"of %d unconfirmed ancestors", tx.Hash(),
+			limits.MaxAncestorCount-1)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+
+	size := GetTxVirtualSize(tx)
+	ancestorSize := size
+	for hash, ancestor := range ancestors {
+		ancestorSize += GetTxVirtualSize(ancestor.Tx)
+		if ancestor.DescendantCount >= limits.MaxDescendantCount {
+			str := fmt.Sprintf("transaction %v would exceed the "+
+				"limit of %d descendants of unconfirmed "+
+				"ancestor %v", tx.Hash(),
+				limits.MaxDescendantCount, hash)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+		if ancestor.DescendantSize+size > limits.MaxDescendantSize {
+			str := fmt.Sprintf("transaction %v would exceed the "+
+				"limit of %d bytes of descendants of "+
+				"unconfirmed ancestor %v", tx.Hash(),
+				limits.MaxDescendantSize, hash)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+	}
+	if ancestorSize > limits.MaxAncestorSize {
+		str := fmt.Sprintf("transaction %v and its unconfirmed "+
+			"ancestors exceed the limit of %d bytes", tx.Hash(),
+			limits.MaxAncestorSize)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+	return nil
+}
+
+// addToAggregates initializes the ancestor and descendant aggregates of the
+// passed transaction, which must have just been added to the main pool, and
+// adds it to the descendant aggregates of its ancestors.  A transaction which
+// was just added can't have any descendants in the main pool yet since they
+// would have been orphans.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) addToAggregates(txD *TxDesc) {
+	size := GetTxVirtualSize(txD.Tx)
+	txD.AncestorCount = 1
+	txD.AncestorSize = size
+	txD.AncestorFees = txD.Fee
+	txD.DescendantCount = 1
+	txD.DescendantSize = size
+	txD.DescendantFees = txD.Fee
+
+	ancestors, _ := mp.poolAncestors(txD.Tx, -1)
+	for _, ancestor := range ancestors {
+		txD.AncestorCount++
+		txD.AncestorSize += GetTxVirtualSize(ancestor.Tx)
+		txD.AncestorFees += ancestor.Fee
+
+		ancestor.DescendantCount++
+		ancestor.DescendantSize += size
+		ancestor.DescendantFees += txD.Fee
+	}
+}
+
+// removeFromAggregates removes the passed transaction, which is about to be
+// removed from the main pool, from the descendant aggregates of its ancestors
+// and the ancestor aggregates of its descendants.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) removeFromAggregates(txD *TxDesc) {
+	size := GetTxVirtualSize(txD.Tx)
+	ancestors, _ := mp.poolAncestors(txD.Tx, -1)
+	for _, ancestor := range ancestors {
+		ancestor.DescendantCount--
+		ancestor.DescendantSize -= size
+		ancestor.DescendantFees -= txD.Fee
+	}
+	for _, descendant := range mp.poolDescendants(txD.Tx) {
+		descendant.AncestorCount--
+		descendant.AncestorSize -= size
+		descendant.AncestorFees -= txD.Fee
+	}
+}
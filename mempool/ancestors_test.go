@@ -0,0 +1,156 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestAncestorAggregates ensures the ancestor and descendant aggregates of the
+// transactions in the pool are maintained as transactions are added and
+// removed.
+func TestAncestorAggregates(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	chainedTxns, err := harness.CreateTxChain(spendableOuts[0], 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chainedTxns {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+		}
+	}
+
+	sizes := make([]int64, len(chainedTxns))
+	for i, tx := range chainedTxns {
+		sizes[i] = GetTxVirtualSize(tx)
+	}
+	checkAggregates := func(tx *btcutil.Tx, ancestorCount int,
+		ancestorSize int64, descendantCount int, descendantSize int64) {
+
+		desc := harness.txPool.pool[*tx.Hash()]
+		if desc.AncestorCount != ancestorCount ||
+			desc.AncestorSize != ancestorSize ||
+			desc.DescendantCount != descendantCount ||
+			desc.DescendantSize != descendantSize {
+
+			t.Fatalf("unexpected aggregates for %v: ancestors %d "+
+				"(%d bytes), descendants %d (%d bytes)",
+				tx.Hash(), desc.AncestorCount,
+				desc.AncestorSize, desc.DescendantCount,
+				desc.DescendantSize)
+		}
+	}
+	total := sizes[0] + sizes[1] + sizes[2]
+	checkAggregates(chainedTxns[0], 1, sizes[0], 3, total)
+	checkAggregates(chainedTxns[1], 2, sizes[0]+sizes[1], 2, total-sizes[0])
+	checkAggregates(chainedTxns[2], 3, total, 1, sizes[2])
+
+	// Removing the first transaction, as when it is included in a block,
+	// removes it from the aggregates of its descendants.
+	harness.txPool.RemoveTransaction(chainedTxns[0], false,
+		RemovalReasonBlock)
+	checkAggregates(chainedTxns[1], 1, sizes[1], 2, sizes[1]+sizes[2])
+	checkAggregates(chainedTxns[2], 2, sizes[1]+sizes[2], 1, sizes[2])
+
+	// Removing the last transaction removes it from the aggregates of its
+	// ancestors.
+	harness.txPool.RemoveTransaction(chainedTxns[2], false,
+		RemovalReasonUnknown)
+	checkAggregates(chainedTxns[1], 1, sizes[1], 1, sizes[1])
+
+	// The descriptors returned for mining are snapshots of the aggregates.
+	descs := harness.txPool.MiningDescs()
+	if len(descs) != 1 || descs[0].AncestorSize != sizes[1] {
+		t.Fatalf("unexpected mining descriptors %+v", descs)
+	}
+	harness.txPool.pool[*chainedTxns[1].Hash()].AncestorSize = 0
+	if descs[0].AncestorSize != sizes[1] {
+		t.Fatal("mining descriptor changed along with the pool")
+	}
+}
+
+// TestPackageLimits ensures transactions which would exceed the ancestor or
+// descendant limits are rejected.
+func TestPackageLimits(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	checkRejected := func(tx *btcutil.Tx, what string) {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if code, _ := extractRejectCode(err); code != wire.RejectNonstandard {
+			t.Fatalf("%s: got error %v, want reject code %v", what,
+				err, wire.RejectNonstandard)
+		}
+		if harness.txPool.HaveTransaction(tx.Hash()) {
+			t.Fatalf("%s: transaction was added to the pool", what)
+		}
+	}
+
+	// A chain of transactions is limited to the default number of
+	// ancestors, including the transaction itself.
+	chainedTxns, err := harness.CreateTxChain(spendableOuts[0],
+		DefaultMaxAncestorCount+1)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chainedTxns[:DefaultMaxAncestorCount] {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+		}
+	}
+	checkRejected(chainedTxns[DefaultMaxAncestorCount],
+		"too many ancestors")
+
+	// The children of a transaction are limited by its descendant count.
+	harness, spendableOuts, err = newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	harness.txPool.cfg.Policy.MaxDescendantCount = 3
+	parent, err := harness.CreateSignedTx(spendableOuts[:1], 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	children := make([]*btcutil.Tx, 3)
+	for i := range children {
+		children[i], err = harness.CreateSignedTx([]spendableOutput{
+			txOutToSpendableOut(parent, uint32(i)),
+		}, 1)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+	}
+	for _, tx := range []*btcutil.Tx{parent, children[0], children[1]} {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+		}
+	}
+	checkRejected(children[2], "too many descendants")
+
+	// The sizes are limited as well.
+	harness.txPool.cfg.Policy.MaxDescendantCount = DefaultMaxDescendantCount
+	harness.txPool.cfg.Policy.MaxDescendantSize =
+		harness.txPool.pool[*parent.Hash()].DescendantSize
+	checkRejected(children[2], "descendants too large")
+	harness.txPool.cfg.Policy.MaxDescendantSize = DefaultMaxDescendantSize
+	harness.txPool.cfg.Policy.MaxAncestorSize = GetTxVirtualSize(parent)
+	checkRejected(children[2], "ancestors too large")
+}
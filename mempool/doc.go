@@ -50,19 +50,29 @@ be an exhaustive list.
    - Individual orphan transaction query support
  - Configurable transaction acceptance policy
    - Option to accept or reject standard transactions
+   - Exceptions accepting specific non-standard transactions by hash or
+     script template
    - Option to accept or reject transactions based on priority calculations
    - Rate limiting of low-fee and free transactions
    - Non-zero fee threshold
    - Max signature operations per transaction
    - Max orphan transaction size
    - Max number of orphan transactions allowed
+   - Max pool size with eviction of the lowest fee rate transactions
+   - Max transaction age
+   - Max number and size of the unconfirmed ancestors and descendants of a
+     transaction
  - Additional metadata tracking for each transaction
    - Timestamp when the transaction was added to the pool
    - Most recent block height when the transaction was added to the pool
    - The fee the transaction pays
    - The starting priority for the transaction
+   - The number, size, and fees of the transactions it depends on and the
+     transactions which depend on it
  - Manual control of transaction removal
    - Recursive removal of all dependent transactions
+ - Notification of removed transactions along with the reason for removal
+ - Fee rate histogram of the pool
 
 Errors
 
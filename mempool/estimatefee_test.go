@@ -61,7 +61,7 @@ func (eft *estimateFeeTester) testTx(fee btcutil.Amount) *TxDesc {
 }
 
 func expectedFeePerKilobyte(t *TxDesc) BtcPerKilobyte {
-	size := float64(t.TxDesc.Tx.MsgTx().SerializeSize())
+	size := float64(GetTxVirtualSize(t.TxDesc.Tx))
 	fee := float64(t.TxDesc.Fee)
 
 	return SatoshiPerByte(fee / size).ToBtcPerKb()
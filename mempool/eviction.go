@@ -0,0 +1,117 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"time"
+)
+
+// RemovalReason describes why a transaction was removed from the main pool.
+type RemovalReason string
+
+// These constants define the reasons a transaction is removed from the main
+// pool.
+const (
+	// RemovalReasonBlock indicates the transaction was included in a block
+	// connected to the main chain.
+	RemovalReasonBlock RemovalReason = "block"
+
+	// RemovalReasonConflict indicates the transaction, or one of the
+	// transactions it depends on, spends an output which is also spent by
+	// a transaction in a block connected to the main chain.
+	RemovalReasonConflict RemovalReason = "conflict"
+
+	// RemovalReasonReorg indicates the transaction depends on a transaction
+	// from a disconnected block which is no longer valid.
+	RemovalReasonReorg RemovalReason = "reorg"
+
+	// RemovalReasonExpiry indicates the transaction, or one of the
+	// transactions it depends on, stayed in the pool for longer than the
+	// maximum transaction age.
+	RemovalReasonExpiry RemovalReason = "expiry"
+
+	// RemovalReasonSizeLimit indicates the transaction, or one of the
+	// transactions it depends on, was evicted to keep the pool within its
+	// maximum size.
+	RemovalReasonSizeLimit RemovalReason = "sizelimit"
+
+	// RemovalReasonUnknown indicates the transaction was removed for
+	// another reason, such as by request of the caller.
+	RemovalReasonUnknown RemovalReason = "unknown"
+)
+
+// FeeRateBucket describes the transactions in the main pool whose fee per
+// kilobyte falls within a range.  It is returned by FeeRateHistogram.
+type FeeRateBucket struct {
+	// MinFeePerKB is the minimum fee per kilobyte of the transactions in
+	// the bucket.
+	MinFeePerKB int64
+
+	// Count is the number of transactions in the bucket.
+	Count int
+
+	// Size is the total virtual size of the transactions in the bucket.
+	Size int64
+}
+
+// lowestFeeRateDesc returns the transaction in the main pool with the lowest fee
+// per kilobyte, or nil when the pool is empty.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) lowestFeeRateDesc() *TxDesc {
+	var lowest *TxDesc
+	for _, desc := range mp.pool {
+		if lowest == nil || desc.FeePerKB < lowest.FeePerKB {
+			lowest = desc
+		}
+	}
+	return lowest
+}
+
+// limitPoolSize evicts the transactions which have been in the main pool for
+// longer than the maximum transaction age, followed by the transactions with
+// the lowest fee per kilobyte until the pool no longer exceeds its maximum
+// size.  The transactions which depend on an evicted transaction are evicted
+// as well, since they would otherwise become orphans.  Expired transactions
+// are only scanned for periodically.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) limitPoolSize(now time.Time) {
+	if maxAge := mp.cfg.Policy.MaxTxAge; maxAge > 0 &&
+		now.After(mp.nextTxExpireScan) {
+
+		origNumTxs := len(mp.pool)
+		for _, desc := range mp.pool {
+			if now.Sub(desc.Added) > maxAge {
+				mp.removeTransaction(desc.Tx, true,
+					RemovalReasonExpiry)
+			}
+		}
+		mp.nextTxExpireScan = now.Add(txExpireScanInterval)
+
+		if numExpired := origNumTxs - len(mp.pool); numExpired > 0 {
+			log.Debugf("Expired %d %s (remaining: %d)", numExpired,
+				pickNoun(numExpired, "transaction", "transactions"),
+				len(mp.pool))
+		}
+	}
+
+	maxSize := mp.cfg.Policy.MaxPoolSize
+	if maxSize <= 0 || mp.totalSize <= maxSize {
+		return
+	}
+	origNumTxs := len(mp.pool)
+	for mp.totalSize > maxSize {
+		desc := mp.lowestFeeRateDesc()
+		if desc == nil {
+			break
+		}
+		mp.removeTransaction(desc.Tx, true, RemovalReasonSizeLimit)
+	}
+	numEvicted := origNumTxs - len(mp.pool)
+	log.Debugf("Evicted %d %s to limit the pool size (remaining: %d)",
+		numEvicted, pickNoun(numEvicted, "transaction", "transactions"),
+		len(mp.pool))
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// createTxWithFee returns a signed transaction which spends the passed output
+// of the harness to a single output paying the passed fee.
+func createTxWithFee(p *poolHarness, input spendableOutput, fee int64) (*btcutil.Tx, error) {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: input.outPoint,
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(&wire.TxOut{
+		PkScript: p.payScript,
+		Value:    int64(input.amount) - fee,
+	})
+	sigScript, err := txscript.SignatureScript(tx, 0, p.payScript,
+		txscript.SigHashAll, p.signKey, true)
+	if err != nil {
+		return nil, err
+	}
+	tx.TxIn[0].SignatureScript = sigScript
+	return btcutil.NewTx(tx), nil
+}
+
+// TestPoolEviction ensures transactions are evicted when the pool exceeds its
+// maximum size or they expire, and that the removals are reported with the
+// correct reasons.
+func TestPoolEviction(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	removed := make(map[chainhash.Hash]RemovalReason)
+	harness.txPool.cfg.TxRemoved = func(tx *btcutil.Tx, reason RemovalReason) {
+		removed[*tx.Hash()] = reason
+	}
+
+	// Split the spendable output into several confirmed outputs so the
+	// transactions created below don't depend on each other.
+	split, err := harness.CreateSignedTx(spendableOuts, 5)
+	if err != nil {
+		t.Fatalf("unable to create split transaction: %v", err)
+	}
+	harness.chain.utxos.AddTxOuts(split, harness.chain.BestHeight())
+
+	var txns []*btcutil.Tx
+	for i, fee := range []int64{1000, 5000, 3000, 500} {
+		tx, err := createTxWithFee(harness, txOutToSpendableOut(split,
+			uint32(i)), fee)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		txns = append(txns, tx)
+	}
+
+	// Limit the pool to two transactions.
+	txSize := GetTxVirtualSize(txns[0])
+	harness.txPool.cfg.Policy.MaxPoolSize = 2*txSize + txSize/2
+	for _, tx := range txns[:2] {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+		}
+	}
+
+	// Adding a third transaction evicts the one with the lowest fee rate.
+	_, err = harness.txPool.ProcessTransaction(txns[2], false, false, 0)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+	}
+	testPoolMembership(tc, txns[0], false, false)
+	testPoolMembership(tc, txns[2], false, true)
+	if reason := removed[*txns[0].Hash()]; reason != RemovalReasonSizeLimit {
+		t.Fatalf("unexpected removal reason %q", reason)
+	}
+
+	// A transaction paying less than all of the transactions in the full
+	// pool is rejected.
+	_, err = harness.txPool.ProcessTransaction(txns[3], false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectInsufficientFee {
+		t.Fatalf("ProcessTransaction: got %v, want insufficient fee "+
+			"error", err)
+	}
+	testPoolMembership(tc, txns[3], false, false)
+	if _, ok := removed[*txns[3].Hash()]; ok {
+		t.Fatal("removal reported for rejected transaction")
+	}
+
+	// The fee rate histogram includes the remaining transactions.
+	histogram := harness.txPool.FeeRateHistogram([]int64{0, 20000})
+	if histogram[0].Count != 1 || histogram[1].Count != 1 ||
+		histogram[1].Size != GetTxVirtualSize(txns[1]) {
+
+		t.Fatalf("unexpected histogram %+v", histogram)
+	}
+
+	// Transactions older than the maximum age are evicted by the next
+	// scan.
+	harness.txPool.cfg.Policy.MaxTxAge = time.Hour
+	harness.txPool.pool[*txns[1].Hash()].Added = time.Now().Add(-2 * time.Hour)
+	harness.txPool.limitPoolSize(time.Now())
+	testPoolMembership(tc, txns[1], false, true)
+	harness.txPool.limitPoolSize(time.Now().Add(txExpireScanInterval * 2))
+	testPoolMembership(tc, txns[1], false, false)
+	if reason := removed[*txns[1].Hash()]; reason != RemovalReasonExpiry {
+		t.Fatalf("unexpected removal reason %q", reason)
+	}
+
+	// Removals requested by the caller are reported with the passed reason.
+	harness.txPool.RemoveTransaction(txns[2], false, RemovalReasonBlock)
+	if reason := removed[*txns[2].Hash()]; reason != RemovalReasonBlock {
+		t.Fatalf("unexpected removal reason %q", reason)
+	}
+	if harness.txPool.totalSize != 0 {
+		t.Fatalf("unexpected total size %d after removing all "+
+			"transactions", harness.txPool.totalSize)
+	}
+}
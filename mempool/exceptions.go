@@ -0,0 +1,155 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// templateDataWildcard is the token which matches any pushed data or
+	// small integer in a script template.
+	templateDataWildcard = "<data>"
+
+	// exemptVerifyFlags are the script verification flags used for the
+	// transactions covered by policy exceptions.  They only include the
+	// flags which are enforced by the consensus rules, so those
+	// transactions must be valid for inclusion in a block, but are not
+	// subject to the additional policy flags in
+	// txscript.StandardVerifyFlags.
+	exemptVerifyFlags = txscript.ScriptBip16 |
+		txscript.ScriptVerifyDERSignatures |
+		txscript.ScriptVerifyCheckLockTimeVerify |
+		txscript.ScriptVerifyCheckSequenceVerify |
+		txscript.ScriptVerifyWitness |
+		txscript.ScriptStrictMultiSig |
+		txscript.ScriptVerifyTaproot
+)
+
+// PolicyExceptions houses the transactions an operator has configured to be
+// accepted into the memory pool, and therefore relayed, even though they are
+// not standard.  This is useful when coordinating recovery transactions or
+// protocol upgrades which the standardness rules would otherwise keep from
+// propagating.  Transactions covered by an exception skip the standardness,
+// signature operation limit and policy script flag checks, but must still
+// satisfy all of the consensus rules.
+//
+// Transactions are covered by an exception either by their hash or when one of
+// their output scripts, or one of the scripts of the outputs they spend,
+// matches a script template.  Templates use the same one-line disassembly
+// format as the decodescript RPC, such as "OP_DUP OP_HASH160 <data>
+// OP_EQUALVERIFY OP_CHECKSIG", where the <data> token matches any pushed data
+// or small integer.
+type PolicyExceptions struct {
+	txHashes  map[chainhash.Hash]struct{}
+	templates [][]string
+}
+
+// NewPolicyExceptions returns policy exceptions for the passed transaction
+// hashes and script templates.  An error is returned when a template is empty
+// or refers to an unknown opcode.
+func NewPolicyExceptions(txHashes []chainhash.Hash, templates []string) (*PolicyExceptions, error) {
+	e := &PolicyExceptions{
+		txHashes: make(map[chainhash.Hash]struct{}, len(txHashes)),
+	}
+	for _, txHash := range txHashes {
+		e.txHashes[txHash] = struct{}{}
+	}
+	for _, template := range templates {
+		tokens := strings.Fields(template)
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("script template %q is empty",
+				template)
+		}
+		for _, token := range tokens {
+			if !strings.HasPrefix(token, "OP_") {
+				continue
+			}
+			if _, ok := txscript.OpcodeByName[token]; !ok {
+				return nil, fmt.Errorf("script template %q "+
+					"contains unknown opcode %s", template,
+					token)
+			}
+		}
+		e.templates = append(e.templates, tokens)
+	}
+	return e, nil
+}
+
+// matchTemplate returns whether the passed script matches any of the script
+// templates.
+func (e *PolicyExceptions) matchTemplate(script []byte) bool {
+	if len(e.templates) == 0 {
+		return false
+	}
+	disasm, err := txscript.DisasmString(script)
+	if err != nil {
+		return false
+	}
+	tokens := strings.Fields(disasm)
+
+nextTemplate:
+	for _, template := range e.templates {
+		if len(template) != len(tokens) {
+			continue
+		}
+		for i, token := range tokens {
+			if template[i] == templateDataWildcard &&
+				!strings.HasPrefix(token, "OP_") {
+
+				continue
+			}
+			if template[i] != token {
+				continue nextTemplate
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// hasTemplates returns whether there are any script templates.  It is safe to
+// call on nil exceptions.
+func (e *PolicyExceptions) hasTemplates() bool {
+	return e != nil && len(e.templates) != 0
+}
+
+// isExempt returns whether the passed transaction is covered by an exception.
+// The scripts of the outputs it spends are only matched against the script
+// templates when the passed view is not nil, in which case it must contain all
+// of them.  It is safe to call on nil exceptions, which cover no transactions.
+func (e *PolicyExceptions) isExempt(tx *btcutil.Tx, utxoView *blockchain.UtxoViewpoint) bool {
+	if e == nil {
+		return false
+	}
+	if _, ok := e.txHashes[*tx.Hash()]; ok {
+		return true
+	}
+	for _, txOut := range tx.MsgTx().TxOut {
+		if e.matchTemplate(txOut.PkScript) {
+			return true
+		}
+	}
+	if utxoView == nil {
+		return false
+	}
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevOut := txIn.PreviousOutPoint
+		entry := utxoView.LookupEntry(&prevOut.Hash)
+		if entry == nil {
+			continue
+		}
+		if e.matchTemplate(entry.PkScriptByIndex(prevOut.Index)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// createTxToScript returns a signed transaction of the passed version which
+// spends the passed output of the harness to a single output with the passed
+// public key script.
+func createTxToScript(p *poolHarness, input spendableOutput, version int32, pkScript []byte) (*btcutil.Tx, error) {
+	tx := wire.NewMsgTx(version)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: input.outPoint,
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(&wire.TxOut{
+		PkScript: pkScript,
+		Value:    int64(input.amount),
+	})
+	sigScript, err := txscript.SignatureScript(tx, 0, p.payScript,
+		txscript.SigHashAll, p.signKey, true)
+	if err != nil {
+		return nil, err
+	}
+	tx.TxIn[0].SignatureScript = sigScript
+	return btcutil.NewTx(tx), nil
+}
+
+// TestPolicyExceptionTemplates ensures script templates are validated and
+// matched as expected.
+func TestPolicyExceptionTemplates(t *testing.T) {
+	t.Parallel()
+
+	p2pkh, err := txscript.NewScriptBuilder().AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).AddData(make([]byte, 20)).
+		AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).
+		Script()
+	if err != nil {
+		t.Fatalf("unable to build script: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		script   []byte
+		match    bool
+	}{{
+		name:     "exact",
+		template: "OP_DUP OP_HASH160 0000000000000000000000000000000000000000 OP_EQUALVERIFY OP_CHECKSIG",
+		script:   p2pkh,
+		match:    true,
+	}, {
+		name:     "wildcard",
+		template: "OP_DUP OP_HASH160 <data> OP_EQUALVERIFY OP_CHECKSIG",
+		script:   p2pkh,
+		match:    true,
+	}, {
+		name:     "wildcard does not match opcode",
+		template: "<data> OP_HASH160 <data> OP_EQUALVERIFY OP_CHECKSIG",
+		script:   p2pkh,
+		match:    false,
+	}, {
+		name:     "different length",
+		template: "OP_DUP OP_HASH160 <data> OP_EQUALVERIFY",
+		script:   p2pkh,
+		match:    false,
+	}, {
+		name:     "small integer",
+		template: "<data>",
+		script:   []byte{txscript.OP_TRUE},
+		match:    true,
+	}, {
+		name:     "unparsable script",
+		template: "<data>",
+		script:   []byte{txscript.OP_DATA_2, 0x01},
+		match:    false,
+	}}
+
+	for _, test := range tests {
+		e, err := NewPolicyExceptions(nil, []string{test.template})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if match := e.matchTemplate(test.script); match != test.match {
+			t.Fatalf("%s: got match %v, want %v", test.name, match,
+				test.match)
+		}
+	}
+
+	for _, template := range []string{"", " ", "OP_DUP OP_BOGUS"} {
+		if _, err := NewPolicyExceptions(nil, []string{template}); err == nil {
+			t.Fatalf("invalid template %q accepted", template)
+		}
+	}
+}
+
+// TestPolicyExceptions ensures non-standard transactions are only accepted
+// into the pool when they are covered by the policy exceptions.
+func TestPolicyExceptions(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	// A transaction paying to a non-standard script is rejected without an
+	// exception.
+	opTrue := []byte{txscript.OP_TRUE}
+	tx, err := createTxToScript(harness, spendableOuts[0], 1, opTrue)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+	if code, _ := extractRejectCode(err); code != wire.RejectNonstandard {
+		t.Fatalf("ProcessTransaction: got %v, want non-standard error",
+			err)
+	}
+	testPoolMembership(tc, tx, false, false)
+
+	// The same transaction is accepted once its output script matches a
+	// template.
+	harness.txPool.cfg.Policy.Exceptions, err = NewPolicyExceptions(nil,
+		[]string{"1"})
+	if err != nil {
+		t.Fatalf("NewPolicyExceptions: %v", err)
+	}
+	if _, err := harness.txPool.ProcessTransaction(tx, false, false, 0); err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept exempt tx: %v",
+			err)
+	}
+	testPoolMembership(tc, tx, false, true)
+
+	// A transaction which is itself non-standard, due to its version, is
+	// accepted when it spends an output matching a template.
+	spend := wire.NewMsgTx(2)
+	spend.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: *tx.Hash()},
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	spend.AddTxOut(&wire.TxOut{
+		PkScript: harness.payScript,
+		Value:    tx.MsgTx().TxOut[0].Value,
+	})
+	spendTx := btcutil.NewTx(spend)
+	if _, err := harness.txPool.ProcessTransaction(spendTx, false, false, 0); err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept exempt spend: %v",
+			err)
+	}
+	testPoolMembership(tc, spendTx, false, true)
+
+	// Transactions may also be exempted by their hash.
+	harness, spendableOuts, err = newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc = &testContext{t, harness}
+	tx, err = createTxToScript(harness, spendableOuts[0], 1,
+		[]byte{txscript.OP_2})
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	harness.txPool.cfg.Policy.Exceptions, err = NewPolicyExceptions(
+		[]chainhash.Hash{*tx.Hash()}, nil)
+	if err != nil {
+		t.Fatalf("NewPolicyExceptions: %v", err)
+	}
+	if _, err := harness.txPool.ProcessTransaction(tx, false, false, 0); err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept exempt tx: %v",
+			err)
+	}
+	testPoolMembership(tc, tx, false, true)
+}
@@ -8,6 +8,7 @@ import (
 	"container/list"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,6 +19,7 @@ import (
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/policy"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
@@ -38,6 +40,31 @@ const (
 	// orphanExpireScanInterval is the minimum amount of time in between
 	// scans of the orphan pool to evict expired transactions.
 	orphanExpireScanInterval = time.Minute * 5
+
+	// txExpireScanInterval is the minimum amount of time in between scans
+	// of the main pool to evict transactions which are older than the
+	// maximum transaction age.
+	txExpireScanInterval = time.Minute * 5
+
+	// DefaultMaxAncestorCount is the default maximum number of
+	// transactions in the main pool a transaction may depend on, including
+	// itself.
+	DefaultMaxAncestorCount = 25
+
+	// DefaultMaxAncestorSize is the default maximum total virtual size in
+	// bytes of a transaction and the transactions in the main pool it
+	// depends on.
+	DefaultMaxAncestorSize = 101000
+
+	// DefaultMaxDescendantCount is the default maximum number of
+	// transactions in the main pool which may depend on a transaction,
+	// including itself.
+	DefaultMaxDescendantCount = 25
+
+	// DefaultMaxDescendantSize is the default maximum total virtual size in
+	// bytes of a transaction and the transactions in the main pool which
+	// depend on it.
+	DefaultMaxDescendantSize = 101000
 )
 
 // Tag represents an identifier to use for tagging orphan transactions.  The
@@ -93,6 +120,12 @@ type Config struct {
 	// FeeEstimatator provides a feeEstimator. If it is not nil, the mempool
 	// records all new transactions it observes into the feeEstimator.
 	FeeEstimator *FeeEstimator
+
+	// TxRemoved defines the function to call when a transaction is removed
+	// from the main pool along with the reason it was removed.  It is
+	// called with the mempool lock held, so it must not call back into the
+	// pool.  This can be nil if the caller is not interested.
+	TxRemoved func(tx *btcutil.Tx, reason RemovalReason)
 }
 
 // Policy houses the policy (configuration parameters) which is used to
@@ -132,7 +165,45 @@ type Policy struct {
 
 	// MinRelayTxFee defines the minimum transaction fee in BTC/kB to be
 	// considered a non-zero fee.
+	//
+	// Deprecated: Use Standard.MinRelayTxFee instead.  This is only used,
+	// along with the default standardness rules, when Standard is not set.
 	MinRelayTxFee btcutil.Amount
+
+	// Standard defines the configurable standardness rules, such as the
+	// minimum relay fee and the dust threshold, applied to transactions.
+	Standard policy.Policy
+
+	// Exceptions defines the non-standard transactions which are accepted
+	// regardless of AcceptNonStd.  It may be nil when there are none.
+	Exceptions *PolicyExceptions
+
+	// MaxPoolSize is the maximum total virtual size in bytes of the
+	// transactions in the main pool.  The transactions with the lowest fee
+	// per kilobyte are evicted when it is exceeded.  Zero means unlimited.
+	MaxPoolSize int64
+
+	// MaxTxAge is the maximum amount of time a transaction may stay in the
+	// main pool before it is evicted.  Zero means transactions never
+	// expire.
+	MaxTxAge time.Duration
+
+	// RequireFeeSnipingLockTime defines whether new transactions must use
+	// a lock time which discourages fee sniping to be accepted.  Unlike the
+	// other standardness rules, it also applies when AcceptNonStd is set.
+	RequireFeeSnipingLockTime bool
+
+	// MaxAncestorCount and MaxAncestorSize are the maximum number and
+	// total virtual size of the transactions in the main pool a
+	// transaction may depend on, including itself.  MaxDescendantCount and
+	// MaxDescendantSize are the maximum number and total virtual size of
+	// the transactions in the main pool which may depend on a transaction,
+	// including itself.  Transactions which would exceed them are
+	// rejected.  Zero selects the defaults.
+	MaxAncestorCount   int
+	MaxAncestorSize    int64
+	MaxDescendantCount int
+	MaxDescendantSize  int64
 }
 
 // TxDesc is a descriptor containing a transaction in the mempool along with
@@ -159,7 +230,8 @@ type orphanTx struct {
 // peers.
 type TxPool struct {
 	// The following variables must only be used atomically.
-	lastUpdated int64 // last time pool was updated
+	lastUpdated int64  // last time pool was updated
+	generation  uint64 // number of times pool was updated
 
 	mtx           sync.RWMutex
 	cfg           Config
@@ -169,12 +241,18 @@ type TxPool struct {
 	outpoints     map[wire.OutPoint]*btcutil.Tx
 	pennyTotal    float64 // exponentially decaying total for penny spends.
 	lastPennyUnix int64   // unix time of last ``penny spend''
+	totalSize     int64   // total virtual size of the main pool.
 
 	// nextExpireScan is the time after which the orphan pool will be
 	// scanned in order to evict orphans.  This is NOT a hard deadline as
 	// the scan will only run when an orphan is added to the pool as opposed
 	// to on an unconditional timer.
 	nextExpireScan time.Time
+
+	// nextTxExpireScan is the time after which the main pool will be
+	// scanned in order to evict expired transactions.  Like the orphan
+	// scan, it only runs when a transaction is added to the pool.
+	nextTxExpireScan time.Time
 }
 
 // Ensure the TxPool type implements the mining.TxSource interface.
@@ -451,20 +529,24 @@ func (mp *TxPool) HaveTransaction(hash *chainhash.Hash) bool {
 // RemoveTransaction.  See the comment for RemoveTransaction for more details.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool) {
+func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool, reason RemovalReason) {
 	txHash := tx.Hash()
 	if removeRedeemers {
 		// Remove any transactions which rely on this one.
 		for i := uint32(0); i < uint32(len(tx.MsgTx().TxOut)); i++ {
 			prevOut := wire.OutPoint{Hash: *txHash, Index: i}
 			if txRedeemer, exists := mp.outpoints[prevOut]; exists {
-				mp.removeTransaction(txRedeemer, true)
+				mp.removeTransaction(txRedeemer, true, reason)
 			}
 		}
 	}
 
 	// Remove the transaction if needed.
 	if txDesc, exists := mp.pool[*txHash]; exists {
+		// Remove the transaction from the aggregates of the
+		// transactions which remain in the pool.
+		mp.removeFromAggregates(txDesc)
+
 		// Remove unconfirmed address index entries associated with the
 		// transaction if enabled.
 		if mp.cfg.AddrIndex != nil {
@@ -476,20 +558,27 @@ func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool) {
 			delete(mp.outpoints, txIn.PreviousOutPoint)
 		}
 		delete(mp.pool, *txHash)
+		mp.totalSize -= GetTxVirtualSize(txDesc.Tx)
 		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+		atomic.AddUint64(&mp.generation, 1)
+
+		if mp.cfg.TxRemoved != nil {
+			mp.cfg.TxRemoved(txDesc.Tx, reason)
+		}
 	}
 }
 
 // RemoveTransaction removes the passed transaction from the mempool. When the
 // removeRedeemers flag is set, any transactions that redeem outputs from the
 // removed transaction will also be removed recursively from the mempool, as
-// they would otherwise become orphans.
+// they would otherwise become orphans.  The reason is passed on to the
+// TxRemoved callback for each removed transaction.
 //
 // This function is safe for concurrent access.
-func (mp *TxPool) RemoveTransaction(tx *btcutil.Tx, removeRedeemers bool) {
+func (mp *TxPool) RemoveTransaction(tx *btcutil.Tx, removeRedeemers bool, reason RemovalReason) {
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	mp.removeTransaction(tx, removeRedeemers)
+	mp.removeTransaction(tx, removeRedeemers, reason)
 	mp.mtx.Unlock()
 }
 
@@ -506,7 +595,8 @@ func (mp *TxPool) RemoveDoubleSpends(tx *btcutil.Tx) {
 	for _, txIn := range tx.MsgTx().TxIn {
 		if txRedeemer, ok := mp.outpoints[txIn.PreviousOutPoint]; ok {
 			if !txRedeemer.Hash().IsEqual(tx.Hash()) {
-				mp.removeTransaction(txRedeemer, true)
+				mp.removeTransaction(txRedeemer, true,
+					RemovalReasonConflict)
 			}
 		}
 	}
@@ -527,7 +617,7 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil
 			Added:    time.Now(),
 			Height:   height,
 			Fee:      fee,
-			FeePerKB: fee * 1000 / int64(tx.MsgTx().SerializeSize()),
+			FeePerKB: fee * 1000 / GetTxVirtualSize(tx),
 		},
 		StartingPriority: mining.CalcPriority(tx.MsgTx(), utxoView, height),
 	}
@@ -536,7 +626,10 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil
 	for _, txIn := range tx.MsgTx().TxIn {
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
+	mp.addToAggregates(txD)
+	mp.totalSize += GetTxVirtualSize(tx)
 	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+	atomic.AddUint64(&mp.generation, 1)
 
 	// Add unconfirmed address index entries associated with the transaction
 	// if enabled.
@@ -675,11 +768,20 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 
 	medianTimePast := mp.cfg.MedianTimePast()
 
+	// Transactions covered by the policy exceptions are not subject to
+	// the standardness rules.  Whether the outputs they spend match a
+	// script template can only be determined once those outputs have been
+	// fetched, so the error for a non-standard transaction is held until
+	// then when there are script templates.
+	exceptions := mp.cfg.Policy.Exceptions
+	exempt := exceptions.isExempt(tx, nil)
+
 	// Don't allow non-standard transactions if the network parameters
 	// forbid their acceptance.
-	if !mp.cfg.Policy.AcceptNonStd {
+	var nonStdErr error
+	if !mp.cfg.Policy.AcceptNonStd && !exempt {
 		err = checkTransactionStandard(tx, nextBlockHeight,
-			medianTimePast, mp.cfg.Policy.MinRelayTxFee,
+			medianTimePast, &mp.cfg.Policy.Standard,
 			mp.cfg.Policy.MaxTxVersion)
 		if err != nil {
 			// Attempt to extract a reject code from the error so
@@ -691,7 +793,27 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 			}
 			str := fmt.Sprintf("transaction %v is not standard: %v",
 				txHash, err)
-			return nil, nil, txRuleError(rejectCode, str)
+			nonStdErr = txRuleError(rejectCode, str)
+			if !exceptions.hasTemplates() {
+				return nil, nil, nonStdErr
+			}
+		}
+	}
+
+	// Require new transactions to discourage fee sniping when configured
+	// to do so.  This is enforced even when non-standard transactions are
+	// accepted since it is opted into separately.
+	if nonStdErr == nil && isNew && !exempt &&
+		mp.cfg.Policy.RequireFeeSnipingLockTime {
+
+		err = checkFeeSnipingLockTime(tx, nextBlockHeight)
+		if err != nil {
+			str := fmt.Sprintf("transaction %v is not standard: %v",
+				txHash, err)
+			nonStdErr = txRuleError(wire.RejectNonstandard, str)
+			if !exceptions.hasTemplates() {
+				return nil, nil, nonStdErr
+			}
 		}
 	}
 
@@ -745,9 +867,42 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		}
 	}
 	if len(missingParents) > 0 {
+		if nonStdErr != nil {
+			return nil, nil, nonStdErr
+		}
 		return missingParents, nil, nil
 	}
 
+	// Now that the outputs the transaction spends are known, check whether
+	// any of them is covered by the policy exceptions.
+	if !exempt {
+		exempt = exceptions.isExempt(tx, utxoView)
+	}
+	if nonStdErr != nil && !exempt {
+		return nil, nil, nonStdErr
+	}
+	if exempt {
+		log.Debugf("Transaction %v is covered by the policy exceptions",
+			txHash)
+	}
+
+	// Don't allow the transaction into the mempool if it spends a taproot
+	// output and taproot isn't active yet since such spends are only
+	// protected by the consensus rules once it is.
+	if tx.MsgTx().HasWitness() && spendsTaprootOutput(tx, utxoView) {
+		taprootActive, err := mp.cfg.IsDeploymentActive(
+			chaincfg.DeploymentTaproot)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !taprootActive {
+			str := fmt.Sprintf("transaction %v spends a taproot "+
+				"output, but taproot isn't active yet", txHash)
+			return nil, nil, txRuleError(wire.RejectNonstandard, str)
+		}
+	}
+
 	// Don't allow the transaction into the mempool unless its sequence
 	// lock is active, meaning that it'll be allowed into the next block
 	// with respect to its defined relative lock times.
@@ -779,7 +934,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 
 	// Don't allow transactions with non-standard inputs if the network
 	// parameters forbid their acceptance.
-	if !mp.cfg.Policy.AcceptNonStd {
+	if !mp.cfg.Policy.AcceptNonStd && !exempt {
 		err := checkInputsStandard(tx, utxoView)
 		if err != nil {
 			// Attempt to extract a reject code from the error so
@@ -812,7 +967,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		}
 		return nil, nil, err
 	}
-	if sigOpCost > mp.cfg.Policy.MaxSigOpCostPerTx {
+	if sigOpCost > mp.cfg.Policy.MaxSigOpCostPerTx && !exempt {
 		str := fmt.Sprintf("transaction %v sigop cost is too high: %d > %d",
 			txHash, sigOpCost, mp.cfg.Policy.MaxSigOpCostPerTx)
 		return nil, nil, txRuleError(wire.RejectNonstandard, str)
@@ -831,7 +986,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	// high-priority transactions, don't require a fee for it.
 	serializedSize := GetTxVirtualSize(tx)
 	minFee := calcMinRequiredTxRelayFee(serializedSize,
-		mp.cfg.Policy.MinRelayTxFee)
+		mp.cfg.Policy.Standard.MinRelayTxFee)
 	if serializedSize >= (DefaultBlockPrioritySize-1000) && txFee < minFee {
 		str := fmt.Sprintf("transaction %v has %d fees which is under "+
 			"the required amount of %d", txHash, txFee,
@@ -879,10 +1034,14 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	}
 
 	// Verify crypto signatures for each input and reject the transaction if
-	// any don't verify.
+	// any don't verify.  Only the consensus flags are enforced for the
+	// transactions covered by the policy exceptions.
+	verifyFlags := txscript.StandardVerifyFlags
+	if exempt {
+		verifyFlags = exemptVerifyFlags
+	}
 	err = blockchain.ValidateTransactionScripts(tx, utxoView,
-		txscript.StandardVerifyFlags, mp.cfg.SigCache,
-		mp.cfg.HashCache)
+		verifyFlags, mp.cfg.SigCache, mp.cfg.HashCache)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
 			return nil, nil, chainRuleError(cerr)
@@ -890,9 +1049,46 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		return nil, nil, err
 	}
 
+	// Don't allow transactions which would make a chain of unconfirmed
+	// transactions in the pool longer or larger than the policy allows.
+	if err := mp.checkPackageLimits(tx); err != nil {
+		return nil, nil, err
+	}
+
+	// Reject the transaction when the pool is full and it doesn't pay a
+	// higher fee per kilobyte than the transactions which would have to be
+	// evicted to make room for it.
+	if mp.cfg.Policy.MaxPoolSize > 0 {
+		size := GetTxVirtualSize(tx)
+		feePerKB := txFee * 1000 / size
+		if mp.totalSize+size > mp.cfg.Policy.MaxPoolSize {
+			if minDesc := mp.lowestFeeRateDesc(); minDesc != nil &&
+				feePerKB <= minDesc.FeePerKB {
+
+				str := fmt.Sprintf("mempool full: transaction %v "+
+					"fee per kilobyte %d is not above the "+
+					"pool minimum of %d", txHash, feePerKB,
+					minDesc.FeePerKB)
+				return nil, nil, txRuleError(
+					wire.RejectInsufficientFee, str)
+			}
+		}
+	}
+
 	// Add to transaction pool.
 	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee)
 
+	// Evict expired transactions and the transactions with the lowest fee
+	// per kilobyte while the pool exceeds its maximum size.  The new
+	// transaction may be evicted along with a low fee parent, in which case
+	// it is rejected.
+	mp.limitPoolSize(time.Now())
+	if _, exists := mp.pool[*txHash]; !exists {
+		str := fmt.Sprintf("mempool full: transaction %v evicted along "+
+			"with its parents", txHash)
+		return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+	}
+
 	log.Debugf("Accepted transaction %v (pool size: %v)", txHash,
 		len(mp.pool))
 
@@ -1139,9 +1335,13 @@ func (mp *TxPool) TxDescs() []*TxDesc {
 func (mp *TxPool) MiningDescs() []*mining.TxDesc {
 	mp.mtx.RLock()
 	descs := make([]*mining.TxDesc, len(mp.pool))
+	copies := make([]mining.TxDesc, len(mp.pool))
 	i := 0
 	for _, desc := range mp.pool {
-		descs[i] = &desc.TxDesc
+		// Copy the descriptors since their ancestor and descendant
+		// aggregates are updated as the pool changes.
+		copies[i] = desc.TxDesc
+		descs[i] = &copies[i]
 		i++
 	}
 	mp.mtx.RUnlock()
@@ -1176,6 +1376,7 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*btcjson.GetRawMempoolVerboseRe
 		mpd := &btcjson.GetRawMempoolVerboseResult{
 			Size:             int32(tx.MsgTx().SerializeSize()),
 			Vsize:            int32(GetTxVirtualSize(tx)),
+			Weight:           int32(blockchain.GetTransactionWeight(tx)),
 			Fee:              btcutil.Amount(desc.Fee).ToBTC(),
 			Time:             desc.Added.Unix(),
 			Height:           int64(desc.Height),
@@ -1197,6 +1398,35 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*btcjson.GetRawMempoolVerboseRe
 	return result
 }
 
+// FeeRateHistogram returns the number and total virtual size of the
+// transactions in the main pool grouped by their fee per kilobyte.  The passed
+// bounds are the ascending minimum fees per kilobyte of the buckets, so each
+// bucket holds the transactions from its bound up to the bound of the next
+// one.  Transactions paying less than the first bound are not included.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) FeeRateHistogram(bounds []int64) []FeeRateBucket {
+	buckets := make([]FeeRateBucket, len(bounds))
+	for i, bound := range bounds {
+		buckets[i].MinFeePerKB = bound
+	}
+
+	mp.mtx.RLock()
+	for _, desc := range mp.pool {
+		i := sort.Search(len(bounds), func(i int) bool {
+			return bounds[i] > desc.FeePerKB
+		}) - 1
+		if i < 0 {
+			continue
+		}
+		buckets[i].Count++
+		buckets[i].Size += GetTxVirtualSize(desc.Tx)
+	}
+	mp.mtx.RUnlock()
+
+	return buckets
+}
+
 // LastUpdated returns the last time a transaction was added to or removed from
 // the main pool.  It does not include the orphan pool.
 //
@@ -1205,15 +1435,51 @@ func (mp *TxPool) LastUpdated() time.Time {
 	return time.Unix(atomic.LoadInt64(&mp.lastUpdated), 0)
 }
 
+// Generation returns a counter which is incremented every time a transaction is
+// added to or removed from the main pool.  Unlike LastUpdated, it changes with
+// every update, even when several updates happen within the same second.  It
+// does not include the orphan pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Generation() uint64 {
+	return atomic.LoadUint64(&mp.generation)
+}
+
 // New returns a new memory pool for validating and storing standalone
 // transactions until they are mined into a block.
 func New(cfg *Config) *TxPool {
-	return &TxPool{
-		cfg:            *cfg,
-		pool:           make(map[chainhash.Hash]*TxDesc),
-		orphans:        make(map[chainhash.Hash]*orphanTx),
-		orphansByPrev:  make(map[wire.OutPoint]map[chainhash.Hash]*btcutil.Tx),
-		nextExpireScan: time.Now().Add(orphanExpireScanInterval),
-		outpoints:      make(map[wire.OutPoint]*btcutil.Tx),
+	mp := &TxPool{
+		cfg:              *cfg,
+		pool:             make(map[chainhash.Hash]*TxDesc),
+		orphans:          make(map[chainhash.Hash]*orphanTx),
+		orphansByPrev:    make(map[wire.OutPoint]map[chainhash.Hash]*btcutil.Tx),
+		nextExpireScan:   time.Now().Add(orphanExpireScanInterval),
+		outpoints:        make(map[wire.OutPoint]*btcutil.Tx),
+		nextTxExpireScan: time.Now().Add(txExpireScanInterval),
+	}
+
+	// Callers which predate the standardness policy only set the
+	// deprecated minimum relay fee, which also determined dust, so the
+	// default rules are used with it.
+	standard := &mp.cfg.Policy.Standard
+	if *standard == (policy.Policy{}) {
+		*standard = *policy.New()
+		standard.MinRelayTxFee = mp.cfg.Policy.MinRelayTxFee
+		standard.DustRelayFee = mp.cfg.Policy.MinRelayTxFee
+	}
+
+	limits := &mp.cfg.Policy
+	if limits.MaxAncestorCount == 0 {
+		limits.MaxAncestorCount = DefaultMaxAncestorCount
+	}
+	if limits.MaxAncestorSize == 0 {
+		limits.MaxAncestorSize = DefaultMaxAncestorSize
+	}
+	if limits.MaxDescendantCount == 0 {
+		limits.MaxDescendantCount = DefaultMaxDescendantCount
+	}
+	if limits.MaxDescendantSize == 0 {
+		limits.MaxDescendantSize = DefaultMaxDescendantSize
 	}
+	return mp
 }
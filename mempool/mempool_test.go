@@ -16,6 +16,7 @@ import (
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/policy"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
@@ -311,8 +312,8 @@ func newPoolHarness(chainParams *chaincfg.Params) (*poolHarness, []spendableOutp
 				MaxOrphanTxs:         5,
 				MaxOrphanTxSize:      1000,
 				MaxSigOpCostPerTx:    blockchain.MaxBlockSigOpsCost / 4,
-				MinRelayTxFee:        1000, // 1 Satoshi per byte
 				MaxTxVersion:         1,
+				Standard:             *policy.New(),
 			},
 			ChainParams:      chainParams,
 			FetchUtxoView:    chain.FetchUtxoView,
@@ -794,3 +795,30 @@ func TestMultiInputOrphanDoubleSpend(t *testing.T) {
 	// was not moved to the transaction pool.
 	testPoolMembership(tc, doubleSpendTx, false, false)
 }
+
+// TestDeprecatedMinRelayTxFee ensures a pool configured with only the
+// deprecated minimum relay fee uses it along with the default standardness
+// rules, while a configured standardness policy takes precedence.
+func TestDeprecatedMinRelayTxFee(t *testing.T) {
+	t.Parallel()
+
+	mp := New(&Config{Policy: Policy{MinRelayTxFee: 5000}})
+	want := policy.New()
+	want.MinRelayTxFee = 5000
+	want.DustRelayFee = 5000
+	if mp.cfg.Policy.Standard != *want {
+		t.Fatalf("standardness policy: got %+v, want %+v",
+			mp.cfg.Policy.Standard, *want)
+	}
+
+	standard := policy.New()
+	standard.MinRelayTxFee = 2000
+	mp = New(&Config{Policy: Policy{
+		MinRelayTxFee: 5000,
+		Standard:      *standard,
+	}})
+	if mp.cfg.Policy.Standard != *standard {
+		t.Fatalf("standardness policy: got %+v, want %+v",
+			mp.cfg.Policy.Standard, *standard)
+	}
+}
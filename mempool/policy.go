@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/policy"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
@@ -44,15 +45,23 @@ const (
 
 	// DefaultMinRelayTxFee is the minimum fee in satoshi that is required
 	// for a transaction to be treated as free for relay and mining
-	// purposes.  It is also used to help determine if a transaction is
-	// considered dust and as a base for calculating minimum required fees
-	// for larger transactions.  This value is in Satoshi/1000 bytes.
-	DefaultMinRelayTxFee = btcutil.Amount(1000)
+	// purposes.  It is also used as a base for calculating minimum
+	// required fees for larger transactions.  This value is in
+	// Satoshi/1000 bytes.
+	DefaultMinRelayTxFee = policy.DefaultMinRelayTxFee
 
 	// maxStandardMultiSigKeys is the maximum number of public keys allowed
 	// in a multi-signature transaction output script for it to be
 	// considered standard.
 	maxStandardMultiSigKeys = 3
+
+	// maxFeeSnipingLockTimeDepth is the maximum number of blocks below the
+	// current best height the lock time of a transaction may be when
+	// anti-fee-sniping lock times are required.  Wallets following the
+	// practice set the lock time to the current height, but occasionally
+	// pick a random height up to this many blocks earlier so transactions
+	// which were delayed before being broadcast don't stand out.
+	maxFeeSnipingLockTimeDepth = 100
 )
 
 // calcMinRequiredTxRelayFee returns the minimum transaction fee required for a
@@ -123,14 +132,41 @@ func checkInputsStandard(tx *btcutil.Tx, utxoView *blockchain.UtxoViewpoint) err
 	return nil
 }
 
+// spendsTaprootOutput returns whether or not any of the inputs of the passed
+// transaction spend a pay-to-taproot output.  The referenced outputs must be
+// available in the passed view.
+func spendsTaprootOutput(tx *btcutil.Tx, utxoView *blockchain.UtxoViewpoint) bool {
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevOut := txIn.PreviousOutPoint
+		entry := utxoView.LookupEntry(&prevOut.Hash)
+		if entry == nil {
+			continue
+		}
+		originPkScript := entry.PkScriptByIndex(prevOut.Index)
+		if txscript.GetScriptClass(originPkScript) ==
+			txscript.WitnessV1TaprootTy {
+
+			return true
+		}
+	}
+	return false
+}
+
 // checkPkScriptStandard performs a series of checks on a transaction output
 // script (public key script) to ensure it is a "standard" public key script.
 // A standard public key script is one that is a recognized form, and for
-// multi-signature scripts, only contains from 1 to maxStandardMultiSigKeys
-// public keys.
-func checkPkScriptStandard(pkScript []byte, scriptClass txscript.ScriptClass) error {
+// multi-signature scripts, is permitted by the passed policy and only contains
+// from 1 to maxStandardMultiSigKeys public keys.
+func checkPkScriptStandard(pkScript []byte, scriptClass txscript.ScriptClass,
+	standard *policy.Policy) error {
+
 	switch scriptClass {
 	case txscript.MultiSigTy:
+		if !standard.PermitBareMultisig {
+			return txRuleError(wire.RejectNonstandard,
+				"bare multi-signature script")
+		}
+
 		numPubKeys, numSigs, err := txscript.CalcMultiSigStats(pkScript)
 		if err != nil {
 			str := fmt.Sprintf("multi-signature script parse "+
@@ -174,11 +210,11 @@ func checkPkScriptStandard(pkScript []byte, scriptClass txscript.ScriptClass) er
 }
 
 // isDust returns whether or not the passed transaction output amount is
-// considered dust or not based on the passed minimum transaction relay fee.
-// Dust is defined in terms of the minimum transaction relay fee.  In
-// particular, if the cost to the network to spend coins is more than 1/3 of the
-// minimum transaction relay fee, it is considered dust.
-func isDust(txOut *wire.TxOut, minRelayTxFee btcutil.Amount) bool {
+// considered dust or not based on the passed dust relay fee.  Dust is defined
+// in terms of the dust relay fee, which defaults to the minimum transaction
+// relay fee.  In particular, if the cost to the network to spend coins is more
+// than 1/3 of the dust relay fee, it is considered dust.
+func isDust(txOut *wire.TxOut, dustRelayFee btcutil.Amount) bool {
 	// Unspendable outputs are considered dust.
 	if txscript.IsUnspendable(txOut.PkScript) {
 		return true
@@ -255,18 +291,16 @@ func isDust(txOut *wire.TxOut, minRelayTxFee btcutil.Amount) bool {
 	}
 
 	// The output is considered dust if the cost to the network to spend the
-	// coins is more than 1/3 of the minimum free transaction relay fee.
-	// minFreeTxRelayFee is in Satoshi/KB, so multiply by 1000 to
-	// convert to bytes.
+	// coins is more than 1/3 of the dust relay fee.  dustRelayFee is in
+	// Satoshi/KB, so multiply by 1000 to convert to bytes.
 	//
 	// Using the typical values for a pay-to-pubkey-hash transaction from
-	// the breakdown above and the default minimum free transaction relay
-	// fee of 1000, this equates to values less than 546 satoshi being
-	// considered dust.
+	// the breakdown above and the default dust relay fee of 1000, this
+	// equates to values less than 546 satoshi being considered dust.
 	//
 	// The following is equivalent to (value/totalSize) * (1/3) * 1000
 	// without needing to do floating point math.
-	return txOut.Value*1000/(3*int64(totalSize)) < int64(minRelayTxFee)
+	return txOut.Value*1000/(3*int64(totalSize)) < int64(dustRelayFee)
 }
 
 // checkTransactionStandard performs a series of checks on a transaction to
@@ -277,7 +311,7 @@ func isDust(txOut *wire.TxOut, minRelayTxFee btcutil.Amount) bool {
 // of recognized forms, and not containing "dust" outputs (those that are
 // so small it costs more to process them than they are worth).
 func checkTransactionStandard(tx *btcutil.Tx, height int32,
-	medianTimePast time.Time, minRelayTxFee btcutil.Amount,
+	medianTimePast time.Time, standard *policy.Policy,
 	maxTxVersion int32) error {
 
 	// The transaction must be a currently supported version.
@@ -330,11 +364,30 @@ func checkTransactionStandard(tx *btcutil.Tx, height int32,
 	}
 
 	// None of the output public key scripts can be a non-standard script or
-	// be "dust" (except when the script is a null data script).
+	// be "dust" (except when the script is a null data script).  Null data
+	// scripts instead must not carry more data than the policy allows.
 	numNullDataOutputs := 0
 	for i, txOut := range msgTx.TxOut {
+		// The null data script class is limited to a single push of
+		// txscript.MaxDataCarrierSize bytes, so scripts carrying more
+		// data are located separately to allow the policy to raise the
+		// limit and to permit multiple pushes.
 		scriptClass := txscript.GetScriptClass(txOut.PkScript)
-		err := checkPkScriptStandard(txOut.PkScript, scriptClass)
+		dataSize, isNullData := policy.NullDataSize(txOut.PkScript,
+			standard.PermitMultiPushDataCarrier)
+		if isNullData {
+			if dataSize > standard.MaxDataCarrierSize {
+				str := fmt.Sprintf("transaction output %d: null "+
+					"data script carries %d bytes which is "+
+					"more than the allowed max of %d", i,
+					dataSize, standard.MaxDataCarrierSize)
+				return txRuleError(wire.RejectNonstandard, str)
+			}
+			scriptClass = txscript.NullDataTy
+		}
+
+		err := checkPkScriptStandard(txOut.PkScript, scriptClass,
+			standard)
 		if err != nil {
 			// Attempt to extract a reject code from the error so
 			// it can be retained.  When not possible, fall back to
@@ -352,7 +405,7 @@ func checkTransactionStandard(tx *btcutil.Tx, height int32,
 		// "dust".
 		if scriptClass == txscript.NullDataTy {
 			numNullDataOutputs++
-		} else if isDust(txOut, minRelayTxFee) {
+		} else if isDust(txOut, standard.DustRelayFee) {
 			str := fmt.Sprintf("transaction output %d: payment "+
 				"of %d is dust", i, txOut.Value)
 			return txRuleError(wire.RejectDust, str)
@@ -369,15 +422,54 @@ func checkTransactionStandard(tx *btcutil.Tx, height int32,
 	return nil
 }
 
+// checkFeeSnipingLockTime ensures the passed transaction uses a lock time which
+// discourages fee sniping, that is miners reorganizing recent blocks in order to
+// collect the fees of the transactions in them.  Such a transaction has a lock
+// time which is a block height no more than maxFeeSnipingLockTimeDepth blocks
+// below the current best height and at least one input with a sequence number
+// which does not disable the lock time, so it can only be included in a block
+// building on, or near, the current tip.  The passed height is the height of
+// the next block.
+func checkFeeSnipingLockTime(tx *btcutil.Tx, height int32) error {
+	msgTx := tx.MsgTx()
+	if msgTx.LockTime == 0 {
+		return txRuleError(wire.RejectNonstandard,
+			"transaction does not have an anti-fee-sniping lock time")
+	}
+	if msgTx.LockTime >= txscript.LockTimeThreshold {
+		str := fmt.Sprintf("transaction lock time %d is a timestamp "+
+			"rather than a block height", msgTx.LockTime)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+	bestHeight := int64(height) - 1
+	if int64(msgTx.LockTime) > bestHeight {
+		str := fmt.Sprintf("transaction lock time %d is above the "+
+			"current height of %d", msgTx.LockTime, bestHeight)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+	if int64(msgTx.LockTime) < bestHeight-maxFeeSnipingLockTimeDepth {
+		str := fmt.Sprintf("transaction lock time %d is more than %d "+
+			"blocks below the current height of %d", msgTx.LockTime,
+			maxFeeSnipingLockTimeDepth, bestHeight)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+
+	// The lock time is ignored when every input has the maximum sequence
+	// number.
+	for _, txIn := range msgTx.TxIn {
+		if txIn.Sequence != wire.MaxTxInSequenceNum {
+			return nil
+		}
+	}
+	return txRuleError(wire.RejectNonstandard, "transaction lock time "+
+		"is disabled by the sequence numbers of all inputs")
+}
+
 // GetTxVirtualSize computes the virtual size of a given transaction. A
 // transaction's virtual size is based off its weight, creating a discount for
 // any witness data it contains, proportional to the current
-// blockchain.WitnessScaleFactor value.
+// blockchain.WitnessScaleFactor value.  It is the same as
+// blockchain.GetTransactionVirtualSize.
 func GetTxVirtualSize(tx *btcutil.Tx) int64 {
-	// vSize := (weight(tx) + 3) / 4
-	//       := (((baseSize * 3) + totalSize) + 3) / 4
-	// We add 3 here as a way to compute the ceiling of the prior arithmetic
-	// to 4. The division by 4 creates a discount for wit witness data.
-	return (blockchain.GetTransactionWeight(tx) + (blockchain.WitnessScaleFactor - 1)) /
-		blockchain.WitnessScaleFactor
+	return blockchain.GetTransactionVirtualSize(tx)
 }
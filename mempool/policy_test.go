@@ -12,6 +12,7 @@ import (
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/policy"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
@@ -193,7 +194,7 @@ func TestCheckPkScriptStandard(t *testing.T) {
 			continue
 		}
 		scriptClass := txscript.GetScriptClass(script)
-		got := checkPkScriptStandard(script, scriptClass)
+		got := checkPkScriptStandard(script, scriptClass, policy.New())
 		if (test.isStandard && got != nil) ||
 			(!test.isStandard && got == nil) {
 
@@ -470,7 +471,7 @@ func TestCheckTransactionStandard(t *testing.T) {
 	for _, test := range tests {
 		// Ensure standardness is as expected.
 		err := checkTransactionStandard(btcutil.NewTx(&test.tx),
-			test.height, pastMedianTime, DefaultMinRelayTxFee, 1)
+			test.height, pastMedianTime, policy.New(), 1)
 		if err == nil && test.isStandard {
 			// Test passes since function returned standard for a
 			// transaction which is intended to be standard.
@@ -510,3 +511,232 @@ func TestCheckTransactionStandard(t *testing.T) {
 		}
 	}
 }
+
+// TestCheckTransactionStandardPolicy ensures checkTransactionStandard applies
+// the configurable standardness rules of the passed policy.
+func TestCheckTransactionStandardPolicy(t *testing.T) {
+	dummyTxIn := wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: 1},
+		SignatureScript:  bytes.Repeat([]byte{0x00}, 65),
+		Sequence:         wire.MaxTxInSequenceNum,
+	}
+	pk, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	multiSigScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_1).AddData(pk.PubKey().SerializeCompressed()).
+		AddOp(txscript.OP_1).AddOp(txscript.OP_CHECKMULTISIG).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	largeNullDataScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_RETURN).AddData(make([]byte, 100)).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	nullDataScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_RETURN).AddData(make([]byte, 30)).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	multiPushNullDataScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_RETURN).AddData(make([]byte, 30)).
+		AddData(make([]byte, 30)).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).AddData(make([]byte, 20)).
+		AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).
+		Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		txOut      wire.TxOut
+		modify     func(p *policy.Policy)
+		isStandard bool
+	}{
+		{
+			name:       "bare multisig permitted by default",
+			txOut:      wire.TxOut{Value: 100000, PkScript: multiSigScript},
+			isStandard: true,
+		},
+		{
+			name:  "bare multisig rejected",
+			txOut: wire.TxOut{Value: 100000, PkScript: multiSigScript},
+			modify: func(p *policy.Policy) {
+				p.PermitBareMultisig = false
+			},
+			isStandard: false,
+		},
+		{
+			name:       "large null data rejected by default",
+			txOut:      wire.TxOut{PkScript: largeNullDataScript},
+			isStandard: false,
+		},
+		{
+			name:  "large null data with raised limit",
+			txOut: wire.TxOut{PkScript: largeNullDataScript},
+			modify: func(p *policy.Policy) {
+				p.MaxDataCarrierSize = 100
+			},
+			isStandard: true,
+		},
+		{
+			name:  "null data with lowered limit",
+			txOut: wire.TxOut{PkScript: nullDataScript},
+			modify: func(p *policy.Policy) {
+				p.MaxDataCarrierSize = 20
+			},
+			isStandard: false,
+		},
+		{
+			name:       "multi-push null data rejected by default",
+			txOut:      wire.TxOut{PkScript: multiPushNullDataScript},
+			isStandard: false,
+		},
+		{
+			name:  "multi-push null data permitted",
+			txOut: wire.TxOut{PkScript: multiPushNullDataScript},
+			modify: func(p *policy.Policy) {
+				p.PermitMultiPushDataCarrier = true
+			},
+			isStandard: true,
+		},
+		{
+			name:  "multi-push null data over the limit",
+			txOut: wire.TxOut{PkScript: multiPushNullDataScript},
+			modify: func(p *policy.Policy) {
+				p.PermitMultiPushDataCarrier = true
+				p.MaxDataCarrierSize = 50
+			},
+			isStandard: false,
+		},
+		{
+			name:       "small output not dust by default",
+			txOut:      wire.TxOut{Value: 1000, PkScript: pkScript},
+			isStandard: true,
+		},
+		{
+			name:  "small output dust with raised dust relay fee",
+			txOut: wire.TxOut{Value: 1000, PkScript: pkScript},
+			modify: func(p *policy.Policy) {
+				p.DustRelayFee = 3000
+			},
+			isStandard: false,
+		},
+	}
+
+	for _, test := range tests {
+		standard := policy.New()
+		if test.modify != nil {
+			test.modify(standard)
+		}
+		tx := btcutil.NewTx(&wire.MsgTx{
+			Version: 1,
+			TxIn:    []*wire.TxIn{&dummyTxIn},
+			TxOut:   []*wire.TxOut{&test.txOut},
+		})
+		err := checkTransactionStandard(tx, 300000, time.Now(),
+			standard, 1)
+		if test.isStandard && err != nil {
+			t.Errorf("checkTransactionStandard (%s): unexpected "+
+				"error: %v", test.name, err)
+			continue
+		}
+		if !test.isStandard && err == nil {
+			t.Errorf("checkTransactionStandard (%s): standard when "+
+				"it should not be", test.name)
+		}
+	}
+}
+
+// TestCheckFeeSnipingLockTime tests the checkFeeSnipingLockTime API.
+func TestCheckFeeSnipingLockTime(t *testing.T) {
+	prevOutHash, err := chainhash.NewHashFromStr("01")
+	if err != nil {
+		t.Fatalf("NewShaHashFromStr: unexpected error: %v", err)
+	}
+	finalTxIn := wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: *prevOutHash, Index: 1},
+		Sequence:         wire.MaxTxInSequenceNum,
+	}
+	lockTxIn := finalTxIn
+	lockTxIn.Sequence = wire.MaxTxInSequenceNum - 1
+
+	const height = 300001
+	tests := []struct {
+		name     string
+		lockTime uint32
+		txIns    []*wire.TxIn
+		valid    bool
+	}{
+		{
+			name:     "Lock time at the current height",
+			lockTime: height - 1,
+			txIns:    []*wire.TxIn{&lockTxIn},
+			valid:    true,
+		},
+		{
+			name:     "Lock time at the maximum depth",
+			lockTime: height - 1 - maxFeeSnipingLockTimeDepth,
+			txIns:    []*wire.TxIn{&finalTxIn, &lockTxIn},
+			valid:    true,
+		},
+		{
+			name:     "No lock time",
+			lockTime: 0,
+			txIns:    []*wire.TxIn{&lockTxIn},
+			valid:    false,
+		},
+		{
+			name:     "Lock time below the maximum depth",
+			lockTime: height - 2 - maxFeeSnipingLockTimeDepth,
+			txIns:    []*wire.TxIn{&lockTxIn},
+			valid:    false,
+		},
+		{
+			name:     "Lock time above the current height",
+			lockTime: height,
+			txIns:    []*wire.TxIn{&lockTxIn},
+			valid:    false,
+		},
+		{
+			name:     "Lock time is a timestamp",
+			lockTime: txscript.LockTimeThreshold + height,
+			txIns:    []*wire.TxIn{&lockTxIn},
+			valid:    false,
+		},
+		{
+			name:     "Lock time disabled by sequence numbers",
+			lockTime: height - 1,
+			txIns:    []*wire.TxIn{&finalTxIn, &finalTxIn},
+			valid:    false,
+		},
+	}
+
+	for _, test := range tests {
+		tx := btcutil.NewTx(&wire.MsgTx{
+			Version:  1,
+			TxIn:     test.txIns,
+			LockTime: test.lockTime,
+		})
+		err := checkFeeSnipingLockTime(tx, height)
+		if test.valid && err != nil {
+			t.Errorf("checkFeeSnipingLockTime (%s): unexpected "+
+				"error: %v", test.name, err)
+			continue
+		}
+		if !test.valid {
+			code, ok := extractRejectCode(err)
+			if !ok || code != wire.RejectNonstandard {
+				t.Errorf("checkFeeSnipingLockTime (%s): got %v, "+
+					"want nonstandard reject", test.name, err)
+			}
+		}
+	}
+}
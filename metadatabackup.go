@@ -0,0 +1,414 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// metadataBackupDirName is the name of the directory within the data
+	// directory which houses the block database metadata snapshots.
+	metadataBackupDirName = "metadata_backups"
+
+	// metadataReplayDirName is the name of the directory within the data
+	// directory which houses the blocks which must be reprocessed after a
+	// metadata snapshot is restored.
+	metadataReplayDirName = "metadata_replay"
+
+	// metadataBackupPrefix is the prefix of the name of each metadata
+	// snapshot.  It is followed by the time the snapshot was taken, so the
+	// snapshots sort by age.
+	metadataBackupPrefix = "snapshot-"
+
+	// metadataBackupTimeFormat is the format of the time in the name of
+	// each metadata snapshot.
+	metadataBackupTimeFormat = "20060102150405"
+)
+
+// replayProgress tracks the progress of reprocessing the blocks left by a
+// recovery of the block database so it can be reported by the metrics server.
+// The fields must only be accessed atomically.
+var replayProgress struct {
+	processed uint64
+	rejected  uint64
+	active    int32
+	files     uint32
+	file      uint32
+}
+
+// errNoMetadataBackup is returned when there is no usable metadata snapshot to
+// restore.
+var errNoMetadataBackup = errors.New("no usable block database metadata " +
+	"snapshot found")
+
+// metadataBackupDir returns the directory which houses the block database
+// metadata snapshots.
+func metadataBackupDir() string {
+	return filepath.Join(cfg.DataDir, metadataBackupDirName)
+}
+
+// metadataReplayDir returns the directory which houses the blocks which must be
+// reprocessed after a metadata snapshot is restored.
+func metadataReplayDir() string {
+	return filepath.Join(cfg.DataDir, metadataReplayDirName)
+}
+
+// metadataBackups returns the paths of the metadata snapshots in the passed
+// directory ordered from oldest to newest.
+func metadataBackups(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var backups []string
+	for _, file := range files {
+		name := file.Name()
+		if !file.IsDir() || !strings.HasPrefix(name, metadataBackupPrefix) ||
+			strings.HasSuffix(name, ".tmp") {
+
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, name))
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// metadataBackupManager periodically takes snapshots of the block database
+// metadata, which contains the block index and chain state, and removes old
+// snapshots so only the configured number of the most recent ones are kept.
+type metadataBackupManager struct {
+	db       database.DB
+	dir      string
+	interval time.Duration
+	retain   int
+}
+
+// newMetadataBackupManager returns a new metadata backup manager which takes a
+// snapshot of the metadata of the passed database every interval and keeps the
+// passed number of snapshots in the passed directory.
+func newMetadataBackupManager(db database.DB, dir string,
+	interval time.Duration, retain int) *metadataBackupManager {
+
+	return &metadataBackupManager{
+		db:       db,
+		dir:      dir,
+		interval: interval,
+		retain:   retain,
+	}
+}
+
+// Backup takes a snapshot of the metadata and removes the oldest snapshots
+// exceeding the number of snapshots to keep.
+func (m *metadataBackupManager) Backup() error {
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	name := metadataBackupPrefix + start.UTC().Format(metadataBackupTimeFormat)
+	path := filepath.Join(m.dir, name)
+	if err := ffldb.SnapshotMetadata(m.db, path); err != nil {
+		return err
+	}
+	btcdLog.Infof("Took block database metadata snapshot %s in %v", name,
+		time.Since(start))
+
+	backups, err := metadataBackups(m.dir)
+	if err != nil {
+		return err
+	}
+	for len(backups) > m.retain {
+		btcdLog.Debugf("Removing metadata snapshot %s",
+			filepath.Base(backups[0]))
+		if err := os.RemoveAll(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Run takes a snapshot of the metadata every interval until the passed quit
+// channel is closed.  It must be run as a goroutine.
+func (m *metadataBackupManager) Run(quit <-chan struct{}) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.Backup(); err != nil {
+				btcdLog.Errorf("Unable to take block database "+
+					"metadata snapshot: %v", err)
+			}
+
+		case <-quit:
+			return
+		}
+	}
+}
+
+// restoreMetadata replaces the metadata of the block database at the passed
+// path with the most recent usable snapshot in the passed directory.  The
+// blocks which were stored after the snapshot was taken are moved to the
+// passed replay directory so they can be reprocessed with replayBlocks.
+func restoreMetadata(dbPath, backupDir, replayDir string) error {
+	backups, err := metadataBackups(backupDir)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		return errNoMetadataBackup
+	}
+
+	// Try the snapshots from newest to oldest.  The database is only
+	// modified once a usable snapshot is found.
+	for i := len(backups) - 1; i >= 0; i-- {
+		name := filepath.Base(backups[i])
+		err := ffldb.RestoreMetadata(dbPath, cfg.ColdBlockDir,
+			backups[i], replayDir)
+		if err == nil {
+			btcdLog.Infof("Restored block database metadata from "+
+				"snapshot %s", name)
+			return nil
+		}
+		if dbErr, ok := err.(database.Error); !ok ||
+			dbErr.ErrorCode != database.ErrCorruption {
+
+			return err
+		}
+		btcdLog.Warnf("Skipping unusable metadata snapshot %s: %v", name,
+			err)
+	}
+	return errNoMetadataBackup
+}
+
+// recoverBlockDB repairs the corrupted block database at the passed path so it
+// can be opened again.  The metadata is restored from the most recent usable
+// snapshot in the passed backup directory when there is one.  Otherwise it is
+// discarded, so the database is created again and rebuilt from the stored
+// blocks.  Either way, the blocks which are not referenced by the repaired
+// metadata are moved to the passed replay directory to be reprocessed with
+// replayBlocks.
+func recoverBlockDB(dbPath, backupDir, replayDir string) error {
+	err := restoreMetadata(dbPath, backupDir, replayDir)
+	if err != errNoMetadataBackup {
+		return err
+	}
+
+	btcdLog.Warnf("No usable metadata snapshot found in %s -- "+
+		"rebuilding the block database from the stored blocks",
+		backupDir)
+	if err := ffldb.ResetMetadata(dbPath, cfg.ColdBlockDir,
+		replayDir); err != nil {
+
+		return err
+	}
+	btcdLog.Infof("Discarded the block database metadata and moved all "+
+		"stored blocks to %s to be reprocessed", replayDir)
+	return nil
+}
+
+// replayBlocks processes the blocks left in the passed replay directory after
+// a metadata snapshot was restored, which brings the chain state back up to
+// date, and removes the directory once they have all been processed.  Nothing
+// is done when the directory does not exist.
+//
+// The stored blocks which lead to a checkpoint are processed with
+// BFCheckpointFastAdd, so they skip most of the checks.  The optional indexes
+// are not updated while replaying since they catch up with the chain on their
+// own when they are initialized.
+func replayBlocks(db database.DB, replayDir string, interrupt <-chan struct{}) error {
+	if !fileExists(replayDir) {
+		return nil
+	}
+
+	var checkpoints []chaincfg.Checkpoint
+	if !cfg.DisableCheckpoints {
+		checkpoints = mergeCheckpoints(activeNetParams.Checkpoints,
+			cfg.addCheckpoints)
+	}
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		Interrupt:   interrupt,
+		ChainParams: activeNetParams.Params,
+		Checkpoints: checkpoints,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Fetch the best header chain from the recovery peers, if any, so the
+	// stored blocks which are on a stale fork can be skipped rather than
+	// trusting whatever is on disk.
+	var staleFilter *staleBlockFilter
+	if len(cfg.RecoveryPeers) > 0 {
+		staleFilter, err = newRecoveryStaleFilter(chain,
+			cfg.RecoveryPeers, interrupt)
+		if err == errRecoveryInterrupted {
+			return nil
+		}
+		if err != nil {
+			btcdLog.Warnf("Reprocessing the stored blocks without "+
+				"a header chain to check them against: %v", err)
+		}
+	}
+
+	// Find the stored blocks which lead to a checkpoint so they can skip
+	// most of the checks while they are reprocessed.
+	headers := readReplayHeaders(replayDir, interrupt)
+	if interruptRequested(interrupt) {
+		return nil
+	}
+	if n := chain.AddCheckpointHeaders(headers); n > 0 {
+		btcdLog.Infof("Found %d stored blocks leading to a checkpoint", n)
+	}
+
+	btcdLog.Infof("Reprocessing blocks stored after the restored metadata "+
+		"snapshot from height %d", chain.BestSnapshot().Height)
+	scanner := ffldb.NewBlockFileScanner(replayDir, activeNetParams.Net)
+	defer scanner.Close()
+	atomic.StoreUint32(&replayProgress.files, scanner.NumFiles())
+	atomic.StoreInt32(&replayProgress.active, 1)
+	defer atomic.StoreInt32(&replayProgress.active, 0)
+	var numBlocks, numStale int
+	for !interruptRequested(interrupt) {
+		// The final block record might be incomplete when the database
+		// was not shut down cleanly, so stop at the first record which
+		// can't be read.
+		sb, err := scanner.Next()
+		if err != nil {
+			if err != io.EOF {
+				btcdLog.Warnf("Stopping at unreadable block "+
+					"record: %v", err)
+			}
+			break
+		}
+		atomic.StoreUint32(&replayProgress.file, sb.FileNum)
+		serializedBlock, err := scanner.ReadBlock()
+		if err != nil {
+			btcdLog.Warnf("Stopping at unreadable block record: %v",
+				err)
+			break
+		}
+		block, err := btcutil.NewBlockFromBytes(serializedBlock)
+		if err != nil {
+			btcdLog.Warnf("Skipping malformed block: %v", err)
+			continue
+		}
+
+		if staleFilter != nil && staleFilter.IsStale(&block.MsgBlock().Header) {
+			btcdLog.Debugf("Skipping block %v on a stale fork",
+				block.Hash())
+			numStale++
+			continue
+		}
+
+		// Blocks which failed to connect before are stored as well, so
+		// rule violations are expected.
+		_, _, err = chain.ProcessBlock(block, blockchain.BFCheckpointFastAdd)
+		if err != nil {
+			if _, ok := err.(blockchain.RuleError); !ok {
+				return err
+			}
+			btcdLog.Debugf("Reprocessed block %v rejected: %v",
+				block.Hash(), err)
+			atomic.AddUint64(&replayProgress.rejected, 1)
+			continue
+		}
+		numBlocks++
+		atomic.AddUint64(&replayProgress.processed, 1)
+	}
+	if interruptRequested(interrupt) {
+		return nil
+	}
+
+	// The blocks have been stored in the database again, so they are no
+	// longer needed.
+	scanner.Close()
+	if err := os.RemoveAll(replayDir); err != nil {
+		return err
+	}
+	best := chain.BestSnapshot()
+	btcdLog.Infof("Reprocessed %d blocks -- best block is %v (height %d)",
+		numBlocks, best.Hash, best.Height)
+	if numStale > 0 {
+		btcdLog.Infof("Skipped %d stored blocks on a stale fork",
+			numStale)
+	}
+	return nil
+}
+
+// readReplayHeaders returns the headers of the blocks stored in the passed
+// replay directory.  Like replayBlocks, it stops at the first record which
+// can't be read.
+func readReplayHeaders(replayDir string, interrupt <-chan struct{}) []wire.BlockHeader {
+	scanner := ffldb.NewBlockFileScanner(replayDir, activeNetParams.Net)
+	defer scanner.Close()
+	var headers []wire.BlockHeader
+	for !interruptRequested(interrupt) {
+		sb, err := scanner.Next()
+		if err != nil {
+			break
+		}
+		headers = append(headers, sb.Header)
+	}
+	return headers
+}
+
+// newRecoveryStaleFilter fetches the best header chain from the passed recovery
+// peers and returns a filter for the stored blocks which are on a stale fork of
+// it.  A warning is logged when the local best block itself is on a stale
+// fork, in which case it and the blocks before it down to the fork point are
+// considered stale as well.  A nil filter is returned when the peers know
+// about no blocks after the local best block.
+func newRecoveryStaleFilter(chain *blockchain.BlockChain, addrs []string,
+	interrupt <-chan struct{}) (*staleBlockFilter, error) {
+
+	headers, err := fetchRecoveryHeaders(chain, addrs, interrupt)
+	if err != nil || headers == nil {
+		return nil, err
+	}
+
+	filter := newStaleBlockFilter(headers)
+	best := chain.BestSnapshot()
+	if headers.forkHeight < best.Height {
+		btcdLog.Warnf("The stored best block %v (height %d) is on a "+
+			"stale fork -- the best header chain reported by the "+
+			"recovery peers diverges after block %v (height %d)",
+			best.Hash, best.Height, headers.forkHash,
+			headers.forkHeight)
+		for height := headers.forkHeight + 1; height <= best.Height; height++ {
+			hash, err := chain.BlockHashByHeight(height)
+			if err != nil {
+				return nil, err
+			}
+			filter.MarkStale(hash)
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,258 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/database/ffldb"
+)
+
+// defaultMetricsPort is the port the metrics server listens on when the
+// metricslisten option does not specify one.
+const defaultMetricsPort = "9332"
+
+// mempoolFeeRateBuckets are the upper bounds, in satoshi per byte, of the
+// buckets the fee rates of the transactions in the memory pool are counted in.
+var mempoolFeeRateBuckets = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000}
+
+// metricsLabelEscaper escapes label values as required by the Prometheus text
+// exposition format.
+var metricsLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsWriter builds a response in the Prometheus text exposition format.
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+// family writes the help and type lines which introduce the samples of the
+// metric with the passed name.
+func (w *metricsWriter) family(name, metricType, help string) {
+	fmt.Fprintf(&w.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name,
+		metricType)
+}
+
+// sample writes a sample of the metric with the passed name and value.  The
+// labels are passed as alternating names and values.
+func (w *metricsWriter) sample(name string, value float64, labels ...string) {
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			fmt.Fprintf(&w.buf, "%s=\"%s\"", labels[i],
+				metricsLabelEscaper.Replace(labels[i+1]))
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.buf.WriteByte('\n')
+}
+
+// single writes a metric which consists of a single unlabeled sample.
+func (w *metricsWriter) single(name, metricType, help string, value float64) {
+	w.family(name, metricType, help)
+	w.sample(name, value)
+}
+
+// writeRecoveryMetrics writes the progress of reprocessing the blocks left by a
+// recovery of the block database.  They are available before the server is
+// started since the blocks are reprocessed first.
+func writeRecoveryMetrics(w *metricsWriter) {
+	active := atomic.LoadInt32(&replayProgress.active)
+	w.single("btcd_recovery_active", "gauge",
+		"Whether blocks left by a block database recovery are being "+
+			"reprocessed.", float64(active))
+	w.single("btcd_recovery_blocks_reprocessed_total", "counter",
+		"Number of blocks reprocessed after a block database recovery.",
+		float64(atomic.LoadUint64(&replayProgress.processed)))
+	w.single("btcd_recovery_blocks_rejected_total", "counter",
+		"Number of reprocessed blocks which were rejected.",
+		float64(atomic.LoadUint64(&replayProgress.rejected)))
+	w.single("btcd_recovery_files", "gauge",
+		"Number of flat files with blocks to reprocess.",
+		float64(atomic.LoadUint32(&replayProgress.files)))
+	w.single("btcd_recovery_current_file", "gauge",
+		"Number of the flat file blocks are currently reprocessed from.",
+		float64(atomic.LoadUint32(&replayProgress.file)))
+}
+
+// writeServerMetrics writes the metrics describing the internals of the passed
+// running server.
+func writeServerMetrics(w *metricsWriter, s *server) {
+	// Peers and network traffic.
+	inbound, outbound := s.PeerCounts()
+	w.family("btcd_peers", "gauge", "Number of connected peers.")
+	w.sample("btcd_peers", float64(inbound), "direction", "inbound")
+	w.sample("btcd_peers", float64(outbound), "direction", "outbound")
+	bytesRecv, bytesSent := s.NetTotals()
+	w.single("btcd_network_received_bytes_total", "counter",
+		"Number of bytes received from peers.", float64(bytesRecv))
+	w.single("btcd_network_sent_bytes_total", "counter",
+		"Number of bytes sent to peers.", float64(bytesSent))
+
+	// Best chain.
+	best := s.chain.BestSnapshot()
+	w.single("btcd_chain_height", "gauge",
+		"Height of the best block in the main chain.", float64(best.Height))
+
+	// Memory pool size and the distribution of its fee rates.
+	descs := s.txMemPool.TxDescs()
+	bucketCounts := make([]int, len(mempoolFeeRateBuckets))
+	var poolBytes int
+	var feeRateSum float64
+	for _, desc := range descs {
+		poolBytes += desc.Tx.MsgTx().SerializeSize()
+		feeRate := float64(desc.FeePerKB) / 1000
+		feeRateSum += feeRate
+		for i, bound := range mempoolFeeRateBuckets {
+			if feeRate <= bound {
+				bucketCounts[i]++
+			}
+		}
+	}
+	w.single("btcd_mempool_transactions", "gauge",
+		"Number of transactions in the memory pool.", float64(len(descs)))
+	w.single("btcd_mempool_bytes", "gauge",
+		"Serialized size of the transactions in the memory pool.",
+		float64(poolBytes))
+	w.family("btcd_mempool_fee_rate", "histogram",
+		"Fee rates in satoshi per byte of the transactions in the "+
+			"memory pool.")
+	for i, bound := range mempoolFeeRateBuckets {
+		w.sample("btcd_mempool_fee_rate_bucket", float64(bucketCounts[i]),
+			"le", strconv.FormatFloat(bound, 'g', -1, 64))
+	}
+	w.sample("btcd_mempool_fee_rate_bucket", float64(len(descs)), "le",
+		"+Inf")
+	w.sample("btcd_mempool_fee_rate_sum", feeRateSum)
+	w.sample("btcd_mempool_fee_rate_count", float64(len(descs)))
+
+	// Block validation timing over the most recently processed blocks.
+	w.family("btcd_block_validation_seconds", "summary",
+		"Time spent in each phase of block validation over the most "+
+			"recently processed blocks.")
+	for _, ps := range s.chain.ValidationStats() {
+		phase := ps.Phase.String()
+		if ps.Samples > 0 {
+			quantiles := []struct {
+				quantile string
+				value    time.Duration
+			}{
+				{"0.5", ps.P50},
+				{"0.9", ps.P90},
+				{"0.99", ps.P99},
+			}
+			for _, q := range quantiles {
+				w.sample("btcd_block_validation_seconds",
+					q.value.Seconds(), "phase", phase,
+					"quantile", q.quantile)
+			}
+		}
+		sum := ps.Mean * time.Duration(ps.Samples)
+		w.sample("btcd_block_validation_seconds_sum", sum.Seconds(),
+			"phase", phase)
+		w.sample("btcd_block_validation_seconds_count",
+			float64(ps.Samples), "phase", phase)
+	}
+
+	// Database cache flushes and flat file I/O.  Only ffldb databases
+	// provide these statistics.
+	stats, err := ffldb.IOStats(s.db)
+	if err != nil {
+		return
+	}
+	w.single("btcd_dbcache_flushes_total", "counter",
+		"Number of database cache flushes.", float64(stats.CacheFlushes))
+	w.single("btcd_dbcache_flush_seconds_total", "counter",
+		"Total time spent flushing the database cache.",
+		stats.CacheFlushTime.Seconds())
+	w.single("btcd_dbcache_last_flush_seconds", "gauge",
+		"Time the most recent database cache flush took.",
+		stats.LastCacheFlushTime.Seconds())
+	w.single("btcd_ffldb_block_writes_total", "counter",
+		"Number of blocks written to the flat files.",
+		float64(stats.BlocksWritten))
+	w.single("btcd_ffldb_written_bytes_total", "counter",
+		"Number of bytes written to the flat files.",
+		float64(stats.BlockBytesWritten))
+	w.single("btcd_ffldb_truncations_total", "counter",
+		"Number of flat file truncations due to rolled back writes.",
+		float64(stats.BlockFileTruncations))
+}
+
+// metricsServer serves metrics describing the internals of the node over HTTP
+// in the Prometheus text exposition format so operators can build dashboards
+// without scraping the logs.
+type metricsServer struct {
+	listener   net.Listener
+	httpServer *http.Server
+
+	mtx    sync.Mutex
+	server *server
+}
+
+// newMetricsServer returns a new metrics server listening on the passed
+// address.  It only reports the recovery progress until SetServer is called.
+func newMetricsServer(listenAddr string) (*metricsServer, error) {
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	m := &metricsServer{listener: listener}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", m.handleMetrics)
+	m.httpServer = &http.Server{Handler: mux}
+	return m, nil
+}
+
+// Start begins serving metrics.
+func (m *metricsServer) Start() {
+	go func() {
+		btcdLog.Infof("Metrics server listening on %s", m.listener.Addr())
+		err := m.httpServer.Serve(m.listener)
+		if err != nil && err != http.ErrServerClosed {
+			btcdLog.Errorf("Metrics server: %v", err)
+		}
+	}()
+}
+
+// Stop stops serving metrics.
+func (m *metricsServer) Stop() {
+	m.httpServer.Close()
+}
+
+// SetServer sets the running server whose internals are reported.
+func (m *metricsServer) SetServer(s *server) {
+	m.mtx.Lock()
+	m.server = s
+	m.mtx.Unlock()
+}
+
+// handleMetrics responds to a metrics scrape.
+func (m *metricsServer) handleMetrics(rw http.ResponseWriter, r *http.Request) {
+	m.mtx.Lock()
+	s := m.server
+	m.mtx.Unlock()
+
+	var w metricsWriter
+	writeRecoveryMetrics(&w)
+	if s != nil {
+		writeServerMetrics(&w, s)
+	}
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	rw.Write(w.buf.Bytes())
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestMetricsWriter ensures metrics are written in the Prometheus text
+// exposition format.
+func TestMetricsWriter(t *testing.T) {
+	t.Parallel()
+
+	var w metricsWriter
+	w.single("test_total", "counter", "A test counter.", 42)
+	w.family("test_labeled", "gauge", "A labeled gauge.")
+	w.sample("test_labeled", 0.25, "name", `a"b\c`, "other", "x")
+	want := "# HELP test_total A test counter.\n" +
+		"# TYPE test_total counter\n" +
+		"test_total 42\n" +
+		"# HELP test_labeled A labeled gauge.\n" +
+		"# TYPE test_labeled gauge\n" +
+		`test_labeled{name="a\"b\\c",other="x"} 0.25` + "\n"
+	if got := w.buf.String(); got != want {
+		t.Fatalf("unexpected metrics:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestMetricsHandler ensures the recovery progress is reported before a server
+// is set.
+func TestMetricsHandler(t *testing.T) {
+	t.Parallel()
+
+	var m metricsServer
+	rec := httptest.NewRecorder()
+	m.handleMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "\nbtcd_recovery_active ") {
+		t.Fatalf("recovery progress not reported:\n%s", body)
+	}
+	if strings.Contains(body, "btcd_peers") {
+		t.Fatalf("server metrics reported without a server:\n%s", body)
+	}
+}
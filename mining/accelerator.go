@@ -0,0 +1,114 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// AcceleratedTx describes a transaction registered with a TxAccelerator.
+type AcceleratedTx struct {
+	// Hash is the hash of the transaction.
+	Hash chainhash.Hash
+
+	// Boost is the priority boost of the transaction.  Transactions with a
+	// higher boost are selected first.
+	Boost uint32
+
+	// Expires is the time the registration expires.
+	Expires time.Time
+}
+
+// TxAccelerator tracks the transactions an operator registered to be selected
+// for block templates ahead of the usual ordering by priority and fee per
+// kilobyte, for example because their fee was paid out of band.  Each
+// registration expires after some time, so forgotten registrations do not
+// affect the templates indefinitely.
+//
+// It is safe for concurrent access.
+type TxAccelerator struct {
+	mtx sync.Mutex
+	txs map[chainhash.Hash]AcceleratedTx
+}
+
+// NewTxAccelerator returns a new, empty transaction accelerator.
+func NewTxAccelerator() *TxAccelerator {
+	return &TxAccelerator{
+		txs: make(map[chainhash.Hash]AcceleratedTx),
+	}
+}
+
+// Add registers the transaction with the passed hash with the passed boost
+// until the passed expiration time.  It replaces any previous registration of
+// the transaction.
+func (a *TxAccelerator) Add(hash *chainhash.Hash, boost uint32, expires time.Time) {
+	a.mtx.Lock()
+	a.txs[*hash] = AcceleratedTx{
+		Hash:    *hash,
+		Boost:   boost,
+		Expires: expires,
+	}
+	a.mtx.Unlock()
+}
+
+// Remove removes the registration of the transaction with the passed hash and
+// returns whether it was registered.
+func (a *TxAccelerator) Remove(hash *chainhash.Hash) bool {
+	a.mtx.Lock()
+	_, ok := a.txs[*hash]
+	delete(a.txs, *hash)
+	a.mtx.Unlock()
+	return ok
+}
+
+// pruneExpired removes the registrations which expired as of the passed time.
+//
+// This function MUST be called with the mutex held.
+func (a *TxAccelerator) pruneExpired(now time.Time) {
+	for hash, tx := range a.txs {
+		if !now.Before(tx.Expires) {
+			delete(a.txs, hash)
+		}
+	}
+}
+
+// Boosts returns the boosts of the transactions which are registered as of the
+// passed time keyed by their hashes.
+func (a *TxAccelerator) Boosts(now time.Time) map[chainhash.Hash]uint32 {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.pruneExpired(now)
+	boosts := make(map[chainhash.Hash]uint32, len(a.txs))
+	for hash, tx := range a.txs {
+		boosts[hash] = tx.Boost
+	}
+	return boosts
+}
+
+// Registered returns the transactions which are registered as of the passed
+// time sorted by their boost, highest first, and then by their hash.
+func (a *TxAccelerator) Registered(now time.Time) []AcceleratedTx {
+	a.mtx.Lock()
+	a.pruneExpired(now)
+	txs := make([]AcceleratedTx, 0, len(a.txs))
+	for _, tx := range a.txs {
+		txs = append(txs, tx)
+	}
+	a.mtx.Unlock()
+
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].Boost != txs[j].Boost {
+			return txs[i].Boost > txs[j].Boost
+		}
+		return bytes.Compare(txs[i].Hash[:], txs[j].Hash[:]) < 0
+	})
+	return txs
+}
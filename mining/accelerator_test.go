@@ -0,0 +1,93 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"container/heap"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestTxAccelerator ensures the transaction accelerator tracks the registered
+// transactions until they expire or are removed.
+func TestTxAccelerator(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1500000000, 0)
+	a := NewTxAccelerator()
+	hash1, hash2, hash3 := chainhash.Hash{1}, chainhash.Hash{2}, chainhash.Hash{3}
+	a.Add(&hash1, 1, now.Add(time.Hour))
+	a.Add(&hash2, 5, now.Add(time.Minute))
+	a.Add(&hash3, 1, now.Add(time.Hour))
+
+	// The registrations are sorted by boost and then by hash.
+	txs := a.Registered(now)
+	want := []chainhash.Hash{hash2, hash1, hash3}
+	if len(txs) != len(want) {
+		t.Fatalf("Registered: got %d transactions, want %d", len(txs),
+			len(want))
+	}
+	for i := range want {
+		if txs[i].Hash != want[i] {
+			t.Fatalf("Registered: transaction %d is %v, want %v", i,
+				txs[i].Hash, want[i])
+		}
+	}
+
+	// Registering a transaction again replaces its boost.
+	a.Add(&hash1, 3, now.Add(time.Hour))
+	if boost := a.Boosts(now)[hash1]; boost != 3 {
+		t.Fatalf("Boosts: got boost %d, want 3", boost)
+	}
+
+	// Removed and expired registrations are no longer returned.
+	if !a.Remove(&hash3) {
+		t.Fatal("Remove: registered transaction not removed")
+	}
+	if a.Remove(&hash3) {
+		t.Fatal("Remove: removed transaction which is not registered")
+	}
+	boosts := a.Boosts(now.Add(time.Minute))
+	if len(boosts) != 1 || boosts[hash1] != 3 {
+		t.Fatalf("Boosts: got %v, want only %v", boosts, hash1)
+	}
+}
+
+// TestBoostedSelection ensures boosted transactions are selected ahead of the
+// others regardless of their fees and priority.
+func TestBoostedSelection(t *testing.T) {
+	t.Parallel()
+
+	newItem := func(lockTime uint32, feePerKB int64, priority float64,
+		boost uint32) *txPrioItem {
+
+		tx := btcutil.NewTx(&wire.MsgTx{LockTime: lockTime})
+		return &txPrioItem{tx: tx, feePerKB: feePerKB,
+			priority: priority, boost: boost, index: -1}
+	}
+	lowBoost := newItem(1, 1, 1, 1)
+	highBoost := newItem(2, 0, 0, 2)
+	highFee := newItem(3, 5000, 1e9, 0)
+	want := []*txPrioItem{highBoost, lowBoost, highFee}
+
+	for _, sortByFee := range []bool{true, false} {
+		pq := newTxPriorityQueue(len(want), sortByFee)
+		for _, item := range []*txPrioItem{highFee, lowBoost, highBoost} {
+			heap.Push(pq, item)
+		}
+		for i := range want {
+			item := heap.Pop(pq).(*txPrioItem)
+			if item != want[i] {
+				t.Fatalf("sortByFee %v: transaction %d is %v, "+
+					"want %v", sortByFee, i, item.tx.Hash(),
+					want[i].tx.Hash())
+			}
+		}
+	}
+}
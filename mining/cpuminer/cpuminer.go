@@ -45,6 +45,10 @@ var (
 	// and is based on the number of processor cores.  This helps ensure the
 	// system stays reasonably responsive under heavy load.
 	defaultNumWorkers = uint32(runtime.NumCPU())
+
+	// errStaleBlock is returned by submitBlock when the best block changed
+	// while the submitted block was being solved.
+	errStaleBlock = errors.New("block is stale")
 )
 
 // Config is a descriptor containing the cpu miner configuration.
@@ -151,8 +155,10 @@ out:
 }
 
 // submitBlock submits the passed block to network after ensuring it passes all
-// of the consensus validation rules.
-func (m *CPUMiner) submitBlock(block *btcutil.Block) bool {
+// of the consensus validation rules.  It returns errStaleBlock when the block
+// no longer extends the best block and the reason the block was not accepted
+// otherwise.
+func (m *CPUMiner) submitBlock(block *btcutil.Block) error {
 	m.submitBlockLock.Lock()
 	defer m.submitBlockLock.Unlock()
 
@@ -165,7 +171,7 @@ func (m *CPUMiner) submitBlock(block *btcutil.Block) bool {
 	if !msgBlock.Header.PrevBlock.IsEqual(&m.g.BestSnapshot().Hash) {
 		log.Debugf("Block submitted via CPU miner with previous "+
 			"block %s is stale", msgBlock.Header.PrevBlock)
-		return false
+		return errStaleBlock
 	}
 
 	// Process this block using the same rules as blocks coming from other
@@ -177,22 +183,22 @@ func (m *CPUMiner) submitBlock(block *btcutil.Block) bool {
 		if _, ok := err.(blockchain.RuleError); !ok {
 			log.Errorf("Unexpected error while processing "+
 				"block submitted via CPU miner: %v", err)
-			return false
+			return err
 		}
 
 		log.Debugf("Block submitted via CPU miner rejected: %v", err)
-		return false
+		return err
 	}
 	if isOrphan {
 		log.Debugf("Block submitted via CPU miner is an orphan")
-		return false
+		return fmt.Errorf("block %v is an orphan", block.Hash())
 	}
 
 	// The block was accepted.
 	coinbaseTx := block.MsgBlock().Transactions[0].TxOut[0]
 	log.Infof("Block submitted via CPU miner accepted (hash %s, "+
 		"amount %v)", block.Hash(), btcutil.Amount(coinbaseTx.Value))
-	return true
+	return nil
 }
 
 // solveBlock attempts to find some combination of a nonce, extra nonce, and
@@ -545,6 +551,49 @@ func (m *CPUMiner) NumWorkers() int32 {
 // generating a new block template.  When a block is solved, it is submitted.
 // The function returns a list of the hashes of generated blocks.
 func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
+	return m.generateNBlocks(n, func() (*mining.BlockTemplate, error) {
+		// Choose a payment address at random.
+		rand.Seed(time.Now().UnixNano())
+		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+
+		// Create a new block template using the available transactions
+		// in the memory pool as a source of transactions to potentially
+		// include in the block.
+		return m.g.NewBlockTemplate(payToAddr)
+	}, true)
+}
+
+// GenerateNBlocksToAddress generates the requested number of blocks paying to
+// the passed address in the same way as GenerateNBlocks.
+func (m *CPUMiner) GenerateNBlocksToAddress(n uint32, payToAddr btcutil.Address) ([]*chainhash.Hash, error) {
+	return m.generateNBlocks(n, func() (*mining.BlockTemplate, error) {
+		return m.g.NewBlockTemplate(payToAddr)
+	}, true)
+}
+
+// GenerateBlock generates a single block paying to the passed address which
+// includes exactly the passed transactions in the given order rather than the
+// transactions from the memory pool.  An error is returned when the
+// transactions can't be included in a block extending the current best block.
+func (m *CPUMiner) GenerateBlock(payToAddr btcutil.Address, txs []*btcutil.Tx) (*chainhash.Hash, error) {
+	blockHashes, err := m.generateNBlocks(1, func() (*mining.BlockTemplate, error) {
+		return m.g.NewBlockTemplateFromTxs(payToAddr, txs)
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+	return blockHashes[0], nil
+}
+
+// generateNBlocks generates the requested number of blocks from the templates
+// created by the passed function.  A new template is created whenever the
+// current one becomes stale.  When retryFailed is set, errors creating a
+// template are logged and another attempt is made, otherwise they are
+// returned.  An error is returned when a solved block is not accepted.
+func (m *CPUMiner) generateNBlocks(n uint32,
+	newTemplate func() (*mining.BlockTemplate, error),
+	retryFailed bool) ([]*chainhash.Hash, error) {
+
 	m.Lock()
 
 	// Respond with an error if server is already mining.
@@ -563,6 +612,16 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 
 	m.Unlock()
 
+	// Stop the speed monitor and leave discrete mining mode once done.
+	defer func() {
+		m.Lock()
+		close(m.speedMonitorQuit)
+		m.wg.Wait()
+		m.started = false
+		m.discreteMining = false
+		m.Unlock()
+	}()
+
 	log.Tracef("Generating %d blocks", n)
 
 	i := uint32(0)
@@ -587,17 +646,12 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		// template on a block that is in the process of becoming stale.
 		m.submitBlockLock.Lock()
 		curHeight := m.g.BestSnapshot().Height
-
-		// Choose a payment address at random.
-		rand.Seed(time.Now().UnixNano())
-		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
-
-		// Create a new block template using the available transactions
-		// in the memory pool as a source of transactions to potentially
-		// include in the block.
-		template, err := m.g.NewBlockTemplate(payToAddr)
+		template, err := newTemplate()
 		m.submitBlockLock.Unlock()
 		if err != nil {
+			if !retryFailed {
+				return nil, err
+			}
 			errStr := fmt.Sprintf("Failed to create new block "+
 				"template: %v", err)
 			log.Errorf(errStr)
@@ -609,18 +663,25 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		// a new block template can be generated.  When the return is
 		// true a solution was found, so submit the solved block.
 		if m.solveBlock(template.Block, curHeight+1, ticker, nil) {
+			// Blocks which became stale are generated again from a
+			// new template when template errors are retried, since
+			// the caller only asked for a number of blocks.  Any
+			// other failure means the block is not part of the
+			// chain, so its hash must not be returned.
 			block := btcutil.NewBlock(template.Block)
-			m.submitBlock(block)
+			err := m.submitBlock(block)
+			if err == errStaleBlock && retryFailed {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to submit "+
+					"generated block %v: %v", block.Hash(),
+					err)
+			}
 			blockHashes[i] = block.Hash()
 			i++
 			if i == n {
 				log.Tracef("Generated %d blocks", i)
-				m.Lock()
-				close(m.speedMonitorQuit)
-				m.wg.Wait()
-				m.started = false
-				m.discreteMining = false
-				m.Unlock()
 				return blockHashes, nil
 			}
 		}
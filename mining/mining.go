@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"container/heap"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
@@ -31,6 +32,12 @@ const (
 	// and is used to monitor BIP16 support as well as blocks that are
 	// generated via btcd.
 	CoinbaseFlags = "/P2SH/btcd/"
+
+	// maxPackageAncestors is the maximum number of ancestors in the source
+	// pool a transaction may have to be included in a block template.  It
+	// matches the default ancestor limit of the memory pool and bounds the
+	// work of resolving the packages of the transactions.
+	maxPackageAncestors = 24
 )
 
 // TxDesc is a descriptor about a transaction in a transaction source along with
@@ -49,8 +56,25 @@ type TxDesc struct {
 	// Fee is the total fee the transaction associated with the entry pays.
 	Fee int64
 
-	// FeePerKB is the fee the transaction pays in Satoshi per 1000 bytes.
+	// FeePerKB is the fee the transaction pays in Satoshi per 1000 bytes of
+	// virtual size.
 	FeePerKB int64
+
+	// AncestorCount, AncestorSize, and AncestorFees are the number, total
+	// virtual size, and total fees of the transaction along with all of
+	// the transactions in the source pool it depends on, directly or
+	// indirectly.
+	AncestorCount int
+	AncestorSize  int64
+	AncestorFees  int64
+
+	// DescendantCount, DescendantSize, and DescendantFees are the number,
+	// total virtual size, and total fees of the transaction along with
+	// all of the transactions in the source pool which depend on it,
+	// directly or indirectly.
+	DescendantCount int
+	DescendantSize  int64
+	DescendantFees  int64
 }
 
 // TxSource represents a source of transactions to consider for inclusion in
@@ -63,6 +87,10 @@ type TxSource interface {
 	// removed from the source pool.
 	LastUpdated() time.Time
 
+	// Generation returns a counter which is incremented every time a
+	// transaction is added to or removed from the source pool.
+	Generation() uint64
+
 	// MiningDescs returns a slice of mining descriptors for all the
 	// transactions in the source pool.
 	MiningDescs() []*TxDesc
@@ -78,14 +106,95 @@ type TxSource interface {
 type txPrioItem struct {
 	tx       *btcutil.Tx
 	fee      int64
+	size     int64
 	priority float64
-	feePerKB int64
+
+	// boost is the priority boost the transaction was registered with in
+	// the transaction accelerator, if any.  Transactions with a higher
+	// boost are selected first regardless of their priority and fees.
+	boost uint32
+
+	// feePerKB is the fee per kilobyte of virtual size of the package of
+	// the transaction, which consists of the transaction along with its
+	// ancestors which are not included in the block yet.  The package fee
+	// and size are updated as ancestors are included.
+	feePerKB    int64
+	packageFee  int64
+	packageSize int64
 
 	// dependsOn holds a map of transaction hashes which this one depends
 	// on.  It will only be set when the transaction references other
 	// transactions in the source pool and hence must come after them in
 	// a block.
 	dependsOn map[chainhash.Hash]struct{}
+
+	// ancestors and descendants hold the transactions in the source pool
+	// which this one depends on and which depend on this one, directly or
+	// indirectly.  The ancestors are nil until they have been resolved.
+	ancestors   map[chainhash.Hash]*txPrioItem
+	descendants map[chainhash.Hash]*txPrioItem
+
+	// unavailable is set when one of the ancestors of the transaction can't
+	// be included in the block.
+	unavailable bool
+
+	// included is set once the transaction is included in the block.
+	included bool
+
+	// index is the index of the item in the priority queue, or -1 when it
+	// is not in the queue.
+	index int
+}
+
+// resolveAncestors populates the ancestors of the passed item from the passed
+// items and returns whether all of them are available for inclusion in the
+// block.  Items with more than maxPackageAncestors ancestors are unavailable.
+func resolveAncestors(item *txPrioItem, items map[chainhash.Hash]*txPrioItem) bool {
+	if item.ancestors != nil {
+		return true
+	}
+	if item.unavailable {
+		return false
+	}
+
+	ancestors := make(map[chainhash.Hash]*txPrioItem)
+	for hash := range item.dependsOn {
+		parent, ok := items[hash]
+		if !ok || !resolveAncestors(parent, items) {
+			item.unavailable = true
+			return false
+		}
+		ancestors[hash] = parent
+		for ancestorHash, ancestor := range parent.ancestors {
+			ancestors[ancestorHash] = ancestor
+		}
+		if len(ancestors) > maxPackageAncestors {
+			item.unavailable = true
+			return false
+		}
+	}
+	item.ancestors = ancestors
+	return true
+}
+
+// pkg returns the ancestors of the item which are not included in the block
+// yet, ordered so each transaction comes after the transactions it depends on,
+// followed by the item itself.
+func (item *txPrioItem) pkg() []*txPrioItem {
+	pkg := make([]*txPrioItem, 0, len(item.ancestors)+1)
+	for _, ancestor := range item.ancestors {
+		if !ancestor.included {
+			pkg = append(pkg, ancestor)
+		}
+	}
+
+	// Every ancestor of a transaction is also an ancestor of the
+	// transactions which depend on it, so a transaction always has more
+	// ancestors than the transactions it depends on.
+	sort.Slice(pkg, func(i, j int) bool {
+		return len(pkg[i].ancestors) < len(pkg[j].ancestors)
+	})
+	return append(pkg, item)
 }
 
 // txPriorityQueueLessFunc describes a function that can be used as a compare
@@ -116,12 +225,16 @@ func (pq *txPriorityQueue) Less(i, j int) bool {
 // part of the heap.Interface implementation.
 func (pq *txPriorityQueue) Swap(i, j int) {
 	pq.items[i], pq.items[j] = pq.items[j], pq.items[i]
+	pq.items[i].index = i
+	pq.items[j].index = j
 }
 
 // Push pushes the passed item onto the priority queue.  It is part of the
 // heap.Interface implementation.
 func (pq *txPriorityQueue) Push(x interface{}) {
-	pq.items = append(pq.items, x.(*txPrioItem))
+	item := x.(*txPrioItem)
+	item.index = len(pq.items)
+	pq.items = append(pq.items, item)
 }
 
 // Pop removes the highest priority item (according to Less) from the priority
@@ -129,6 +242,7 @@ func (pq *txPriorityQueue) Push(x interface{}) {
 func (pq *txPriorityQueue) Pop() interface{} {
 	n := len(pq.items)
 	item := pq.items[n-1]
+	item.index = -1
 	pq.items[n-1] = nil
 	pq.items = pq.items[0 : n-1]
 	return item
@@ -142,11 +256,14 @@ func (pq *txPriorityQueue) SetLessFunc(lessFunc txPriorityQueueLessFunc) {
 	heap.Init(pq)
 }
 
-// txPQByPriority sorts a txPriorityQueue by transaction priority and then fees
-// per kilobyte.
+// txPQByPriority sorts a txPriorityQueue by boost, transaction priority and
+// then fees per kilobyte.
 func txPQByPriority(pq *txPriorityQueue, i, j int) bool {
 	// Using > here so that pop gives the highest priority item as opposed
-	// to the lowest.  Sort by priority first, then fee.
+	// to the lowest.  Sort by boost first, then priority, then fee.
+	if pq.items[i].boost != pq.items[j].boost {
+		return pq.items[i].boost > pq.items[j].boost
+	}
 	if pq.items[i].priority == pq.items[j].priority {
 		return pq.items[i].feePerKB > pq.items[j].feePerKB
 	}
@@ -154,11 +271,14 @@ func txPQByPriority(pq *txPriorityQueue, i, j int) bool {
 
 }
 
-// txPQByFee sorts a txPriorityQueue by fees per kilobyte and then transaction
-// priority.
+// txPQByFee sorts a txPriorityQueue by boost, package fees per kilobyte and
+// then transaction priority.
 func txPQByFee(pq *txPriorityQueue, i, j int) bool {
 	// Using > here so that pop gives the highest fee item as opposed
-	// to the lowest.  Sort by fee first, then priority.
+	// to the lowest.  Sort by boost first, then fee, then priority.
+	if pq.items[i].boost != pq.items[j].boost {
+		return pq.items[i].boost > pq.items[j].boost
+	}
 	if pq.items[i].feePerKB == pq.items[j].feePerKB {
 		return pq.items[i].priority > pq.items[j].priority
 	}
@@ -246,11 +366,13 @@ func standardCoinbaseScript(nextBlockHeight int32, extraNonce uint64) ([]byte, e
 
 // createCoinbaseTx returns a coinbase transaction paying an appropriate subsidy
 // based on the passed block height to the provided address.  When the address
-// is nil, the coinbase transaction will instead be redeemable by anyone.
+// is nil, the coinbase transaction will instead be redeemable by anyone.  A
+// non-zero lock time is set on the transaction and enabled through the sequence
+// number of its input.
 //
 // See the comment for NewBlockTemplate for more information about why the nil
 // address handling is useful.
-func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockHeight int32, addr btcutil.Address) (*btcutil.Tx, error) {
+func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockHeight int32, addr btcutil.Address, lockTime uint32) (*btcutil.Tx, error) {
 	// Create the script to pay to the provided payment address if one was
 	// specified.  Otherwise create a script that allows the coinbase to be
 	// redeemable by anyone.
@@ -270,6 +392,10 @@ func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockH
 		}
 	}
 
+	sequence := uint32(wire.MaxTxInSequenceNum)
+	if lockTime != 0 {
+		sequence--
+	}
 	tx := wire.NewMsgTx(wire.TxVersion)
 	tx.AddTxIn(&wire.TxIn{
 		// Coinbase transactions have no inputs, so previous outpoint is
@@ -277,8 +403,9 @@ func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockH
 		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
 			wire.MaxPrevOutIndex),
 		SignatureScript: coinbaseScript,
-		Sequence:        wire.MaxTxInSequenceNum,
+		Sequence:        sequence,
 	})
+	tx.LockTime = lockTime
 	tx.AddTxOut(&wire.TxOut{
 		Value:    blockchain.CalcBlockSubsidy(nextBlockHeight, params),
 		PkScript: pkScript,
@@ -316,6 +443,36 @@ func logSkippedDeps(tx *btcutil.Tx, deps map[chainhash.Hash]*txPrioItem) {
 	}
 }
 
+// includePrioItem marks the passed item as included in the block.  The item is
+// removed from the priority queue, and it is removed from the packages of the
+// transactions which depend on it since it no longer has to be included along
+// with them.  When the pushReady flag is set, the passed dependers of the item
+// which no longer depend on any transaction which is not in the block are added
+// to the priority queue.
+func includePrioItem(pq *txPriorityQueue, item *txPrioItem, deps map[chainhash.Hash]*txPrioItem, pushReady bool) {
+	item.included = true
+	if item.index != -1 {
+		heap.Remove(pq, item.index)
+	}
+
+	for _, descendant := range item.descendants {
+		descendant.packageFee -= item.fee
+		descendant.packageSize -= item.size
+		descendant.feePerKB = descendant.packageFee * 1000 /
+			descendant.packageSize
+		if descendant.index != -1 {
+			heap.Fix(pq, descendant.index)
+		}
+	}
+
+	for _, dep := range deps {
+		delete(dep.dependsOn, *item.tx.Hash())
+		if pushReady && len(dep.dependsOn) == 0 && dep.index == -1 {
+			heap.Push(pq, dep)
+		}
+	}
+}
+
 // MinimumMedianTime returns the minimum allowed timestamp for a block building
 // on the end of the provided best chain.  In particular, it is one second after
 // the median timestamp of the last several blocks per the chain consensus
@@ -355,6 +512,7 @@ type BlkTmplGenerator struct {
 	timeSource  blockchain.MedianTimeSource
 	sigCache    *txscript.SigCache
 	hashCache   *txscript.HashCache
+	accelerator *TxAccelerator
 }
 
 // NewBlkTmplGenerator returns a new block template generator for the given
@@ -362,12 +520,14 @@ type BlkTmplGenerator struct {
 //
 // The additional state-related fields are required in order to ensure the
 // templates are built on top of the current best chain and adhere to the
-// consensus rules.
+// consensus rules.  The transactions registered with the passed accelerator,
+// which may be nil, are selected ahead of the others.
 func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 	txSource TxSource, chain *blockchain.BlockChain,
 	timeSource blockchain.MedianTimeSource,
 	sigCache *txscript.SigCache,
-	hashCache *txscript.HashCache) *BlkTmplGenerator {
+	hashCache *txscript.HashCache,
+	accelerator *TxAccelerator) *BlkTmplGenerator {
 
 	return &BlkTmplGenerator{
 		policy:      policy,
@@ -377,6 +537,7 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 		timeSource:  timeSource,
 		sigCache:    sigCache,
 		hashCache:   hashCache,
+		accelerator: accelerator,
 	}
 }
 
@@ -393,28 +554,39 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 // factors.  First, each transaction has a priority calculated based on its
 // value, age of inputs, and size.  Transactions which consist of larger
 // amounts, older inputs, and small sizes have the highest priority.  Second, a
-// fee per kilobyte is calculated for each transaction.  Transactions with a
-// higher fee per kilobyte are preferred.  Finally, the block generation related
-// policy settings are all taken into account.
+// fee per kilobyte is calculated for the package of each transaction, which
+// consists of the transaction along with all of the transactions in the source
+// pool it depends on that are not in the block yet.  Packages with a higher fee
+// per kilobyte are preferred, which allows a transaction paying a high fee to
+// pay for the transactions it depends on (child pays for parent).  Finally, the
+// block generation related policy settings are all taken into account.
+//
+// The transactions registered with the transaction accelerator of the generator
+// are selected before all others, highest boost first, along with the
+// transactions they depend on.  They are neither subject to the high-priority
+// area nor skipped for paying less than the minimum relay fee of the Standard
+// policy setting, since their fees may have been paid out of band.
 //
-// Transactions which only spend outputs from other transactions already in the
-// block chain are immediately added to a priority queue which either
-// prioritizes based on the priority (then fee per kilobyte) or the fee per
-// kilobyte (then priority) depending on whether or not the BlockPrioritySize
-// policy setting allots space for high-priority transactions.  Transactions
+// When the BlockPrioritySize policy setting allots space for high-priority
+// transactions, the transactions which only spend outputs from other
+// transactions already in the block chain are added to a priority queue which
+// prioritizes based on the priority (then fee per kilobyte).  Transactions
 // which spend outputs from other transactions in the source pool are added to a
 // dependency map so they can be added to the priority queue once the
 // transactions they depend on have been included.
 //
 // Once the high-priority area (if configured) has been filled with
 // transactions, or the priority falls below what is considered high-priority,
-// the priority queue is updated to prioritize by fees per kilobyte (then
-// priority).
+// all of the remaining transactions are added to the priority queue which is
+// updated to prioritize by package fees per kilobyte (then priority).  Each
+// time a package is selected, the transactions it consists of are included in
+// the order they depend on each other, and the packages of the transactions
+// which depend on them are updated to no longer include them.
 //
-// When the fees per kilobyte drop below the TxMinFreeFee policy setting, the
-// transaction will be skipped unless the BlockMinSize policy setting is
-// nonzero, in which case the block will be filled with the low-fee/free
-// transactions until the block size reaches that minimum size.
+// When the package fees per kilobyte drop below the minimum relay fee of the
+// Standard policy setting, the package will be skipped unless the BlockMinSize
+// policy setting is nonzero, in which case the block will be filled with the
+// low-fee/free transactions until the block size reaches that minimum size.
 //
 // Any transactions which would cause the block to exceed the BlockMaxSize
 // policy setting, exceed the maximum allowed signature operations per block, or
@@ -433,7 +605,7 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 //  |                                   |   |
 //  |                                   |   |--- policy.BlockMaxSize
 //  |  Transactions prioritized by fee  |   |
-//  |  until <= policy.TxMinFreeFee     |   |
+//  |  until <= policy.minFreeFee()     |   |
 //  |                                   |   |
 //  |                                   |   |
 //  |                                   |   |
@@ -460,8 +632,12 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress btcutil.Address) (*Bloc
 	if err != nil {
 		return nil, err
 	}
+	var coinbaseLockTime uint32
+	if g.policy.CoinbaseLockTime {
+		coinbaseLockTime = uint32(best.Height)
+	}
 	coinbaseTx, err := createCoinbaseTx(g.chainParams, coinbaseScript,
-		nextBlockHeight, payToAddress)
+		nextBlockHeight, payToAddress, coinbaseLockTime)
 	if err != nil {
 		return nil, err
 	}
@@ -477,6 +653,13 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress btcutil.Address) (*Bloc
 	sortedByFee := g.policy.BlockPrioritySize == 0
 	priorityQueue := newTxPriorityQueue(len(sourceTxns), sortedByFee)
 
+	// Look up the boosts of the transactions registered with the
+	// accelerator.
+	var boosts map[chainhash.Hash]uint32
+	if g.accelerator != nil {
+		boosts = g.accelerator.Boosts(time.Now())
+	}
+
 	// Create a slice to hold the transactions to be included in the
 	// generated block with reserved space.  Also create a utxo view to
 	// house all of the input transactions so multiple lookups can be
@@ -492,6 +675,10 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress btcutil.Address) (*Bloc
 	// in the block once each transaction has been included.
 	dependers := make(map[chainhash.Hash]map[chainhash.Hash]*txPrioItem)
 
+	// items holds all of the transactions which are considered for
+	// inclusion in the block.
+	items := make(map[chainhash.Hash]*txPrioItem, len(sourceTxns))
+
 	// Create slices to hold the fees and number of signature operations
 	// for each of the selected transactions and add an entry for the
 	// coinbase.  This allows the code below to simply append details about
@@ -534,10 +721,20 @@ mempoolLoop:
 			continue
 		}
 
+		// Skip transactions which have too many ancestors in the
+		// source pool according to its aggregates before resolving
+		// them, which keeps the walk over the ancestors short.
+		if txDesc.AncestorCount > maxPackageAncestors+1 {
+			log.Tracef("Skipping tx %s because it has %d "+
+				"unconfirmed ancestors", tx.Hash(),
+				txDesc.AncestorCount-1)
+			continue
+		}
+
 		// Setup dependencies for any transactions which reference
 		// other transactions in the mempool so they can be properly
 		// ordered below.
-		prioItem := &txPrioItem{tx: tx}
+		prioItem := &txPrioItem{tx: tx, index: -1}
 		for _, txIn := range tx.MsgTx().TxIn {
 			originHash := &txIn.PreviousOutPoint.Hash
 			originIndex := txIn.PreviousOutPoint.Index
@@ -578,15 +775,16 @@ mempoolLoop:
 		prioItem.priority = CalcPriority(tx.MsgTx(), utxos,
 			nextBlockHeight)
 
-		// Calculate the fee in Satoshi/kB.
-		prioItem.feePerKB = txDesc.FeePerKB
+		// Calculate the fee in Satoshi/kB of the package of the
+		// transaction from the ancestor aggregates of the source pool.
 		prioItem.fee = txDesc.Fee
-
-		// Add the transaction to the priority queue to mark it ready
-		// for inclusion in the block unless it has dependencies.
-		if prioItem.dependsOn == nil {
-			heap.Push(priorityQueue, prioItem)
-		}
+		prioItem.size = blockchain.GetTransactionVirtualSize(tx)
+		prioItem.packageFee = txDesc.AncestorFees
+		prioItem.packageSize = txDesc.AncestorSize
+		prioItem.feePerKB = prioItem.packageFee * 1000 /
+			prioItem.packageSize
+		prioItem.boost = boosts[*tx.Hash()]
+		items[*tx.Hash()] = prioItem
 
 		// Merge the referenced outputs from the input transactions to
 		// this transaction into the block utxo view.  This allows the
@@ -594,14 +792,45 @@ mempoolLoop:
 		mergeUtxoView(blockUtxos, utxos)
 	}
 
+	// Resolve the ancestors of the transactions which depend on other
+	// transactions in the source pool.  Transactions which depend on a
+	// transaction that can't be included in the block are skipped.
+	for hash, item := range items {
+		if !resolveAncestors(item, items) {
+			log.Tracef("Skipping tx %s because it depends on a "+
+				"transaction which is not available", hash)
+			delete(items, hash)
+		}
+	}
+	for hash, item := range items {
+		for _, ancestor := range item.ancestors {
+			if ancestor.descendants == nil {
+				ancestor.descendants = make(
+					map[chainhash.Hash]*txPrioItem)
+			}
+			ancestor.descendants[hash] = item
+		}
+
+		// Add the transaction to the priority queue to mark it ready
+		// for inclusion in the block unless it has dependencies and the
+		// queue is sorted by priority.  When it is sorted by package
+		// fees, the dependencies are included along with it, as they
+		// are for boosted transactions regardless of the sort order.
+		if sortedByFee || item.dependsOn == nil || item.boost != 0 {
+			heap.Push(priorityQueue, item)
+		}
+	}
+
 	log.Tracef("Priority queue len %d, dependers len %d",
 		priorityQueue.Len(), len(dependers))
 
-	// The starting block size is the size of the block header plus the max
-	// possible transaction count size, plus the size of the coinbase
-	// transaction.
+	// The starting block weight is the weight of the block header plus the
+	// max possible transaction count size, plus the weight of the coinbase
+	// transaction.  The size of the high-priority area is converted to a
+	// weight as well since block templates are only limited by weight.
 	blockWeight := uint32((blockHeaderOverhead * blockchain.WitnessScaleFactor) +
 		blockchain.GetTransactionWeight(coinbaseTx))
+	priorityWeight := g.policy.BlockPrioritySize * blockchain.WitnessScaleFactor
 	blockSigOpCost := coinbaseSigOpCost
 	totalFees := int64(0)
 
@@ -617,113 +846,139 @@ mempoolLoop:
 
 	witnessIncluded := false
 
+	// When segregated witness is active, the coinbase transaction will
+	// need to include a witness commitment once a transaction bearing
+	// witness data is included.  Therefore, we account for the additional
+	// weight within the block with a model coinbase tx with a witness
+	// commitment.
+	var witnessCommitmentWeight uint32
+	if segwitActive {
+		coinbaseCopy := btcutil.NewTx(coinbaseTx.MsgTx().Copy())
+		coinbaseCopy.MsgTx().TxIn[0].Witness = [][]byte{
+			bytes.Repeat([]byte("a"),
+				blockchain.CoinbaseWitnessDataLen),
+		}
+		coinbaseCopy.MsgTx().AddTxOut(&wire.TxOut{
+			PkScript: bytes.Repeat([]byte("a"),
+				blockchain.CoinbaseWitnessPkScriptLength),
+		})
+
+		// In order to accurately account for the weight addition due
+		// to this coinbase transaction, we'll add the difference of the
+		// transaction before and after the addition of the commitment
+		// to the block weight.
+		witnessCommitmentWeight = uint32(
+			blockchain.GetTransactionWeight(coinbaseCopy) -
+				blockchain.GetTransactionWeight(coinbaseTx))
+	}
+
+	// failed tracks the transactions which could not be included in the
+	// block.  The packages of the transactions which depend on them are
+	// skipped as well.
+	failed := make(map[chainhash.Hash]struct{})
+
 	// Choose which transactions make it into the block.
 	for priorityQueue.Len() > 0 {
-		// Grab the highest priority (or highest fee per kilobyte
-		// depending on the sort order) transaction.
+		// Grab the highest priority (or highest package fee per
+		// kilobyte depending on the sort order) transaction along with
+		// the ancestors which have to be included before it.
 		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
 		tx := prioItem.tx
-
-		switch {
-		// If segregated witness has not been activated yet, then we
-		// shouldn't include any witness transactions in the block.
-		case !segwitActive && tx.HasWitness():
-			continue
-
-		// Otherwise, Keep track of if we've included a transaction
-		// with witness data or not. If so, then we'll need to include
-		// the witness commitment as the last output in the coinbase
-		// transaction.
-		case segwitActive && !witnessIncluded && tx.HasWitness():
-			// If we're about to include a transaction bearing
-			// witness data, then we'll also need to include a
-			// witness commitment in the coinbase transaction.
-			// Therefore, we account for the additional weight
-			// within the block with a model coinbase tx with a
-			// witness commitment.
-			coinbaseCopy := btcutil.NewTx(coinbaseTx.MsgTx().Copy())
-			coinbaseCopy.MsgTx().TxIn[0].Witness = [][]byte{
-				bytes.Repeat([]byte("a"),
-					blockchain.CoinbaseWitnessDataLen),
-			}
-			coinbaseCopy.MsgTx().AddTxOut(&wire.TxOut{
-				PkScript: bytes.Repeat([]byte("a"),
-					blockchain.CoinbaseWitnessPkScriptLength),
-			})
-
-			// In order to accurately account for the weight
-			// addition due to this coinbase transaction, we'll add
-			// the difference of the transaction before and after
-			// the addition of the commitment to the block weight.
-			weightDiff := blockchain.GetTransactionWeight(coinbaseCopy) -
-				blockchain.GetTransactionWeight(coinbaseTx)
-
-			blockWeight += uint32(weightDiff)
-
-			witnessIncluded = true
-		}
+		pkg := prioItem.pkg()
 
 		// Grab any transactions which depend on this one.
 		deps := dependers[*tx.Hash()]
 
-		// Enforce maximum block size.  Also check for overflow.
-		txWeight := uint32(blockchain.GetTransactionWeight(tx))
-		blockPlusTxWeight := blockWeight + txWeight
-		if blockPlusTxWeight < blockWeight ||
-			blockPlusTxWeight >= g.policy.BlockMaxWeight {
-
-			log.Tracef("Skipping tx %s because it would exceed "+
-				"the max block weight", tx.Hash())
-			logSkippedDeps(tx, deps)
+		// Skip the package when a transaction in it already failed to
+		// be included.
+		var pkgWeight uint32
+		pkgFailed, pkgHasWitness := false, false
+		for _, item := range pkg {
+			if _, ok := failed[*item.tx.Hash()]; ok {
+				pkgFailed = true
+			}
+			if item.tx.HasWitness() {
+				pkgHasWitness = true
+			}
+			pkgWeight += uint32(blockchain.GetTransactionWeight(item.tx))
+		}
+		if pkgFailed {
+			log.Tracef("Skipping tx %s because it depends on a "+
+				"transaction which failed to be included",
+				tx.Hash())
+			failed[*tx.Hash()] = struct{}{}
 			continue
 		}
 
-		// Enforce maximum signature operation cost per block.  Also
-		// check for overflow.
-		sigOpCost, err := blockchain.GetSigOpCost(tx, false,
-			blockUtxos, true, segwitActive)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"GetSigOpCost: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
+		// If segregated witness has not been activated yet, then we
+		// shouldn't include any witness transactions in the block.
+		if !segwitActive && pkgHasWitness {
+			failed[*tx.Hash()] = struct{}{}
 			continue
 		}
-		if blockSigOpCost+int64(sigOpCost) < blockSigOpCost ||
-			blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
-			log.Tracef("Skipping tx %s because it would "+
-				"exceed the maximum sigops per block", tx.Hash())
+
+		// Enforce maximum block weight.  Also check for overflow.  The
+		// witness commitment is accounted for when the package is the
+		// first one with witness data.
+		blockPlusPkgWeight := blockWeight + pkgWeight
+		if !witnessIncluded && pkgHasWitness {
+			blockPlusPkgWeight += witnessCommitmentWeight
+		}
+		if blockPlusPkgWeight < blockWeight ||
+			blockPlusPkgWeight >= g.policy.BlockMaxWeight {
+
+			log.Tracef("Skipping tx %s because it would exceed "+
+				"the max block weight", tx.Hash())
 			logSkippedDeps(tx, deps)
+			failed[*tx.Hash()] = struct{}{}
 			continue
 		}
 
-		// Skip free transactions once the block is larger than the
-		// minimum block size.
-		if sortedByFee &&
-			prioItem.feePerKB < int64(g.policy.TxMinFreeFee) &&
-			blockPlusTxWeight >= g.policy.BlockMinWeight {
+		// Skip free transactions once the block is heavier than the
+		// minimum block weight.  Boosted transactions are exempt since
+		// their fees may have been paid out of band.
+		if sortedByFee && prioItem.boost == 0 &&
+			prioItem.feePerKB < int64(g.policy.minFreeFee()) &&
+			blockPlusPkgWeight >= g.policy.BlockMinWeight {
 
-			log.Tracef("Skipping tx %s with feePerKB %d "+
-				"< TxMinFreeFee %d and block weight %d >= "+
+			log.Tracef("Skipping tx %s with package feePerKB %d "+
+				"< minFreeFee %d and block weight %d >= "+
 				"minBlockWeight %d", tx.Hash(), prioItem.feePerKB,
-				g.policy.TxMinFreeFee, blockPlusTxWeight,
+				g.policy.minFreeFee(), blockPlusPkgWeight,
 				g.policy.BlockMinWeight)
 			logSkippedDeps(tx, deps)
 			continue
 		}
 
-		// Prioritize by fee per kilobyte once the block is larger than
-		// the priority size or there are no more high-priority
-		// transactions.
-		if !sortedByFee && (blockPlusTxWeight >= g.policy.BlockPrioritySize ||
+		// Prioritize by package fee per kilobyte once the block is
+		// larger than the priority size or there are no more
+		// high-priority transactions.  Transactions are only selected
+		// by priority when they don't depend on any transaction which
+		// is not in the block yet, so the package only consists of the
+		// transaction itself at this point, unless it is boosted.
+		// Boosted transactions are selected first regardless of the
+		// high-priority area.
+		if !sortedByFee && prioItem.boost == 0 && (blockPlusPkgWeight >= priorityWeight ||
 			prioItem.priority <= MinHighPriority) {
 
-			log.Tracef("Switching to sort by fees per "+
-				"kilobyte blockSize %d >= BlockPrioritySize "+
+			log.Tracef("Switching to sort by package fees per "+
+				"kilobyte blockWeight %d >= priorityWeight "+
 				"%d || priority %.2f <= minHighPriority %.2f",
-				blockPlusTxWeight, g.policy.BlockPrioritySize,
+				blockPlusPkgWeight, priorityWeight,
 				prioItem.priority, MinHighPriority)
 
+			// Add all of the remaining transactions to the priority
+			// queue, including the ones which depend on
+			// transactions that are not in the block yet, since
+			// they are now selected along with their ancestors.
 			sortedByFee = true
+			for _, item := range items {
+				if !item.included && item.index == -1 &&
+					item != prioItem {
+
+					heap.Push(priorityQueue, item)
+				}
+			}
 			priorityQueue.SetLessFunc(txPQByFee)
 
 			// Put the transaction back into the priority queue and
@@ -732,7 +987,7 @@ mempoolLoop:
 			// is too low.  Otherwise this transaction will be the
 			// final one in the high-priority section, so just fall
 			// though to the code below so it is added now.
-			if blockPlusTxWeight > g.policy.BlockPrioritySize ||
+			if blockPlusPkgWeight > priorityWeight ||
 				prioItem.priority < MinHighPriority {
 
 				heap.Push(priorityQueue, prioItem)
@@ -740,55 +995,91 @@ mempoolLoop:
 			}
 		}
 
-		// Ensure the transaction inputs pass all of the necessary
-		// preconditions before allowing it to be added to the block.
-		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
-			blockUtxos, g.chainParams)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"CheckTransactionInputs: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
-		}
-		err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
-			txscript.StandardVerifyFlags, g.sigCache,
-			g.hashCache)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"ValidateTransactionScripts: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
-		}
-
-		// Spend the transaction inputs in the block utxo view and add
-		// an entry for it to ensure any transactions which reference
-		// this one have it available as an input and can ensure they
-		// aren't double spending.
-		spendTransaction(blockUtxos, tx, nextBlockHeight)
+		// Add the transactions of the package to the block in the
+		// order they depend on each other.  Should one of them fail
+		// any of the checks below, the ones before it remain in the
+		// block since they are valid on their own.
+		for _, item := range pkg {
+			tx := item.tx
+			deps := dependers[*tx.Hash()]
+
+			// Enforce maximum signature operation cost per block.
+			// Also check for overflow.
+			sigOpCost, err := blockchain.GetSigOpCost(tx, false,
+				blockUtxos, true, segwitActive)
+			if err != nil {
+				log.Tracef("Skipping tx %s due to error in "+
+					"GetSigOpCost: %v", tx.Hash(), err)
+				logSkippedDeps(tx, deps)
+				failed[*tx.Hash()] = struct{}{}
+				failed[*prioItem.tx.Hash()] = struct{}{}
+				break
+			}
+			if blockSigOpCost+int64(sigOpCost) < blockSigOpCost ||
+				blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
+				log.Tracef("Skipping tx %s because it would "+
+					"exceed the maximum sigops per block", tx.Hash())
+				logSkippedDeps(tx, deps)
+				failed[*tx.Hash()] = struct{}{}
+				failed[*prioItem.tx.Hash()] = struct{}{}
+				break
+			}
 
-		// Add the transaction to the block, increment counters, and
-		// save the fees and signature operation counts to the block
-		// template.
-		blockTxns = append(blockTxns, tx)
-		blockWeight += txWeight
-		blockSigOpCost += int64(sigOpCost)
-		totalFees += prioItem.fee
-		txFees = append(txFees, prioItem.fee)
-		txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
+			// Ensure the transaction inputs pass all of the
+			// necessary preconditions before allowing it to be
+			// added to the block.
+			_, err = blockchain.CheckTransactionInputs(tx,
+				nextBlockHeight, blockUtxos, g.chainParams)
+			if err != nil {
+				log.Tracef("Skipping tx %s due to error in "+
+					"CheckTransactionInputs: %v", tx.Hash(), err)
+				logSkippedDeps(tx, deps)
+				failed[*tx.Hash()] = struct{}{}
+				failed[*prioItem.tx.Hash()] = struct{}{}
+				break
+			}
+			err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
+				txscript.StandardVerifyFlags, g.sigCache,
+				g.hashCache)
+			if err != nil {
+				log.Tracef("Skipping tx %s due to error in "+
+					"ValidateTransactionScripts: %v", tx.Hash(), err)
+				logSkippedDeps(tx, deps)
+				failed[*tx.Hash()] = struct{}{}
+				failed[*prioItem.tx.Hash()] = struct{}{}
+				break
+			}
 
-		log.Tracef("Adding tx %s (priority %.2f, feePerKB %.2f)",
-			prioItem.tx.Hash(), prioItem.priority, prioItem.feePerKB)
-
-		// Add transactions which depend on this one (and also do not
-		// have any other unsatisified dependencies) to the priority
-		// queue.
-		for _, item := range deps {
-			// Add the transaction to the priority queue if there
-			// are no more dependencies after this one.
-			delete(item.dependsOn, *tx.Hash())
-			if len(item.dependsOn) == 0 {
-				heap.Push(priorityQueue, item)
+			// Keep track of if we've included a transaction with
+			// witness data or not. If so, then we'll need to
+			// include the witness commitment as the last output in
+			// the coinbase transaction.
+			if !witnessIncluded && tx.HasWitness() {
+				blockWeight += witnessCommitmentWeight
+				witnessIncluded = true
 			}
+
+			// Spend the transaction inputs in the block utxo view
+			// and add an entry for it to ensure any transactions
+			// which reference this one have it available as an
+			// input and can ensure they aren't double spending.
+			spendTransaction(blockUtxos, tx, nextBlockHeight)
+
+			// Add the transaction to the block, increment counters,
+			// and save the fees and signature operation counts to
+			// the block template.
+			blockTxns = append(blockTxns, tx)
+			blockWeight += uint32(blockchain.GetTransactionWeight(tx))
+			blockSigOpCost += int64(sigOpCost)
+			totalFees += item.fee
+			txFees = append(txFees, item.fee)
+			txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
+
+			log.Tracef("Adding tx %s (priority %.2f, package "+
+				"feePerKB %d)", tx.Hash(), item.priority,
+				prioItem.feePerKB)
+
+			includePrioItem(priorityQueue, item, deps, !sortedByFee)
 		}
 	}
 
@@ -806,42 +1097,194 @@ mempoolLoop:
 	// OP_RETURN output within the coinbase transaction.
 	var witnessCommitment []byte
 	if witnessIncluded {
-		// The witness of the coinbase transaction MUST be exactly 32-bytes
-		// of all zeroes.
-		var witnessNonce [blockchain.CoinbaseWitnessDataLen]byte
-		coinbaseTx.MsgTx().TxIn[0].Witness = wire.TxWitness{witnessNonce[:]}
-
-		// Next, obtain the merkle root of a tree which consists of the
-		// wtxid of all transactions in the block. The coinbase
-		// transaction will have a special wtxid of all zeroes.
-		witnessMerkleTree := blockchain.BuildMerkleTreeStore(blockTxns,
-			true)
-		witnessMerkleRoot := witnessMerkleTree[len(witnessMerkleTree)-1]
-
-		// The preimage to the witness commitment is:
-		// witnessRoot || coinbaseWitness
-		var witnessPreimage [64]byte
-		copy(witnessPreimage[:32], witnessMerkleRoot[:])
-		copy(witnessPreimage[32:], witnessNonce[:])
-
-		// The witness commitment itself is the double-sha256 of the
-		// witness preimage generated above. With the commitment
-		// generated, the witness script for the output is: OP_RETURN
-		// OP_DATA_36 {0xaa21a9ed || witnessCommitment}. The leading
-		// prefix is refered to as the "witness magic bytes".
-		witnessCommitment = chainhash.DoubleHashB(witnessPreimage[:])
-		witnessScript := append(blockchain.WitnessMagicBytes, witnessCommitment...)
-
-		// Finally, create the OP_RETURN carrying witness commitment
-		// output as an additional output within the coinbase.
-		commitmentOutput := &wire.TxOut{
-			Value:    0,
-			PkScript: witnessScript,
-		}
-		coinbaseTx.MsgTx().TxOut = append(coinbaseTx.MsgTx().TxOut,
-			commitmentOutput)
+		witnessCommitment = blockchain.AddWitnessCommitment(coinbaseTx,
+			blockTxns)
+	}
+
+	// Create a new block ready to be solved.
+	msgBlock, err := g.newTemplateBlock(best, blockTxns)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Created new block template (%d transactions, %d in "+
+		"fees, %d signature operations cost, %d weight, target difficulty "+
+		"%064x)", len(msgBlock.Transactions), totalFees, blockSigOpCost,
+		blockWeight, blockchain.CompactToBig(msgBlock.Header.Bits))
+
+	return &BlockTemplate{
+		Block:             msgBlock,
+		Fees:              txFees,
+		SigOpCosts:        txSigOpCosts,
+		Height:            nextBlockHeight,
+		ValidPayAddress:   payToAddress != nil,
+		WitnessCommitment: witnessCommitment,
+	}, nil
+}
+
+// NewBlockTemplateFromTxs returns a new block template that is ready to be
+// solved which consists of a coinbase paying to the passed address followed by
+// exactly the passed transactions in the given order.  Unlike NewBlockTemplate,
+// the transaction source is not consulted and no policy is applied, so the
+// transactions may only spend outputs which are in the block chain or created
+// by the transactions before them.  An error is returned when any of the
+// transactions can't be included in the block.
+func (g *BlkTmplGenerator) NewBlockTemplateFromTxs(payToAddress btcutil.Address,
+	txs []*btcutil.Tx) (*BlockTemplate, error) {
+
+	// Extend the most recently known best block.
+	best := g.chain.BestSnapshot()
+	nextBlockHeight := best.Height + 1
+
+	// Create a standard coinbase transaction paying to the provided
+	// address.  The coinbase value is updated to include the fees of the
+	// transactions once they are known.
+	coinbaseScript, err := standardCoinbaseScript(nextBlockHeight, 0)
+	if err != nil {
+		return nil, err
+	}
+	var coinbaseLockTime uint32
+	if g.policy.CoinbaseLockTime {
+		coinbaseLockTime = uint32(best.Height)
+	}
+	coinbaseTx, err := createCoinbaseTx(g.chainParams, coinbaseScript,
+		nextBlockHeight, payToAddress, coinbaseLockTime)
+	if err != nil {
+		return nil, err
+	}
+	coinbaseSigOpCost := int64(blockchain.CountSigOps(coinbaseTx)) * blockchain.WitnessScaleFactor
+
+	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
+	if err != nil {
+		return nil, err
+	}
+	segwitActive := segwitState == blockchain.ThresholdActive
+
+	blockTxns := make([]*btcutil.Tx, 0, len(txs)+1)
+	blockTxns = append(blockTxns, coinbaseTx)
+	txFees := make([]int64, 0, len(txs)+1)
+	txSigOpCosts := make([]int64, 0, len(txs)+1)
+	txFees = append(txFees, -1) // Updated once known
+	txSigOpCosts = append(txSigOpCosts, coinbaseSigOpCost)
+	blockUtxos := blockchain.NewUtxoViewpoint()
+	blockSigOpCost := coinbaseSigOpCost
+	totalFees := int64(0)
+	witnessIncluded := false
+	for _, tx := range txs {
+		// The supplied transactions did not go through the memory
+		// pool, so they are checked for sanity first.
+		if err := blockchain.CheckTransactionSanity(tx); err != nil {
+			return nil, fmt.Errorf("transaction %s: %v", tx.Hash(),
+				err)
+		}
+		if blockchain.IsCoinBase(tx) {
+			return nil, fmt.Errorf("transaction %s is a coinbase",
+				tx.Hash())
+		}
+		if !segwitActive && tx.HasWitness() {
+			return nil, fmt.Errorf("transaction %s has witness "+
+				"data before segwit is active", tx.Hash())
+		}
+		if !blockchain.IsFinalizedTransaction(tx, nextBlockHeight,
+			g.timeSource.AdjustedTime()) {
+
+			return nil, fmt.Errorf("transaction %s is not "+
+				"finalized", tx.Hash())
+		}
+
+		// Fetch the utxos referenced by the transaction which are in
+		// the block chain.  The outputs of the transactions before it
+		// in the block are already in the block utxo view.
+		utxos, err := g.chain.FetchUtxoView(tx)
+		if err != nil {
+			return nil, err
+		}
+		mergeUtxoView(blockUtxos, utxos)
+
+		sigOpCost, err := blockchain.GetSigOpCost(tx, false, blockUtxos,
+			true, segwitActive)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %s: %v", tx.Hash(),
+				err)
+		}
+		if blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
+			return nil, fmt.Errorf("transaction %s would exceed "+
+				"the maximum sigops per block", tx.Hash())
+		}
+		fee, err := blockchain.CheckTransactionInputs(tx,
+			nextBlockHeight, blockUtxos, g.chainParams)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %s: %v", tx.Hash(),
+				err)
+		}
+		err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
+			txscript.StandardVerifyFlags, g.sigCache, g.hashCache)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %s: %v", tx.Hash(),
+				err)
+		}
+		spendTransaction(blockUtxos, tx, nextBlockHeight)
+
+		if tx.HasWitness() {
+			witnessIncluded = true
+		}
+		blockTxns = append(blockTxns, tx)
+		blockSigOpCost += int64(sigOpCost)
+		totalFees += fee
+		txFees = append(txFees, fee)
+		txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
+	}
+	coinbaseTx.MsgTx().TxOut[0].Value += totalFees
+	txFees[0] = -totalFees
+
+	var witnessCommitment []byte
+	if witnessIncluded {
+		witnessCommitment = blockchain.AddWitnessCommitment(coinbaseTx,
+			blockTxns)
 	}
 
+	// The supplied transactions are not limited by the block weight
+	// policy, but they must fit into a block according to the consensus
+	// rules.
+	blockWeight := int64(wire.MaxBlockHeaderPayload+
+		wire.VarIntSerializeSize(uint64(len(blockTxns)))) *
+		blockchain.WitnessScaleFactor
+	for _, tx := range blockTxns {
+		blockWeight += blockchain.GetTransactionWeight(tx)
+	}
+	if blockWeight > blockchain.MaxBlockWeight {
+		return nil, fmt.Errorf("block weight of %d with the supplied "+
+			"transactions is more than the max allowed weight of %d",
+			blockWeight, blockchain.MaxBlockWeight)
+	}
+
+	// Create a new block ready to be solved.
+	msgBlock, err := g.newTemplateBlock(best, blockTxns)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Created new block template from %d supplied "+
+		"transactions (%d in fees)", len(txs), totalFees)
+
+	return &BlockTemplate{
+		Block:             msgBlock,
+		Fees:              txFees,
+		SigOpCosts:        txSigOpCosts,
+		Height:            nextBlockHeight,
+		ValidPayAddress:   payToAddress != nil,
+		WitnessCommitment: witnessCommitment,
+	}, nil
+}
+
+// newTemplateBlock returns a new block ready to be solved which extends the
+// passed best block and consists of the passed transactions, the first of
+// which must be the coinbase.  The required difficulty and block version are
+// calculated and the block is fully checked against the chain consensus rules
+// to ensure it properly connects to the current best chain with no issues.
+func (g *BlkTmplGenerator) newTemplateBlock(best *blockchain.BestState,
+	blockTxns []*btcutil.Tx) (*wire.MsgBlock, error) {
+
 	// Calculate the required difficulty for the block.  The timestamp
 	// is potentially adjusted to ensure it comes after the median time of
 	// the last several blocks per the chain consensus rules.
@@ -858,13 +1301,11 @@ mempoolLoop:
 		return nil, err
 	}
 
-	// Create a new block ready to be solved.
-	merkles := blockchain.BuildMerkleTreeStore(blockTxns, false)
 	var msgBlock wire.MsgBlock
 	msgBlock.Header = wire.BlockHeader{
 		Version:    nextBlockVersion,
 		PrevBlock:  best.Hash,
-		MerkleRoot: *merkles[len(merkles)-1],
+		MerkleRoot: blockchain.CalcMerkleRoot(blockTxns, false),
 		Timestamp:  ts,
 		Bits:       reqDifficulty,
 	}
@@ -878,24 +1319,11 @@ mempoolLoop:
 	// consensus rules to ensure it properly connects to the current best
 	// chain with no issues.
 	block := btcutil.NewBlock(&msgBlock)
-	block.SetHeight(nextBlockHeight)
+	block.SetHeight(best.Height + 1)
 	if err := g.chain.CheckConnectBlock(block); err != nil {
 		return nil, err
 	}
-
-	log.Debugf("Created new block template (%d transactions, %d in "+
-		"fees, %d signature operations cost, %d weight, target difficulty "+
-		"%064x)", len(msgBlock.Transactions), totalFees, blockSigOpCost,
-		blockWeight, blockchain.CompactToBig(msgBlock.Header.Bits))
-
-	return &BlockTemplate{
-		Block:             &msgBlock,
-		Fees:              txFees,
-		SigOpCosts:        txSigOpCosts,
-		Height:            nextBlockHeight,
-		ValidPayAddress:   payToAddress != nil,
-		WitnessCommitment: witnessCommitment,
-	}, nil
+	return &msgBlock, nil
 }
 
 // UpdateBlockTime updates the timestamp in the header of the passed block to
@@ -946,8 +1374,8 @@ func (g *BlkTmplGenerator) UpdateExtraNonce(msgBlock *wire.MsgBlock, blockHeight
 
 	// Recalculate the merkle root with the updated extra nonce.
 	block := btcutil.NewBlock(msgBlock)
-	merkles := blockchain.BuildMerkleTreeStore(block.Transactions(), false)
-	msgBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+	msgBlock.Header.MerkleRoot = blockchain.CalcMerkleRoot(
+		block.Transactions(), false)
 	return nil
 }
 
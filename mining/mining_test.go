@@ -9,6 +9,8 @@ import (
 	"math/rand"
 	"testing"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 )
 
@@ -108,3 +110,101 @@ func TestTxFeePrioHeap(t *testing.T) {
 		highest = prioItem
 	}
 }
+
+// TestPackageSelection ensures transactions are selected by the fee per
+// kilobyte of their package so a child paying a high fee pulls in its low-fee
+// parent ahead of other transactions, and that the packages of descendants are
+// updated as their ancestors are included.
+func TestPackageSelection(t *testing.T) {
+	t.Parallel()
+
+	newItem := func(lockTime uint32, fee, size int64) *txPrioItem {
+		tx := btcutil.NewTx(&wire.MsgTx{LockTime: lockTime})
+		return &txPrioItem{tx: tx, fee: fee, size: size, index: -1}
+	}
+	parent := newItem(1, 100, 1000)
+	child := newItem(2, 5000, 200)
+	other := newItem(3, 2000, 500)
+	child.dependsOn = map[chainhash.Hash]struct{}{
+		*parent.tx.Hash(): {},
+	}
+	items := map[chainhash.Hash]*txPrioItem{
+		*parent.tx.Hash(): parent,
+		*child.tx.Hash():  child,
+		*other.tx.Hash():  other,
+	}
+
+	priorityQueue := newTxPriorityQueue(len(items), true)
+	for hash, item := range items {
+		if !resolveAncestors(item, items) {
+			t.Fatalf("unable to resolve ancestors of %v", hash)
+		}
+		item.packageFee, item.packageSize = item.fee, item.size
+		for _, ancestor := range item.ancestors {
+			if ancestor.descendants == nil {
+				ancestor.descendants = make(
+					map[chainhash.Hash]*txPrioItem)
+			}
+			ancestor.descendants[hash] = item
+			item.packageFee += ancestor.fee
+			item.packageSize += ancestor.size
+		}
+		item.feePerKB = item.packageFee * 1000 / item.packageSize
+		heap.Push(priorityQueue, item)
+	}
+
+	// The package of the child pays the highest fee per kilobyte, so the
+	// parent is selected along with it.
+	var selected []*txPrioItem
+	for priorityQueue.Len() > 0 {
+		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
+		for _, item := range prioItem.pkg() {
+			selected = append(selected, item)
+			includePrioItem(priorityQueue, item, nil, false)
+		}
+	}
+	want := []*txPrioItem{parent, child, other}
+	if len(selected) != len(want) {
+		t.Fatalf("selected %d transactions, want %d", len(selected),
+			len(want))
+	}
+	for i := range want {
+		if selected[i] != want[i] {
+			t.Fatalf("transaction %d is %v, want %v", i,
+				selected[i].tx.Hash(), want[i].tx.Hash())
+		}
+	}
+	if child.packageFee != child.fee || child.packageSize != child.size {
+		t.Fatalf("package of child not updated after parent was " +
+			"included")
+	}
+
+	// Transactions depending on a transaction which is not available
+	// can't be included.
+	orphan := newItem(4, 1000, 100)
+	orphan.dependsOn = map[chainhash.Hash]struct{}{{0x01}: {}}
+	if resolveAncestors(orphan, items) {
+		t.Fatal("resolved ancestors of transaction with missing parent")
+	}
+
+	// Transactions with more ancestors than the limit can't be included,
+	// while their ancestors within the limit can.
+	chain := make([]*txPrioItem, maxPackageAncestors+2)
+	for i := range chain {
+		chain[i] = newItem(uint32(100+i), 1000, 100)
+		if i > 0 {
+			chain[i].dependsOn = map[chainhash.Hash]struct{}{
+				*chain[i-1].tx.Hash(): {},
+			}
+		}
+		items[*chain[i].tx.Hash()] = chain[i]
+	}
+	if resolveAncestors(chain[len(chain)-1], items) {
+		t.Fatal("resolved ancestors of transaction exceeding the " +
+			"ancestor limit")
+	}
+	if !resolveAncestors(chain[len(chain)-2], items) {
+		t.Fatal("unable to resolve ancestors of transaction at the " +
+			"ancestor limit")
+	}
+}
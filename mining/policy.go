@@ -6,6 +6,7 @@ package mining
 
 import (
 	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/policy"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 )
@@ -44,7 +45,35 @@ type Policy struct {
 	// TxMinFreeFee is the minimum fee in Satoshi/1000 bytes that is
 	// required for a transaction to be treated as free for mining purposes
 	// (block template generation).
+	//
+	// Deprecated: Use Standard.MinRelayTxFee instead.  This is only used
+	// when Standard is not set.
 	TxMinFreeFee btcutil.Amount
+
+	// Standard defines the standardness rules shared with the mempool.
+	// Transactions paying less than its minimum relay fee are treated as
+	// free when generating a block template, the same as they are when
+	// they are relayed.
+	Standard policy.Policy
+
+	// CoinbaseLockTime defines whether the coinbase transaction of
+	// generated block templates sets its lock time to the height of the
+	// block being built upon, as wallets do to discourage fee sniping.  The
+	// coinbase then can't be reused in a block which reorganizes the
+	// current tip and it serves as guidance for the lock time expected of
+	// other transactions.
+	CoinbaseLockTime bool
+}
+
+// minFreeFee returns the minimum fee in Satoshi/1000 bytes that is required for
+// a transaction to not be treated as free when generating a block template.
+func (p *Policy) minFreeFee() btcutil.Amount {
+	// Callers which predate the standardness policy only set the
+	// deprecated TxMinFreeFee.
+	if p.Standard == (policy.Policy{}) {
+		return p.TxMinFreeFee
+	}
+	return p.Standard.MinRelayTxFee
 }
 
 // minInt is a helper function to return the minimum of two ints.  This avoids
@@ -10,6 +10,7 @@ import (
 
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/policy"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 )
@@ -166,3 +167,27 @@ func TestCalcPriority(t *testing.T) {
 		}
 	}
 }
+
+// TestMinFreeFee ensures the fee below which transactions are treated as free
+// comes from the standardness policy shared with the mempool, falling back to
+// the deprecated TxMinFreeFee when it is not set.
+func TestMinFreeFee(t *testing.T) {
+	standard := policy.New()
+	standard.MinRelayTxFee = 5000
+
+	tests := []struct {
+		name   string
+		policy Policy
+		want   btcutil.Amount
+	}{
+		{"deprecated", Policy{TxMinFreeFee: 2000}, 2000},
+		{"standard", Policy{Standard: *standard}, 5000},
+		{"both", Policy{TxMinFreeFee: 2000, Standard: *standard}, 5000},
+	}
+	for _, test := range tests {
+		if got := test.policy.minFreeFee(); got != test.want {
+			t.Errorf("minFreeFee (%s): got %v, want %v", test.name,
+				got, test.want)
+		}
+	}
+}
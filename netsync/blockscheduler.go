@@ -0,0 +1,254 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// blockDownloadWindow is the maximum number of blocks past the next
+	// block to be processed which are requested during headers-first mode.
+	// Blocks which arrive out of order are held until the blocks before
+	// them have been processed, so this bounds the number of blocks held
+	// in memory.
+	blockDownloadWindow = 1024
+
+	// maxBlocksInFlightPerPeer is the maximum number of blocks requested
+	// from a single peer at a time during headers-first mode.
+	maxBlocksInFlightPerPeer = 16
+
+	// blockStallTimeout is the duration a peer which has been requested
+	// blocks may go without delivering any of them before it is considered
+	// to be stalling the download.
+	blockStallTimeout = 30 * time.Second
+
+	// stallSampleInterval is the interval at which the peers are checked
+	// for stalls.
+	stallSampleInterval = 5 * time.Second
+)
+
+// downloadPeer is a peer blocks can be downloaded from.  The block scheduler
+// only depends on this interface so it can be exercised without real peers.
+type downloadPeer interface {
+	// LastBlock returns the height of the latest block the peer is known
+	// to have.
+	LastBlock() int32
+}
+
+// scheduledBlock houses the download state of a block in the header chain.
+type scheduledBlock struct {
+	node  *headerNode
+	peer  downloadPeer
+	block *btcutil.Block
+}
+
+// schedulerPeer houses the download state of a peer.
+type schedulerPeer struct {
+	inFlight     int
+	lastProgress time.Time
+}
+
+// blockScheduler distributes the download of the blocks described by a
+// validated header chain across multiple peers.  Only the blocks within a
+// window past the next block to be processed are requested, and each peer is
+// limited in the number of blocks it is requested at a time.  Since the blocks
+// may arrive in any order, they are held until all of the blocks before them
+// have arrived so they can be processed in order.
+//
+// Peers which have been requested blocks, but have not delivered any of them
+// within a timeout are reported as stalled, after which they should be removed
+// so their blocks are requested from other peers.
+//
+// The block scheduler is not safe for concurrent access.
+type blockScheduler struct {
+	window       int
+	maxPerPeer   int
+	stallTimeout time.Duration
+
+	blocks    []*scheduledBlock
+	byHash    map[chainhash.Hash]*scheduledBlock
+	peers     map[downloadPeer]*schedulerPeer
+	peerOrder []downloadPeer
+}
+
+// newBlockScheduler returns a new block scheduler which requests blocks up to
+// the passed window past the next block to be processed, at most the passed
+// number of blocks from each peer at a time, and considers peers stalled once
+// they have not delivered a requested block for the passed duration.
+func newBlockScheduler(window, maxPerPeer int, stallTimeout time.Duration) *blockScheduler {
+	return &blockScheduler{
+		window:       window,
+		maxPerPeer:   maxPerPeer,
+		stallTimeout: stallTimeout,
+		byHash:       make(map[chainhash.Hash]*scheduledBlock),
+		peers:        make(map[downloadPeer]*schedulerPeer),
+	}
+}
+
+// Reset removes all blocks from the scheduler.  The peers are kept, but no
+// longer have any blocks in flight.
+func (s *blockScheduler) Reset() {
+	s.blocks = nil
+	s.byHash = make(map[chainhash.Hash]*scheduledBlock)
+	for _, state := range s.peers {
+		state.inFlight = 0
+	}
+}
+
+// Len returns the number of blocks which have not been returned by Next yet.
+func (s *blockScheduler) Len() int {
+	return len(s.blocks)
+}
+
+// AddHeaders appends the blocks described by the passed header nodes, which
+// must extend the header chain of the blocks already in the scheduler, to the
+// blocks to download.
+func (s *blockScheduler) AddHeaders(nodes []*headerNode) {
+	for _, node := range nodes {
+		sb := &scheduledBlock{node: node}
+		s.blocks = append(s.blocks, sb)
+		s.byHash[*node.hash] = sb
+	}
+}
+
+// IsScheduled returns whether the block with the passed hash is one of the
+// blocks in the scheduler.
+func (s *blockScheduler) IsScheduled(hash *chainhash.Hash) bool {
+	_, ok := s.byHash[*hash]
+	return ok
+}
+
+// HasPeer returns whether the passed peer is one of the peers blocks are
+// downloaded from.
+func (s *blockScheduler) HasPeer(p downloadPeer) bool {
+	_, ok := s.peers[p]
+	return ok
+}
+
+// AddPeer adds the passed peer to the peers blocks are downloaded from.
+func (s *blockScheduler) AddPeer(p downloadPeer) {
+	if s.HasPeer(p) {
+		return
+	}
+	s.peers[p] = &schedulerPeer{}
+	s.peerOrder = append(s.peerOrder, p)
+}
+
+// RemovePeer removes the passed peer from the peers blocks are downloaded from
+// and returns the hashes of the blocks it had in flight.  Those blocks are
+// requested from other peers by the following calls to Schedule.
+func (s *blockScheduler) RemovePeer(p downloadPeer) []chainhash.Hash {
+	if !s.HasPeer(p) {
+		return nil
+	}
+	delete(s.peers, p)
+	for i, op := range s.peerOrder {
+		if op == p {
+			s.peerOrder = append(s.peerOrder[:i], s.peerOrder[i+1:]...)
+			break
+		}
+	}
+
+	var hashes []chainhash.Hash
+	for _, sb := range s.blocks {
+		if sb.peer == p && sb.block == nil {
+			sb.peer = nil
+			hashes = append(hashes, *sb.node.hash)
+		}
+	}
+	return hashes
+}
+
+// Schedule assigns the blocks within the download window which are not in
+// flight to the peers which have room for more requests and returns the
+// blocks each peer must be requested.  Each block is assigned to the least
+// loaded peer which is known to have it.
+func (s *blockScheduler) Schedule(now time.Time) map[downloadPeer][]*headerNode {
+	requests := make(map[downloadPeer][]*headerNode)
+	window := s.blocks
+	if len(window) > s.window {
+		window = window[:s.window]
+	}
+	for _, sb := range window {
+		if sb.peer != nil {
+			continue
+		}
+
+		var best downloadPeer
+		var bestState *schedulerPeer
+		for _, p := range s.peerOrder {
+			state := s.peers[p]
+			if state.inFlight >= s.maxPerPeer ||
+				p.LastBlock() < sb.node.height {
+
+				continue
+			}
+			if bestState == nil || state.inFlight < bestState.inFlight {
+				best, bestState = p, state
+			}
+		}
+		if best == nil {
+			continue
+		}
+
+		// The stall timer of a peer starts when it is requested blocks
+		// while it has none in flight.
+		if bestState.inFlight == 0 {
+			bestState.lastProgress = now
+		}
+		bestState.inFlight++
+		sb.peer = best
+		requests[best] = append(requests[best], sb.node)
+	}
+	return requests
+}
+
+// Received records the delivery of the passed block by the passed peer and
+// returns whether it was in flight from that peer.  The block is held until it
+// is returned by Next.
+func (s *blockScheduler) Received(p downloadPeer, block *btcutil.Block, now time.Time) bool {
+	sb, ok := s.byHash[*block.Hash()]
+	if !ok || sb.peer != p || sb.block != nil {
+		return false
+	}
+	sb.block = block
+	if state, ok := s.peers[p]; ok {
+		state.inFlight--
+		state.lastProgress = now
+	}
+	return true
+}
+
+// Next removes and returns the next block in the header chain along with the
+// peer which delivered it when it has arrived.  Otherwise, nil is returned.
+func (s *blockScheduler) Next() (*btcutil.Block, downloadPeer) {
+	if len(s.blocks) == 0 || s.blocks[0].block == nil {
+		return nil, nil
+	}
+	sb := s.blocks[0]
+	s.blocks[0] = nil
+	s.blocks = s.blocks[1:]
+	delete(s.byHash, *sb.node.hash)
+	return sb.block, sb.peer
+}
+
+// Stalled returns the peers which have blocks in flight, but have not
+// delivered any of them within the stall timeout.
+func (s *blockScheduler) Stalled(now time.Time) []downloadPeer {
+	var stalled []downloadPeer
+	for _, p := range s.peerOrder {
+		state := s.peers[p]
+		if state.inFlight > 0 &&
+			now.Sub(state.lastProgress) > s.stallTimeout {
+
+			stalled = append(stalled, p)
+		}
+	}
+	return stalled
+}
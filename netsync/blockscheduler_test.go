@@ -0,0 +1,175 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// fakePeer is a download peer which claims to have the blocks up to a fixed
+// height.
+type fakePeer struct {
+	lastBlock int32
+}
+
+// LastBlock returns the height of the latest block the peer claims to have.
+func (p *fakePeer) LastBlock() int32 {
+	return p.lastBlock
+}
+
+// makeHeaderChain returns the passed number of blocks along with header nodes
+// for them starting at height 1.  The blocks only differ by their nonce, which
+// is enough to give each one a unique hash.
+func makeHeaderChain(numBlocks int) ([]*btcutil.Block, []*headerNode) {
+	blocks := make([]*btcutil.Block, 0, numBlocks)
+	nodes := make([]*headerNode, 0, numBlocks)
+	for i := 0; i < numBlocks; i++ {
+		var msgBlock wire.MsgBlock
+		msgBlock.Header.Nonce = uint32(i)
+		block := btcutil.NewBlock(&msgBlock)
+		blocks = append(blocks, block)
+		nodes = append(nodes, &headerNode{
+			height: int32(i + 1),
+			hash:   block.Hash(),
+		})
+	}
+	return blocks, nodes
+}
+
+// TestBlockScheduler ensures blocks are spread across peers within the download
+// window, delivered in order, and requested again when a peer is removed.
+func TestBlockScheduler(t *testing.T) {
+	t.Parallel()
+
+	blocks, nodes := makeHeaderChain(10)
+	s := newBlockScheduler(6, 2, time.Minute)
+	s.AddHeaders(nodes)
+	peerA := &fakePeer{lastBlock: 10}
+	peerB := &fakePeer{lastBlock: 10}
+	peerC := &fakePeer{lastBlock: 3}
+	s.AddPeer(peerA)
+	s.AddPeer(peerB)
+	s.AddPeer(peerC)
+
+	// Each peer is limited to two blocks, and the peer which only has the
+	// first three blocks can't be assigned the later ones, so only five of
+	// the six blocks within the window are requested.
+	now := time.Now()
+	requests := s.Schedule(now)
+	assigned := make(map[*headerNode]downloadPeer)
+	for p, pnodes := range requests {
+		if len(pnodes) > 2 {
+			t.Fatalf("peer %v was requested %d blocks", p, len(pnodes))
+		}
+		for _, node := range pnodes {
+			if node.height > 6 {
+				t.Fatalf("block %d outside of the window was "+
+					"requested", node.height)
+			}
+			if node.height > 3 && p == downloadPeer(peerC) {
+				t.Fatalf("block %d requested from peer without it",
+					node.height)
+			}
+			assigned[node] = p
+		}
+	}
+	if len(assigned) != 5 {
+		t.Fatalf("got %d requested blocks, want 5", len(assigned))
+	}
+	if len(s.Schedule(now)) != 0 {
+		t.Fatal("blocks requested from peers without room for them")
+	}
+
+	// Blocks must be delivered by the peer they were requested from and
+	// are only returned once all blocks before them have arrived.
+	if s.Received(peerA, blocks[7], now) {
+		t.Fatal("block which was not requested was accepted")
+	}
+	second := assigned[nodes[1]]
+	if !s.Received(second, blocks[1], now) {
+		t.Fatal("requested block was not accepted")
+	}
+	if block, _ := s.Next(); block != nil {
+		t.Fatal("block returned before the block preceding it arrived")
+	}
+	first := assigned[nodes[0]]
+	if !s.Received(first, blocks[0], now) {
+		t.Fatal("requested block was not accepted")
+	}
+	for i := 0; i < 2; i++ {
+		block, p := s.Next()
+		if block != blocks[i] {
+			t.Fatalf("block %d was not returned in order", i+1)
+		}
+		if p != assigned[nodes[i]] {
+			t.Fatalf("block %d returned with the wrong peer", i+1)
+		}
+	}
+	if s.Len() != 8 {
+		t.Fatalf("got %d remaining blocks, want 8", s.Len())
+	}
+
+	// Peers which don't deliver their blocks within the timeout are
+	// reported as stalled.
+	wantStalled := make(map[downloadPeer]struct{})
+	for _, node := range nodes[2:6] {
+		if p, ok := assigned[node]; ok {
+			wantStalled[p] = struct{}{}
+		}
+	}
+	if len(s.Stalled(now.Add(30*time.Second))) != 0 {
+		t.Fatal("peers reported as stalled before the timeout")
+	}
+	stalled := s.Stalled(now.Add(2 * time.Minute))
+	if len(stalled) != len(wantStalled) {
+		t.Fatalf("got %d stalled peers, want %d", len(stalled),
+			len(wantStalled))
+	}
+	for _, p := range stalled {
+		if _, ok := wantStalled[p]; !ok {
+			t.Fatalf("peer %v without blocks in flight reported "+
+				"as stalled", p)
+		}
+	}
+
+	// Removing a peer requeues the blocks it had in flight, which are then
+	// requested from the remaining peers.
+	var wantRequeued int
+	for _, node := range nodes[2:6] {
+		if assigned[node] == downloadPeer(peerB) {
+			wantRequeued++
+		}
+	}
+	requeued := s.RemovePeer(peerB)
+	if len(requeued) != wantRequeued {
+		t.Fatalf("got %d requeued blocks, want %d", len(requeued),
+			wantRequeued)
+	}
+	if s.HasPeer(peerB) {
+		t.Fatal("removed peer is still known")
+	}
+	for _, hash := range requeued {
+		if !s.IsScheduled(&hash) {
+			t.Fatalf("requeued block %v is no longer scheduled", hash)
+		}
+	}
+	for p := range s.Schedule(now) {
+		if p == downloadPeer(peerB) {
+			t.Fatal("blocks requested from removed peer")
+		}
+	}
+
+	// Resetting removes all blocks, but keeps the peers.
+	s.Reset()
+	if s.Len() != 0 || !s.HasPeer(peerA) || len(s.Stalled(now.Add(
+		2*time.Minute))) != 0 {
+
+		t.Fatal("unexpected state after reset")
+	}
+}
@@ -6,8 +6,14 @@
 Package netsync implements a concurrency safe block syncing protocol. The
 SyncManager communicates with connected peers to perform an initial block
 download, keep the chain and unconfirmed transaction pool in sync, and announce
-new blocks connected to the chain. Currently the sync manager selects a single
-sync peer that it downloads all blocks from until it is up to date with the
-longest chain the sync peer is aware of.
+new blocks connected to the chain. The sync manager selects a single sync peer
+that it downloads the chain from until it is up to date with the longest chain
+the sync peer is aware of. While the block headers up to the final checkpoint
+are downloaded from the sync peer, the blocks they describe are downloaded from
+the sync peer and the other outbound peers in parallel, and peers which stall
+the download have their blocks requested from the others. Transactions
+announced by several peers are only requested from one of them at a time,
+preferably an outbound peer, and are requested from the next announcer when
+the peer does not deliver them in time.
 */
 package netsync
@@ -27,6 +27,16 @@ type PeerNotifier interface {
 	TransactionConfirmed(tx *btcutil.Tx)
 }
 
+// Recorder exposes methods to record the blocks and transactions received from
+// peers as they are processed, along with how they are processed, so the
+// processing can be reproduced later.  Currently the trace recorder of the
+// server (in the main package) implements this interface.
+type Recorder interface {
+	RecordBlock(source string, block *btcutil.Block, flags blockchain.BehaviorFlags)
+
+	RecordTx(source string, tx *btcutil.Tx, tag mempool.Tag)
+}
+
 // Config is a configuration struct used to initialize a new SyncManager.
 type Config struct {
 	PeerNotifier PeerNotifier
@@ -38,4 +48,9 @@ type Config struct {
 	MaxPeers           int
 
 	FeeEstimator *mempool.FeeEstimator
+
+	// Recorder, when set, records the blocks and transactions received
+	// from peers which pass the checks of the sync manager right before
+	// they are processed.
+	Recorder Recorder
 }
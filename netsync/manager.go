@@ -22,11 +22,6 @@ import (
 )
 
 const (
-	// minInFlightBlocks is the minimum number of blocks that should be
-	// in the request queue for headers-first mode before requesting
-	// more.
-	minInFlightBlocks = 10
-
 	// maxRejectedTxns is the maximum number of rejected transactions
 	// hashes to store in memory.
 	maxRejectedTxns = 1000
@@ -34,10 +29,6 @@ const (
 	// maxRequestedBlocks is the maximum number of requested block
 	// hashes to store in memory.
 	maxRequestedBlocks = wire.MaxInvPerMsg
-
-	// maxRequestedTxns is the maximum number of requested transactions
-	// hashes to store in memory.
-	maxRequestedTxns = wire.MaxInvPerMsg
 )
 
 // zeroHash is the zero value hash (all zeros).  It is defined as a convenience.
@@ -63,6 +54,13 @@ type invMsg struct {
 	peer *peerpkg.Peer
 }
 
+// notFoundMsg packages a bitcoin notfound message and the peer it came from
+// together so the block handler has access to that information.
+type notFoundMsg struct {
+	notFound *wire.MsgNotFound
+	peer     *peerpkg.Peer
+}
+
 // headersMsg packages a bitcoin headers message and the peer it came from
 // together so the block handler has access to that information.
 type headersMsg struct {
@@ -134,7 +132,6 @@ type headerNode struct {
 type peerSyncState struct {
 	syncCandidate   bool
 	requestQueue    []*wire.InvVect
-	requestedTxns   map[chainhash.Hash]struct{}
 	requestedBlocks map[chainhash.Hash]struct{}
 }
 
@@ -157,19 +154,22 @@ type SyncManager struct {
 
 	// These fields should only be accessed from the blockHandler thread
 	rejectedTxns    map[chainhash.Hash]struct{}
-	requestedTxns   map[chainhash.Hash]struct{}
 	requestedBlocks map[chainhash.Hash]struct{}
 	syncPeer        *peerpkg.Peer
 	peerStates      map[*peerpkg.Peer]*peerSyncState
+	txRequests      *txRequestTracker
 
 	// The following fields are used for headers-first mode.
 	headersFirstMode bool
 	headerList       *list.List
-	startHeader      *list.Element
+	scheduler        *blockScheduler
 	nextCheckpoint   *chaincfg.Checkpoint
 
 	// An optional fee estimator.
 	feeEstimator *mempool.FeeEstimator
+
+	// An optional recorder of the processed blocks and transactions.
+	recorder Recorder
 }
 
 // resetHeaderState sets the headers-first mode state to values appropriate for
@@ -177,7 +177,7 @@ type SyncManager struct {
 func (sm *SyncManager) resetHeaderState(newestHash *chainhash.Hash, newestHeight int32) {
 	sm.headersFirstMode = false
 	sm.headerList.Init()
-	sm.startHeader = nil
+	sm.scheduler.Reset()
 
 	// When there is a next checkpoint, add an entry for the latest known
 	// block into the header pool.  This allows the next downloaded header
@@ -310,6 +310,10 @@ func (sm *SyncManager) startSync() {
 			bestPeer.PushGetBlocksMsg(locator, &zeroHash)
 		}
 		sm.syncPeer = bestPeer
+
+		// The sync peer downloads blocks in headers-first mode even
+		// when it would not have been chosen to on its own.
+		sm.scheduler.AddPeer(bestPeer)
 	} else {
 		log.Warnf("No sync peer candidates available")
 	}
@@ -367,9 +371,19 @@ func (sm *SyncManager) handleNewPeerMsg(peer *peerpkg.Peer) {
 	isSyncCandidate := sm.isSyncCandidate(peer)
 	sm.peerStates[peer] = &peerSyncState{
 		syncCandidate:   isSyncCandidate,
-		requestedTxns:   make(map[chainhash.Hash]struct{}),
 		requestedBlocks: make(map[chainhash.Hash]struct{}),
 	}
+	sm.txRequests.AddPeer(peer, !peer.Inbound(), time.Now())
+
+	// Outbound sync candidates download blocks in parallel with the sync
+	// peer in headers-first mode.  Inbound peers are not used since they
+	// are not chosen by us.
+	if isSyncCandidate && !peer.Inbound() {
+		sm.scheduler.AddPeer(peer)
+		if sm.headersFirstMode {
+			sm.fetchHeaderBlocks()
+		}
+	}
 
 	// Start syncing by choosing the best candidate if needed.
 	if isSyncCandidate && sm.syncPeer == nil {
@@ -393,11 +407,16 @@ func (sm *SyncManager) handleDonePeerMsg(peer *peerpkg.Peer) {
 
 	log.Infof("Lost peer %s", peer)
 
-	// Remove requested transactions from the global map so that they will
-	// be fetched from elsewhere next time we get an inv.
-	for txHash := range state.requestedTxns {
-		delete(sm.requestedTxns, txHash)
-	}
+	// Remove the peer from the peers blocks are downloaded from in
+	// headers-first mode so the blocks it had in flight are requested from
+	// other peers.
+	requeued := sm.scheduler.RemovePeer(peer)
+
+	// Remove the peer's transaction announcements so the transactions it
+	// had in flight are requested from the next peers which announced
+	// them.
+	sm.txRequests.RemovePeer(peer)
+	sm.requestTxns()
 
 	// Remove requested blocks from the global map so that they will be
 	// fetched from elsewhere next time we get an inv.
@@ -417,14 +436,15 @@ func (sm *SyncManager) handleDonePeerMsg(peer *peerpkg.Peer) {
 			sm.resetHeaderState(&best.Hash, best.Height)
 		}
 		sm.startSync()
+	} else if sm.headersFirstMode && len(requeued) > 0 {
+		sm.fetchHeaderBlocks()
 	}
 }
 
 // handleTxMsg handles transaction messages from all peers.
 func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	peer := tmsg.peer
-	state, exists := sm.peerStates[peer]
-	if !exists {
+	if _, exists := sm.peerStates[peer]; !exists {
 		log.Warnf("Received tx message from unknown peer %s", peer)
 		return
 	}
@@ -438,11 +458,12 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	// to disconnect peers for sending unsolicited transactions to provide
 	// interoperability.
 	txHash := tmsg.tx.Hash()
+	sm.txRequests.Received(txHash)
 
 	// Ignore transactions that we have already rejected.  Do not
 	// send a reject message here because if the transaction was already
 	// rejected, the transaction was unsolicited.
-	if _, exists = sm.rejectedTxns[*txHash]; exists {
+	if _, exists := sm.rejectedTxns[*txHash]; exists {
 		log.Debugf("Ignoring unsolicited previously rejected "+
 			"transaction %v from %s", txHash, peer)
 		return
@@ -450,15 +471,12 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 
 	// Process the transaction to include validation, insertion in the
 	// memory pool, orphan handling, etc.
+	tag := mempool.Tag(peer.ID())
+	if sm.recorder != nil {
+		sm.recorder.RecordTx(peer.Addr(), tmsg.tx, tag)
+	}
 	acceptedTxs, err := sm.txMemPool.ProcessTransaction(tmsg.tx,
-		true, true, mempool.Tag(peer.ID()))
-
-	// Remove transaction from request maps. Either the mempool/chain
-	// already knows about it and as such we shouldn't have any more
-	// instances of trying to fetch it, or we failed to insert and thus
-	// we'll retry next time we get an inv.
-	delete(state.requestedTxns, *txHash)
-	delete(sm.requestedTxns, *txHash)
+		true, true, tag)
 
 	if err != nil {
 		// Do not request this transaction again until a new block
@@ -534,39 +552,83 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
-	// When in headers-first mode, if the block matches the hash of the
-	// first header in the list of headers that are being fetched, it's
-	// eligible for less validation since the headers have already been
-	// verified to link together and are valid up to the next checkpoint.
-	// Also, remove the list entry for all blocks except the checkpoint
-	// since it is needed to verify the next round of headers links
-	// properly.
-	isCheckpointBlock := false
-	behaviorFlags := blockchain.BFNone
-	if sm.headersFirstMode {
-		firstNodeEl := sm.headerList.Front()
-		if firstNodeEl != nil {
-			firstNode := firstNodeEl.Value.(*headerNode)
-			if blockHash.IsEqual(firstNode.hash) {
-				behaviorFlags |= blockchain.BFFastAdd
-				if firstNode.hash.IsEqual(sm.nextCheckpoint.Hash) {
-					isCheckpointBlock = true
-				} else {
-					sm.headerList.Remove(firstNodeEl)
-				}
-			}
-		}
-	}
-
 	// Remove block from request maps. Either chain will know about it and
 	// so we shouldn't have any more instances of trying to fetch it, or we
 	// will fail the insert and thus we'll retry next time we get an inv.
 	delete(state.requestedBlocks, *blockHash)
 	delete(sm.requestedBlocks, *blockHash)
 
+	// When in headers-first mode, blocks which are part of the header chain
+	// being downloaded are eligible for less validation since the headers
+	// have already been verified to link together and are valid up to the
+	// next checkpoint.  Since they are downloaded from multiple peers, they
+	// may arrive in any order, so the scheduler holds them until they can
+	// be processed in the order of the header chain.
+	if !sm.headersFirstMode || !sm.scheduler.Received(peer, bmsg.block,
+		time.Now()) {
+
+		sm.processPeerBlock(bmsg.block, peer, blockchain.BFNone)
+		return
+	}
+	for sm.headersFirstMode {
+		block, p := sm.scheduler.Next()
+		if block == nil {
+			break
+		}
+		sender := p.(*peerpkg.Peer)
+		err := sm.processPeerBlock(block, sender, blockchain.BFFastAdd)
+		if err == nil || isDuplicateBlockErr(err) {
+			continue
+		}
+
+		// The block does not match the header chain it was requested
+		// for, so the remaining blocks can't be connected.  Start over
+		// from the best chain, and disconnect the peer which sent it
+		// unless something went wrong on our end.
+		if _, ok := err.(blockchain.RuleError); ok {
+			log.Warnf("Block %v from peer %s is invalid -- "+
+				"disconnecting", block.Hash(), sender.Addr())
+			sender.Disconnect()
+		}
+		best := sm.chain.BestSnapshot()
+		sm.resetHeaderState(&best.Hash, best.Height)
+		sm.syncPeer = nil
+		sm.startSync()
+		return
+	}
+
+	// Request more blocks as the window advances.
+	if sm.headersFirstMode {
+		sm.fetchHeaderBlocks()
+	}
+}
+
+// isDuplicateBlockErr returns whether the passed error is a rule error which
+// indicates a block is already known.
+func isDuplicateBlockErr(err error) bool {
+	rErr, ok := err.(blockchain.RuleError)
+	return ok && rErr.ErrorCode == blockchain.ErrDuplicateBlock
+}
+
+// processPeerBlock processes the passed block received from the passed peer
+// with the passed behavior flags, updates the peer heights accordingly, and
+// advances the headers-first mode state when the block is the next checkpoint.
+// The error returned by the chain, if any, is returned after being logged and
+// reported to the peer.
+func (sm *SyncManager) processPeerBlock(block *btcutil.Block, peer *peerpkg.Peer, behaviorFlags blockchain.BehaviorFlags) error {
+	// Only blocks which are part of the header chain being downloaded are
+	// fast added, so the checkpoint block is always one of them.
+	blockHash := block.Hash()
+	isCheckpointBlock := sm.headersFirstMode &&
+		behaviorFlags&blockchain.BFFastAdd == blockchain.BFFastAdd &&
+		blockHash.IsEqual(sm.nextCheckpoint.Hash)
+
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
-	_, isOrphan, err := sm.chain.ProcessBlock(bmsg.block, behaviorFlags)
+	if sm.recorder != nil {
+		sm.recorder.RecordBlock(peer.Addr(), block, behaviorFlags)
+	}
+	_, isOrphan, err := sm.chain.ProcessBlock(block, behaviorFlags)
 	if err != nil {
 		// When the error is a rule error, it means the block was simply
 		// rejected as opposed to something actually going wrong, so log
@@ -588,7 +650,7 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		// send it.
 		code, reason := mempool.ErrToRejectErr(err)
 		peer.PushRejectMsg(wire.CmdBlock, code, reason, blockHash, false)
-		return
+		return err
 	}
 
 	// Meta-data about the new block this peer is reporting. We use this
@@ -610,9 +672,9 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		// block height from the scriptSig of the coinbase transaction.
 		// Extraction is only attempted if the block's version is
 		// high enough (ver 2+).
-		header := &bmsg.block.MsgBlock().Header
+		header := &block.MsgBlock().Header
 		if blockchain.ShouldHaveSerializedBlockHeight(header) {
-			coinbaseTx := bmsg.block.Transactions()[0]
+			coinbaseTx := block.Transactions()[0]
 			cbHeight, err := blockchain.ExtractCoinbaseHeight(coinbaseTx)
 			if err != nil {
 				log.Warnf("Unable to extract height from "+
@@ -636,7 +698,7 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 	} else {
 		// When the block is not an orphan, log information about it and
 		// update the chain state.
-		sm.progressLogger.LogBlockHeight(bmsg.block)
+		sm.progressLogger.LogBlockHeight(block)
 
 		// Update this peer's latest block height, for future
 		// potential sync node candidacy.
@@ -660,41 +722,31 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
-	// Nothing more to do if we aren't in headers-first mode.
-	if !sm.headersFirstMode {
-		return
-	}
-
-	// This is headers-first mode, so if the block is not a checkpoint
-	// request more blocks using the header list when the request queue is
-	// getting short.
+	// Nothing more to do unless the block is the next checkpoint of the
+	// headers-first mode.
 	if !isCheckpointBlock {
-		if sm.startHeader != nil &&
-			len(state.requestedBlocks) < minInFlightBlocks {
-			sm.fetchHeaderBlocks()
-		}
-		return
+		return nil
 	}
 
 	// This is headers-first mode and the block is a checkpoint.  When
 	// there is a next checkpoint, get the next round of headers by asking
-	// for headers starting from the block after this one up to the next
-	// checkpoint.
+	// the sync peer for headers starting from the block after this one up
+	// to the next checkpoint.
 	prevHeight := sm.nextCheckpoint.Height
 	prevHash := sm.nextCheckpoint.Hash
 	sm.nextCheckpoint = sm.findNextHeaderCheckpoint(prevHeight)
 	if sm.nextCheckpoint != nil {
 		locator := blockchain.BlockLocator([]*chainhash.Hash{prevHash})
-		err := peer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
+		err := sm.syncPeer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
 		if err != nil {
 			log.Warnf("Failed to send getheaders message to "+
-				"peer %s: %v", peer.Addr(), err)
-			return
+				"peer %s: %v", sm.syncPeer.Addr(), err)
+			return nil
 		}
 		log.Infof("Downloading headers for blocks %d to %d from "+
 			"peer %s", prevHeight+1, sm.nextCheckpoint.Height,
 			sm.syncPeer.Addr())
-		return
+		return nil
 	}
 
 	// This is headers-first mode, the block is a checkpoint, and there are
@@ -702,67 +754,76 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 	// from the block after this one up to the end of the chain (zero hash).
 	sm.headersFirstMode = false
 	sm.headerList.Init()
+	sm.scheduler.Reset()
 	log.Infof("Reached the final checkpoint -- switching to normal mode")
 	locator := blockchain.BlockLocator([]*chainhash.Hash{blockHash})
-	err = peer.PushGetBlocksMsg(locator, &zeroHash)
+	err = sm.syncPeer.PushGetBlocksMsg(locator, &zeroHash)
 	if err != nil {
 		log.Warnf("Failed to send getblocks message to peer %s: %v",
-			peer.Addr(), err)
-		return
+			sm.syncPeer.Addr(), err)
 	}
+	return nil
 }
 
-// fetchHeaderBlocks creates and sends a request to the syncPeer for the next
-// list of blocks to be downloaded based on the current list of headers.
+// fetchHeaderBlocks requests the blocks described by the header chain being
+// downloaded in headers-first mode which are within the download window and
+// not already in flight, spreading them across the peers blocks are downloaded
+// from.
 func (sm *SyncManager) fetchHeaderBlocks() {
-	// Nothing to do if there is no start header.
-	if sm.startHeader == nil {
-		log.Warnf("fetchHeaderBlocks called with no start header")
-		return
-	}
-
-	// Build up a getdata request for the list of blocks the headers
-	// describe.  The size hint will be limited to wire.MaxInvPerMsg by
-	// the function, so no need to double check it here.
-	gdmsg := wire.NewMsgGetDataSizeHint(uint(sm.headerList.Len()))
-	numRequested := 0
-	for e := sm.startHeader; e != nil; e = e.Next() {
-		node, ok := e.Value.(*headerNode)
-		if !ok {
-			log.Warn("Header list node type is not a headerNode")
+	for p, nodes := range sm.scheduler.Schedule(time.Now()) {
+		peer := p.(*peerpkg.Peer)
+		state, exists := sm.peerStates[peer]
+		if !exists {
+			log.Warnf("Block download scheduled for unknown peer %s",
+				peer)
+			sm.scheduler.RemovePeer(peer)
 			continue
 		}
 
-		iv := wire.NewInvVect(wire.InvTypeBlock, node.hash)
-		haveInv, err := sm.haveInventory(iv)
-		if err != nil {
-			log.Warnf("Unexpected failure when checking for "+
-				"existing inventory during header block "+
-				"fetch: %v", err)
-		}
-		if !haveInv {
-			syncPeerState := sm.peerStates[sm.syncPeer]
-
+		gdmsg := wire.NewMsgGetDataSizeHint(uint(len(nodes)))
+		for _, node := range nodes {
 			sm.requestedBlocks[*node.hash] = struct{}{}
-			syncPeerState.requestedBlocks[*node.hash] = struct{}{}
+			state.requestedBlocks[*node.hash] = struct{}{}
 
 			// If we're fetching from a witness enabled peer
 			// post-fork, then ensure that we receive all the
 			// witness data in the blocks.
-			if sm.syncPeer.IsWitnessEnabled() {
+			iv := wire.NewInvVect(wire.InvTypeBlock, node.hash)
+			if peer.IsWitnessEnabled() {
 				iv.Type = wire.InvTypeWitnessBlock
 			}
-
 			gdmsg.AddInvVect(iv)
-			numRequested++
 		}
-		sm.startHeader = e.Next()
-		if numRequested >= wire.MaxInvPerMsg {
-			break
+		peer.QueueMessage(gdmsg, nil)
+	}
+}
+
+// handleStallSample disconnects the peers which are stalling the block download
+// in headers-first mode and requests the blocks they had in flight from the
+// other peers.
+func (sm *SyncManager) handleStallSample() {
+	if !sm.headersFirstMode {
+		return
+	}
+
+	stalled := sm.scheduler.Stalled(time.Now())
+	for _, p := range stalled {
+		peer := p.(*peerpkg.Peer)
+		hashes := sm.scheduler.RemovePeer(peer)
+		log.Infof("Peer %s has not delivered any of %d requested "+
+			"blocks within %v -- disconnecting", peer.Addr(),
+			len(hashes), blockStallTimeout)
+		state, exists := sm.peerStates[peer]
+		for i := range hashes {
+			if exists {
+				delete(state.requestedBlocks, hashes[i])
+			}
+			delete(sm.requestedBlocks, hashes[i])
 		}
+		peer.Disconnect()
 	}
-	if len(gdmsg.InvList) > 0 {
-		sm.syncPeer.QueueMessage(gdmsg, nil)
+	if len(stalled) > 0 {
+		sm.fetchHeaderBlocks()
 	}
 }
 
@@ -814,10 +875,7 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		prevNode := prevNodeEl.Value.(*headerNode)
 		if prevNode.hash.IsEqual(&blockHeader.PrevBlock) {
 			node.height = prevNode.height + 1
-			e := sm.headerList.PushBack(&node)
-			if sm.startHeader == nil {
-				sm.startHeader = e
-			}
+			sm.headerList.PushBack(&node)
 		} else {
 			log.Warnf("Received block header that does not "+
 				"properly connect to the chain from peer %s "+
@@ -853,10 +911,19 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		// Since the first entry of the list is always the final block
 		// that is already in the database and is only used to ensure
 		// the next header links properly, it must be removed before
-		// fetching the blocks.
+		// handing the headers to the block scheduler.  Only the
+		// checkpoint is kept in the list since it is needed to verify
+		// the next round of headers links properly.
 		sm.headerList.Remove(sm.headerList.Front())
+		nodes := make([]*headerNode, 0, sm.headerList.Len())
+		for e := sm.headerList.Front(); e != nil; e = e.Next() {
+			nodes = append(nodes, e.Value.(*headerNode))
+		}
+		sm.headerList.Init()
+		sm.headerList.PushBack(nodes[len(nodes)-1])
+		sm.scheduler.AddHeaders(nodes)
 		log.Infof("Received %v block headers: Fetching blocks",
-			sm.headerList.Len())
+			len(nodes))
 		sm.progressLogger.SetLastLogTime(time.Now())
 		sm.fetchHeaderBlocks()
 		return
@@ -960,6 +1027,7 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 	// request parent blocks of orphans if we receive one we already have.
 	// Finally, attempt to detect potential stalls due to long side chains
 	// we already have and request more blocks to prevent them.
+	now := time.Now()
 	for i, iv := range invVects {
 		// Ignore unsupported inventory types.
 		switch iv.Type {
@@ -989,12 +1057,20 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 			continue
 		}
 		if !haveInv {
-			if iv.Type == wire.InvTypeTx {
+			if iv.Type == wire.InvTypeTx ||
+				iv.Type == wire.InvTypeWitnessTx {
+
 				// Skip the transaction if it has already been
 				// rejected.
 				if _, exists := sm.rejectedTxns[iv.Hash]; exists {
 					continue
 				}
+
+				// Transactions are requested by the transaction
+				// request tracker so announcements from several
+				// peers are deduplicated.
+				sm.txRequests.Announce(peer, &iv.Hash, now)
+				continue
 			}
 
 			// Ignore invs block invs from non-witness enabled
@@ -1076,26 +1152,6 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 					iv.Type = wire.InvTypeWitnessBlock
 				}
 
-				gdmsg.AddInvVect(iv)
-				numRequested++
-			}
-
-		case wire.InvTypeWitnessTx:
-			fallthrough
-		case wire.InvTypeTx:
-			// Request the transaction if there is not already a
-			// pending request.
-			if _, exists := sm.requestedTxns[iv.Hash]; !exists {
-				sm.requestedTxns[iv.Hash] = struct{}{}
-				sm.limitMap(sm.requestedTxns, maxRequestedTxns)
-				state.requestedTxns[iv.Hash] = struct{}{}
-
-				// If the peer is capable, request the txn
-				// including all witness data.
-				if peer.IsWitnessEnabled() {
-					iv.Type = wire.InvTypeWitnessTx
-				}
-
 				gdmsg.AddInvVect(iv)
 				numRequested++
 			}
@@ -1109,6 +1165,60 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 	if len(gdmsg.InvList) > 0 {
 		peer.QueueMessage(gdmsg, nil)
 	}
+
+	sm.requestTxns()
+}
+
+// handleNotFoundMsg handles notfound messages from all peers.  The
+// transactions the peer does not have are requested from the next peers which
+// announced them.
+func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
+	peer := nfmsg.peer
+	if _, exists := sm.peerStates[peer]; !exists {
+		log.Warnf("Received notfound message from unknown peer %s", peer)
+		return
+	}
+
+	for _, iv := range nfmsg.notFound.InvList {
+		switch iv.Type {
+		case wire.InvTypeTx, wire.InvTypeWitnessTx:
+			sm.txRequests.NotFound(peer, &iv.Hash)
+		}
+	}
+	sm.requestTxns()
+}
+
+// requestTxns requests the transactions which are ready to be requested from
+// the peers chosen by the transaction request tracker.  Transactions which were
+// obtained or rejected since they were announced are skipped.
+func (sm *SyncManager) requestTxns() {
+	for p, hashes := range sm.txRequests.Schedule(time.Now()) {
+		peer := p.(*peerpkg.Peer)
+		gdmsg := wire.NewMsgGetData()
+		for i := range hashes {
+			hash := &hashes[i]
+			iv := wire.NewInvVect(wire.InvTypeTx, hash)
+			if _, exists := sm.rejectedTxns[*hash]; exists {
+				sm.txRequests.Received(hash)
+				continue
+			}
+			haveInv, err := sm.haveInventory(iv)
+			if err == nil && haveInv {
+				sm.txRequests.Received(hash)
+				continue
+			}
+
+			// If the peer is capable, request the txn including
+			// all witness data.
+			if peer.IsWitnessEnabled() {
+				iv.Type = wire.InvTypeWitnessTx
+			}
+			gdmsg.AddInvVect(iv)
+		}
+		if len(gdmsg.InvList) > 0 {
+			peer.QueueMessage(gdmsg, nil)
+		}
+	}
 }
 
 // limitMap is a helper function for maps that require a maximum limit by
@@ -1136,6 +1246,11 @@ func (sm *SyncManager) limitMap(m map[chainhash.Hash]struct{}, limit int) {
 // important because the sync manager controls which blocks are needed and how
 // the fetching should proceed.
 func (sm *SyncManager) blockHandler() {
+	stallTicker := time.NewTicker(stallSampleInterval)
+	defer stallTicker.Stop()
+	txRequestTicker := time.NewTicker(txRequestInterval)
+	defer txRequestTicker.Stop()
+
 out:
 	for {
 		select {
@@ -1155,6 +1270,9 @@ out:
 			case *invMsg:
 				sm.handleInvMsg(msg)
 
+			case *notFoundMsg:
+				sm.handleNotFoundMsg(msg)
+
 			case *headersMsg:
 				sm.handleHeadersMsg(msg)
 
@@ -1176,6 +1294,7 @@ out:
 						isOrphan: false,
 						err:      err,
 					}
+					continue
 				}
 
 				msg.reply <- processBlockResponse{
@@ -1195,6 +1314,12 @@ out:
 					"handler: %T", msg)
 			}
 
+		case <-stallTicker.C:
+			sm.handleStallSample()
+
+		case <-txRequestTicker.C:
+			sm.requestTxns()
+
 		case <-sm.quit:
 			break out
 		}
@@ -1244,7 +1369,8 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 		// transaction are NOT removed recursively because they are still
 		// valid.
 		for _, tx := range block.Transactions()[1:] {
-			sm.txMemPool.RemoveTransaction(tx, false)
+			sm.txMemPool.RemoveTransaction(tx, false,
+				mempool.RemovalReasonBlock)
 			sm.txMemPool.RemoveDoubleSpends(tx)
 			sm.txMemPool.RemoveOrphan(tx)
 			sm.peerNotifier.TransactionConfirmed(tx)
@@ -1283,7 +1409,8 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 				// Remove the transaction and all transactions
 				// that depend on it if it wasn't accepted into
 				// the transaction pool.
-				sm.txMemPool.RemoveTransaction(tx, true)
+				sm.txMemPool.RemoveTransaction(tx, true,
+					mempool.RemovalReasonReorg)
 			}
 		}
 
@@ -1340,6 +1467,18 @@ func (sm *SyncManager) QueueInv(inv *wire.MsgInv, peer *peerpkg.Peer) {
 	sm.msgChan <- &invMsg{inv: inv, peer: peer}
 }
 
+// QueueNotFound adds the passed notfound message and peer to the block handling
+// queue.
+func (sm *SyncManager) QueueNotFound(notFound *wire.MsgNotFound, peer *peerpkg.Peer) {
+	// No channel handling here because peers do not need to block on
+	// notfound messages.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		return
+	}
+
+	sm.msgChan <- &notFoundMsg{notFound: notFound, peer: peer}
+}
+
 // QueueHeaders adds the passed headers message and peer to the block handling
 // queue.
 func (sm *SyncManager) QueueHeaders(headers *wire.MsgHeaders, peer *peerpkg.Peer) {
@@ -1432,7 +1571,6 @@ func New(config *Config) (*SyncManager, error) {
 		txMemPool:       config.TxMemPool,
 		chainParams:     config.ChainParams,
 		rejectedTxns:    make(map[chainhash.Hash]struct{}),
-		requestedTxns:   make(map[chainhash.Hash]struct{}),
 		requestedBlocks: make(map[chainhash.Hash]struct{}),
 		peerStates:      make(map[*peerpkg.Peer]*peerSyncState),
 		progressLogger:  newBlockProgressLogger("Processed", log),
@@ -1440,6 +1578,11 @@ func New(config *Config) (*SyncManager, error) {
 		headerList:      list.New(),
 		quit:            make(chan struct{}),
 		feeEstimator:    config.FeeEstimator,
+		recorder:        config.Recorder,
+		scheduler: newBlockScheduler(blockDownloadWindow,
+			maxBlocksInFlightPerPeer, blockStallTimeout),
+		txRequests: newTxRequestTracker(maxTxsInFlightPerPeer,
+			inboundTxRequestDelay, txRequestTimeout),
 	}
 
 	best := sm.chain.BestSnapshot()
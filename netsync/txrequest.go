@@ -0,0 +1,440 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+const (
+	// maxTxsInFlightPerPeer is the maximum number of transactions requested
+	// from a single peer at a time.
+	maxTxsInFlightPerPeer = 100
+
+	// maxTxAnnouncementsPerPeer is the maximum number of transaction
+	// announcements tracked for a single peer.  Further announcements from
+	// the peer are ignored until some of them are resolved.
+	maxTxAnnouncementsPerPeer = 5000
+
+	// txAnnounceBurst is the number of transaction announcements a peer
+	// may make in a burst before they are rate limited.
+	txAnnounceBurst = 5000
+
+	// txAnnounceRate is the sustained number of transaction announcements
+	// per second accepted from a peer once its burst has been used.
+	txAnnounceRate = 100
+
+	// inboundTxRequestDelay is the duration a transaction announced by an
+	// inbound peer waits before it is requested from that peer.  This
+	// gives outbound peers, which are chosen by us and therefore harder
+	// for an attacker to control, the chance to announce it as well.
+	inboundTxRequestDelay = 2 * time.Second
+
+	// txRequestTimeout is the duration a peer has to deliver a requested
+	// transaction before it is requested from the next peer which
+	// announced it.
+	txRequestTimeout = time.Minute
+
+	// txRequestInterval is the interval at which transactions which are
+	// ready to be requested are scheduled.
+	txRequestInterval = time.Second
+)
+
+// txAnnouncement houses a single announcement of a transaction by a peer.
+type txAnnouncement struct {
+	peer downloadPeer
+
+	// requestTime is the earliest time the transaction may be requested
+	// from the peer.
+	requestTime time.Time
+}
+
+// trackedTx houses the request state of an announced transaction.
+type trackedTx struct {
+	announcements []txAnnouncement
+	requestedFrom downloadPeer
+	expiry        time.Time
+}
+
+// txRequestPeer houses the request state of a peer.  The transactions which
+// were due to be requested from the peer while it had no room for more requests
+// wait in the order they became due until it has room again.
+type txRequestPeer struct {
+	outbound   bool
+	inFlight   int
+	announced  int
+	tokens     float64
+	lastRefill time.Time
+	waiting    []chainhash.Hash
+	isWaiting  map[chainhash.Hash]struct{}
+}
+
+// wait adds the transaction with the passed hash to the transactions waiting
+// for the peer to have room for more requests unless it is already waiting.
+func (p *txRequestPeer) wait(hash chainhash.Hash) {
+	if _, ok := p.isWaiting[hash]; ok {
+		return
+	}
+	if p.isWaiting == nil {
+		p.isWaiting = make(map[chainhash.Hash]struct{})
+	}
+	p.waiting = append(p.waiting, hash)
+	p.isWaiting[hash] = struct{}{}
+}
+
+// txCheck houses the time a tracked transaction must next be checked by
+// Schedule, which is either when one of its announcements becomes due or when
+// the request in flight times out.
+type txCheck struct {
+	hash chainhash.Hash
+	time time.Time
+}
+
+// txCheckQueue implements a priority queue of the checks of tracked
+// transactions ordered by their time.  It may hold checks of transactions which
+// are no longer tracked or which were already handled, so the checks are
+// validated when they are removed.
+type txCheckQueue []txCheck
+
+// Len returns the number of checks in the queue.  It is part of the
+// heap.Interface implementation.
+func (q txCheckQueue) Len() int {
+	return len(q)
+}
+
+// Less returns whether the check at index i is due before the check at index
+// j.  It is part of the heap.Interface implementation.
+func (q txCheckQueue) Less(i, j int) bool {
+	return q[i].time.Before(q[j].time)
+}
+
+// Swap swaps the checks at the passed indices.  It is part of the
+// heap.Interface implementation.
+func (q txCheckQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+// Push pushes the passed check onto the queue.  It is part of the
+// heap.Interface implementation.
+func (q *txCheckQueue) Push(x interface{}) {
+	*q = append(*q, x.(txCheck))
+}
+
+// Pop removes the last check of the queue and returns it.  It is part of the
+// heap.Interface implementation.
+func (q *txCheckQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	check := old[n-1]
+	*q = old[:n-1]
+	return check
+}
+
+// refill adds the tokens accrued by the peer since the last refill, up to the
+// announcement burst.
+func (p *txRequestPeer) refill(now time.Time) {
+	elapsed := now.Sub(p.lastRefill).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	p.tokens += elapsed * txAnnounceRate
+	if p.tokens > txAnnounceBurst {
+		p.tokens = txAnnounceBurst
+	}
+	p.lastRefill = now
+}
+
+// txRequestTracker decides which peer each announced transaction is requested
+// from.  A transaction announced by several peers is only requested from one
+// of them at a time.  Outbound peers are preferred, and announcements from
+// inbound peers are only acted on after a short delay.  Each peer is limited
+// in the number of transactions it is requested at a time, and the
+// announcements accepted from a peer are rate limited with a token bucket to
+// mitigate inventory spam.
+//
+// Requests which are not answered within a timeout, or which are answered with
+// a notfound message, are made again to the next peer which announced the
+// transaction.  Transactions are forgotten once they are received or no peer
+// which announced them remains.
+//
+// Scheduling only visits the transactions which are due, which are kept in a
+// queue ordered by the time they must be checked, along with the transactions
+// waiting for peers which have room for more requests again.
+//
+// The transaction request tracker is not safe for concurrent access.
+type txRequestTracker struct {
+	maxPerPeer   int
+	inboundDelay time.Duration
+	timeout      time.Duration
+
+	txns   map[chainhash.Hash]*trackedTx
+	peers  map[downloadPeer]*txRequestPeer
+	checks txCheckQueue
+}
+
+// newTxRequestTracker returns a new transaction request tracker which requests
+// at most the passed number of transactions from each peer at a time, delays
+// requests to inbound peers by the passed duration, and requests transactions
+// again from other peers once they have not been delivered for the passed
+// timeout.
+func newTxRequestTracker(maxPerPeer int, inboundDelay, timeout time.Duration) *txRequestTracker {
+	return &txRequestTracker{
+		maxPerPeer:   maxPerPeer,
+		inboundDelay: inboundDelay,
+		timeout:      timeout,
+		txns:         make(map[chainhash.Hash]*trackedTx),
+		peers:        make(map[downloadPeer]*txRequestPeer),
+	}
+}
+
+// Len returns the number of transactions which are tracked.
+func (t *txRequestTracker) Len() int {
+	return len(t.txns)
+}
+
+// AddPeer adds the passed peer to the peers transactions are requested from.
+func (t *txRequestTracker) AddPeer(p downloadPeer, outbound bool, now time.Time) {
+	if _, ok := t.peers[p]; ok {
+		return
+	}
+	t.peers[p] = &txRequestPeer{
+		outbound:   outbound,
+		tokens:     txAnnounceBurst,
+		lastRefill: now,
+	}
+}
+
+// RemovePeer removes the passed peer along with its announcements.  The
+// transactions it had in flight are requested from the next peers which
+// announced them by the following calls to Schedule.
+func (t *txRequestTracker) RemovePeer(p downloadPeer) {
+	if _, ok := t.peers[p]; !ok {
+		return
+	}
+	delete(t.peers, p)
+	for hash, tx := range t.txns {
+		if tx.requestedFrom == p {
+			tx.requestedFrom = nil
+			t.checkNow(hash)
+		}
+		t.removeAnnouncement(hash, tx, p)
+	}
+}
+
+// checkAt schedules a check of the transaction with the passed hash at the
+// passed time.
+func (t *txRequestTracker) checkAt(hash chainhash.Hash, when time.Time) {
+	heap.Push(&t.checks, txCheck{hash: hash, time: when})
+}
+
+// checkNow schedules a check of the transaction with the passed hash by the
+// next call to Schedule.
+func (t *txRequestTracker) checkNow(hash chainhash.Hash) {
+	t.checkAt(hash, time.Time{})
+}
+
+// removeAnnouncement removes the announcement of the passed transaction by the
+// passed peer and forgets the transaction when no announcement remains.
+func (t *txRequestTracker) removeAnnouncement(hash chainhash.Hash, tx *trackedTx, p downloadPeer) {
+	for i, ann := range tx.announcements {
+		if ann.peer != p {
+			continue
+		}
+		tx.announcements = append(tx.announcements[:i],
+			tx.announcements[i+1:]...)
+		if state, ok := t.peers[p]; ok {
+			state.announced--
+		}
+		break
+	}
+	if len(tx.announcements) == 0 {
+		delete(t.txns, hash)
+	}
+}
+
+// Announce records the announcement of the transaction with the passed hash by
+// the passed peer and returns whether it was accepted.  Announcements from
+// unknown peers, duplicate announcements, and announcements from peers which
+// exceeded their limits are ignored.
+func (t *txRequestTracker) Announce(p downloadPeer, hash *chainhash.Hash, now time.Time) bool {
+	state, ok := t.peers[p]
+	if !ok || state.announced >= maxTxAnnouncementsPerPeer {
+		return false
+	}
+	tx, ok := t.txns[*hash]
+	if ok {
+		for _, ann := range tx.announcements {
+			if ann.peer == p {
+				return false
+			}
+		}
+	}
+	state.refill(now)
+	if state.tokens < 1 {
+		return false
+	}
+	state.tokens--
+
+	if tx == nil {
+		tx = &trackedTx{}
+		t.txns[*hash] = tx
+	}
+	requestTime := now
+	if !state.outbound {
+		requestTime = now.Add(t.inboundDelay)
+	}
+	tx.announcements = append(tx.announcements, txAnnouncement{
+		peer:        p,
+		requestTime: requestTime,
+	})
+	state.announced++
+	if tx.requestedFrom == nil {
+		t.checkAt(*hash, requestTime)
+	}
+	return true
+}
+
+// IsRequested returns whether the transaction with the passed hash is in
+// flight from any peer.
+func (t *txRequestTracker) IsRequested(hash *chainhash.Hash) bool {
+	tx, ok := t.txns[*hash]
+	return ok && tx.requestedFrom != nil
+}
+
+// Received records the delivery of the transaction with the passed hash and
+// forgets it.  The transaction may be delivered by any peer.
+func (t *txRequestTracker) Received(hash *chainhash.Hash) {
+	tx, ok := t.txns[*hash]
+	if !ok {
+		return
+	}
+	if state, ok := t.peers[tx.requestedFrom]; ok {
+		state.inFlight--
+	}
+	for _, ann := range tx.announcements {
+		if state, ok := t.peers[ann.peer]; ok {
+			state.announced--
+		}
+	}
+	delete(t.txns, *hash)
+}
+
+// NotFound records that the passed peer does not have the transaction with the
+// passed hash.  When the transaction was in flight from the peer, it is
+// requested from the next peer which announced it by the following call to
+// Schedule.
+func (t *txRequestTracker) NotFound(p downloadPeer, hash *chainhash.Hash) {
+	tx, ok := t.txns[*hash]
+	if !ok || tx.requestedFrom != p {
+		return
+	}
+	tx.requestedFrom = nil
+	if state, ok := t.peers[p]; ok {
+		state.inFlight--
+	}
+	t.removeAnnouncement(*hash, tx, p)
+	t.checkNow(*hash)
+}
+
+// Schedule expires the requests which timed out and assigns the transactions
+// which are not in flight to one of the peers which announced them, and
+// returns the transactions each peer must be requested.  Outbound peers are
+// preferred over inbound peers, and among them the earliest announcer with
+// room for more requests is chosen.  Transactions which are due while all of
+// their due announcers are out of room are requested from the first of those
+// peers to have room again.
+func (t *txRequestTracker) Schedule(now time.Time) map[downloadPeer][]chainhash.Hash {
+	requests := make(map[downloadPeer][]chainhash.Hash)
+	for len(t.checks) > 0 && !now.Before(t.checks[0].time) {
+		check := heap.Pop(&t.checks).(txCheck)
+		hash := check.hash
+		tx, ok := t.txns[hash]
+		if !ok {
+			continue
+		}
+		if tx.requestedFrom != nil {
+			if now.Before(tx.expiry) {
+				continue
+			}
+
+			// The request timed out, so stop waiting for the peer
+			// and try the next announcer.
+			p := tx.requestedFrom
+			tx.requestedFrom = nil
+			if state, ok := t.peers[p]; ok {
+				state.inFlight--
+			}
+			t.removeAnnouncement(hash, tx, p)
+			if len(tx.announcements) == 0 {
+				continue
+			}
+		}
+
+		var best downloadPeer
+		var bestState *txRequestPeer
+		var nextCheck time.Time
+		for _, ann := range tx.announcements {
+			state := t.peers[ann.peer]
+			if now.Before(ann.requestTime) {
+				if nextCheck.IsZero() || ann.requestTime.Before(nextCheck) {
+					nextCheck = ann.requestTime
+				}
+				continue
+			}
+			if state.inFlight >= t.maxPerPeer {
+				state.wait(hash)
+				continue
+			}
+			if bestState == nil || (state.outbound && !bestState.outbound) {
+				best, bestState = ann.peer, state
+			}
+		}
+		if best == nil {
+			if !nextCheck.IsZero() {
+				t.checkAt(hash, nextCheck)
+			}
+			continue
+		}
+		t.request(hash, tx, best, bestState, now, requests)
+	}
+
+	// Assign the transactions waiting for the peers which have room for
+	// more requests again.
+	for p, state := range t.peers {
+		for len(state.waiting) > 0 && state.inFlight < t.maxPerPeer {
+			hash := state.waiting[0]
+			state.waiting = state.waiting[1:]
+			delete(state.isWaiting, hash)
+
+			tx, ok := t.txns[hash]
+			if !ok || tx.requestedFrom != nil {
+				continue
+			}
+			for _, ann := range tx.announcements {
+				if ann.peer == p {
+					t.request(hash, tx, p, state, now, requests)
+					break
+				}
+			}
+		}
+		if len(state.waiting) == 0 {
+			state.waiting = nil
+		}
+	}
+	return requests
+}
+
+// request assigns the transaction with the passed hash to the passed peer,
+// schedules the check for its timeout, and adds it to the passed requests.
+func (t *txRequestTracker) request(hash chainhash.Hash, tx *trackedTx, p downloadPeer, state *txRequestPeer, now time.Time, requests map[downloadPeer][]chainhash.Hash) {
+	state.inFlight++
+	tx.requestedFrom = p
+	tx.expiry = now.Add(t.timeout)
+	t.checkAt(hash, tx.expiry)
+	requests[p] = append(requests[p], hash)
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// TestTxRequestTracker ensures transactions announced by several peers are
+// only requested once, preferably from outbound peers, and requested again
+// from the next announcer when a request times out or is answered with a
+// notfound message.
+func TestTxRequestTracker(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1500000000, 0)
+	tr := newTxRequestTracker(2, 2*time.Second, time.Minute)
+	inbound := &fakePeer{}
+	outbound := &fakePeer{}
+	other := &fakePeer{}
+	tr.AddPeer(inbound, false, now)
+	tr.AddPeer(outbound, true, now)
+	tr.AddPeer(other, true, now)
+
+	hashes := make([]chainhash.Hash, 4)
+	for i := range hashes {
+		hashes[i][0] = byte(i + 1)
+	}
+
+	// Transactions announced by an inbound peer are delayed.
+	if !tr.Announce(inbound, &hashes[0], now) {
+		t.Fatal("announcement was not accepted")
+	}
+	if tr.Announce(inbound, &hashes[0], now) {
+		t.Fatal("duplicate announcement was accepted")
+	}
+	if reqs := tr.Schedule(now); len(reqs) != 0 {
+		t.Fatalf("unexpected requests before inbound delay: %v", reqs)
+	}
+
+	// An outbound announcer is preferred once it announces the transaction
+	// as well.
+	tr.Announce(outbound, &hashes[0], now.Add(time.Second))
+	now = now.Add(3 * time.Second)
+	reqs := tr.Schedule(now)
+	if len(reqs) != 1 || len(reqs[outbound]) != 1 ||
+		reqs[outbound][0] != hashes[0] {
+
+		t.Fatalf("unexpected requests: %v", reqs)
+	}
+	if !tr.IsRequested(&hashes[0]) {
+		t.Fatal("transaction is not in flight")
+	}
+	if reqs := tr.Schedule(now); len(reqs) != 0 {
+		t.Fatalf("transaction requested twice: %v", reqs)
+	}
+
+	// A notfound response causes the transaction to be requested from the
+	// next announcer.
+	tr.NotFound(outbound, &hashes[0])
+	reqs = tr.Schedule(now)
+	if len(reqs[inbound]) != 1 || reqs[inbound][0] != hashes[0] {
+		t.Fatalf("unexpected requests after notfound: %v", reqs)
+	}
+
+	// Once the request times out and no announcer is left, the transaction
+	// is forgotten.
+	now = now.Add(2 * time.Minute)
+	if reqs := tr.Schedule(now); len(reqs) != 0 {
+		t.Fatalf("unexpected requests after timeout: %v", reqs)
+	}
+	if tr.Len() != 0 {
+		t.Fatalf("unexpected number of tracked transactions %d", tr.Len())
+	}
+
+	// Each peer is limited in the number of transactions in flight.
+	for i := 1; i < len(hashes); i++ {
+		tr.Announce(other, &hashes[i], now)
+	}
+	reqs = tr.Schedule(now)
+	if len(reqs[other]) != 2 {
+		t.Fatalf("unexpected requests: %v", reqs)
+	}
+	received := reqs[other][0]
+	tr.Received(&received)
+	reqs = tr.Schedule(now)
+	if len(reqs[other]) != 1 {
+		t.Fatalf("unexpected requests after delivery: %v", reqs)
+	}
+
+	// Removing the peer forgets the transactions only it announced.
+	tr.RemovePeer(other)
+	if tr.Len() != 0 {
+		t.Fatalf("unexpected number of tracked transactions %d", tr.Len())
+	}
+	if tr.Announce(other, &hashes[0], now) {
+		t.Fatal("announcement from removed peer was accepted")
+	}
+}
+
+// TestTxRequestRateLimit ensures the announcements accepted from a peer are
+// limited by its token bucket.
+func TestTxRequestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1500000000, 0)
+	tr := newTxRequestTracker(maxTxsInFlightPerPeer, 0, time.Minute)
+	p := &fakePeer{}
+	tr.AddPeer(p, true, now)
+
+	var hash chainhash.Hash
+	for i := 0; i < txAnnounceBurst; i++ {
+		hash[0], hash[1] = byte(i), byte(i>>8)
+		if !tr.Announce(p, &hash, now) {
+			t.Fatalf("announcement %d was not accepted", i)
+		}
+	}
+
+	// The burst is used up, so further announcements are ignored until
+	// tokens accrue again.  The peer is also at its announcement limit, so
+	// resolve one of its announcements first.
+	tr.Received(&hash)
+	hash[2] = 1
+	if tr.Announce(p, &hash, now) {
+		t.Fatal("announcement over the burst was accepted")
+	}
+	if !tr.Announce(p, &hash, now.Add(time.Second)) {
+		t.Fatal("announcement after refill was not accepted")
+	}
+}
+
+// TestTxRequestScheduleDue ensures scheduling only visits the transactions
+// which are due and requests the transactions which waited for a peer with no
+// room once the peer has room again.
+func TestTxRequestScheduleDue(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1500000000, 0)
+	tr := newTxRequestTracker(1, 2*time.Second, time.Minute)
+	inbound := &fakePeer{}
+	tr.AddPeer(inbound, false, now)
+
+	hashes := make([]chainhash.Hash, 3)
+	for i := range hashes {
+		hashes[i][0] = byte(i + 1)
+		tr.Announce(inbound, &hashes[i], now)
+	}
+
+	// None of the announcements is due, so none of the checks is removed.
+	if reqs := tr.Schedule(now.Add(time.Second)); len(reqs) != 0 {
+		t.Fatalf("unexpected requests before inbound delay: %v", reqs)
+	}
+	if len(tr.checks) != len(hashes) {
+		t.Fatalf("got %d scheduled checks, want %d", len(tr.checks),
+			len(hashes))
+	}
+
+	// Once due, only one transaction fits in flight and the others wait
+	// for the peer.  Only the check of the timeout of the request remains.
+	now = now.Add(2 * time.Second)
+	reqs := tr.Schedule(now)
+	if len(reqs[inbound]) != 1 {
+		t.Fatalf("unexpected requests: %v", reqs)
+	}
+	first := reqs[inbound][0]
+	if len(tr.checks) != 1 {
+		t.Fatalf("got %d scheduled checks, want 1", len(tr.checks))
+	}
+
+	// Delivering the transaction makes room for the next waiting one.
+	tr.Received(&first)
+	reqs = tr.Schedule(now)
+	if len(reqs[inbound]) != 1 || reqs[inbound][0] == first ||
+		!tr.IsRequested(&reqs[inbound][0]) {
+
+		t.Fatalf("unexpected requests after delivery: %v", reqs)
+	}
+}
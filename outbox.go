@@ -0,0 +1,352 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btclog"
+)
+
+const (
+	// outboxRecordHeaderSize is the size of the header preceding the
+	// payload of each record in an outbox file.  It consists of a flag
+	// which is set once the payload was delivered, the time the payload
+	// was queued in seconds since 1 Jan 1970 GMT, the length of the
+	// payload and its crc32 checksum.
+	outboxRecordHeaderSize = 1 + 8 + 4 + 4
+
+	// outboxRecordDelivered is the value of the flag of a record which was
+	// delivered.
+	outboxRecordDelivered = 0x01
+
+	// outboxCompactThreshold is the number of bytes of delivered records
+	// an outbox file must contain before it is rewritten without them.
+	outboxCompactThreshold = 1024 * 1024
+)
+
+// errOutboxRecordTooLarge is returned when a payload which exceeds the
+// maximum size of an outbox is queued.
+var errOutboxRecordTooLarge = errors.New("outbox record exceeds the maximum " +
+	"outbox size")
+
+// outboxEntry is a payload queued in an outbox.
+type outboxEntry struct {
+	offset  int64
+	added   time.Time
+	payload []byte
+}
+
+// size returns the number of bytes the entry takes in the outbox file.
+func (e *outboxEntry) size() int64 {
+	return int64(outboxRecordHeaderSize + len(e.payload))
+}
+
+// serialize returns the record of the entry as it is stored in the outbox file.
+func (e *outboxEntry) serialize() []byte {
+	record := make([]byte, outboxRecordHeaderSize, e.size())
+	binary.LittleEndian.PutUint64(record[1:9], uint64(e.added.Unix()))
+	binary.LittleEndian.PutUint32(record[9:13], uint32(len(e.payload)))
+	binary.LittleEndian.PutUint32(record[13:17], crc32.ChecksumIEEE(e.payload))
+	return append(record, e.payload...)
+}
+
+// diskOutbox is a first-in, first-out queue of payloads which is mirrored to a
+// file, so payloads which are not yet delivered to a notification sink survive
+// outages of the sink as well as restarts.
+//
+// The queue is bounded by the total size of the queued payloads and by their
+// age.  The oldest payloads are dropped once either limit is exceeded, so an
+// unavailable sink can't make the outbox grow without bound.
+//
+// Payloads are appended to the file as they are queued and their records are
+// flagged once they are delivered.  Delivered records are only removed from the
+// file once it is empty or they take a significant part of it, which avoids
+// rewriting the file for every delivery.  The flag is not synced to disk, so a
+// payload may be delivered again after a crash, but it is never lost.
+type diskOutbox struct {
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+	log     btclog.Logger
+
+	mtx      sync.Mutex
+	file     *os.File
+	entries  []outboxEntry
+	size     int64
+	fileSize int64
+	dropped  uint64
+}
+
+// openDiskOutbox opens the outbox stored in the file at the passed path,
+// creating it and its directory when they do not exist, and loads the payloads which were queued
+// but not yet delivered.  A partially written record at the end of the file,
+// left by a crash while appending it, is discarded.
+func openDiskOutbox(path string, maxSize int64, maxAge time.Duration) (*diskOutbox, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &diskOutbox{
+		path:    path,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+		log:     srvrLog,
+		file:    file,
+	}
+	if err := o.load(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return o, nil
+}
+
+// load reads the records of the outbox file and truncates the file after the
+// last valid record.
+func (o *diskOutbox) load() error {
+	r := bufio.NewReader(o.file)
+	var header [outboxRecordHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			break
+		}
+		added := int64(binary.LittleEndian.Uint64(header[1:9]))
+		length := binary.LittleEndian.Uint32(header[9:13])
+		checksum := binary.LittleEndian.Uint32(header[13:17])
+		if int64(length) > o.maxSize {
+			break
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(payload) != checksum {
+			break
+		}
+
+		entry := outboxEntry{
+			offset:  o.fileSize,
+			added:   time.Unix(added, 0),
+			payload: payload,
+		}
+		o.fileSize += entry.size()
+		if header[0] == outboxRecordDelivered {
+			continue
+		}
+		o.entries = append(o.entries, entry)
+		o.size += entry.size()
+	}
+
+	if err := o.file.Truncate(o.fileSize); err != nil {
+		return err
+	}
+	_, err := o.file.Seek(o.fileSize, io.SeekStart)
+	return err
+}
+
+// Push queues the passed payload and syncs it to disk.  The oldest payloads are
+// dropped when the outbox exceeds its size limit as a result.
+//
+// This function is safe for concurrent access.
+func (o *diskOutbox) Push(payload []byte, now time.Time) error {
+	entry := outboxEntry{added: now, payload: payload}
+	if entry.size() > o.maxSize {
+		return errOutboxRecordTooLarge
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	// Remove any partially written record when the payload can't be
+	// written, so the following records are not lost.
+	entry.offset = o.fileSize
+	_, err := o.file.Write(entry.serialize())
+	if err == nil {
+		err = o.file.Sync()
+	}
+	if err != nil {
+		o.file.Truncate(o.fileSize)
+		o.file.Seek(o.fileSize, io.SeekStart)
+		return err
+	}
+	o.entries = append(o.entries, entry)
+	o.size += entry.size()
+	o.fileSize += entry.size()
+
+	return o.prune(now)
+}
+
+// Front returns the oldest queued payload, after dropping the payloads which
+// exceed the age limit, and whether there is one.
+//
+// This function is safe for concurrent access.
+func (o *diskOutbox) Front(now time.Time) ([]byte, bool, error) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if err := o.prune(now); err != nil {
+		return nil, false, err
+	}
+	if len(o.entries) == 0 {
+		return nil, false, nil
+	}
+	return o.entries[0].payload, true, nil
+}
+
+// Pop removes the oldest queued payload once it was delivered.
+//
+// This function is safe for concurrent access.
+func (o *diskOutbox) Pop() error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if len(o.entries) == 0 {
+		return nil
+	}
+	if err := o.removeFront(); err != nil {
+		return err
+	}
+	return o.compact()
+}
+
+// Len returns the number of queued payloads.
+//
+// This function is safe for concurrent access.
+func (o *diskOutbox) Len() int {
+	o.mtx.Lock()
+	n := len(o.entries)
+	o.mtx.Unlock()
+	return n
+}
+
+// Dropped returns the number of payloads which were dropped since the outbox
+// was opened because they exceeded the size or age limits.
+//
+// This function is safe for concurrent access.
+func (o *diskOutbox) Dropped() uint64 {
+	o.mtx.Lock()
+	dropped := o.dropped
+	o.mtx.Unlock()
+	return dropped
+}
+
+// Close closes the outbox file.  The queued payloads remain in the file, so they
+// are loaded again when the outbox is reopened.
+//
+// This function is safe for concurrent access.
+func (o *diskOutbox) Close() error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	return o.file.Close()
+}
+
+// removeFront removes the oldest queued payload and flags its record in the
+// file as delivered.  The record is left in the file until it is compacted.
+//
+// This function MUST be called with the outbox lock held (for writes).
+func (o *diskOutbox) removeFront() error {
+	offset := o.entries[0].offset
+	o.size -= o.entries[0].size()
+	o.entries[0] = outboxEntry{}
+	o.entries = o.entries[1:]
+
+	flag := []byte{outboxRecordDelivered}
+	_, err := o.file.WriteAt(flag, offset)
+	return err
+}
+
+// prune drops the oldest payloads while the outbox exceeds its size limit or
+// they exceed the age limit.
+//
+// This function MUST be called with the outbox lock held (for writes).
+func (o *diskOutbox) prune(now time.Time) error {
+	var dropped int
+	for len(o.entries) > 0 {
+		if o.size <= o.maxSize && (o.maxAge == 0 ||
+			now.Sub(o.entries[0].added) <= o.maxAge) {
+
+			break
+		}
+		if err := o.removeFront(); err != nil {
+			return err
+		}
+		dropped++
+	}
+	if dropped == 0 {
+		return nil
+	}
+
+	o.dropped += uint64(dropped)
+	o.log.Warnf("Dropped %d undelivered notifications from outbox %s "+
+		"since they exceed its limits", dropped, o.path)
+	return o.compact()
+}
+
+// compact removes the records which are no longer queued from the outbox file
+// once it is empty or they take a significant part of it.
+//
+// This function MUST be called with the outbox lock held (for writes).
+func (o *diskOutbox) compact() error {
+	if len(o.entries) == 0 {
+		if err := o.file.Truncate(0); err != nil {
+			return err
+		}
+		o.fileSize = 0
+		_, err := o.file.Seek(0, io.SeekStart)
+		return err
+	}
+
+	removed := o.fileSize - o.size
+	if removed < outboxCompactThreshold || removed < o.size {
+		return nil
+	}
+
+	// Write the queued records to a temporary file and atomically replace
+	// the outbox file with it.
+	tmpPath := o.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for i := range o.entries {
+		w.Write(o.entries[i].serialize())
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, o.path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	o.file.Close()
+	o.file = tmp
+	o.fileSize = 0
+	for i := range o.entries {
+		o.entries[i].offset = o.fileSize
+		o.fileSize += o.entries[i].size()
+	}
+	_, err = o.file.Seek(o.fileSize, io.SeekStart)
+	return err
+}
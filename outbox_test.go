@@ -0,0 +1,225 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btclog"
+)
+
+// pushOutbox queues the passed payloads in the passed outbox.
+func pushOutbox(t *testing.T, o *diskOutbox, now time.Time, payloads ...string) {
+	for _, payload := range payloads {
+		if err := o.Push([]byte(payload), now); err != nil {
+			t.Fatalf("Push %q: unexpected error: %v", payload, err)
+		}
+	}
+}
+
+// popOutbox ensures the oldest payloads of the passed outbox are the passed
+// payloads in order and delivers them.
+func popOutbox(t *testing.T, o *diskOutbox, now time.Time, payloads ...string) {
+	for _, payload := range payloads {
+		got, ok, err := o.Front(now)
+		if err != nil {
+			t.Fatalf("Front: unexpected error: %v", err)
+		}
+		if !ok || string(got) != payload {
+			t.Fatalf("Front: got %q (%v), want %q", got, ok, payload)
+		}
+		if err := o.Pop(); err != nil {
+			t.Fatalf("Pop: unexpected error: %v", err)
+		}
+	}
+}
+
+// checkOutboxEmpty ensures the passed outbox has no queued payloads.
+func checkOutboxEmpty(t *testing.T, o *diskOutbox, now time.Time) {
+	if got, ok, _ := o.Front(now); ok {
+		t.Fatalf("outbox has %d payloads left starting with %q, want "+
+			"none", o.Len(), got)
+	}
+}
+
+// TestDiskOutbox ensures the disk outbox persists the undelivered payloads in
+// order and recovers from partially written records.
+func TestDiskOutbox(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "outbox", "test")
+	reopen := func(o *diskOutbox) *diskOutbox {
+		if o != nil {
+			o.Close()
+		}
+		o, err := openDiskOutbox(path, 1024*1024, time.Hour)
+		if err != nil {
+			t.Fatalf("openDiskOutbox: unexpected error: %v", err)
+		}
+		o.log = btclog.Disabled
+		return o
+	}
+
+	// Deliver some of the queued payloads and ensure only the others are
+	// loaded again once the outbox is reopened.
+	now := time.Unix(1500000000, 0)
+	o := reopen(nil)
+	pushOutbox(t, o, now, "event 0", "event 1", "event 2")
+	popOutbox(t, o, now, "event 0")
+	o = reopen(o)
+	popOutbox(t, o, now, "event 1")
+	pushOutbox(t, o, now, "event 3")
+	o = reopen(o)
+	if o.Len() != 2 {
+		t.Fatalf("got %d queued payloads, want 2", o.Len())
+	}
+
+	// Append a partial record to simulate a crash while appending and
+	// ensure it is discarded without affecting the following records.
+	o.Close()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatalf("unable to open outbox file: %v", err)
+	}
+	f.Write([]byte{0x00, 0x01, 0x02})
+	f.Close()
+	o = reopen(nil)
+	pushOutbox(t, o, now, "event 4")
+	o = reopen(o)
+	popOutbox(t, o, now, "event 2", "event 3", "event 4")
+	checkOutboxEmpty(t, o, now)
+
+	// The file is emptied once all payloads are delivered.
+	o.Close()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unable to stat outbox file: %v", err)
+	}
+	if fi.Size() != 0 {
+		t.Fatalf("outbox file has %d bytes after delivering all "+
+			"payloads, want 0", fi.Size())
+	}
+}
+
+// TestDiskOutboxLimits ensures the disk outbox drops the oldest payloads when
+// they exceed the size or age limits and compacts its file.
+func TestDiskOutboxLimits(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test")
+
+	// Limit the outbox to three records of eight byte payloads.
+	recordSize := int64(outboxRecordHeaderSize + 8)
+	o, err := openDiskOutbox(path, 3*recordSize, time.Hour)
+	if err != nil {
+		t.Fatalf("openDiskOutbox: unexpected error: %v", err)
+	}
+	o.log = btclog.Disabled
+	defer o.Close()
+
+	now := time.Unix(1500000000, 0)
+	pushOutbox(t, o, now, "event 00", "event 01", "event 02", "event 03")
+	if o.Dropped() != 1 {
+		t.Fatalf("got %d dropped payloads, want 1", o.Dropped())
+	}
+	popOutbox(t, o, now, "event 01")
+
+	// Payloads older than the age limit are dropped once they are next.
+	later := now.Add(time.Minute * 30)
+	pushOutbox(t, o, later, "event 04")
+	popOutbox(t, o, now.Add(time.Hour+time.Second), "event 04")
+	checkOutboxEmpty(t, o, later)
+	if o.Dropped() != 3 {
+		t.Fatalf("got %d dropped payloads, want 3", o.Dropped())
+	}
+
+	// Payloads larger than the outbox are rejected.
+	err = o.Push(make([]byte, 3*recordSize), now)
+	if err != errOutboxRecordTooLarge {
+		t.Fatalf("Push: got error %v, want %v", err,
+			errOutboxRecordTooLarge)
+	}
+}
+
+// TestDiskOutboxCompact ensures delivered records are removed from the outbox
+// file once they take a significant part of it.
+func TestDiskOutboxCompact(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test")
+
+	o, err := openDiskOutbox(path, 16*1024*1024, 0)
+	if err != nil {
+		t.Fatalf("openDiskOutbox: unexpected error: %v", err)
+	}
+
+	// Queue enough payloads for the delivered ones to exceed the compaction
+	// threshold, then deliver most of them.
+	const numPayloads = 2 * outboxCompactThreshold / 1024
+	now := time.Unix(1500000000, 0)
+	for i := 0; i < numPayloads; i++ {
+		payload := make([]byte, 1024)
+		copy(payload, fmt.Sprintf("event %d", i))
+		if err := o.Push(payload, now); err != nil {
+			t.Fatalf("Push: unexpected error: %v", err)
+		}
+	}
+	for i := 0; i < numPayloads-2; i++ {
+		if err := o.Pop(); err != nil {
+			t.Fatalf("Pop: unexpected error: %v", err)
+		}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unable to stat outbox file: %v", err)
+	}
+	if fi.Size() >= outboxCompactThreshold {
+		t.Fatalf("outbox file has %d bytes, want it compacted",
+			fi.Size())
+	}
+
+	// The remaining payloads must survive the compaction and reopening.
+	pushOutbox(t, o, now, "last")
+	o.Close()
+	o, err = openDiskOutbox(path, 16*1024*1024, 0)
+	if err != nil {
+		t.Fatalf("openDiskOutbox: unexpected error: %v", err)
+	}
+	defer o.Close()
+	for i := numPayloads - 2; i < numPayloads; i++ {
+		got, ok, err := o.Front(now)
+		if err != nil || !ok {
+			t.Fatalf("Front: got %v (%v), want payload %d", err, ok,
+				i)
+		}
+		want := fmt.Sprintf("event %d", i)
+		if string(got[:len(want)]) != want {
+			t.Fatalf("Front: got %q, want %q", got[:len(want)], want)
+		}
+		o.Pop()
+	}
+	popOutbox(t, o, now, "last")
+	checkOutboxEmpty(t, o, now)
+}
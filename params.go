@@ -48,6 +48,14 @@ var testNet3Params = params{
 	rpcPort: "18334",
 }
 
+// testNet4Params contains parameters specific to the test network (version 4)
+// (wire.TestNet4).  NOTE: The RPC port is intentionally different than the
+// reference implementation - see the mainNetParams comment for details.
+var testNet4Params = params{
+	Params:  &chaincfg.TestNet4Params,
+	rpcPort: "48334",
+}
+
 // simNetParams contains parameters specific to the simulation test network
 // (wire.SimNet).
 var simNetParams = params{
@@ -55,6 +63,14 @@ var simNetParams = params{
 	rpcPort: "18556",
 }
 
+// sigNetParams contains parameters specific to the default signet test
+// network (wire.SigNet).  NOTE: The RPC port is intentionally different than
+// the reference implementation - see the mainNetParams comment for details.
+var sigNetParams = params{
+	Params:  &chaincfg.SigNetParams,
+	rpcPort: "38332",
+}
+
 // netName returns the name used when referring to a bitcoin network.  At the
 // time of writing, btcd currently places blocks for testnet version 3 in the
 // data and log directory "testnet", which does not match the Name field of the
@@ -181,6 +181,10 @@ func messageSummary(msg wire.Message) string {
 		return fmt.Sprintf("hash %s, ver %d, %d tx, %s", msg.BlockHash(),
 			header.Version, len(msg.Transactions), header.Timestamp)
 
+	case *wire.MsgZBlock:
+		return fmt.Sprintf("size %d, compressed %d", msg.Size,
+			len(msg.Data))
+
 	case *wire.MsgInv:
 		return invSummary(msg.InvList)
 
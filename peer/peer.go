@@ -242,6 +242,26 @@ type Config struct {
 	// not send inv messages for transactions.
 	DisableRelayTx bool
 
+	// MaxUploadRate specifies the maximum rate in bytes per second at which
+	// blocks are sent to the remote peer.  This field can be omitted in
+	// which case it will be 0 and therefore not limit the rate.
+	MaxUploadRate int64
+
+	// MaxDownloadRate specifies the maximum rate in bytes per second at
+	// which messages are read from the remote peer.  The time spent waiting
+	// to read the next message does not count towards the stall detection
+	// of the remote peer.  This field can be omitted in which case it will
+	// be 0 and therefore not limit the rate.
+	MaxDownloadRate int64
+
+	// AllowZBlocks specifies whether blocks compressed in zblock messages
+	// are accepted from the remote peer when both peers advertise
+	// SFNodeZstdBlocks.  Decompressing blocks costs CPU time and
+	// memory, so the remote peer is disconnected before its zblock message
+	// is decompressed when this is false.  This field can be omitted in
+	// which case it will be false.
+	AllowZBlocks bool
+
 	// Listeners houses callback functions to be invoked on receiving peer
 	// messages.
 	Listeners MessageListeners
@@ -349,6 +369,14 @@ type StatsSnap struct {
 	LastPingNonce  uint64
 	LastPingTime   time.Time
 	LastPingMicros int64
+
+	// MaxUploadRate and MaxDownloadRate are the configured rate limits in
+	// bytes per second, or 0 when the rate is not limited.  The throttled
+	// durations are the total time transfers have been delayed by them.
+	MaxUploadRate     int64
+	MaxDownloadRate   int64
+	UploadThrottled   time.Duration
+	DownloadThrottled time.Duration
 }
 
 // HashFunc is a function which returns a block hash, height and error
@@ -440,6 +468,11 @@ type Peer struct {
 	lastPingTime       time.Time // Time we sent last ping.
 	lastPingMicros     int64     // Time for last ping to return.
 
+	// The rate limiters are set at creation time and are nil when the
+	// corresponding rate is not limited.
+	uploadLimiter   *rateLimiter
+	downloadLimiter *rateLimiter
+
 	stallControl  chan stallControlMsg
 	outputQueue   chan outMsg
 	sendQueue     chan outMsg
@@ -524,6 +557,14 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
 	}
+	if p.uploadLimiter != nil {
+		statsSnap.MaxUploadRate = p.cfg.MaxUploadRate
+		statsSnap.UploadThrottled = p.uploadLimiter.Throttled()
+	}
+	if p.downloadLimiter != nil {
+		statsSnap.MaxDownloadRate = p.cfg.MaxDownloadRate
+		statsSnap.DownloadThrottled = p.downloadLimiter.Throttled()
+	}
 
 	p.statsMtx.RUnlock()
 	return statsSnap
@@ -1190,6 +1231,12 @@ func (p *Peer) writeMessage(msg wire.Message, enc wire.MessageEncoding) error {
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
 	}
+
+	// Delay sending the next message as needed to keep the rate at which
+	// blocks are served within the limit.
+	if err == nil && p.uploadLimiter != nil && isBlockMessage(msg) {
+		p.uploadLimiter.Wait(n, p.quit)
+	}
 	return err
 }
 
@@ -1325,6 +1372,8 @@ out:
 				// one of a group of responses, remove
 				// everything in the expected group accordingly.
 				switch msgCmd := msg.message.Command(); msgCmd {
+				case wire.CmdZBlock:
+					fallthrough
 				case wire.CmdBlock:
 					fallthrough
 				case wire.CmdMerkleBlock:
@@ -1549,6 +1598,26 @@ out:
 				p.cfg.Listeners.OnBlock(p, msg, buf)
 			}
 
+		case *wire.MsgZBlock:
+			// Compressed blocks are only accepted when both peers
+			// advertised support for them and they are allowed
+			// from the remote peer.
+			if !p.cfg.AllowZBlocks || !p.SupportsZstdBlocks() {
+				log.Debugf("Received unexpected zblock message "+
+					"from %s -- disconnecting", p)
+				break out
+			}
+			block, blockBytes, err := decodeZBlockMsg(msg,
+				p.ProtocolVersion(), p.wireEncoding)
+			if err != nil {
+				log.Errorf("Unable to decode zblock message from "+
+					"%s: %v -- disconnecting", p, err)
+				break out
+			}
+			if p.cfg.Listeners.OnBlock != nil {
+				p.cfg.Listeners.OnBlock(p, block, blockBytes)
+			}
+
 		case *wire.MsgInv:
 			if p.cfg.Listeners.OnInv != nil {
 				p.cfg.Listeners.OnInv(p, msg)
@@ -1622,6 +1691,15 @@ out:
 			log.Debugf("Received unhandled message of type %v "+
 				"from %v", rmsg.Command(), p)
 		}
+
+		// Delay reading the next message as needed to keep the download
+		// rate within the limit.  This is done before signalling the
+		// handler is done so the stall handler doesn't count the time
+		// spent waiting against the remote peer.
+		if p.downloadLimiter != nil {
+			p.downloadLimiter.Wait(wire.MessageHeaderSize+len(buf),
+				p.quit)
+		}
 		p.stallControl <- stallControlMsg{sccHandlerDone, rmsg}
 
 		// A message was received so reset the idle timer.
@@ -2129,6 +2207,13 @@ func newPeerBase(origCfg *Config, inbound bool) *Peer {
 		services:        cfg.Services,
 		protocolVersion: cfg.ProtocolVersion,
 	}
+	if cfg.MaxUploadRate > 0 {
+		p.uploadLimiter = newRateLimiter(cfg.MaxUploadRate, time.Now())
+	}
+	if cfg.MaxDownloadRate > 0 {
+		p.downloadLimiter = newRateLimiter(cfg.MaxDownloadRate,
+			time.Now())
+	}
 	return &p
 }
 
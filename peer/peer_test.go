@@ -5,6 +5,7 @@
 package peer_test
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"net"
@@ -569,6 +570,88 @@ func TestPeerListeners(t *testing.T) {
 	outPeer.Disconnect()
 }
 
+// TestZBlockAllowed ensures blocks compressed in zblock messages are only
+// accepted from the peers they are allowed from and that the other peers are
+// disconnected instead.
+func TestZBlockAllowed(t *testing.T) {
+	for _, allow := range []bool{true, false} {
+		verack := make(chan struct{}, 2)
+		blocks := make(chan *wire.MsgBlock, 1)
+		peerCfg := &peer.Config{
+			Listeners: peer.MessageListeners{
+				OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+					verack <- struct{}{}
+				},
+				OnBlock: func(p *peer.Peer, msg *wire.MsgBlock, buf []byte) {
+					blocks <- msg
+				},
+			},
+			UserAgentName:    "peer",
+			UserAgentVersion: "1.0",
+			ChainParams:      &chaincfg.MainNetParams,
+			Services:         wire.SFNodeZstdBlocks,
+			AllowZBlocks:     allow,
+		}
+		inConn, outConn := pipe(
+			&conn{raddr: "10.0.0.1:8333"},
+			&conn{raddr: "10.0.0.2:8333"},
+		)
+		inPeer := peer.NewInboundPeer(peerCfg)
+		inPeer.AssociateConnection(inConn)
+		outPeer, err := peer.NewOutboundPeer(peerCfg, "10.0.0.1:8333")
+		if err != nil {
+			t.Fatalf("NewOutboundPeer: unexpected err %v", err)
+		}
+		outPeer.AssociateConnection(outConn)
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(time.Second):
+				t.Fatal("TestZBlockAllowed: verack timeout")
+			}
+		}
+
+		genesis := chaincfg.MainNetParams.GenesisBlock
+		var buf bytes.Buffer
+		if err := genesis.Serialize(&buf); err != nil {
+			t.Fatalf("Serialize: %v", err)
+		}
+		msg, err := peer.NewZBlockMsg(buf.Bytes())
+		if err != nil {
+			t.Fatalf("NewZBlockMsg: %v", err)
+		}
+		outPeer.QueueMessage(msg, nil)
+
+		disconnected := make(chan struct{})
+		go func() {
+			inPeer.WaitForDisconnect()
+			close(disconnected)
+		}()
+		select {
+		case block := <-blocks:
+			if !allow {
+				t.Fatal("TestZBlockAllowed: accepted a zblock " +
+					"which is not allowed")
+			}
+			if block.BlockHash() != genesis.BlockHash() {
+				t.Fatalf("TestZBlockAllowed: got block %v, "+
+					"want %v", block.BlockHash(),
+					genesis.BlockHash())
+			}
+		case <-disconnected:
+			if allow {
+				t.Fatal("TestZBlockAllowed: disconnected on " +
+					"an allowed zblock")
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("TestZBlockAllowed: timeout with allowed %v",
+				allow)
+		}
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+	}
+}
+
 // TestOutboundPeer tests that the outbound peer works as expected.
 func TestOutboundPeer(t *testing.T) {
 
@@ -0,0 +1,98 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// rateLimiter limits the rate at which bytes are transferred with a token
+// bucket.  The bucket fills at the configured rate up to one second worth of
+// bytes, and each transfer takes its size out of the bucket.  Transfers larger
+// than the bucket are allowed, but leave it in debt, so the transfers which
+// follow have to wait until the debt is paid off.
+type rateLimiter struct {
+	mtx       sync.Mutex
+	rate      float64
+	tokens    float64
+	last      time.Time
+	throttled time.Duration
+}
+
+// newRateLimiter returns a new rate limiter which limits transfers to the
+// passed number of bytes per second.
+func newRateLimiter(bytesPerSec int64, now time.Time) *rateLimiter {
+	return &rateLimiter{
+		rate:   float64(bytesPerSec),
+		tokens: float64(bytesPerSec),
+		last:   now,
+	}
+}
+
+// take takes the passed number of bytes out of the bucket at the passed time
+// and returns how long the caller must wait before the next transfer.
+//
+// This function is safe for concurrent access.
+func (r *rateLimiter) take(n int, now time.Time) time.Duration {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if elapsed := now.Sub(r.last); elapsed > 0 {
+		r.tokens += elapsed.Seconds() * r.rate
+		if r.tokens > r.rate {
+			r.tokens = r.rate
+		}
+		r.last = now
+	}
+	r.tokens -= float64(n)
+	if r.tokens >= 0 {
+		return 0
+	}
+	wait := time.Duration(-r.tokens / r.rate * float64(time.Second))
+	r.throttled += wait
+	return wait
+}
+
+// Wait takes the passed number of transferred bytes out of the bucket and
+// blocks until the rate allows another transfer or the passed quit channel is
+// closed.
+//
+// This function is safe for concurrent access.
+func (r *rateLimiter) Wait(n int, quit <-chan struct{}) {
+	wait := r.take(n, time.Now())
+	if wait == 0 {
+		return
+	}
+	t := time.NewTimer(wait)
+	select {
+	case <-t.C:
+	case <-quit:
+		t.Stop()
+	}
+}
+
+// Throttled returns the total duration transfers have been delayed by the
+// rate limiter.
+//
+// This function is safe for concurrent access.
+func (r *rateLimiter) Throttled() time.Duration {
+	r.mtx.Lock()
+	throttled := r.throttled
+	r.mtx.Unlock()
+	return throttled
+}
+
+// isBlockMessage returns whether the passed message serves a block and is
+// therefore subject to the upload rate limit.
+func isBlockMessage(msg wire.Message) bool {
+	switch msg.(type) {
+	case *wire.MsgBlock, *wire.MsgMerkleBlock:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestRateLimiter ensures the rate limiter allows bursts of up to one second
+// worth of bytes and delays transfers beyond that according to the rate.
+func TestRateLimiter(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	r := newRateLimiter(1000, start)
+
+	tests := []struct {
+		name   string
+		n      int
+		offset time.Duration
+		wait   time.Duration
+	}{
+		{"within burst", 600, 0, 0},
+		{"exhausts burst", 400, 0, 0},
+		{"in debt", 500, 0, 500 * time.Millisecond},
+		{"debt partly paid", 100, 300 * time.Millisecond, 300 * time.Millisecond},
+		{"debt paid", 0, 600 * time.Millisecond, 0},
+		{"larger than burst", 3000, 10 * time.Second, 2 * time.Second},
+	}
+
+	var throttled time.Duration
+	for _, test := range tests {
+		wait := r.take(test.n, start.Add(test.offset))
+		if wait != test.wait {
+			t.Fatalf("%s: got wait %v, want %v", test.name, wait,
+				test.wait)
+		}
+		throttled += test.wait
+	}
+	if r.Throttled() != throttled {
+		t.Fatalf("got throttled %v, want %v", r.Throttled(), throttled)
+	}
+
+	// Waiting returns once the quit channel is closed.
+	quit := make(chan struct{})
+	close(quit)
+	done := make(chan struct{})
+	go func() {
+		r.Wait(1000000, quit)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("wait did not return after quit")
+	}
+}
+
+// TestIsBlockMessage ensures only the messages which serve blocks are subject
+// to the upload rate limit.
+func TestIsBlockMessage(t *testing.T) {
+	if !isBlockMessage(&wire.MsgBlock{}) || !isBlockMessage(&wire.MsgMerkleBlock{}) {
+		t.Fatal("block message not detected")
+	}
+	if isBlockMessage(&wire.MsgTx{}) || isBlockMessage(&wire.MsgHeaders{}) {
+		t.Fatal("non-block message detected as block message")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	// zstdEncoder and zstdDecoder are used to compress and decompress the
+	// blocks of zblock messages.  They are only created once needed and
+	// are safe for concurrent use.
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdInit    sync.Once
+	zstdInitErr error
+)
+
+// initZstd creates the zstd encoder and decoder if they were not created yet.
+// The decoder refuses to allocate more memory than is needed for the largest
+// possible block.
+func initZstd() error {
+	zstdInit.Do(func() {
+		zstdEncoder, zstdInitErr = zstd.NewWriter(nil,
+			zstd.WithEncoderConcurrency(1))
+		if zstdInitErr != nil {
+			return
+		}
+		zstdDecoder, zstdInitErr = zstd.NewReader(nil,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(wire.MaxBlockPayload))
+	})
+	return zstdInitErr
+}
+
+// NewZBlockMsg returns a zblock message holding the passed serialized block
+// compressed with zstd.  The block must be serialized with the encoding the
+// peer it is sent to expects for block messages.
+func NewZBlockMsg(blockBytes []byte) (*wire.MsgZBlock, error) {
+	if err := initZstd(); err != nil {
+		return nil, err
+	}
+	data := zstdEncoder.EncodeAll(blockBytes, nil)
+	return wire.NewMsgZBlock(uint32(len(blockBytes)), data), nil
+}
+
+// decodeZBlockMsg decompresses the block held by the passed zblock message and
+// returns it along with its serialized bytes.
+func decodeZBlockMsg(msg *wire.MsgZBlock, pver uint32,
+	enc wire.MessageEncoding) (*wire.MsgBlock, []byte, error) {
+
+	if err := initZstd(); err != nil {
+		return nil, nil, err
+	}
+	blockBytes, err := zstdDecoder.DecodeAll(msg.Data,
+		make([]byte, 0, msg.Size))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(blockBytes) != int(msg.Size) {
+		return nil, nil, fmt.Errorf("decompressed block is %d bytes "+
+			"instead of %d", len(blockBytes), msg.Size)
+	}
+
+	var block wire.MsgBlock
+	r := bytes.NewReader(blockBytes)
+	if err := block.BtcDecode(r, pver, enc); err != nil {
+		return nil, nil, err
+	}
+	if r.Len() != 0 {
+		return nil, nil, fmt.Errorf("decompressed block has %d trailing "+
+			"bytes", r.Len())
+	}
+	return &block, blockBytes, nil
+}
+
+// SupportsZstdBlocks returns whether both the local and the remote peer
+// advertise SFNodeZstdBlocks, in which case blocks may be exchanged in zblock
+// messages.
+//
+// This function is safe for concurrent access.
+func (p *Peer) SupportsZstdBlocks() bool {
+	return p.cfg.Services&wire.SFNodeZstdBlocks != 0 &&
+		p.Services()&wire.SFNodeZstdBlocks != 0
+}
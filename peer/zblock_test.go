@@ -0,0 +1,77 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestZBlockMsg ensures blocks survive the round trip through a zblock message
+// and that malformed zblock messages are rejected.
+func TestZBlockMsg(t *testing.T) {
+	t.Parallel()
+
+	block := chaincfg.MainNetParams.GenesisBlock
+	var buf bytes.Buffer
+	if err := block.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	blockBytes := buf.Bytes()
+
+	msg, err := NewZBlockMsg(blockBytes)
+	if err != nil {
+		t.Fatalf("NewZBlockMsg: %v", err)
+	}
+	if msg.Size != uint32(len(blockBytes)) {
+		t.Fatalf("NewZBlockMsg: got size %d, want %d", msg.Size,
+			len(blockBytes))
+	}
+	gotBlock, gotBytes, err := decodeZBlockMsg(msg, wire.ProtocolVersion,
+		wire.WitnessEncoding)
+	if err != nil {
+		t.Fatalf("decodeZBlockMsg: %v", err)
+	}
+	if gotBlock.BlockHash() != block.BlockHash() {
+		t.Fatalf("decodeZBlockMsg: got block %v, want %v",
+			gotBlock.BlockHash(), block.BlockHash())
+	}
+	if !bytes.Equal(gotBytes, blockBytes) {
+		t.Fatal("decodeZBlockMsg: serialized block mismatch")
+	}
+
+	// A size which does not match the decompressed block is rejected.
+	for _, size := range []uint32{msg.Size - 1, msg.Size + 1} {
+		bad := wire.NewMsgZBlock(size, msg.Data)
+		_, _, err = decodeZBlockMsg(bad, wire.ProtocolVersion,
+			wire.WitnessEncoding)
+		if err == nil {
+			t.Fatalf("decodeZBlockMsg: expected error for size %d "+
+				"instead of %d", size, msg.Size)
+		}
+	}
+
+	// Data which is not zstd compressed is rejected.
+	bad := wire.NewMsgZBlock(msg.Size, blockBytes)
+	_, _, err = decodeZBlockMsg(bad, wire.ProtocolVersion,
+		wire.WitnessEncoding)
+	if err == nil {
+		t.Fatal("decodeZBlockMsg: expected error for corrupt data")
+	}
+
+	// Bytes following the block are rejected.
+	trailing, err := NewZBlockMsg(append(blockBytes[:len(blockBytes):len(blockBytes)], 0))
+	if err != nil {
+		t.Fatalf("NewZBlockMsg: %v", err)
+	}
+	_, _, err = decodeZBlockMsg(trailing, wire.ProtocolVersion,
+		wire.WitnessEncoding)
+	if err == nil {
+		t.Fatal("decodeZBlockMsg: expected error for trailing bytes")
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// probeProtocolVersion is the protocol version advertised when probing
+	// a peer.  It is newer than the version the peer package supports so
+	// the remote peer announces the optional features it supports.  This
+	// is fine since the probe reads the messages itself and skips the ones
+	// it does not understand instead of disconnecting.
+	probeProtocolVersion = 70016
+
+	// probeCmdSendCmpct is the command of the message a peer sends to
+	// announce it supports compact blocks as defined by BIP0152.
+	probeCmdSendCmpct = "sendcmpct"
+
+	// maxProbeMessages is the maximum number of distinct commands recorded
+	// while probing a peer.
+	maxProbeMessages = 32
+
+	// sfNodeCF is the service flag which indicates a peer serves committed
+	// filters as defined by BIP0157.
+	sfNodeCF wire.ServiceFlag = 1 << 6
+
+	// sfNodeNetworkLimited is the service flag which indicates a peer only
+	// serves the most recent blocks as defined by BIP0159.
+	sfNodeNetworkLimited wire.ServiceFlag = 1 << 10
+)
+
+// probeServiceNames maps the service flags known to the probe to their names.
+// It extends the flags known by the wire package with the ones defined by
+// later BIPs which a peer might advertise.
+var probeServiceNames = map[wire.ServiceFlag]string{
+	wire.SFNodeNetwork:   "SFNodeNetwork",
+	wire.SFNodeGetUTXO:   "SFNodeGetUTXO",
+	wire.SFNodeBloom:     "SFNodeBloom",
+	wire.SFNodeWitness:   "SFNodeWitness",
+	sfNodeCF:             "SFNodeCF",
+	sfNodeNetworkLimited: "SFNodeNetworkLimited",
+}
+
+// serviceNames returns the names of the passed service flags.  Flags which are
+// not known are named by their value in hex.
+func serviceNames(services wire.ServiceFlag) []string {
+	names := make([]string, 0)
+	for bit := uint(0); bit < 64; bit++ {
+		flag := wire.ServiceFlag(1) << bit
+		if services&flag == 0 {
+			continue
+		}
+		name, ok := probeServiceNames[flag]
+		if !ok {
+			name = fmt.Sprintf("0x%x", uint64(flag))
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// readRawMessage reads the next message for the passed bitcoin network from
+// the passed reader and returns its command along with the full serialized
+// message.  Unlike wire.ReadMessage, messages with unknown commands are
+// returned rather than rejected.
+func readRawMessage(r io.Reader, btcnet wire.BitcoinNet) (string, []byte, error) {
+	var hdr [wire.MessageHeaderSize]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return "", nil, err
+	}
+	if magic := wire.BitcoinNet(binary.LittleEndian.Uint32(hdr[:4])); magic != btcnet {
+		return "", nil, fmt.Errorf("message from other network [%v]",
+			magic)
+	}
+	command := string(bytes.TrimRight(hdr[4:4+wire.CommandSize], "\x00"))
+	length := binary.LittleEndian.Uint32(hdr[4+wire.CommandSize:])
+	if length > wire.MaxMessagePayload {
+		return "", nil, fmt.Errorf("%s message payload of %d bytes is "+
+			"too large", command, length)
+	}
+
+	msg := make([]byte, wire.MessageHeaderSize+int(length))
+	copy(msg, hdr[:])
+	if _, err := io.ReadFull(r, msg[wire.MessageHeaderSize:]); err != nil {
+		return "", nil, err
+	}
+	return command, msg, nil
+}
+
+// probePeer connects to the peer at the passed address with the passed dial
+// function, performs the version handshake, measures the round trip time of a
+// ping, and disconnects.  The returned result describes the services, user
+// agent, and height the peer advertised, how long each step took, and the
+// optional features the peer announced support for.  Probing fails when it
+// takes longer than the passed timeout.
+//
+// The probe advertises no services, the passed best height, and that
+// transactions should not be relayed to it, so the peer has no reason to send
+// anything else in the meantime.
+func probePeer(addr net.Addr, dial func(net.Addr) (net.Conn, error),
+	params *chaincfg.Params, bestHeight int32,
+	timeout time.Duration) (*btcjson.GetPeerServicesResult, error) {
+
+	start := time.Now()
+	conn, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	connected := time.Now()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		return nil, err
+	}
+	toMillis := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+
+	nonce, err := wire.RandomUint64()
+	if err != nil {
+		return nil, err
+	}
+	you := wire.NewNetAddressIPPort(net.IPv4zero, 0, 0)
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		you = wire.NewNetAddress(tcpAddr, 0)
+	}
+	me := wire.NewNetAddressIPPort(net.IPv4zero, 0, 0)
+	version := wire.NewMsgVersion(me, you, nonce, bestHeight)
+	version.ProtocolVersion = probeProtocolVersion
+	version.DisableRelayTx = true
+	err = version.AddUserAgent(userAgentName, userAgentVersion, "probe")
+	if err != nil {
+		return nil, err
+	}
+	send := func(msg wire.Message) error {
+		return wire.WriteMessage(conn, msg, wire.ProtocolVersion,
+			params.Net)
+	}
+	if err := send(version); err != nil {
+		return nil, err
+	}
+
+	result := &btcjson.GetPeerServicesResult{
+		Addr:                 addr.String(),
+		ConnectTime:          toMillis(connected.Sub(start)),
+		Messages:             make([]string, 0),
+		CompactBlockVersions: make([]uint64, 0),
+	}
+	seen := make(map[string]struct{})
+	var gotVersion, gotVerAck bool
+	var pingNonce uint64
+	var pingSent time.Time
+	for {
+		command, rawMsg, err := readRawMessage(conn, params.Net)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message: %v", err)
+		}
+		if _, ok := seen[command]; !ok && len(seen) < maxProbeMessages {
+			seen[command] = struct{}{}
+			result.Messages = append(result.Messages, command)
+		}
+
+		switch command {
+		case wire.CmdVersion, wire.CmdPing, wire.CmdPong:
+			msg, _, err := wire.ReadMessage(bytes.NewReader(rawMsg),
+				wire.ProtocolVersion, params.Net)
+			if err != nil {
+				return nil, err
+			}
+
+			switch msg := msg.(type) {
+			case *wire.MsgVersion:
+				if msg.Nonce == nonce {
+					return nil, fmt.Errorf("connected to self")
+				}
+				gotVersion = true
+				result.Version = uint32(msg.ProtocolVersion)
+				result.Services = fmt.Sprintf("%08d",
+					uint64(msg.Services))
+				result.ServiceNames = serviceNames(msg.Services)
+				result.SubVer = msg.UserAgent
+				result.StartingHeight = msg.LastBlock
+				result.RelayTxes = !msg.DisableRelayTx
+				result.TimeOffset = msg.Timestamp.Unix() -
+					time.Now().Unix()
+				result.CFilters = msg.Services&sfNodeCF == sfNodeCF
+				if err := send(wire.NewMsgVerAck()); err != nil {
+					return nil, err
+				}
+
+			case *wire.MsgPing:
+				if err := send(wire.NewMsgPong(msg.Nonce)); err != nil {
+					return nil, err
+				}
+
+			case *wire.MsgPong:
+				if !pingSent.IsZero() && msg.Nonce == pingNonce {
+					result.PingTime = toMillis(time.Since(pingSent))
+					return result, nil
+				}
+			}
+
+		case wire.CmdVerAck:
+			gotVerAck = true
+
+		case probeCmdSendCmpct:
+			// The payload is whether new blocks should be announced
+			// as compact blocks followed by the compact block
+			// version.
+			payload := rawMsg[wire.MessageHeaderSize:]
+			if len(payload) == 9 {
+				result.CompactBlocks = true
+				result.CompactBlockVersions = append(
+					result.CompactBlockVersions,
+					binary.LittleEndian.Uint64(payload[1:]))
+			}
+		}
+
+		if !gotVersion || !gotVerAck || !pingSent.IsZero() {
+			continue
+		}
+		result.HandshakeTime = toMillis(time.Since(connected))
+
+		// Peers which predate BIP0031 don't reply to pings, so the
+		// probe is done once the handshake is.
+		if result.Version <= wire.BIP0031Version {
+			return result, nil
+		}
+		pingNonce, err = wire.RandomUint64()
+		if err != nil {
+			return nil, err
+		}
+		if err := send(wire.NewMsgPing(pingNonce)); err != nil {
+			return nil, err
+		}
+		pingSent = time.Now()
+	}
+}
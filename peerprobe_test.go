@@ -0,0 +1,150 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// rawMessage returns a serialized message with the passed command and payload
+// for the passed bitcoin network, which allows creating messages the wire
+// package does not know about.
+func rawMessage(btcnet wire.BitcoinNet, command string, payload []byte) []byte {
+	var buf bytes.Buffer
+	var hdr [wire.MessageHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[:4], uint32(btcnet))
+	copy(hdr[4:4+wire.CommandSize], command)
+	binary.LittleEndian.PutUint32(hdr[16:20], uint32(len(payload)))
+	copy(hdr[20:], chainhash.DoubleHashB(payload)[:4])
+	buf.Write(hdr[:])
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// TestProbePeer ensures probing a peer records what it advertised during the
+// version handshake along with the optional features it announced.
+func TestProbePeer(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer listener.Close()
+
+	// Serve a single remote peer which announces compact blocks and replies
+	// to pings.
+	remoteErr := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			remoteErr <- err
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+		msg, _, err := wire.ReadMessage(conn, wire.ProtocolVersion,
+			params.Net)
+		if err != nil {
+			remoteErr <- err
+			return
+		}
+		if msg.(*wire.MsgVersion).ProtocolVersion != probeProtocolVersion {
+			remoteErr <- errors.New("unexpected protocol version")
+			return
+		}
+
+		me := wire.NewNetAddressIPPort(net.IPv4zero, 0, 0)
+		version := wire.NewMsgVersion(me, me, 1, 1234)
+		version.Services = wire.SFNodeNetwork | wire.SFNodeWitness |
+			sfNodeCF
+		version.UserAgent = "/remote:1.0/"
+		for _, msg := range []wire.Message{version, wire.NewMsgVerAck()} {
+			err := wire.WriteMessage(conn, msg, wire.ProtocolVersion,
+				params.Net)
+			if err != nil {
+				remoteErr <- err
+				return
+			}
+		}
+		sendCmpct := []byte{0, 2, 0, 0, 0, 0, 0, 0, 0}
+		if _, err := conn.Write(rawMessage(params.Net, "sendcmpct",
+			sendCmpct)); err != nil {
+
+			remoteErr <- err
+			return
+		}
+
+		for {
+			msg, _, err := wire.ReadMessage(conn, wire.ProtocolVersion,
+				params.Net)
+			if err != nil {
+				remoteErr <- err
+				return
+			}
+			if ping, ok := msg.(*wire.MsgPing); ok {
+				remoteErr <- wire.WriteMessage(conn,
+					wire.NewMsgPong(ping.Nonce),
+					wire.ProtocolVersion, params.Net)
+				return
+			}
+		}
+	}()
+
+	dial := func(addr net.Addr) (net.Conn, error) {
+		return net.Dial(addr.Network(), addr.String())
+	}
+	result, err := probePeer(listener.Addr(), dial, params, 100,
+		10*time.Second)
+	if err != nil {
+		t.Fatalf("unable to probe peer: %v", err)
+	}
+	if err := <-remoteErr; err != nil {
+		t.Fatalf("remote peer failed: %v", err)
+	}
+
+	wantServices := []string{"SFNodeNetwork", "SFNodeWitness", "SFNodeCF"}
+	if !reflect.DeepEqual(result.ServiceNames, wantServices) {
+		t.Errorf("got services %v, want %v", result.ServiceNames,
+			wantServices)
+	}
+	if result.SubVer != "/remote:1.0/" || result.StartingHeight != 1234 {
+		t.Errorf("unexpected version details %+v", result)
+	}
+	if !result.CFilters || !result.CompactBlocks ||
+		!reflect.DeepEqual(result.CompactBlockVersions, []uint64{2}) {
+
+		t.Errorf("unexpected features %+v", result)
+	}
+	wantMessages := []string{"version", "verack", "sendcmpct", "pong"}
+	if !reflect.DeepEqual(result.Messages, wantMessages) {
+		t.Errorf("got messages %v, want %v", result.Messages,
+			wantMessages)
+	}
+}
+
+// TestServiceNames ensures service flags are named, including the ones the
+// wire package does not know about.
+func TestServiceNames(t *testing.T) {
+	t.Parallel()
+
+	services := wire.SFNodeNetwork | sfNodeNetworkLimited | 1<<30
+	want := []string{"SFNodeNetwork", "SFNodeNetworkLimited", "0x40000000"}
+	if got := serviceNames(services); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
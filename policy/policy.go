@@ -0,0 +1,122 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package policy houses the standardness rules used to decide which transactions
+are relayed and mined.
+
+Unlike the consensus rules enforced by the blockchain package, the standardness
+rules are local policy which may differ between nodes without splitting the
+chain.  The rules are grouped into a Policy so they can be constructed once from
+the configuration of a node and shared by the mempool and mining packages.
+Operators of other networks may therefore tune them without patching source.
+*/
+package policy
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// DefaultMinRelayTxFee is the minimum fee in satoshi that is required
+	// for a transaction to be treated as free for relay and mining
+	// purposes.  It is also used as a base for calculating minimum
+	// required fees for larger transactions.  This value is in
+	// Satoshi/1000 bytes.
+	DefaultMinRelayTxFee = btcutil.Amount(1000)
+
+	// DefaultDustRelayFee is the default fee rate used to determine
+	// whether a transaction output is dust.  This value is in
+	// Satoshi/1000 bytes.
+	DefaultDustRelayFee = DefaultMinRelayTxFee
+
+	// DefaultMaxDataCarrierSize is the default maximum number of bytes of
+	// data which may be pushed by a standard null data (OP_RETURN) output.
+	DefaultMaxDataCarrierSize = txscript.MaxDataCarrierSize
+)
+
+// Policy houses the standardness rules which are configurable by operators.
+// The zero value is very restrictive, so New should be used to obtain the
+// default rules which are then adjusted as needed.
+type Policy struct {
+	// MinRelayTxFee defines the minimum transaction fee in Satoshi/1000
+	// bytes to be considered a non-zero fee.
+	MinRelayTxFee btcutil.Amount
+
+	// DustRelayFee defines the fee rate in Satoshi/1000 bytes used to
+	// determine whether a transaction output is dust.  An output is dust
+	// when spending it costs more than a third of its value at this rate.
+	DustRelayFee btcutil.Amount
+
+	// MaxDataCarrierSize is the maximum number of bytes of data which may
+	// be pushed by a standard null data (OP_RETURN) output.
+	MaxDataCarrierSize int
+
+	// PermitBareMultisig defines whether transactions with bare (not
+	// pay-to-script-hash) multi-signature outputs are standard.
+	PermitBareMultisig bool
+
+	// PermitMultiPushDataCarrier defines whether null data (OP_RETURN)
+	// outputs which push their data in more than one push are standard.
+	// Only a single push is standard otherwise.
+	PermitMultiPushDataCarrier bool
+}
+
+// New returns a new policy with the default standardness rules.
+func New() *Policy {
+	return &Policy{
+		MinRelayTxFee:      DefaultMinRelayTxFee,
+		DustRelayFee:       DefaultDustRelayFee,
+		MaxDataCarrierSize: DefaultMaxDataCarrierSize,
+		PermitBareMultisig: true,
+	}
+}
+
+// Validate returns an error when the policy contains values which are out of
+// range.
+func (p *Policy) Validate() error {
+	if p.MinRelayTxFee < 0 || p.MinRelayTxFee > btcutil.MaxSatoshi {
+		return fmt.Errorf("minimum relay fee %v is out of range",
+			p.MinRelayTxFee)
+	}
+	if p.DustRelayFee < 0 || p.DustRelayFee > btcutil.MaxSatoshi {
+		return fmt.Errorf("dust relay fee %v is out of range",
+			p.DustRelayFee)
+	}
+	if p.MaxDataCarrierSize < 0 ||
+		p.MaxDataCarrierSize > txscript.MaxScriptSize {
+
+		return fmt.Errorf("max data carrier size %d is not in the "+
+			"valid range of %d-%d", p.MaxDataCarrierSize, 0,
+			txscript.MaxScriptSize)
+	}
+	return nil
+}
+
+// NullDataSize returns the number of data bytes pushed by the passed public key
+// script and whether it is a null data script.  A null data script is an
+// OP_RETURN followed by at most one data push, or any number of data pushes
+// when multiPush is set.  Unlike the NullDataTy script class, the amount of
+// data is not limited so it can be checked against MaxDataCarrierSize.
+func NullDataSize(pkScript []byte, multiPush bool) (int, bool) {
+	if len(pkScript) == 0 || pkScript[0] != txscript.OP_RETURN {
+		return 0, false
+	}
+	if !txscript.IsPushOnlyScript(pkScript[1:]) {
+		return 0, false
+	}
+	pushes, err := txscript.PushedData(pkScript[1:])
+	if err != nil || (len(pushes) > 1 && !multiPush) {
+		return 0, false
+	}
+
+	var size int
+	for _, data := range pushes {
+		size += len(data)
+	}
+	return size, true
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// TestNullDataSize tests the NullDataSize API.
+func TestNullDataSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     *txscript.ScriptBuilder
+		multiPush  bool
+		size       int
+		isNullData bool
+	}{
+		{
+			name:       "bare OP_RETURN",
+			script:     txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN),
+			size:       0,
+			isNullData: true,
+		},
+		{
+			name: "single push",
+			script: txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+				AddData(make([]byte, 40)),
+			size:       40,
+			isNullData: true,
+		},
+		{
+			name: "push larger than the null data script class",
+			script: txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+				AddData(make([]byte, txscript.MaxDataCarrierSize+1)),
+			size:       txscript.MaxDataCarrierSize + 1,
+			isNullData: true,
+		},
+		{
+			name: "multiple pushes",
+			script: txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+				AddData(make([]byte, 10)).AddData(make([]byte, 20)),
+			isNullData: false,
+		},
+		{
+			name: "multiple pushes permitted",
+			script: txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+				AddData(make([]byte, 10)).AddData(make([]byte, 20)),
+			multiPush:  true,
+			size:       30,
+			isNullData: true,
+		},
+		{
+			name: "non-push opcode",
+			script: txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+				AddOp(txscript.OP_CHECKSIG),
+			isNullData: false,
+		},
+		{
+			name: "no leading OP_RETURN",
+			script: txscript.NewScriptBuilder().AddData(make([]byte, 10)).
+				AddOp(txscript.OP_RETURN),
+			isNullData: false,
+		},
+	}
+
+	for _, test := range tests {
+		script, err := test.script.Script()
+		if err != nil {
+			t.Fatalf("%s: unexpected script error: %v", test.name, err)
+		}
+		size, isNullData := NullDataSize(script, test.multiPush)
+		if isNullData != test.isNullData {
+			t.Errorf("%s: unexpected null data result - got %v, "+
+				"want %v", test.name, isNullData, test.isNullData)
+			continue
+		}
+		if size != test.size {
+			t.Errorf("%s: unexpected size - got %d, want %d",
+				test.name, size, test.size)
+		}
+	}
+}
+
+// TestValidate ensures out of range policies are rejected.
+func TestValidate(t *testing.T) {
+	if err := New().Validate(); err != nil {
+		t.Fatalf("Validate: unexpected error for default policy: %v", err)
+	}
+
+	p := New()
+	p.DustRelayFee = -1
+	if err := p.Validate(); err == nil {
+		t.Errorf("Validate: accepted negative dust relay fee")
+	}
+
+	p = New()
+	p.MaxDataCarrierSize = txscript.MaxScriptSize + 1
+	if err := p.Validate(); err == nil {
+		t.Errorf("Validate: accepted oversized max data carrier size")
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btclog"
+)
+
+const (
+	// proxyHealthCheckInterval is the interval at which the proxy is
+	// checked for reachability.
+	proxyHealthCheckInterval = time.Minute
+
+	// proxyFailureWindow is the number of most recent outbound connection
+	// attempts through the proxy which are considered when determining
+	// whether connection failures have spiked.
+	proxyFailureWindow = 16
+
+	// proxyFailureThreshold is the number of failed attempts within the
+	// failure window which causes new circuits to be requested.
+	proxyFailureThreshold = 10
+
+	// minCircuitRefreshInterval is the minimum amount of time between
+	// requests for new circuits.  Tor rate limits the requests itself,
+	// but this also prevents hammering the control port while the network
+	// is unreachable.
+	minCircuitRefreshInterval = time.Minute
+
+	// maxOutboundPeersPerCircuit is the maximum number of outbound peers
+	// which may be connected through the same circuit generation before
+	// new circuits are requested when stream isolation is disabled.  This
+	// limits how many peers a single malicious exit relay is able to
+	// observe or tamper with.
+	maxOutboundPeersPerCircuit = 4
+
+	// proxyHealthWarning is the name used to record proxy health warnings
+	// with the supervisor.
+	proxyHealthWarning = "proxy"
+)
+
+// proxyHealthMonitor tracks the health of the Tor proxy used for outbound
+// connections.  It periodically checks the proxy is reachable, requests new
+// circuits through the Tor control port when outbound connection failures
+// spike, and tracks the number of outbound peers connected through each
+// circuit generation so circuits can be refreshed to keep peers diverse.
+//
+// A circuit generation is the period between two circuit refreshes.  Without
+// stream isolation, Tor reuses the same circuit for new connections within a
+// generation, so all peers connected within it share the same exit relay.
+type proxyHealthMonitor struct {
+	proxyAddr string
+	isolation bool
+	dial      func(net.Addr) (net.Conn, error)
+	viaProxy  func(net.Addr) bool
+	ping      func() error
+	refresh   func() error
+	warnings  *supervisor
+	log       btclog.Logger
+
+	mtx          sync.Mutex
+	results      []bool
+	healthy      bool
+	circuit      uint32
+	circuitPeers map[uint32]int
+	lastRefresh  time.Time
+	refreshReq   chan string
+}
+
+// newProxyHealthMonitor returns a new proxy health monitor for the proxy at the
+// passed address.  Outbound connections made through the monitor's Dial method
+// are made with the passed dial function and only those for which viaProxy
+// returns true are tracked.  The refresh function requests new circuits and may
+// be nil when no Tor control port is configured.
+func newProxyHealthMonitor(proxyAddr string, isolation bool,
+	dial func(net.Addr) (net.Conn, error), viaProxy func(net.Addr) bool,
+	refresh func() error, warnings *supervisor) *proxyHealthMonitor {
+
+	return &proxyHealthMonitor{
+		proxyAddr: proxyAddr,
+		isolation: isolation,
+		dial:      dial,
+		viaProxy:  viaProxy,
+		ping: func() error {
+			conn, err := net.DialTimeout("tcp", proxyAddr,
+				defaultConnectTimeout)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		},
+		refresh:      refresh,
+		warnings:     warnings,
+		log:          srvrLog,
+		healthy:      true,
+		circuitPeers: make(map[uint32]int),
+		refreshReq:   make(chan string, 1),
+	}
+}
+
+// circuitConn wraps a connection made through the proxy in order to track the
+// number of connected peers per circuit generation.
+type circuitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+// Close releases the connection from its circuit generation and closes it.
+func (c *circuitConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+// Dial connects to the passed address and records the result when the
+// connection is made through the proxy.  It is suitable for use as the dial
+// function of the connection manager.
+func (m *proxyHealthMonitor) Dial(addr net.Addr) (net.Conn, error) {
+	conn, err := m.dial(addr)
+	if !m.viaProxy(addr) {
+		return conn, err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.results = append(m.results, err == nil)
+	if len(m.results) > proxyFailureWindow {
+		m.results = m.results[len(m.results)-proxyFailureWindow:]
+	}
+	if err != nil {
+		if m.failures() >= proxyFailureThreshold {
+			m.requestRefresh(fmt.Sprintf("%d of the last %d "+
+				"connections through the proxy failed",
+				m.failures(), len(m.results)))
+		}
+		return nil, err
+	}
+
+	circuit := m.circuit
+	m.circuitPeers[circuit]++
+	if !m.isolation && m.circuitPeers[circuit] >= maxOutboundPeersPerCircuit {
+		m.requestRefresh(fmt.Sprintf("%d peers are connected through "+
+			"the current circuit", m.circuitPeers[circuit]))
+	}
+	return &circuitConn{Conn: conn, release: func() {
+		m.mtx.Lock()
+		m.circuitPeers[circuit]--
+		if m.circuitPeers[circuit] <= 0 {
+			delete(m.circuitPeers, circuit)
+		}
+		m.mtx.Unlock()
+	}}, nil
+}
+
+// failures returns the number of failed connection attempts within the failure
+// window.
+//
+// This function MUST be called with the monitor lock held.
+func (m *proxyHealthMonitor) failures() int {
+	var failures int
+	for _, ok := range m.results {
+		if !ok {
+			failures++
+		}
+	}
+	return failures
+}
+
+// requestRefresh asks the monitor goroutine to request new circuits for the
+// passed reason.  It does nothing when there is no Tor control port or a
+// request is already pending.
+//
+// This function MUST be called with the monitor lock held.
+func (m *proxyHealthMonitor) requestRefresh(reason string) {
+	if m.refresh == nil {
+		return
+	}
+	select {
+	case m.refreshReq <- reason:
+	default:
+	}
+}
+
+// refreshCircuits requests new circuits unless circuits were refreshed too
+// recently.  On success, a new circuit generation is started and the failure
+// window is reset.
+func (m *proxyHealthMonitor) refreshCircuits(reason string) {
+	m.mtx.Lock()
+	if time.Since(m.lastRefresh) < minCircuitRefreshInterval {
+		m.mtx.Unlock()
+		return
+	}
+	m.lastRefresh = time.Now()
+	m.mtx.Unlock()
+
+	m.log.Infof("Requesting new tor circuits: %s", reason)
+	if err := m.refresh(); err != nil {
+		m.log.Warnf("Unable to request new tor circuits: %v", err)
+		return
+	}
+
+	m.mtx.Lock()
+	m.circuit++
+	m.results = m.results[:0]
+	m.mtx.Unlock()
+}
+
+// checkProxy checks whether the proxy is reachable and records a warning while
+// it is not.
+func (m *proxyHealthMonitor) checkProxy() {
+	err := m.ping()
+
+	m.mtx.Lock()
+	wasHealthy := m.healthy
+	m.healthy = err == nil
+	m.mtx.Unlock()
+
+	switch {
+	case err != nil:
+		if wasHealthy {
+			m.log.Warnf("Proxy %s is unreachable: %v", m.proxyAddr,
+				err)
+		}
+		m.warnings.setWarning(proxyHealthWarning, fmt.Sprintf("proxy "+
+			"%s is unreachable: %v", m.proxyAddr, err))
+
+	case !wasHealthy:
+		m.log.Infof("Proxy %s is reachable again", m.proxyAddr)
+		m.warnings.clearWarning(proxyHealthWarning)
+	}
+}
+
+// CircuitPeers returns the number of outbound peers connected through the
+// proxy keyed by circuit generation.
+//
+// This function is safe for concurrent access.
+func (m *proxyHealthMonitor) CircuitPeers() map[uint32]int {
+	m.mtx.Lock()
+	peers := make(map[uint32]int, len(m.circuitPeers))
+	for circuit, n := range m.circuitPeers {
+		peers[circuit] = n
+	}
+	m.mtx.Unlock()
+	return peers
+}
+
+// Run periodically checks the health of the proxy and handles requests for new
+// circuits until the passed quit channel is closed.  It must be run as a
+// goroutine.
+func (m *proxyHealthMonitor) Run(quit <-chan struct{}) {
+	ticker := time.NewTicker(proxyHealthCheckInterval)
+	defer ticker.Stop()
+
+	m.checkProxy()
+	for {
+		select {
+		case <-ticker.C:
+			m.checkProxy()
+			m.log.Debugf("Outbound peers per tor circuit: %v",
+				m.CircuitPeers())
+
+		case reason := <-m.refreshReq:
+			m.refreshCircuits(reason)
+
+		case <-quit:
+			return
+		}
+	}
+}
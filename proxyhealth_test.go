@@ -0,0 +1,215 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btclog"
+)
+
+// newTestProxyHealthMonitor returns a proxy health monitor suitable for tests
+// which dials using the passed function and counts circuit refreshes.
+func newTestProxyHealthMonitor(isolation bool,
+	dial func(net.Addr) (net.Conn, error)) (*proxyHealthMonitor, *int) {
+
+	var refreshes int
+	viaProxy := func(addr net.Addr) bool {
+		return !strings.Contains(addr.String(), ".onion:")
+	}
+	refresh := func() error {
+		refreshes++
+		return nil
+	}
+	m := newProxyHealthMonitor("127.0.0.1:9050", isolation, dial, viaProxy,
+		refresh, newSupervisor(nil))
+	m.log = btclog.Disabled
+	m.warnings.log = btclog.Disabled
+	return m, &refreshes
+}
+
+// handleRefresh services a pending circuit refresh request, if any, and
+// returns whether there was one.
+func handleRefresh(m *proxyHealthMonitor) bool {
+	select {
+	case reason := <-m.refreshReq:
+		m.refreshCircuits(reason)
+		return true
+	default:
+		return false
+	}
+}
+
+// TestProxyHealthFailureRefresh ensures new circuits are requested once
+// connection failures through the proxy spike and that refreshes are rate
+// limited.
+func TestProxyHealthFailureRefresh(t *testing.T) {
+	t.Parallel()
+
+	dialErr := errors.New("general SOCKS server failure")
+	m, refreshes := newTestProxyHealthMonitor(true,
+		func(net.Addr) (net.Conn, error) {
+			return nil, dialErr
+		})
+
+	// Failed connections to addresses which are not dialed through the
+	// proxy must not be counted.
+	for i := 0; i < proxyFailureWindow; i++ {
+		m.Dial(&onionAddrForTest{"abcdefghijklmnop.onion:8333"})
+	}
+	if handleRefresh(m) {
+		t.Fatal("unexpected refresh request for failed onion dials")
+	}
+
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8333}
+	for i := 0; i < proxyFailureThreshold-1; i++ {
+		if _, err := m.Dial(addr); err != dialErr {
+			t.Fatalf("unexpected dial error: %v", err)
+		}
+	}
+	if handleRefresh(m) {
+		t.Fatal("unexpected refresh request below failure threshold")
+	}
+
+	m.Dial(addr)
+	if !handleRefresh(m) {
+		t.Fatal("no refresh request once failure threshold reached")
+	}
+	if *refreshes != 1 {
+		t.Fatalf("unexpected number of refreshes - got %d, want 1",
+			*refreshes)
+	}
+	if m.circuit != 1 || len(m.results) != 0 {
+		t.Fatalf("refresh did not start a new circuit generation - "+
+			"circuit %d, results %d", m.circuit, len(m.results))
+	}
+
+	// Another spike right away must not request new circuits again.
+	for i := 0; i < proxyFailureThreshold; i++ {
+		m.Dial(addr)
+	}
+	if !handleRefresh(m) {
+		t.Fatal("no refresh request once failure threshold reached")
+	}
+	if *refreshes != 1 {
+		t.Fatalf("refresh was not rate limited - got %d refreshes, "+
+			"want 1", *refreshes)
+	}
+}
+
+// onionAddrForTest is a net.Addr for .onion addresses used in tests.
+type onionAddrForTest struct {
+	addr string
+}
+
+// Network returns the network of the address.
+func (a *onionAddrForTest) Network() string { return "tcp" }
+
+// String returns the address.
+func (a *onionAddrForTest) String() string { return a.addr }
+
+// TestProxyHealthCircuitDiversity ensures the number of peers per circuit
+// generation is tracked, that connections are released from their circuit
+// when closed, and that new circuits are requested once too many peers share
+// a circuit without stream isolation.
+func TestProxyHealthCircuitDiversity(t *testing.T) {
+	t.Parallel()
+
+	var conns []net.Conn
+	dial := func(net.Addr) (net.Conn, error) {
+		c1, c2 := net.Pipe()
+		conns = append(conns, c2)
+		return c1, nil
+	}
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8333}
+	for _, isolation := range []bool{true, false} {
+		m, refreshes := newTestProxyHealthMonitor(isolation, dial)
+
+		var peers []net.Conn
+		for i := 0; i < maxOutboundPeersPerCircuit; i++ {
+			conn, err := m.Dial(addr)
+			if err != nil {
+				t.Fatalf("unexpected dial error: %v", err)
+			}
+			peers = append(peers, conn)
+		}
+		if got := m.CircuitPeers()[0]; got != maxOutboundPeersPerCircuit {
+			t.Fatalf("unexpected circuit peers - got %d, want %d",
+				got, maxOutboundPeersPerCircuit)
+		}
+
+		refreshed := handleRefresh(m)
+		if refreshed == isolation {
+			t.Fatalf("unexpected refresh request with isolation %v",
+				isolation)
+		}
+
+		if !isolation {
+			if *refreshes != 1 {
+				t.Fatalf("unexpected number of refreshes - "+
+					"got %d, want 1", *refreshes)
+			}
+			conn, err := m.Dial(addr)
+			if err != nil {
+				t.Fatalf("unexpected dial error: %v", err)
+			}
+			peers = append(peers, conn)
+			if got := m.CircuitPeers()[1]; got != 1 {
+				t.Fatalf("unexpected peers on new circuit - "+
+					"got %d, want 1", got)
+			}
+		}
+
+		// Closing a connection more than once must only release it
+		// from its circuit once.
+		peers[0].Close()
+		peers[0].Close()
+		if got := m.CircuitPeers()[0]; got != maxOutboundPeersPerCircuit-1 {
+			t.Fatalf("unexpected circuit peers after close - got "+
+				"%d, want %d", got, maxOutboundPeersPerCircuit-1)
+		}
+		for _, conn := range peers[1:] {
+			conn.Close()
+		}
+		if peers := m.CircuitPeers(); len(peers) != 0 {
+			t.Fatalf("unexpected circuit peers after closing all "+
+				"connections: %v", peers)
+		}
+	}
+}
+
+// TestProxyHealthWarning ensures an unreachable proxy is reported as a warning
+// which is cleared once the proxy is reachable again.
+func TestProxyHealthWarning(t *testing.T) {
+	t.Parallel()
+
+	m, _ := newTestProxyHealthMonitor(false, nil)
+	pingErr := errors.New("connection refused")
+	m.ping = func() error { return pingErr }
+
+	m.checkProxy()
+	if warnings := m.warnings.Warnings(); !strings.Contains(warnings,
+		"127.0.0.1:9050 is unreachable") {
+
+		t.Fatalf("unexpected warnings for unreachable proxy: %q",
+			warnings)
+	}
+
+	pingErr = nil
+	m.checkProxy()
+	if warnings := m.warnings.Warnings(); warnings != "" {
+		t.Fatalf("unexpected warnings for reachable proxy: %q",
+			warnings)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+const (
+	// defaultRawBlockWindow is the default number of blocks a fetchblocksraw
+	// stream sends before the client must acknowledge them.
+	defaultRawBlockWindow = 16
+
+	// maxRawBlockWindow is the maximum number of blocks a fetchblocksraw
+	// stream sends before the client must acknowledge them.
+	maxRawBlockWindow = 1024
+
+	// rawBlockFrameHeaderLen is the length of the header of each binary
+	// frame sent by a fetchblocksraw stream, which is the height of the
+	// block as a little-endian uint32.
+	rawBlockFrameHeaderLen = 4
+
+	// prevBlockOffset is the offset of the previous block hash within a
+	// serialized block, which follows the 4-byte block version.
+	prevBlockOffset = 4
+)
+
+// errRawBlockStreamStopped is returned when a raw block stream is stopped
+// before all of the blocks were sent.
+var errRawBlockStreamStopped = errors.New("raw block stream stopped")
+
+// rawBlockStream streams a range of serialized main chain blocks to a client as
+// binary frames, each consisting of the height of the block as a little-endian
+// uint32 followed by the block exactly as it is stored in the block database.
+// This avoids the overhead of decoding the blocks and hex encoding them in JSON
+// replies when bulk-syncing an indexer from a local node.
+//
+// At most window frames are outstanding before the client acknowledges them,
+// so slow clients do not make the server buffer an arbitrary number of blocks.
+// The hashes of the blocks are looked up as they are sent, so the stream is
+// aborted should the main chain be reorganized in a way that the next block no
+// longer extends the previous one.
+type rawBlockStream struct {
+	startHeight int32
+	count       int32
+	window      int32
+	fetchBlock  func(height int32) (*chainhash.Hash, []byte, error)
+	send        func(frame []byte) error
+
+	mtx   sync.Mutex
+	sent  int32
+	acked int32
+
+	// signal is notified when blocks are acknowledged.
+	signal chan struct{}
+	quit   chan struct{}
+	stop   sync.Once
+}
+
+// newRawBlockStream returns a raw block stream which sends count blocks
+// starting at the passed height.  The passed fetchBlock function returns the
+// hash and serialized bytes of the main chain block at a height and the send
+// function sends a binary frame to the client.
+func newRawBlockStream(startHeight, count, window int32,
+	fetchBlock func(height int32) (*chainhash.Hash, []byte, error),
+	send func(frame []byte) error) *rawBlockStream {
+
+	return &rawBlockStream{
+		startHeight: startHeight,
+		count:       count,
+		window:      window,
+		fetchBlock:  fetchBlock,
+		send:        send,
+		signal:      make(chan struct{}, 1),
+		quit:        make(chan struct{}),
+	}
+}
+
+// Ack acknowledges the frames of all blocks up to and including the passed
+// height, which allows more blocks to be sent.  An error is returned when the
+// block at the height was not sent yet.
+func (s *rawBlockStream) Ack(height int32) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if height < s.startHeight || height >= s.startHeight+s.sent {
+		return fmt.Errorf("block at height %d was not sent", height)
+	}
+	if acked := height - s.startHeight + 1; acked > s.acked {
+		s.acked = acked
+		select {
+		case s.signal <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Stop stops the stream.  It is safe to call multiple times.
+func (s *rawBlockStream) Stop() {
+	s.stop.Do(func() {
+		close(s.quit)
+	})
+}
+
+// waitForWindow blocks until the window allows another frame to be sent.
+func (s *rawBlockStream) waitForWindow(quit <-chan struct{}) error {
+	for {
+		s.mtx.Lock()
+		unacked := s.sent - s.acked
+		s.mtx.Unlock()
+		if unacked < s.window {
+			return nil
+		}
+
+		select {
+		case <-s.signal:
+		case <-s.quit:
+			return errRawBlockStreamStopped
+		case <-quit:
+			return errRawBlockStreamStopped
+		}
+	}
+}
+
+// Run sends the blocks of the stream until they have all been sent, the stream
+// is stopped, or the passed quit channel is closed.  It returns the number of
+// blocks sent along with errRawBlockStreamStopped when the stream was stopped,
+// or the error which caused the stream to be aborted.  It may only be called
+// once.
+func (s *rawBlockStream) Run(quit <-chan struct{}) (int32, error) {
+	var prevHash *chainhash.Hash
+	for i := int32(0); i < s.count; i++ {
+		if err := s.waitForWindow(quit); err != nil {
+			return i, err
+		}
+
+		height := s.startHeight + i
+		hash, blkBytes, err := s.fetchBlock(height)
+		if err != nil {
+			return i, err
+		}
+		if len(blkBytes) < prevBlockOffset+chainhash.HashSize {
+			return i, fmt.Errorf("block %v is malformed", hash)
+		}
+		if prevHash != nil && !prevHash.IsEqual((*chainhash.Hash)(
+			blkBytes[prevBlockOffset:prevBlockOffset+chainhash.HashSize])) {
+
+			return i, fmt.Errorf("block %v at height %d does not "+
+				"extend the previous block %v -- the main chain "+
+				"was reorganized", hash, height, prevHash)
+		}
+
+		frame := make([]byte, rawBlockFrameHeaderLen+len(blkBytes))
+		binary.LittleEndian.PutUint32(frame, uint32(height))
+		copy(frame[rawBlockFrameHeaderLen:], blkBytes)
+
+		// The block is counted as sent before the frame is sent since
+		// the client may acknowledge it before the send returns.
+		s.mtx.Lock()
+		s.sent++
+		s.mtx.Unlock()
+		if err := s.send(frame); err != nil {
+			s.mtx.Lock()
+			s.sent--
+			s.mtx.Unlock()
+			return i, err
+		}
+		prevHash = hash
+	}
+	return s.count, nil
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// rawBlockChain returns the serialized blocks of a chain with the passed
+// number of blocks, indexed by height, along with their hashes.
+func rawBlockChain(t *testing.T, n int) ([][]byte, []chainhash.Hash) {
+	blocks := make([][]byte, 0, n)
+	hashes := make([]chainhash.Hash, 0, n)
+	var prev chainhash.Hash
+	for i := 0; i < n; i++ {
+		block := wire.MsgBlock{Header: wire.BlockHeader{
+			PrevBlock: prev,
+			Nonce:     uint32(i),
+		}}
+		var buf bytes.Buffer
+		if err := block.Serialize(&buf); err != nil {
+			t.Fatalf("Serialize: unexpected error: %v", err)
+		}
+		prev = block.BlockHash()
+		blocks = append(blocks, buf.Bytes())
+		hashes = append(hashes, prev)
+	}
+	return blocks, hashes
+}
+
+// runRawBlockStream runs the passed stream in a goroutine and returns the
+// channel its result is delivered on.
+func runRawBlockStream(s *rawBlockStream, quit chan struct{}) chan error {
+	result := make(chan error, 1)
+	go func() {
+		_, err := s.Run(quit)
+		result <- err
+	}()
+	return result
+}
+
+// expectRawBlockFrame ensures the next frame sent by a stream is for the block
+// at the passed height.
+func expectRawBlockFrame(t *testing.T, frames chan []byte, blocks [][]byte,
+	height int32) {
+
+	select {
+	case frame := <-frames:
+		gotHeight := int32(binary.LittleEndian.Uint32(frame))
+		if gotHeight != height {
+			t.Fatalf("got frame for height %d, want %d", gotHeight,
+				height)
+		}
+		if !bytes.Equal(frame[rawBlockFrameHeaderLen:], blocks[height]) {
+			t.Fatalf("frame for height %d does not hold the block",
+				height)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timeout waiting for frame for height %d", height)
+	}
+}
+
+// expectNoRawBlockFrame ensures a stream does not send another frame.
+func expectNoRawBlockFrame(t *testing.T, frames chan []byte) {
+	select {
+	case frame := <-frames:
+		t.Fatalf("got unexpected frame for height %d",
+			binary.LittleEndian.Uint32(frame))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestRawBlockStream ensures a raw block stream sends the requested blocks as
+// frames while respecting its window, and that it is aborted when the main
+// chain is reorganized or it is stopped.
+func TestRawBlockStream(t *testing.T) {
+	t.Parallel()
+
+	blocks, hashes := rawBlockChain(t, 6)
+	fetchBlock := func(height int32) (*chainhash.Hash, []byte, error) {
+		if int(height) >= len(blocks) {
+			return nil, nil, errors.New("no block at height")
+		}
+		return &hashes[height], blocks[height], nil
+	}
+	frames := make(chan []byte, len(blocks))
+	send := func(frame []byte) error {
+		frames <- frame
+		return nil
+	}
+
+	// Only the window of blocks is sent until they are acknowledged.
+	quit := make(chan struct{})
+	defer close(quit)
+	s := newRawBlockStream(1, 4, 2, fetchBlock, send)
+	result := runRawBlockStream(s, quit)
+	expectRawBlockFrame(t, frames, blocks, 1)
+	expectRawBlockFrame(t, frames, blocks, 2)
+	expectNoRawBlockFrame(t, frames)
+	if err := s.Ack(3); err == nil {
+		t.Fatal("Ack: expected error for block which was not sent")
+	}
+	if err := s.Ack(0); err == nil {
+		t.Fatal("Ack: expected error for block before the stream")
+	}
+	if err := s.Ack(1); err != nil {
+		t.Fatalf("Ack: unexpected error: %v", err)
+	}
+	expectRawBlockFrame(t, frames, blocks, 3)
+	expectNoRawBlockFrame(t, frames)
+	if err := s.Ack(3); err != nil {
+		t.Fatalf("Ack: unexpected error: %v", err)
+	}
+	expectRawBlockFrame(t, frames, blocks, 4)
+	if err := <-result; err != nil {
+		t.Fatalf("Run: unexpected error: %v", err)
+	}
+	expectNoRawBlockFrame(t, frames)
+
+	// The stream is aborted when the next block does not extend the
+	// previous one.
+	reorged := append([][]byte(nil), blocks...)
+	reorged[3] = append([]byte(nil), blocks[3]...)
+	reorged[3][prevBlockOffset] ^= 0xff
+	fetchReorged := func(height int32) (*chainhash.Hash, []byte, error) {
+		return &hashes[height], reorged[height], nil
+	}
+	s = newRawBlockStream(1, 4, 4, fetchReorged, send)
+	sent, err := s.Run(quit)
+	if err == nil || sent != 2 {
+		t.Fatalf("Run: got %d blocks sent, error %v, want 2 blocks "+
+			"and an error", sent, err)
+	}
+	expectRawBlockFrame(t, frames, blocks, 1)
+	expectRawBlockFrame(t, frames, blocks, 2)
+	expectNoRawBlockFrame(t, frames)
+
+	// Blocks acknowledged before the send of their frame returns allow the
+	// next block to be sent.
+	var early *rawBlockStream
+	sendAcked := func(frame []byte) error {
+		height := int32(binary.LittleEndian.Uint32(frame))
+		if err := early.Ack(height); err != nil {
+			return err
+		}
+		return send(frame)
+	}
+	early = newRawBlockStream(0, 3, 1, fetchBlock, sendAcked)
+	if sent, err := early.Run(quit); err != nil || sent != 3 {
+		t.Fatalf("Run: got %d blocks sent, error %v, want 3 blocks",
+			sent, err)
+	}
+	for height := int32(0); height < 3; height++ {
+		expectRawBlockFrame(t, frames, blocks, height)
+	}
+
+	// A failed send is not counted as sent.
+	sendErr := errors.New("send failed")
+	s = newRawBlockStream(0, 3, 1, fetchBlock, func([]byte) error {
+		return sendErr
+	})
+	if sent, err := s.Run(quit); err != sendErr || sent != 0 {
+		t.Fatalf("Run: got %d blocks sent, error %v, want 0 blocks "+
+			"and error %v", sent, err, sendErr)
+	}
+	if err := s.Ack(0); err == nil {
+		t.Fatal("Ack: expected error for block which failed to send")
+	}
+
+	// A stream waiting for acknowledgements is stopped.
+	s = newRawBlockStream(0, 6, 1, fetchBlock, send)
+	result = runRawBlockStream(s, quit)
+	expectRawBlockFrame(t, frames, blocks, 0)
+	s.Stop()
+	s.Stop()
+	if err := <-result; err != errRawBlockStreamStopped {
+		t.Fatalf("Run: got error %v, want %v", err,
+			errRawBlockStreamStopped)
+	}
+}
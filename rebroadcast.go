@@ -0,0 +1,82 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// rebroadcastEntry houses a locally submitted inventory along with the details
+// of its broadcasts.
+type rebroadcastEntry struct {
+	data          interface{}
+	added         time.Time
+	lastBroadcast time.Time
+	broadcasts    int
+}
+
+// rebroadcastSet tracks the locally submitted inventories which are announced
+// again periodically, in case peers restarted or otherwise dropped them, until
+// they make it into a block or are removed from the memory pool.
+//
+// The rebroadcast set is not safe for concurrent access.  It is only used by
+// the rebroadcast handler.
+type rebroadcastSet map[wire.InvVect]*rebroadcastEntry
+
+// Add adds the passed inventory, which was broadcast for the first time at the
+// passed time, to the set.  Inventories which are already tracked are not
+// modified.
+func (s rebroadcastSet) Add(iv *wire.InvVect, data interface{}, now time.Time) {
+	if _, ok := s[*iv]; ok {
+		return
+	}
+	s[*iv] = &rebroadcastEntry{
+		data:          data,
+		added:         now,
+		lastBroadcast: now,
+		broadcasts:    1,
+	}
+}
+
+// Remove removes the passed inventory from the set if present.
+func (s rebroadcastSet) Remove(iv *wire.InvVect) {
+	delete(s, *iv)
+}
+
+// Rebroadcast invokes the passed function with each inventory in the set and
+// records the broadcast.
+func (s rebroadcastSet) Rebroadcast(now time.Time, relay func(iv *wire.InvVect, data interface{})) {
+	for iv, entry := range s {
+		ivCopy := iv
+		relay(&ivCopy, entry.data)
+		entry.lastBroadcast = now
+		entry.broadcasts++
+	}
+}
+
+// Results returns the inventories in the set as RPC results sorted by the time
+// they were added.
+func (s rebroadcastSet) Results() []btcjson.RebroadcastTxResult {
+	results := make([]btcjson.RebroadcastTxResult, 0, len(s))
+	for iv, entry := range s {
+		results = append(results, btcjson.RebroadcastTxResult{
+			TxID:          iv.Hash.String(),
+			Added:         entry.added.Unix(),
+			LastBroadcast: entry.lastBroadcast.Unix(),
+			Broadcasts:    entry.broadcasts,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Added != results[j].Added {
+			return results[i].Added < results[j].Added
+		}
+		return results[i].TxID < results[j].TxID
+	})
+	return results
+}
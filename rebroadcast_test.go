@@ -0,0 +1,70 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestRebroadcastSet ensures locally submitted inventories are tracked until
+// they are removed and the details of their broadcasts are recorded.
+func TestRebroadcastSet(t *testing.T) {
+	t.Parallel()
+
+	set := make(rebroadcastSet)
+	now := time.Unix(1500000000, 0)
+	iv1 := wire.NewInvVect(wire.InvTypeTx, &chainhash.Hash{0x01})
+	iv2 := wire.NewInvVect(wire.InvTypeTx, &chainhash.Hash{0x02})
+	set.Add(iv2, "tx2", now.Add(time.Second))
+	set.Add(iv1, "tx1", now)
+
+	// Adding a tracked inventory again does not modify it.
+	set.Add(iv1, "other", now.Add(time.Hour))
+	if set[*iv1].data != "tx1" || !set[*iv1].added.Equal(now) {
+		t.Fatalf("tracked inventory was modified: %+v", set[*iv1])
+	}
+
+	relayed := make(map[wire.InvVect]interface{})
+	later := now.Add(10 * time.Minute)
+	set.Rebroadcast(later, func(iv *wire.InvVect, data interface{}) {
+		relayed[*iv] = data
+	})
+	wantRelayed := map[wire.InvVect]interface{}{*iv1: "tx1", *iv2: "tx2"}
+	if !reflect.DeepEqual(relayed, wantRelayed) {
+		t.Fatalf("unexpected relayed inventory: got %v, want %v",
+			relayed, wantRelayed)
+	}
+
+	want := []btcjson.RebroadcastTxResult{
+		{
+			TxID:          iv1.Hash.String(),
+			Added:         now.Unix(),
+			LastBroadcast: later.Unix(),
+			Broadcasts:    2,
+		},
+		{
+			TxID:          iv2.Hash.String(),
+			Added:         now.Add(time.Second).Unix(),
+			LastBroadcast: later.Unix(),
+			Broadcasts:    2,
+		},
+	}
+	if got := set.Results(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected results: got %+v, want %+v", got, want)
+	}
+
+	set.Remove(iv1)
+	set.Remove(iv1)
+	if got := set.Results(); !reflect.DeepEqual(got, want[1:]) {
+		t.Fatalf("unexpected results after removal: got %+v, want %+v",
+			got, want[1:])
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// recoveryPeerTimeout is the maximum amount of time to wait for the handshake
+// with a recovery peer and for each of its responses to a request for headers.
+const recoveryPeerTimeout = time.Minute
+
+// errRecoveryInterrupted is returned when an interrupt is requested while the
+// headers are being fetched from the recovery peers.
+var errRecoveryInterrupted = errors.New("interrupted while fetching headers " +
+	"from the recovery peers")
+
+// recoveryChain is the subset of the chain functionality needed to validate a
+// header chain fetched from the recovery peers and orient it relative to the
+// local chain.  It is satisfied by *blockchain.BlockChain.
+type recoveryChain interface {
+	CheckHeaders(headers []wire.BlockHeader) error
+	MainChainHasBlock(hash *chainhash.Hash) bool
+	BlockHeightByHash(hash *chainhash.Hash) (int32, error)
+	ChainWork(hash *chainhash.Hash) (*big.Int, error)
+}
+
+// recoveryHeaders is the best header chain reported by the recovery peers
+// oriented relative to the local chain.  It is used to tell whether the blocks
+// left by a recovery of the block database are on the best chain or on a stale
+// fork.
+type recoveryHeaders struct {
+	// forkHash and forkHeight identify the last block of the local main
+	// chain which is also in the header chain.
+	forkHash   chainhash.Hash
+	forkHeight int32
+
+	// tipHash and tipHeight identify the last header of the chain.
+	tipHash   chainhash.Hash
+	tipHeight int32
+
+	// work is the total amount of work in the header chain.
+	work *big.Int
+
+	// heights maps the headers after the fork point to their heights.
+	heights map[chainhash.Hash]int32
+}
+
+// OnChain returns whether the block with the passed hash is in the header
+// chain after the fork point or is the fork point itself.
+func (h *recoveryHeaders) OnChain(hash *chainhash.Hash) bool {
+	if *hash == h.forkHash {
+		return true
+	}
+	_, ok := h.heights[*hash]
+	return ok
+}
+
+// orientRecoveryHeaders validates the passed headers, which must connect to
+// each other, and returns them oriented relative to the local chain.  The
+// headers the local main chain already contains are skipped, so the first
+// remaining header must extend a block in the local main chain.  The remaining
+// headers are checked in the context of the local chain, so their difficulty,
+// timestamps, and the checkpoints are enforced as well as their proof of work.
+func orientRecoveryHeaders(chain recoveryChain,
+	headers []wire.BlockHeader) (*recoveryHeaders, error) {
+
+	// Skip the headers which are already in the local main chain.  This
+	// happens when the peer does not know about the most recent blocks
+	// in the passed locator.
+	var i int
+	for ; i < len(headers); i++ {
+		hash := headers[i].BlockHash()
+		if !chain.MainChainHasBlock(&hash) {
+			break
+		}
+	}
+	headers = headers[i:]
+	if len(headers) == 0 {
+		return nil, nil
+	}
+
+	forkHash := headers[0].PrevBlock
+	if !chain.MainChainHasBlock(&forkHash) {
+		return nil, fmt.Errorf("header %v does not connect to the "+
+			"local main chain", headers[0].BlockHash())
+	}
+	forkHeight, err := chain.BlockHeightByHash(&forkHash)
+	if err != nil {
+		return nil, err
+	}
+	work, err := chain.ChainWork(&forkHash)
+	if err != nil {
+		return nil, err
+	}
+	if err := chain.CheckHeaders(headers); err != nil {
+		return nil, fmt.Errorf("invalid header chain: %v", err)
+	}
+
+	h := &recoveryHeaders{
+		forkHash:   forkHash,
+		forkHeight: forkHeight,
+		tipHash:    forkHash,
+		tipHeight:  forkHeight,
+		work:       work,
+		heights:    make(map[chainhash.Hash]int32, len(headers)),
+	}
+	for i := range headers {
+		header := &headers[i]
+		if header.PrevBlock != h.tipHash {
+			return nil, fmt.Errorf("header %v does not connect to "+
+				"the previous header %v", header.BlockHash(),
+				h.tipHash)
+		}
+
+		h.tipHash = header.BlockHash()
+		h.tipHeight++
+		h.heights[h.tipHash] = h.tipHeight
+		h.work.Add(h.work, blockchain.CalcWork(header.Bits))
+	}
+	return h, nil
+}
+
+// maxRecoveryHeaders returns the maximum number of headers accepted from a
+// recovery peer given the median time of the local best block.  It allows
+// twice the number of blocks expected to have been mined since then, plus a
+// full message of headers since the peer may start from a fork point before
+// the local best block.
+func maxRecoveryHeaders(medianTime, now time.Time, params *chaincfg.Params) int {
+	var expected int
+	if elapsed := now.Sub(medianTime); elapsed > 0 {
+		expected = int(elapsed / params.TargetTimePerBlock)
+	}
+	return 2*expected + wire.MaxBlockHeadersPerMsg
+}
+
+// fetchPeerHeaders connects to the peer with the passed address and returns
+// the headers it has after the blocks in the passed locator.  An error is
+// returned when the peer sends more than the passed maximum number of headers.
+func fetchPeerHeaders(addr string, locator blockchain.BlockLocator,
+	maxHeaders int, interrupt <-chan struct{}) ([]wire.BlockHeader, error) {
+
+	// The listeners must not block the peer once the headers are no
+	// longer being waited for, so unexpected messages are dropped.
+	verack := make(chan struct{}, 1)
+	headersChan := make(chan *wire.MsgHeaders, 1)
+	peerCfg := &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				select {
+				case verack <- struct{}{}:
+				default:
+				}
+			},
+			OnHeaders: func(p *peer.Peer, msg *wire.MsgHeaders) {
+				select {
+				case headersChan <- msg:
+				default:
+				}
+			},
+		},
+		Proxy:             cfg.Proxy,
+		UserAgentName:     userAgentName,
+		UserAgentVersion:  userAgentVersion,
+		UserAgentComments: cfg.UserAgentComments,
+		ChainParams:       activeNetParams.Params,
+		DisableRelayTx:    true,
+		ProtocolVersion:   peer.MaxProtocolVersion,
+	}
+	p, err := peer.NewOutboundPeer(peerCfg, addr)
+	if err != nil {
+		return nil, err
+	}
+	netAddr, err := addrStringToNetAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := btcdDial(netAddr)
+	if err != nil {
+		return nil, err
+	}
+	p.AssociateConnection(conn)
+	defer func() {
+		p.Disconnect()
+		p.WaitForDisconnect()
+	}()
+
+	select {
+	case <-verack:
+	case <-time.After(recoveryPeerTimeout):
+		return nil, errors.New("timeout waiting for the handshake")
+	case <-interrupt:
+		return nil, errRecoveryInterrupted
+	}
+
+	// Keep requesting the headers after the last one received until the
+	// peer sends less than the maximum number of headers per message.
+	var headers []wire.BlockHeader
+	for {
+		msg := wire.NewMsgGetHeaders()
+		for _, hash := range locator {
+			msg.AddBlockLocatorHash(hash)
+		}
+		p.QueueMessage(msg, nil)
+
+		var reply *wire.MsgHeaders
+		select {
+		case reply = <-headersChan:
+		case <-time.After(recoveryPeerTimeout):
+			return nil, errors.New("timeout waiting for headers")
+		case <-interrupt:
+			return nil, errRecoveryInterrupted
+		}
+		if len(headers)+len(reply.Headers) > maxHeaders {
+			return nil, fmt.Errorf("peer sent more than the %d "+
+				"headers expected after the local best block",
+				maxHeaders)
+		}
+		for _, header := range reply.Headers {
+			headers = append(headers, *header)
+		}
+		if len(reply.Headers) < wire.MaxBlockHeadersPerMsg {
+			return headers, nil
+		}
+		lastHash := headers[len(headers)-1].BlockHash()
+		locator = blockchain.BlockLocator{&lastHash}
+	}
+}
+
+// fetchRecoveryHeaders fetches the best header chain from each of the passed
+// peers and returns the one with the most work oriented relative to the local
+// chain.  The peers which can't be reached or send invalid headers are
+// skipped, but an error is returned when none of them provided usable
+// headers or when they disagree.  A nil header chain is returned when no peer
+// knows about any blocks after the local best block.
+func fetchRecoveryHeaders(chain *blockchain.BlockChain, addrs []string,
+	interrupt <-chan struct{}) (*recoveryHeaders, error) {
+
+	locator, err := chain.LatestBlockLocator()
+	if err != nil {
+		return nil, err
+	}
+	maxHeaders := maxRecoveryHeaders(chain.BestSnapshot().MedianTime,
+		time.Now(), activeNetParams.Params)
+
+	peerHeaders := make(map[string]*recoveryHeaders)
+	var numUsable int
+	for _, addr := range addrs {
+		btcdLog.Infof("Fetching the best header chain from recovery "+
+			"peer %s", addr)
+		headers, err := fetchPeerHeaders(addr, locator, maxHeaders,
+			interrupt)
+		if err == errRecoveryInterrupted {
+			return nil, err
+		}
+		if err != nil {
+			btcdLog.Warnf("Unable to fetch headers from recovery "+
+				"peer %s: %v", addr, err)
+			continue
+		}
+		oriented, err := orientRecoveryHeaders(chain, headers)
+		if err != nil {
+			btcdLog.Warnf("Ignoring headers from recovery peer %s: "+
+				"%v", addr, err)
+			continue
+		}
+		numUsable++
+		if oriented == nil {
+			btcdLog.Infof("Recovery peer %s has no blocks after the "+
+				"local best block", addr)
+			continue
+		}
+		btcdLog.Infof("Recovery peer %s reported best header %v "+
+			"(height %d)", addr, oriented.tipHash, oriented.tipHeight)
+		peerHeaders[addr] = oriented
+	}
+	if numUsable == 0 {
+		return nil, errors.New("none of the recovery peers provided " +
+			"usable headers")
+	}
+	return agreedRecoveryHeaders(peerHeaders)
+}
+
+// agreedRecoveryHeaders returns the header chain with the most work from the
+// passed header chains reported by the recovery peers keyed by their address.
+// An error is returned unless the tips of all of the other chains are on it,
+// since the peers with shorter chains might simply be behind, but a peer
+// reporting a different fork means at least one of them can't be trusted.
+func agreedRecoveryHeaders(peerHeaders map[string]*recoveryHeaders) (*recoveryHeaders, error) {
+	var best *recoveryHeaders
+	var bestAddr string
+	for addr, headers := range peerHeaders {
+		if best == nil || headers.work.Cmp(best.work) > 0 {
+			best, bestAddr = headers, addr
+		}
+	}
+	for addr, headers := range peerHeaders {
+		if !best.OnChain(&headers.tipHash) {
+			return nil, fmt.Errorf("recovery peers %s and %s "+
+				"disagree on the best header chain", addr,
+				bestAddr)
+		}
+	}
+	return best, nil
+}
+
+// staleBlockFilter tracks the blocks which are on a stale fork of the header
+// chain fetched from the recovery peers while the stored blocks are
+// reprocessed.
+type staleBlockFilter struct {
+	headers *recoveryHeaders
+	stale   map[chainhash.Hash]struct{}
+}
+
+// newStaleBlockFilter returns a filter for the blocks which are on a stale fork
+// of the passed header chain.
+func newStaleBlockFilter(headers *recoveryHeaders) *staleBlockFilter {
+	return &staleBlockFilter{
+		headers: headers,
+		stale:   make(map[chainhash.Hash]struct{}),
+	}
+}
+
+// MarkStale marks the block with the passed hash as being on a stale fork, so
+// the blocks extending it are considered stale as well.
+func (f *staleBlockFilter) MarkStale(hash *chainhash.Hash) {
+	f.stale[*hash] = struct{}{}
+}
+
+// IsStale returns whether the passed block header is on a stale fork, that is,
+// it branches off the header chain after the fork point, or it extends a block
+// which does.  The blocks building on the tip of the header chain are not
+// considered stale since the peers might simply not know about them yet.
+func (f *staleBlockFilter) IsStale(header *wire.BlockHeader) bool {
+	if _, ok := f.stale[header.PrevBlock]; ok {
+		f.stale[header.BlockHash()] = struct{}{}
+		return true
+	}
+	hash := header.BlockHash()
+	if f.headers.OnChain(&hash) || header.PrevBlock == f.headers.tipHash ||
+		!f.headers.OnChain(&header.PrevBlock) {
+
+		return false
+	}
+	f.stale[hash] = struct{}{}
+	return true
+}
@@ -0,0 +1,275 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// fakeRecoveryChain is a recoveryChain which consists of a main chain of
+// headers.  It rejects the header chains containing one of the invalid headers.
+type fakeRecoveryChain struct {
+	heights map[chainhash.Hash]int32
+	work    map[chainhash.Hash]*big.Int
+	invalid map[chainhash.Hash]struct{}
+}
+
+// newFakeRecoveryChain returns a fake chain whose main chain consists of the
+// passed headers, the first of which is at height 0.
+func newFakeRecoveryChain(headers ...wire.BlockHeader) *fakeRecoveryChain {
+	c := &fakeRecoveryChain{
+		heights: make(map[chainhash.Hash]int32),
+		work:    make(map[chainhash.Hash]*big.Int),
+		invalid: make(map[chainhash.Hash]struct{}),
+	}
+	work := new(big.Int)
+	for i := range headers {
+		hash := headers[i].BlockHash()
+		work = new(big.Int).Add(work, blockchain.CalcWork(headers[i].Bits))
+		c.heights[hash] = int32(i)
+		c.work[hash] = work
+	}
+	return c
+}
+
+func (c *fakeRecoveryChain) CheckHeaders(headers []wire.BlockHeader) error {
+	for i := range headers {
+		if _, ok := c.invalid[headers[i].BlockHash()]; ok {
+			return errors.New("invalid header")
+		}
+	}
+	return nil
+}
+
+func (c *fakeRecoveryChain) MainChainHasBlock(hash *chainhash.Hash) bool {
+	_, ok := c.heights[*hash]
+	return ok
+}
+
+func (c *fakeRecoveryChain) BlockHeightByHash(hash *chainhash.Hash) (int32, error) {
+	height, ok := c.heights[*hash]
+	if !ok {
+		return 0, errors.New("block not in main chain")
+	}
+	return height, nil
+}
+
+func (c *fakeRecoveryChain) ChainWork(hash *chainhash.Hash) (*big.Int, error) {
+	work, ok := c.work[*hash]
+	if !ok {
+		return nil, errors.New("block not known")
+	}
+	return new(big.Int).Set(work), nil
+}
+
+// solveRecoveryHeader returns a header which extends the passed header and
+// satisfies the regression test proof of work.  The passed tag is used to
+// create distinct headers extending the same parent.
+func solveRecoveryHeader(prev *wire.BlockHeader, tag byte) wire.BlockHeader {
+	header := wire.BlockHeader{
+		Version:    1,
+		PrevBlock:  prev.BlockHash(),
+		MerkleRoot: chainhash.Hash{tag},
+		Timestamp:  prev.Timestamp.Add(time.Minute),
+		Bits:       chaincfg.RegressionNetParams.PowLimitBits,
+	}
+	target := blockchain.CompactToBig(header.Bits)
+	for {
+		hash := header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			return header
+		}
+		header.Nonce++
+	}
+}
+
+// TestOrientRecoveryHeaders ensures the headers fetched from the recovery peers
+// are validated and oriented relative to the local chain, and that the stored
+// blocks on a stale fork of them are detected.
+func TestOrientRecoveryHeaders(t *testing.T) {
+	t.Parallel()
+
+	// Local main chain: genesis -> a1 -> a2
+	// Peer header chain:            a1 -> b2 -> b3
+	params := &chaincfg.RegressionNetParams
+	genesis := params.GenesisBlock.Header
+	a1 := solveRecoveryHeader(&genesis, 'a')
+	a2 := solveRecoveryHeader(&a1, 'a')
+	b2 := solveRecoveryHeader(&a1, 'b')
+	b3 := solveRecoveryHeader(&b2, 'b')
+	chain := newFakeRecoveryChain(genesis, a1, a2)
+
+	// The headers already in the local main chain are skipped.
+	headers, err := orientRecoveryHeaders(chain,
+		[]wire.BlockHeader{a1, b2, b3})
+	if err != nil {
+		t.Fatalf("orientRecoveryHeaders: unexpected error: %v", err)
+	}
+	a1Hash, b3Hash := a1.BlockHash(), b3.BlockHash()
+	if headers.forkHash != a1Hash || headers.forkHeight != 1 {
+		t.Fatalf("orientRecoveryHeaders: got fork %v (height %d), "+
+			"want %v (height 1)", headers.forkHash,
+			headers.forkHeight, a1Hash)
+	}
+	if headers.tipHash != b3Hash || headers.tipHeight != 3 {
+		t.Fatalf("orientRecoveryHeaders: got tip %v (height %d), "+
+			"want %v (height 3)", headers.tipHash, headers.tipHeight,
+			b3Hash)
+	}
+	wantWork, _ := chain.ChainWork(&a1Hash)
+	wantWork.Add(wantWork, blockchain.CalcWork(b2.Bits))
+	wantWork.Add(wantWork, blockchain.CalcWork(b3.Bits))
+	if headers.work.Cmp(wantWork) != 0 {
+		t.Fatalf("orientRecoveryHeaders: got work %v, want %v",
+			headers.work, wantWork)
+	}
+
+	// No header chain is returned when the peer has nothing new.
+	headers2, err := orientRecoveryHeaders(chain,
+		[]wire.BlockHeader{a1, a2})
+	if err != nil || headers2 != nil {
+		t.Fatalf("orientRecoveryHeaders: got %v, %v for known headers",
+			headers2, err)
+	}
+
+	// Invalid header chains are rejected.
+	badHeader := solveRecoveryHeader(&b2, 'x')
+	chain.invalid[badHeader.BlockHash()] = struct{}{}
+	tests := []struct {
+		name    string
+		headers []wire.BlockHeader
+	}{
+		{"unconnected to main chain", []wire.BlockHeader{b3}},
+		{"unconnected headers", []wire.BlockHeader{b2, b2}},
+		{"invalid header", []wire.BlockHeader{b2, badHeader}},
+	}
+	for _, test := range tests {
+		_, err := orientRecoveryHeaders(chain, test.headers)
+		if err == nil {
+			t.Errorf("%s: orientRecoveryHeaders: expected error",
+				test.name)
+		}
+	}
+
+	// The local best block is on a stale fork, so the blocks extending it
+	// are stale, as are the blocks branching off the header chain after
+	// the fork point and their descendants.  The blocks on the header
+	// chain, extending its tip, or unrelated to it are not.
+	a2Hash := a2.BlockHash()
+	filter := newStaleBlockFilter(headers)
+	filter.MarkStale(&a2Hash)
+	a3 := solveRecoveryHeader(&a2, 'a')
+	b4 := solveRecoveryHeader(&b3, 'b')
+	c3 := solveRecoveryHeader(&b2, 'c')
+	c4 := solveRecoveryHeader(&c3, 'c')
+	d2 := solveRecoveryHeader(&a1, 'd')
+	orphan := solveRecoveryHeader(&wire.BlockHeader{Nonce: 1}, 'e')
+	staleTests := []struct {
+		name   string
+		header wire.BlockHeader
+		stale  bool
+	}{
+		{"on header chain", b2, false},
+		{"header chain tip", b3, false},
+		{"extends header chain tip", b4, false},
+		{"extends stale local block", a3, true},
+		{"branches off header chain", c3, true},
+		{"extends stale block", c4, true},
+		{"branches off at fork point", d2, true},
+		{"unrelated", orphan, false},
+	}
+	for _, test := range staleTests {
+		if got := filter.IsStale(&test.header); got != test.stale {
+			t.Errorf("%s: IsStale: got %v, want %v", test.name, got,
+				test.stale)
+		}
+	}
+}
+
+// TestAgreedRecoveryHeaders ensures the header chain with the most work is only
+// used when the chains reported by the other recovery peers are on it.
+func TestAgreedRecoveryHeaders(t *testing.T) {
+	t.Parallel()
+
+	// Local main chain: genesis -> a1
+	// Peer header chains:          a1 -> b2 -> b3
+	//                              a1 -> c2
+	params := &chaincfg.RegressionNetParams
+	genesis := params.GenesisBlock.Header
+	a1 := solveRecoveryHeader(&genesis, 'a')
+	b2 := solveRecoveryHeader(&a1, 'b')
+	b3 := solveRecoveryHeader(&b2, 'b')
+	c2 := solveRecoveryHeader(&a1, 'c')
+	chain := newFakeRecoveryChain(genesis, a1)
+	orient := func(headers ...wire.BlockHeader) *recoveryHeaders {
+		oriented, err := orientRecoveryHeaders(chain, headers)
+		if err != nil {
+			t.Fatalf("orientRecoveryHeaders: unexpected error: %v",
+				err)
+		}
+		return oriented
+	}
+	long, short, fork := orient(b2, b3), orient(b2), orient(c2)
+
+	// A peer which is behind agrees with the chain with the most work.
+	best, err := agreedRecoveryHeaders(map[string]*recoveryHeaders{
+		"peer1": short,
+		"peer2": long,
+	})
+	if err != nil || best != long {
+		t.Fatalf("agreedRecoveryHeaders: got %v, %v, want the longest "+
+			"chain", best, err)
+	}
+
+	// A peer reporting a different fork does not.
+	_, err = agreedRecoveryHeaders(map[string]*recoveryHeaders{
+		"peer1": long,
+		"peer2": fork,
+	})
+	if err == nil {
+		t.Fatal("agreedRecoveryHeaders: expected error for peers on " +
+			"different forks")
+	}
+
+	// No header chain is returned when no peer reported one.
+	best, err = agreedRecoveryHeaders(nil)
+	if err != nil || best != nil {
+		t.Fatalf("agreedRecoveryHeaders: got %v, %v, want no chain",
+			best, err)
+	}
+}
+
+// TestMaxRecoveryHeaders ensures the number of headers accepted from a recovery
+// peer is capped according to the time since the local best block.
+func TestMaxRecoveryHeaders(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.MainNetParams
+	now := time.Unix(1500000000, 0)
+	tests := []struct {
+		name       string
+		medianTime time.Time
+		want       int
+	}{
+		{"in the future", now.Add(time.Hour), wire.MaxBlockHeadersPerMsg},
+		{"now", now, wire.MaxBlockHeadersPerMsg},
+		{"one day ago", now.Add(-24 * time.Hour),
+			2*144 + wire.MaxBlockHeadersPerMsg},
+	}
+	for _, test := range tests {
+		got := maxRecoveryHeaders(test.medianTime, now, params)
+		if got != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, got, test.want)
+		}
+	}
+}
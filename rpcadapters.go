@@ -5,9 +5,12 @@
 package main
 
 import (
+	"net"
 	"sync/atomic"
+	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/netsync"
@@ -43,6 +46,15 @@ func (p *rpcPeer) IsTxRelayDisabled() bool {
 	return (*serverPeer)(p).disableRelayTx
 }
 
+// IsBlockRelayOnly returns whether or not the peer is an outbound
+// block-relay-only peer.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) IsBlockRelayOnly() bool {
+	return (*serverPeer)(p).blockRelayOnly
+}
+
 // BanScore returns the current integer value that represents how close the peer
 // is to being banned.
 //
@@ -111,6 +123,7 @@ func (cm *rpcConnManager) RemoveByID(id int32) error {
 func (cm *rpcConnManager) RemoveByAddr(addr string) error {
 	replyChan := make(chan error)
 	cm.server.query <- removeNodeMsg{
+		addr:  addr,
 		cmp:   func(sp *serverPeer) bool { return sp.Addr() == addr },
 		reply: replyChan,
 	}
@@ -164,6 +177,75 @@ func (cm *rpcConnManager) NetTotals() (uint64, uint64) {
 	return cm.server.NetTotals()
 }
 
+// UploadTarget returns the state of the upload target which limits the
+// outbound traffic.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) UploadTarget() btcjson.UploadTargetResult {
+	return cm.server.uploadTarget.Result(time.Now())
+}
+
+// Ban bans the passed IP address, subnet or host until the passed time and
+// disconnects the connected peers the ban applies to.  Attempting to ban a
+// target which is already banned will return an error.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) Ban(target *banTarget, until time.Time) error {
+	err := cm.server.banManager.Ban(target, time.Now(), until,
+		banReasonManual, false)
+	if err != nil {
+		return err
+	}
+
+	replyChan := make(chan []*serverPeer)
+	cm.server.query <- getPeersMsg{reply: replyChan}
+	for _, sp := range <-replyChan {
+		host, _, err := net.SplitHostPort(sp.Addr())
+		if err == nil && target.matches(host) {
+			srvrLog.Infof("Disconnecting banned peer %s", sp)
+			sp.Disconnect()
+		}
+	}
+	return nil
+}
+
+// Unban removes the ban of the passed IP address, subnet or host.  Attempting
+// to remove a ban which does not exist will return an error.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) Unban(target *banTarget) error {
+	return cm.server.banManager.Unban(target)
+}
+
+// ClearBans removes all bans.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) ClearBans() error {
+	return cm.server.banManager.Clear()
+}
+
+// Bans returns the bans which are in effect.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) Bans() []btcjson.ListBannedResult {
+	bans := cm.server.banManager.Bans(time.Now())
+	results := make([]btcjson.ListBannedResult, 0, len(bans))
+	for _, ban := range bans {
+		results = append(results, btcjson.ListBannedResult{
+			Address:     ban.Target,
+			BannedUntil: ban.Until,
+			BanCreated:  ban.Created,
+			BanReason:   ban.Reason,
+		})
+	}
+	return results
+}
+
 // ConnectedPeers returns an array consisting of all connected peers.
 //
 // This function is safe for concurrent access and is part of the
@@ -181,22 +263,26 @@ func (cm *rpcConnManager) ConnectedPeers() []rpcserverPeer {
 	return peers
 }
 
-// PersistentPeers returns an array consisting of all the added persistent
-// peers.
+// AddedNodes returns an array consisting of all the added nodes, including
+// the ones which are not connected, sorted by address.
 //
 // This function is safe for concurrent access and is part of the
 // rpcserverConnManager interface implementation.
-func (cm *rpcConnManager) PersistentPeers() []rpcserverPeer {
-	replyChan := make(chan []*serverPeer)
+func (cm *rpcConnManager) AddedNodes() []rpcAddedNode {
+	replyChan := make(chan []addedNodeInfo)
 	cm.server.query <- getAddedNodesMsg{reply: replyChan}
-	serverPeers := <-replyChan
+	addedNodes := <-replyChan
 
 	// Convert to generic peers.
-	peers := make([]rpcserverPeer, 0, len(serverPeers))
-	for _, sp := range serverPeers {
-		peers = append(peers, (*rpcPeer)(sp))
+	nodes := make([]rpcAddedNode, 0, len(addedNodes))
+	for _, node := range addedNodes {
+		rpcNode := rpcAddedNode{Addr: node.addr}
+		if node.peer != nil {
+			rpcNode.Peer = (*rpcPeer)(node.peer)
+		}
+		nodes = append(nodes, rpcNode)
 	}
-	return peers
+	return nodes
 }
 
 // BroadcastMessage sends the provided message to all currently connected peers.
@@ -207,6 +293,16 @@ func (cm *rpcConnManager) BroadcastMessage(msg wire.Message) {
 	cm.server.BroadcastMessage(msg)
 }
 
+// RebroadcastSet returns the locally submitted transactions which are
+// rebroadcast until they show up in a block or are removed from the memory
+// pool.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) RebroadcastSet() []btcjson.RebroadcastTxResult {
+	return cm.server.RebroadcastSet()
+}
+
 // AddRebroadcastInventory adds the provided inventory to the list of
 // inventories to be rebroadcast at random intervals until they show up in a
 // block.
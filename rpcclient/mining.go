@@ -61,6 +61,70 @@ func (c *Client) Generate(numBlocks uint32) ([]*chainhash.Hash, error) {
 	return c.GenerateAsync(numBlocks).Receive()
 }
 
+// GenerateToAddressAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GenerateToAddress for the blocking version and more details.
+func (c *Client) GenerateToAddressAsync(numBlocks uint32, address btcutil.Address) FutureGenerateResult {
+	cmd := btcjson.NewGenerateToAddressCmd(numBlocks, address.EncodeAddress())
+	return c.sendCmd(cmd)
+}
+
+// GenerateToAddress generates numBlocks blocks paying to the passed address and
+// returns their hashes.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GenerateToAddress(numBlocks uint32, address btcutil.Address) ([]*chainhash.Hash, error) {
+	return c.GenerateToAddressAsync(numBlocks, address).Receive()
+}
+
+// FutureGenerateBlockResult is a future promise to deliver the result of a
+// GenerateBlockAsync RPC invocation (or an applicable error).
+type FutureGenerateBlockResult chan *response
+
+// Receive waits for the response promised by the future and returns the hash
+// of the generated block.
+func (r FutureGenerateBlockResult) Receive() (*chainhash.Hash, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a generateblock result object.
+	var result btcjson.GenerateBlockResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return chainhash.NewHashFromStr(result.Hash)
+}
+
+// GenerateBlockAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GenerateBlock for the blocking version and more details.
+func (c *Client) GenerateBlockAsync(output btcutil.Address, transactions []string) FutureGenerateBlockResult {
+	var txs *[]string
+	if transactions != nil {
+		txs = &transactions
+	}
+	cmd := btcjson.NewGenerateBlockCmd(output.EncodeAddress(), txs)
+	return c.sendCmd(cmd)
+}
+
+// GenerateBlock generates a block paying to the passed address which includes
+// exactly the passed transactions in order and returns its hash.  Each of the
+// transactions is either the id of a transaction in the memory pool or a
+// hex-encoded raw transaction.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GenerateBlock(output btcutil.Address, transactions []string) (*chainhash.Hash, error) {
+	return c.GenerateBlockAsync(output, transactions).Receive()
+}
+
 // FutureGetGenerateResult is a future promise to deliver the result of a
 // GetGenerateAsync RPC invocation (or an applicable error).
 type FutureGetGenerateResult chan *response
@@ -14,7 +14,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
@@ -34,6 +33,7 @@ import (
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/database/ffldb"
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/mining/cpuminer"
@@ -58,6 +58,12 @@ const (
 	// is closed.
 	rpcAuthTimeoutSeconds = 10
 
+	// rpcIdleTimeout is the amount of time a connection to the RPC server
+	// is kept open between requests.  Keeping connections open allows
+	// clients which issue many requests to avoid the overhead of opening a
+	// connection for each of them.
+	rpcIdleTimeout = time.Minute * 2
+
 	// uint256Size is the number of bytes needed to represent an unsigned
 	// 256-bit integer.
 	uint256Size = 32
@@ -75,6 +81,24 @@ const (
 
 	// maxProtocolVersion is the max protocol version the server supports.
 	maxProtocolVersion = 70002
+
+	// maxUtxoStatsEntries is the maximum number of utxo set entries the
+	// getutxostats RPC processes per call.  It bounds the time the chain
+	// lock is held for.
+	maxUtxoStatsEntries = 1000000
+
+	// maxPeerProbeTimeout is the maximum number of seconds the
+	// getpeerservices RPC may take to probe a peer.
+	maxPeerProbeTimeout = 60
+
+	// maxBlockRewardsRange is the maximum number of blocks the
+	// getblockrewards RPC returns per call.
+	maxBlockRewardsRange = 2000
+
+	// subsidyFeeWindow is the number of most recent blocks whose average
+	// fees the getblocksubsidy RPC reports as the fees expected for blocks
+	// which are not mined yet.
+	subsidyFeeWindow = 144
 )
 
 var (
@@ -127,13 +151,20 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 // a dependency loop.
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
+	"acceleratetx":          handleAccelerateTx,
 	"addnode":               handleAddNode,
+	"approvereorg":          handleApproveReorg,
+	"clearbanned":           handleClearBanned,
+	"comparechains":         handleCompareChains,
 	"createrawtransaction":  handleCreateRawTransaction,
 	"debuglevel":            handleDebugLevel,
 	"decoderawtransaction":  handleDecodeRawTransaction,
 	"decodescript":          handleDecodeScript,
 	"estimatefee":           handleEstimateFee,
+	"flushcache":            handleFlushCache,
 	"generate":              handleGenerate,
+	"generateblock":         handleGenerateBlock,
+	"generatetoaddress":     handleGenerateToAddress,
 	"getaddednodeinfo":      handleGetAddedNodeInfo,
 	"getbestblock":          handleGetBestBlock,
 	"getbestblockhash":      handleGetBestBlockHash,
@@ -142,7 +173,11 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getblockcount":         handleGetBlockCount,
 	"getblockhash":          handleGetBlockHash,
 	"getblockheader":        handleGetBlockHeader,
+	"getblockrewards":       handleGetBlockRewards,
+	"getblocksubsidy":       handleGetBlockSubsidy,
+	"getblockstats":         handleGetBlockStats,
 	"getblocktemplate":      handleGetBlockTemplate,
+	"getchaintxstats":       handleGetChainTxStats,
 	"getconnectioncount":    handleGetConnectionCount,
 	"getcurrentnet":         handleGetCurrentNet,
 	"getdifficulty":         handleGetDifficulty,
@@ -155,14 +190,25 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getnettotals":          handleGetNetTotals,
 	"getnetworkhashps":      handleGetNetworkHashPS,
 	"getpeerinfo":           handleGetPeerInfo,
+	"getpeerservices":       handleGetPeerServices,
+	"getpendingreorgs":      handleGetPendingReorgs,
 	"getrawmempool":         handleGetRawMempool,
 	"getrawtransaction":     handleGetRawTransaction,
+	"getrebroadcastset":     handleGetRebroadcastSet,
 	"gettxout":              handleGetTxOut,
+	"gettxtimelocks":        handleGetTxTimeLocks,
+	"getutxostats":          handleGetUtxoStats,
+	"getvalidationstats":    handleGetValidationStats,
 	"help":                  handleHelp,
+	"invalidateblock":       handleInvalidateBlock,
+	"listacceleratedtxs":    handleListAcceleratedTxs,
+	"listbanned":            handleListBanned,
 	"node":                  handleNode,
 	"ping":                  handlePing,
+	"reconsiderblock":       handleReconsiderBlock,
 	"searchrawtransactions": handleSearchRawTransactions,
 	"sendrawtransaction":    handleSendRawTransaction,
+	"setban":                handleSetBan,
 	"setgenerate":           handleSetGenerate,
 	"stop":                  handleStop,
 	"submitblock":           handleSubmitBlock,
@@ -228,14 +274,16 @@ var rpcUnimplemented = map[string]struct{}{
 	"getmempoolentry":  {},
 	"getnetworkinfo":   {},
 	"getwork":          {},
-	"invalidateblock":  {},
 	"preciousblock":    {},
-	"reconsiderblock":  {},
 }
 
 // Commands that are available to a limited user
 var rpcLimited = map[string]struct{}{
 	// Websockets commands
+	"getblocksbatch":        {},
+	"fetchblocksraw":        {},
+	"ackfetchblocksraw":     {},
+	"stopfetchblocksraw":    {},
 	"loadtxfilter":          {},
 	"notifyblocks":          {},
 	"notifynewtransactions": {},
@@ -243,12 +291,20 @@ var rpcLimited = map[string]struct{}{
 	"notifyspent":           {},
 	"rescan":                {},
 	"rescanblocks":          {},
+	"rescanblockchain":      {},
+	"abortrescan":           {},
+	"notifychainevents":     {},
+	"ackchainevents":        {},
+	"stopnotifychainevents": {},
+	"notifymempoolevents":   {},
+	"notifyreorg":           {},
 	"session":               {},
 
 	// Websockets AND HTTP/S commands
 	"help": {},
 
 	// HTTP/S-only commands
+	"comparechains":         {},
 	"createrawtransaction":  {},
 	"decoderawtransaction":  {},
 	"decodescript":          {},
@@ -259,15 +315,19 @@ var rpcLimited = map[string]struct{}{
 	"getblockcount":         {},
 	"getblockhash":          {},
 	"getblockheader":        {},
+	"getblockrewards":       {},
+	"getblocksubsidy":       {},
 	"getcurrentnet":         {},
 	"getdifficulty":         {},
 	"getheaders":            {},
 	"getinfo":               {},
 	"getnettotals":          {},
 	"getnetworkhashps":      {},
+	"getpendingreorgs":      {},
 	"getrawmempool":         {},
 	"getrawtransaction":     {},
 	"gettxout":              {},
+	"gettxtimelocks":        {},
 	"searchrawtransactions": {},
 	"sendrawtransaction":    {},
 	"submitblock":           {},
@@ -324,12 +384,12 @@ func rpcNoTxInfoError(txHash *chainhash.Hash) *btcjson.RPCError {
 // getblocktemplate.
 type gbtWorkState struct {
 	sync.Mutex
-	lastTxUpdate  time.Time
+	txGeneration  uint64
 	lastGenerated time.Time
 	prevHash      *chainhash.Hash
 	minTimestamp  time.Time
 	template      *mining.BlockTemplate
-	notifyMap     map[chainhash.Hash]map[int64]chan struct{}
+	notifyMap     map[chainhash.Hash]map[uint64]chan struct{}
 	timeSource    blockchain.MedianTimeSource
 }
 
@@ -337,7 +397,7 @@ type gbtWorkState struct {
 // fields initialized and ready to use.
 func newGbtWorkState(timeSource blockchain.MedianTimeSource) *gbtWorkState {
 	return &gbtWorkState{
-		notifyMap:  make(map[chainhash.Hash]map[int64]chan struct{}),
+		notifyMap:  make(map[chainhash.Hash]map[uint64]chan struct{}),
 		timeSource: timeSource,
 	}
 }
@@ -355,6 +415,61 @@ func handleAskWallet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (
 	return nil, ErrRPCNoWallet
 }
 
+// handleAccelerateTx implements the acceleratetx command.
+func handleAccelerateTx(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AccelerateTxCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	// A boost of zero removes the registration.
+	if c.Boost == 0 {
+		if s.cfg.Accelerator.Remove(txHash) {
+			rpcsLog.Infof("Removed acceleration of transaction %v",
+				txHash)
+		}
+		return nil, nil
+	}
+
+	expiry := int64(86400)
+	if c.Expiry != nil {
+		expiry = *c.Expiry
+	}
+	if expiry <= 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Expiry must be positive",
+		}
+	}
+	expires := time.Now().Add(time.Duration(expiry) * time.Second)
+	s.cfg.Accelerator.Add(txHash, c.Boost, expires)
+	rpcsLog.Infof("Accelerating transaction %v with boost %d until %v",
+		txHash, c.Boost, expires.Format(time.RFC3339))
+	return nil, nil
+}
+
+// handleApproveReorg implements the approvereorg command.
+//
+// NOTE: This is a btcd extension.
+func handleApproveReorg(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ApproveReorgCmd)
+
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+	if err := s.cfg.Chain.ApproveReorg(hash); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: err.Error(),
+		}
+	}
+
+	return nil, nil
+}
+
 // handleAddNode handles addnode commands.
 func handleAddNode(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.AddNodeCmd)
@@ -504,6 +619,65 @@ func messageToHex(msg wire.Message) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
+// handleClearBanned implements the clearbanned command.
+func handleClearBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if err := s.cfg.ConnMgr.ClearBans(); err != nil {
+		return nil, internalRPCError(err.Error(), "Failed to save bans")
+	}
+	return nil, nil
+}
+
+// handleCompareChains implements the comparechains command.
+func handleCompareChains(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CompareChainsCmd)
+
+	hash1, err := chainhash.NewHashFromStr(c.Hash1)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash1)
+	}
+	hash2, err := chainhash.NewHashFromStr(c.Hash2)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash2)
+	}
+	comparison, err := s.cfg.Chain.CompareChains(hash1, hash2)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: err.Error(),
+		}
+	}
+
+	var chains [2]btcjson.ComparedChainResult
+	for i, chain := range comparison.Chains {
+		chains[i] = btcjson.ComparedChainResult{
+			Hash:         chain.Hash.String(),
+			Height:       chain.Height,
+			ChainWork:    chainWorkString(chain.Work),
+			BranchLength: chain.BranchLength,
+			BranchWork:   chainWorkString(chain.BranchWork),
+			MainChain:    chain.MainChain,
+		}
+	}
+
+	// The relative work is signed, so encode its magnitude and prefix it
+	// with a minus sign when the second chain has more work.
+	relative := new(big.Int).Sub(comparison.Chains[0].Work,
+		comparison.Chains[1].Work)
+	relativeWork := chainWorkString(new(big.Int).Abs(relative))
+	if relative.Sign() < 0 {
+		relativeWork = "-" + relativeWork
+	}
+
+	return &btcjson.CompareChainsResult{
+		ForkHash:      comparison.ForkHash.String(),
+		ForkHeight:    comparison.ForkHeight,
+		ForkChainWork: chainWorkString(comparison.ForkWork),
+		Chain1:        chains[0],
+		Chain2:        chains[1],
+		RelativeWork:  relativeWork,
+	}, nil
+}
+
 // handleCreateRawTransaction handles createrawtransaction commands.
 func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.CreateRawTransactionCmd)
@@ -746,12 +920,14 @@ func createTxRawResult(chainParams *chaincfg.Params, mtx *wire.MsgTx,
 		return nil, err
 	}
 
+	tx := btcutil.NewTx(mtx)
 	txReply := &btcjson.TxRawResult{
 		Hex:      mtxHex,
 		Txid:     txHash,
 		Hash:     mtx.WitnessHash().String(),
 		Size:     int32(mtx.SerializeSize()),
-		Vsize:    int32(mempool.GetTxVirtualSize(btcutil.NewTx(mtx))),
+		Vsize:    int32(mempool.GetTxVirtualSize(tx)),
+		Weight:   int32(blockchain.GetTransactionWeight(tx)),
 		Vin:      createVinList(mtx),
 		Vout:     createVoutList(mtx, chainParams, nil),
 		Version:  mtx.Version,
@@ -792,8 +968,13 @@ func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan
 	}
 
 	// Create and return the result.
+	tx := btcutil.NewTx(&mtx)
 	txReply := btcjson.TxRawDecodeResult{
 		Txid:     mtx.TxHash().String(),
+		Hash:     mtx.WitnessHash().String(),
+		Size:     int32(mtx.SerializeSize()),
+		Vsize:    int32(mempool.GetTxVirtualSize(tx)),
+		Weight:   int32(blockchain.GetTransactionWeight(tx)),
 		Version:  mtx.Version,
 		Locktime: mtx.LockTime,
 		Vin:      createVinList(&mtx),
@@ -871,6 +1052,24 @@ func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return float64(feeRate), nil
 }
 
+// handleFlushCache implements the flushcache command.
+func handleFlushCache(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Any block which is the best block before flushing has already been
+	// committed to the database, so it is known to be durable afterwards.
+	best := s.cfg.Chain.BestSnapshot()
+	start := time.Now()
+	if err := ffldb.Flush(s.cfg.DB); err != nil {
+		context := "Failed to flush database"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &btcjson.FlushCacheResult{
+		Hash:     best.Hash.String(),
+		Height:   best.Height,
+		Duration: int64(time.Since(start) / time.Millisecond),
+	}, nil
+}
+
 // handleGenerate handles generate commands.
 func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if there are no addresses to pay the
@@ -885,14 +1084,8 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 
 	// Respond with an error if there's virtually 0 chance of mining a block
 	// with the CPU.
-	if !s.cfg.ChainParams.GenerateSupported {
-		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCDifficulty,
-			Message: fmt.Sprintf("No support for `generate` on "+
-				"the current network, %s, as it's unlikely to "+
-				"be possible to main a block with the CPU.",
-				s.cfg.ChainParams.Net),
-		}
+	if err := checkGenerateSupported(s, "generate"); err != nil {
+		return nil, err
 	}
 
 	c := cmd.(*btcjson.GenerateCmd)
@@ -925,20 +1118,152 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return reply, nil
 }
 
+// checkGenerateSupported returns an error when the current network does not
+// support generating blocks with the CPU, which is the case for all networks
+// but the regression and simulation test networks since there's virtually 0
+// chance of mining a block with the CPU on them.
+func checkGenerateSupported(s *rpcServer, method string) error {
+	if s.cfg.ChainParams.GenerateSupported {
+		return nil
+	}
+	return &btcjson.RPCError{
+		Code: btcjson.ErrRPCDifficulty,
+		Message: fmt.Sprintf("No support for `%s` on the current "+
+			"network, %s, as it's unlikely to be possible to mine "+
+			"a block with the CPU.", method, s.cfg.ChainParams.Net),
+	}
+}
+
+// decodeGenerateAddress decodes the passed address blocks are generated to and
+// ensures it is for the current network.
+func decodeGenerateAddress(s *rpcServer, encodedAddr string) (btcutil.Address, error) {
+	addr, err := btcutil.DecodeAddress(encodedAddr, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+	if !addr.IsForNet(s.cfg.ChainParams) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: address is not for " +
+				"the current network",
+		}
+	}
+	return addr, nil
+}
+
+// handleGenerateToAddress handles generatetoaddress commands.
+func handleGenerateToAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if err := checkGenerateSupported(s, "generatetoaddress"); err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GenerateToAddressCmd)
+	if c.NumBlocks == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: "Please request a nonzero number of blocks to generate.",
+		}
+	}
+	addr, err := decodeGenerateAddress(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	blockHashes, err := s.cfg.CPUMiner.GenerateNBlocksToAddress(c.NumBlocks,
+		addr)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: err.Error(),
+		}
+	}
+	reply := make([]string, len(blockHashes))
+	for i, hash := range blockHashes {
+		reply[i] = hash.String()
+	}
+	return reply, nil
+}
+
+// handleGenerateBlock handles generateblock commands.
+func handleGenerateBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if err := checkGenerateSupported(s, "generateblock"); err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GenerateBlockCmd)
+	addr, err := decodeGenerateAddress(s, c.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each transaction is either the id of a transaction in the memory
+	// pool or a raw transaction.
+	var txs []*btcutil.Tx
+	if c.Transactions != nil {
+		txs = make([]*btcutil.Tx, 0, len(*c.Transactions))
+		for _, txStr := range *c.Transactions {
+			if len(txStr) == chainhash.MaxHashStringSize {
+				txHash, err := chainhash.NewHashFromStr(txStr)
+				if err != nil {
+					return nil, rpcDecodeHexError(txStr)
+				}
+				tx, err := s.cfg.TxMemPool.FetchTransaction(txHash)
+				if err != nil {
+					return nil, &btcjson.RPCError{
+						Code: btcjson.ErrRPCNoTxInfo,
+						Message: "Transaction " + txStr +
+							" not in mempool",
+					}
+				}
+				txs = append(txs, tx)
+				continue
+			}
+
+			serializedTx, err := hex.DecodeString(txStr)
+			if err != nil {
+				return nil, rpcDecodeHexError(txStr)
+			}
+			var msgTx wire.MsgTx
+			err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCDeserialization,
+					Message: "TX decode failed: " + err.Error(),
+				}
+			}
+			txs = append(txs, btcutil.NewTx(&msgTx))
+		}
+	}
+
+	blockHash, err := s.cfg.CPUMiner.GenerateBlock(addr, txs)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCVerify,
+			Message: "Unable to generate block: " + err.Error(),
+		}
+	}
+	return &btcjson.GenerateBlockResult{Hash: blockHash.String()}, nil
+}
+
 // handleGetAddedNodeInfo handles getaddednodeinfo commands.
 func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetAddedNodeInfoCmd)
 
-	// Retrieve a list of persistent (added) peers from the server and
-	// filter the list of peers per the specified address (if any).
-	peers := s.cfg.ConnMgr.PersistentPeers()
+	// Retrieve a list of the added nodes, including the ones which are not
+	// connected, from the server and filter the list of nodes per the
+	// specified address (if any).
+	nodes := s.cfg.ConnMgr.AddedNodes()
 	if c.Node != nil {
 		node := *c.Node
 		found := false
-		for i, peer := range peers {
-			if peer.ToPeer().Addr() == node {
-				peers = peers[i : i+1]
+		for i, addedNode := range nodes {
+			if addedNode.Addr == node {
+				nodes = nodes[i : i+1]
 				found = true
+				break
 			}
 		}
 		if !found {
@@ -952,30 +1277,34 @@ func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	// Without the dns flag, the result is just a slice of the addresses as
 	// strings.
 	if !c.DNS {
-		results := make([]string, 0, len(peers))
-		for _, peer := range peers {
-			results = append(results, peer.ToPeer().Addr())
+		results := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			results = append(results, node.Addr)
 		}
 		return results, nil
 	}
 
 	// With the dns flag, the result is an array of JSON objects which
-	// include the result of DNS lookups for each peer.
-	results := make([]*btcjson.GetAddedNodeInfoResult, 0, len(peers))
-	for _, rpcPeer := range peers {
-		// Set the "address" of the peer which could be an ip address
+	// include the result of DNS lookups for each node along with their
+	// connection status.
+	results := make([]*btcjson.GetAddedNodeInfoResult, 0, len(nodes))
+	for _, node := range nodes {
+		// Set the "address" of the node which could be an ip address
 		// or a domain name.
-		peer := rpcPeer.ToPeer()
+		var connPeer *peer.Peer
+		if node.Peer != nil && node.Peer.ToPeer().Connected() {
+			connPeer = node.Peer.ToPeer()
+		}
 		var result btcjson.GetAddedNodeInfoResult
-		result.AddedNode = peer.Addr()
-		result.Connected = btcjson.Bool(peer.Connected())
+		result.AddedNode = node.Addr
+		result.Connected = btcjson.Bool(connPeer != nil)
 
 		// Split the address into host and port portions so we can do
 		// a DNS lookup against the host.  When no port is specified in
 		// the address, just use the address as the host.
-		host, _, err := net.SplitHostPort(peer.Addr())
+		host, _, err := net.SplitHostPort(node.Addr)
 		if err != nil {
-			host = peer.Addr()
+			host = node.Addr
 		}
 
 		var ipList []string
@@ -998,14 +1327,24 @@ func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan stru
 			}
 		}
 
+		// The connected peer address is the resolved address of the
+		// node.
+		var peerHost string
+		if connPeer != nil {
+			peerHost, _, err = net.SplitHostPort(connPeer.Addr())
+			if err != nil {
+				peerHost = connPeer.Addr()
+			}
+		}
+
 		// Add the addresses and connection info to the result.
 		addrs := make([]btcjson.GetAddedNodeInfoResultAddr, 0, len(ipList))
 		for _, ip := range ipList {
 			var addr btcjson.GetAddedNodeInfoResultAddr
 			addr.Address = ip
 			addr.Connected = "false"
-			if ip == host && peer.Connected() {
-				addr.Connected = directionString(peer.Inbound())
+			if connPeer != nil && (ip == peerHost || ip == host) {
+				addr.Connected = directionString(connPeer.Inbound())
 			}
 			addrs = append(addrs, addr)
 		}
@@ -1034,6 +1373,12 @@ func handleGetBestBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return best.Hash.String(), nil
 }
 
+// chainWorkString returns the passed chain work as a hex-encoded string padded
+// to 64 characters as reported by the chainwork fields of the RPC results.
+func chainWorkString(work *big.Int) string {
+	return fmt.Sprintf("%064x", work)
+}
+
 // getDifficultyRatio returns the proof-of-work difficulty as a multiple of the
 // minimum difficulty using the passed bits field from the header of a block.
 func getDifficultyRatio(bits uint32, params *chaincfg.Params) float64 {
@@ -1099,6 +1444,11 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	}
 	blk.SetHeight(blockHeight)
 	best := s.cfg.Chain.BestSnapshot()
+	chainWork, err := s.cfg.Chain.ChainWork(hash)
+	if err != nil {
+		context := "Failed to obtain chain work"
+		return nil, internalRPCError(err.Error(), context)
+	}
 
 	// Get next block hash unless there are none.
 	var nextHashString string
@@ -1128,6 +1478,7 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 		Weight:        int32(blockchain.GetBlockWeight(blk)),
 		Bits:          strconv.FormatInt(int64(blockHeader.Bits), 16),
 		Difficulty:    getDifficultyRatio(blockHeader.Bits, params),
+		ChainWork:     chainWorkString(chainWork),
 		NextHash:      nextHashString,
 	}
 
@@ -1183,6 +1534,11 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 	params := s.cfg.ChainParams
 	chain := s.cfg.Chain
 	chainSnapshot := chain.BestSnapshot()
+	chainWork, err := chain.ChainWork(&chainSnapshot.Hash)
+	if err != nil {
+		context := "Failed to obtain chain work"
+		return nil, internalRPCError(err.Error(), context)
+	}
 
 	chainInfo := &btcjson.GetBlockChainInfoResult{
 		Chain:         params.Name,
@@ -1192,9 +1548,17 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 		Difficulty:    getDifficultyRatio(chainSnapshot.Bits, params),
 		MedianTime:    chainSnapshot.MedianTime.Unix(),
 		Pruned:        false,
+		ChainWork:     chainWorkString(chainWork),
 		Bip9SoftForks: make(map[string]*btcjson.Bip9SoftForkDescription),
 	}
 
+	// Report the height below which the undo data is pruned since the chain
+	// can't be reorganized below it.
+	if undoPruneHeight := chain.UndoPruneHeight(); undoPruneHeight > 0 {
+		chainInfo.UndoPruned = true
+		chainInfo.UndoPruneHeight = undoPruneHeight
+	}
+
 	// Next, populate the response with information describing the current
 	// status of soft-forks deployed via the super-majority block
 	// signalling mechanism.
@@ -1245,6 +1609,9 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 		case chaincfg.DeploymentSegwit:
 			forkName = "segwit"
 
+		case chaincfg.DeploymentTaproot:
+			forkName = "taproot"
+
 		default:
 			return nil, &btcjson.RPCError{
 				Code: btcjson.ErrRPCInternal.Code,
@@ -1344,6 +1711,11 @@ func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct
 		return nil, internalRPCError(err.Error(), context)
 	}
 	best := s.cfg.Chain.BestSnapshot()
+	chainWork, err := s.cfg.Chain.ChainWork(hash)
+	if err != nil {
+		context := "Failed to obtain chain work"
+		return nil, internalRPCError(err.Error(), context)
+	}
 
 	// Get next block hash unless there are none.
 	var nextHashString string
@@ -1370,22 +1742,273 @@ func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct
 		Time:          blockHeader.Timestamp.Unix(),
 		Bits:          strconv.FormatInt(int64(blockHeader.Bits), 16),
 		Difficulty:    getDifficultyRatio(blockHeader.Bits, params),
+		ChainWork:     chainWorkString(chainWork),
 	}
 	return blockHeaderReply, nil
 }
 
+// handleGetBlockRewards implements the getblockrewards command.
+//
+// NOTE: This is a btcd extension.
+func handleGetBlockRewards(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockRewardsCmd)
+
+	rewardIndex := s.cfg.RewardIndex
+	if rewardIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Block reward index must be enabled (--rewardindex)",
+		}
+	}
+
+	endHeight := c.StartHeight
+	if c.EndHeight != nil {
+		endHeight = *c.EndHeight
+	}
+	best := s.cfg.Chain.BestSnapshot()
+	if c.StartHeight < 0 || endHeight < c.StartHeight ||
+		endHeight > best.Height {
+
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "Block height range out of range",
+		}
+	}
+	if endHeight-c.StartHeight >= maxBlockRewardsRange {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Block height range exceeds the "+
+				"maximum of %d blocks", maxBlockRewardsRange),
+		}
+	}
+
+	params := s.cfg.ChainParams
+	verbose := c.Verbose != nil && *c.Verbose
+	results := make([]btcjson.BlockRewardsResult, 0,
+		endHeight-c.StartHeight+1)
+	for height := c.StartHeight; height <= endHeight; height++ {
+		hash, err := s.cfg.Chain.BlockHashByHeight(height)
+		if err != nil {
+			context := "Failed to obtain block hash"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		header, err := s.cfg.Chain.FetchHeader(hash)
+		if err != nil {
+			context := "Failed to obtain block header"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		// The index may still be catching up with the main chain or
+		// the block may have been disconnected since its hash was
+		// looked up.
+		reward, err := rewardIndex.BlockReward(hash)
+		if err != nil {
+			context := "Failed to obtain block reward"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if reward == nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCMisc,
+				Message: fmt.Sprintf("Block %v at height %d is "+
+					"not in the block reward index", hash,
+					height),
+			}
+		}
+
+		subsidy := blockchain.CalcBlockSubsidy(height, params)
+		result := btcjson.BlockRewardsResult{
+			Hash:      hash.String(),
+			Height:    height,
+			Time:      header.Timestamp.Unix(),
+			Subsidy:   subsidy,
+			Fees:      reward.Fees,
+			Coinbase:  reward.Coinbase,
+			Unclaimed: subsidy + reward.Fees - reward.Coinbase,
+		}
+		if verbose {
+			block, err := s.cfg.Chain.BlockByHash(hash)
+			if err != nil {
+				context := "Failed to fetch block"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			coinbase := block.MsgBlock().Transactions[0]
+			vouts := createVoutList(coinbase, params, nil)
+			result.Outputs = make([]btcjson.CoinbaseOutputResult,
+				0, len(vouts))
+			for _, vout := range vouts {
+				result.Outputs = append(result.Outputs,
+					btcjson.CoinbaseOutputResult{
+						N:            vout.N,
+						Value:        coinbase.TxOut[vout.N].Value,
+						ScriptPubKey: vout.ScriptPubKey,
+					})
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// handleGetBlockSubsidy implements the getblocksubsidy command.
+//
+// NOTE: This is a btcd extension.
+func handleGetBlockSubsidy(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockSubsidyCmd)
+
+	best := s.cfg.Chain.BestSnapshot()
+	height := best.Height + 1
+	if c.Height != nil {
+		height = *c.Height
+	}
+	if height < 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Block height must not be negative",
+		}
+	}
+
+	params := s.cfg.ChainParams
+	result := btcjson.GetBlockSubsidyResult{
+		Height:          height,
+		Subsidy:         blockchain.CalcBlockSubsidy(height, params),
+		HalvingInterval: params.SubsidyReductionInterval,
+	}
+	if params.SubsidyReductionInterval != 0 {
+		result.Halvings = height / params.SubsidyReductionInterval
+	}
+	if next := blockchain.CalcNextHalvingHeight(height, params); next != -1 {
+		nextSubsidy := blockchain.CalcBlockSubsidy(next, params)
+		result.NextHalvingHeight = &next
+		result.NextSubsidy = &nextSubsidy
+	}
+
+	// The fees are only known from the block reward index.  Blocks which
+	// are not in the index, such as while it is catching up or when they
+	// were disconnected in the meantime, are left out.
+	rewardIndex := s.cfg.RewardIndex
+	if rewardIndex == nil {
+		return &result, nil
+	}
+	blockFees := func(height int32) (*int64, error) {
+		hash, err := s.cfg.Chain.BlockHashByHeight(height)
+		if err != nil {
+			return nil, nil
+		}
+		reward, err := rewardIndex.BlockReward(hash)
+		if err != nil || reward == nil {
+			return nil, err
+		}
+		return &reward.Fees, nil
+	}
+	if height <= best.Height {
+		fees, err := blockFees(height)
+		if err != nil {
+			context := "Failed to obtain block reward"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		result.Fees = fees
+		return &result, nil
+	}
+
+	var totalFees, numBlocks int64
+	for h := best.Height; h >= 0 && h > best.Height-subsidyFeeWindow; h-- {
+		fees, err := blockFees(h)
+		if err != nil {
+			context := "Failed to obtain block reward"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if fees != nil {
+			totalFees += *fees
+			numBlocks++
+		}
+	}
+	if numBlocks > 0 {
+		expectedFees := totalFees / numBlocks
+		result.ExpectedFees = &expectedFees
+	}
+	return &result, nil
+}
+
+// scriptStatsResult converts the passed script execution statistics to their
+// JSON-RPC representation.
+func scriptStatsResult(stats *txscript.ExecutionStats) btcjson.ScriptStatsResult {
+	return btcjson.ScriptStatsResult{
+		Opcodes:   stats.Opcodes,
+		StackPeak: stats.StackPeak,
+		SigOps:    stats.SigOps,
+		HashOps:   stats.HashOps,
+	}
+}
+
+// handleGetBlockStats implements the getblockstats command.
+//
+// NOTE: This is a btcd extension.
+func handleGetBlockStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockStatsCmd)
+
+	hash, err := chainhash.NewHashFromStr(c.Hash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash)
+	}
+	block, err := s.cfg.Chain.BlockByHash(hash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found in the main chain",
+		}
+	}
+	height, err := s.cfg.Chain.BlockHeightByHash(hash)
+	if err != nil {
+		context := "Failed to obtain block height"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// Execute the scripts of the block again to meter them.
+	stats, err := s.cfg.Chain.BlockScriptStats(hash)
+	if err != nil {
+		context := "Failed to execute block scripts"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	result := &btcjson.GetBlockStatsResult{
+		Hash:    c.Hash,
+		Height:  height,
+		Txs:     len(block.Transactions()),
+		Size:    block.MsgBlock().SerializeSize(),
+		Weight:  blockchain.GetBlockWeight(block),
+		Scripts: scriptStatsResult(&stats.Total),
+	}
+	for _, tx := range block.MsgBlock().Transactions {
+		result.Inputs += len(tx.TxIn)
+		result.Outputs += len(tx.TxOut)
+	}
+	if c.Verbose != nil && *c.Verbose {
+		result.Transactions = make([]btcjson.TxScriptStatsResult, 0,
+			len(stats.Transactions))
+		for i := range stats.Transactions {
+			txStats := &stats.Transactions[i]
+			result.Transactions = append(result.Transactions,
+				btcjson.TxScriptStatsResult{
+					TxID:    txStats.Hash.String(),
+					Scripts: scriptStatsResult(&txStats.Stats),
+				})
+		}
+	}
+	return result, nil
+}
+
 // encodeTemplateID encodes the passed details into an ID that can be used to
 // uniquely identify a block template.
-func encodeTemplateID(prevHash *chainhash.Hash, lastGenerated time.Time) string {
-	return fmt.Sprintf("%s-%d", prevHash.String(), lastGenerated.Unix())
+func encodeTemplateID(prevHash *chainhash.Hash, txGeneration uint64) string {
+	return fmt.Sprintf("%s-%d", prevHash.String(), txGeneration)
 }
 
 // decodeTemplateID decodes an ID that is used to uniquely identify a block
 // template.  This is mainly used as a mechanism to track when to update clients
 // that are using long polling for block templates.  The ID consists of the
-// previous block hash for the associated template and the time the associated
-// template was generated.
-func decodeTemplateID(templateID string) (*chainhash.Hash, int64, error) {
+// previous block hash for the associated template and the generation of the
+// transaction memory pool the associated template was generated from.
+func decodeTemplateID(templateID string) (*chainhash.Hash, uint64, error) {
 	fields := strings.Split(templateID, "-")
 	if len(fields) != 2 {
 		return nil, 0, errors.New("invalid longpollid format")
@@ -1395,19 +2018,19 @@ func decodeTemplateID(templateID string) (*chainhash.Hash, int64, error) {
 	if err != nil {
 		return nil, 0, errors.New("invalid longpollid format")
 	}
-	lastGenerated, err := strconv.ParseInt(fields[1], 10, 64)
+	txGeneration, err := strconv.ParseUint(fields[1], 10, 64)
 	if err != nil {
 		return nil, 0, errors.New("invalid longpollid format")
 	}
 
-	return prevHash, lastGenerated, nil
+	return prevHash, txGeneration, nil
 }
 
 // notifyLongPollers notifies any channels that have been registered to be
 // notified when block templates are stale.
 //
 // This function MUST be called with the state locked.
-func (state *gbtWorkState) notifyLongPollers(latestHash *chainhash.Hash, lastGenerated time.Time) {
+func (state *gbtWorkState) notifyLongPollers(latestHash *chainhash.Hash, txGeneration uint64) {
 	// Notify anything that is waiting for a block template update from a
 	// hash which is not the hash of the tip of the best chain since their
 	// work is now invalid.
@@ -1420,12 +2043,6 @@ func (state *gbtWorkState) notifyLongPollers(latestHash *chainhash.Hash, lastGen
 		}
 	}
 
-	// Return now if the provided last generated timestamp has not been
-	// initialized.
-	if lastGenerated.IsZero() {
-		return
-	}
-
 	// Return now if there is nothing registered for updates to the current
 	// best block hash.
 	channels, ok := state.notifyMap[*latestHash]
@@ -1434,13 +2051,12 @@ func (state *gbtWorkState) notifyLongPollers(latestHash *chainhash.Hash, lastGen
 	}
 
 	// Notify anything that is waiting for a block template update from a
-	// block template generated before the most recently generated block
-	// template.
-	lastGeneratedUnix := lastGenerated.Unix()
-	for lastGen, c := range channels {
-		if lastGen < lastGeneratedUnix {
+	// block template generated from an older generation of the memory pool
+	// than the provided one.
+	for gen, c := range channels {
+		if gen < txGeneration {
 			close(c)
-			delete(channels, lastGen)
+			delete(channels, gen)
 		}
 	}
 
@@ -1459,15 +2075,15 @@ func (state *gbtWorkState) NotifyBlockConnected(blockHash *chainhash.Hash) {
 		state.Lock()
 		defer state.Unlock()
 
-		state.notifyLongPollers(blockHash, state.lastTxUpdate)
+		state.notifyLongPollers(blockHash, state.txGeneration)
 	}()
 }
 
-// NotifyMempoolTx uses the new last updated time for the transaction memory
-// pool to notify any long poll clients with a new block template when their
-// existing block template is stale due to enough time passing and the contents
-// of the memory pool changing.
-func (state *gbtWorkState) NotifyMempoolTx(lastUpdated time.Time) {
+// NotifyMempoolTx uses the new generation of the transaction memory pool to
+// notify any long poll clients with a new block template when their existing
+// block template is stale due to enough time passing and the contents of the
+// memory pool changing.
+func (state *gbtWorkState) NotifyMempoolTx(txGeneration uint64) {
 	go func() {
 		state.Lock()
 		defer state.Unlock()
@@ -1481,34 +2097,34 @@ func (state *gbtWorkState) NotifyMempoolTx(lastUpdated time.Time) {
 		if time.Now().After(state.lastGenerated.Add(time.Second *
 			gbtRegenerateSeconds)) {
 
-			state.notifyLongPollers(state.prevHash, lastUpdated)
+			state.notifyLongPollers(state.prevHash, txGeneration)
 		}
 	}()
 }
 
 // templateUpdateChan returns a channel that will be closed once the block
-// template associated with the passed previous hash and last generated time
+// template associated with the passed previous hash and memory pool generation
 // is stale.  The function will return existing channels for duplicate
 // parameters which allows multiple clients to wait for the same block template
 // without requiring a different channel for each client.
 //
 // This function MUST be called with the state locked.
-func (state *gbtWorkState) templateUpdateChan(prevHash *chainhash.Hash, lastGenerated int64) chan struct{} {
+func (state *gbtWorkState) templateUpdateChan(prevHash *chainhash.Hash, txGeneration uint64) chan struct{} {
 	// Either get the current list of channels waiting for updates about
 	// changes to block template for the previous hash or create a new one.
 	channels, ok := state.notifyMap[*prevHash]
 	if !ok {
-		m := make(map[int64]chan struct{})
+		m := make(map[uint64]chan struct{})
 		state.notifyMap[*prevHash] = m
 		channels = m
 	}
 
-	// Get the current channel associated with the time the block template
-	// was last generated or create a new one.
-	c, ok := channels[lastGenerated]
+	// Get the current channel associated with the memory pool generation
+	// the block template was generated from or create a new one.
+	c, ok := channels[txGeneration]
 	if !ok {
 		c = make(chan struct{})
-		channels[lastGenerated] = c
+		channels[txGeneration] = c
 	}
 
 	return c
@@ -1528,10 +2144,7 @@ func (state *gbtWorkState) templateUpdateChan(prevHash *chainhash.Hash, lastGene
 // This function MUST be called with the state locked.
 func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bool) error {
 	generator := s.cfg.Generator
-	lastTxUpdate := generator.TxSource().LastUpdated()
-	if lastTxUpdate.IsZero() {
-		lastTxUpdate = time.Now()
-	}
+	txGeneration := generator.TxSource().Generation()
 
 	// Generate a new block template when the current best block has
 	// changed or the transactions in the memory pool have been updated and
@@ -1543,7 +2156,7 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 	template := state.template
 	if template == nil || state.prevHash == nil ||
 		!state.prevHash.IsEqual(latestHash) ||
-		(state.lastTxUpdate != lastTxUpdate &&
+		(state.txGeneration != txGeneration &&
 			time.Now().After(state.lastGenerated.Add(time.Second*
 				gbtRegenerateSeconds))) {
 
@@ -1585,7 +2198,7 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 		// generated until needed.
 		state.template = template
 		state.lastGenerated = time.Now()
-		state.lastTxUpdate = lastTxUpdate
+		state.txGeneration = txGeneration
 		state.prevHash = latestHash
 		state.minTimestamp = minTimestamp
 
@@ -1596,7 +2209,7 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 
 		// Notify any clients that are long polling about the new
 		// template.
-		state.notifyLongPollers(latestHash, lastTxUpdate)
+		state.notifyLongPollers(latestHash, txGeneration)
 	} else {
 		// At this point, there is a saved block template and another
 		// request for a template was made, but either the available
@@ -1626,8 +2239,8 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 
 			// Update the merkle root.
 			block := btcutil.NewBlock(template.Block)
-			merkles := blockchain.BuildMerkleTreeStore(block.Transactions(), false)
-			template.Block.Header.MerkleRoot = *merkles[len(merkles)-1]
+			template.Block.Header.MerkleRoot = blockchain.CalcMerkleRoot(
+				block.Transactions(), false)
 		}
 
 		// Set locals for convenience.
@@ -1730,7 +2343,7 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 	//  Including MinTime -> time/decrement
 	//  Omitting CoinbaseTxn -> coinbase, generation
 	targetDifficulty := fmt.Sprintf("%064x", blockchain.CompactToBig(header.Bits))
-	templateID := encodeTemplateID(state.prevHash, state.lastGenerated)
+	templateID := encodeTemplateID(state.prevHash, state.txGeneration)
 	reply := btcjson.GetBlockTemplateResult{
 		Bits:         strconv.FormatInt(int64(header.Bits), 16),
 		CurTime:      header.Timestamp.Unix(),
@@ -1818,7 +2431,7 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 
 	// Just return the current block template if the long poll ID provided by
 	// the caller is invalid.
-	prevHash, lastGenerated, err := decodeTemplateID(longPollID)
+	prevHash, txGeneration, err := decodeTemplateID(longPollID)
 	if err != nil {
 		result, err := state.blockTemplateResult(useCoinbaseValue, nil)
 		if err != nil {
@@ -1835,7 +2448,7 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 	// template as this means the provided template is stale.
 	prevTemplateHash := &state.template.Block.Header.PrevBlock
 	if !prevHash.IsEqual(prevTemplateHash) ||
-		lastGenerated != state.lastGenerated.Unix() {
+		txGeneration != state.txGeneration {
 
 		// Include whether or not it is valid to submit work against the
 		// old block template depending on whether or not a solution has
@@ -1852,11 +2465,11 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 		return result, nil
 	}
 
-	// Register the previous hash and last generated time for notifications
+	// Register the previous hash and memory pool generation for notifications
 	// Get a channel that will be notified when the template associated with
 	// the provided ID is stale and a new block template should be returned to
 	// the caller.
-	longPollChan := state.templateUpdateChan(prevHash, lastGenerated)
+	longPollChan := state.templateUpdateChan(prevHash, txGeneration)
 	state.Unlock()
 
 	select {
@@ -2067,8 +2680,30 @@ func chainErrToGBTErrString(err error) string {
 	return "rejected: " + err.Error()
 }
 
+// duplicateBlockResult returns the result described by BIP0022 and BIP0023 for
+// a submitted or proposed block which is already known, or an empty string when
+// the block is not known.  Blocks in the main chain are reported as duplicates,
+// while blocks which are only known as part of a side chain or as orphans are
+// reported as inconclusive duplicates since they have not necessarily been
+// fully validated.
+func duplicateBlockResult(s *rpcServer, hash *chainhash.Hash) (string, error) {
+	if s.cfg.Chain.MainChainHasBlock(hash) {
+		return "duplicate", nil
+	}
+	haveBlock, err := s.cfg.Chain.HaveBlock(hash)
+	if err != nil {
+		return "", err
+	}
+	if haveBlock {
+		return "duplicate-inconclusive", nil
+	}
+	return "", nil
+}
+
 // handleGetBlockTemplateProposal is a helper for handleGetBlockTemplate which
-// deals with block proposals.
+// deals with block proposals.  The proposed block is fully validated, including
+// all of the checks which are performed when connecting it to the main chain,
+// without actually connecting it.
 //
 // See https://en.bitcoin.it/wiki/BIP_0023 for more details.
 func handleGetBlockTemplateProposal(s *rpcServer, request *btcjson.TemplateRequest) (interface{}, error) {
@@ -2103,11 +2738,22 @@ func handleGetBlockTemplateProposal(s *rpcServer, request *btcjson.TemplateReque
 	}
 	block := btcutil.NewBlock(&msgBlock)
 
-	// Ensure the block is building from the expected previous block.
+	// Report blocks which are already known as duplicates.
+	result, err := duplicateBlockResult(s, block.Hash())
+	if err != nil {
+		context := "Failed to check for duplicate block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if result != "" {
+		return result, nil
+	}
+
+	// The proposal can only be validated when the block is building from
+	// the current best block.
 	expectedPrevHash := s.cfg.Chain.BestSnapshot().Hash
 	prevHash := &block.MsgBlock().Header.PrevBlock
 	if !expectedPrevHash.IsEqual(prevHash) {
-		return "bad-prevblk", nil
+		return "inconclusive-not-best-prevblk", nil
 	}
 
 	flags := blockchain.BFDryRun | blockchain.BFNoPoWCheck
@@ -2159,6 +2805,64 @@ func handleGetBlockTemplate(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	}
 }
 
+// handleGetChainTxStats implements the getchaintxstats command.
+func handleGetChainTxStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetChainTxStatsCmd)
+
+	var hash *chainhash.Hash
+	if c.BlockHash != nil {
+		var err error
+		hash, err = chainhash.NewHashFromStr(*c.BlockHash)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.BlockHash)
+		}
+		if !s.cfg.Chain.MainChainHasBlock(hash) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found in the main chain",
+			}
+		}
+	}
+
+	// A negative window selects the default window of about a month.
+	window := int32(-1)
+	if c.NBlocks != nil {
+		window = *c.NBlocks
+		if window < 0 {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid block count: should be between 0 and the block's height - 1",
+			}
+		}
+	}
+
+	stats, err := s.cfg.Chain.ChainTxStats(window, hash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+
+	result := &btcjson.GetChainTxStatsResult{
+		Time:                   stats.Time.Unix(),
+		TxCount:                stats.TxCount,
+		WindowFinalBlockHash:   stats.FinalHash.String(),
+		WindowFinalBlockHeight: stats.FinalHeight,
+		WindowBlockCount:       stats.WindowBlockCount,
+	}
+	if stats.WindowBlockCount > 0 {
+		interval := int64(stats.WindowInterval / time.Second)
+		result.WindowTxCount = &stats.WindowTxCount
+		result.WindowInterval = &interval
+		if interval > 0 {
+			txRate := float64(stats.WindowTxCount) / float64(interval)
+			result.TxRate = &txRate
+		}
+	}
+	return result, nil
+}
+
 // handleGetConnectionCount implements the getconnectioncount command.
 func handleGetConnectionCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return s.cfg.ConnMgr.ConnectedCount(), nil
@@ -2240,6 +2944,7 @@ func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 		Difficulty:      getDifficultyRatio(best.Bits, s.cfg.ChainParams),
 		TestNet:         cfg.TestNet3,
 		RelayFee:        cfg.minRelayTxFee.ToBTC(),
+		Errors:          s.cfg.Supervisor.Warnings(),
 	}
 
 	return ret, nil
@@ -2251,7 +2956,7 @@ func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct
 
 	var numBytes int64
 	for _, txD := range mempoolTxns {
-		numBytes += int64(txD.Tx.MsgTx().SerializeSize())
+		numBytes += mempool.GetTxVirtualSize(txD.Tx)
 	}
 
 	ret := &btcjson.GetMempoolInfoResult{
@@ -2288,6 +2993,7 @@ func handleGetMiningInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 		CurrentBlockWeight: best.BlockWeight,
 		CurrentBlockTx:     best.NumTxns,
 		Difficulty:         getDifficultyRatio(best.Bits, s.cfg.ChainParams),
+		Errors:             s.cfg.Supervisor.Warnings(),
 		Generate:           s.cfg.CPUMiner.IsMining(),
 		GenProcLimit:       s.cfg.CPUMiner.NumWorkers(),
 		HashesPerSec:       int64(s.cfg.CPUMiner.HashesPerSecond()),
@@ -2295,6 +3001,18 @@ func handleGetMiningInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 		PooledTx:           uint64(s.cfg.TxMemPool.Count()),
 		TestNet:            cfg.TestNet3,
 	}
+
+	// The countdown includes the block at the halving height, so it is one
+	// when the next block mined is the first with the reduced subsidy.
+	nextHeight := blockchain.CalcNextHalvingHeight(best.Height,
+		s.cfg.ChainParams)
+	if nextHeight != -1 {
+		blocksLeft := nextHeight - best.Height
+		result.NextHalvingHeight = nextHeight
+		result.BlocksUntilHalving = blocksLeft
+		result.NextHalvingTime = time.Now().Add(time.Duration(blocksLeft) *
+			s.cfg.ChainParams.TargetTimePerBlock).Unix()
+	}
 	return &result, nil
 }
 
@@ -2305,6 +3023,7 @@ func handleGetNetTotals(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 		TotalBytesRecv: totalBytesRecv,
 		TotalBytesSent: totalBytesSent,
 		TimeMillis:     time.Now().UTC().UnixNano() / int64(time.Millisecond),
+		UploadTarget:   s.cfg.ConnMgr.UploadTarget(),
 	}
 	return reply, nil
 }
@@ -2431,6 +3150,12 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			BanScore:       int32(p.BanScore()),
 			FeeFilter:      p.FeeFilter(),
 			SyncNode:       statsSnap.ID == syncPeerID,
+			BlockRelayOnly: p.IsBlockRelayOnly(),
+
+			MaxUploadRate:     statsSnap.MaxUploadRate,
+			MaxDownloadRate:   statsSnap.MaxDownloadRate,
+			UploadThrottled:   statsSnap.UploadThrottled.Seconds(),
+			DownloadThrottled: statsSnap.DownloadThrottled.Seconds(),
 		}
 		if p.ToPeer().LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
@@ -2442,6 +3167,29 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return infos, nil
 }
 
+// handleGetPendingReorgs implements the getpendingreorgs command.
+//
+// NOTE: This is a btcd extension.
+func handleGetPendingReorgs(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	reorgs := s.cfg.Chain.PendingReorgs()
+	result := make([]btcjson.PendingReorgResult, 0, len(reorgs))
+	for i := range reorgs {
+		reorg := &reorgs[i]
+		result = append(result, btcjson.PendingReorgResult{
+			ForkHash:      reorg.ForkHash.String(),
+			ForkHeight:    reorg.ForkHeight,
+			TipHash:       reorg.TipHash.String(),
+			TipHeight:     reorg.TipHeight,
+			Detach:        reorg.Detach,
+			Attach:        reorg.Attach,
+			TipChainWork:  chainWorkString(reorg.TipWork),
+			MainChainWork: chainWorkString(reorg.MainWork),
+			FirstSeen:     reorg.FirstSeen.Unix(),
+		})
+	}
+	return result, nil
+}
+
 // handleGetRawMempool implements the getrawmempool command.
 func handleGetRawMempool(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetRawMempoolCmd)
@@ -2581,6 +3329,11 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 	return *rawTxn, nil
 }
 
+// handleGetRebroadcastSet implements the getrebroadcastset command.
+func handleGetRebroadcastSet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return s.cfg.ConnMgr.RebroadcastSet(), nil
+}
+
 // handleGetTxOut handles gettxout commands.
 func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetTxOutCmd)
@@ -2658,36 +3411,288 @@ func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 		isCoinbase = entry.IsCoinBase()
 	}
 
-	// Disassemble script into single line printable format.
-	// The disassembled string will contain [error] inline if the script
-	// doesn't fully parse, so ignore the error here.
-	disbuf, _ := txscript.DisasmString(pkScript)
+	// Disassemble script into single line printable format.
+	// The disassembled string will contain [error] inline if the script
+	// doesn't fully parse, so ignore the error here.
+	disbuf, _ := txscript.DisasmString(pkScript)
+
+	// Get further info about the script.
+	// Ignore the error here since an error means the script couldn't parse
+	// and there is no additional information about it anyways.
+	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript,
+		s.cfg.ChainParams)
+	addresses := make([]string, len(addrs))
+	for i, addr := range addrs {
+		addresses[i] = addr.EncodeAddress()
+	}
+
+	txOutReply := &btcjson.GetTxOutResult{
+		BestBlock:     bestBlockHash,
+		Confirmations: int64(confirmations),
+		Value:         btcutil.Amount(value).ToBTC(),
+		Version:       txVersion,
+		ScriptPubKey: btcjson.ScriptPubKeyResult{
+			Asm:       disbuf,
+			Hex:       hex.EncodeToString(pkScript),
+			ReqSigs:   int32(reqSigs),
+			Type:      scriptClass.String(),
+			Addresses: addresses,
+		},
+		Coinbase: isCoinbase,
+	}
+	return txOutReply, nil
+}
+
+// handleGetPeerServices implements the getpeerservices command.
+func handleGetPeerServices(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetPeerServicesCmd)
+
+	timeout := int32(10)
+	if c.Timeout != nil {
+		timeout = *c.Timeout
+	}
+	if timeout < 1 || timeout > maxPeerProbeTimeout {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("timeout must be between 1 and %d",
+				maxPeerProbeTimeout),
+		}
+	}
+
+	addr := normalizeAddress(c.Addr, s.cfg.ChainParams.DefaultPort)
+	netAddr, err := addrStringToNetAddr(addr)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid address %q: %v", c.Addr, err),
+		}
+	}
+
+	// The probe uses its own connection, so the peer is never added to the
+	// peers of the server.
+	best := s.cfg.Chain.BestSnapshot()
+	result, err := probePeer(netAddr, btcdDial, s.cfg.ChainParams,
+		best.Height, time.Duration(timeout)*time.Second)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCClientNotConnected,
+			Message: fmt.Sprintf("Unable to probe peer %s: %v", addr,
+				err),
+		}
+	}
+	return result, nil
+}
+
+// handleGetTxTimeLocks implements the gettxtimelocks command.
+func handleGetTxTimeLocks(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetTxTimeLocksCmd)
+
+	// Deserialize the transaction.
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var mtx wire.MsgTx
+	err = mtx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
+		}
+	}
+	tx := btcutil.NewTx(&mtx)
+	if blockchain.IsCoinBase(tx) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Coinbase transactions have no time locks",
+		}
+	}
+
+	// Load the outputs spent by the transaction from the main chain and
+	// the memory pool, which is how the memory pool views them as well.
+	// Outputs created by transactions in the memory pool are treated as
+	// if they were included in the next block.
+	view, err := s.cfg.Chain.FetchUtxoView(tx)
+	if err != nil {
+		context := "Failed to load spent outputs"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	for originHash, entry := range view.Entries() {
+		if entry != nil && !entry.IsFullySpent() {
+			continue
+		}
+		originTx, err := s.cfg.TxMemPool.FetchTransaction(&originHash)
+		if err == nil {
+			view.AddTxOuts(originTx, mining.UnminedHeight)
+		}
+	}
+
+	inputs := make([]btcjson.TxInTimeLocksResult, 0, len(mtx.TxIn))
+	for _, txIn := range mtx.TxIn {
+		prevOut := &txIn.PreviousOutPoint
+		entry := view.LookupEntry(&prevOut.Hash)
+		if entry == nil || entry.IsOutputSpent(prevOut.Index) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCNoTxInfo,
+				Message: fmt.Sprintf("Output %v spent by the "+
+					"transaction does not exist or has already "+
+					"been spent", prevOut),
+			}
+		}
+
+		lockType, lock := relativeLock(mtx.Version, txIn.Sequence)
+		input := btcjson.TxInTimeLocksResult{
+			Txid:             prevOut.Hash.String(),
+			Vout:             prevOut.Index,
+			Sequence:         txIn.Sequence,
+			RelativeLockType: lockType,
+			RelativeLock:     lock,
+			ScriptLocks: scriptTimeLockResults(&mtx, txIn,
+				entry.PkScriptByIndex(prevOut.Index)),
+		}
+		if height := entry.BlockHeight(); height != mining.UnminedHeight {
+			input.PrevOutHeight = &height
+		}
+		inputs = append(inputs, input)
+	}
+
+	// Sequence locks are calculated the way the memory pool does, which
+	// always enforces them regardless of the state of the soft fork.
+	seqLock, err := s.cfg.Chain.CalcSequenceLock(tx, view, true)
+	if err != nil {
+		context := "Failed to calculate sequence locks"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// The lock-time does not apply when every input disables it.
+	lockTimeType := "none"
+	lockTimeEnabled := false
+	for _, txIn := range mtx.TxIn {
+		if txIn.Sequence != wire.MaxTxInSequenceNum {
+			lockTimeEnabled = true
+			break
+		}
+	}
+	if lockTimeEnabled && mtx.LockTime != 0 {
+		lockTimeType = absoluteLock(int64(mtx.LockTime))
+	}
+
+	// The lock-time and sequence locks must be less than the height of the
+	// including block and the median time of the blocks before it.
+	finalHeight := seqLock.BlockHeight + 1
+	finalMedianTime := seqLock.Seconds + 1
+	switch lockTimeType {
+	case "height":
+		if height := int32(mtx.LockTime) + 1; height > finalHeight {
+			finalHeight = height
+		}
+	case "time":
+		if t := int64(mtx.LockTime) + 1; t > finalMedianTime {
+			finalMedianTime = t
+		}
+	}
+
+	best := s.cfg.Chain.BestSnapshot()
+	nextHeight := best.Height + 1
+	final := blockchain.IsFinalizedTransaction(tx, nextHeight,
+		best.MedianTime) && blockchain.SequenceLockActive(seqLock,
+		nextHeight, best.MedianTime)
+
+	return &btcjson.GetTxTimeLocksResult{
+		Txid:            mtx.TxHash().String(),
+		LockTime:        mtx.LockTime,
+		LockTimeType:    lockTimeType,
+		Inputs:          inputs,
+		FinalHeight:     finalHeight,
+		FinalMedianTime: finalMedianTime,
+		Final:           final,
+	}, nil
+}
+
+// handleGetUtxoStats implements the getutxostats command.
+func handleGetUtxoStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetUtxoStatsCmd)
+
+	maxEntries := int32(100000)
+	if c.MaxEntries != nil {
+		maxEntries = *c.MaxEntries
+	}
+	if maxEntries < 1 || maxEntries > maxUtxoStatsEntries {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("maxentries must be between 1 and "+
+				"%d", maxUtxoStatsEntries),
+		}
+	}
+	heightBucketSize := int32(10000)
+	if c.HeightBucketSize != nil {
+		heightBucketSize = *c.HeightBucketSize
+	}
+	if heightBucketSize < 1 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "heightbucketsize must be positive",
+		}
+	}
+
+	// The cursor is the raw utxo set key to resume from.
+	var start *chainhash.Hash
+	if c.Cursor != nil && *c.Cursor != "" {
+		key, err := hex.DecodeString(*c.Cursor)
+		if err != nil || len(key) != chainhash.HashSize {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid cursor: " + *c.Cursor,
+			}
+		}
+		start = new(chainhash.Hash)
+		copy(start[:], key)
+	}
+
+	stats := newUtxoStats(heightBucketSize)
+	next, best, err := s.cfg.Chain.ForEachUtxoEntry(start, int(maxEntries),
+		func(_ *chainhash.Hash, entry *blockchain.UtxoEntry) error {
+			stats.AddEntry(entry)
+			return nil
+		})
+	if err != nil {
+		context := "Failed to load utxo set"
+		return nil, internalRPCError(err.Error(), context)
+	}
 
-	// Get further info about the script.
-	// Ignore the error here since an error means the script couldn't parse
-	// and there is no additional information about it anyways.
-	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript,
-		s.cfg.ChainParams)
-	addresses := make([]string, len(addrs))
-	for i, addr := range addrs {
-		addresses[i] = addr.EncodeAddress()
+	result := stats.Result()
+	result.BestHash = best.Hash.String()
+	result.Height = best.Height
+	if next != nil {
+		result.Cursor = hex.EncodeToString(next[:])
 	}
+	return result, nil
+}
 
-	txOutReply := &btcjson.GetTxOutResult{
-		BestBlock:     bestBlockHash,
-		Confirmations: int64(confirmations),
-		Value:         btcutil.Amount(value).ToBTC(),
-		Version:       txVersion,
-		ScriptPubKey: btcjson.ScriptPubKeyResult{
-			Asm:       disbuf,
-			Hex:       hex.EncodeToString(pkScript),
-			ReqSigs:   int32(reqSigs),
-			Type:      scriptClass.String(),
-			Addresses: addresses,
-		},
-		Coinbase: isCoinbase,
+// handleGetValidationStats implements the getvalidationstats command.
+func handleGetValidationStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	toMicros := func(d time.Duration) int64 {
+		return int64(d / time.Microsecond)
+	}
+
+	stats := s.cfg.Chain.ValidationStats()
+	phases := make([]btcjson.ValidationPhaseStatsResult, 0, len(stats))
+	for _, ps := range stats {
+		phases = append(phases, btcjson.ValidationPhaseStatsResult{
+			Phase:   ps.Phase.String(),
+			Samples: ps.Samples,
+			Mean:    toMicros(ps.Mean),
+			P50:     toMicros(ps.P50),
+			P90:     toMicros(ps.P90),
+			P99:     toMicros(ps.P99),
+			Max:     toMicros(ps.Max),
+		})
 	}
-	return txOutReply, nil
+	return &btcjson.GetValidationStatsResult{Phases: phases}, nil
 }
 
 // handleHelp implements the help command.
@@ -2729,6 +3734,49 @@ func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return help, nil
 }
 
+// handleInvalidateBlock implements the invalidateblock command.
+func handleInvalidateBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.InvalidateBlockCmd)
+
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+	if err := s.cfg.Chain.InvalidateBlock(hash); err != nil {
+		if _, ok := err.(blockchain.UnknownBlockError); ok {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: err.Error(),
+			}
+		}
+		context := "Failed to invalidate block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return nil, nil
+}
+
+// handleListAcceleratedTxs implements the listacceleratedtxs command.
+func handleListAcceleratedTxs(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	txs := s.cfg.Accelerator.Registered(time.Now())
+	result := make([]btcjson.AcceleratedTxResult, 0, len(txs))
+	for i := range txs {
+		tx := &txs[i]
+		result = append(result, btcjson.AcceleratedTxResult{
+			TxID:      tx.Hash.String(),
+			Boost:     tx.Boost,
+			Expires:   tx.Expires.Unix(),
+			InMempool: s.cfg.TxMemPool.HaveTransaction(&tx.Hash),
+		})
+	}
+	return result, nil
+}
+
+// handleListBanned implements the listbanned command.
+func handleListBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return s.cfg.ConnMgr.Bans(), nil
+}
+
 // handlePing implements the ping command.
 func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Ask server to ping \o_
@@ -2742,6 +3790,28 @@ func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return nil, nil
 }
 
+// handleReconsiderBlock implements the reconsiderblock command.
+func handleReconsiderBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ReconsiderBlockCmd)
+
+	hash, err := chainhash.NewHashFromStr(c.BlockHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.BlockHash)
+	}
+	if err := s.cfg.Chain.ReconsiderBlock(hash); err != nil {
+		if _, ok := err.(blockchain.UnknownBlockError); ok {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: err.Error(),
+			}
+		}
+		context := "Failed to reconsider block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return nil, nil
+}
+
 // retrievedTx represents a transaction that was either loaded from the
 // transaction memory pool or from the database.  When a transaction is loaded
 // from the database, it is loaded with the raw serialized bytes while the
@@ -3279,7 +4349,8 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	// Also, since an error is being returned to the caller, ensure the
 	// transaction is removed from the memory pool.
 	if len(acceptedTxs) == 0 || !acceptedTxs[0].Tx.Hash().IsEqual(tx.Hash()) {
-		s.cfg.TxMemPool.RemoveTransaction(tx, true)
+		s.cfg.TxMemPool.RemoveTransaction(tx, true,
+			mempool.RemovalReasonUnknown)
 
 		errStr := fmt.Sprintf("transaction %v is not in accepted list",
 			tx.Hash())
@@ -3304,6 +4375,76 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	return tx.Hash().String(), nil
 }
 
+// handleSetBan implements the setban command.
+func handleSetBan(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetBanCmd)
+
+	// Only IP addresses, subnets and onion addresses can be banned since
+	// those are the only addresses peers are known by.
+	target, err := parseBanTarget(c.Subnet)
+	if err == nil && target.subnet == nil &&
+		!strings.HasSuffix(c.Subnet, ".onion") {
+
+		err = errors.New("not an IP address, subnet or onion address")
+	}
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCClientInvalidIPOrSubnet,
+			Message: fmt.Sprintf("Invalid IP/Subnet %q: %v",
+				c.Subnet, err),
+		}
+	}
+
+	switch c.SubCmd {
+	case btcjson.SBAdd:
+		// The ban lasts for the configured ban duration by default.
+		// Otherwise, the ban time is either a number of seconds or an
+		// absolute unix time when the absolute flag is set.
+		now := time.Now()
+		until := now.Add(cfg.BanDuration)
+		if *c.BanTime != 0 {
+			until = now.Add(time.Duration(*c.BanTime) * time.Second)
+			if *c.Absolute {
+				until = time.Unix(*c.BanTime, 0)
+			}
+		}
+		if !until.After(now) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "The ban must end in the future",
+			}
+		}
+		err = s.cfg.ConnMgr.Ban(target, until)
+		if err == errAlreadyBanned {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientNodeAlreadyAdded,
+				Message: err.Error(),
+			}
+		}
+
+	case btcjson.SBRemove:
+		err = s.cfg.ConnMgr.Unban(target)
+		if err == errNotBanned {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientInvalidIPOrSubnet,
+				Message: err.Error(),
+			}
+		}
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "invalid subcommand for setban",
+		}
+	}
+	if err != nil {
+		return nil, internalRPCError(err.Error(), "Failed to save bans")
+	}
+
+	// no data returned unless an error.
+	return nil, nil
+}
+
 // handleSetGenerate implements the setgenerate command.
 func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.SetGenerateCmd)
@@ -3371,11 +4512,32 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 		}
 	}
 
+	// Report blocks which are already known as duplicates.
+	result, err := duplicateBlockResult(s, block.Hash())
+	if err != nil {
+		context := "Failed to check for duplicate block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if result != "" {
+		return result, nil
+	}
+
 	// Process this block using the same rules as blocks coming from other
 	// nodes.  This will in turn relay it to the network like normal.
-	_, err = s.cfg.SyncMgr.SubmitBlock(block, blockchain.BFNone)
+	// Rejected blocks are reported with the reasons described by BIP0022.
+	isOrphan, err := s.cfg.SyncMgr.SubmitBlock(block, blockchain.BFNone)
 	if err != nil {
-		return fmt.Sprintf("rejected: %s", err.Error()), nil
+		rpcsLog.Infof("Rejected block %s via submitblock: %v",
+			block.Hash(), err)
+		return chainErrToGBTErrString(err), nil
+	}
+
+	// The block was accepted, but whether it is valid can't be determined
+	// yet when it is an orphan or extends a side chain.
+	if isOrphan || !s.cfg.Chain.MainChainHasBlock(block.Hash()) {
+		rpcsLog.Infof("Accepted block %s via submitblock, but it is "+
+			"not part of the main chain", block.Hash())
+		return "inconclusive", nil
 	}
 
 	rpcsLog.Infof("Accepted block %s via submitblock", block.Hash())
@@ -3405,38 +4567,17 @@ func handleValidateAddress(s *rpcServer, cmd interface{}, closeChan <-chan struc
 }
 
 func verifyChain(s *rpcServer, level, depth int32) error {
-	best := s.cfg.Chain.BestSnapshot()
-	finishHeight := best.Height - depth
-	if finishHeight < 0 {
-		finishHeight = 0
-	}
-	rpcsLog.Infof("Verifying chain for %d blocks at level %d",
-		best.Height-finishHeight, level)
-
-	for height := best.Height; height > finishHeight; height-- {
-		// Level 0 just looks up the block.
-		block, err := s.cfg.Chain.BlockByHeight(height)
-		if err != nil {
-			rpcsLog.Errorf("Verify is unable to fetch block at "+
-				"height %d: %v", height, err)
+	err := s.cfg.Chain.VerifyChain(level, depth)
+	if err != nil {
+		if verr, ok := err.(blockchain.VerifyError); ok {
+			rpcsLog.Errorf("Chain verification failed at block %v "+
+				"(height %d): %s", verr.Hash, verr.Height,
+				verr.Description)
 			return err
 		}
-
-		// Level 1 does basic chain sanity checks.
-		if level > 0 {
-			err := blockchain.CheckBlockSanity(block,
-				s.cfg.ChainParams.PowLimit, s.cfg.TimeSource)
-			if err != nil {
-				rpcsLog.Errorf("Verify is unable to validate "+
-					"block at hash %v height %d: %v",
-					block.Hash(), height, err)
-				return err
-			}
-		}
+		rpcsLog.Errorf("Unable to verify chain: %v", err)
 	}
-	rpcsLog.Infof("Chain verify completed successfully")
-
-	return nil
+	return err
 }
 
 // handleVerifyChain implements the verifychain command.
@@ -3542,8 +4683,6 @@ type rpcServer struct {
 	limitauthsha           [sha256.Size]byte
 	ntfnMgr                *wsNotificationManager
 	numClients             int32
-	statusLines            map[int]string
-	statusLock             sync.RWMutex
 	wg                     sync.WaitGroup
 	gbtWorkState           *gbtWorkState
 	helpCacher             *helpCacher
@@ -3551,61 +4690,6 @@ type rpcServer struct {
 	quit                   chan int
 }
 
-// httpStatusLine returns a response Status-Line (RFC 2616 Section 6.1)
-// for the given request and response status code.  This function was lifted and
-// adapted from the standard library HTTP server code since it's not exported.
-func (s *rpcServer) httpStatusLine(req *http.Request, code int) string {
-	// Fast path:
-	key := code
-	proto11 := req.ProtoAtLeast(1, 1)
-	if !proto11 {
-		key = -key
-	}
-	s.statusLock.RLock()
-	line, ok := s.statusLines[key]
-	s.statusLock.RUnlock()
-	if ok {
-		return line
-	}
-
-	// Slow path:
-	proto := "HTTP/1.0"
-	if proto11 {
-		proto = "HTTP/1.1"
-	}
-	codeStr := strconv.Itoa(code)
-	text := http.StatusText(code)
-	if text != "" {
-		line = proto + " " + codeStr + " " + text + "\r\n"
-		s.statusLock.Lock()
-		s.statusLines[key] = line
-		s.statusLock.Unlock()
-	} else {
-		text = "status code " + codeStr
-		line = proto + " " + codeStr + " " + text + "\r\n"
-	}
-
-	return line
-}
-
-// writeHTTPResponseHeaders writes the necessary response headers prior to
-// writing an HTTP body given a request to use for protocol negotiation, headers
-// to write, a status code, and a writer.
-func (s *rpcServer) writeHTTPResponseHeaders(req *http.Request, headers http.Header, code int, w io.Writer) error {
-	_, err := io.WriteString(w, s.httpStatusLine(req, code))
-	if err != nil {
-		return err
-	}
-
-	err = headers.Write(w)
-	if err != nil {
-		return err
-	}
-
-	_, err = io.WriteString(w, "\r\n")
-	return err
-}
-
 // Stop is used by server.go to stop the rpc listener.
 func (s *rpcServer) Stop() error {
 	if atomic.AddInt32(&s.shutdown, 1) != 1 {
@@ -3620,6 +4704,12 @@ func (s *rpcServer) Stop() error {
 			return err
 		}
 	}
+	drained := s.ntfnMgr.Drain(s.cfg.Chain.BestSnapshot(),
+		cfg.ShutdownDowntime, cfg.RPCShutdownGrace)
+	if !drained {
+		rpcsLog.Warnf("Shutdown grace period of %v elapsed before all "+
+			"websocket clients were drained", cfg.RPCShutdownGrace)
+	}
 	s.ntfnMgr.Shutdown()
 	s.ntfnMgr.WaitForShutdown()
 	close(s.quit)
@@ -3645,19 +4735,35 @@ func (s *rpcServer) NotifyNewTransactions(txns []*mempool.TxDesc) {
 
 		// Potentially notify any getblocktemplate long poll clients
 		// about stale block templates due to the new transaction.
-		s.gbtWorkState.NotifyMempoolTx(s.cfg.TxMemPool.LastUpdated())
+		s.gbtWorkState.NotifyMempoolTx(s.cfg.TxMemPool.Generation())
 	}
 }
 
+// NotifyRemovedTransaction notifies websocket clients which requested memory
+// pool events that the passed transaction has been removed from the memory pool
+// for the passed reason.
+//
+// This function is safe for concurrent access.
+func (s *rpcServer) NotifyRemovedTransaction(tx *btcutil.Tx, reason mempool.RemovalReason) {
+	s.ntfnMgr.NotifyMempoolTxRemoved(tx, reason)
+
+	// Potentially notify any getblocktemplate long poll clients about
+	// stale block templates due to the removed transaction.
+	s.gbtWorkState.NotifyMempoolTx(s.cfg.TxMemPool.Generation())
+}
+
 // limitConnections responds with a 503 service unavailable and returns true if
-// adding another client would exceed the maximum allow RPC clients.
+// the connection of the request exceeds the maximum allowed RPC clients.
 //
 // This function is safe for concurrent access.
 func (s *rpcServer) limitConnections(w http.ResponseWriter, remoteAddr string) bool {
-	if int(atomic.LoadInt32(&s.numClients)+1) > cfg.RPCMaxClients {
+	// The connection of the client is already counted, so the limit is
+	// only exceeded when there are more clients.
+	if int(atomic.LoadInt32(&s.numClients)) > cfg.RPCMaxClients {
 		rpcsLog.Infof("Max RPC clients exceeded [%d] - "+
 			"disconnecting client %s", cfg.RPCMaxClients,
 			remoteAddr)
+		w.Header().Set("Connection", "close")
 		http.Error(w, "503 Too busy.  Try again later.",
 			http.StatusServiceUnavailable)
 		return true
@@ -3758,6 +4864,9 @@ func (s *rpcServer) standardCmdResult(cmd *parsedRPCCmd, closeChan <-chan struct
 	return nil, btcjson.ErrRPCMethodNotFound
 handled:
 
+	if s.cfg.TraceRecorder != nil {
+		s.cfg.TraceRecorder.recordRPC(cmd.method, cmd.cmd)
+	}
 	return handler(s, cmd.cmd, closeChan)
 }
 
@@ -3808,142 +4917,188 @@ func createMarshalledReply(id, result interface{}, replyErr error) ([]byte, erro
 	return btcjson.MarshalResponse(id, result, jsonErr)
 }
 
-// jsonRPCRead handles reading and responding to RPC messages.
-func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin bool) {
-	if atomic.LoadInt32(&s.shutdown) != 0 {
-		return
+// processRequest handles a single JSON-RPC request and returns the marshalled
+// reply, or nil when the request is a notification which must not be replied
+// to.  The passed channel is closed when the client disconnects.
+func (s *rpcServer) processRequest(request *btcjson.Request, isAdmin bool, closeChan <-chan struct{}) []byte {
+	// The JSON-RPC 1.0 spec defines that notifications must have their "id"
+	// set to null and states that notifications do not have a response.
+	//
+	// A JSON-RPC 2.0 notification is a request with "json-rpc":"2.0", and
+	// without an "id" member. The specification states that notifications
+	// must not be responded to. JSON-RPC 2.0 permits the null value as a
+	// valid request id, therefore such requests are not notifications.
+	//
+	// Bitcoin Core serves requests with "id":null or even an absent "id",
+	// and responds to such requests with "id":null in the response.
+	//
+	// Btcd does not respond to any request without and "id" or "id":null,
+	// regardless the indicated JSON-RPC protocol version unless RPC quirks
+	// are enabled. With RPC quirks enabled, such requests will be responded
+	// to if the reqeust does not indicate JSON-RPC version.
+	//
+	// RPC quirks can be enabled by the user to avoid compatibility issues
+	// with software relying on Core's behavior.
+	if request.ID == nil && !(cfg.RPCQuirks && request.Jsonrpc == "") {
+		return nil
 	}
 
-	// Read and close the JSON-RPC request body from the caller.
-	body, err := ioutil.ReadAll(r.Body)
-	r.Body.Close()
-	if err != nil {
-		errCode := http.StatusBadRequest
-		http.Error(w, fmt.Sprintf("%d error reading JSON message: %v",
-			errCode, err), errCode)
-		return
+	// Check if the user is limited and set error if method unauthorized
+	var result interface{}
+	var jsonErr error
+	if !isAdmin {
+		if _, ok := rpcLimited[request.Method]; !ok {
+			jsonErr = &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParams.Code,
+				Message: "limited user not authorized for this method",
+			}
+		}
 	}
 
-	// Unfortunately, the http server doesn't provide the ability to
-	// change the read deadline for the new connection and having one breaks
-	// long polling.  However, not having a read deadline on the initial
-	// connection would mean clients can connect and idle forever.  Thus,
-	// hijack the connecton from the HTTP server, clear the read deadline,
-	// and handle writing the response manually.
-	hj, ok := w.(http.Hijacker)
-	if !ok {
-		errMsg := "webserver doesn't support hijacking"
-		rpcsLog.Warnf(errMsg)
-		errCode := http.StatusInternalServerError
-		http.Error(w, strconv.Itoa(errCode)+" "+errMsg, errCode)
-		return
+	if jsonErr == nil {
+		// Attempt to parse the JSON-RPC request into a known concrete
+		// command.
+		parsedCmd := parseCmd(request)
+		if parsedCmd.err != nil {
+			jsonErr = parsedCmd.err
+		} else {
+			result, jsonErr = s.standardCmdResult(parsedCmd, closeChan)
+		}
 	}
-	conn, buf, err := hj.Hijack()
+
+	return s.marshalReply(request.ID, result, jsonErr)
+}
+
+// marshalReply marshals the reply to a JSON-RPC request.  Nil is returned when
+// it can't be marshalled.
+func (s *rpcServer) marshalReply(id, result interface{}, replyErr error) []byte {
+	msg, err := createMarshalledReply(id, result, replyErr)
 	if err != nil {
-		rpcsLog.Warnf("Failed to hijack HTTP connection: %v", err)
-		errCode := http.StatusInternalServerError
-		http.Error(w, strconv.Itoa(errCode)+" "+err.Error(), errCode)
-		return
+		rpcsLog.Errorf("Failed to marshal reply: %v", err)
+		return nil
 	}
-	defer conn.Close()
-	defer buf.Flush()
-	conn.SetReadDeadline(timeZeroVal)
+	return msg
+}
 
-	// Attempt to parse the raw body into a JSON-RPC request.
-	var responseID interface{}
-	var jsonErr error
-	var result interface{}
-	var request btcjson.Request
-	if err := json.Unmarshal(body, &request); err != nil {
-		jsonErr = &btcjson.RPCError{
+// isBatchRequest returns whether the passed JSON-RPC request body is a batch of
+// requests, which is a JSON array.
+func isBatchRequest(body []byte) bool {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// processBatch handles a batch of JSON-RPC requests and returns the marshalled
+// replies as a JSON array in the order of the requests.  Each request is
+// handled on its own, so a request which fails results in an error reply for
+// it without affecting the others.  Notifications are not replied to, so nil is
+// returned when the batch only consists of notifications.
+func (s *rpcServer) processBatch(body []byte, isAdmin bool, closeChan <-chan struct{}) []byte {
+	var rawRequests []json.RawMessage
+	if err := json.Unmarshal(body, &rawRequests); err != nil {
+		jsonErr := &btcjson.RPCError{
 			Code:    btcjson.ErrRPCParse.Code,
 			Message: "Failed to parse request: " + err.Error(),
 		}
+		return s.marshalReply(nil, nil, jsonErr)
 	}
-	if jsonErr == nil {
-		// The JSON-RPC 1.0 spec defines that notifications must have their "id"
-		// set to null and states that notifications do not have a response.
-		//
-		// A JSON-RPC 2.0 notification is a request with "json-rpc":"2.0", and
-		// without an "id" member. The specification states that notifications
-		// must not be responded to. JSON-RPC 2.0 permits the null value as a
-		// valid request id, therefore such requests are not notifications.
-		//
-		// Bitcoin Core serves requests with "id":null or even an absent "id",
-		// and responds to such requests with "id":null in the response.
-		//
-		// Btcd does not respond to any request without and "id" or "id":null,
-		// regardless the indicated JSON-RPC protocol version unless RPC quirks
-		// are enabled. With RPC quirks enabled, such requests will be responded
-		// to if the reqeust does not indicate JSON-RPC version.
-		//
-		// RPC quirks can be enabled by the user to avoid compatibility issues
-		// with software relying on Core's behavior.
-		if request.ID == nil && !(cfg.RPCQuirks && request.Jsonrpc == "") {
-			return
+	if len(rawRequests) == 0 {
+		jsonErr := &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidRequest.Code,
+			Message: "Empty batch request",
 		}
+		return s.marshalReply(nil, nil, jsonErr)
+	}
 
-		// The parse was at least successful enough to have an ID so
-		// set it for the response.
-		responseID = request.ID
-
-		// Setup a close notifier.  Since the connection is hijacked,
-		// the CloseNotifer on the ResponseWriter is not available.
-		closeChan := make(chan struct{}, 1)
-		go func() {
-			_, err := conn.Read(make([]byte, 1))
-			if err != nil {
-				close(closeChan)
-			}
-		}()
-
-		// Check if the user is limited and set error if method unauthorized
-		if !isAdmin {
-			if _, ok := rpcLimited[request.Method]; !ok {
-				jsonErr = &btcjson.RPCError{
-					Code:    btcjson.ErrRPCInvalidParams.Code,
-					Message: "limited user not authorized for this method",
-				}
+	var replies [][]byte
+	for _, rawRequest := range rawRequests {
+		var reply []byte
+		var request btcjson.Request
+		if err := json.Unmarshal(rawRequest, &request); err != nil {
+			jsonErr := &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidRequest.Code,
+				Message: "Failed to parse request: " + err.Error(),
 			}
+			reply = s.marshalReply(nil, nil, jsonErr)
+		} else {
+			reply = s.processRequest(&request, isAdmin, closeChan)
+		}
+		if reply != nil {
+			replies = append(replies, reply)
 		}
 
-		if jsonErr == nil {
-			// Attempt to parse the JSON-RPC request into a known concrete
-			// command.
-			parsedCmd := parseCmd(&request)
-			if parsedCmd.err != nil {
-				jsonErr = parsedCmd.err
-			} else {
-				result, jsonErr = s.standardCmdResult(parsedCmd, closeChan)
-			}
+		// Stop handling the remaining requests once the client is
+		// gone since nobody is left to receive the replies.
+		select {
+		case <-closeChan:
+			return nil
+		default:
 		}
 	}
+	if len(replies) == 0 {
+		return nil
+	}
 
-	// Marshal the response.
-	msg, err := createMarshalledReply(responseID, result, jsonErr)
-	if err != nil {
-		rpcsLog.Errorf("Failed to marshal reply: %v", err)
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	buf.Write(bytes.Join(replies, []byte{','}))
+	buf.WriteByte(']')
+	return buf.Bytes()
+}
+
+// jsonRPCRead handles reading and responding to RPC messages.  The body may be
+// a single JSON-RPC request or a batch of them.
+func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin bool) {
+	if atomic.LoadInt32(&s.shutdown) != 0 {
 		return
 	}
 
-	// Write the response.
-	err = s.writeHTTPResponseHeaders(r, w.Header(), http.StatusOK, buf)
+	// Read and close the JSON-RPC request body from the caller.
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
 	if err != nil {
-		rpcsLog.Error(err)
+		errCode := http.StatusBadRequest
+		http.Error(w, fmt.Sprintf("%d error reading JSON message: %v",
+			errCode, err), errCode)
 		return
 	}
-	if _, err := buf.Write(msg); err != nil {
-		rpcsLog.Errorf("Failed to write marshalled reply: %v", err)
+
+	// The context of the request is canceled when the client disconnects,
+	// which is used to stop long polling requests early.
+	closeChan := r.Context().Done()
+
+	// Attempt to parse the raw body into a JSON-RPC request or a batch of
+	// them and handle them.
+	var msg []byte
+	if isBatchRequest(body) {
+		msg = s.processBatch(body, isAdmin, closeChan)
+	} else {
+		var request btcjson.Request
+		if err := json.Unmarshal(body, &request); err != nil {
+			jsonErr := &btcjson.RPCError{
+				Code:    btcjson.ErrRPCParse.Code,
+				Message: "Failed to parse request: " + err.Error(),
+			}
+			msg = s.marshalReply(nil, nil, jsonErr)
+		} else {
+			msg = s.processRequest(&request, isAdmin, closeChan)
+		}
+	}
+	if msg == nil {
+		return
 	}
 
-	// Terminate with newline to maintain compatibility with Bitcoin Core.
-	if err := buf.WriteByte('\n'); err != nil {
-		rpcsLog.Errorf("Failed to append terminating newline to reply: %v", err)
+	// Write the response, terminated with a newline to maintain
+	// compatibility with Bitcoin Core.
+	if _, err := w.Write(append(msg, '\n')); err != nil {
+		rpcsLog.Errorf("Failed to write marshalled reply: %v", err)
 	}
 }
 
 // jsonAuthFail sends a message back to the client if the http auth is rejected.
 func jsonAuthFail(w http.ResponseWriter) {
+	// Close the connection so clients which failed to authenticate can't
+	// keep it open.
+	w.Header().Set("Connection", "close")
 	w.Header().Add("WWW-Authenticate", `Basic realm="btcd RPC"`)
 	http.Error(w, "401 Unauthorized.", http.StatusUnauthorized)
 }
@@ -3959,23 +5114,37 @@ func (s *rpcServer) Start() {
 	httpServer := &http.Server{
 		Handler: rpcServeMux,
 
-		// Timeout connections which don't complete the initial
-		// handshake within the allowed timeframe.
+		// Timeout connections which don't send a complete request,
+		// and thereby the credentials, within the allowed timeframe.
+		// The deadline only applies to reading the request, so
+		// handling it, such as a long poll, may take arbitrarily long.
 		ReadTimeout: time.Second * rpcAuthTimeoutSeconds,
+
+		// Keep connections open between requests so clients don't
+		// need to open a connection for each request.
+		IdleTimeout: rpcIdleTimeout,
+
+		// Keep track of the number of connected clients.  Connections
+		// kept open between requests count as well, so idle clients
+		// can't be used to exceed the limit.  Websocket connections
+		// are hijacked and tracked separately.
+		ConnState: func(conn net.Conn, state http.ConnState) {
+			switch state {
+			case http.StateNew:
+				s.incrementClients()
+			case http.StateHijacked, http.StateClosed:
+				s.decrementClients()
+			}
+		},
 	}
 	rpcServeMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Connection", "close")
 		w.Header().Set("Content-Type", "application/json")
-		r.Close = true
 
 		// Limit the number of connections to max allowed.
 		if s.limitConnections(w, r.RemoteAddr) {
 			return
 		}
 
-		// Keep track of the number of connected clients.
-		s.incrementClients()
-		defer s.decrementClients()
 		_, isAdmin, err := s.checkAuth(r, true)
 		if err != nil {
 			jsonAuthFail(w)
@@ -4064,6 +5233,18 @@ type rpcserverPeer interface {
 	// FeeFilter returns the requested current minimum fee rate for which
 	// transactions should be announced.
 	FeeFilter() int64
+
+	// IsBlockRelayOnly returns whether or not the peer is an outbound
+	// block-relay-only peer.
+	IsBlockRelayOnly() bool
+}
+
+// rpcAddedNode describes a node added with the addnode command or configured
+// to be connected to permanently along with the peer connected to it, which is
+// nil when the node is not connected.
+type rpcAddedNode struct {
+	Addr string
+	Peer rpcserverPeer
 }
 
 // rpcserverConnManager represents a connection manager for use with the RPC
@@ -4106,17 +5287,42 @@ type rpcserverConnManager interface {
 	// network for all peers.
 	NetTotals() (uint64, uint64)
 
+	// UploadTarget returns the state of the upload target which limits the
+	// outbound traffic.
+	UploadTarget() btcjson.UploadTargetResult
+
+	// Ban bans the passed IP address, subnet or host until the passed time
+	// and disconnects the connected peers the ban applies to.  Attempting
+	// to ban a target which is already banned will return an error.
+	Ban(target *banTarget, until time.Time) error
+
+	// Unban removes the ban of the passed IP address, subnet or host.
+	// Attempting to remove a ban which does not exist will return an
+	// error.
+	Unban(target *banTarget) error
+
+	// ClearBans removes all bans.
+	ClearBans() error
+
+	// Bans returns the bans which are in effect.
+	Bans() []btcjson.ListBannedResult
+
 	// ConnectedPeers returns an array consisting of all connected peers.
 	ConnectedPeers() []rpcserverPeer
 
-	// PersistentPeers returns an array consisting of all the persistent
-	// peers.
-	PersistentPeers() []rpcserverPeer
+	// AddedNodes returns an array consisting of all the added nodes,
+	// including the ones which are not connected, sorted by address.
+	AddedNodes() []rpcAddedNode
 
 	// BroadcastMessage sends the provided message to all currently
 	// connected peers.
 	BroadcastMessage(msg wire.Message)
 
+	// RebroadcastSet returns the locally submitted transactions which are
+	// rebroadcast until they show up in a block or are removed from the
+	// memory pool.
+	RebroadcastSet() []btcjson.RebroadcastTxResult
+
 	// AddRebroadcastInventory adds the provided inventory to the list of
 	// inventories to be rebroadcast at random intervals until they show up
 	// in a block.
@@ -4193,21 +5399,34 @@ type rpcserverConfig struct {
 	Generator *mining.BlkTmplGenerator
 	CPUMiner  *cpuminer.CPUMiner
 
+	// Accelerator holds the transactions registered with acceleratetx to
+	// be selected for block templates ahead of the others.
+	Accelerator *mining.TxAccelerator
+
 	// These fields define any optional indexes the RPC server can make use
 	// of to provide additional data when queried.
-	TxIndex   *indexers.TxIndex
-	AddrIndex *indexers.AddrIndex
+	TxIndex     *indexers.TxIndex
+	AddrIndex   *indexers.AddrIndex
+	RewardIndex *indexers.RewardIndex
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
 	FeeEstimator *mempool.FeeEstimator
+
+	// Supervisor restarts non-critical RPC server subsystems, such as the
+	// websocket notification manager, when they panic.  Any resulting
+	// warnings are reported via the errors field of getinfo.
+	Supervisor *supervisor
+
+	// TraceRecorder records the calls which change the chain or memory
+	// pool to a trace file.  It will be nil when no trace is recorded.
+	TraceRecorder *traceRecorder
 }
 
 // newRPCServer returns a new instance of the rpcServer struct.
 func newRPCServer(config *rpcserverConfig) (*rpcServer, error) {
 	rpc := rpcServer{
 		cfg:                    *config,
-		statusLines:            make(map[int]string),
 		gbtWorkState:           newGbtWorkState(config.TimeSource),
 		helpCacher:             newHelpCacher(),
 		requestProcessShutdown: make(chan struct{}),
@@ -4264,6 +5483,30 @@ func (s *rpcServer) handleBlockchainNotification(notification *blockchain.Notifi
 
 		// Notify registered websocket clients.
 		s.ntfnMgr.NotifyBlockDisconnected(block)
+
+	case blockchain.NTReorganization:
+		reorg, ok := notification.Data.(*blockchain.Reorganization)
+		if !ok {
+			rpcsLog.Warnf("Chain reorganization notification is not " +
+				"a reorganization.")
+			break
+		}
+
+		// The sync manager is subscribed before the RPC server, so the
+		// transactions of the disconnected blocks have already been
+		// returned to the memory pool, and the ones included in the
+		// connected blocks removed again, by the time this is called.
+		var returnedTxs []*chainhash.Hash
+		for _, block := range reorg.DisconnectedBlocks {
+			for _, tx := range block.Transactions()[1:] {
+				if s.cfg.TxMemPool.HaveTransaction(tx.Hash()) {
+					returnedTxs = append(returnedTxs, tx.Hash())
+				}
+			}
+		}
+
+		// Notify registered websocket clients.
+		s.ntfnMgr.NotifyReorganization(reorg, returnedTxs)
 	}
 }
 
@@ -0,0 +1,177 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// isClosed returns whether the passed channel has been closed.
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+// TestTemplateID ensures block template IDs round trip and malformed IDs are
+// rejected.
+func TestTemplateID(t *testing.T) {
+	t.Parallel()
+
+	prevHash := chainhash.Hash{0x01, 0x02}
+	id := encodeTemplateID(&prevHash, 18446744073709551615)
+	gotHash, gotGeneration, err := decodeTemplateID(id)
+	if err != nil {
+		t.Fatalf("decodeTemplateID: unexpected error: %v", err)
+	}
+	if *gotHash != prevHash || gotGeneration != 18446744073709551615 {
+		t.Fatalf("decodeTemplateID: got %v-%d, want %v-%d", gotHash,
+			gotGeneration, prevHash, uint64(18446744073709551615))
+	}
+
+	for _, id := range []string{"", prevHash.String(), "xyz-1",
+		prevHash.String() + "--1", prevHash.String() + "-1-2"} {
+
+		if _, _, err := decodeTemplateID(id); err == nil {
+			t.Errorf("decodeTemplateID(%q): expected error", id)
+		}
+	}
+}
+
+// TestLongPollNotify ensures long poll clients are notified once the chain tip
+// changes or a template is generated from a newer memory pool generation.
+func TestLongPollNotify(t *testing.T) {
+	t.Parallel()
+
+	state := newGbtWorkState(blockchain.NewMedianTime())
+	tip := chainhash.Hash{0x01}
+	newTip := chainhash.Hash{0x02}
+
+	old := state.templateUpdateChan(&tip, 5)
+	current := state.templateUpdateChan(&tip, 7)
+	if state.templateUpdateChan(&tip, 7) != current {
+		t.Fatal("templateUpdateChan: duplicate parameters returned " +
+			"a new channel")
+	}
+
+	// Only templates from older generations are stale.
+	state.notifyLongPollers(&tip, 7)
+	if !isClosed(old) || isClosed(current) {
+		t.Fatal("unexpected notification after new generation")
+	}
+
+	// All templates building on the old tip are stale once it changes.
+	state.notifyLongPollers(&newTip, 0)
+	if !isClosed(current) {
+		t.Fatal("no notification after tip change")
+	}
+	if len(state.notifyMap) != 0 {
+		t.Fatalf("unexpected registrations %v", state.notifyMap)
+	}
+}
+
+// TestJSONRPCBatch ensures batches of JSON-RPC requests are replied to in order
+// with an error reply for each request which fails.
+func TestJSONRPCBatch(t *testing.T) {
+	t.Parallel()
+
+	s := &rpcServer{}
+	post := func(body string, isAdmin bool) string {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		s.jsonRPCRead(w, r, isAdmin)
+		return w.Body.String()
+	}
+
+	// A request which can't be parsed, a method which does not exist, and
+	// invalid parameters must not affect the other requests.
+	reply := post(`[{"jsonrpc":"1.0","method":"version","params":[],"id":1},`+
+		`5,`+
+		`{"jsonrpc":"1.0","method":"nosuchmethod","params":[],"id":"a"},`+
+		`{"jsonrpc":"1.0","method":"version","params":[1],"id":3},`+
+		`{"jsonrpc":"1.0","method":"version","params":[],"id":4}]`, true)
+	var replies []btcjson.Response
+	if err := json.Unmarshal([]byte(reply), &replies); err != nil {
+		t.Fatalf("unable to parse batch reply %q: %v", reply, err)
+	}
+	tests := []struct {
+		id      string
+		errCode btcjson.RPCErrorCode
+	}{
+		{id: "1"},
+		{id: "null", errCode: btcjson.ErrRPCInvalidRequest.Code},
+		{id: `"a"`, errCode: btcjson.ErrRPCMethodNotFound.Code},
+		{id: "3", errCode: btcjson.ErrRPCInvalidParams.Code},
+		{id: "4"},
+	}
+	if len(replies) != len(tests) {
+		t.Fatalf("got %d replies, want %d: %s", len(replies),
+			len(tests), reply)
+	}
+	for i, test := range tests {
+		got := replies[i]
+		id := "null"
+		if got.ID != nil {
+			b, _ := json.Marshal(*got.ID)
+			id = string(b)
+		}
+		if id != test.id {
+			t.Errorf("reply #%d: got id %s, want %s", i, id, test.id)
+		}
+		switch {
+		case test.errCode == 0 && (got.Error != nil || got.Result == nil):
+			t.Errorf("reply #%d: unexpected error %v", i, got.Error)
+		case test.errCode != 0 && (got.Error == nil ||
+			got.Error.Code != test.errCode):
+			t.Errorf("reply #%d: got error %v, want code %d", i,
+				got.Error, test.errCode)
+		}
+	}
+
+	// An empty batch and a malformed batch result in a single error reply.
+	for _, body := range []string{"[]", " [1, "} {
+		var reply btcjson.Response
+		if err := json.Unmarshal([]byte(post(body, true)), &reply); err != nil {
+			t.Fatalf("unable to parse reply to %q: %v", body, err)
+		}
+		if reply.Error == nil {
+			t.Errorf("no error reply to %q", body)
+		}
+	}
+
+	// Methods which aren't available to limited users are rejected.
+	reply = post(`[{"jsonrpc":"1.0","method":"stop","params":[],"id":1}]`,
+		false)
+	replies = nil
+	if err := json.Unmarshal([]byte(reply), &replies); err != nil {
+		t.Fatalf("unable to parse batch reply %q: %v", reply, err)
+	}
+	if len(replies) != 1 || replies[0].Error == nil ||
+		replies[0].Error.Code != btcjson.ErrRPCInvalidParams.Code {
+
+		t.Fatalf("unexpected reply for limited user %q", reply)
+	}
+
+	// Single requests are replied to as before.
+	reply = post(`{"jsonrpc":"1.0","method":"version","params":[],"id":7}`,
+		true)
+	var single btcjson.Response
+	if err := json.Unmarshal([]byte(reply), &single); err != nil {
+		t.Fatalf("unable to parse reply %q: %v", reply, err)
+	}
+	if single.Error != nil || single.ID == nil || *single.ID != 7.0 {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+}
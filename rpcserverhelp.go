@@ -30,7 +30,7 @@ var helpDescsEnUS = map[string]string{
 	"debuglevel--result1":    "The list of subsystems",
 
 	// AddNodeCmd help.
-	"addnode--synopsis": "Attempts to add or remove a persistent peer.",
+	"addnode--synopsis": "Attempts to add or remove a persistent peer.  Added peers are saved and connected to again after a restart.",
 	"addnode-addr":      "IP address and port of the peer to operate on",
 	"addnode-subcmd":    "'add' to add a persistent peer, 'remove' to remove a persistent peer, or 'onetry' to try a single connection to a peer",
 
@@ -40,6 +40,30 @@ var helpDescsEnUS = map[string]string{
 	"node-target":        "Either the IP address and port of the peer to operate on, or a valid peer ID.",
 	"node-connectsubcmd": "'perm' to make the connected peer a permanent one, 'temp' to try a single connect to a peer",
 
+	// ClearBannedCmd help.
+	"clearbanned--synopsis": "Removes all bans, including the bans of misbehaving peers.",
+
+	// CompareChainsCmd help.
+	"comparechains--synopsis": "Compares the chains ending at two blocks, neither of which has to be in the main chain, and returns their fork point along with the length and work of each chain.",
+	"comparechains-hash1":     "The hash of the block the first chain ends at",
+	"comparechains-hash2":     "The hash of the block the second chain ends at",
+
+	// ComparedChainResult help.
+	"comparedchainresult-hash":         "The hash of the block the chain ends at",
+	"comparedchainresult-height":       "The height of the block the chain ends at",
+	"comparedchainresult-chainwork":    "The total cumulative work in the chain up to and including the block (hex-encoded)",
+	"comparedchainresult-branchlength": "The number of blocks in the chain after the fork point",
+	"comparedchainresult-branchwork":   "The work in the chain after the fork point (hex-encoded)",
+	"comparedchainresult-mainchain":    "Whether the block is in the main chain",
+
+	// CompareChainsResult help.
+	"comparechainsresult-forkhash":      "The hash of the last block both chains have in common",
+	"comparechainsresult-forkheight":    "The height of the last block both chains have in common",
+	"comparechainsresult-forkchainwork": "The total cumulative work in the chain up to and including the fork point (hex-encoded)",
+	"comparechainsresult-chain1":        "The chain ending at the first block",
+	"comparechainsresult-chain2":        "The chain ending at the second block",
+	"comparechainsresult-relativework":  "The work of the first chain minus the work of the second chain (hex-encoded, prefixed with '-' when negative)",
+
 	// TransactionInput help.
 	"transactioninput-txid": "The hash of the input transaction",
 	"transactioninput-vout": "The specific output of the input transaction to redeem",
@@ -95,6 +119,10 @@ var helpDescsEnUS = map[string]string{
 
 	// TxRawDecodeResult help.
 	"txrawdecoderesult-txid":     "The hash of the transaction",
+	"txrawdecoderesult-hash":     "The wtxid of the transaction",
+	"txrawdecoderesult-size":     "The size of the transaction in bytes",
+	"txrawdecoderesult-vsize":    "The virtual size of the transaction in bytes",
+	"txrawdecoderesult-weight":   "The weight of the transaction",
 	"txrawdecoderesult-version":  "The transaction version",
 	"txrawdecoderesult-locktime": "The transaction lock time",
 	"txrawdecoderesult-vin":      "The transaction inputs as JSON objects",
@@ -124,12 +152,36 @@ var helpDescsEnUS = map[string]string{
 	"estimatefee--result0": "Estimated fee per kilobyte in satoshis for a block to " +
 		"be mined in the next NumBlocks blocks.",
 
+	// FlushCacheCmd help.
+	"flushcache--synopsis": "Flushes the database cache and syncs the block files to disk, returning once all data is durable.\n" +
+		"The data directory can be snapshotted at the filesystem level in a consistent state afterwards as long as no new blocks are processed in the meantime.",
+
+	// FlushCacheResult help.
+	"flushcacheresult-hash":     "The hash of the best block which is known to be durable",
+	"flushcacheresult-height":   "The height of the best block which is known to be durable",
+	"flushcacheresult-duration": "The time it took to flush in milliseconds",
+
 	// GenerateCmd help
 	"generate--synopsis": "Generates a set number of blocks (simnet or regtest only) and returns a JSON\n" +
 		" array of their hashes.",
 	"generate-numblocks": "Number of blocks to generate",
 	"generate--result0":  "The hashes, in order, of blocks generated by the call",
 
+	// GenerateToAddressCmd help
+	"generatetoaddress--synopsis": "Generates a set number of blocks paying to the given address (simnet or regtest only) and returns a JSON\n" +
+		" array of their hashes.",
+	"generatetoaddress-numblocks": "Number of blocks to generate",
+	"generatetoaddress-address":   "The address the coinbase of each block pays to",
+	"generatetoaddress--result0":  "The hashes, in order, of blocks generated by the call",
+
+	// GenerateBlockCmd help
+	"generateblock--synopsis":    "Generates a single block paying to the given address which includes exactly the given transactions in order (simnet or regtest only).",
+	"generateblock-output":       "The address the coinbase of the block pays to",
+	"generateblock-transactions": "The transactions to include, each either the id of a transaction in the memory pool or a hex-encoded raw transaction",
+
+	// GenerateBlockResult help
+	"generateblockresult-hash": "The hash of the generated block",
+
 	// GetAddedNodeInfoResultAddr help.
 	"getaddednodeinforesultaddr-address":   "The ip address for this DNS entry",
 	"getaddednodeinforesultaddr-connected": "The connection 'direction' (inbound/outbound/false)",
@@ -140,7 +192,7 @@ var helpDescsEnUS = map[string]string{
 	"getaddednodeinforesult-addresses": "DNS lookup and connection information about the peer",
 
 	// GetAddedNodeInfo help.
-	"getaddednodeinfo--synopsis":   "Returns information about manually added (persistent) peers.",
+	"getaddednodeinfo--synopsis":   "Returns information about manually added (persistent) peers, including the ones which are not connected.",
 	"getaddednodeinfo-dns":         "Specifies whether the returned data is a JSON object including DNS and connection information, or just a list of added peers",
 	"getaddednodeinfo-node":        "Only return information about this specific peer instead of all added peers",
 	"getaddednodeinfo--condition0": "dns=false",
@@ -181,6 +233,8 @@ var helpDescsEnUS = map[string]string{
 	"getblockchaininforesult-verificationprogress":  "An estimate for how much of the best chain we've verified",
 	"getblockchaininforesult-pruned":                "A bool that indicates if the node is pruned or not",
 	"getblockchaininforesult-pruneheight":           "The lowest block retained in the current pruned chain",
+	"getblockchaininforesult-undopruned":            "Whether the undo data needed to disconnect blocks is pruned below undopruneheight",
+	"getblockchaininforesult-undopruneheight":       "The lowest block with undo data, so the chain can't be reorganized to a block below the previous one",
 	"getblockchaininforesult-chainwork":             "The total cumulative work in the best chain",
 	"getblockchaininforesult-softforks":             "The status of the super-majority soft-forks",
 	"getblockchaininforesult-bip9_softforks":        "JSON object describing active BIP0009 deployments",
@@ -207,6 +261,7 @@ var helpDescsEnUS = map[string]string{
 	"txrawresult-blocktime":     "Block time in seconds since the 1 Jan 1970 GMT",
 	"txrawresult-size":          "The size of the transation in bytes",
 	"txrawresult-vsize":         "The virtual size of the transaction in bytes",
+	"txrawresult-weight":        "The weight of the transaction",
 	"txrawresult-hash":          "The wtxid of the transaction",
 
 	// SearchRawTransactionsResult help.
@@ -238,6 +293,7 @@ var helpDescsEnUS = map[string]string{
 	"getblockverboseresult-nonce":             "The block nonce",
 	"getblockverboseresult-bits":              "The bits which represent the block difficulty",
 	"getblockverboseresult-difficulty":        "The proof-of-work difficulty as a multiple of the minimum difficulty",
+	"getblockverboseresult-chainwork":         "The total cumulative work in the chain up to and including the block (hex-encoded)",
 	"getblockverboseresult-previousblockhash": "The hash of the previous block",
 	"getblockverboseresult-nextblockhash":     "The hash of the next block (only if there is one)",
 	"getblockverboseresult-strippedsize":      "The size of the block without witness data",
@@ -271,9 +327,74 @@ var helpDescsEnUS = map[string]string{
 	"getblockheaderverboseresult-nonce":             "The block nonce",
 	"getblockheaderverboseresult-bits":              "The bits which represent the block difficulty",
 	"getblockheaderverboseresult-difficulty":        "The proof-of-work difficulty as a multiple of the minimum difficulty",
+	"getblockheaderverboseresult-chainwork":         "The total cumulative work in the chain up to and including the block (hex-encoded)",
 	"getblockheaderverboseresult-previousblockhash": "The hash of the previous block",
 	"getblockheaderverboseresult-nextblockhash":     "The hash of the next block (only if there is one)",
 
+	// GetBlockRewardsCmd help.
+	"getblockrewards--synopsis": "Returns the subsidy, fees, and coinbase value of a range of blocks in the main chain from the block reward index.\n" +
+		"Usage of this RPC requires the optional --rewardindex flag to be activated, and the index must have caught up with the requested blocks.",
+	"getblockrewards-startheight": "The height of the first block",
+	"getblockrewards-endheight":   "The height of the last block (max 2000 blocks per call)",
+	"getblockrewards-verbose":     "Also return the outputs of the coinbase of each block",
+
+	// BlockRewardsResult help.
+	"blockrewardsresult-hash":      "The hash of the block",
+	"blockrewardsresult-height":    "The height of the block in the block chain",
+	"blockrewardsresult-time":      "The block time in seconds since 1 Jan 1970 GMT",
+	"blockrewardsresult-subsidy":   "The subsidy of the block in satoshis",
+	"blockrewardsresult-fees":      "The total fees paid by the transactions of the block in satoshis",
+	"blockrewardsresult-coinbase":  "The total value of the coinbase outputs in satoshis",
+	"blockrewardsresult-unclaimed": "The part of the subsidy and fees the coinbase does not pay out in satoshis",
+	"blockrewardsresult-outputs":   "The outputs of the coinbase (only when verbose=true)",
+
+	// CoinbaseOutputResult help.
+	"coinbaseoutputresult-n":            "The index of the output",
+	"coinbaseoutputresult-value":        "The value of the output in satoshis",
+	"coinbaseoutputresult-scriptPubKey": "The public key script used to pay coins as a JSON object",
+
+	// GetBlockSubsidyCmd help.
+	"getblocksubsidy--synopsis": "Returns the subsidy of the block at a height according to the emission schedule of the network, along with the next halving and the fees paid or expected.\n" +
+		"The fees require the optional --rewardindex flag to be activated.",
+	"getblocksubsidy-height": "The height of the block (default: the height of the next block)",
+
+	// GetBlockSubsidyResult help.
+	"getblocksubsidyresult-height":            "The height of the block",
+	"getblocksubsidyresult-subsidy":           "The subsidy of the block in satoshis",
+	"getblocksubsidyresult-halvings":          "The number of times the subsidy was halved as of the block",
+	"getblocksubsidyresult-halvinginterval":   "The number of blocks between halvings (0 when the subsidy is never halved)",
+	"getblocksubsidyresult-nexthalvingheight": "The height of the first block after the block with a lower subsidy (only if there is one)",
+	"getblocksubsidyresult-nextsubsidy":       "The subsidy in satoshis as of the next halving (only if there is one)",
+	"getblocksubsidyresult-fees":              "The total fees paid by the transactions of the block in satoshis (only for blocks in the main chain)",
+	"getblocksubsidyresult-expectedfees":      "The average fees of the 144 most recent blocks in satoshis (only for blocks which are not mined yet)",
+
+	// GetBlockStatsCmd help.
+	"getblockstats--synopsis": "Returns statistics about a block in the main chain, including the resources used executing its scripts.\n" +
+		"The scripts are executed again, using the outputs they spend from the spend journal, to meter them.",
+	"getblockstats-hash":    "The hash of the block",
+	"getblockstats-verbose": "Also return the script statistics of each transaction",
+
+	// GetBlockStatsResult help.
+	"getblockstatsresult-hash":         "The hash of the block (same as provided)",
+	"getblockstatsresult-height":       "The height of the block in the block chain",
+	"getblockstatsresult-txs":          "The number of transactions in the block",
+	"getblockstatsresult-inputs":       "The number of transaction inputs in the block, including the coinbase input",
+	"getblockstatsresult-outputs":      "The number of transaction outputs in the block",
+	"getblockstatsresult-size":         "The serialized size of the block",
+	"getblockstatsresult-weight":       "The weight of the block as defined in BIP 141",
+	"getblockstatsresult-scripts":      "The resources used executing all of the scripts in the block",
+	"getblockstatsresult-transactions": "The resources used executing the scripts of each transaction except the coinbase (only when verbose=true)",
+
+	// ScriptStatsResult help.
+	"scriptstatsresult-opcodes":   "The number of opcodes executed, excluding those in conditional branches which were not taken",
+	"scriptstatsresult-stackpeak": "The largest combined number of elements on the data and alt stacks of any script execution",
+	"scriptstatsresult-sigops":    "The number of signature verifications performed",
+	"scriptstatsresult-hashops":   "The number of hash opcodes executed",
+
+	// TxScriptStatsResult help.
+	"txscriptstatsresult-txid":    "The hash of the transaction",
+	"txscriptstatsresult-scripts": "The resources used executing the scripts of the transaction inputs",
+
 	// TemplateRequest help.
 	"templaterequest-mode":         "This is 'template', 'proposal', or omitted",
 	"templaterequest-capabilities": "List of capabilities",
@@ -330,7 +451,22 @@ var helpDescsEnUS = map[string]string{
 	"getblocktemplate--condition0": "mode=template",
 	"getblocktemplate--condition1": "mode=proposal, rejected",
 	"getblocktemplate--condition2": "mode=proposal, accepted",
-	"getblocktemplate--result1":    "An error string which represents why the proposal was rejected or nothing if accepted",
+	"getblocktemplate--result1":    "An error string which represents why the proposal was rejected, 'duplicate' or 'duplicate-inconclusive' for known blocks, 'inconclusive-not-best-prevblk' for blocks which do not build on the best block, or nothing if accepted",
+
+	// GetChainTxStatsCmd help.
+	"getchaintxstats--synopsis": "Returns statistics about the total number and rate of transactions in the main chain.",
+	"getchaintxstats-nblocks":   "The number of blocks in the window, at most the number of blocks expected in one month (default: the number of blocks expected in one month)",
+	"getchaintxstats-blockhash": "The hash of the block which ends the window (default: the best block)",
+
+	// GetChainTxStatsResult help.
+	"getchaintxstatsresult-time":                      "The timestamp of the final block of the window in seconds since 1 Jan 1970 GMT",
+	"getchaintxstatsresult-txcount":                   "The total number of transactions in the main chain up to the final block of the window",
+	"getchaintxstatsresult-window_final_block_hash":   "The hash of the final block of the window",
+	"getchaintxstatsresult-window_final_block_height": "The height of the final block of the window",
+	"getchaintxstatsresult-window_block_count":        "The number of blocks in the window",
+	"getchaintxstatsresult-window_tx_count":           "The number of transactions in the window (only when window_block_count > 0)",
+	"getchaintxstatsresult-window_interval":           "The elapsed median time in the window in seconds (only when window_block_count > 0)",
+	"getchaintxstatsresult-txrate":                    "The average number of transactions per second in the window (only when window_interval > 0)",
 
 	// GetConnectionCountCmd help.
 	"getconnectioncount--synopsis": "Returns the number of active connections to other peers.",
@@ -395,7 +531,7 @@ var helpDescsEnUS = map[string]string{
 	"getmempoolinfo--synopsis": "Returns memory pool information",
 
 	// GetMempoolInfoResult help.
-	"getmempoolinforesult-bytes": "Size in bytes of the mempool",
+	"getmempoolinforesult-bytes": "Sum of the virtual sizes in bytes of the transactions in the mempool",
 	"getmempoolinforesult-size":  "Number of transactions in the mempool",
 
 	// GetMiningInfoResult help.
@@ -411,6 +547,9 @@ var helpDescsEnUS = map[string]string{
 	"getmininginforesult-networkhashps":      "Estimated network hashes per second for the most recent blocks",
 	"getmininginforesult-pooledtx":           "Number of transactions in the memory pool",
 	"getmininginforesult-testnet":            "Whether or not server is using testnet",
+	"getmininginforesult-nexthalvingheight":  "Height of the next block with a reduced subsidy (only if there is one)",
+	"getmininginforesult-blocksuntilhalving": "Number of blocks left to mine up to and including the next block with a reduced subsidy",
+	"getmininginforesult-nexthalvingtime":    "Estimated time of the next halving in seconds since 1 Jan 1970 GMT based on the target block spacing",
 
 	// GetMiningInfoCmd help.
 	"getmininginfo--synopsis": "Returns a JSON object containing mining-related information.",
@@ -428,6 +567,15 @@ var helpDescsEnUS = map[string]string{
 	"getnettotalsresult-totalbytesrecv": "Total bytes received",
 	"getnettotalsresult-totalbytessent": "Total bytes sent",
 	"getnettotalsresult-timemillis":     "Number of milliseconds since 1 Jan 1970 GMT",
+	"getnettotalsresult-uploadtarget":   "The state of the upload target",
+
+	// UploadTargetResult help.
+	"uploadtargetresult-timeframe":               "Duration of the cycles the upload target applies to in seconds",
+	"uploadtargetresult-target":                  "Target number of bytes to send per cycle, or 0 when there is no target",
+	"uploadtargetresult-target_reached":          "Whether or not the target has been reached during the current cycle",
+	"uploadtargetresult-serve_historical_blocks": "Whether or not blocks older than a week are served to peers which are not whitelisted",
+	"uploadtargetresult-bytes_left_in_cycle":     "Number of bytes which may still be sent during the current cycle",
+	"uploadtargetresult-time_left_in_cycle":      "Number of seconds left in the current cycle",
 
 	// GetPeerInfoResult help.
 	"getpeerinforesult-id":             "A unique node ID",
@@ -451,6 +599,12 @@ var helpDescsEnUS = map[string]string{
 	"getpeerinforesult-banscore":       "The ban score",
 	"getpeerinforesult-feefilter":      "The requested minimum fee a transaction must have to be announced to the peer",
 	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
+	"getpeerinforesult-blockrelayonly": "Whether or not the peer is an outbound block-relay-only peer, which neither relays transactions nor addresses",
+
+	"getpeerinforesult-maxuploadrate":     "Maximum rate in bytes per second at which blocks are sent to the peer, omitted when not limited",
+	"getpeerinforesult-maxdownloadrate":   "Maximum rate in bytes per second at which data is received from the peer, omitted when not limited",
+	"getpeerinforesult-uploadthrottled":   "Total number of seconds sending blocks to the peer was delayed by the rate limit",
+	"getpeerinforesult-downloadthrottled": "Total number of seconds receiving data from the peer was delayed by the rate limit",
 
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
@@ -464,6 +618,7 @@ var helpDescsEnUS = map[string]string{
 	"getrawmempoolverboseresult-currentpriority":  "Current priority",
 	"getrawmempoolverboseresult-depends":          "Unconfirmed transactions used as inputs for this transaction",
 	"getrawmempoolverboseresult-vsize":            "The virtual size of a transaction",
+	"getrawmempoolverboseresult-weight":           "The weight of a transaction",
 
 	// GetRawMempoolCmd help.
 	"getrawmempool--synopsis":   "Returns information about all of the transactions currently in the memory pool.",
@@ -494,6 +649,127 @@ var helpDescsEnUS = map[string]string{
 	"gettxout-vout":           "The index of the output",
 	"gettxout-includemempool": "Include the mempool when true",
 
+	// GetRebroadcastSetCmd help.
+	"getrebroadcastset--synopsis": "Returns the transactions submitted with sendrawtransaction which are announced to peers again periodically until they are included in a block or removed from the memory pool, for example because they expired.",
+	"getrebroadcastset--result0":  "The transactions sorted by the time they were submitted",
+
+	// RebroadcastTxResult help.
+	"rebroadcasttxresult-txid":          "The hash of the transaction",
+	"rebroadcasttxresult-added":         "The time the transaction was submitted in seconds since 1 Jan 1970 GMT",
+	"rebroadcasttxresult-lastbroadcast": "The time the transaction was last announced in seconds since 1 Jan 1970 GMT",
+	"rebroadcasttxresult-broadcasts":    "The number of times the transaction was announced",
+
+	// AccelerateTxCmd help.
+	"acceleratetx--synopsis": "Registers a transaction to be selected for block templates ahead of the transactions ordered by priority and fee rate, for example because its fee was paid out of band.\n" +
+		"Transactions with a higher boost are selected first, along with the transactions they depend on, and are included even when their fee rate is below the minimum relay fee.\n" +
+		"The transaction does not need to be in the memory pool yet.  Registering a transaction again replaces its boost and expiry.",
+	"acceleratetx-txid":   "The hash of the transaction",
+	"acceleratetx-boost":  "The priority boost of the transaction, higher boosts are selected first; 0 removes the registration",
+	"acceleratetx-expiry": "The number of seconds after which the registration expires",
+
+	// ListAcceleratedTxsCmd help.
+	"listacceleratedtxs--synopsis": "Returns the transactions registered with acceleratetx which did not expire yet.",
+	"listacceleratedtxs--result0":  "The transactions sorted by boost, highest first",
+
+	// AcceleratedTxResult help.
+	"acceleratedtxresult-txid":      "The hash of the transaction",
+	"acceleratedtxresult-boost":     "The priority boost of the transaction",
+	"acceleratedtxresult-expires":   "The time the registration expires in seconds since 1 Jan 1970 GMT",
+	"acceleratedtxresult-inmempool": "Whether the transaction is in the memory pool",
+
+	// GetPeerServicesCmd help.
+	"getpeerservices--synopsis": "Connects to the peer at the given address, performs the version handshake, measures the round trip time of a ping, and disconnects.\n" +
+		"The peer is not added to the connected peers, which makes this useful to monitor the health of arbitrary nodes.",
+	"getpeerservices-addr":    "The IP address or hostname of the peer with an optional port, which defaults to the port of the active network",
+	"getpeerservices-timeout": "The maximum number of seconds probing the peer may take (max 60)",
+
+	// GetPeerServicesResult help.
+	"getpeerservicesresult-addr":                 "The address of the peer",
+	"getpeerservicesresult-services":             "The services advertised by the peer",
+	"getpeerservicesresult-servicenames":         "The names of the services advertised by the peer",
+	"getpeerservicesresult-version":              "The protocol version of the peer",
+	"getpeerservicesresult-subver":               "The user agent of the peer",
+	"getpeerservicesresult-startingheight":       "The height of the best block of the peer",
+	"getpeerservicesresult-relaytxes":            "Whether the peer relays transactions by default",
+	"getpeerservicesresult-timeoffset":           "The difference between the time of the peer and the local time in seconds",
+	"getpeerservicesresult-connecttime":          "The number of milliseconds it took to connect to the peer",
+	"getpeerservicesresult-handshaketime":        "The number of milliseconds the version handshake took",
+	"getpeerservicesresult-pingtime":             "The round trip time of a ping in milliseconds, or 0 when the peer does not support pongs",
+	"getpeerservicesresult-messages":             "The commands of the messages the peer sent",
+	"getpeerservicesresult-compactblocks":        "Whether the peer announced support for compact blocks (BIP0152)",
+	"getpeerservicesresult-compactblockversions": "The compact block versions the peer announced",
+	"getpeerservicesresult-cfilters":             "Whether the peer advertised serving committed filters (BIP0157)",
+
+	// GetTxTimeLocksCmd help.
+	"gettxtimelocks--synopsis": "Returns the absolute and relative time locks imposed on a raw transaction and the earliest block it can be included in.\n" +
+		"The outputs spent by the transaction are loaded from the main chain and the memory pool, and outputs created by memory pool transactions are treated as if they were included in the next block.\n" +
+		"Sequence locks are evaluated the way the memory pool does, which always enforces them.",
+	"gettxtimelocks-hextx": "Serialized, hex-encoded transaction",
+
+	// GetTxTimeLocksResult help.
+	"gettxtimelocksresult-txid":            "The hash of the transaction",
+	"gettxtimelocksresult-locktime":        "The transaction lock time",
+	"gettxtimelocksresult-locktimetype":    "The type of the lock time (none, height, or time), which is none when it is zero or every input disables it",
+	"gettxtimelocksresult-inputs":          "The time locks of each input",
+	"gettxtimelocksresult-finalheight":     "The earliest height of a block which can include the transaction",
+	"gettxtimelocksresult-finalmediantime": "The earliest median time of the blocks before a block which can include the transaction",
+	"gettxtimelocksresult-final":           "Whether or not the time locks allow the transaction to be included in the next block",
+
+	// TxInTimeLocksResult help.
+	"txintimelocksresult-txid":             "The hash of the transaction which created the spent output",
+	"txintimelocksresult-vout":             "The index of the spent output",
+	"txintimelocksresult-sequence":         "The input sequence number",
+	"txintimelocksresult-prevoutheight":    "The height of the block which created the spent output, omitted when it is in the memory pool",
+	"txintimelocksresult-relativelocktype": "The type of the BIP0068 relative lock imposed by the sequence number (none, blocks, or seconds)",
+	"txintimelocksresult-relativelock":     "The number of blocks or seconds the spent output must have been confirmed for",
+	"txintimelocksresult-scriptlocks":      "The OP_CHECKLOCKTIMEVERIFY and OP_CHECKSEQUENCEVERIFY opcodes in the scripts executed by the input",
+
+	// ScriptTimeLockResult help.
+	"scripttimelockresult-script":    "The script containing the opcode (pkscript, redeemscript, or witnessscript)",
+	"scripttimelockresult-opcode":    "The name of the opcode",
+	"scripttimelockresult-value":     "The lock time or sequence required by the opcode, omitted when it is not pushed right before it",
+	"scripttimelockresult-type":      "The type of the required lock (height, time, blocks, seconds, none, or unknown)",
+	"scripttimelockresult-satisfied": "Whether or not the transaction satisfies the opcode",
+
+	// GetUtxoStatsCmd help.
+	"getutxostats--synopsis": "Returns statistics about a page of the unspent transaction output set grouped by script type, value, and creation height.\n" +
+		"The utxo set is walked in pages of transactions to bound the time the chain is locked for.\n" +
+		"Statistics for the whole set are obtained by passing the returned cursor to the next call until no cursor is returned and summing the results of all pages.",
+	"getutxostats-cursor":           "The cursor returned by the previous call to continue from, or omitted or empty to start from the beginning of the utxo set",
+	"getutxostats-maxentries":       "The maximum number of transactions with unspent outputs to process (max 1000000)",
+	"getutxostats-heightbucketsize": "The number of blocks each creation height bucket spans",
+
+	// GetUtxoStatsResult help.
+	"getutxostatsresult-besthash":     "The hash of the best block the page reflects",
+	"getutxostatsresult-height":       "The height of the best block the page reflects",
+	"getutxostatsresult-transactions": "The number of transactions with unspent outputs processed",
+	"getutxostatsresult-outputs":      "The number of unspent outputs processed",
+	"getutxostatsresult-amount":       "The total value of the unspent outputs in satoshis",
+	"getutxostatsresult-scripttypes":  "The unspent outputs grouped by script type",
+	"getutxostatsresult-values":       "The unspent outputs grouped by value in satoshis",
+	"getutxostatsresult-heights":      "The unspent outputs grouped by the height of the block which created them",
+	"getutxostatsresult-cursor":       "The cursor to pass to the next call, or omitted when the end of the utxo set was reached",
+
+	// UtxoStatsBucket help.
+	"utxostatsbucket-bucket":  "The script type, or the inclusive range of values or heights, of the group",
+	"utxostatsbucket-outputs": "The number of unspent outputs in the group",
+	"utxostatsbucket-amount":  "The total value of the unspent outputs in the group in satoshis",
+
+	// GetValidationStatsCmd help.
+	"getvalidationstats--synopsis": "Returns rolling timing statistics for each phase of block validation over the most recently processed blocks.",
+
+	// GetValidationStatsResult help.
+	"getvalidationstatsresult-phases": "The statistics of each validation phase",
+
+	// ValidationPhaseStatsResult help.
+	"validationphasestatsresult-phase":   "The validation phase (headerchecks, contextchecks, utxofetch, scriptvalidation, indexupdate, or dbflush)",
+	"validationphasestatsresult-samples": "The number of recent samples the statistics are calculated over",
+	"validationphasestatsresult-mean":    "The mean duration in microseconds",
+	"validationphasestatsresult-p50":     "The median duration in microseconds",
+	"validationphasestatsresult-p90":     "The 90th percentile duration in microseconds",
+	"validationphasestatsresult-p99":     "The 99th percentile duration in microseconds",
+	"validationphasestatsresult-max":     "The maximum duration in microseconds",
+
 	// HelpCmd help.
 	"help--synopsis":   "Returns a list of all commands or help for a specified command.",
 	"help-command":     "The command to retrieve help for",
@@ -502,10 +778,51 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// ApproveReorgCmd help.
+	"approvereorg--synopsis": "Reorganizes the chain to a competing chain which was held back because the reorganization disconnects more blocks than allowed by --maxreorgdepth.\n" +
+		"The chain is reorganized to the block with the most work of the competing chain the given block belongs to.",
+	"approvereorg-blockhash": "The hash of any block of the competing chain after the fork point, as returned by getpendingreorgs",
+
+	// GetPendingReorgsCmd help.
+	"getpendingreorgs--synopsis": "Returns the competing chains with more work than the main chain which were not switched to because the reorganization disconnects more blocks than allowed by --maxreorgdepth.\n" +
+		"They are kept in memory only, so they are forgotten on restart.",
+
+	// PendingReorgResult help.
+	"pendingreorgresult-forkhash":      "The hash of the last block the competing chain has in common with the main chain",
+	"pendingreorgresult-forkheight":    "The height of the fork point",
+	"pendingreorgresult-tiphash":       "The hash of the block the competing chain ends at",
+	"pendingreorgresult-tipheight":     "The height of the block the competing chain ends at",
+	"pendingreorgresult-detach":        "The number of blocks the reorganization disconnects from the main chain",
+	"pendingreorgresult-attach":        "The number of blocks the reorganization connects to the main chain",
+	"pendingreorgresult-tipchainwork":  "The total work of the competing chain (hex-encoded)",
+	"pendingreorgresult-mainchainwork": "The total work of the main chain (hex-encoded)",
+	"pendingreorgresult-firstseen":     "The time the reorganization was first held back in seconds since 1 Jan 1970 GMT",
+
+	// InvalidateBlockCmd help.
+	"invalidateblock--synopsis": "Permanently marks a block as invalid, as if it violated a consensus rule.\n" +
+		"The block and its descendants are disconnected from the main chain when they are part of it and their transactions are returned to the memory pool.\n" +
+		"The chain then switches to the valid chain with the most work.\n" +
+		"The mark is stored in the database, so it survives restarts.",
+	"invalidateblock-blockhash": "The hash of the block to mark as invalid",
+
+	// ListBannedCmd help.
+	"listbanned--synopsis": "Returns the banned IP addresses, subnets and onion addresses.",
+
+	// ListBannedResult help.
+	"listbannedresult-address":      "The banned IP address or subnet in CIDR notation, or onion address",
+	"listbannedresult-banned_until": "The time the ban expires in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-ban_created":  "The time the ban was added in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-ban_reason":   "Why the ban was added, either 'manually added' or 'node misbehaving'",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
 
+	// ReconsiderBlockCmd help.
+	"reconsiderblock--synopsis": "Removes the invalidity status set by invalidateblock from a block, its ancestors and its descendants.\n" +
+		"The chain then switches to the valid chain with the most work, which validates the blocks again.",
+	"reconsiderblock-blockhash": "The hash of the block to reconsider",
+
 	// SearchRawTransactionsCmd help.
 	"searchrawtransactions--synopsis": "Returns raw data for transactions involving the passed address.\n" +
 		"Returned transactions are pulled from both the database, and transactions currently in the mempool.\n" +
@@ -529,6 +846,13 @@ var helpDescsEnUS = map[string]string{
 	"sendrawtransaction-allowhighfees": "Whether or not to allow insanely high fees (btcd does not yet implement this parameter, so it has no effect)",
 	"sendrawtransaction--result0":      "The hash of the transaction",
 
+	// SetBanCmd help.
+	"setban--synopsis": "Bans or unbans an IP address, subnet or onion address.  Adding a ban disconnects the connected peers it applies to.",
+	"setban-subnet":    "The IP address, subnet in CIDR notation (e.g. 192.168.0.0/24), or onion address",
+	"setban-subcmd":    "'add' to add a ban or 'remove' to remove one",
+	"setban-bantime":   "The number of seconds the ban lasts, or the unix time it ends when absolute is set (0 for the configured ban duration)",
+	"setban-absolute":  "Whether bantime is an absolute unix time",
+
 	// SetGenerateCmd help.
 	"setgenerate--synopsis":    "Set the server to generate coins (mine) or not.",
 	"setgenerate-generate":     "Use true to enable generation, false to disable it",
@@ -546,8 +870,8 @@ var helpDescsEnUS = map[string]string{
 	"submitblock-hexblock":    "Serialized, hex-encoded block",
 	"submitblock-options":     "This parameter is currently ignored",
 	"submitblock--condition0": "Block successfully submitted",
-	"submitblock--condition1": "Block rejected",
-	"submitblock--result1":    "The reason the block was rejected",
+	"submitblock--condition1": "Block rejected or not accepted into the main chain",
+	"submitblock--result1":    "The reason the block was rejected, 'duplicate' or 'duplicate-inconclusive' for known blocks, or 'inconclusive' for blocks which are not part of the main chain",
 
 	// ValidateAddressResult help.
 	"validateaddresschainresult-isvalid": "Whether or not the address is valid",
@@ -562,9 +886,14 @@ var helpDescsEnUS = map[string]string{
 		"The actual checks performed by the checklevel parameter are implementation specific.\n" +
 		"For btcd this is:\n" +
 		"checklevel=0 - Look up each block and ensure it can be loaded from the database.\n" +
-		"checklevel=1 - Perform basic context-free sanity checks on each block.",
+		"checklevel=1 - Perform basic context-free sanity checks on each block.\n" +
+		"checklevel=2 - Disconnect each block from the utxo set in memory using its spend journal entry, which must be consistent with the block.\n" +
+		"checklevel=3 - Ensure the outputs created and spent by each block are consistent with the utxo set before disconnecting it.\n" +
+		"checklevel=4 - Reconnect the disconnected blocks with full validation and ensure the resulting spend journal entries and utxos match the database.\n" +
+		"Levels 2 and above are limited to as many of the most recent blocks as fit in memory.\n" +
+		"The first inconsistency found is logged with the hash and height of the block.",
 	"verifychain-checklevel": "How thorough the block verification is",
-	"verifychain-checkdepth": "The number of blocks to check",
+	"verifychain-checkdepth": "The number of blocks to check (0 = all)",
 	"verifychain--result0":   "Whether or not the chain verified",
 
 	// VerifyMessageCmd help.
@@ -639,6 +968,105 @@ var helpDescsEnUS = map[string]string{
 	"rescannedblock-hash":         "Hash of the matching block.",
 	"rescannedblock-transactions": "List of matching transactions, serialized and hex-encoded.",
 
+	// RescanBlockchainCmd help.
+	"rescanblockchain--synopsis": "Rescan the main chain for transactions paying to, or spending outputs paying to, the provided output scripts.\n" +
+		"Blocks are read directly from the block files and progress is reported with rescanblockchainprogress notifications.\n" +
+		"The rescan may be stopped with abortrescan, in which case the results found so far are returned.",
+	"rescanblockchain-descriptors": "List of output script descriptors (raw, addr, pk, pkh, wpkh, or sh(wpkh) with hex public keys) or hex-encoded output scripts to scan for",
+	"rescanblockchain-startheight": "Height of the first block to rescan",
+	"rescanblockchain-stopheight":  "Height of the last block to rescan (default: current best height)",
+
+	// RescanBlockchainResult help.
+	"rescanblockchainresult-start_height": "Height of the first block that was rescanned",
+	"rescanblockchainresult-stop_height":  "Height of the last block that was rescanned",
+	"rescanblockchainresult-aborted":      "Whether or not the rescan was stopped before reaching the requested stop height",
+	"rescanblockchainresult-transactions": "List of matching transactions",
+
+	// RescannedTx help.
+	"rescannedtx-txid":        "Hash of the matching transaction",
+	"rescannedtx-blockhash":   "Hash of the block containing the transaction",
+	"rescannedtx-blockheight": "Height of the block containing the transaction",
+	"rescannedtx-hex":         "Serialized, hex-encoded transaction",
+
+	// AbortRescanCmd help.
+	"abortrescan--synopsis": "Stops the rescanblockchain operation that is currently underway for the websocket client.",
+	"abortrescan--result0":  "Whether or not a rescan was underway and has been aborted",
+
+	// GetBlocksBatchCmd help.
+	"getblocksbatch--synopsis": "Returns multiple serialized blocks at once, each prefixed by its length as a little-endian uint32, optionally compressed as a whole.\n" +
+		"The blocks are either requested by hash or as a range of main chain blocks.\n" +
+		"Fewer blocks than requested are returned once the serialized blocks exceed 32 MiB, in which case the remaining blocks should be requested again.",
+	"getblocksbatch-request":     "The blocks to return",
+	"getblocksbatch-compression": "The compression of the returned blocks (none or gzip)",
+
+	// BlocksBatchRequest help.
+	"blocksbatchrequest-hashes":      "Hashes of the blocks to return, at most 1000",
+	"blocksbatchrequest-startheight": "Height of the first main chain block to return when the hashes are not provided",
+	"blocksbatchrequest-count":       "Number of main chain blocks to return, at most 1000 (default: 100)",
+
+	// GetBlocksBatchResult help.
+	"getblocksbatchresult-count":       "Number of blocks returned",
+	"getblocksbatchresult-nextheight":  "Height of the main chain block following the returned blocks, only set when the blocks are requested by height",
+	"getblocksbatchresult-compression": "The compression of the returned blocks",
+	"getblocksbatchresult-size":        "Size of the framed blocks before compression",
+	"getblocksbatchresult-data":        "The framed and compressed blocks, base64 encoded",
+
+	// FetchBlocksRawCmd help.
+	"fetchblocksraw--synopsis": "Start a stream of binary websocket messages following the reply, one for each of a range of main chain blocks.\n" +
+		"Each message holds the height of the block as a little-endian uint32 followed by the serialized block as it is stored in the database.\n" +
+		"Blocks must be acknowledged with ackfetchblocksraw since only window unacknowledged blocks are sent, and a fetchblocksrawdone notification is sent once the stream ends.",
+	"fetchblocksraw-startheight": "Height of the first main chain block to send",
+	"fetchblocksraw-count":       "Number of main chain blocks to send, limited to the blocks up to the current best block",
+	"fetchblocksraw-window":      "Maximum number of unacknowledged blocks, at most 1024",
+
+	// FetchBlocksRawResult help.
+	"fetchblocksrawresult-startheight": "Height of the first block to be sent",
+	"fetchblocksrawresult-count":       "Number of blocks to be sent",
+	"fetchblocksrawresult-window":      "Maximum number of unacknowledged blocks",
+
+	// AckFetchBlocksRawCmd help.
+	"ackfetchblocksraw--synopsis": "Acknowledge that all blocks of the fetchblocksraw stream up to and including the provided height have been processed.",
+	"ackfetchblocksraw-height":    "Height of the last processed block",
+
+	// StopFetchBlocksRawCmd help.
+	"stopfetchblocksraw--synopsis": "Stop the fetchblocksraw stream that is currently underway for the websocket client.",
+	"stopfetchblocksraw--result0":  "Whether or not a stream was underway and has been stopped",
+
+	// NotifyChainEventsCmd help.
+	"notifychainevents--synopsis": "Start a stream of chainevent notifications which deliver the blocks connected to and disconnected from the main chain in the order they must be applied to mirror it.\n" +
+		"The stream first moves the client from the last block it processed to the best block, disconnecting blocks which are no longer in the main chain, and then follows the chain.\n" +
+		"Events must be acknowledged with ackchainevents since only a limited number of unacknowledged events are sent.",
+	"notifychainevents-lastblock":     "Hash of the last block the client has processed, which need not be in the main chain (default: the current best block)",
+	"notifychainevents-sequence":      "Sequence number of the last event the client has processed; the first event sent has the following sequence number",
+	"notifychainevents-includeblocks": "Include the serialized, hex-encoded block in each notification",
+
+	// NotifyChainEventsResult help.
+	"notifychaineventsresult-hash":     "Hash of the block the stream starts from",
+	"notifychaineventsresult-height":   "Height of the block the stream starts from",
+	"notifychaineventsresult-sequence": "Sequence number of the last processed event; the first event sent has the following sequence number",
+
+	// AckChainEventsCmd help.
+	"ackchainevents--synopsis": "Acknowledge that all chain events up to and including the provided sequence number have been processed.",
+	"ackchainevents-sequence":  "Sequence number of the last processed chain event",
+
+	// StopNotifyChainEventsCmd help.
+	"stopnotifychainevents--synopsis": "Stop the stream of chainevent notifications started with notifychainevents.",
+
+	// NotifyMempoolEventsCmd help.
+	"notifymempoolevents--synopsis": "Send a txremoved notification when a transaction is removed from the memory pool, along with the reason (block, conflict, reorg, expiry, sizelimit or unknown).\n" +
+		"Optionally also send a mempoolfeehistogram notification with the number and size of the transactions in the memory pool by fee rate right away and every 10 seconds.",
+	"notifymempoolevents-feehistogram": "Also send the periodic fee rate histogram",
+
+	// StopNotifyMempoolEventsCmd help.
+	"stopnotifymempoolevents--synopsis": "Stop the notifications requested with notifymempoolevents.",
+
+	// NotifyReorgCmd help.
+	"notifyreorg--synopsis": "Send a reorganization notification when the main chain is reorganized.\n" +
+		"The notification includes the fork point, the hashes of the disconnected and connected blocks, and the hashes of the transactions of the disconnected blocks which were returned to the memory pool.",
+
+	// StopNotifyReorgCmd help.
+	"stopnotifyreorg--synopsis": "Stop the reorganization notifications requested with notifyreorg.",
+
 	// Uptime help.
 	"uptime--synopsis": "Returns the total uptime of the server.",
 	"uptime--result0":  "The number of seconds that the server has been running",
@@ -662,13 +1090,20 @@ var helpDescsEnUS = map[string]string{
 // This information is used to generate the help.  Each result type must be a
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
+	"acceleratetx":          nil,
 	"addnode":               nil,
+	"approvereorg":          nil,
+	"clearbanned":           nil,
+	"comparechains":         {(*btcjson.CompareChainsResult)(nil)},
 	"createrawtransaction":  {(*string)(nil)},
 	"debuglevel":            {(*string)(nil), (*string)(nil)},
 	"decoderawtransaction":  {(*btcjson.TxRawDecodeResult)(nil)},
 	"decodescript":          {(*btcjson.DecodeScriptResult)(nil)},
 	"estimatefee":           {(*float64)(nil)},
+	"flushcache":            {(*btcjson.FlushCacheResult)(nil)},
 	"generate":              {(*[]string)(nil)},
+	"generateblock":         {(*btcjson.GenerateBlockResult)(nil)},
+	"generatetoaddress":     {(*[]string)(nil)},
 	"getaddednodeinfo":      {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
 	"getbestblock":          {(*btcjson.GetBestBlockResult)(nil)},
 	"getbestblockhash":      {(*string)(nil)},
@@ -676,8 +1111,12 @@ var rpcResultTypes = map[string][]interface{}{
 	"getblockcount":         {(*int64)(nil)},
 	"getblockhash":          {(*string)(nil)},
 	"getblockheader":        {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
+	"getblockrewards":       {(*[]btcjson.BlockRewardsResult)(nil)},
+	"getblocksubsidy":       {(*btcjson.GetBlockSubsidyResult)(nil)},
+	"getblockstats":         {(*btcjson.GetBlockStatsResult)(nil)},
 	"getblocktemplate":      {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
 	"getblockchaininfo":     {(*btcjson.GetBlockChainInfoResult)(nil)},
+	"getchaintxstats":       {(*btcjson.GetChainTxStatsResult)(nil)},
 	"getconnectioncount":    {(*int32)(nil)},
 	"getcurrentnet":         {(*uint32)(nil)},
 	"getdifficulty":         {(*float64)(nil)},
@@ -693,11 +1132,22 @@ var rpcResultTypes = map[string][]interface{}{
 	"getrawmempool":         {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
 	"getrawtransaction":     {(*string)(nil), (*btcjson.TxRawResult)(nil)},
 	"gettxout":              {(*btcjson.GetTxOutResult)(nil)},
+	"getpeerservices":       {(*btcjson.GetPeerServicesResult)(nil)},
+	"getpendingreorgs":      {(*[]btcjson.PendingReorgResult)(nil)},
+	"getrebroadcastset":     {(*[]btcjson.RebroadcastTxResult)(nil)},
+	"gettxtimelocks":        {(*btcjson.GetTxTimeLocksResult)(nil)},
+	"getutxostats":          {(*btcjson.GetUtxoStatsResult)(nil)},
+	"getvalidationstats":    {(*btcjson.GetValidationStatsResult)(nil)},
+	"listacceleratedtxs":    {(*[]btcjson.AcceleratedTxResult)(nil)},
 	"node":                  nil,
 	"help":                  {(*string)(nil), (*string)(nil)},
+	"invalidateblock":       nil,
+	"listbanned":            {(*[]btcjson.ListBannedResult)(nil)},
 	"ping":                  nil,
+	"reconsiderblock":       nil,
 	"searchrawtransactions": {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
 	"sendrawtransaction":    {(*string)(nil)},
+	"setban":                nil,
 	"setgenerate":           nil,
 	"stop":                  {(*string)(nil)},
 	"submitblock":           {nil, (*string)(nil)},
@@ -720,6 +1170,19 @@ var rpcResultTypes = map[string][]interface{}{
 	"stopnotifyspent":           nil,
 	"rescan":                    nil,
 	"rescanblocks":              {(*[]btcjson.RescannedBlock)(nil)},
+	"rescanblockchain":          {(*btcjson.RescanBlockchainResult)(nil)},
+	"abortrescan":               {(*bool)(nil)},
+	"notifychainevents":         {(*btcjson.NotifyChainEventsResult)(nil)},
+	"getblocksbatch":            {(*btcjson.GetBlocksBatchResult)(nil)},
+	"fetchblocksraw":            {(*btcjson.FetchBlocksRawResult)(nil)},
+	"ackfetchblocksraw":         nil,
+	"stopfetchblocksraw":        {(*bool)(nil)},
+	"ackchainevents":            nil,
+	"stopnotifychainevents":     nil,
+	"notifymempoolevents":       nil,
+	"stopnotifymempoolevents":   nil,
+	"notifyreorg":               nil,
+	"stopnotifyreorg":           nil,
 }
 
 // helpCacher provides a concurrent safe type that provides help and usage for
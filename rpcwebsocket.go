@@ -27,6 +27,7 @@ import (
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
@@ -40,8 +41,21 @@ const (
 	// handler since notifications have their own queuing mechanism
 	// independent of the send channel buffer.
 	websocketSendBufferSize = 50
+
+	// mempoolFeeHistogramInterval is the interval at which the fee rate
+	// histogram of the memory pool is sent to the websocket clients which
+	// requested it.
+	mempoolFeeHistogramInterval = time.Second * 10
 )
 
+// mempoolFeeHistogramBounds are the minimum fee rates, in satoshi per
+// kilobyte, of the buckets of the memory pool fee rate histogram.
+var mempoolFeeHistogramBounds = []int64{
+	0, 1000, 2000, 3000, 4000, 5000, 6000, 8000, 10000, 12000, 15000,
+	20000, 25000, 30000, 40000, 50000, 70000, 100000, 150000, 200000,
+	300000, 500000, 1000000,
+}
+
 type semaphore chan struct{}
 
 func makeSemaphore(n int) semaphore {
@@ -64,6 +78,10 @@ type wsCommandHandler func(*wsClient, interface{}) (interface{}, error)
 // causes a dependency loop.
 var wsHandlers map[string]wsCommandHandler
 var wsHandlersBeforeInit = map[string]wsCommandHandler{
+	"getblocksbatch":            handleGetBlocksBatch,
+	"fetchblocksraw":            handleFetchBlocksRaw,
+	"ackfetchblocksraw":         handleAckFetchBlocksRaw,
+	"stopfetchblocksraw":        handleStopFetchBlocksRaw,
 	"loadtxfilter":              handleLoadTxFilter,
 	"help":                      handleWebsocketHelp,
 	"notifyblocks":              handleNotifyBlocks,
@@ -77,6 +95,15 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 	"stopnotifyreceived":        handleStopNotifyReceived,
 	"rescan":                    handleRescan,
 	"rescanblocks":              handleRescanBlocks,
+	"rescanblockchain":          handleRescanBlockchain,
+	"abortrescan":               handleAbortRescan,
+	"notifychainevents":         handleNotifyChainEvents,
+	"ackchainevents":            handleAckChainEvents,
+	"stopnotifychainevents":     handleStopNotifyChainEvents,
+	"notifymempoolevents":       handleNotifyMempoolEvents,
+	"stopnotifymempoolevents":   handleStopNotifyMempoolEvents,
+	"notifyreorg":               handleNotifyReorg,
+	"stopnotifyreorg":           handleStopNotifyReorg,
 }
 
 // WebsocketHandler handles a new websocket client by creating a new wsClient,
@@ -140,6 +167,17 @@ type wsNotificationManager struct {
 	// Access channel for current number of connected clients.
 	numClients chan int
 
+	// draining is closed once the RPC server begins shutting down.  New
+	// subscriptions are refused from then on and in-flight rescans stop
+	// at the next block.  rescans tracks the rescans which are in
+	// progress so shutdown can give them a grace period to report how far
+	// they got.  drainMtx protects isDraining, which ensures no rescan is
+	// started once shutdown waits for them.
+	drainMtx   sync.Mutex
+	isDraining bool
+	draining   chan struct{}
+	rescans    sync.WaitGroup
+
 	// Shutdown handling
 	wg   sync.WaitGroup
 	quit chan struct{}
@@ -247,6 +285,47 @@ func (m *wsNotificationManager) NotifyMempoolTx(tx *btcutil.Tx, isNew bool) {
 	}
 }
 
+// NotifyMempoolTxRemoved passes a transaction removed from the mempool along
+// with the reason to the notification manager for transaction notification
+// processing.
+func (m *wsNotificationManager) NotifyMempoolTxRemoved(tx *btcutil.Tx, reason mempool.RemovalReason) {
+	n := &notificationTxRemovedFromMempool{
+		tx:     tx,
+		reason: reason,
+	}
+
+	// As NotifyMempoolTxRemoved will be called by mempool and the RPC
+	// server may no longer be running, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun shutting
+	// down.
+	select {
+	case m.queueNotification <- n:
+	case <-m.quit:
+	}
+}
+
+// NotifyReorganization passes a reorganization of the main chain along with
+// the hashes of the transactions of the disconnected blocks which were returned
+// to the mempool to the notification manager for reorganization notification
+// processing.
+func (m *wsNotificationManager) NotifyReorganization(reorg *blockchain.Reorganization,
+	returnedTxs []*chainhash.Hash) {
+
+	n := &notificationReorganization{
+		reorg:       reorg,
+		returnedTxs: returnedTxs,
+	}
+
+	// As NotifyReorganization will be called by the block manager and the
+	// RPC server may no longer be running, use a select statement to
+	// unblock enqueuing the notification once the RPC server has begun
+	// shutting down.
+	select {
+	case m.queueNotification <- n:
+	case <-m.quit:
+	}
+}
+
 // wsClientFilter tracks relevant addresses for each websocket client for
 // the `rescanblocks` extension. It is modified by the `loadtxfilter` command.
 //
@@ -452,6 +531,19 @@ type notificationTxAcceptedByMempool struct {
 	isNew bool
 	tx    *btcutil.Tx
 }
+type notificationTxRemovedFromMempool struct {
+	tx     *btcutil.Tx
+	reason mempool.RemovalReason
+}
+type notificationReorganization struct {
+	reorg       *blockchain.Reorganization
+	returnedTxs []*chainhash.Hash
+}
+type notificationServerShutdown struct {
+	best     *blockchain.BestState
+	downtime time.Duration
+	done     chan struct{}
+}
 
 // Notification control requests
 type notificationRegisterClient wsClient
@@ -476,12 +568,26 @@ type notificationUnregisterAddr struct {
 	wsc  *wsClient
 	addr string
 }
+type notificationRegisterChainEvents struct {
+	wsc    *wsClient
+	stream *chainEventStream
+}
+type notificationUnregisterChainEvents wsClient
+type notificationRegisterMempoolEvents struct {
+	wsc          *wsClient
+	feeHistogram bool
+}
+type notificationUnregisterMempoolEvents wsClient
+type notificationRegisterReorg wsClient
+type notificationUnregisterReorg wsClient
 
-// notificationHandler reads notifications and control messages from the queue
-// handler and processes one at a time.
-func (m *wsNotificationManager) notificationHandler() {
+// wsNotificationState houses the connected clients and their notification
+// registrations which are maintained by the notification handler.  It is kept
+// separate from the handler so the registrations survive the handler being
+// restarted by the supervisor after a panic.
+type wsNotificationState struct {
 	// clients is a map of all currently connected websocket clients.
-	clients := make(map[chan struct{}]*wsClient)
+	clients map[chan struct{}]*wsClient
 
 	// Maps used to hold lists of websocket clients to be notified on
 	// certain events.  Each websocket client also keeps maps for the events
@@ -490,10 +596,57 @@ func (m *wsNotificationManager) notificationHandler() {
 	//
 	// Where possible, the quit channel is used as the unique id for a client
 	// since it is quite a bit more efficient than using the entire struct.
-	blockNotifications := make(map[chan struct{}]*wsClient)
-	txNotifications := make(map[chan struct{}]*wsClient)
-	watchedOutPoints := make(map[wire.OutPoint]map[chan struct{}]*wsClient)
-	watchedAddrs := make(map[string]map[chan struct{}]*wsClient)
+	blockNotifications map[chan struct{}]*wsClient
+	txNotifications    map[chan struct{}]*wsClient
+	watchedOutPoints   map[wire.OutPoint]map[chan struct{}]*wsClient
+	watchedAddrs       map[string]map[chan struct{}]*wsClient
+
+	// chainEventStreams holds the chain event streams of the clients
+	// which have requested them.  The streams are notified whenever the
+	// chain changes.
+	chainEventStreams map[chan struct{}]*chainEventStream
+
+	// mempoolEvents holds the clients which requested notifications of
+	// transactions removed from the memory pool, and feeHistograms the
+	// ones which also requested the periodic fee rate histogram.
+	mempoolEvents map[chan struct{}]*wsClient
+	feeHistograms map[chan struct{}]*wsClient
+
+	// reorgNotifications holds the clients which requested notifications
+	// of reorganizations of the main chain.
+	reorgNotifications map[chan struct{}]*wsClient
+}
+
+// newWsNotificationState returns a new empty notification handler state.
+func newWsNotificationState() *wsNotificationState {
+	return &wsNotificationState{
+		clients:            make(map[chan struct{}]*wsClient),
+		blockNotifications: make(map[chan struct{}]*wsClient),
+		txNotifications:    make(map[chan struct{}]*wsClient),
+		watchedOutPoints:   make(map[wire.OutPoint]map[chan struct{}]*wsClient),
+		watchedAddrs:       make(map[string]map[chan struct{}]*wsClient),
+		chainEventStreams:  make(map[chan struct{}]*chainEventStream),
+		mempoolEvents:      make(map[chan struct{}]*wsClient),
+		feeHistograms:      make(map[chan struct{}]*wsClient),
+		reorgNotifications: make(map[chan struct{}]*wsClient),
+	}
+}
+
+// notificationHandler reads notifications and control messages from the queue
+// handler and processes one at a time.
+func (m *wsNotificationManager) notificationHandler(state *wsNotificationState) {
+	clients := state.clients
+	blockNotifications := state.blockNotifications
+	txNotifications := state.txNotifications
+	watchedOutPoints := state.watchedOutPoints
+	watchedAddrs := state.watchedAddrs
+	chainEventStreams := state.chainEventStreams
+	mempoolEvents := state.mempoolEvents
+	feeHistograms := state.feeHistograms
+	reorgNotifications := state.reorgNotifications
+
+	histogramTicker := time.NewTicker(mempoolFeeHistogramInterval)
+	defer histogramTicker.Stop()
 
 out:
 	for {
@@ -522,6 +675,9 @@ out:
 					m.notifyFilteredBlockConnected(blockNotifications,
 						block)
 				}
+				for _, stream := range chainEventStreams {
+					stream.Notify()
+				}
 
 			case *notificationBlockDisconnected:
 				block := (*btcutil.Block)(n)
@@ -532,6 +688,9 @@ out:
 					m.notifyFilteredBlockDisconnected(blockNotifications,
 						block)
 				}
+				for _, stream := range chainEventStreams {
+					stream.Notify()
+				}
 
 			case *notificationTxAcceptedByMempool:
 				if n.isNew && len(txNotifications) != 0 {
@@ -540,6 +699,21 @@ out:
 				m.notifyForTx(watchedOutPoints, watchedAddrs, n.tx, nil)
 				m.notifyRelevantTxAccepted(n.tx, clients)
 
+			case *notificationTxRemovedFromMempool:
+				if len(mempoolEvents) != 0 {
+					m.notifyTxRemoved(mempoolEvents, n.tx,
+						n.reason)
+				}
+
+			case *notificationReorganization:
+				if len(reorgNotifications) != 0 {
+					m.notifyReorganization(reorgNotifications,
+						n.reorg, n.returnedTxs)
+				}
+
+			case *notificationServerShutdown:
+				m.notifyServerShutdown(clients, n)
+
 			case *notificationRegisterBlocks:
 				wsc := (*wsClient)(n)
 				blockNotifications[wsc.quit] = wsc
@@ -558,6 +732,9 @@ out:
 				// the client itself.
 				delete(blockNotifications, wsc.quit)
 				delete(txNotifications, wsc.quit)
+				delete(mempoolEvents, wsc.quit)
+				delete(feeHistograms, wsc.quit)
+				delete(reorgNotifications, wsc.quit)
 				for k := range wsc.spentRequests {
 					op := k
 					m.removeSpentRequest(watchedOutPoints, wsc, &op)
@@ -565,6 +742,10 @@ out:
 				for addr := range wsc.addrRequests {
 					m.removeAddrRequest(watchedAddrs, wsc, addr)
 				}
+				if stream, ok := chainEventStreams[wsc.quit]; ok {
+					stream.Stop()
+					delete(chainEventStreams, wsc.quit)
+				}
 				delete(clients, wsc.quit)
 
 			case *notificationRegisterSpent:
@@ -579,6 +760,19 @@ out:
 			case *notificationUnregisterAddr:
 				m.removeAddrRequest(watchedAddrs, n.wsc, n.addr)
 
+			case *notificationRegisterChainEvents:
+				if old, ok := chainEventStreams[n.wsc.quit]; ok {
+					old.Stop()
+				}
+				chainEventStreams[n.wsc.quit] = n.stream
+
+			case *notificationUnregisterChainEvents:
+				wsc := (*wsClient)(n)
+				if stream, ok := chainEventStreams[wsc.quit]; ok {
+					stream.Stop()
+					delete(chainEventStreams, wsc.quit)
+				}
+
 			case *notificationRegisterNewMempoolTxs:
 				wsc := (*wsClient)(n)
 				txNotifications[wsc.quit] = wsc
@@ -587,10 +781,43 @@ out:
 				wsc := (*wsClient)(n)
 				delete(txNotifications, wsc.quit)
 
+			case *notificationRegisterMempoolEvents:
+				mempoolEvents[n.wsc.quit] = n.wsc
+				if !n.feeHistogram {
+					delete(feeHistograms, n.wsc.quit)
+					break
+				}
+
+				// Send the current histogram right away so
+				// the client doesn't have to wait for the next
+				// interval.
+				feeHistograms[n.wsc.quit] = n.wsc
+				m.notifyFeeHistogram(map[chan struct{}]*wsClient{
+					n.wsc.quit: n.wsc,
+				})
+
+			case *notificationUnregisterMempoolEvents:
+				wsc := (*wsClient)(n)
+				delete(mempoolEvents, wsc.quit)
+				delete(feeHistograms, wsc.quit)
+
+			case *notificationRegisterReorg:
+				wsc := (*wsClient)(n)
+				reorgNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterReorg:
+				wsc := (*wsClient)(n)
+				delete(reorgNotifications, wsc.quit)
+
 			default:
 				rpcsLog.Warn("Unhandled notification type")
 			}
 
+		case <-histogramTicker.C:
+			if len(feeHistograms) != 0 {
+				m.notifyFeeHistogram(feeHistograms)
+			}
+
 		case m.numClients <- len(clients):
 
 		case <-m.quit:
@@ -598,11 +825,6 @@ out:
 			break out
 		}
 	}
-
-	for _, c := range clients {
-		c.Disconnect()
-	}
-	m.wg.Done()
 }
 
 // NumClients returns the number of clients actively being served.
@@ -808,6 +1030,24 @@ func (*wsNotificationManager) notifyFilteredBlockDisconnected(clients map[chan s
 	}
 }
 
+// RegisterChainEvents registers the passed chain event stream of the passed
+// websocket client so it is notified whenever the chain changes.  Any previous
+// stream of the client is stopped.
+func (m *wsNotificationManager) RegisterChainEvents(wsc *wsClient,
+	stream *chainEventStream) {
+
+	m.queueNotification <- &notificationRegisterChainEvents{
+		wsc:    wsc,
+		stream: stream,
+	}
+}
+
+// UnregisterChainEvents stops the chain event stream of the passed websocket
+// client, if any.
+func (m *wsNotificationManager) UnregisterChainEvents(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterChainEvents)(wsc)
+}
+
 // RegisterNewMempoolTxsUpdates requests notifications to the passed websocket
 // client when new transactions are added to the memory pool.
 func (m *wsNotificationManager) RegisterNewMempoolTxsUpdates(wsc *wsClient) {
@@ -869,6 +1109,108 @@ func (m *wsNotificationManager) notifyForNewTx(clients map[chan struct{}]*wsClie
 	}
 }
 
+// RegisterMempoolEvents requests notifications to the passed websocket client
+// when transactions are removed from the memory pool.  The fee rate histogram
+// of the memory pool is also sent periodically when feeHistogram is set.
+func (m *wsNotificationManager) RegisterMempoolEvents(wsc *wsClient, feeHistogram bool) {
+	m.queueNotification <- &notificationRegisterMempoolEvents{
+		wsc:          wsc,
+		feeHistogram: feeHistogram,
+	}
+}
+
+// UnregisterMempoolEvents removes the memory pool event notifications to the
+// passed websocket client.
+func (m *wsNotificationManager) UnregisterMempoolEvents(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterMempoolEvents)(wsc)
+}
+
+// notifyTxRemoved notifies websocket clients that have registered for memory
+// pool events that a transaction has been removed from the memory pool.
+func (*wsNotificationManager) notifyTxRemoved(clients map[chan struct{}]*wsClient,
+	tx *btcutil.Tx, reason mempool.RemovalReason) {
+
+	ntfn := btcjson.NewTxRemovedNtfn(tx.Hash().String(), string(reason))
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal tx removed notification: %v",
+			err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
+// RegisterReorg requests reorganization notifications to the passed websocket
+// client.
+func (m *wsNotificationManager) RegisterReorg(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterReorg)(wsc)
+}
+
+// UnregisterReorg removes reorganization notifications for the passed
+// websocket client.
+func (m *wsNotificationManager) UnregisterReorg(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterReorg)(wsc)
+}
+
+// notifyReorganization notifies websocket clients that have registered for
+// reorganization notifications that the main chain has been reorganized.
+func (*wsNotificationManager) notifyReorganization(clients map[chan struct{}]*wsClient,
+	reorg *blockchain.Reorganization, returnedTxs []*chainhash.Hash) {
+
+	disconnected := make([]string, 0, len(reorg.DisconnectedBlocks))
+	for _, block := range reorg.DisconnectedBlocks {
+		disconnected = append(disconnected, block.Hash().String())
+	}
+	connected := make([]string, 0, len(reorg.ConnectedBlocks))
+	for _, block := range reorg.ConnectedBlocks {
+		connected = append(connected, block.Hash().String())
+	}
+	txs := make([]string, 0, len(returnedTxs))
+	for _, hash := range returnedTxs {
+		txs = append(txs, hash.String())
+	}
+
+	ntfn := btcjson.NewReorganizationNtfn(reorg.ForkHash.String(),
+		reorg.ForkHeight, disconnected, connected, txs)
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal reorganization notification: "+
+			"%v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
+// notifyFeeHistogram sends the current fee rate histogram of the memory pool to
+// the passed websocket clients.
+func (m *wsNotificationManager) notifyFeeHistogram(clients map[chan struct{}]*wsClient) {
+	histogram := m.server.cfg.TxMemPool.FeeRateHistogram(
+		mempoolFeeHistogramBounds)
+	buckets := make([]btcjson.FeeHistogramBucket, 0, len(histogram))
+	for _, bucket := range histogram {
+		buckets = append(buckets, btcjson.FeeHistogramBucket{
+			FeeRate: bucket.MinFeePerKB,
+			Count:   bucket.Count,
+			Size:    bucket.Size,
+		})
+	}
+
+	ntfn := btcjson.NewMempoolFeeHistogramNtfn(time.Now().Unix(), buckets)
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal fee histogram notification: "+
+			"%v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // RegisterSpentRequests requests a notification when each of the passed
 // outpoints is confirmed spent (contained in a block connected to the main
 // chain) for the passed websocket client.  The request is automatically
@@ -1177,10 +1519,138 @@ func (m *wsNotificationManager) RemoveClient(wsc *wsClient) {
 
 // Start starts the goroutines required for the manager to queue and process
 // websocket client notifications.
+//
+// The notification handler is supervised so a panic while notifying clients
+// results in the handler being restarted with its registrations intact rather
+// than bringing down the process.  Notifications queued while it is restarting
+// are buffered by the queue handler.
 func (m *wsNotificationManager) Start() {
 	m.wg.Add(2)
 	go m.queueHandler()
-	go m.notificationHandler()
+	go func() {
+		state := newWsNotificationState()
+		m.server.cfg.Supervisor.Run("websocket notifications", func() {
+			m.notificationHandler(state)
+		})
+
+		for _, c := range state.clients {
+			c.Disconnect()
+		}
+		m.wg.Done()
+	}()
+}
+
+// beginRescan registers a rescan which is about to start.  It returns false
+// when the RPC server is shutting down, in which case the rescan must not be
+// started.  Every successful call must be paired with a call to endRescan.
+func (m *wsNotificationManager) beginRescan() bool {
+	m.drainMtx.Lock()
+	defer m.drainMtx.Unlock()
+
+	if m.isDraining {
+		return false
+	}
+	m.rescans.Add(1)
+	return true
+}
+
+// endRescan marks a rescan registered with beginRescan as finished.
+func (m *wsNotificationManager) endRescan() {
+	m.rescans.Done()
+}
+
+// Draining returns whether the RPC server has begun shutting down, in which
+// case no new subscriptions are accepted.
+func (m *wsNotificationManager) Draining() bool {
+	select {
+	case <-m.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// Drain prepares the connected websocket clients for the RPC server shutting
+// down.  New subscriptions and rescans are refused, every client is sent a
+// servershutdown notification with the passed best block and estimated
+// downtime, and the rescans in progress are stopped.  It blocks until the
+// notification has been sent and the rescans have reported the last block
+// they processed, or until the grace period has elapsed.  It returns false
+// when the grace period elapsed first.
+func (m *wsNotificationManager) Drain(best *blockchain.BestState,
+	downtime, grace time.Duration) bool {
+
+	m.drainMtx.Lock()
+	if m.isDraining {
+		m.drainMtx.Unlock()
+		return true
+	}
+	m.isDraining = true
+	close(m.draining)
+	m.drainMtx.Unlock()
+
+	n := &notificationServerShutdown{
+		best:     best,
+		downtime: downtime,
+		done:     make(chan struct{}),
+	}
+	select {
+	case m.queueNotification <- n:
+	case <-m.quit:
+		return true
+	}
+
+	rescansDone := make(chan struct{})
+	go func() {
+		m.rescans.Wait()
+		close(rescansDone)
+	}()
+
+	timeout := time.After(grace)
+	for n.done != nil || rescansDone != nil {
+		select {
+		case <-n.done:
+			n.done = nil
+		case <-rescansDone:
+			rescansDone = nil
+		case <-timeout:
+			return false
+		}
+	}
+	return true
+}
+
+// notifyServerShutdown sends a servershutdown notification to all of the
+// passed websocket clients.  The notification bypasses the notification queue
+// of each client so the done channel of the passed request can be closed once
+// it has been written to every client.
+func (*wsNotificationManager) notifyServerShutdown(clients map[chan struct{}]*wsClient,
+	n *notificationServerShutdown) {
+
+	ntfn := btcjson.NewServerShutdownNtfn(n.best.Hash.String(),
+		n.best.Height, int64(n.downtime/time.Second))
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal server shutdown notification: "+
+			"%v", err)
+		close(n.done)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, wsc := range clients {
+		wg.Add(1)
+		go func(wsc *wsClient) {
+			sent := make(chan bool, 1)
+			wsc.SendMessage(marshalledJSON, sent)
+			<-sent
+			wg.Done()
+		}(wsc)
+	}
+	go func() {
+		wg.Wait()
+		close(n.done)
+	}()
 }
 
 // WaitForShutdown blocks until all notification manager goroutines have
@@ -1203,14 +1673,17 @@ func newWsNotificationManager(server *rpcServer) *wsNotificationManager {
 		queueNotification: make(chan interface{}),
 		notificationMsgs:  make(chan interface{}),
 		numClients:        make(chan int),
+		draining:          make(chan struct{}),
 		quit:              make(chan struct{}),
 	}
 }
 
 // wsResponse houses a message to send to a connected websocket client as
-// well as a channel to reply on when the message is sent.
+// well as a channel to reply on when the message is sent.  Binary messages are
+// sent as binary websocket frames instead of text frames.
 type wsResponse struct {
 	msg      []byte
+	binary   bool
 	doneChan chan bool
 }
 
@@ -1277,6 +1750,19 @@ type wsClient struct {
 	// `rescanblocks` methods.
 	filterData *wsClientFilter
 
+	// rescanQuit is closed to abort the rescanblockchain operation that is
+	// currently underway for the client.  It is nil when no such operation
+	// is underway.
+	rescanQuit chan struct{}
+
+	// chainEvents is the chain event stream requested with the
+	// notifychainevents command.  It is nil when no stream was requested.
+	chainEvents *chainEventStream
+
+	// rawBlocks is the block stream requested with the fetchblocksraw
+	// command.  It is nil when no stream is underway.
+	rawBlocks *rawBlockStream
+
 	// Networking infrastructure.
 	serviceRequestSem semaphore
 	ntfnChan          chan []byte
@@ -1463,6 +1949,39 @@ out:
 	rpcsLog.Tracef("Websocket client input handler done for %s", c.addr)
 }
 
+// wsSubscriptionCmds is a set of the websocket commands which subscribe to
+// notifications or start a rescan.  They are refused once the RPC server has
+// begun shutting down.
+var wsSubscriptionCmds = map[string]struct{}{
+	"loadtxfilter":          {},
+	"notifyblocks":          {},
+	"notifychainevents":     {},
+	"notifymempoolevents":   {},
+	"notifynewtransactions": {},
+	"notifyreceived":        {},
+	"notifyreorg":           {},
+	"notifyspent":           {},
+	"rescan":                {},
+	"rescanblockchain":      {},
+	"rescanblocks":          {},
+}
+
+// wsRescanCmds is a set of the websocket commands which rescan the chain.  The
+// RPC server gives them a grace period to stop and report how far they got
+// when shutting down.
+var wsRescanCmds = map[string]struct{}{
+	"rescan":           {},
+	"rescanblockchain": {},
+	"rescanblocks":     {},
+}
+
+// errRPCShuttingDown is the error returned for the websocket commands which are
+// refused once the RPC server has begun shutting down.
+var errRPCShuttingDown = &btcjson.RPCError{
+	Code:    btcjson.ErrRPCMisc,
+	Message: "Server is shutting down",
+}
+
 // serviceRequest services a parsed RPC request by looking up and executing the
 // appropriate RPC handler.  The response is marshalled and sent to the
 // websocket client.
@@ -1472,13 +1991,35 @@ func (c *wsClient) serviceRequest(r *parsedRPCCmd) {
 		err    error
 	)
 
-	// Lookup the websocket extension for the command and if it doesn't
-	// exist fallback to handling the command as a standard command.
-	wsHandler, ok := wsHandlers[r.method]
-	if ok {
-		result, err = wsHandler(c, r.cmd)
-	} else {
-		result, err = c.server.standardCmdResult(r, nil)
+	// Rescans are tracked until their reply has been sent so shutdown can
+	// wait for them to report the last block they processed.
+	ntfnMgr := c.server.ntfnMgr
+	_, isSubscription := wsSubscriptionCmds[r.method]
+	_, isRescan := wsRescanCmds[r.method]
+	switch {
+	case isRescan && !ntfnMgr.beginRescan():
+		err = errRPCShuttingDown
+
+	case isSubscription && ntfnMgr.Draining():
+		if isRescan {
+			ntfnMgr.endRescan()
+		}
+		err = errRPCShuttingDown
+
+	default:
+		if isRescan {
+			defer ntfnMgr.endRescan()
+		}
+
+		// Lookup the websocket extension for the command and if it
+		// doesn't exist fallback to handling the command as a standard
+		// command.
+		wsHandler, ok := wsHandlers[r.method]
+		if ok {
+			result, err = wsHandler(c, r.cmd)
+		} else {
+			result, err = c.server.standardCmdResult(r, nil)
+		}
 	}
 	reply, err := createMarshalledReply(r.id, result, err)
 	if err != nil {
@@ -1486,7 +2027,24 @@ func (c *wsClient) serviceRequest(r *parsedRPCCmd) {
 			"command: %v", r.method, err)
 		return
 	}
-	c.SendMessage(reply, nil)
+	// The frames of a fetchblocksraw stream must follow its reply, so the
+	// stream is only started once the reply has been sent.
+	_, isRawBlocks := result.(*btcjson.FetchBlocksRawResult)
+	if !isRescan && !isRawBlocks {
+		c.SendMessage(reply, nil)
+		return
+	}
+	sent := make(chan bool, 1)
+	c.SendMessage(reply, sent)
+	<-sent
+	if isRawBlocks {
+		c.Lock()
+		stream := c.rawBlocks
+		c.Unlock()
+		if stream != nil {
+			go c.runRawBlockStream(stream)
+		}
+	}
 }
 
 // notificationQueueHandler handles the queuing of outgoing notifications for
@@ -1573,7 +2131,11 @@ out:
 		// closed.
 		select {
 		case r := <-c.sendChan:
-			err := c.conn.WriteMessage(websocket.TextMessage, r.msg)
+			messageType := websocket.TextMessage
+			if r.binary {
+				messageType = websocket.BinaryMessage
+			}
+			err := c.conn.WriteMessage(messageType, r.msg)
 			if err != nil {
 				c.Disconnect()
 				break out
@@ -1622,6 +2184,27 @@ func (c *wsClient) SendMessage(marshalledJSON []byte, doneChan chan bool) {
 	c.sendChan <- wsResponse{msg: marshalledJSON, doneChan: doneChan}
 }
 
+// SendBinaryMessage sends the passed data to the websocket client as a binary
+// message and waits for it to be written.  It returns ErrClientQuit when the
+// client disconnects before the message is written.
+func (c *wsClient) SendBinaryMessage(msg []byte) error {
+	// Don't send the message if disconnected.
+	if c.Disconnected() {
+		return ErrClientQuit
+	}
+
+	done := make(chan bool, 1)
+	select {
+	case c.sendChan <- wsResponse{msg: msg, binary: true, doneChan: done}:
+	case <-c.quit:
+		return ErrClientQuit
+	}
+	if !<-done {
+		return ErrClientQuit
+	}
+	return nil
+}
+
 // ErrClientQuit describes the error where a client send is not processed due
 // to the client having already been disconnected or dropped.
 var ErrClientQuit = errors.New("client quit")
@@ -2253,6 +2836,314 @@ func handleRescanBlocks(wsc *wsClient, icmd interface{}) (interface{}, error) {
 	return &discoveredData, nil
 }
 
+// handleGetBlocksBatch implements the getblocksbatch command extension for
+// websocket connections.  It returns multiple serialized blocks at once, framed
+// by their length and optionally compressed, which avoids the overhead of
+// requesting and encoding each block separately.
+func handleGetBlocksBatch(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	cmd, ok := icmd.(*btcjson.GetBlocksBatchCmd)
+	if !ok {
+		return nil, btcjson.ErrRPCInternal
+	}
+
+	compression := blockBatchCompressionNone
+	if cmd.Compression != nil {
+		compression = *cmd.Compression
+	}
+	bw, err := newBlockBatchWriter(compression)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+
+	// Determine the hashes of the requested blocks.  Blocks requested by
+	// height are looked up in the main chain, while blocks requested by
+	// hash may also be on a side chain.
+	req := &cmd.Request
+	chain := wsc.server.cfg.Chain
+	var hashes []*chainhash.Hash
+	var startHeight int32
+	switch {
+	case len(req.Hashes) != 0 && req.StartHeight == nil:
+		if len(req.Hashes) > maxBlockBatchBlocks {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("At most %d blocks may be "+
+					"requested", maxBlockBatchBlocks),
+			}
+		}
+		hashes = make([]*chainhash.Hash, 0, len(req.Hashes))
+		for _, hashStr := range req.Hashes {
+			hash, err := chainhash.NewHashFromStr(hashStr)
+			if err != nil {
+				return nil, rpcDecodeHexError(hashStr)
+			}
+			hashes = append(hashes, hash)
+		}
+
+	case len(req.Hashes) == 0 && req.StartHeight != nil:
+		startHeight = *req.StartHeight
+		count := int32(100)
+		if req.Count != nil {
+			count = *req.Count
+		}
+		best := chain.BestSnapshot()
+		if startHeight < 0 || startHeight > best.Height {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid start height",
+			}
+		}
+		if count < 1 || count > maxBlockBatchBlocks {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Count must be between 1 and "+
+					"%d", maxBlockBatchBlocks),
+			}
+		}
+		endHeight := startHeight + count
+		if endHeight > best.Height+1 {
+			endHeight = best.Height + 1
+		}
+		hashList, err := chain.HeightRange(startHeight, endHeight)
+		if err != nil {
+			context := "Failed to look up block range"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		hashes = make([]*chainhash.Hash, 0, len(hashList))
+		for i := range hashList {
+			hashes = append(hashes, &hashList[i])
+		}
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Either the block hashes or the start height " +
+				"must be provided",
+		}
+	}
+
+	// Frame the blocks straight from the database since the data it
+	// returns is only valid during the transaction.  Once the batch is
+	// large enough, the remaining blocks are left for a following request.
+	err = wsc.server.cfg.DB.View(func(dbTx database.Tx) error {
+		for _, hash := range hashes {
+			if bw.Size() >= maxBlockBatchBytes {
+				break
+			}
+			blkBytes, err := dbTx.FetchBlock(hash)
+			if err != nil {
+				return &btcjson.RPCError{
+					Code:    btcjson.ErrRPCBlockNotFound,
+					Message: fmt.Sprintf("Block %v not found", hash),
+				}
+			}
+			if err := bw.WriteBlock(blkBytes); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if _, ok := err.(*btcjson.RPCError); ok {
+			return nil, err
+		}
+		context := "Failed to load blocks"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	data, err := bw.Bytes()
+	if err != nil {
+		context := "Failed to compress blocks"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	result := &btcjson.GetBlocksBatchResult{
+		Count:       bw.Blocks(),
+		Compression: compression,
+		Size:        bw.Size(),
+		Data:        base64.StdEncoding.EncodeToString(data),
+	}
+	if req.StartHeight != nil {
+		nextHeight := startHeight + int32(bw.Blocks())
+		result.NextHeight = &nextHeight
+	}
+	return result, nil
+}
+
+// handleFetchBlocksRaw implements the fetchblocksraw command extension for
+// websocket connections.  It starts a stream of binary frames, one for each of
+// the requested main chain blocks, which follows the reply.  Each frame holds
+// the height of the block as a little-endian uint32 followed by the serialized
+// block exactly as it is stored in the database.  The client acknowledges the
+// frames with ackfetchblocksraw and a fetchblocksrawdone notification is sent
+// once the stream ends.
+//
+// NOTE: This is a btcd extension.
+func handleFetchBlocksRaw(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	cmd, ok := icmd.(*btcjson.FetchBlocksRawCmd)
+	if !ok {
+		return nil, btcjson.ErrRPCInternal
+	}
+
+	chain := wsc.server.cfg.Chain
+	best := chain.BestSnapshot()
+	if cmd.StartHeight < 0 || cmd.StartHeight > best.Height {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid start height",
+		}
+	}
+	if cmd.Count < 1 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Count must be at least 1",
+		}
+	}
+	window := int32(defaultRawBlockWindow)
+	if cmd.Window != nil {
+		window = *cmd.Window
+	}
+	if window < 1 || window > maxRawBlockWindow {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Window must be between 1 and %d",
+				maxRawBlockWindow),
+		}
+	}
+
+	// Don't stream past the current best block.
+	count := cmd.Count
+	if count > best.Height-cmd.StartHeight+1 {
+		count = best.Height - cmd.StartHeight + 1
+	}
+
+	// The blocks are read straight out of the database without being
+	// deserialized.
+	fetchBlock := func(height int32) (*chainhash.Hash, []byte, error) {
+		hash, err := chain.BlockHashByHeight(height)
+		if err != nil {
+			return nil, nil, err
+		}
+		var blkBytes []byte
+		err = wsc.server.cfg.DB.View(func(dbTx database.Tx) error {
+			b, err := dbTx.FetchBlock(hash)
+			if err != nil {
+				return err
+			}
+
+			// The returned bytes are only valid during the
+			// transaction, so they must be copied.
+			blkBytes = make([]byte, len(b))
+			copy(blkBytes, b)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return hash, blkBytes, nil
+	}
+	stream := newRawBlockStream(cmd.StartHeight, count, window, fetchBlock,
+		wsc.SendBinaryMessage)
+
+	// Only a single stream is allowed per client at a time so that the
+	// acknowledgements are unambiguous.
+	wsc.Lock()
+	if wsc.rawBlocks != nil {
+		wsc.Unlock()
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Block stream already in progress",
+		}
+	}
+	wsc.rawBlocks = stream
+	wsc.Unlock()
+
+	return &btcjson.FetchBlocksRawResult{
+		StartHeight: cmd.StartHeight,
+		Count:       count,
+		Window:      window,
+	}, nil
+}
+
+// runRawBlockStream runs the passed block stream requested with fetchblocksraw
+// and sends a fetchblocksrawdone notification once it ends, unless it was
+// stopped by the client.  It must be run as a goroutine.
+func (c *wsClient) runRawBlockStream(stream *rawBlockStream) {
+	sent, err := stream.Run(c.quit)
+
+	c.Lock()
+	if c.rawBlocks == stream {
+		c.rawBlocks = nil
+	}
+	c.Unlock()
+
+	if err == errRawBlockStreamStopped || err == ErrClientQuit {
+		return
+	}
+	var errStr string
+	if err != nil {
+		rpcsLog.Warnf("Block stream for %s aborted after %d blocks: %v",
+			c.addr, sent, err)
+		errStr = err.Error()
+	}
+	ntfn := btcjson.NewFetchBlocksRawDoneNtfn(stream.startHeight, sent,
+		errStr)
+	marshalled, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal fetchblocksrawdone "+
+			"notification: %v", err)
+		return
+	}
+	c.QueueNotification(marshalled)
+}
+
+// handleAckFetchBlocksRaw implements the ackfetchblocksraw command extension
+// for websocket connections.
+//
+// NOTE: This is a btcd extension.
+func handleAckFetchBlocksRaw(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	cmd, ok := icmd.(*btcjson.AckFetchBlocksRawCmd)
+	if !ok {
+		return nil, btcjson.ErrRPCInternal
+	}
+
+	wsc.Lock()
+	stream := wsc.rawBlocks
+	wsc.Unlock()
+	if stream == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "No block stream is in progress",
+		}
+	}
+	if err := stream.Ack(cmd.Height); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+	return nil, nil
+}
+
+// handleStopFetchBlocksRaw implements the stopfetchblocksraw command extension
+// for websocket connections.  It stops the block stream that is currently
+// underway for the client, if any, and returns whether there was one.
+//
+// NOTE: This is a btcd extension.
+func handleStopFetchBlocksRaw(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.Lock()
+	defer wsc.Unlock()
+
+	if wsc.rawBlocks == nil {
+		return false, nil
+	}
+	wsc.rawBlocks.Stop()
+	wsc.rawBlocks = nil
+	return true, nil
+}
+
 // recoverFromReorg attempts to recover from a detected reorganize during a
 // rescan.  It fetches a new range of block shas from the database and
 // verifies that the new range of blocks is on the same fork as a previous
@@ -2564,6 +3455,15 @@ fetchRange:
 				rpcsLog.Debugf("Stopped rescan at height %v "+
 					"for disconnected client", blk.Height())
 				return nil, nil
+			case <-wsc.server.ntfnMgr.draining:
+				// Report the last block processed so the
+				// client can resume the rescan from there.
+				rpcsLog.Debugf("Stopped rescan at height %v "+
+					"for server shutdown", blk.Height())
+				if lastBlock != nil {
+					notifyRescanProgress(wsc, lastBlock)
+				}
+				return nil, errRPCShuttingDown
 			default:
 				rescanBlock(wsc, &lookups, blk)
 				lastBlock = blk
@@ -2579,16 +3479,7 @@ fetchRange:
 				continue
 			}
 
-			n := btcjson.NewRescanProgressNtfn(hashList[i].String(),
-				blk.Height(), blk.MsgBlock().Header.Timestamp.Unix())
-			mn, err := btcjson.MarshalCmd(nil, n)
-			if err != nil {
-				rpcsLog.Errorf("Failed to marshal rescan "+
-					"progress notification: %v", err)
-				continue
-			}
-
-			if err = wsc.QueueNotification(mn); err == ErrClientQuit {
+			if notifyRescanProgress(wsc, blk) == ErrClientQuit {
 				// Finished if the client disconnected.
 				rpcsLog.Debugf("Stopped rescan at height %v "+
 					"for disconnected client", blk.Height())
@@ -2622,6 +3513,325 @@ fetchRange:
 	return nil, nil
 }
 
+// notifyRescanProgress sends a rescanprogress notification for the passed block
+// to the websocket client.  ErrClientQuit is returned when the client has
+// disconnected.
+func notifyRescanProgress(wsc *wsClient, blk *btcutil.Block) error {
+	n := btcjson.NewRescanProgressNtfn(blk.Hash().String(), blk.Height(),
+		blk.MsgBlock().Header.Timestamp.Unix())
+	mn, err := btcjson.MarshalCmd(nil, n)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal rescan progress "+
+			"notification: %v", err)
+		return nil
+	}
+	return wsc.QueueNotification(mn)
+}
+
+// handleRescanBlockchain implements the rescanblockchain command extension for
+// websocket connections.
+//
+// NOTE: This is a btcd extension.
+func handleRescanBlockchain(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	cmd, ok := icmd.(*btcjson.RescanBlockchainCmd)
+	if !ok {
+		return nil, btcjson.ErrRPCInternal
+	}
+
+	params := wsc.server.cfg.ChainParams
+	scripts := make(map[string]struct{}, len(cmd.Descriptors))
+	for _, desc := range cmd.Descriptors {
+		script, err := descriptorScript(desc, params)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid descriptor: " + err.Error(),
+			}
+		}
+		scripts[string(script)] = struct{}{}
+	}
+
+	best := wsc.server.cfg.Chain.BestSnapshot()
+	startHeight := int32(0)
+	if cmd.StartHeight != nil {
+		startHeight = *cmd.StartHeight
+	}
+	stopHeight := best.Height
+	if cmd.StopHeight != nil {
+		stopHeight = *cmd.StopHeight
+	}
+	if startHeight < 0 || startHeight > best.Height {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid start height",
+		}
+	}
+	if stopHeight < startHeight || stopHeight > best.Height {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid stop height",
+		}
+	}
+
+	// Only a single rescanblockchain operation is allowed per client at a
+	// time so that abortrescan is unambiguous.
+	quit := make(chan struct{})
+	wsc.Lock()
+	if wsc.rescanQuit != nil {
+		wsc.Unlock()
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Rescan already in progress",
+		}
+	}
+	wsc.rescanQuit = quit
+	wsc.Unlock()
+	defer func() {
+		wsc.Lock()
+		if wsc.rescanQuit == quit {
+			wsc.rescanQuit = nil
+		}
+		wsc.Unlock()
+	}()
+
+	// Stop the rescan when it is either aborted, the client disconnects,
+	// or the server begins shutting down.
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-quit:
+		case <-wsc.quit:
+		case <-wsc.server.ntfnMgr.draining:
+		case <-done:
+			return
+		}
+		close(stop)
+	}()
+
+	rpcsLog.Infof("Beginning rescan of blocks %d to %d for %d output "+
+		"scripts", startHeight, stopHeight, len(scripts))
+	rescanner := scriptRescanner{
+		chain:       wsc.server.cfg.Chain,
+		params:      params,
+		dbPath:      blockDbPath(cfg.DbType),
+		coldPath:    cfg.ColdBlockDir,
+		scripts:     scripts,
+		startHeight: startHeight,
+		stopHeight:  stopHeight,
+		progress: func(hash *chainhash.Hash, height int32, timestamp time.Time) {
+			n := btcjson.NewRescanBlockchainProgressNtfn(hash.String(),
+				height, stopHeight, timestamp.Unix())
+			mn, err := btcjson.MarshalCmd(nil, n)
+			if err != nil {
+				rpcsLog.Errorf("Failed to marshal rescan "+
+					"progress notification: %v", err)
+				return
+			}
+			_ = wsc.QueueNotification(mn)
+		},
+		quit: stop,
+	}
+	scanResult, err := rescanner.Run()
+	if err != nil && err != errRescanAborted {
+		rpcsLog.Errorf("Rescan failed: %v", err)
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDatabase,
+			Message: "Database error: " + err.Error(),
+		}
+	}
+	if err == errRescanAborted {
+		rpcsLog.Infof("Rescan aborted at height %d",
+			scanResult.lastHeight)
+	} else {
+		rpcsLog.Info("Finished rescan")
+	}
+
+	result := &btcjson.RescanBlockchainResult{
+		StartHeight:  startHeight,
+		StopHeight:   scanResult.lastHeight,
+		Aborted:      err == errRescanAborted,
+		Transactions: make([]btcjson.RescannedTx, 0, len(scanResult.txns)),
+	}
+	for _, rtx := range scanResult.txns {
+		result.Transactions = append(result.Transactions, btcjson.RescannedTx{
+			TxID:        rtx.tx.Hash().String(),
+			BlockHash:   rtx.blockHash.String(),
+			BlockHeight: rtx.height,
+			Hex:         txHexString(rtx.tx.MsgTx()),
+		})
+	}
+	return result, nil
+}
+
+// handleAbortRescan implements the abortrescan command extension for
+// websocket connections.  It stops the rescanblockchain operation that is
+// currently underway for the client, if any.
+//
+// NOTE: This is a btcd extension.
+func handleAbortRescan(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.Lock()
+	defer wsc.Unlock()
+
+	if wsc.rescanQuit == nil {
+		return false, nil
+	}
+	close(wsc.rescanQuit)
+	wsc.rescanQuit = nil
+	return true, nil
+}
+
+// handleNotifyChainEvents implements the notifychainevents command extension
+// for websocket connections.  It starts a stream of chainevent notifications
+// which moves the client from the passed block to the best block and follows
+// the chain from then on.
+//
+// NOTE: This is a btcd extension.
+func handleNotifyChainEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	cmd, ok := icmd.(*btcjson.NotifyChainEventsCmd)
+	if !ok {
+		return nil, btcjson.ErrRPCInternal
+	}
+
+	var startHash *chainhash.Hash
+	if cmd.LastBlock != nil {
+		var err error
+		startHash, err = chainhash.NewHashFromStr(*cmd.LastBlock)
+		if err != nil {
+			return nil, rpcDecodeHexError(*cmd.LastBlock)
+		}
+	}
+	var sequence uint64
+	if cmd.Sequence != nil {
+		sequence = *cmd.Sequence
+	}
+	includeBlocks := cmd.IncludeBlocks != nil && *cmd.IncludeBlocks
+
+	send := func(ntfn *btcjson.ChainEventNtfn) error {
+		marshalled, err := btcjson.MarshalCmd(nil, ntfn)
+		if err != nil {
+			return err
+		}
+		return wsc.QueueNotification(marshalled)
+	}
+	fetchBlock := func(hash *chainhash.Hash) (*btcutil.Block, error) {
+		var blkBytes []byte
+		err := wsc.server.cfg.DB.View(func(dbTx database.Tx) error {
+			var err error
+			blkBytes, err = dbTx.FetchBlock(hash)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return btcutil.NewBlockFromBytes(blkBytes)
+	}
+	stream, err := newChainEventStream(wsc.server.cfg.Chain, fetchBlock,
+		send, startHash, sequence, includeBlocks)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: err.Error(),
+		}
+	}
+	hash, height, sequence := stream.Tip()
+
+	wsc.Lock()
+	wsc.chainEvents = stream
+	wsc.Unlock()
+	wsc.server.ntfnMgr.RegisterChainEvents(wsc, stream)
+	go stream.Run(wsc.quit)
+
+	return &btcjson.NotifyChainEventsResult{
+		Hash:     hash.String(),
+		Height:   height,
+		Sequence: sequence,
+	}, nil
+}
+
+// handleAckChainEvents implements the ackchainevents command extension for
+// websocket connections.
+//
+// NOTE: This is a btcd extension.
+func handleAckChainEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	cmd, ok := icmd.(*btcjson.AckChainEventsCmd)
+	if !ok {
+		return nil, btcjson.ErrRPCInternal
+	}
+
+	wsc.Lock()
+	stream := wsc.chainEvents
+	wsc.Unlock()
+	if stream == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "No chain event stream was requested",
+		}
+	}
+	if err := stream.Ack(cmd.Sequence); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+	return nil, nil
+}
+
+// handleStopNotifyChainEvents implements the stopnotifychainevents command
+// extension for websocket connections.
+//
+// NOTE: This is a btcd extension.
+func handleStopNotifyChainEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.Lock()
+	wsc.chainEvents = nil
+	wsc.Unlock()
+	wsc.server.ntfnMgr.UnregisterChainEvents(wsc)
+	return nil, nil
+}
+
+// handleNotifyMempoolEvents implements the notifymempoolevents command
+// extension for websocket connections.
+//
+// NOTE: This is a btcd extension.
+func handleNotifyMempoolEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	cmd, ok := icmd.(*btcjson.NotifyMempoolEventsCmd)
+	if !ok {
+		return nil, btcjson.ErrRPCInternal
+	}
+
+	feeHistogram := cmd.FeeHistogram == nil || *cmd.FeeHistogram
+	wsc.server.ntfnMgr.RegisterMempoolEvents(wsc, feeHistogram)
+	return nil, nil
+}
+
+// handleStopNotifyMempoolEvents implements the stopnotifymempoolevents command
+// extension for websocket connections.
+//
+// NOTE: This is a btcd extension.
+func handleStopNotifyMempoolEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterMempoolEvents(wsc)
+	return nil, nil
+}
+
+// handleNotifyReorg implements the notifyreorg command extension for websocket
+// connections.
+//
+// NOTE: This is a btcd extension.
+func handleNotifyReorg(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.RegisterReorg(wsc)
+	return nil, nil
+}
+
+// handleStopNotifyReorg implements the stopnotifyreorg command extension for
+// websocket connections.
+//
+// NOTE: This is a btcd extension.
+func handleStopNotifyReorg(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterReorg(wsc)
+	return nil, nil
+}
+
 func init() {
 	wsHandlers = wsHandlersBeforeInit
 }
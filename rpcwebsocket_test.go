@@ -0,0 +1,82 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+)
+
+// TestWsNotificationManagerDrain ensures draining the notification manager
+// refuses new rescans and waits for the rescans in progress for at most the
+// grace period.
+func TestWsNotificationManagerDrain(t *testing.T) {
+	m := newWsNotificationManager(nil)
+
+	// Stand in for the notification handler by acknowledging the server
+	// shutdown notification right away.
+	go func() {
+		n := (<-m.queueNotification).(*notificationServerShutdown)
+		close(n.done)
+	}()
+
+	if !m.beginRescan() {
+		t.Fatal("beginRescan: refused before draining")
+	}
+
+	const grace = 50 * time.Millisecond
+	start := time.Now()
+	drained := m.Drain(&blockchain.BestState{Height: 100}, 0, grace)
+	if drained {
+		t.Fatal("Drain: reported drained with a rescan in progress")
+	}
+	if elapsed := time.Since(start); elapsed < grace {
+		t.Fatalf("Drain: returned after %v with a rescan in progress, "+
+			"want at least %v", elapsed, grace)
+	}
+	if !m.Draining() {
+		t.Fatal("Draining: not draining after Drain")
+	}
+	if m.beginRescan() {
+		t.Fatal("beginRescan: accepted while draining")
+	}
+	m.endRescan()
+
+	// Draining again is a no-op and must not block.
+	m.Drain(&blockchain.BestState{Height: 100}, 0, time.Hour)
+}
+
+// TestWsNotificationManagerDrainRescansDone ensures draining returns as soon as
+// the rescans in progress finish.
+func TestWsNotificationManagerDrainRescansDone(t *testing.T) {
+	m := newWsNotificationManager(nil)
+	go func() {
+		n := (<-m.queueNotification).(*notificationServerShutdown)
+		close(n.done)
+	}()
+
+	if !m.beginRescan() {
+		t.Fatal("beginRescan: refused before draining")
+	}
+	go func() {
+		<-m.draining
+		m.endRescan()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		if !m.Drain(&blockchain.BestState{Height: 100}, 0, time.Hour) {
+			t.Error("Drain: grace period elapsed")
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Drain: did not return once the rescan finished")
+	}
+}
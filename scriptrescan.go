@@ -0,0 +1,402 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// descriptorInputCharset is the set of characters that may appear in
+	// an output script descriptor.  The position of each character is used
+	// when computing the descriptor checksum.
+	descriptorInputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+	// descriptorChecksumCharset is the set of characters used to encode a
+	// descriptor checksum.
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	// descriptorChecksumLen is the number of characters in a descriptor
+	// checksum.
+	descriptorChecksumLen = 8
+
+	// rescanProgressInterval is the minimum amount of time between
+	// progress notifications sent while a rescan is underway.
+	rescanProgressInterval = 10 * time.Second
+)
+
+// descriptorGenerator houses the generator constants for the descriptor
+// checksum.
+var descriptorGenerator = [5]uint64{
+	0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd,
+}
+
+// errRescanAborted is returned by a script rescan when it is stopped before
+// scanning all of the requested blocks.
+var errRescanAborted = errors.New("rescan aborted")
+
+// descriptorChecksum returns the checksum for the provided descriptor (without
+// any existing checksum) as defined by BIP380.
+func descriptorChecksum(desc string) (string, error) {
+	polymod := func(chk uint64, value uint64) uint64 {
+		top := chk >> 35
+		chk = (chk&0x7ffffffff)<<5 ^ value
+		for i := uint(0); i < 5; i++ {
+			if (top>>i)&1 != 0 {
+				chk ^= descriptorGenerator[i]
+			}
+		}
+		return chk
+	}
+
+	chk := uint64(1)
+	var groups [3]uint64
+	numGroups := 0
+	for _, c := range desc {
+		pos := strings.IndexRune(descriptorInputCharset, c)
+		if pos == -1 {
+			return "", fmt.Errorf("invalid character %q in "+
+				"descriptor", c)
+		}
+		chk = polymod(chk, uint64(pos&31))
+		groups[numGroups] = uint64(pos >> 5)
+		numGroups++
+		if numGroups == 3 {
+			chk = polymod(chk, groups[0]*9+groups[1]*3+groups[2])
+			numGroups = 0
+		}
+	}
+	switch numGroups {
+	case 1:
+		chk = polymod(chk, groups[0])
+	case 2:
+		chk = polymod(chk, groups[0]*3+groups[1])
+	}
+	for i := 0; i < descriptorChecksumLen; i++ {
+		chk = polymod(chk, 0)
+	}
+	chk ^= 1
+
+	var checksum [descriptorChecksumLen]byte
+	for i := range checksum {
+		shift := uint(5 * (descriptorChecksumLen - 1 - i))
+		checksum[i] = descriptorChecksumCharset[(chk>>shift)&31]
+	}
+	return string(checksum[:]), nil
+}
+
+// parsePubKeyArg parses a hex-encoded public key argument of a descriptor.
+func parsePubKeyArg(arg string) ([]byte, error) {
+	serialized, err := hex.DecodeString(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %v", arg, err)
+	}
+	_, err = btcec.ParsePubKey(serialized, btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %v", arg, err)
+	}
+	return serialized, nil
+}
+
+// descriptorScript returns the output script described by the provided output
+// script descriptor.  A descriptor consisting only of hex characters is treated
+// as a raw output script.
+//
+// Only descriptors which describe a single output script are supported.  They
+// are raw(HEX), addr(ADDR), pk(KEY), pkh(KEY), wpkh(KEY), and sh(wpkh(KEY)),
+// where KEY is a hex-encoded public key.  An optional checksum is verified
+// when present.
+func descriptorScript(desc string, params *chaincfg.Params) ([]byte, error) {
+	if i := strings.IndexByte(desc, '#'); i != -1 {
+		want, err := descriptorChecksum(desc[:i])
+		if err != nil {
+			return nil, err
+		}
+		if desc[i+1:] != want {
+			return nil, fmt.Errorf("descriptor checksum mismatch - "+
+				"got %q, want %q", desc[i+1:], want)
+		}
+		desc = desc[:i]
+	}
+
+	// Treat descriptors without any function as raw scripts.
+	open := strings.IndexByte(desc, '(')
+	if open == -1 {
+		script, err := hex.DecodeString(desc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output script %q: %v",
+				desc, err)
+		}
+		return script, nil
+	}
+	if !strings.HasSuffix(desc, ")") {
+		return nil, fmt.Errorf("malformed descriptor %q", desc)
+	}
+	fn, arg := desc[:open], desc[open+1:len(desc)-1]
+
+	switch fn {
+	case "raw":
+		script, err := hex.DecodeString(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output script %q: %v",
+				arg, err)
+		}
+		return script, nil
+
+	case "addr":
+		addr, err := btcutil.DecodeAddress(arg, params)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v", arg, err)
+		}
+		if !addr.IsForNet(params) {
+			return nil, fmt.Errorf("address %q is not for %s", arg,
+				params.Name)
+		}
+		return txscript.PayToAddrScript(addr)
+
+	case "pk":
+		serialized, err := parsePubKeyArg(arg)
+		if err != nil {
+			return nil, err
+		}
+		return txscript.NewScriptBuilder().AddData(serialized).
+			AddOp(txscript.OP_CHECKSIG).Script()
+
+	case "pkh":
+		serialized, err := parsePubKeyArg(arg)
+		if err != nil {
+			return nil, err
+		}
+		addr, err := btcutil.NewAddressPubKeyHash(
+			btcutil.Hash160(serialized), params)
+		if err != nil {
+			return nil, err
+		}
+		return txscript.PayToAddrScript(addr)
+
+	case "wpkh", "sh":
+		isP2SH := fn == "sh"
+		if isP2SH {
+			if !strings.HasPrefix(arg, "wpkh(") ||
+				!strings.HasSuffix(arg, ")") {
+
+				return nil, fmt.Errorf("unsupported descriptor "+
+					"%q", desc)
+			}
+			arg = arg[len("wpkh(") : len(arg)-1]
+		}
+		serialized, err := parsePubKeyArg(arg)
+		if err != nil {
+			return nil, err
+		}
+		if len(serialized) != btcec.PubKeyBytesLenCompressed {
+			return nil, fmt.Errorf("public key %q must be "+
+				"compressed", arg)
+		}
+		addr, err := btcutil.NewAddressWitnessPubKeyHash(
+			btcutil.Hash160(serialized), params)
+		if err != nil {
+			return nil, err
+		}
+		script, err := txscript.PayToAddrScript(addr)
+		if err != nil || !isP2SH {
+			return script, err
+		}
+		shAddr, err := btcutil.NewAddressScriptHash(script, params)
+		if err != nil {
+			return nil, err
+		}
+		return txscript.PayToAddrScript(shAddr)
+	}
+
+	return nil, fmt.Errorf("unsupported descriptor %q", desc)
+}
+
+// scriptRescanResult houses the results of a script rescan.
+type scriptRescanResult struct {
+	// lastHeight is the height of the last block that was scanned or one
+	// less than the start height when no blocks were scanned.
+	lastHeight int32
+
+	// txns are the transactions which either pay to one of the scanned
+	// output scripts or spend an output that does, along with the blocks
+	// they were found in.
+	txns []scriptRescanTx
+}
+
+// scriptRescanTx describes a transaction found by a script rescan.
+type scriptRescanTx struct {
+	tx        *btcutil.Tx
+	blockHash chainhash.Hash
+	height    int32
+}
+
+// scriptRescanner scans the main chain for transactions that pay to a set of
+// output scripts, along with any transactions that spend those outputs.  Rather
+// than fetching each block through the block index, the block records are read
+// sequentially straight out of the database flat files and only the blocks in
+// the main chain that fall within the requested height range are loaded.
+//
+// Main chain blocks are always written to the flat files after their parent, so
+// scanning the records in order sees every output before any transaction that
+// spends it.  Blocks are matched against the main chain as it exists when they
+// are encountered, so a reorganize that happens while the scan is underway may
+// cause some blocks to be missed.  The scan is reported as aborted in that case.
+type scriptRescanner struct {
+	chain  *blockchain.BlockChain
+	params *chaincfg.Params
+	dbPath string
+
+	// coldPath is the directory which houses the oldest flat files of the
+	// database, if any.
+	coldPath string
+
+	// scripts is the set of output scripts to scan for.
+	scripts map[string]struct{}
+
+	// startHeight and stopHeight are the inclusive range of main chain
+	// block heights to scan.
+	startHeight int32
+	stopHeight  int32
+
+	// progress, when set, is invoked periodically with the most recently
+	// scanned block while the scan is underway.
+	progress func(hash *chainhash.Hash, height int32, timestamp time.Time)
+
+	// quit is closed to stop the scan before it has completed.
+	quit <-chan struct{}
+}
+
+// findStartFile returns the number of the last flat file whose first main
+// chain block is at or below the start height.  Since main chain blocks are
+// stored in height order, every main chain block within the scan range is
+// located in that file or a later one.
+func (r *scriptRescanner) findStartFile(scanner *ffldb.BlockFileScanner) uint32 {
+	for fileNum := int64(scanner.NumFiles()) - 1; fileNum > 0; fileNum-- {
+		scanner.Seek(uint32(fileNum), 0)
+		sb, err := scanner.Next()
+		if err != nil {
+			continue
+		}
+		height, err := r.chain.BlockHeightByHash(&sb.Hash)
+		if err == nil && height <= r.startHeight {
+			return uint32(fileNum)
+		}
+	}
+	return 0
+}
+
+// scanBlock matches all transactions in the provided block against the scanned
+// output scripts and outputs found so far.
+func (r *scriptRescanner) scanBlock(block *btcutil.Block, unspent map[wire.OutPoint]struct{}, result *scriptRescanResult) {
+	for _, tx := range block.Transactions() {
+		relevant := false
+		for _, txIn := range tx.MsgTx().TxIn {
+			if _, ok := unspent[txIn.PreviousOutPoint]; ok {
+				delete(unspent, txIn.PreviousOutPoint)
+				relevant = true
+			}
+		}
+		for i, txOut := range tx.MsgTx().TxOut {
+			if _, ok := r.scripts[string(txOut.PkScript)]; ok {
+				op := wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i)}
+				unspent[op] = struct{}{}
+				relevant = true
+			}
+		}
+		if relevant {
+			result.txns = append(result.txns, scriptRescanTx{
+				tx:        tx,
+				blockHash: *block.Hash(),
+				height:    block.Height(),
+			})
+		}
+	}
+}
+
+// Run performs the scan.  The results found so far are returned along with
+// errRescanAborted when the scan is stopped before all of the blocks in the
+// requested range have been scanned.
+func (r *scriptRescanner) Run() (*scriptRescanResult, error) {
+	result := &scriptRescanResult{lastHeight: r.startHeight - 1}
+	if r.stopHeight < r.startHeight {
+		return result, nil
+	}
+
+	scanner := ffldb.NewSplitBlockFileScanner(r.dbPath, r.coldPath,
+		r.params.Net)
+	defer scanner.Close()
+	scanner.Seek(r.findStartFile(scanner), 0)
+
+	ticker := time.NewTicker(rescanProgressInterval)
+	defer ticker.Stop()
+
+	unspent := make(map[wire.OutPoint]struct{})
+	remaining := r.stopHeight - r.startHeight + 1
+	for remaining > 0 {
+		select {
+		case <-r.quit:
+			return result, errRescanAborted
+		default:
+		}
+
+		sb, err := scanner.Next()
+		if err == io.EOF {
+			return result, errRescanAborted
+		}
+		if err != nil {
+			return result, err
+		}
+
+		// Skip blocks that are not part of the main chain or are
+		// outside of the requested range without loading them.
+		height, err := r.chain.BlockHeightByHash(&sb.Hash)
+		if err != nil || height < r.startHeight || height > r.stopHeight {
+			continue
+		}
+
+		blockBytes, err := scanner.ReadBlock()
+		if err != nil {
+			return result, err
+		}
+		block, err := btcutil.NewBlockFromBytes(blockBytes)
+		if err != nil {
+			return result, err
+		}
+		block.SetHeight(height)
+		r.scanBlock(block, unspent, result)
+		if height > result.lastHeight {
+			result.lastHeight = height
+		}
+		remaining--
+
+		if r.progress == nil {
+			continue
+		}
+		select {
+		case <-ticker.C:
+			r.progress(&sb.Hash, height, sb.Header.Timestamp)
+		default:
+		}
+	}
+
+	return result, nil
+}
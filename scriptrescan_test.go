@@ -0,0 +1,92 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// TestDescriptorScript ensures output script descriptors are converted to the
+// expected output scripts and that invalid descriptors are rejected.
+func TestDescriptorScript(t *testing.T) {
+	t.Parallel()
+
+	const pubKey = "02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9"
+	tests := []struct {
+		name    string
+		desc    string
+		script  string
+		wantErr bool
+	}{
+		{
+			name:   "raw script",
+			desc:   "deadbeef",
+			script: "deadbeef",
+		},
+		{
+			name:   "raw descriptor with checksum",
+			desc:   "raw(deadbeef)#89f8spxm",
+			script: "deadbeef",
+		},
+		{
+			name:    "raw descriptor with bad checksum",
+			desc:    "raw(deadbeef)#89f8spxn",
+			wantErr: true,
+		},
+		{
+			name:   "addr descriptor with checksum",
+			desc:   "addr(1BoatSLRHtKNngkdXEeobR76b53LETtpyT)#nx337m00",
+			script: "76a9147680adec8eabcabac676be9e83854ade0bd22cdb88ac",
+		},
+		{
+			name:   "pk descriptor",
+			desc:   "pk(" + pubKey + ")",
+			script: "21" + pubKey + "ac",
+		},
+		{
+			name:   "wpkh descriptor with checksum",
+			desc:   "wpkh(" + pubKey + ")#8zl0zxma",
+			script: "00147dd65592d0ab2fe0d0257d571abf032cd9db93dc",
+		},
+		{
+			name:   "pkh descriptor",
+			desc:   "pkh(" + pubKey + ")",
+			script: "76a9147dd65592d0ab2fe0d0257d571abf032cd9db93dc88ac",
+		},
+		{
+			name:    "invalid public key",
+			desc:    "pkh(02f9)",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported descriptor",
+			desc:    "tr(" + pubKey + ")",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		script, err := descriptorScript(test.desc, &chaincfg.MainNetParams)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		want, _ := hex.DecodeString(test.script)
+		if !bytes.Equal(script, want) {
+			t.Errorf("%s: unexpected script - got %x, want %x",
+				test.name, script, want)
+		}
+	}
+}
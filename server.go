@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
@@ -25,6 +26,7 @@ import (
 	"github.com/btcsuite/btcd/addrmgr"
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/blockchain/indexers"
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/connmgr"
@@ -130,6 +132,12 @@ type broadcastInventoryAdd relayMsg
 // needs to be removed from the rebroadcast map
 type broadcastInventoryDel *wire.InvVect
 
+// getRebroadcastSetMsg is a type used to request the inventories in the
+// rebroadcast map.
+type getRebroadcastSetMsg struct {
+	reply chan []btcjson.RebroadcastTxResult
+}
+
 // relayMsg packages an inventory vector along with the newly discovered
 // inventory so the relay has access to that information.
 type relayMsg struct {
@@ -150,13 +158,17 @@ type updatePeerHeightsMsg struct {
 }
 
 // peerState maintains state of inbound, persistent, outbound peers as well
-// as banned peers and outbound groups.
+// as outbound groups.
 type peerState struct {
 	inboundPeers    map[int32]*serverPeer
 	outboundPeers   map[int32]*serverPeer
 	persistentPeers map[int32]*serverPeer
-	banned          map[string]time.Time
 	outboundGroups  map[string]int
+
+	// addedNodes holds the nodes connected to permanently keyed by the
+	// address they were added with.  Unlike persistentPeers, it includes
+	// the nodes which are not connected at the moment.
+	addedNodes map[string]*addedNode
 }
 
 // Count returns the count of all known peers.
@@ -165,6 +177,17 @@ func (ps *peerState) Count() int {
 		len(ps.persistentPeers)
 }
 
+// addedNodeByConnReq returns the added node with the passed connection request
+// or nil when the request does not belong to an added node.
+func (ps *peerState) addedNodeByConnReq(connReq *connmgr.ConnReq) *addedNode {
+	for _, node := range ps.addedNodes {
+		if node.connReq == connReq {
+			return node
+		}
+	}
+	return nil
+}
+
 // forAllOutboundPeers is a helper function that runs closure on all outbound
 // peers known to peerState.
 func (ps *peerState) forAllOutboundPeers(closure func(sp *serverPeer)) {
@@ -200,6 +223,7 @@ type server struct {
 	chainParams          *chaincfg.Params
 	addrManager          *addrmgr.AddrManager
 	connManager          *connmgr.ConnManager
+	addedNodes           map[string]*addedNode
 	sigCache             *txscript.SigCache
 	hashCache            *txscript.HashCache
 	rpcServer            *rpcServer
@@ -222,12 +246,45 @@ type server struct {
 	timeSource           blockchain.MedianTimeSource
 	services             wire.ServiceFlag
 
+	// supervisor restarts non-critical subsystems which panic and tracks
+	// the resulting node warnings.
+	supervisor *supervisor
+
+	// proxyHealth monitors the proxy used for outbound connections.  It
+	// will be nil when no proxy is configured.
+	proxyHealth *proxyHealthMonitor
+
+	// metadataBackup periodically takes snapshots of the block database
+	// metadata.  It will be nil when snapshots are disabled.
+	metadataBackup *metadataBackupManager
+
+	// webhooks deliver chain notifications to the configured webhook
+	// endpoints.
+	webhooks []*webhookNotifier
+
+	// uploadTarget keeps track of the outbound traffic in order to stop
+	// serving historical blocks once the configured target is reached.
+	uploadTarget *uploadTarget
+
+	// banManager keeps track of the banned peers and persists the bans
+	// across restarts.
+	banManager *banManager
+
+	// traceRecorder records the events which change the chain or memory
+	// pool to a trace file.  It will be nil when no trace is recorded.
+	traceRecorder *traceRecorder
+
+	// traceClock is the time source of the server while it replays a
+	// trace.  It will be nil when no trace is replayed.
+	traceClock *traceClock
+
 	// The following fields are used for optional indexes.  They will be nil
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
 	// do not need to be protected for concurrent access.
-	txIndex   *indexers.TxIndex
-	addrIndex *indexers.AddrIndex
+	txIndex     *indexers.TxIndex
+	addrIndex   *indexers.AddrIndex
+	rewardIndex *indexers.RewardIndex
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
@@ -250,6 +307,7 @@ type serverPeer struct {
 	disableRelayTx bool
 	sentAddrs      bool
 	isWhitelisted  bool
+	blockRelayOnly bool
 	filter         *bloom.Filter
 	knownAddresses map[string]struct{}
 	banScore       connmgr.DynamicBanScore
@@ -303,11 +361,11 @@ func (sp *serverPeer) setDisableRelayTx(disable bool) {
 }
 
 // relayTxDisabled returns whether or not relaying of transactions for the given
-// peer is disabled.
+// peer is disabled.  It is always disabled for block-relay-only peers.
 // It is safe for concurrent access.
 func (sp *serverPeer) relayTxDisabled() bool {
 	sp.relayMtx.Lock()
-	isDisabled := sp.disableRelayTx
+	isDisabled := sp.disableRelayTx || sp.blockRelayOnly
 	sp.relayMtx.Unlock()
 
 	return isDisabled
@@ -415,9 +473,12 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) {
 				return
 			}
 
+			// Addresses are neither advertised to nor requested
+			// from block-relay-only peers.
+			//
 			// TODO(davec): Only do this if not doing the initial block
 			// download and the local address is routable.
-			if !cfg.DisableListen /* && isCurrent? */ {
+			if !cfg.DisableListen && !sp.blockRelayOnly /* && isCurrent? */ {
 				// Get address that best matches.
 				lna := addrManager.GetBestLocalAddress(sp.NA())
 				if addrmgr.IsRoutable(lna) {
@@ -432,7 +493,9 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) {
 			// include a timestamp with addresses.
 			hasTimestamp := sp.ProtocolVersion() >=
 				wire.NetAddressTimeVersion
-			if addrManager.NeedMoreAddresses() && hasTimestamp {
+			if addrManager.NeedMoreAddresses() && hasTimestamp &&
+				!sp.blockRelayOnly {
+
 				sp.QueueMessage(wire.NewMsgGetAddr(), nil)
 			}
 
@@ -459,11 +522,19 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 		return
 	}
 
+	// Transactions are not relayed to block-relay-only peers.
+	if sp.blockRelayOnly {
+		peerLog.Debugf("Ignoring mempool request from block-relay-only "+
+			"peer %v", sp)
+		return
+	}
+
 	// A decaying ban score increase is applied to prevent flooding.
 	// The ban score accumulates and passes the ban threshold if a burst of
 	// mempool messages comes from a peer. The score decays each minute to
 	// half of its value.
-	sp.addBanScore(0, 33, "mempool")
+	sp.addBanScore(0, sp.server.banManager.OffenseScore(offenseMemPool),
+		"mempool")
 
 	// Generate inventory message with the available transactions in the
 	// transaction memory pool.  Limit it to the max allowed inventory
@@ -503,6 +574,11 @@ func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
 			msg.TxHash(), sp)
 		return
 	}
+	if sp.blockRelayOnly {
+		peerLog.Tracef("Ignoring tx %v from block-relay-only peer %v",
+			msg.TxHash(), sp)
+		return
+	}
 
 	// Add the transaction to the known inventory for the peer.
 	// Convert the raw MsgTx to a btcutil.Tx which provides some convenience
@@ -551,7 +627,7 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 // accordingly.  We pass the message down to blockmanager which will call
 // QueueMessage with any appropriate responses.
 func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
-	if !cfg.BlocksOnly {
+	if !cfg.BlocksOnly && !sp.blockRelayOnly {
 		if len(msg.InvList) > 0 {
 			sp.server.syncManager.QueueInv(msg, sp.Peer)
 		}
@@ -562,7 +638,8 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 	for _, invVect := range msg.InvList {
 		if invVect.Type == wire.InvTypeTx {
 			peerLog.Tracef("Ignoring tx %v in inv from %v -- "+
-				"blocksonly enabled", invVect.Hash, sp)
+				"blocksonly enabled or block-relay-only peer",
+				invVect.Hash, sp)
 			if sp.ProtocolVersion() >= wire.BIP0037Version {
 				peerLog.Infof("Peer %v is announcing "+
 					"transactions -- disconnecting", sp)
@@ -583,6 +660,15 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 	}
 }
 
+// OnNotFound is invoked when a peer receives a notfound bitcoin message.  The
+// message is passed down to the sync manager so the transactions the peer does
+// not have are requested from other peers.
+func (sp *serverPeer) OnNotFound(_ *peer.Peer, msg *wire.MsgNotFound) {
+	if len(msg.InvList) > 0 {
+		sp.server.syncManager.QueueNotFound(msg, sp.Peer)
+	}
+}
+
 // OnHeaders is invoked when a peer receives a headers bitcoin
 // message.  The message is passed down to the sync manager.
 func (sp *serverPeer) OnHeaders(_ *peer.Peer, msg *wire.MsgHeaders) {
@@ -603,7 +689,8 @@ func (sp *serverPeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
 	// bursts of small requests are not penalized as that would potentially ban
 	// peers performing IBD.
 	// This incremental score decays each minute to half of its value.
-	sp.addBanScore(0, uint32(length)*99/wire.MaxInvPerMsg, "getdata")
+	score := sp.server.banManager.OffenseScore(offenseGetData)
+	sp.addBanScore(0, uint32(length)*score/wire.MaxInvPerMsg, "getdata")
 
 	// We wait on this wait channel periodically to prevent queuing
 	// far more data than we can send in a reasonable time, wasting memory.
@@ -760,7 +847,8 @@ func (sp *serverPeer) enforceNodeBloomFlag(cmd string) bool {
 
 			// Disconnect the peer regardless of whether it was
 			// banned.
-			sp.addBanScore(100, 0, cmd)
+			score := sp.server.banManager.OffenseScore(offenseNoBloom)
+			sp.addBanScore(score, 0, cmd)
 			sp.Disconnect()
 			return false
 		}
@@ -898,6 +986,12 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		return
 	}
 
+	// Ignore addresses from block-relay-only peers, which are never asked
+	// for them, so they can't be used to fill the address manager.
+	if sp.blockRelayOnly {
+		return
+	}
+
 	// Ignore old style addresses which don't include a timestamp.
 	if sp.ProtocolVersion() < wire.NetAddressTimeVersion {
 		return
@@ -989,6 +1083,23 @@ func (s *server) RemoveRebroadcastInventory(iv *wire.InvVect) {
 	s.modifyRebroadcastInv <- broadcastInventoryDel(iv)
 }
 
+// RebroadcastSet returns the inventories which are rebroadcast until they show
+// up in a block or are removed from the memory pool.
+func (s *server) RebroadcastSet() []btcjson.RebroadcastTxResult {
+	reply := make(chan []btcjson.RebroadcastTxResult, 1)
+	select {
+	case s.modifyRebroadcastInv <- getRebroadcastSetMsg{reply: reply}:
+	case <-s.quit:
+		return nil
+	}
+	select {
+	case results := <-reply:
+		return results
+	case <-s.quit:
+		return nil
+	}
+}
+
 // relayTransactions generates and relays inventory vectors for all of the
 // passed transactions to all connected peers.
 func (s *server) relayTransactions(txns []*mempool.TxDesc) {
@@ -1055,6 +1166,65 @@ func (s *server) pushTxMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<-
 	return nil
 }
 
+// errUploadTargetReached indicates a historical block was not served because
+// the upload target has been reached.
+var errUploadTargetReached = errors.New("upload target reached")
+
+// checkUploadTarget returns errUploadTargetReached and disconnects the peer
+// when the block with the passed header is historical and must no longer be
+// served to the peer since the upload target has been reached.  Whitelisted
+// peers are always served.
+func (s *server) checkUploadTarget(sp *serverPeer, header *wire.BlockHeader) error {
+	if sp.isWhitelisted {
+		return nil
+	}
+	best := s.chain.BestSnapshot()
+	if !isHistoricalBlock(header.Timestamp, best.MedianTime) ||
+		!s.uploadTarget.Reached(true, time.Now()) {
+
+		return nil
+	}
+
+	peerLog.Infof("Upload target reached, disconnecting peer %v which "+
+		"requested historical block %v", sp, header.BlockHash())
+	sp.Disconnect()
+	return errUploadTargetReached
+}
+
+// blockMsg returns the message to send the passed block to the passed peer in.
+// Compressing blocks costs CPU time, so they are only sent in zblock messages
+// to whitelisted peers when both peers support them.  The passed block bytes
+// are the block as stored in the database, which are serialized with witness
+// data.
+func (s *server) blockMsg(sp *serverPeer, msgBlock *wire.MsgBlock,
+	blockBytes []byte, encoding wire.MessageEncoding) wire.Message {
+
+	if !sp.isWhitelisted || !sp.SupportsZstdBlocks() {
+		return msgBlock
+	}
+
+	if encoding != wire.WitnessEncoding {
+		var buf bytes.Buffer
+		buf.Grow(msgBlock.SerializeSizeStripped())
+		if err := msgBlock.SerializeNoWitness(&buf); err != nil {
+			return msgBlock
+		}
+		blockBytes = buf.Bytes()
+	}
+	msg, err := peer.NewZBlockMsg(blockBytes)
+	if err != nil {
+		peerLog.Warnf("Unable to compress block %v: %v",
+			msgBlock.BlockHash(), err)
+		return msgBlock
+	}
+
+	// Send the block uncompressed when compressing it does not pay off.
+	if len(msg.Data) >= len(blockBytes) {
+		return msgBlock
+	}
+	return msg
+}
+
 // pushBlockMsg sends a block message for the provided block hash to the
 // connected peer.  An error is returned if the block hash is not known.
 func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{},
@@ -1090,6 +1260,13 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 		return err
 	}
 
+	if err := s.checkUploadTarget(sp, &msgBlock.Header); err != nil {
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return err
+	}
+
 	// Once we have fetched data wait for any previous operation to finish.
 	if waitChan != nil {
 		<-waitChan
@@ -1103,7 +1280,8 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 	if !sendInv {
 		dc = doneChan
 	}
-	sp.QueueMessageWithEncoding(&msgBlock, dc, encoding)
+	sp.QueueMessageWithEncoding(s.blockMsg(sp, &msgBlock, blockBytes,
+		encoding), dc, encoding)
 
 	// When the peer requests the final block that was advertised in
 	// response to a getblocks message which requested more blocks than
@@ -1148,6 +1326,13 @@ func (s *server) pushMerkleBlockMsg(sp *serverPeer, hash *chainhash.Hash,
 		return err
 	}
 
+	if err := s.checkUploadTarget(sp, &blk.MsgBlock().Header); err != nil {
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return err
+	}
+
 	// Generate a merkle block by filtering the requested block according
 	// to the filter for the peer.
 	merkle, matchedTxIndices := bloom.NewMerkleBlock(blk, sp.filter)
@@ -1231,16 +1416,11 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		sp.Disconnect()
 		return false
 	}
-	if banEnd, ok := state.banned[host]; ok {
-		if time.Now().Before(banEnd) {
-			srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
-				host, time.Until(banEnd))
-			sp.Disconnect()
-			return false
-		}
-
-		srvrLog.Infof("Peer %s is no longer banned", host)
-		delete(state.banned, host)
+	if banEnd, ok := s.banManager.IsBanned(host, time.Now()); ok {
+		srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
+			host, time.Until(banEnd))
+		sp.Disconnect()
+		return false
 	}
 
 	// TODO: Check for max peers from a single IP.
@@ -1295,7 +1475,13 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	}
 
 	if sp.connReq != nil {
-		s.connManager.Disconnect(sp.connReq.ID())
+		// Connections to nodes which were removed from the added
+		// nodes must not be retried.
+		if sp.persistent && state.addedNodeByConnReq(sp.connReq) == nil {
+			s.connManager.Remove(sp.connReq.ID())
+		} else {
+			s.connManager.Disconnect(sp.connReq.ID())
+		}
 	}
 
 	// Update the address' last seen time if the peer has acknowledged
@@ -1316,10 +1502,20 @@ func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
 		srvrLog.Debugf("can't split ban peer %s %v", sp.Addr(), err)
 		return
 	}
+	target, err := parseBanTarget(host)
+	if err != nil {
+		srvrLog.Debugf("can't ban peer %s %v", sp.Addr(), err)
+		return
+	}
 	direction := directionString(sp.Inbound())
 	srvrLog.Infof("Banned peer %s (%s) for %v", host, direction,
 		cfg.BanDuration)
-	state.banned[host] = time.Now().Add(cfg.BanDuration)
+	now := time.Now()
+	err = s.banManager.Ban(target, now, now.Add(cfg.BanDuration),
+		banReasonMisbehaving, true)
+	if err != nil {
+		srvrLog.Errorf("Unable to save bans: %v", err)
+	}
 }
 
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
@@ -1419,8 +1615,15 @@ type getOutboundGroup struct {
 	reply chan int
 }
 
+// addedNodeInfo describes an added node along with the peer connected to it,
+// which is nil when the node is not connected.
+type addedNodeInfo struct {
+	addr string
+	peer *serverPeer
+}
+
 type getAddedNodesMsg struct {
-	reply chan []*serverPeer
+	reply chan []addedNodeInfo
 }
 
 type disconnectNodeMsg struct {
@@ -1435,6 +1638,7 @@ type connectNodeMsg struct {
 }
 
 type removeNodeMsg struct {
+	addr  string
 	cmp   func(*serverPeer) bool
 	reply chan error
 }
@@ -1469,6 +1673,10 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 			msg.reply <- errors.New("max peers reached")
 			return
 		}
+		if _, ok := state.addedNodes[msg.addr]; ok && msg.permanent {
+			msg.reply <- errors.New("node already added")
+			return
+		}
 		for _, peer := range state.persistentPeers {
 			if peer.Addr() == msg.addr {
 				if msg.permanent {
@@ -1486,19 +1694,60 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 			return
 		}
 
-		// TODO: if too many, nuke a non-perm peer.
-		go s.connManager.Connect(&connmgr.ConnReq{
+		// Permanent peers are remembered as added nodes and saved so
+		// they are connected to again after a restart.
+		connReq := &connmgr.ConnReq{
 			Addr:      netAddr,
 			Permanent: msg.permanent,
-		})
+		}
+		if msg.permanent {
+			state.addedNodes[msg.addr] = &addedNode{
+				addr:    msg.addr,
+				connReq: connReq,
+				persist: true,
+			}
+			err := saveAddedNodes(s.db, state.addedNodes)
+			if err != nil {
+				srvrLog.Errorf("Unable to save added nodes: %v", err)
+			}
+		}
+
+		// TODO: if too many, nuke a non-perm peer.
+		go s.connManager.Connect(connReq)
 		msg.reply <- nil
 	case removeNodeMsg:
-		found := disconnectPeer(state.persistentPeers, msg.cmp, func(sp *serverPeer) {
+		// The added node is either identified by the address it was
+		// added with or by the peer connected to it.
+		node := state.addedNodes[msg.addr]
+		cmp := func(sp *serverPeer) bool {
+			return msg.cmp(sp) ||
+				(node != nil && sp.connReq == node.connReq)
+		}
+		found := disconnectPeer(state.persistentPeers, cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
 			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			if node == nil {
+				node = state.addedNodeByConnReq(sp.connReq)
+			}
 		})
 
+		if node != nil {
+			delete(state.addedNodes, node.addr)
+			err := saveAddedNodes(s.db, state.addedNodes)
+			if err != nil {
+				srvrLog.Errorf("Unable to save added nodes: %v", err)
+			}
+
+			// The connection request of a connected node is removed
+			// once its peer is done.  Otherwise, remove it now so
+			// that pending retries are canceled.
+			if !found && node.connReq != nil {
+				go s.connManager.Remove(node.connReq.ID())
+			}
+			found = true
+		}
+
 		if found {
 			msg.reply <- nil
 		} else {
@@ -1511,14 +1760,26 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		} else {
 			msg.reply <- 0
 		}
-	// Request a list of the persistent (added) peers.
+	// Request a list of the added nodes along with the peers connected to
+	// them.
 	case getAddedNodesMsg:
-		// Respond with a slice of the relevant peers.
-		peers := make([]*serverPeer, 0, len(state.persistentPeers))
+		peers := make(map[*connmgr.ConnReq]*serverPeer,
+			len(state.persistentPeers))
 		for _, sp := range state.persistentPeers {
-			peers = append(peers, sp)
+			peers[sp.connReq] = sp
 		}
-		msg.reply <- peers
+		nodes := make([]addedNodeInfo, 0, len(state.addedNodes))
+		for addr, node := range state.addedNodes {
+			var sp *serverPeer
+			if node.connReq != nil {
+				sp = peers[node.connReq]
+			}
+			nodes = append(nodes, addedNodeInfo{addr: addr, peer: sp})
+		}
+		sort.Slice(nodes, func(i, j int) bool {
+			return nodes[i].addr < nodes[j].addr
+		})
+		msg.reply <- nodes
 	case disconnectNodeMsg:
 		// Check inbound peers. We pass a nil callback since we don't
 		// require any additional actions on disconnect for inbound peers.
@@ -1577,6 +1838,13 @@ func disconnectPeer(peerList map[int32]*serverPeer, compareFunc func(*serverPeer
 
 // newPeerConfig returns the configuration for the given serverPeer.
 func newPeerConfig(sp *serverPeer) *peer.Config {
+	// Whitelisted peers are not subject to the rate limits.
+	var maxUploadRate, maxDownloadRate int64
+	if !sp.isWhitelisted {
+		maxUploadRate = cfg.MaxPeerUpload * 1024
+		maxDownloadRate = cfg.MaxPeerDownload * 1024
+	}
+
 	return &peer.Config{
 		Listeners: peer.MessageListeners{
 			OnVersion:     sp.OnVersion,
@@ -1585,6 +1853,7 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnBlock:       sp.OnBlock,
 			OnInv:         sp.OnInv,
 			OnHeaders:     sp.OnHeaders,
+			OnNotFound:    sp.OnNotFound,
 			OnGetData:     sp.OnGetData,
 			OnGetBlocks:   sp.OnGetBlocks,
 			OnGetHeaders:  sp.OnGetHeaders,
@@ -1611,8 +1880,11 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		UserAgentComments: cfg.UserAgentComments,
 		ChainParams:       sp.server.chainParams,
 		Services:          sp.server.services,
-		DisableRelayTx:    cfg.BlocksOnly,
+		DisableRelayTx:    cfg.BlocksOnly || sp.blockRelayOnly,
 		ProtocolVersion:   peer.MaxProtocolVersion,
+		MaxUploadRate:     maxUploadRate,
+		MaxDownloadRate:   maxDownloadRate,
+		AllowZBlocks:      sp.isWhitelisted,
 	}
 }
 
@@ -1635,6 +1907,8 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	sp := newServerPeer(s, c.Permanent)
+	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
+	sp.blockRelayOnly = c.BlockRelayOnly
 	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
 	if err != nil {
 		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
@@ -1642,7 +1916,6 @@ func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	}
 	sp.Peer = p
 	sp.connReq = c
-	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
 	sp.AssociateConnection(conn)
 	go s.peerDoneHandler(sp)
 	s.addrManager.Attempt(sp.NA())
@@ -1687,8 +1960,8 @@ func (s *server) peerHandler() {
 		inboundPeers:    make(map[int32]*serverPeer),
 		persistentPeers: make(map[int32]*serverPeer),
 		outboundPeers:   make(map[int32]*serverPeer),
-		banned:          make(map[string]time.Time),
 		outboundGroups:  make(map[string]int),
+		addedNodes:      s.addedNodes,
 	}
 
 	if !cfg.DisableDNSSeed {
@@ -1737,6 +2010,22 @@ out:
 			s.handleQuery(state, qmsg)
 
 		case <-s.quit:
+			// Save the block-relay-only peers as anchors to connect
+			// to first on the next start.
+			var anchors []string
+			for _, sp := range state.outboundPeers {
+				if sp.blockRelayOnly {
+					anchors = append(anchors, sp.Addr())
+				}
+			}
+			if len(anchors) > 0 {
+				path := filepath.Join(cfg.DataDir, anchorsFilename)
+				if err := writeAnchors(path, anchors); err != nil {
+					srvrLog.Errorf("Unable to save anchors: %v",
+						err)
+				}
+			}
+
 			// Disconnect all peers on server shutdown.
 			state.forAllPeers(func(sp *serverPeer) {
 				srvrLog.Tracef("Shutdown peer %s", sp)
@@ -1803,6 +2092,33 @@ func (s *server) ConnectedCount() int32 {
 	return <-replyChan
 }
 
+// PeerCounts returns the number of connected inbound and outbound peers.  It
+// returns zero counts once the server is shutting down.
+func (s *server) PeerCounts() (int, int) {
+	reply := make(chan []*serverPeer, 1)
+	select {
+	case s.query <- getPeersMsg{reply: reply}:
+	case <-s.quit:
+		return 0, 0
+	}
+
+	var peers []*serverPeer
+	select {
+	case peers = <-reply:
+	case <-s.quit:
+		return 0, 0
+	}
+	var inbound, outbound int
+	for _, sp := range peers {
+		if sp.Inbound() {
+			inbound++
+		} else {
+			outbound++
+		}
+	}
+	return inbound, outbound
+}
+
 // OutboundGroupCount returns the number of peers connected to the given
 // outbound group key.
 func (s *server) OutboundGroupCount(key string) int {
@@ -1815,6 +2131,7 @@ func (s *server) OutboundGroupCount(key string) int {
 // for the server.  It is safe for concurrent access.
 func (s *server) AddBytesSent(bytesSent uint64) {
 	atomic.AddUint64(&s.bytesSent, bytesSent)
+	s.uploadTarget.AddBytesSent(bytesSent, time.Now())
 }
 
 // AddBytesReceived adds the passed number of bytes to the total bytes received
@@ -1845,10 +2162,12 @@ func (s *server) UpdatePeerHeights(latestBlkHash *chainhash.Hash, latestHeight i
 // rebroadcastHandler keeps track of user submitted inventories that we have
 // sent out but have not yet made it into a block. We periodically rebroadcast
 // them in case our peers restarted or otherwise lost track of them.
-func (s *server) rebroadcastHandler() {
+//
+// The pending inventories are provided by the caller so they are retained
+// when the handler is restarted by the supervisor.
+func (s *server) rebroadcastHandler(pendingInvs rebroadcastSet) {
 	// Wait 5 min before first tx rebroadcast.
 	timer := time.NewTimer(5 * time.Minute)
-	pendingInvs := make(map[wire.InvVect]interface{})
 
 out:
 	for {
@@ -1857,23 +2176,22 @@ out:
 			switch msg := riv.(type) {
 			// Incoming InvVects are added to our map of RPC txs.
 			case broadcastInventoryAdd:
-				pendingInvs[*msg.invVect] = msg.data
+				pendingInvs.Add(msg.invVect, msg.data, time.Now())
 
-			// When an InvVect has been added to a block, we can
-			// now remove it, if it was present.
+			// When an InvVect has been added to a block or removed
+			// from the memory pool, we can now remove it, if it was
+			// present.
 			case broadcastInventoryDel:
-				if _, ok := pendingInvs[*msg]; ok {
-					delete(pendingInvs, *msg)
-				}
+				pendingInvs.Remove(msg)
+
+			case getRebroadcastSetMsg:
+				msg.reply <- pendingInvs.Results()
 			}
 
 		case <-timer.C:
 			// Any inventory we have has not made it into a block
 			// yet. We periodically resubmit them until they have.
-			for iv, data := range pendingInvs {
-				ivCopy := iv
-				s.RelayInventory(&ivCopy, data)
-			}
+			pendingInvs.Rebroadcast(time.Now(), s.RelayInventory)
 
 			// Process at a random time up to 30mins (in seconds)
 			// in the future.
@@ -1897,7 +2215,6 @@ cleanup:
 			break cleanup
 		}
 	}
-	s.wg.Done()
 }
 
 // Start begins accepting connections from peers.
@@ -1919,7 +2236,42 @@ func (s *server) Start() {
 
 	if s.nat != nil {
 		s.wg.Add(1)
-		go s.upnpUpdateThread()
+		go func() {
+			s.supervisor.Run("upnp", s.upnpUpdateThread)
+			s.wg.Done()
+		}()
+	}
+
+	// Start monitoring the health of the proxy used for outbound
+	// connections.
+	if s.proxyHealth != nil {
+		s.wg.Add(1)
+		go func() {
+			s.supervisor.Run("proxy health", func() {
+				s.proxyHealth.Run(s.quit)
+			})
+			s.wg.Done()
+		}()
+	}
+
+	if s.metadataBackup != nil {
+		s.wg.Add(1)
+		go func() {
+			s.supervisor.Run("metadata backup", func() {
+				s.metadataBackup.Run(s.quit)
+			})
+			s.wg.Done()
+		}()
+	}
+
+	for _, webhook := range s.webhooks {
+		s.wg.Add(1)
+		go func(webhook *webhookNotifier) {
+			s.supervisor.Run("webhook", func() {
+				webhook.Run(s.quit)
+			})
+			s.wg.Done()
+		}(webhook)
 	}
 
 	if !cfg.DisableRPC {
@@ -1927,7 +2279,13 @@ func (s *server) Start() {
 
 		// Start the rebroadcastHandler, which ensures user tx received by
 		// the RPC server are rebroadcast until being included in a block.
-		go s.rebroadcastHandler()
+		pendingInvs := make(rebroadcastSet)
+		go func() {
+			s.supervisor.Run("rebroadcast", func() {
+				s.rebroadcastHandler(pendingInvs)
+			})
+			s.wg.Done()
+		}()
 
 		s.rpcServer.Start()
 	}
@@ -1957,6 +2315,14 @@ func (s *server) Stop() error {
 		s.rpcServer.Stop()
 	}
 
+	// Stop recording the trace.  Events which are still being processed
+	// are not recorded anymore.
+	if s.traceRecorder != nil {
+		if err := s.traceRecorder.Close(); err != nil {
+			srvrLog.Errorf("Unable to close trace file: %v", err)
+		}
+	}
+
 	// Save fee estimator state in the database.
 	s.db.Update(func(tx database.Tx) error {
 		metadata := tx.Metadata()
@@ -2109,8 +2475,6 @@ out:
 	} else {
 		srvrLog.Debugf("successfully disestablished UPnP port mapping")
 	}
-
-	s.wg.Done()
 }
 
 // setupRPCListeners returns a slice of listners that are configured for use
@@ -2133,9 +2497,13 @@ func setupRPCListeners() ([]net.Listener, error) {
 			return nil, err
 		}
 
+		// Offer HTTP/2 so clients can issue many concurrent requests
+		// over a single connection.  Websocket clients negotiate
+		// HTTP/1.1.
 		tlsConfig := tls.Config{
 			Certificates: []tls.Certificate{keypair},
 			MinVersion:   tls.VersionTLS12,
+			NextProtos:   []string{"h2", "http/1.1"},
 		}
 
 		// Change the standard net.Listen function to the tls one.
@@ -2170,6 +2538,9 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	if cfg.NoPeerBloomFilters {
 		services &^= wire.SFNodeBloom
 	}
+	if cfg.ZstdBlocks {
+		services |= wire.SFNodeZstdBlocks
+	}
 
 	amgr := addrmgr.New(cfg.DataDir, btcdLookup)
 
@@ -2204,21 +2575,50 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		services:             services,
 		sigCache:             txscript.NewSigCache(cfg.SigCacheMaxSize),
 		hashCache:            txscript.NewHashCache(cfg.SigCacheMaxSize),
+		uploadTarget: newUploadTarget(cfg.MaxUploadTarget*1024*1024,
+			uploadTargetTimeframe),
+		banManager: newBanManager(filepath.Join(cfg.DataDir,
+			banListFilename), cfg.banScores),
+	}
+	s.supervisor = newSupervisor(s.quit)
+
+	// A node replaying a trace uses the time recorded with each event, so
+	// the blocks are validated exactly as when they were recorded.
+	if cfg.ReplayTrace != "" {
+		s.traceClock = newTraceClock()
+		s.timeSource = s.traceClock
+	}
+	if cfg.RecordTrace != "" {
+		var err error
+		s.traceRecorder, err = newTraceRecorder(cfg.RecordTrace,
+			chainParams.Net, s.timeSource)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := s.banManager.Load(time.Now()); err != nil {
+		srvrLog.Warnf("Unable to load bans: %v", err)
+	}
+
+	if cfg.MetaBackupInterval > 0 {
+		s.metadataBackup = newMetadataBackupManager(db,
+			metadataBackupDir(), cfg.MetaBackupInterval,
+			cfg.MetaBackups)
 	}
 
 	// Create the transaction and address indexes if needed.
 	//
 	// CAUTION: the txindex needs to be first in the indexes array because
-	// the addrindex uses data from the txindex during catchup.  If the
-	// addrindex is run first, it may not have the transactions from the
-	// current block indexed.
+	// the addrindex and rewardindex use data from the txindex during
+	// catchup.  If they are run first, they may not have the transactions
+	// from the current block indexed.
 	var indexes []indexers.Indexer
-	if cfg.TxIndex || cfg.AddrIndex {
-		// Enable transaction index if address index is enabled since it
-		// requires it.
+	if cfg.TxIndex || cfg.AddrIndex || cfg.RewardIndex {
+		// Enable transaction index if address or block reward index is
+		// enabled since they require it.
 		if !cfg.TxIndex {
 			indxLog.Infof("Transaction index enabled because it " +
-				"is required by the address index")
+				"is required by the address or block reward index")
 			cfg.TxIndex = true
 		} else {
 			indxLog.Info("Transaction index is enabled")
@@ -2232,6 +2632,11 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		s.addrIndex = indexers.NewAddrIndex(db, chainParams)
 		indexes = append(indexes, s.addrIndex)
 	}
+	if cfg.RewardIndex {
+		indxLog.Info("Block reward index is enabled")
+		s.rewardIndex = indexers.NewRewardIndex(db)
+		indexes = append(indexes, s.rewardIndex)
+	}
 
 	// Create an index manager if any of the optional indexes are enabled.
 	var indexManager blockchain.IndexManager
@@ -2248,19 +2653,41 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	// Create a new block chain instance with the appropriate configuration.
 	var err error
 	s.chain, err = blockchain.New(&blockchain.Config{
-		DB:           s.db,
-		Interrupt:    interrupt,
-		ChainParams:  s.chainParams,
-		Checkpoints:  checkpoints,
-		TimeSource:   s.timeSource,
-		SigCache:     s.sigCache,
-		IndexManager: indexManager,
-		HashCache:    s.hashCache,
+		DB:            s.db,
+		Interrupt:     interrupt,
+		ChainParams:   s.chainParams,
+		Checkpoints:   checkpoints,
+		TimeSource:    s.timeSource,
+		SigCache:      s.sigCache,
+		IndexManager:  indexManager,
+		HashCache:     s.hashCache,
+		UndoDepth:     cfg.UndoDepth,
+		MaxReorgDepth: cfg.MaxReorgDepth,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	// Queue chain notifications for the configured webhooks.  The outboxes
+	// are opened before any blocks are processed, so no notifications are
+	// missed.
+	for _, url := range cfg.Webhooks {
+		outbox, err := openDiskOutbox(webhookOutboxPath(url),
+			int64(cfg.WebhookOutboxSize)*1024*1024,
+			cfg.WebhookOutboxAge)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open outbox of webhook "+
+				"%s: %v", url, err)
+		}
+		if n := outbox.Len(); n > 0 {
+			srvrLog.Infof("Loaded %d queued notifications for webhook %s",
+				n, url)
+		}
+		webhook := newWebhookNotifier(url, outbox)
+		s.chain.Subscribe(webhook.handleBlockchainNotification)
+		s.webhooks = append(s.webhooks, webhook)
+	}
+
 	// Search for a FeeEstimator state in the database. If none can be found
 	// or if it cannot be loaded, create a new one.
 	db.Update(func(tx database.Tx) error {
@@ -2299,8 +2726,13 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			MaxOrphanTxs:         cfg.MaxOrphanTxs,
 			MaxOrphanTxSize:      defaultMaxOrphanTxSize,
 			MaxSigOpCostPerTx:    blockchain.MaxBlockSigOpsCost / 4,
-			MinRelayTxFee:        cfg.minRelayTxFee,
 			MaxTxVersion:         2,
+			Standard:             *cfg.standardPolicy,
+			Exceptions:           cfg.policyExceptions,
+			MaxPoolSize:          int64(cfg.MaxMempool) * 1000000,
+			MaxTxAge:             cfg.MempoolExpiry,
+
+			RequireFeeSnipingLockTime: cfg.RequireLockTime,
 		},
 		ChainParams:    chainParams,
 		FetchUtxoView:  s.chain.FetchUtxoView,
@@ -2314,17 +2746,33 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		HashCache:          s.hashCache,
 		AddrIndex:          s.addrIndex,
 		FeeEstimator:       s.feeEstimator,
+		TxRemoved: func(tx *btcutil.Tx, reason mempool.RemovalReason) {
+			if s.rpcServer != nil {
+				s.rpcServer.NotifyRemovedTransaction(tx, reason)
+
+				// Transactions which are no longer in the pool,
+				// for example because they expired, are not
+				// rebroadcast anymore.  The removal is done
+				// asynchronously since the pool is locked.
+				iv := wire.NewInvVect(wire.InvTypeTx, tx.Hash())
+				go s.RemoveRebroadcastInventory(iv)
+			}
+		},
 	}
 	s.txMemPool = mempool.New(&txC)
 
-	s.syncManager, err = netsync.New(&netsync.Config{
+	syncCfg := &netsync.Config{
 		PeerNotifier:       &s,
 		Chain:              s.chain,
 		TxMemPool:          s.txMemPool,
 		ChainParams:        s.chainParams,
 		DisableCheckpoints: cfg.DisableCheckpoints,
 		MaxPeers:           cfg.MaxPeers,
-	})
+	}
+	if s.traceRecorder != nil {
+		syncCfg.Recorder = s.traceRecorder
+	}
+	s.syncManager, err = netsync.New(syncCfg)
 	if err != nil {
 		return nil, err
 	}
@@ -2340,16 +2788,23 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		BlockMinSize:      cfg.BlockMinSize,
 		BlockMaxSize:      cfg.BlockMaxSize,
 		BlockPrioritySize: cfg.BlockPrioritySize,
-		TxMinFreeFee:      cfg.minRelayTxFee,
+		Standard:          *cfg.standardPolicy,
+		CoinbaseLockTime:  cfg.BlockLockTime,
 	}
+	txAccelerator := mining.NewTxAccelerator()
 	blockTemplateGenerator := mining.NewBlkTmplGenerator(&policy,
 		s.chainParams, s.txMemPool, s.chain, s.timeSource,
-		s.sigCache, s.hashCache)
+		s.sigCache, s.hashCache, txAccelerator)
+	processMinedBlock := s.syncManager.ProcessBlock
+	if s.traceRecorder != nil {
+		processMinedBlock = s.traceRecorder.processMinedBlock(
+			processMinedBlock)
+	}
 	s.cpuMiner = cpuminer.New(&cpuminer.Config{
 		ChainParams:            chainParams,
 		BlockTemplateGenerator: blockTemplateGenerator,
 		MiningAddrs:            cfg.miningAddrs,
-		ProcessBlock:           s.syncManager.ProcessBlock,
+		ProcessBlock:           processMinedBlock,
 		ConnectedCount:         s.ConnectedCount,
 		IsCurrent:              s.syncManager.IsCurrent,
 	})
@@ -2359,9 +2814,9 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	// in connect-only mode since it is only intended to connect to
 	// specified peers and actively avoid advertising and connecting to
 	// discovered peers in order to prevent it from becoming a public test
-	// network.
+	// network.  A node replaying a trace does not connect to any peers.
 	var newAddressFunc func() (net.Addr, error)
-	if !cfg.SimNet && len(cfg.ConnectPeers) == 0 {
+	if !cfg.SimNet && len(cfg.ConnectPeers) == 0 && cfg.ReplayTrace == "" {
 		newAddressFunc = func() (net.Addr, error) {
 			for tries := 0; tries < 100; tries++ {
 				addr := s.addrManager.GetAddress()
@@ -2405,24 +2860,86 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	if cfg.MaxPeers < targetOutbound {
 		targetOutbound = cfg.MaxPeers
 	}
+	dial := btcdDial
+	if cfg.Proxy != "" || cfg.OnionProxy != "" {
+		// Track the health of the main proxy when there is one and the
+		// onion proxy otherwise.  Connections to .onion addresses are
+		// only made through the main proxy when there is no separate
+		// onion proxy.
+		proxyAddr := cfg.Proxy
+		viaProxy := func(addr net.Addr) bool {
+			return !strings.Contains(addr.String(), ".onion:") ||
+				cfg.OnionProxy == "" || cfg.OnionProxy == cfg.Proxy
+		}
+		if cfg.Proxy == "" {
+			proxyAddr = cfg.OnionProxy
+			viaProxy = func(addr net.Addr) bool {
+				return strings.Contains(addr.String(), ".onion:")
+			}
+		}
+		var refresh func() error
+		if cfg.TorControl != "" {
+			tc := connmgr.NewTorController(cfg.TorControl,
+				cfg.TorControlPass)
+			refresh = tc.NewCircuits
+		}
+		s.proxyHealth = newProxyHealthMonitor(proxyAddr,
+			cfg.TorIsolation, btcdDial, viaProxy, refresh,
+			s.supervisor)
+		dial = s.proxyHealth.Dial
+	}
+
+	// Block-relay-only connections are only made to discovered peers, and
+	// the first ones to the anchors saved on the last shutdown.
+	var targetBlockRelayOnly int
+	var anchors []net.Addr
+	if newAddressFunc != nil {
+		targetBlockRelayOnly = cfg.BlockRelayPeers
+		if cfg.MaxPeers-targetOutbound < targetBlockRelayOnly {
+			targetBlockRelayOnly = cfg.MaxPeers - targetOutbound
+		}
+		path := filepath.Join(cfg.DataDir, anchorsFilename)
+		addrs, err := readAnchors(path)
+		if err != nil {
+			srvrLog.Warnf("Unable to read anchors: %v", err)
+		}
+		for _, addr := range addrs {
+			netAddr, err := addrStringToNetAddr(addr)
+			if err != nil {
+				srvrLog.Warnf("Ignoring anchor %s: %v", addr, err)
+				continue
+			}
+			anchors = append(anchors, netAddr)
+		}
+	}
 	cmgr, err := connmgr.New(&connmgr.Config{
-		Listeners:      listeners,
-		OnAccept:       s.inboundPeerConnected,
-		RetryDuration:  connectionRetryInterval,
-		TargetOutbound: uint32(targetOutbound),
-		Dial:           btcdDial,
-		OnConnection:   s.outboundPeerConnected,
-		GetNewAddress:  newAddressFunc,
+		Listeners:            listeners,
+		OnAccept:             s.inboundPeerConnected,
+		RetryDuration:        connectionRetryInterval,
+		TargetOutbound:       uint32(targetOutbound),
+		TargetBlockRelayOnly: uint32(targetBlockRelayOnly),
+		Anchors:              anchors,
+		Dial:                 dial,
+		OnConnection:         s.outboundPeerConnected,
+		GetNewAddress:        newAddressFunc,
 	})
 	if err != nil {
 		return nil, err
 	}
 	s.connManager = cmgr
 
-	// Start up persistent peers.
+	// Start up persistent peers.  Unless the connections are restricted
+	// with the connect option, the nodes saved by the addnode RPC are
+	// connected to in addition to the configured ones.
+	s.addedNodes = make(map[string]*addedNode)
 	permanentPeers := cfg.ConnectPeers
-	if len(permanentPeers) == 0 {
+	var savedNodes []string
+	if len(permanentPeers) == 0 && cfg.ReplayTrace == "" {
 		permanentPeers = cfg.AddPeers
+		savedNodes, err = loadAddedNodes(db)
+		if err != nil {
+			srvrLog.Warnf("Unable to load added nodes: %v", err)
+		}
 	}
 	for _, addr := range permanentPeers {
 		netAddr, err := addrStringToNetAddr(addr)
@@ -2430,10 +2947,34 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			return nil, err
 		}
 
-		go s.connManager.Connect(&connmgr.ConnReq{
+		connReq := &connmgr.ConnReq{
 			Addr:      netAddr,
 			Permanent: true,
-		})
+		}
+		s.addedNodes[addr] = &addedNode{addr: addr, connReq: connReq}
+		go s.connManager.Connect(connReq)
+	}
+	for _, addr := range savedNodes {
+		if node, ok := s.addedNodes[addr]; ok {
+			node.persist = true
+			continue
+		}
+
+		// Saved nodes which can't be resolved at the moment are kept so
+		// they are not lost, but they are not connected to.
+		node := &addedNode{addr: addr, persist: true}
+		s.addedNodes[addr] = node
+		netAddr, err := addrStringToNetAddr(addr)
+		if err != nil {
+			srvrLog.Warnf("Unable to resolve added node %s: %v",
+				addr, err)
+			continue
+		}
+		node.connReq = &connmgr.ConnReq{
+			Addr:      netAddr,
+			Permanent: true,
+		}
+		go s.connManager.Connect(node.connReq)
 	}
 
 	if !cfg.DisableRPC {
@@ -2448,20 +2989,24 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		}
 
 		s.rpcServer, err = newRPCServer(&rpcserverConfig{
-			Listeners:    rpcListeners,
-			StartupTime:  s.startupTime,
-			ConnMgr:      &rpcConnManager{&s},
-			SyncMgr:      &rpcSyncMgr{&s, s.syncManager},
-			TimeSource:   s.timeSource,
-			Chain:        s.chain,
-			ChainParams:  chainParams,
-			DB:           db,
-			TxMemPool:    s.txMemPool,
-			Generator:    blockTemplateGenerator,
-			CPUMiner:     s.cpuMiner,
-			TxIndex:      s.txIndex,
-			AddrIndex:    s.addrIndex,
-			FeeEstimator: s.feeEstimator,
+			Listeners:     rpcListeners,
+			StartupTime:   s.startupTime,
+			ConnMgr:       &rpcConnManager{&s},
+			SyncMgr:       &rpcSyncMgr{&s, s.syncManager},
+			TimeSource:    s.timeSource,
+			Chain:         s.chain,
+			ChainParams:   chainParams,
+			DB:            db,
+			TxMemPool:     s.txMemPool,
+			Generator:     blockTemplateGenerator,
+			CPUMiner:      s.cpuMiner,
+			Accelerator:   txAccelerator,
+			TxIndex:       s.txIndex,
+			AddrIndex:     s.addrIndex,
+			RewardIndex:   s.rewardIndex,
+			FeeEstimator:  s.feeEstimator,
+			Supervisor:    s.supervisor,
+			TraceRecorder: s.traceRecorder,
 		})
 		if err != nil {
 			return nil, err
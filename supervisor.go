@@ -0,0 +1,164 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btclog"
+)
+
+const (
+	// defaultMinRestartBackoff is the amount of time the supervisor waits
+	// before restarting a subsystem the first time it panics.
+	defaultMinRestartBackoff = time.Second
+
+	// defaultMaxRestartBackoff is the maximum amount of time the supervisor
+	// waits before restarting a subsystem that repeatedly panics.
+	defaultMaxRestartBackoff = time.Minute
+
+	// defaultStableRunDuration is the amount of time a restarted subsystem
+	// must run without panicking before its restart backoff is reset.
+	defaultStableRunDuration = 10 * time.Minute
+)
+
+// supervisor runs long-running goroutines for non-critical subsystems such as
+// the websocket notification manager.  A panic in a supervised subsystem is
+// recovered, logged along with its stack trace, recorded as a node warning,
+// and the subsystem is restarted after a backoff which doubles with each
+// consecutive panic.  This prevents a bug in a subsystem that the rest of the
+// node does not depend on from taking down the entire process.
+//
+// Critical subsystems, such as the chain and the sync manager, must not be
+// supervised since restarting them could leave the node in an inconsistent
+// state.
+type supervisor struct {
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	stableRun  time.Duration
+	quit       <-chan struct{}
+	log        btclog.Logger
+
+	mtx      sync.Mutex
+	warnings map[string]string
+}
+
+// newSupervisor returns a new supervisor which stops restarting subsystems once
+// the provided quit channel is closed.
+func newSupervisor(quit <-chan struct{}) *supervisor {
+	return &supervisor{
+		minBackoff: defaultMinRestartBackoff,
+		maxBackoff: defaultMaxRestartBackoff,
+		stableRun:  defaultStableRunDuration,
+		quit:       quit,
+		log:        srvrLog,
+		warnings:   make(map[string]string),
+	}
+}
+
+// runRecovered invokes the passed function and returns the value it panicked
+// with, if any, along with the stack trace at the time of the panic.
+func runRecovered(fn func()) (panicked bool, value interface{}, stack []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			value = r
+			stack = debug.Stack()
+		}
+	}()
+
+	fn()
+	return false, nil, nil
+}
+
+// Run invokes the passed function, which is identified by name in log messages
+// and warnings, and restarts it whenever it panics.  It blocks until the
+// function returns without panicking or the supervisor's quit channel is
+// closed while waiting to restart it.
+//
+// The function is restarted with the same closure, so any state which must
+// survive a restart should be held outside of it.
+func (s *supervisor) Run(name string, fn func()) {
+	backoff := s.minBackoff
+	panics := 0
+	for {
+		start := time.Now()
+		panicked, value, stack := runRecovered(fn)
+		if !panicked {
+			return
+		}
+
+		// Reset the backoff when the subsystem ran long enough to be
+		// considered stable before panicking again.
+		if time.Since(start) >= s.stableRun {
+			backoff = s.minBackoff
+		}
+
+		panics++
+		s.log.Criticalf("Subsystem %s panicked: %v\n%s", name, value,
+			stack)
+		s.setWarning(name, fmt.Sprintf("subsystem %s panicked %d "+
+			"time(s), last at %v: %v", name, panics,
+			time.Now().UTC().Format(time.RFC3339), value))
+
+		s.log.Warnf("Restarting subsystem %s in %v", name, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-s.quit:
+			return
+		}
+
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// setWarning records a warning for the named subsystem, replacing any warning
+// previously recorded for it.
+//
+// This function is safe for concurrent access.
+func (s *supervisor) setWarning(name, warning string) {
+	s.mtx.Lock()
+	s.warnings[name] = warning
+	s.mtx.Unlock()
+}
+
+// clearWarning removes the warning recorded for the named subsystem, if any.
+//
+// This function is safe for concurrent access.
+func (s *supervisor) clearWarning(name string) {
+	s.mtx.Lock()
+	delete(s.warnings, name)
+	s.mtx.Unlock()
+}
+
+// Warnings returns the warnings recorded for all supervised subsystems sorted
+// by subsystem name and joined into a single string suitable for the errors
+// field of the getinfo and getmininginfo RPCs.  An empty string is returned
+// when no warnings have been recorded.
+//
+// This function is safe for concurrent access.
+func (s *supervisor) Warnings() string {
+	s.mtx.Lock()
+	names := make([]string, 0, len(s.warnings))
+	for name := range s.warnings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	warnings := make([]string, 0, len(names))
+	for _, name := range names {
+		warnings = append(warnings, s.warnings[name])
+	}
+	s.mtx.Unlock()
+
+	return strings.Join(warnings, "; ")
+}
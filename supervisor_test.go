@@ -0,0 +1,78 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btclog"
+)
+
+// TestSupervisorRestart ensures a supervised function which panics is restarted
+// until it returns normally and that the panic is reported as a warning.
+func TestSupervisorRestart(t *testing.T) {
+	t.Parallel()
+
+	quit := make(chan struct{})
+	s := newSupervisor(quit)
+	s.log = btclog.Disabled
+	s.minBackoff = time.Millisecond
+	s.maxBackoff = 2 * time.Millisecond
+
+	if warnings := s.Warnings(); warnings != "" {
+		t.Fatalf("unexpected initial warnings: %q", warnings)
+	}
+
+	runs := 0
+	s.Run("test", func() {
+		runs++
+		if runs < 3 {
+			panic("test panic")
+		}
+	})
+	if runs != 3 {
+		t.Fatalf("unexpected number of runs - got %d, want 3", runs)
+	}
+
+	warnings := s.Warnings()
+	if !strings.Contains(warnings, "subsystem test panicked 2 time(s)") ||
+		!strings.Contains(warnings, "test panic") {
+
+		t.Fatalf("unexpected warnings: %q", warnings)
+	}
+}
+
+// TestSupervisorQuit ensures the supervisor stops restarting a function which
+// panics once its quit channel is closed.
+func TestSupervisorQuit(t *testing.T) {
+	t.Parallel()
+
+	quit := make(chan struct{})
+	s := newSupervisor(quit)
+	s.log = btclog.Disabled
+	s.minBackoff = time.Hour
+
+	done := make(chan struct{})
+	runs := 0
+	go func() {
+		s.Run("test", func() {
+			runs++
+			panic("test panic")
+		})
+		close(done)
+	}()
+
+	close(quit)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not stop after quit")
+	}
+	if runs != 1 {
+		t.Fatalf("unexpected number of runs - got %d, want 1", runs)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TraceStep describes the execution of a single opcode by a DebugEngine.
+type TraceStep struct {
+	// ScriptIdx and OpcodeIdx are the index of the script and the offset
+	// of the opcode within it as returned by Engine.PC.
+	ScriptIdx int
+	OpcodeIdx int
+
+	// Opcode is the value of the executed opcode and Data is the data it
+	// pushes, if any.
+	Opcode byte
+	Data   []byte
+
+	// Disasm is the disassembly of the opcode as returned by
+	// Engine.DisasmPC.
+	Disasm string
+
+	// Executed is false when the opcode was skipped because it is in a
+	// conditional branch which is not executing.  Conditional opcodes are
+	// always executed.
+	Executed bool
+
+	// Stack and AltStack are the contents of the data and alternate stacks
+	// after the step, where the last item is the top of the stack.
+	Stack    [][]byte
+	AltStack [][]byte
+
+	// Err is the error returned by the step, if any.
+	Err error
+}
+
+// String returns the trace step as a human-readable string.
+func (s *TraceStep) String() string {
+	str := s.Disasm
+	if !s.Executed {
+		str += " (skipped)"
+	}
+	if s.Err != nil {
+		return fmt.Sprintf("%s: %v", str, s.Err)
+	}
+	return fmt.Sprintf("%s: stack %x altstack %x", str, s.Stack,
+		s.AltStack)
+}
+
+// DebugEngine wraps an Engine in order to record a trace of every step of the
+// script execution.  It is intended for tools such as script debuggers which
+// need to inspect the execution after the fact.  Since the stacks are copied
+// after every step, it should not be used for regular validation.
+type DebugEngine struct {
+	*Engine
+	trace []TraceStep
+}
+
+// NewDebugEngine returns a new debug engine for the provided public key
+// script, transaction, and input index.  The parameters are the same as those
+// of NewEngine.
+func NewDebugEngine(scriptPubKey []byte, tx *wire.MsgTx, txIdx int,
+	flags ScriptFlags, sigCache *SigCache, hashCache *TxSigHashes,
+	inputAmount int64) (*DebugEngine, error) {
+
+	vm, err := NewEngine(scriptPubKey, tx, txIdx, flags, sigCache,
+		hashCache, inputAmount)
+	if err != nil {
+		return nil, err
+	}
+	return &DebugEngine{Engine: vm}, nil
+}
+
+// Step executes the next opcode in the same way as Engine.Step and records the
+// step in the execution trace.
+func (vm *DebugEngine) Step() (done bool, err error) {
+	scriptIdx, opcodeIdx, err := vm.curPC()
+	if err != nil {
+		return true, err
+	}
+	pop := &vm.scripts[scriptIdx][opcodeIdx]
+	step := TraceStep{
+		ScriptIdx: scriptIdx,
+		OpcodeIdx: opcodeIdx,
+		Opcode:    pop.opcode.value,
+		Data:      pop.data,
+		Disasm:    vm.disasm(scriptIdx, opcodeIdx),
+		Executed:  vm.isBranchExecuting() || pop.isConditional(),
+	}
+
+	done, err = vm.Engine.Step()
+	step.Stack = vm.GetStack()
+	step.AltStack = vm.GetAltStack()
+	step.Err = err
+	vm.trace = append(vm.trace, step)
+	return done, err
+}
+
+// Execute executes all scripts in the same way as Engine.Execute while
+// recording every step in the execution trace.
+func (vm *DebugEngine) Execute() error {
+	for done := false; !done; {
+		var err error
+		done, err = vm.Step()
+		if err != nil {
+			return err
+		}
+	}
+	return vm.CheckErrorCondition(true)
+}
+
+// Trace returns the steps executed so far.
+func (vm *DebugEngine) Trace() []TraceStep {
+	return vm.trace
+}
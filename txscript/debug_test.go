@@ -0,0 +1,111 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestDebugEngine ensures the debug engine records the expected execution
+// trace and that the engine inspection methods report the next opcode.
+func TestDebugEngine(t *testing.T) {
+	t.Parallel()
+
+	tx := &wire.MsgTx{
+		Version: 1,
+		TxIn:    []*wire.TxIn{{Sequence: wire.MaxTxInSequenceNum}},
+		TxOut:   []*wire.TxOut{{Value: 1000000000}},
+	}
+	pkScript := mustParseShortForm("0 IF 1 ELSE 2 ENDIF 2 EQUAL")
+	vm, err := NewDebugEngine(pkScript, tx, 0, 0, nil, nil, 0)
+	if err != nil {
+		t.Fatalf("NewDebugEngine: unexpected error: %v", err)
+	}
+
+	// The empty signature script is skipped, so the first opcode of the
+	// public key script must be next to execute.
+	scriptIdx, opcodeIdx, err := vm.PC()
+	if err != nil || scriptIdx != 1 || opcodeIdx != 0 {
+		t.Fatalf("PC: unexpected result %d:%d, %v", scriptIdx,
+			opcodeIdx, err)
+	}
+	if _, err := vm.Step(); err != nil {
+		t.Fatalf("Step: unexpected error: %v", err)
+	}
+	opcode, data, err := vm.CurrentOpcode()
+	if err != nil || opcode != OP_IF || data != nil {
+		t.Fatalf("CurrentOpcode: unexpected result %x %x, %v", opcode,
+			data, err)
+	}
+	remaining, err := vm.RemainingScript()
+	if err != nil {
+		t.Fatalf("RemainingScript: unexpected error: %v", err)
+	}
+	if !bytes.Equal(remaining, pkScript[1:]) {
+		t.Fatalf("RemainingScript: unexpected script - got %x, want %x",
+			remaining, pkScript[1:])
+	}
+
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute: unexpected error: %v", err)
+	}
+	if _, _, err := vm.PC(); !IsErrorCode(err, ErrInvalidProgramCounter) {
+		t.Fatalf("PC: unexpected error after execution: %v", err)
+	}
+
+	wantOpcodes := []byte{OP_0, OP_IF, OP_1, OP_ELSE, OP_2, OP_ENDIF,
+		OP_2, OP_EQUAL}
+	trace := vm.Trace()
+	if len(trace) != len(wantOpcodes) {
+		t.Fatalf("unexpected number of trace steps - got %d, want %d",
+			len(trace), len(wantOpcodes))
+	}
+	for i, step := range trace {
+		if step.ScriptIdx != 1 || step.OpcodeIdx != i {
+			t.Fatalf("step %d: unexpected position %d:%d", i,
+				step.ScriptIdx, step.OpcodeIdx)
+		}
+		if step.Opcode != wantOpcodes[i] {
+			t.Fatalf("step %d: unexpected opcode - got %x, want %x",
+				i, step.Opcode, wantOpcodes[i])
+		}
+		if step.Executed != (step.Opcode != OP_1) {
+			t.Fatalf("step %d: unexpected executed flag %v", i,
+				step.Executed)
+		}
+		if step.Err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, step.Err)
+		}
+	}
+	last := trace[len(trace)-1]
+	if len(last.Stack) != 1 || !bytes.Equal(last.Stack[0], []byte{1}) {
+		t.Fatalf("unexpected final stack: %x", last.Stack)
+	}
+
+	// A failing opcode must be recorded with its error.
+	pkScript = mustParseShortForm("1 TOALTSTACK 0 VERIFY")
+	vm, err = NewDebugEngine(pkScript, tx, 0, 0, nil, nil, 0)
+	if err != nil {
+		t.Fatalf("NewDebugEngine: unexpected error: %v", err)
+	}
+	if err := vm.Execute(); !IsErrorCode(err, ErrVerify) {
+		t.Fatalf("Execute: unexpected error: %v", err)
+	}
+	trace = vm.Trace()
+	if len(trace) != 4 {
+		t.Fatalf("unexpected number of trace steps - got %d, want 4",
+			len(trace))
+	}
+	if len(trace[1].AltStack) != 1 || len(trace[1].Stack) != 0 {
+		t.Fatalf("unexpected stacks after TOALTSTACK: %x %x",
+			trace[1].Stack, trace[1].AltStack)
+	}
+	if !IsErrorCode(trace[3].Err, ErrVerify) {
+		t.Fatalf("unexpected error for failed step: %v", trace[3].Err)
+	}
+}
@@ -93,6 +93,24 @@ const (
 	// operation whose public key isn't serialized in a compressed format
 	// non-standard.
 	ScriptVerifyWitnessPubKeyType
+
+	// ScriptVerifyTaproot defines whether or not to verify a transaction
+	// output spends version 1 witness programs as defined by the taproot
+	// soft fork (BIP0341 and BIP0342).
+	ScriptVerifyTaproot
+
+	// ScriptVerifyDiscourageUpgradeableTaprootVersion makes taproot script
+	// path spends which use an unknown leaf version non-standard.
+	ScriptVerifyDiscourageUpgradeableTaprootVersion
+
+	// ScriptVerifyDiscourageOpSuccess makes tapscripts which contain an
+	// OP_SUCCESSx opcode non-standard.
+	ScriptVerifyDiscourageOpSuccess
+
+	// ScriptVerifyDiscourageUpgradeablePubkeyType makes tapscript
+	// signature operations on public keys of an unknown type
+	// non-standard.
+	ScriptVerifyDiscourageUpgradeablePubkeyType
 )
 
 const (
@@ -110,11 +128,47 @@ const (
 	// payToWitnessScriptHashDataSize is the size of the witness program's
 	// data push for a pay-to-witness-script-hash output.
 	payToWitnessScriptHashDataSize = 32
+
+	// payToTaprootDataSize is the size of the witness program's data push
+	// for a pay-to-taproot output.
+	payToTaprootDataSize = 32
 )
 
 // halforder is used to tame ECDSA malleability (see BIP0062).
 var halfOrder = new(big.Int).Rsh(btcec.S256().N, 1)
 
+// ExecutionStats describes the resources used by executing scripts.  It is
+// intended for measuring the cost of scripts rather than enforcing limits.
+type ExecutionStats struct {
+	// Opcodes is the number of opcodes executed, including data pushes
+	// but excluding the opcodes skipped in conditional branches which are
+	// not executed.
+	Opcodes int
+
+	// StackPeak is the largest combined number of elements on the data
+	// and alt stacks at any point during execution.
+	StackPeak int
+
+	// SigOps is the number of signature verifications performed.  Unlike
+	// the sigop counts used for the block limits, it only includes the
+	// signatures which were actually checked.
+	SigOps int
+
+	// HashOps is the number of hash opcodes executed.
+	HashOps int
+}
+
+// Add adds the passed statistics to the statistics.  The counts are summed
+// while the stack peak is the larger of the two.
+func (s *ExecutionStats) Add(other *ExecutionStats) {
+	s.Opcodes += other.Opcodes
+	if other.StackPeak > s.StackPeak {
+		s.StackPeak = other.StackPeak
+	}
+	s.SigOps += other.SigOps
+	s.HashOps += other.HashOps
+}
+
 // Engine is the virtual machine that executes scripts.
 type Engine struct {
 	scripts         [][]parsedOpcode
@@ -135,6 +189,8 @@ type Engine struct {
 	witnessVersion  int
 	witnessProgram  []byte
 	inputAmount     int64
+	taprootCtx      *taprootExecutionCtx
+	stats           ExecutionStats
 }
 
 // hasFlag returns whether the script engine instance has the passed flag set.
@@ -172,9 +228,10 @@ func (vm *Engine) executeOpcode(pop *parsedOpcode) error {
 	}
 
 	// Note that this includes OP_RESERVED which counts as a push operation.
+	// Tapscripts are not subject to the limit on the number of operations.
 	if pop.opcode.value > OP_16 {
 		vm.numOps++
-		if vm.numOps > MaxOpsPerScript {
+		if vm.numOps > MaxOpsPerScript && !vm.isTapscript() {
 			str := fmt.Sprintf("exceeded max operation limit of %d",
 				MaxOpsPerScript)
 			return scriptError(ErrTooManyOperations, str)
@@ -192,6 +249,13 @@ func (vm *Engine) executeOpcode(pop *parsedOpcode) error {
 		return nil
 	}
 
+	// Meter the executed opcode.
+	vm.stats.Opcodes++
+	switch pop.opcode.value {
+	case OP_RIPEMD160, OP_SHA1, OP_SHA256, OP_HASH160, OP_HASH256:
+		vm.stats.HashOps++
+	}
+
 	// Ensure all executed data push opcodes use the minimal encoding when
 	// the minimal data verification flag is set.
 	if vm.dstack.verifyMinimalData && vm.isBranchExecuting() &&
@@ -328,6 +392,15 @@ func (vm *Engine) verifyWitnessProgram(witness [][]byte) error {
 				len(vm.witnessProgram))
 			return scriptError(ErrWitnessProgramWrongLength, errStr)
 		}
+	} else if vm.isWitnessVersionActive(1) && !vm.bip16 &&
+		len(vm.witnessProgram) == payToTaprootDataSize &&
+		vm.hasFlag(ScriptVerifyTaproot) {
+
+		// Version 1 witness programs of 32 bytes which are not nested
+		// within P2SH are taproot outputs.
+		if err := vm.verifyTaprootWitness(witness); err != nil {
+			return err
+		}
 	} else if vm.hasFlag(ScriptVerifyDiscourageUpgradeableWitnessProgram) {
 		errStr := fmt.Sprintf("new witness program versions "+
 			"invalid: %v", vm.witnessProgram)
@@ -384,6 +457,35 @@ func (vm *Engine) DisasmScript(idx int) (string, error) {
 	return disstr, nil
 }
 
+// PC returns the index of the script and the offset of the opcode within that
+// script that will be next to execute when Step() is called.  Index 0 is the
+// signature script and 1 is the public key script.  Any redeem script or
+// witness script is appended once the scripts preceding it have executed.
+func (vm *Engine) PC() (scriptIdx int, opcodeIdx int, err error) {
+	return vm.curPC()
+}
+
+// CurrentOpcode returns the value and any pushed data of the opcode that will
+// be next to execute when Step() is called.
+func (vm *Engine) CurrentOpcode() (byte, []byte, error) {
+	scriptIdx, scriptOff, err := vm.curPC()
+	if err != nil {
+		return 0, nil, err
+	}
+	pop := &vm.scripts[scriptIdx][scriptOff]
+	return pop.opcode.value, pop.data, nil
+}
+
+// RemainingScript returns the remainder of the script currently executing,
+// starting with the opcode that will be next to execute when Step() is called.
+func (vm *Engine) RemainingScript() ([]byte, error) {
+	scriptIdx, scriptOff, err := vm.curPC()
+	if err != nil {
+		return nil, err
+	}
+	return unparseScript(vm.scripts[scriptIdx][scriptOff:])
+}
+
 // CheckErrorCondition returns nil if the running script has ended and was
 // successful, leaving a a true boolean on the stack.  An error otherwise,
 // including if the script has not finished.
@@ -395,10 +497,22 @@ func (vm *Engine) CheckErrorCondition(finalScript bool) error {
 			"error check when script unfinished")
 	}
 
-	// If we're in version zero witness execution mode, and this was the
-	// final script, then the stack MUST be clean in order to maintain
-	// compatibility with BIP16.
-	if finalScript && vm.isWitnessVersionActive(0) && vm.dstack.Depth() != 1 {
+	// Taproot key path spends have already been verified at this point
+	// and script path spends which are unconditionally successful due to
+	// an unknown leaf version or OP_SUCCESSx opcode do not execute any
+	// script.
+	if vm.taprootCtx != nil && (vm.taprootCtx.keySpend ||
+		vm.taprootCtx.mustSucceed) {
+
+		return nil
+	}
+
+	// If we're in version zero witness execution mode, or executing a
+	// tapscript, and this was the final script, then the stack MUST be
+	// clean in order to maintain compatibility with BIP16.
+	if finalScript && (vm.isWitnessVersionActive(0) || vm.isTapscript()) &&
+		vm.dstack.Depth() != 1 {
+
 		return scriptError(ErrEvalFalse, "witness program must "+
 			"have clean stack")
 	}
@@ -458,6 +572,9 @@ func (vm *Engine) Step() (done bool, err error) {
 	// The number of elements in the combination of the data and alt stacks
 	// must not exceed the maximum number of stack elements allowed.
 	combinedStackSize := vm.dstack.Depth() + vm.astack.Depth()
+	if int(combinedStackSize) > vm.stats.StackPeak {
+		vm.stats.StackPeak = int(combinedStackSize)
+	}
 	if combinedStackSize > MaxStackSize {
 		str := fmt.Sprintf("combined stack size %d > max allowed %d",
 			combinedStackSize, MaxStackSize)
@@ -559,6 +676,11 @@ func (vm *Engine) Execute() (err error) {
 	return vm.CheckErrorCondition(true)
 }
 
+// Stats returns the resources used by the script execution so far.
+func (vm *Engine) Stats() ExecutionStats {
+	return vm.stats
+}
+
 // subScript returns the script since the last OP_CODESEPARATOR.
 func (vm *Engine) subScript() []parsedOpcode {
 	return vm.scripts[vm.scriptIdx][vm.lastCodeSep:]
@@ -7,8 +7,10 @@ package txscript
 import (
 	"testing"
 
+	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
 )
 
 // TestBadPC sets the pc to a deliberately bad result then confirms that Step()
@@ -425,3 +427,105 @@ func TestCheckSignatureEncoding(t *testing.T) {
 		}
 	}
 }
+
+// TestExecutionStats ensures the resources used by executing scripts are
+// metered.
+func TestExecutionStats(t *testing.T) {
+	t.Parallel()
+
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	pubKeyHash := btcutil.Hash160(privKey.PubKey().SerializeCompressed())
+	p2pkh, err := NewScriptBuilder().AddOp(OP_DUP).AddOp(OP_HASH160).
+		AddData(pubKeyHash).AddOp(OP_EQUALVERIFY).AddOp(OP_CHECKSIG).
+		Script()
+	if err != nil {
+		t.Fatalf("failed to build script: %v", err)
+	}
+
+	tx := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: 0},
+			Sequence:         wire.MaxTxInSequenceNum,
+		}},
+		TxOut: []*wire.TxOut{{Value: 1000, PkScript: nil}},
+	}
+	sigScript, err := SignatureScript(tx, 0, p2pkh, SigHashAll, privKey,
+		true)
+	if err != nil {
+		t.Fatalf("SignatureScript: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		sigScript []byte
+		pkScript  []byte
+		want      ExecutionStats
+	}{
+		{
+			// The two pushes of the signature script and the five
+			// opcodes of the public key script are executed.  The
+			// stack peaks after pushing the public key hash.
+			name:      "p2pkh",
+			sigScript: sigScript,
+			pkScript:  p2pkh,
+			want: ExecutionStats{
+				Opcodes:   7,
+				StackPeak: 4,
+				SigOps:    1,
+				HashOps:   1,
+			},
+		},
+		{
+			// The hash in the branch which is not executed is not
+			// counted.
+			name:      "skipped branch",
+			sigScript: nil,
+			pkScript:  mustParseShortForm("0 IF SHA256 ENDIF 1"),
+			want: ExecutionStats{
+				Opcodes:   4,
+				StackPeak: 1,
+			},
+		},
+		{
+			// An empty signature fails without being checked.
+			name:      "empty signature",
+			sigScript: mustParseShortForm("0 0"),
+			pkScript:  mustParseShortForm("CHECKSIG NOT"),
+			want: ExecutionStats{
+				Opcodes:   4,
+				StackPeak: 2,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		tx.TxIn[0].SignatureScript = test.sigScript
+		vm, err := NewEngine(test.pkScript, tx, 0, 0, nil, nil, 1000)
+		if err != nil {
+			t.Errorf("%s: NewEngine: unexpected error: %v", test.name,
+				err)
+			continue
+		}
+		if err := vm.Execute(); err != nil {
+			t.Errorf("%s: Execute: unexpected error: %v", test.name,
+				err)
+			continue
+		}
+		if got := vm.Stats(); got != test.want {
+			t.Errorf("%s: got stats %+v, want %+v", test.name, got,
+				test.want)
+		}
+	}
+
+	// Aggregated statistics sum the counts and keep the largest stack.
+	stats := ExecutionStats{Opcodes: 1, StackPeak: 5, SigOps: 2, HashOps: 3}
+	stats.Add(&ExecutionStats{Opcodes: 2, StackPeak: 3, SigOps: 1})
+	want := ExecutionStats{Opcodes: 3, StackPeak: 5, SigOps: 3, HashOps: 3}
+	if stats != want {
+		t.Errorf("Add: got %+v, want %+v", stats, want)
+	}
+}
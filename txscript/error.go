@@ -276,6 +276,58 @@ const (
 	// serialized in a compressed format.
 	ErrWitnessPubKeyType
 
+	// -------------------------------
+	// Failures related to taproot.
+	// -------------------------------
+
+	// ErrTaprootSigInvalid is returned if ScriptVerifyTaproot is set and
+	// a taproot key path signature, or a non-empty signature checked by a
+	// tapscript signature opcode, fails to verify.
+	ErrTaprootSigInvalid
+
+	// ErrTaprootMerkleProofInvalid is returned if ScriptVerifyTaproot is
+	// set and the control block of a taproot script path spend does not
+	// commit the revealed script to the output key.
+	ErrTaprootMerkleProofInvalid
+
+	// ErrControlBlockInvalidLength is returned if ScriptVerifyTaproot is
+	// set and the control block of a taproot script path spend is not
+	// 33 + 32m bytes for some m in [0, 128].
+	ErrControlBlockInvalidLength
+
+	// ErrTaprootPrevOutsUnavailable is returned if ScriptVerifyTaproot is
+	// set and a taproot output is spent without the previous outputs of
+	// all transaction inputs, which the signature hash commits to, having
+	// been provided.
+	ErrTaprootPrevOutsUnavailable
+
+	// ErrTaprootPubKeyIsEmpty is returned if ScriptVerifyTaproot is set
+	// and a tapscript signature opcode is passed an empty public key.
+	ErrTaprootPubKeyIsEmpty
+
+	// ErrTaprootMaxSigOps is returned if ScriptVerifyTaproot is set and
+	// a tapscript exceeds its signature operation budget.
+	ErrTaprootMaxSigOps
+
+	// ErrTapscriptCheckMultiSig is returned if ScriptVerifyTaproot is
+	// set and OP_CHECKMULTISIG or OP_CHECKMULTISIGVERIFY is executed
+	// within a tapscript.
+	ErrTapscriptCheckMultiSig
+
+	// ErrDiscourageUpgradeableTaprootVersion is returned if
+	// ScriptVerifyDiscourageUpgradeableTaprootVersion is set and a taproot
+	// script path spend uses an unknown leaf version.
+	ErrDiscourageUpgradeableTaprootVersion
+
+	// ErrDiscourageOpSuccess is returned if ScriptVerifyDiscourageOpSuccess
+	// is set and a tapscript contains an OP_SUCCESSx opcode.
+	ErrDiscourageOpSuccess
+
+	// ErrDiscourageUpgradeablePubKeyType is returned if
+	// ScriptVerifyDiscourageUpgradeablePubkeyType is set and a tapscript
+	// signature opcode is passed a public key of an unknown type.
+	ErrDiscourageUpgradeablePubKeyType
+
 	// numErrorCodes is the maximum error code number used in tests.  This
 	// entry MUST be the last entry in the enum.
 	numErrorCodes
@@ -283,56 +335,66 @@ const (
 
 // Map of ErrorCode values back to their constant names for pretty printing.
 var errorCodeStrings = map[ErrorCode]string{
-	ErrInternal:                           "ErrInternal",
-	ErrInvalidFlags:                       "ErrInvalidFlags",
-	ErrInvalidIndex:                       "ErrInvalidIndex",
-	ErrUnsupportedAddress:                 "ErrUnsupportedAddress",
-	ErrNotMultisigScript:                  "ErrNotMultisigScript",
-	ErrTooManyRequiredSigs:                "ErrTooManyRequiredSigs",
-	ErrTooMuchNullData:                    "ErrTooMuchNullData",
-	ErrEarlyReturn:                        "ErrEarlyReturn",
-	ErrEmptyStack:                         "ErrEmptyStack",
-	ErrEvalFalse:                          "ErrEvalFalse",
-	ErrScriptUnfinished:                   "ErrScriptUnfinished",
-	ErrInvalidProgramCounter:              "ErrInvalidProgramCounter",
-	ErrScriptTooBig:                       "ErrScriptTooBig",
-	ErrElementTooBig:                      "ErrElementTooBig",
-	ErrTooManyOperations:                  "ErrTooManyOperations",
-	ErrStackOverflow:                      "ErrStackOverflow",
-	ErrInvalidPubKeyCount:                 "ErrInvalidPubKeyCount",
-	ErrInvalidSignatureCount:              "ErrInvalidSignatureCount",
-	ErrNumberTooBig:                       "ErrNumberTooBig",
-	ErrVerify:                             "ErrVerify",
-	ErrEqualVerify:                        "ErrEqualVerify",
-	ErrNumEqualVerify:                     "ErrNumEqualVerify",
-	ErrCheckSigVerify:                     "ErrCheckSigVerify",
-	ErrCheckMultiSigVerify:                "ErrCheckMultiSigVerify",
-	ErrDisabledOpcode:                     "ErrDisabledOpcode",
-	ErrReservedOpcode:                     "ErrReservedOpcode",
-	ErrMalformedPush:                      "ErrMalformedPush",
-	ErrInvalidStackOperation:              "ErrInvalidStackOperation",
-	ErrUnbalancedConditional:              "ErrUnbalancedConditional",
-	ErrMinimalData:                        "ErrMinimalData",
-	ErrInvalidSigHashType:                 "ErrInvalidSigHashType",
-	ErrSigDER:                             "ErrSigDER",
-	ErrSigHighS:                           "ErrSigHighS",
-	ErrNotPushOnly:                        "ErrNotPushOnly",
-	ErrSigNullDummy:                       "ErrSigNullDummy",
-	ErrPubKeyType:                         "ErrPubKeyType",
-	ErrCleanStack:                         "ErrCleanStack",
-	ErrNullFail:                           "ErrNullFail",
-	ErrDiscourageUpgradableNOPs:           "ErrDiscourageUpgradableNOPs",
-	ErrNegativeLockTime:                   "ErrNegativeLockTime",
-	ErrUnsatisfiedLockTime:                "ErrUnsatisfiedLockTime",
-	ErrWitnessProgramEmpty:                "ErrWitnessProgramEmpty",
-	ErrWitnessProgramMismatch:             "ErrWitnessProgramMismatch",
-	ErrWitnessProgramWrongLength:          "ErrWitnessProgramWrongLength",
-	ErrWitnessMalleated:                   "ErrWitnessMalleated",
-	ErrWitnessMalleatedP2SH:               "ErrWitnessMalleatedP2SH",
-	ErrWitnessUnexpected:                  "ErrWitnessUnexpected",
-	ErrMinimalIf:                          "ErrMinimalIf",
-	ErrWitnessPubKeyType:                  "ErrWitnessPubKeyType",
-	ErrDiscourageUpgradableWitnessProgram: "ErrDiscourageUpgradableWitnessProgram",
+	ErrInternal:                            "ErrInternal",
+	ErrInvalidFlags:                        "ErrInvalidFlags",
+	ErrInvalidIndex:                        "ErrInvalidIndex",
+	ErrUnsupportedAddress:                  "ErrUnsupportedAddress",
+	ErrNotMultisigScript:                   "ErrNotMultisigScript",
+	ErrTooManyRequiredSigs:                 "ErrTooManyRequiredSigs",
+	ErrTooMuchNullData:                     "ErrTooMuchNullData",
+	ErrEarlyReturn:                         "ErrEarlyReturn",
+	ErrEmptyStack:                          "ErrEmptyStack",
+	ErrEvalFalse:                           "ErrEvalFalse",
+	ErrScriptUnfinished:                    "ErrScriptUnfinished",
+	ErrInvalidProgramCounter:               "ErrInvalidProgramCounter",
+	ErrScriptTooBig:                        "ErrScriptTooBig",
+	ErrElementTooBig:                       "ErrElementTooBig",
+	ErrTooManyOperations:                   "ErrTooManyOperations",
+	ErrStackOverflow:                       "ErrStackOverflow",
+	ErrInvalidPubKeyCount:                  "ErrInvalidPubKeyCount",
+	ErrInvalidSignatureCount:               "ErrInvalidSignatureCount",
+	ErrNumberTooBig:                        "ErrNumberTooBig",
+	ErrVerify:                              "ErrVerify",
+	ErrEqualVerify:                         "ErrEqualVerify",
+	ErrNumEqualVerify:                      "ErrNumEqualVerify",
+	ErrCheckSigVerify:                      "ErrCheckSigVerify",
+	ErrCheckMultiSigVerify:                 "ErrCheckMultiSigVerify",
+	ErrDisabledOpcode:                      "ErrDisabledOpcode",
+	ErrReservedOpcode:                      "ErrReservedOpcode",
+	ErrMalformedPush:                       "ErrMalformedPush",
+	ErrInvalidStackOperation:               "ErrInvalidStackOperation",
+	ErrUnbalancedConditional:               "ErrUnbalancedConditional",
+	ErrMinimalData:                         "ErrMinimalData",
+	ErrInvalidSigHashType:                  "ErrInvalidSigHashType",
+	ErrSigDER:                              "ErrSigDER",
+	ErrSigHighS:                            "ErrSigHighS",
+	ErrNotPushOnly:                         "ErrNotPushOnly",
+	ErrSigNullDummy:                        "ErrSigNullDummy",
+	ErrPubKeyType:                          "ErrPubKeyType",
+	ErrCleanStack:                          "ErrCleanStack",
+	ErrNullFail:                            "ErrNullFail",
+	ErrDiscourageUpgradableNOPs:            "ErrDiscourageUpgradableNOPs",
+	ErrNegativeLockTime:                    "ErrNegativeLockTime",
+	ErrUnsatisfiedLockTime:                 "ErrUnsatisfiedLockTime",
+	ErrWitnessProgramEmpty:                 "ErrWitnessProgramEmpty",
+	ErrWitnessProgramMismatch:              "ErrWitnessProgramMismatch",
+	ErrWitnessProgramWrongLength:           "ErrWitnessProgramWrongLength",
+	ErrWitnessMalleated:                    "ErrWitnessMalleated",
+	ErrWitnessMalleatedP2SH:                "ErrWitnessMalleatedP2SH",
+	ErrWitnessUnexpected:                   "ErrWitnessUnexpected",
+	ErrMinimalIf:                           "ErrMinimalIf",
+	ErrWitnessPubKeyType:                   "ErrWitnessPubKeyType",
+	ErrDiscourageUpgradableWitnessProgram:  "ErrDiscourageUpgradableWitnessProgram",
+	ErrTaprootSigInvalid:                   "ErrTaprootSigInvalid",
+	ErrTaprootMerkleProofInvalid:           "ErrTaprootMerkleProofInvalid",
+	ErrControlBlockInvalidLength:           "ErrControlBlockInvalidLength",
+	ErrTaprootPrevOutsUnavailable:          "ErrTaprootPrevOutsUnavailable",
+	ErrTaprootPubKeyIsEmpty:                "ErrTaprootPubKeyIsEmpty",
+	ErrTaprootMaxSigOps:                    "ErrTaprootMaxSigOps",
+	ErrTapscriptCheckMultiSig:              "ErrTapscriptCheckMultiSig",
+	ErrDiscourageUpgradeableTaprootVersion: "ErrDiscourageUpgradeableTaprootVersion",
+	ErrDiscourageOpSuccess:                 "ErrDiscourageOpSuccess",
+	ErrDiscourageUpgradeablePubKeyType:     "ErrDiscourageUpgradeablePubKeyType",
 }
 
 // String returns the ErrorCode as a human-readable name.
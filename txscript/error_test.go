@@ -66,6 +66,16 @@ func TestErrorCodeStringer(t *testing.T) {
 		{ErrMinimalIf, "ErrMinimalIf"},
 		{ErrWitnessPubKeyType, "ErrWitnessPubKeyType"},
 		{ErrDiscourageUpgradableWitnessProgram, "ErrDiscourageUpgradableWitnessProgram"},
+		{ErrTaprootSigInvalid, "ErrTaprootSigInvalid"},
+		{ErrTaprootMerkleProofInvalid, "ErrTaprootMerkleProofInvalid"},
+		{ErrControlBlockInvalidLength, "ErrControlBlockInvalidLength"},
+		{ErrTaprootPrevOutsUnavailable, "ErrTaprootPrevOutsUnavailable"},
+		{ErrTaprootPubKeyIsEmpty, "ErrTaprootPubKeyIsEmpty"},
+		{ErrTaprootMaxSigOps, "ErrTaprootMaxSigOps"},
+		{ErrTapscriptCheckMultiSig, "ErrTapscriptCheckMultiSig"},
+		{ErrDiscourageUpgradeableTaprootVersion, "ErrDiscourageUpgradeableTaprootVersion"},
+		{ErrDiscourageOpSuccess, "ErrDiscourageOpSuccess"},
+		{ErrDiscourageUpgradeablePubKeyType, "ErrDiscourageUpgradeablePubKeyType"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
@@ -15,10 +15,15 @@ import (
 // This partial set of sighashes may be re-used within each input across a
 // transaction when validating all inputs. As a result, validation complexity
 // for SigHashAll can be reduced by a polynomial factor.
+//
+// The BIP0341 partial sighashes used by taproot signatures are also included
+// when the outputs spent by the transaction were provided.  Otherwise, Taproot
+// is nil and taproot signatures can't be verified.
 type TxSigHashes struct {
 	HashPrevOuts chainhash.Hash
 	HashSequence chainhash.Hash
 	HashOutputs  chainhash.Hash
+	Taproot      *TaprootSigHashes
 }
 
 // NewTxSigHashes computes, and returns the cached sighashes of the given
@@ -31,6 +36,18 @@ func NewTxSigHashes(tx *wire.MsgTx) *TxSigHashes {
 	}
 }
 
+// NewTxSigHashesWithPrevOuts computes, and returns the cached sighashes of the
+// given transaction including the taproot sighashes which commit to the
+// outputs it spends.  The taproot sighashes are omitted when the fetcher does
+// not provide the output spent by every input.
+func NewTxSigHashesWithPrevOuts(tx *wire.MsgTx, prevOuts PrevOutputFetcher) *TxSigHashes {
+	sigHashes := NewTxSigHashes(tx)
+	if prevOuts != nil {
+		sigHashes.Taproot, _ = NewTaprootSigHashes(tx, prevOuts)
+	}
+	return sigHashes
+}
+
 // HashCache houses a set of partial sighashes keyed by txid. The set of partial
 // sighashes are those introduced within BIP0143 by the new more efficient
 // sighash digest calculation algorithm. Using this threadsafe shared cache,
@@ -58,6 +75,16 @@ func (h *HashCache) AddSigHashes(tx *wire.MsgTx) {
 	h.Unlock()
 }
 
+// AddSigHashesWithPrevOuts computes, then adds the partial sighashes for the
+// passed transaction including the taproot sighashes which commit to the
+// outputs provided by the fetcher.
+func (h *HashCache) AddSigHashesWithPrevOuts(tx *wire.MsgTx, prevOuts PrevOutputFetcher) {
+	sigHashes := NewTxSigHashesWithPrevOuts(tx, prevOuts)
+	h.Lock()
+	h.sigHashes[tx.TxHash()] = sigHashes
+	h.Unlock()
+}
+
 // ContainsHashes returns true if the partial sighashes for the passed
 // transaction currently exist within the HashCache, and false otherwise.
 func (h *HashCache) ContainsHashes(txid *chainhash.Hash) bool {
@@ -224,7 +224,7 @@ const (
 	OP_NOP8                = 0xb7 // 183
 	OP_NOP9                = 0xb8 // 184
 	OP_NOP10               = 0xb9 // 185
-	OP_UNKNOWN186          = 0xba // 186
+	OP_CHECKSIGADD         = 0xba // 186
 	OP_UNKNOWN187          = 0xbb // 187
 	OP_UNKNOWN188          = 0xbc // 188
 	OP_UNKNOWN189          = 0xbd // 189
@@ -509,8 +509,10 @@ var opcodeArray = [256]opcode{
 	OP_NOP9:  {OP_NOP9, "OP_NOP9", 1, opcodeNop},
 	OP_NOP10: {OP_NOP10, "OP_NOP10", 1, opcodeNop},
 
+	// Tapscript opcodes.
+	OP_CHECKSIGADD: {OP_CHECKSIGADD, "OP_CHECKSIGADD", 1, opcodeCheckSigAdd},
+
 	// Undefined opcodes.
-	OP_UNKNOWN186: {OP_UNKNOWN186, "OP_UNKNOWN186", 1, opcodeInvalid},
 	OP_UNKNOWN187: {OP_UNKNOWN187, "OP_UNKNOWN187", 1, opcodeInvalid},
 	OP_UNKNOWN188: {OP_UNKNOWN188, "OP_UNKNOWN188", 1, opcodeInvalid},
 	OP_UNKNOWN189: {OP_UNKNOWN189, "OP_UNKNOWN189", 1, opcodeInvalid},
@@ -923,18 +925,22 @@ func opcodeNop(op *parsedOpcode, vm *Engine) error {
 // of nuisance malleability, post-segwit for version 0 witness programs, we now
 // require the following: for OP_IF and OP_NOT_IF, the top stack item MUST
 // either be an empty byte slice, or [0x01]. Otherwise, the item at the top of
-// the stack will be popped and interpreted as a boolean.
+// the stack will be popped and interpreted as a boolean.  The same
+// requirement is always enforced for tapscripts since it is a consensus rule
+// there.
 func popIfBool(vm *Engine) (bool, error) {
-	// When not in witness execution mode, not executing a v0 witness
-	// program, or the minimal if flag isn't set pop the top stack item as
-	// a normal bool.
-	if !vm.isWitnessVersionActive(0) || !vm.hasFlag(ScriptVerifyMinimalIf) {
+	// When not executing a tapscript and either not in witness execution
+	// mode, not executing a v0 witness program, or the minimal if flag
+	// isn't set pop the top stack item as a normal bool.
+	if !vm.isTapscript() && (!vm.isWitnessVersionActive(0) ||
+		!vm.hasFlag(ScriptVerifyMinimalIf)) {
+
 		return vm.dstack.PopBool()
 	}
 
 	// At this point, a v0 witness program is being executed and the minimal
-	// if flag is set, so enforce additional constraints on the top stack
-	// item.
+	// if flag is set, or a tapscript is being executed, so enforce
+	// additional constraints on the top stack item.
 	so, err := vm.dstack.PopByteArray()
 	if err != nil {
 		return false, err
@@ -2025,6 +2031,12 @@ func opcodeHash256(op *parsedOpcode, vm *Engine) error {
 // This opcode does not change the contents of the data stack.
 func opcodeCodeSeparator(op *parsedOpcode, vm *Engine) error {
 	vm.lastCodeSep = vm.scriptOff
+
+	// Tapscript signatures commit to the position of the opcode itself
+	// rather than the position of the script which follows it.
+	if vm.isTapscript() {
+		vm.taprootCtx.codeSepPos = uint32(vm.scriptOff - 1)
+	}
 	return nil
 }
 
@@ -2053,6 +2065,17 @@ func opcodeCheckSig(op *parsedOpcode, vm *Engine) error {
 		return err
 	}
 
+	// Tapscripts use schnorr signatures which are verified according to
+	// BIP0342.
+	if vm.isTapscript() {
+		valid, err := vm.tapscriptCheckSig(fullSigBytes, pkBytes)
+		if err != nil {
+			return err
+		}
+		vm.dstack.PushBool(valid)
+		return nil
+	}
+
 	// The signature actually needs needs to be longer than this, but at
 	// least 1 byte is needed for the hash type below.  The full length is
 	// checked depending on the script flags and upon parsing the signature.
@@ -2130,6 +2153,7 @@ func opcodeCheckSig(op *parsedOpcode, vm *Engine) error {
 		return nil
 	}
 
+	vm.stats.SigOps++
 	var valid bool
 	if vm.sigCache != nil {
 		var sigHash chainhash.Hash
@@ -2166,6 +2190,43 @@ func opcodeCheckSigVerify(op *parsedOpcode, vm *Engine) error {
 	return err
 }
 
+// opcodeCheckSigAdd treats the top 3 items on the stack as a public key, an
+// integer, and a schnorr signature and replaces them with the integer
+// incremented by one if the signature is not empty and is valid, or the
+// integer unchanged if the signature is empty.  A non-empty signature which is
+// not valid results in a script error.  It is only valid within tapscripts and
+// is treated as an invalid opcode otherwise.
+//
+// Stack transformation: [... signature n pubkey] -> [... n+success]
+func opcodeCheckSigAdd(op *parsedOpcode, vm *Engine) error {
+	if !vm.isTapscript() {
+		return opcodeInvalid(op, vm)
+	}
+
+	pkBytes, err := vm.dstack.PopByteArray()
+	if err != nil {
+		return err
+	}
+	n, err := vm.dstack.PopInt()
+	if err != nil {
+		return err
+	}
+	sigBytes, err := vm.dstack.PopByteArray()
+	if err != nil {
+		return err
+	}
+
+	valid, err := vm.tapscriptCheckSig(sigBytes, pkBytes)
+	if err != nil {
+		return err
+	}
+	if valid {
+		n++
+	}
+	vm.dstack.PushInt(n)
+	return nil
+}
+
 // parsedSigInfo houses a raw signature along with its parsed form and a flag
 // for whether or not it has already been parsed.  It is used to prevent parsing
 // the same signature multiple times when verifying a multisig.
@@ -2195,6 +2256,13 @@ type parsedSigInfo struct {
 // Stack transformation:
 // [... dummy [sig ...] numsigs [pubkey ...] numpubkeys] -> [... bool]
 func opcodeCheckMultiSig(op *parsedOpcode, vm *Engine) error {
+	// Tapscripts replace multisig with OP_CHECKSIGADD.
+	if vm.isTapscript() {
+		str := fmt.Sprintf("attempt to execute %s in tapscript",
+			op.opcode.name)
+		return scriptError(ErrTapscriptCheckMultiSig, str)
+	}
+
 	numKeys, err := vm.dstack.PopInt()
 	if err != nil {
 		return err
@@ -2380,6 +2448,7 @@ func opcodeCheckMultiSig(op *parsedOpcode, vm *Engine) error {
 			hash = calcSignatureHash(script, hashType, &vm.tx, vm.txIdx)
 		}
 
+		vm.stats.SigOps++
 		var valid bool
 		if vm.sigCache != nil {
 			var sigHash chainhash.Hash
@@ -24,6 +24,7 @@ type SigHashType uint32
 
 // Hash type bits from the end of a signature.
 const (
+	SigHashDefault      SigHashType = 0x0
 	SigHashOld          SigHashType = 0x0
 	SigHashAll          SigHashType = 0x1
 	SigHashNone         SigHashType = 0x2
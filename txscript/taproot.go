@@ -0,0 +1,780 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// BaseLeafVersion is the leaf version of tapscripts as defined by
+	// BIP0342.
+	BaseLeafVersion = 0xc0
+
+	// TaprootAnnexTag is the first byte of the optional annex which may be
+	// present as the last element of the witness of a taproot spend.
+	TaprootAnnexTag = 0x50
+
+	// TaprootLeafMask is the mask applied to the first byte of a control
+	// block to obtain the leaf version.  The remaining bit is the parity
+	// of the y coordinate of the output key.
+	TaprootLeafMask = 0xfe
+
+	// ControlBlockBaseSize is the size of a control block which contains
+	// no merkle path: the leaf version and parity byte followed by the
+	// 32-byte internal key.
+	ControlBlockBaseSize = 33
+
+	// ControlBlockNodeSize is the size of each node in the merkle path of
+	// a control block.
+	ControlBlockNodeSize = 32
+
+	// ControlBlockMaxNodeCount is the maximum number of nodes in the
+	// merkle path of a control block.
+	ControlBlockMaxNodeCount = 128
+
+	// ControlBlockMaxSize is the maximum size of a control block.
+	ControlBlockMaxSize = ControlBlockBaseSize +
+		ControlBlockNodeSize*ControlBlockMaxNodeCount
+
+	// sigOpsDelta is the amount the signature operation budget of a
+	// tapscript is decreased by for each executed signature operation with
+	// a non-empty signature.
+	sigOpsDelta = 50
+
+	// blankCodeSepValue is the code separator position committed to by
+	// tapscript signatures when no OP_CODESEPARATOR has been executed.
+	blankCodeSepValue = 0xffffffff
+)
+
+var (
+	// These are the tags of the tagged hashes defined by BIP0341.
+	tapLeafTag    = []byte("TapLeaf")
+	tapBranchTag  = []byte("TapBranch")
+	tapTweakTag   = []byte("TapTweak")
+	tapSighashTag = []byte("TapSighash")
+)
+
+// PrevOutputFetcher is an interface used to supply the outputs spent by the
+// inputs of a transaction.  Taproot signatures commit to the amounts and
+// public key scripts of all spent outputs, so they must be available to
+// calculate and verify them.
+type PrevOutputFetcher interface {
+	// FetchPrevOutput returns the output referenced by the passed outpoint
+	// or nil when it is not known.
+	FetchPrevOutput(wire.OutPoint) *wire.TxOut
+}
+
+// MultiPrevOutFetcher is a PrevOutputFetcher backed by a map of outpoints to
+// the outputs they reference.
+type MultiPrevOutFetcher map[wire.OutPoint]*wire.TxOut
+
+// FetchPrevOutput returns the output referenced by the passed outpoint or nil
+// when it is not in the map.
+//
+// This is part of the PrevOutputFetcher interface.
+func (m MultiPrevOutFetcher) FetchPrevOutput(op wire.OutPoint) *wire.TxOut {
+	return m[op]
+}
+
+// Ensure MultiPrevOutFetcher implements the PrevOutputFetcher interface.
+var _ PrevOutputFetcher = MultiPrevOutFetcher(nil)
+
+// TaprootSigHashes houses the partial set of sighashes introduced within
+// BIP0341.  Unlike the BIP0143 fragments, they are single SHA256 hashes and
+// additionally commit to the amounts and public key scripts of all outputs
+// spent by the transaction.
+type TaprootSigHashes struct {
+	HashPrevOuts      chainhash.Hash
+	HashAmounts       chainhash.Hash
+	HashScriptPubKeys chainhash.Hash
+	HashSequence      chainhash.Hash
+	HashOutputs       chainhash.Hash
+}
+
+// NewTaprootSigHashes computes and returns the BIP0341 partial sighashes of
+// the passed transaction.  An error is returned when the fetcher does not
+// provide the output spent by every input.
+func NewTaprootSigHashes(tx *wire.MsgTx, prevOuts PrevOutputFetcher) (*TaprootSigHashes, error) {
+	if prevOuts == nil {
+		return nil, scriptError(ErrTaprootPrevOutsUnavailable,
+			"no previous outputs provided")
+	}
+
+	var prevOutsBuf, amountsBuf, scriptsBuf, sequenceBuf bytes.Buffer
+	for i, txIn := range tx.TxIn {
+		prevOut := prevOuts.FetchPrevOutput(txIn.PreviousOutPoint)
+		if prevOut == nil {
+			str := fmt.Sprintf("previous output %v spent by input "+
+				"%d is unavailable", txIn.PreviousOutPoint, i)
+			return nil, scriptError(ErrTaprootPrevOutsUnavailable,
+				str)
+		}
+
+		var buf [8]byte
+		prevOutsBuf.Write(txIn.PreviousOutPoint.Hash[:])
+		binary.LittleEndian.PutUint32(buf[:4], txIn.PreviousOutPoint.Index)
+		prevOutsBuf.Write(buf[:4])
+
+		binary.LittleEndian.PutUint64(buf[:], uint64(prevOut.Value))
+		amountsBuf.Write(buf[:])
+
+		wire.WriteVarBytes(&scriptsBuf, 0, prevOut.PkScript)
+
+		binary.LittleEndian.PutUint32(buf[:4], txIn.Sequence)
+		sequenceBuf.Write(buf[:4])
+	}
+
+	var outputsBuf bytes.Buffer
+	for _, txOut := range tx.TxOut {
+		wire.WriteTxOut(&outputsBuf, 0, 0, txOut)
+	}
+
+	return &TaprootSigHashes{
+		HashPrevOuts:      chainhash.HashH(prevOutsBuf.Bytes()),
+		HashAmounts:       chainhash.HashH(amountsBuf.Bytes()),
+		HashScriptPubKeys: chainhash.HashH(scriptsBuf.Bytes()),
+		HashSequence:      chainhash.HashH(sequenceBuf.Bytes()),
+		HashOutputs:       chainhash.HashH(outputsBuf.Bytes()),
+	}, nil
+}
+
+// isValidTaprootSigHashType returns whether or not the passed hash type may be
+// used by taproot signatures.
+func isValidTaprootSigHashType(hashType SigHashType) bool {
+	switch hashType {
+	case SigHashDefault, SigHashAll, SigHashNone, SigHashSingle,
+		SigHashAll | SigHashAnyOneCanPay,
+		SigHashNone | SigHashAnyOneCanPay,
+		SigHashSingle | SigHashAnyOneCanPay:
+
+		return true
+	}
+	return false
+}
+
+// tapscriptSigHashExt houses the values tapscript signatures commit to in
+// addition to those committed to by key path signatures.
+type tapscriptSigHashExt struct {
+	tapLeafHash chainhash.Hash
+	codeSepPos  uint32
+}
+
+// calcTaprootSignatureHash computes the BIP0341 signature hash of the
+// specified input of the passed transaction which spends the passed previous
+// output.  The annex is the optional annex of the input and ext must be
+// provided for tapscript signatures.
+func calcTaprootSignatureHash(sigHashes *TaprootSigHashes, hashType SigHashType,
+	tx *wire.MsgTx, idx int, prevOut *wire.TxOut, annex []byte,
+	ext *tapscriptSigHashExt) ([]byte, error) {
+
+	if !isValidTaprootSigHashType(hashType) {
+		str := fmt.Sprintf("invalid taproot hash type 0x%x", hashType)
+		return nil, scriptError(ErrInvalidSigHashType, str)
+	}
+	if idx < 0 || idx >= len(tx.TxIn) {
+		str := fmt.Sprintf("transaction input index %d is negative or "+
+			">= %d", idx, len(tx.TxIn))
+		return nil, scriptError(ErrInvalidIndex, str)
+	}
+
+	// The output type is the low two bits of the hash type, where the
+	// default hash type is treated as SigHashAll.
+	outputType := hashType & SigHashSingle
+	if outputType == SigHashDefault {
+		outputType = SigHashAll
+	}
+	anyoneCanPay := hashType&SigHashAnyOneCanPay != 0
+	if outputType == SigHashSingle && idx >= len(tx.TxOut) {
+		str := fmt.Sprintf("SigHashSingle used for input %d with only "+
+			"%d outputs", idx, len(tx.TxOut))
+		return nil, scriptError(ErrInvalidSigHashType, str)
+	}
+
+	// The message starts with the epoch, which is always zero.
+	var sigMsg bytes.Buffer
+	var buf [8]byte
+	sigMsg.WriteByte(0x00)
+
+	// Next write the hash type, version and lock time of the transaction.
+	sigMsg.WriteByte(byte(hashType))
+	binary.LittleEndian.PutUint32(buf[:4], uint32(tx.Version))
+	sigMsg.Write(buf[:4])
+	binary.LittleEndian.PutUint32(buf[:4], tx.LockTime)
+	sigMsg.Write(buf[:4])
+
+	// Commit to all of the inputs unless only the input being signed is
+	// committed to, and to all of the outputs when requested.
+	if !anyoneCanPay {
+		sigMsg.Write(sigHashes.HashPrevOuts[:])
+		sigMsg.Write(sigHashes.HashAmounts[:])
+		sigMsg.Write(sigHashes.HashScriptPubKeys[:])
+		sigMsg.Write(sigHashes.HashSequence[:])
+	}
+	if outputType == SigHashAll {
+		sigMsg.Write(sigHashes.HashOutputs[:])
+	}
+
+	// The spend type indicates whether this is a tapscript signature and
+	// whether the input has an annex.
+	var spendType byte
+	if ext != nil {
+		spendType |= 0x02
+	}
+	if annex != nil {
+		spendType |= 0x01
+	}
+	sigMsg.WriteByte(spendType)
+
+	// Write the details of the input being signed or just its index when
+	// all inputs are committed to above.
+	if anyoneCanPay {
+		txIn := tx.TxIn[idx]
+		sigMsg.Write(txIn.PreviousOutPoint.Hash[:])
+		binary.LittleEndian.PutUint32(buf[:4], txIn.PreviousOutPoint.Index)
+		sigMsg.Write(buf[:4])
+		binary.LittleEndian.PutUint64(buf[:], uint64(prevOut.Value))
+		sigMsg.Write(buf[:])
+		wire.WriteVarBytes(&sigMsg, 0, prevOut.PkScript)
+		binary.LittleEndian.PutUint32(buf[:4], txIn.Sequence)
+		sigMsg.Write(buf[:4])
+	} else {
+		binary.LittleEndian.PutUint32(buf[:4], uint32(idx))
+		sigMsg.Write(buf[:4])
+	}
+
+	if annex != nil {
+		var b bytes.Buffer
+		wire.WriteVarBytes(&b, 0, annex)
+		sigMsg.Write(chainhash.HashB(b.Bytes()))
+	}
+
+	if outputType == SigHashSingle {
+		var b bytes.Buffer
+		wire.WriteTxOut(&b, 0, 0, tx.TxOut[idx])
+		sigMsg.Write(chainhash.HashB(b.Bytes()))
+	}
+
+	// Finally, tapscript signatures commit to the leaf being executed, the
+	// key version, which is always zero, and the position of the last
+	// executed OP_CODESEPARATOR.
+	if ext != nil {
+		sigMsg.Write(ext.tapLeafHash[:])
+		sigMsg.WriteByte(0x00)
+		binary.LittleEndian.PutUint32(buf[:4], ext.codeSepPos)
+		sigMsg.Write(buf[:4])
+	}
+
+	return chainhash.TaggedHash(tapSighashTag, sigMsg.Bytes())[:], nil
+}
+
+// taprootPrevOut returns the output spent by the specified input of the
+// passed transaction or an error when it is not available.
+func taprootPrevOut(tx *wire.MsgTx, idx int, prevOuts PrevOutputFetcher) (*wire.TxOut, error) {
+	if idx < 0 || idx >= len(tx.TxIn) {
+		str := fmt.Sprintf("transaction input index %d is negative or "+
+			">= %d", idx, len(tx.TxIn))
+		return nil, scriptError(ErrInvalidIndex, str)
+	}
+	prevOut := prevOuts.FetchPrevOutput(tx.TxIn[idx].PreviousOutPoint)
+	if prevOut == nil {
+		str := fmt.Sprintf("previous output spent by input %d is "+
+			"unavailable", idx)
+		return nil, scriptError(ErrTaprootPrevOutsUnavailable, str)
+	}
+	return prevOut, nil
+}
+
+// CalcTaprootSignatureHash computes the BIP0341 signature hash of a taproot
+// key path spend of the specified input of the passed transaction.  The
+// fetcher must provide the outputs spent by all inputs of the transaction.
+func CalcTaprootSignatureHash(sigHashes *TxSigHashes, hType SigHashType,
+	tx *wire.MsgTx, idx int, prevOuts PrevOutputFetcher) ([]byte, error) {
+
+	if sigHashes == nil || sigHashes.Taproot == nil {
+		return nil, scriptError(ErrTaprootPrevOutsUnavailable,
+			"taproot sighashes are unavailable")
+	}
+	prevOut, err := taprootPrevOut(tx, idx, prevOuts)
+	if err != nil {
+		return nil, err
+	}
+	return calcTaprootSignatureHash(sigHashes.Taproot, hType, tx, idx,
+		prevOut, nil, nil)
+}
+
+// CalcTapscriptSignatureHash computes the BIP0342 signature hash of a tapscript
+// signature for the specified input of the passed transaction which is
+// executing the passed leaf script with the base leaf version and without an
+// OP_CODESEPARATOR.  The fetcher must provide the outputs spent by all inputs
+// of the transaction.
+func CalcTapscriptSignatureHash(sigHashes *TxSigHashes, hType SigHashType,
+	tx *wire.MsgTx, idx int, prevOuts PrevOutputFetcher,
+	leafScript []byte) ([]byte, error) {
+
+	if sigHashes == nil || sigHashes.Taproot == nil {
+		return nil, scriptError(ErrTaprootPrevOutsUnavailable,
+			"taproot sighashes are unavailable")
+	}
+	prevOut, err := taprootPrevOut(tx, idx, prevOuts)
+	if err != nil {
+		return nil, err
+	}
+	ext := &tapscriptSigHashExt{
+		tapLeafHash: TapLeafHash(BaseLeafVersion, leafScript),
+		codeSepPos:  blankCodeSepValue,
+	}
+	return calcTaprootSignatureHash(sigHashes.Taproot, hType, tx, idx,
+		prevOut, nil, ext)
+}
+
+// TapLeafHash returns the BIP0341 leaf hash of the passed script with the
+// passed leaf version.
+func TapLeafHash(leafVersion byte, script []byte) chainhash.Hash {
+	var b bytes.Buffer
+	b.WriteByte(leafVersion)
+	wire.WriteVarBytes(&b, 0, script)
+	return *chainhash.TaggedHash(tapLeafTag, b.Bytes())
+}
+
+// TapBranchHash returns the BIP0341 branch hash of the two passed child
+// hashes.  The children are sorted before hashing, so the order in which they
+// are passed does not matter.
+func TapBranchHash(a, b []byte) chainhash.Hash {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	return *chainhash.TaggedHash(tapBranchTag, a, b)
+}
+
+// taprootTweak returns the BIP0341 tweak of the passed internal key with the
+// passed script tree root, which may be empty when the output key does not
+// commit to any scripts.  An error is returned when the tweak is not less
+// than the group order.
+func taprootTweak(internalKey *btcec.PublicKey, scriptRoot []byte) (*big.Int, error) {
+	tweakHash := chainhash.TaggedHash(tapTweakTag,
+		btcec.SerializeSchnorrPubKey(internalKey), scriptRoot)
+	tweak := new(big.Int).SetBytes(tweakHash[:])
+	if tweak.Cmp(btcec.S256().N) >= 0 {
+		return nil, fmt.Errorf("taproot tweak is >= curve.N")
+	}
+	return tweak, nil
+}
+
+// ComputeTaprootOutputKey returns the BIP0341 output key which commits to the
+// passed internal key and script tree root.  The script root may be empty when
+// the output does not commit to any scripts.  Only the x coordinate of the
+// internal key is used.
+func ComputeTaprootOutputKey(internalKey *btcec.PublicKey, scriptRoot []byte) (*btcec.PublicKey, error) {
+	// Use the point with an even y coordinate for the internal key.
+	curve := btcec.S256()
+	px, py := internalKey.X, internalKey.Y
+	if py.Bit(0) == 1 {
+		py = new(big.Int).Sub(curve.P, py)
+	}
+
+	tweak, err := taprootTweak(internalKey, scriptRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	// Q = P + t*G
+	tx, ty := curve.ScalarBaseMult(tweak.Bytes())
+	qx, qy := curve.Add(px, py, tx, ty)
+	if qx.Sign() == 0 && qy.Sign() == 0 {
+		return nil, fmt.Errorf("taproot output key is the point at " +
+			"infinity")
+	}
+	return &btcec.PublicKey{Curve: curve, X: qx, Y: qy}, nil
+}
+
+// TweakTaprootPrivKey returns the private key for the output key produced by
+// ComputeTaprootOutputKey for the public key of the passed private key and the
+// passed script tree root.  It is used to sign taproot key path spends.
+func TweakTaprootPrivKey(privKey *btcec.PrivateKey, scriptRoot []byte) (*btcec.PrivateKey, error) {
+	curve := btcec.S256()
+	pubKey := privKey.PubKey()
+	tweak, err := taprootTweak(pubKey, scriptRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	d := new(big.Int).Set(privKey.D)
+	if pubKey.Y.Bit(0) == 1 {
+		d.Sub(curve.N, d)
+	}
+	d.Add(d, tweak)
+	d.Mod(d, curve.N)
+	if d.Sign() == 0 {
+		return nil, fmt.Errorf("tweaked taproot private key is zero")
+	}
+
+	tweaked, _ := btcec.PrivKeyFromBytes(curve, d.Bytes())
+	return tweaked, nil
+}
+
+// PayToTaprootScript creates a new script to pay to a version 1 witness
+// program committing to the passed taproot output key.
+func PayToTaprootScript(outputKey *btcec.PublicKey) ([]byte, error) {
+	return NewScriptBuilder().AddOp(OP_1).
+		AddData(btcec.SerializeSchnorrPubKey(outputKey)).Script()
+}
+
+// verifyTaprootCommitment returns an error when the passed control block and
+// leaf hash do not commit to the passed witness program as described by
+// BIP0341.  The control block must already be known to be of a valid length.
+func verifyTaprootCommitment(controlBlock, witnessProgram []byte, leafHash chainhash.Hash) error {
+	internalKey, err := btcec.ParseSchnorrPubKey(
+		controlBlock[1:ControlBlockBaseSize])
+	if err != nil {
+		str := fmt.Sprintf("invalid taproot internal key: %v", err)
+		return scriptError(ErrTaprootMerkleProofInvalid, str)
+	}
+
+	// Compute the root of the script tree from the leaf hash and the
+	// merkle path in the control block.
+	root := leafHash
+	path := controlBlock[ControlBlockBaseSize:]
+	for len(path) > 0 {
+		root = TapBranchHash(root[:], path[:ControlBlockNodeSize])
+		path = path[ControlBlockNodeSize:]
+	}
+
+	outputKey, err := ComputeTaprootOutputKey(internalKey, root[:])
+	if err != nil {
+		str := fmt.Sprintf("unable to compute taproot output key: %v",
+			err)
+		return scriptError(ErrTaprootMerkleProofInvalid, str)
+	}
+	if !bytes.Equal(btcec.SerializeSchnorrPubKey(outputKey), witnessProgram) {
+		return scriptError(ErrTaprootMerkleProofInvalid,
+			"taproot output key does not commit to the script")
+	}
+	if byte(outputKey.Y.Bit(0)) != controlBlock[0]&^TaprootLeafMask {
+		return scriptError(ErrTaprootMerkleProofInvalid,
+			"taproot output key parity mismatch")
+	}
+	return nil
+}
+
+// isOpSuccess returns whether or not the passed opcode is one of the
+// OP_SUCCESSx opcodes defined by BIP0342 which cause a tapscript to succeed
+// unconditionally.
+func isOpSuccess(opcode byte) bool {
+	return opcode == 80 || opcode == 98 ||
+		(opcode >= 126 && opcode <= 129) ||
+		(opcode >= 131 && opcode <= 134) ||
+		(opcode >= 137 && opcode <= 138) ||
+		(opcode >= 141 && opcode <= 142) ||
+		(opcode >= 149 && opcode <= 153) ||
+		(opcode >= 187 && opcode <= 254)
+}
+
+// scriptHasOpSuccess returns whether or not the passed tapscript contains an
+// OP_SUCCESSx opcode.  The script is scanned before it is fully parsed since
+// the presence of such an opcode makes the script succeed even when the
+// remainder of it would fail to parse.  An error is only returned when the
+// script fails to parse before an OP_SUCCESSx opcode is found.
+func scriptHasOpSuccess(script []byte) (bool, error) {
+	for i := 0; i < len(script); {
+		op := &opcodeArray[script[i]]
+		if isOpSuccess(op.value) {
+			return true, nil
+		}
+
+		// Skip over the data pushed by the opcode, if any.  The length
+		// checks mirror those performed by parseScriptTemplate.
+		switch {
+		case op.length > 1:
+			if len(script[i:]) < op.length {
+				str := fmt.Sprintf("opcode %s requires %d bytes, "+
+					"but script only has %d remaining",
+					op.name, op.length, len(script[i:]))
+				return false, scriptError(ErrMalformedPush, str)
+			}
+			i += op.length
+
+		case op.length < 0:
+			off := i + 1
+			if len(script[off:]) < -op.length {
+				str := fmt.Sprintf("opcode %s requires %d bytes, "+
+					"but script only has %d remaining",
+					op.name, -op.length, len(script[off:]))
+				return false, scriptError(ErrMalformedPush, str)
+			}
+
+			var l uint
+			switch op.length {
+			case -1:
+				l = uint(script[off])
+			case -2:
+				l = uint(binary.LittleEndian.Uint16(script[off:]))
+			case -4:
+				l = uint(binary.LittleEndian.Uint32(script[off:]))
+			}
+			off += -op.length
+			if l > uint(len(script[off:])) {
+				str := fmt.Sprintf("opcode %s pushes %d bytes, "+
+					"but script only has %d remaining",
+					op.name, l, len(script[off:]))
+				return false, scriptError(ErrMalformedPush, str)
+			}
+			i = off + int(l)
+
+		default:
+			i++
+		}
+	}
+	return false, nil
+}
+
+// taprootExecutionCtx houses the state of the engine which is specific to
+// spending a taproot output.
+type taprootExecutionCtx struct {
+	// keySpend is set when the output is spent via the key path.
+	keySpend bool
+
+	// mustSucceed is set when the output is spent via the script path
+	// and the script is unconditionally successful due to an unknown leaf
+	// version or an OP_SUCCESSx opcode.
+	mustSucceed bool
+
+	// annex is the optional annex of the input.
+	annex []byte
+
+	// tapLeafHash is the leaf hash of the tapscript being executed.
+	tapLeafHash chainhash.Hash
+
+	// codeSepPos is the opcode position of the last executed
+	// OP_CODESEPARATOR.
+	codeSepPos uint32
+
+	// sigOpsBudget is the remaining signature operation budget of the
+	// tapscript being executed.
+	sigOpsBudget int
+}
+
+// isTapscript returns whether or not the engine is executing a tapscript.
+func (vm *Engine) isTapscript() bool {
+	return vm.taprootCtx != nil && !vm.taprootCtx.keySpend &&
+		!vm.taprootCtx.mustSucceed
+}
+
+// verifyTaprootSig verifies the passed BIP0340 signature, which may have a
+// trailing hash type byte, of the input being validated by the passed x-only
+// public key.  The signature hash includes the tapscript extension when a
+// tapscript is being executed.  An error is returned when the signature is
+// invalid.
+func (vm *Engine) verifyTaprootSig(rawSig, rawPubKey []byte) error {
+	hashType := SigHashDefault
+	switch len(rawSig) {
+	case btcec.SchnorrSigBytesLen:
+	case btcec.SchnorrSigBytesLen + 1:
+		// An explicit default hash type is not allowed since it would
+		// allow the same signature to be encoded two ways.
+		hashType = SigHashType(rawSig[btcec.SchnorrSigBytesLen])
+		if hashType == SigHashDefault {
+			return scriptError(ErrInvalidSigHashType,
+				"explicit default taproot hash type")
+		}
+		rawSig = rawSig[:btcec.SchnorrSigBytesLen]
+	default:
+		str := fmt.Sprintf("invalid taproot signature length %d",
+			len(rawSig))
+		return scriptError(ErrTaprootSigInvalid, str)
+	}
+
+	if vm.hashCache == nil || vm.hashCache.Taproot == nil {
+		return scriptError(ErrTaprootPrevOutsUnavailable,
+			"taproot sighashes are unavailable")
+	}
+
+	var ext *tapscriptSigHashExt
+	if vm.isTapscript() {
+		ext = &tapscriptSigHashExt{
+			tapLeafHash: vm.taprootCtx.tapLeafHash,
+			codeSepPos:  vm.taprootCtx.codeSepPos,
+		}
+	}
+	prevOut := &wire.TxOut{
+		Value:    vm.inputAmount,
+		PkScript: append([]byte{OP_1, OP_DATA_32}, vm.witnessProgram...),
+	}
+	hash, err := calcTaprootSignatureHash(vm.hashCache.Taproot, hashType,
+		&vm.tx, vm.txIdx, prevOut, vm.taprootCtx.annex, ext)
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := btcec.ParseSchnorrPubKey(rawPubKey)
+	if err != nil {
+		str := fmt.Sprintf("invalid taproot public key: %v", err)
+		return scriptError(ErrTaprootSigInvalid, str)
+	}
+	sig, err := btcec.ParseSchnorrSignature(rawSig)
+	if err != nil {
+		str := fmt.Sprintf("invalid taproot signature: %v", err)
+		return scriptError(ErrTaprootSigInvalid, str)
+	}
+	vm.stats.SigOps++
+	if !sig.Verify(hash, pubKey) {
+		return scriptError(ErrTaprootSigInvalid,
+			"taproot signature verification failed")
+	}
+	return nil
+}
+
+// tapscriptCheckSig performs the signature check shared by OP_CHECKSIG,
+// OP_CHECKSIGVERIFY and OP_CHECKSIGADD within a tapscript as defined by
+// BIP0342.  It returns true when the signature is not empty and valid, and
+// false when it is empty.  An error is returned when the public key is empty,
+// the signature operation budget is exceeded, or a non-empty signature is
+// invalid.
+func (vm *Engine) tapscriptCheckSig(sig, pubKey []byte) (bool, error) {
+	if len(pubKey) == 0 {
+		return false, scriptError(ErrTaprootPubKeyIsEmpty,
+			"tapscript signature operation with empty public key")
+	}
+
+	if len(sig) != 0 {
+		vm.taprootCtx.sigOpsBudget -= sigOpsDelta
+		if vm.taprootCtx.sigOpsBudget < 0 {
+			return false, scriptError(ErrTaprootMaxSigOps,
+				"tapscript signature operation budget exceeded")
+		}
+	}
+
+	// Public keys of other sizes are reserved for future soft forks and
+	// any signature is treated as valid for them.
+	if len(pubKey) == btcec.SchnorrPubKeyBytesLen {
+		if len(sig) != 0 {
+			if err := vm.verifyTaprootSig(sig, pubKey); err != nil {
+				return false, err
+			}
+		}
+	} else if vm.hasFlag(ScriptVerifyDiscourageUpgradeablePubkeyType) {
+		str := fmt.Sprintf("tapscript public key of unknown type with "+
+			"length %d", len(pubKey))
+		return false, scriptError(ErrDiscourageUpgradeablePubKeyType, str)
+	}
+
+	return len(sig) != 0, nil
+}
+
+// verifyTaprootWitness validates the spend of a taproot output by the passed
+// witness as described by BIP0341.  Key path spends are verified immediately
+// while script path spends result in the tapscript being added as the next
+// script to execute unless it is unconditionally successful.
+func (vm *Engine) verifyTaprootWitness(witness [][]byte) error {
+	if len(witness) == 0 {
+		return scriptError(ErrWitnessProgramEmpty, "witness "+
+			"program empty passed empty witness")
+	}
+
+	ctx := &taprootExecutionCtx{codeSepPos: blankCodeSepValue}
+	vm.taprootCtx = ctx
+
+	// Remove the annex, if any.
+	if len(witness) >= 2 {
+		last := witness[len(witness)-1]
+		if len(last) > 0 && last[0] == TaprootAnnexTag {
+			ctx.annex = last
+			witness = witness[:len(witness)-1]
+		}
+	}
+
+	// A single remaining element is a signature for the output key.
+	if len(witness) == 1 {
+		ctx.keySpend = true
+		return vm.verifyTaprootSig(witness[0], vm.witnessProgram)
+	}
+
+	// Otherwise, this is a script path spend where the last two elements
+	// are the leaf script and the control block proving its inclusion in
+	// the script tree committed to by the output key.
+	controlBlock := witness[len(witness)-1]
+	leafScript := witness[len(witness)-2]
+	stack := witness[:len(witness)-2]
+	if len(controlBlock) < ControlBlockBaseSize ||
+		len(controlBlock) > ControlBlockMaxSize ||
+		(len(controlBlock)-ControlBlockBaseSize)%ControlBlockNodeSize != 0 {
+
+		str := fmt.Sprintf("invalid control block length %d",
+			len(controlBlock))
+		return scriptError(ErrControlBlockInvalidLength, str)
+	}
+	leafVersion := controlBlock[0] & TaprootLeafMask
+	leafHash := TapLeafHash(leafVersion, leafScript)
+	err := verifyTaprootCommitment(controlBlock, vm.witnessProgram, leafHash)
+	if err != nil {
+		return err
+	}
+
+	// Leaf versions other than tapscript are reserved for future soft
+	// forks and are unconditionally successful.
+	if leafVersion != BaseLeafVersion {
+		if vm.hasFlag(ScriptVerifyDiscourageUpgradeableTaprootVersion) {
+			str := fmt.Sprintf("taproot leaf version 0x%x is "+
+				"reserved for soft-fork upgrades", leafVersion)
+			return scriptError(ErrDiscourageUpgradeableTaprootVersion,
+				str)
+		}
+		ctx.mustSucceed = true
+		return nil
+	}
+
+	// Tapscripts which contain an OP_SUCCESSx opcode are likewise
+	// unconditionally successful.
+	hasOpSuccess, err := scriptHasOpSuccess(leafScript)
+	if err != nil {
+		return err
+	}
+	if hasOpSuccess {
+		if vm.hasFlag(ScriptVerifyDiscourageOpSuccess) {
+			return scriptError(ErrDiscourageOpSuccess,
+				"tapscript contains OP_SUCCESSx opcode")
+		}
+		ctx.mustSucceed = true
+		return nil
+	}
+
+	pops, err := parseScript(leafScript)
+	if err != nil {
+		return err
+	}
+
+	// The initial stack is subject to the same limits as the stack during
+	// execution.
+	if len(stack) > MaxStackSize {
+		str := fmt.Sprintf("tapscript initial stack size %d > max "+
+			"allowed %d", len(stack), MaxStackSize)
+		return scriptError(ErrStackOverflow, str)
+	}
+	for _, witElement := range stack {
+		if len(witElement) > MaxScriptElementSize {
+			str := fmt.Sprintf("element size %d exceeds max "+
+				"allowed size %d", len(witElement),
+				MaxScriptElementSize)
+			return scriptError(ErrElementTooBig, str)
+		}
+	}
+
+	// The signature operation budget is based on the size of the full
+	// witness, including the annex and control block.
+	fullWitness := wire.TxWitness(vm.tx.TxIn[vm.txIdx].Witness)
+	ctx.sigOpsBudget = sigOpsDelta + fullWitness.SerializeSize()
+	ctx.tapLeafHash = leafHash
+
+	vm.scripts = append(vm.scripts, pops)
+	vm.SetStack(stack)
+	return nil
+}
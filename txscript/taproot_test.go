@@ -0,0 +1,572 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// taprootTestFlags are the flags used when executing the taproot tests.
+const taprootTestFlags = ScriptBip16 | ScriptVerifyWitness | ScriptVerifyTaproot
+
+// TestTaprootOutputKey ensures the taproot output key and leaf hash are
+// computed as in the BIP0341 test vectors and that control blocks are checked
+// against the resulting witness programs.
+func TestTaprootOutputKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		internalKey string
+		leafScript  string
+		leafHash    string
+		outputKey   string
+		parity      byte
+	}{
+		{
+			internalKey: "d6889cb081036e0faefa3a35157ad71086b123b2b144b649798b494c300a961d",
+			outputKey:   "53a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343",
+			parity:      1,
+		},
+		{
+			internalKey: "187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27",
+			leafScript:  "20d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8ac",
+			leafHash:    "5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c72b9a2481752dd88b21",
+			outputKey:   "147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3",
+			parity:      1,
+		},
+	}
+
+	for i, test := range tests {
+		internalKeyBytes := hexToBytes(test.internalKey)
+		internalKey, err := btcec.ParseSchnorrPubKey(internalKeyBytes)
+		if err != nil {
+			t.Errorf("#%d: unable to parse internal key: %v", i, err)
+			continue
+		}
+
+		var scriptRoot []byte
+		var leafHash chainhash.Hash
+		if test.leafScript != "" {
+			leafHash = TapLeafHash(BaseLeafVersion,
+				hexToBytes(test.leafScript))
+			if hex.EncodeToString(leafHash[:]) != test.leafHash {
+				t.Errorf("#%d: unexpected leaf hash - got %x, "+
+					"want %s", i, leafHash[:], test.leafHash)
+				continue
+			}
+			scriptRoot = leafHash[:]
+		}
+
+		outputKey, err := ComputeTaprootOutputKey(internalKey, scriptRoot)
+		if err != nil {
+			t.Errorf("#%d: unable to compute output key: %v", i, err)
+			continue
+		}
+		program := btcec.SerializeSchnorrPubKey(outputKey)
+		if hex.EncodeToString(program) != test.outputKey {
+			t.Errorf("#%d: unexpected output key - got %x, want %s",
+				i, program, test.outputKey)
+			continue
+		}
+		if byte(outputKey.Y.Bit(0)) != test.parity {
+			t.Errorf("#%d: unexpected output key parity", i)
+			continue
+		}
+		if test.leafScript == "" {
+			continue
+		}
+
+		// The control block for the single leaf must be accepted and
+		// must be rejected when the parity bit is wrong.
+		controlBlock := append([]byte{BaseLeafVersion | test.parity},
+			internalKeyBytes...)
+		err = verifyTaprootCommitment(controlBlock, program, leafHash)
+		if err != nil {
+			t.Errorf("#%d: unexpected commitment error: %v", i, err)
+			continue
+		}
+		controlBlock[0] ^= 0x01
+		err = verifyTaprootCommitment(controlBlock, program, leafHash)
+		if err == nil {
+			t.Errorf("#%d: commitment with wrong parity accepted", i)
+		}
+	}
+}
+
+// newTaprootTestTx returns a transaction which spends a single output with the
+// passed public key script and amount along with a fetcher for that output.
+func newTaprootTestTx(pkScript []byte, amount int64) (*wire.MsgTx, PrevOutputFetcher) {
+	var prevHash chainhash.Hash
+	for i := range prevHash {
+		prevHash[i] = 0x01
+	}
+	prevOutPoint := wire.OutPoint{Hash: prevHash, Index: 0}
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&prevOutPoint, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(50000, hexToBytes("5120147c9c57132f6e7ecdd"+
+		"ba9800bb0c4449251c92a1e60371ee77557b6620f3ea3")))
+
+	fetcher := MultiPrevOutFetcher{
+		prevOutPoint: wire.NewTxOut(amount, pkScript),
+	}
+	return tx, fetcher
+}
+
+// TestTaprootSigHash ensures the BIP0341 signature hashes are calculated
+// correctly for the supported hash types, with and without an annex, and for
+// tapscript signatures.
+func TestTaprootSigHash(t *testing.T) {
+	t.Parallel()
+
+	pkScript := hexToBytes("512053a1f6e454df1aa2776a2814a721372d625805" +
+		"0de330b3c6d10ee8f4e0dda343")
+	tx, fetcher := newTaprootTestTx(pkScript, 100000)
+	sigHashes := NewTxSigHashesWithPrevOuts(tx, fetcher)
+	prevOut := fetcher.FetchPrevOutput(tx.TxIn[0].PreviousOutPoint)
+	leafHash := hexToBytes("5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c" +
+		"72b9a2481752dd88b21")
+
+	tests := []struct {
+		name     string
+		hashType SigHashType
+		annex    []byte
+		leafHash []byte
+		want     string
+	}{
+		{
+			name:     "default",
+			hashType: SigHashDefault,
+			want:     "af8bb63fd7064d1ee05dd89faada12aec038822c8c30eead6be94dc809e7a50e",
+		},
+		{
+			name:     "single anyone can pay",
+			hashType: SigHashSingle | SigHashAnyOneCanPay,
+			want:     "34bfe423ac3678383cca15453f40416cfb19cef8bb1cfdf4eb5b3831540e2634",
+		},
+		{
+			name:     "all with annex",
+			hashType: SigHashAll,
+			annex:    []byte{TaprootAnnexTag, 0x01, 0x02},
+			want:     "e175515564a4d8bec4c9af608b89173916965853f630f8e7ce86c7db6ee17d4d",
+		},
+		{
+			name:     "tapscript none",
+			hashType: SigHashNone,
+			leafHash: leafHash,
+			want:     "142387d6ae924cf459b18dfffd886784ca6b48e312f5452921f117a1846e9183",
+		},
+	}
+
+	for _, test := range tests {
+		var ext *tapscriptSigHashExt
+		if test.leafHash != nil {
+			ext = &tapscriptSigHashExt{codeSepPos: blankCodeSepValue}
+			copy(ext.tapLeafHash[:], test.leafHash)
+		}
+		hash, err := calcTaprootSignatureHash(sigHashes.Taproot,
+			test.hashType, tx, 0, prevOut, test.annex, ext)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if hex.EncodeToString(hash) != test.want {
+			t.Errorf("%s: unexpected sighash - got %x, want %s",
+				test.name, hash, test.want)
+		}
+	}
+
+	// Invalid hash types must be rejected.
+	for _, hashType := range []SigHashType{0x04, 0x80, 0x84} {
+		_, err := calcTaprootSignatureHash(sigHashes.Taproot, hashType,
+			tx, 0, prevOut, nil, nil)
+		if err == nil {
+			t.Errorf("hash type 0x%x accepted", hashType)
+		}
+	}
+}
+
+// TestTaprootKeySpend ensures taproot key path spends are validated according
+// to BIP0341.
+func TestTaprootKeySpend(t *testing.T) {
+	t.Parallel()
+
+	privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), hexToBytes("b7e15"+
+		"1628aed2a6abf7158809cf4f3c762e7160f38b4da56a784d9045190cfef"))
+	outputKey, err := ComputeTaprootOutputKey(privKey.PubKey(), nil)
+	if err != nil {
+		t.Fatalf("unable to compute output key: %v", err)
+	}
+	tweakedKey, err := TweakTaprootPrivKey(privKey, nil)
+	if err != nil {
+		t.Fatalf("unable to tweak private key: %v", err)
+	}
+	pkScript, err := PayToTaprootScript(outputKey)
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+	const amount = 100000
+	tx, fetcher := newTaprootTestTx(pkScript, amount)
+
+	sign := func(hashType SigHashType) []byte {
+		sigHashes := NewTxSigHashesWithPrevOuts(tx, fetcher)
+		hash, err := CalcTaprootSignatureHash(sigHashes, hashType, tx,
+			0, fetcher)
+		if err != nil {
+			t.Fatalf("unable to calculate sighash: %v", err)
+		}
+		sig, err := btcec.SignSchnorr(tweakedKey, hash, nil)
+		if err != nil {
+			t.Fatalf("unable to sign: %v", err)
+		}
+		if hashType == SigHashDefault {
+			return sig.Serialize()
+		}
+		return append(sig.Serialize(), byte(hashType))
+	}
+
+	badSig := sign(SigHashDefault)
+	badSig[0] ^= 0x01
+	explicitDefault := append(sign(SigHashDefault), byte(SigHashDefault))
+
+	tests := []struct {
+		name      string
+		witness   wire.TxWitness
+		flags     ScriptFlags
+		noPrevOut bool
+		err       error
+	}{
+		{
+			name:    "default hash type",
+			witness: wire.TxWitness{sign(SigHashDefault)},
+			flags:   taprootTestFlags,
+		},
+		{
+			name:    "all anyone can pay",
+			witness: wire.TxWitness{sign(SigHashAll | SigHashAnyOneCanPay)},
+			flags:   taprootTestFlags,
+		},
+		{
+			name:    "invalid signature",
+			witness: wire.TxWitness{badSig},
+			flags:   taprootTestFlags,
+			err:     scriptError(ErrTaprootSigInvalid, ""),
+		},
+		{
+			name:    "explicit default hash type",
+			witness: wire.TxWitness{explicitDefault},
+			flags:   taprootTestFlags,
+			err:     scriptError(ErrInvalidSigHashType, ""),
+		},
+		{
+			name:    "invalid signature length",
+			witness: wire.TxWitness{badSig[:63]},
+			flags:   taprootTestFlags,
+			err:     scriptError(ErrTaprootSigInvalid, ""),
+		},
+		{
+			name:      "previous outputs unavailable",
+			witness:   wire.TxWitness{sign(SigHashDefault)},
+			flags:     taprootTestFlags,
+			noPrevOut: true,
+			err:       scriptError(ErrTaprootPrevOutsUnavailable, ""),
+		},
+		{
+			name:    "taproot inactive",
+			witness: wire.TxWitness{badSig},
+			flags:   ScriptBip16 | ScriptVerifyWitness,
+		},
+		{
+			name:    "taproot inactive discourage upgradeable",
+			witness: wire.TxWitness{badSig},
+			flags: ScriptBip16 | ScriptVerifyWitness |
+				ScriptVerifyDiscourageUpgradeableWitnessProgram,
+			err: scriptError(ErrDiscourageUpgradableWitnessProgram, ""),
+		},
+	}
+
+	for _, test := range tests {
+		tx.TxIn[0].Witness = test.witness
+		sigHashes := NewTxSigHashesWithPrevOuts(tx, fetcher)
+		if test.noPrevOut {
+			sigHashes = NewTxSigHashes(tx)
+		}
+		vm, err := NewEngine(pkScript, tx, 0, test.flags, nil,
+			sigHashes, amount)
+		if err != nil {
+			t.Errorf("%s: unable to create engine: %v", test.name, err)
+			continue
+		}
+		err = vm.Execute()
+		if test.err == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if err := tstCheckScriptError(err, test.err); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+}
+
+// TestTapscriptSpend ensures taproot script path spends are validated according
+// to BIP0341 and BIP0342.
+func TestTapscriptSpend(t *testing.T) {
+	t.Parallel()
+
+	internalPriv, _ := btcec.PrivKeyFromBytes(btcec.S256(), hexToBytes("c9"+
+		"0fdaa22168c234c4c6628b80dc1cd129024e088a67cc74020bbea63b14e5c9"))
+	priv1, _ := btcec.PrivKeyFromBytes(btcec.S256(), hexToBytes("0b432b"+
+		"2677937381aef05bb02a66ecd012773062cf3fa2549e44f58ed2401710"))
+	priv2, _ := btcec.PrivKeyFromBytes(btcec.S256(), hexToBytes("000000"+
+		"0000000000000000000000000000000000000000000000000000000003"))
+	pub1 := btcec.SerializeSchnorrPubKey(priv1.PubKey())
+	pub2 := btcec.SerializeSchnorrPubKey(priv2.PubKey())
+
+	// The leaves of the script tree, which is built by hashing the first
+	// two leaves together, then hashing the result with each subsequent
+	// leaf in turn.
+	type leaf struct {
+		version byte
+		script  []byte
+	}
+	leaves := []leaf{
+		// 0: single key.
+		{BaseLeafVersion, append(append([]byte{OP_DATA_32}, pub1...),
+			OP_CHECKSIG)},
+		// 1: 2-of-2 using OP_CHECKSIGADD.
+		{BaseLeafVersion, append(append(append(append([]byte{OP_DATA_32},
+			pub1...), OP_CHECKSIG, OP_DATA_32), pub2...),
+			OP_CHECKSIGADD, OP_2, OP_NUMEQUAL)},
+		// 2: OP_SUCCESS80 followed by an invalid push.
+		{BaseLeafVersion, []byte{OP_RESERVED, OP_PUSHDATA1}},
+		// 3: unknown leaf version.
+		{0xc2, []byte{OP_RETURN}},
+		// 4: 1-of-1 multisig.
+		{BaseLeafVersion, append(append([]byte{OP_1, OP_DATA_32},
+			pub1...), OP_1, OP_CHECKMULTISIG)},
+		// 5: empty public key.
+		{BaseLeafVersion, []byte{OP_0, OP_CHECKSIG}},
+		// 6: unknown public key type.
+		{BaseLeafVersion, []byte{OP_1, OP_CHECKSIG}},
+		// 7: non-minimal OP_IF argument.
+		{BaseLeafVersion, []byte{OP_IF, OP_1, OP_ENDIF, OP_1}},
+		// 8: more than 201 operations.
+		{BaseLeafVersion, append(bytes.Repeat([]byte{OP_NOP}, 250),
+			OP_1)},
+	}
+	leafHashes := make([]chainhash.Hash, len(leaves))
+	for i, l := range leaves {
+		leafHashes[i] = TapLeafHash(l.version, l.script)
+	}
+	branches := make([]chainhash.Hash, len(leaves))
+	branches[1] = TapBranchHash(leafHashes[0][:], leafHashes[1][:])
+	for i := 2; i < len(leaves); i++ {
+		branches[i] = TapBranchHash(branches[i-1][:], leafHashes[i][:])
+	}
+	scriptRoot := branches[len(leaves)-1]
+
+	outputKey, err := ComputeTaprootOutputKey(internalPriv.PubKey(),
+		scriptRoot[:])
+	if err != nil {
+		t.Fatalf("unable to compute output key: %v", err)
+	}
+	pkScript, err := PayToTaprootScript(outputKey)
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+	const amount = 100000
+	tx, fetcher := newTaprootTestTx(pkScript, amount)
+
+	// controlBlock returns the control block proving the inclusion of the
+	// leaf with the passed index.
+	controlBlock := func(idx int) []byte {
+		cb := []byte{leaves[idx].version | byte(outputKey.Y.Bit(0))}
+		cb = append(cb, btcec.SerializeSchnorrPubKey(
+			internalPriv.PubKey())...)
+		switch idx {
+		case 0:
+			cb = append(cb, leafHashes[1][:]...)
+		case 1:
+			cb = append(cb, leafHashes[0][:]...)
+		default:
+			cb = append(cb, branches[idx-1][:]...)
+		}
+		for i := idx + 1; i < len(leaves); i++ {
+			if i > 1 {
+				cb = append(cb, leafHashes[i][:]...)
+			}
+		}
+		return cb
+	}
+
+	// sign returns a signature of the passed leaf by the passed key.
+	sign := func(idx int, privKey *btcec.PrivateKey) []byte {
+		sigHashes := NewTxSigHashesWithPrevOuts(tx, fetcher)
+		hash, err := CalcTapscriptSignatureHash(sigHashes,
+			SigHashDefault, tx, 0, fetcher, leaves[idx].script)
+		if err != nil {
+			t.Fatalf("unable to calculate sighash: %v", err)
+		}
+		sig, err := btcec.SignSchnorr(privKey, hash, nil)
+		if err != nil {
+			t.Fatalf("unable to sign: %v", err)
+		}
+		return sig.Serialize()
+	}
+
+	badCB := controlBlock(0)
+	badCB[len(badCB)-1] ^= 0x01
+	badSig := sign(0, priv1)
+	badSig[0] ^= 0x01
+
+	tests := []struct {
+		name  string
+		leaf  int
+		stack [][]byte
+		cb    []byte
+		flags ScriptFlags
+		err   error
+	}{
+		{
+			name:  "single key",
+			leaf:  0,
+			stack: [][]byte{sign(0, priv1)},
+		},
+		{
+			name:  "single key invalid signature",
+			leaf:  0,
+			stack: [][]byte{badSig},
+			err:   scriptError(ErrTaprootSigInvalid, ""),
+		},
+		{
+			name:  "single key empty signature",
+			leaf:  0,
+			stack: [][]byte{nil},
+			err:   scriptError(ErrEvalFalse, ""),
+		},
+		{
+			name:  "invalid merkle proof",
+			leaf:  0,
+			stack: [][]byte{sign(0, priv1)},
+			cb:    badCB,
+			err:   scriptError(ErrTaprootMerkleProofInvalid, ""),
+		},
+		{
+			name:  "invalid control block length",
+			leaf:  0,
+			stack: [][]byte{sign(0, priv1)},
+			cb:    badCB[:len(badCB)-1],
+			err:   scriptError(ErrControlBlockInvalidLength, ""),
+		},
+		{
+			name:  "2-of-2 checksigadd",
+			leaf:  1,
+			stack: [][]byte{sign(1, priv2), sign(1, priv1)},
+		},
+		{
+			name:  "2-of-2 checksigadd missing signature",
+			leaf:  1,
+			stack: [][]byte{sign(1, priv2), nil},
+			err:   scriptError(ErrEvalFalse, ""),
+		},
+		{
+			name: "op success",
+			leaf: 2,
+		},
+		{
+			name:  "op success discouraged",
+			leaf:  2,
+			flags: ScriptVerifyDiscourageOpSuccess,
+			err:   scriptError(ErrDiscourageOpSuccess, ""),
+		},
+		{
+			name: "unknown leaf version",
+			leaf: 3,
+		},
+		{
+			name:  "unknown leaf version discouraged",
+			leaf:  3,
+			flags: ScriptVerifyDiscourageUpgradeableTaprootVersion,
+			err:   scriptError(ErrDiscourageUpgradeableTaprootVersion, ""),
+		},
+		{
+			name:  "checkmultisig",
+			leaf:  4,
+			stack: [][]byte{nil, sign(4, priv1)},
+			err:   scriptError(ErrTapscriptCheckMultiSig, ""),
+		},
+		{
+			name:  "empty public key",
+			leaf:  5,
+			stack: [][]byte{sign(5, priv1)},
+			err:   scriptError(ErrTaprootPubKeyIsEmpty, ""),
+		},
+		{
+			name:  "unknown public key type",
+			leaf:  6,
+			stack: [][]byte{{0x01}},
+		},
+		{
+			name:  "unknown public key type discouraged",
+			leaf:  6,
+			stack: [][]byte{{0x01}},
+			flags: ScriptVerifyDiscourageUpgradeablePubkeyType,
+			err:   scriptError(ErrDiscourageUpgradeablePubKeyType, ""),
+		},
+		{
+			name:  "non-minimal if",
+			leaf:  7,
+			stack: [][]byte{{0x02}},
+			err:   scriptError(ErrMinimalIf, ""),
+		},
+		{
+			name:  "unclean stack",
+			leaf:  0,
+			stack: [][]byte{{0x01}, sign(0, priv1)},
+			err:   scriptError(ErrEvalFalse, ""),
+		},
+		{
+			name: "no operation limit",
+			leaf: 8,
+		},
+	}
+
+	for _, test := range tests {
+		cb := test.cb
+		if cb == nil {
+			cb = controlBlock(test.leaf)
+		}
+		witness := make(wire.TxWitness, 0, len(test.stack)+2)
+		witness = append(witness, test.stack...)
+		witness = append(witness, leaves[test.leaf].script, cb)
+		tx.TxIn[0].Witness = witness
+
+		sigHashes := NewTxSigHashesWithPrevOuts(tx, fetcher)
+		vm, err := NewEngine(pkScript, tx, 0,
+			taprootTestFlags|test.flags, nil, sigHashes, amount)
+		if err != nil {
+			t.Errorf("%s: unable to create engine: %v", test.name, err)
+			continue
+		}
+		err = vm.Execute()
+		if test.err == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if err := tstCheckScriptError(err, test.err); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+// ScriptTimeLock describes an OP_CHECKLOCKTIMEVERIFY or OP_CHECKSEQUENCEVERIFY
+// found in a script.
+type ScriptTimeLock struct {
+	// Opcode is either OP_CHECKLOCKTIMEVERIFY or OP_CHECKSEQUENCEVERIFY.
+	Opcode byte
+
+	// Value is the lock time or sequence the opcode requires.  It is only
+	// valid when HasValue is true, which is the case when the opcode
+	// directly follows a push of a valid 5-byte script number, as in all
+	// of the commonly used timelocked scripts.
+	Value    int64
+	HasValue bool
+}
+
+// OpcodeName returns the name of the opcode of the time lock.
+func (l *ScriptTimeLock) OpcodeName() string {
+	return opcodeArray[l.Opcode].name
+}
+
+// ExtractTimeLocks returns the time locks imposed by the passed script in the
+// order they appear in it.  Since the value checked by each opcode is taken
+// from the stack, it can only be determined statically when it is pushed
+// right before the opcode.  The locks are reported regardless of whether the
+// branch of the script they are in is executed.
+//
+// An error is returned when the script does not parse.
+func ExtractTimeLocks(script []byte) ([]ScriptTimeLock, error) {
+	pops, err := parseScript(script)
+	if err != nil {
+		return nil, err
+	}
+
+	var locks []ScriptTimeLock
+	for i, pop := range pops {
+		if pop.opcode.value != OP_CHECKLOCKTIMEVERIFY &&
+			pop.opcode.value != OP_CHECKSEQUENCEVERIFY {
+
+			continue
+		}
+
+		lock := ScriptTimeLock{Opcode: pop.opcode.value}
+		if i > 0 {
+			prev := pops[i-1]
+			switch {
+			case isSmallInt(prev.opcode):
+				lock.Value = int64(asSmallInt(prev.opcode))
+				lock.HasValue = true
+
+			case prev.opcode.value <= OP_PUSHDATA4:
+				// The opcodes use 5-byte script numbers so the
+				// full range of lock times and sequences can be
+				// expressed.
+				n, err := makeScriptNum(prev.data, true, 5)
+				if err == nil {
+					lock.Value = int64(n)
+					lock.HasValue = true
+				}
+			}
+		}
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestExtractTimeLocks ensures the time locks imposed by scripts are extracted
+// along with the values pushed for them.
+func TestExtractTimeLocks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		script string
+		locks  []ScriptTimeLock
+		err    bool
+	}{
+		{
+			name:   "no time locks",
+			script: "DUP HASH160 DATA_1 0x01 EQUALVERIFY CHECKSIG",
+		},
+		{
+			name: "absolute height lock",
+			script: "500000 CHECKLOCKTIMEVERIFY DROP DATA_1 0x02 " +
+				"CHECKSIG",
+			locks: []ScriptTimeLock{{
+				Opcode:   OP_CHECKLOCKTIMEVERIFY,
+				Value:    500000,
+				HasValue: true,
+			}},
+		},
+		{
+			name: "relative lock in a branch",
+			script: "IF DATA_1 0x02 ELSE 16 CHECKSEQUENCEVERIFY " +
+				"DROP DATA_1 0x03 ENDIF CHECKSIG",
+			locks: []ScriptTimeLock{{
+				Opcode:   OP_CHECKSEQUENCEVERIFY,
+				Value:    16,
+				HasValue: true,
+			}},
+		},
+		{
+			name:   "value not pushed directly",
+			script: "DUP CHECKLOCKTIMEVERIFY 1500000000 NOP3",
+			locks: []ScriptTimeLock{
+				{Opcode: OP_CHECKLOCKTIMEVERIFY},
+				{
+					Opcode:   OP_CHECKSEQUENCEVERIFY,
+					Value:    1500000000,
+					HasValue: true,
+				},
+			},
+		},
+		{
+			name:   "value too large",
+			script: "0x06 0x010000000000 CHECKLOCKTIMEVERIFY",
+			locks:  []ScriptTimeLock{{Opcode: OP_CHECKLOCKTIMEVERIFY}},
+		},
+		{
+			name:   "malformed script",
+			script: "CHECKSEQUENCEVERIFY DATA_5 0x01",
+			err:    true,
+		},
+	}
+
+	for _, test := range tests {
+		locks, err := ExtractTimeLocks(mustParseShortForm(test.script))
+		if (err != nil) != test.err {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(locks, test.locks) {
+			t.Errorf("%s: got locks %+v, want %+v", test.name, locks,
+				test.locks)
+		}
+	}
+
+	lock := ScriptTimeLock{Opcode: OP_CHECKSEQUENCEVERIFY}
+	if name := lock.OpcodeName(); name != "OP_CHECKSEQUENCEVERIFY" {
+		t.Errorf("unexpected opcode name %q", name)
+	}
+}
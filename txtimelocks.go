@@ -0,0 +1,133 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// relativeLock returns the type and value of the relative lock-time the passed
+// sequence imposes on an input of a transaction with the passed version as
+// defined by BIP0068.  The type is none when the lock is disabled, otherwise
+// the value is the number of blocks or seconds the referenced output must have
+// been confirmed for.
+func relativeLock(txVersion int32, sequence uint32) (string, int64) {
+	if txVersion < 2 || sequence&wire.SequenceLockTimeDisabled != 0 {
+		return "none", 0
+	}
+
+	value := int64(sequence & wire.SequenceLockTimeMask)
+	if sequence&wire.SequenceLockTimeIsSeconds != 0 {
+		return "seconds", value << wire.SequenceLockTimeGranularity
+	}
+	return "blocks", value
+}
+
+// absoluteLock returns the type of the passed lock-time, which is either a
+// block height or a unix timestamp depending on its value.
+func absoluteLock(lockTime int64) string {
+	if lockTime < txscript.LockTimeThreshold {
+		return "height"
+	}
+	return "time"
+}
+
+// timeLockScript is a script which is executed when an input is spent along
+// with the name of its role.
+type timeLockScript struct {
+	name   string
+	script []byte
+}
+
+// txInScripts returns the scripts which are executed when the passed input
+// spends an output with the passed public key script.  Besides the public key
+// script itself, these are the redeem script of pay-to-script-hash outputs and
+// the witness script of pay-to-witness-script-hash outputs, including those
+// nested in pay-to-script-hash.
+func txInScripts(txIn *wire.TxIn, pkScript []byte) []timeLockScript {
+	scripts := []timeLockScript{{name: "pkscript", script: pkScript}}
+
+	program := pkScript
+	if txscript.IsPayToScriptHash(pkScript) {
+		pushes, err := txscript.PushedData(txIn.SignatureScript)
+		if err != nil || len(pushes) == 0 ||
+			!txscript.IsPushOnlyScript(txIn.SignatureScript) {
+
+			return scripts
+		}
+		program = pushes[len(pushes)-1]
+		scripts = append(scripts, timeLockScript{name: "redeemscript",
+			script: program})
+	}
+
+	if txscript.IsPayToWitnessScriptHash(program) && len(txIn.Witness) > 0 {
+		scripts = append(scripts, timeLockScript{name: "witnessscript",
+			script: txIn.Witness[len(txIn.Witness)-1]})
+	}
+	return scripts
+}
+
+// scriptTimeLockResults returns the time locks imposed by the scripts executed
+// when the passed input of the passed transaction spends an output with the
+// passed public key script, along with whether the transaction satisfies them.
+// A lock whose value can't be determined is never considered satisfied.
+//
+// The transaction satisfies an OP_CHECKLOCKTIMEVERIFY when its lock-time is of
+// the same type and at least the required value and the input does not disable
+// the lock-time.  It satisfies an OP_CHECKSEQUENCEVERIFY when the relative lock
+// of the input is of the same type and at least the required value, or the
+// required sequence has the disable flag set.
+func scriptTimeLockResults(tx *wire.MsgTx, txIn *wire.TxIn, pkScript []byte) []btcjson.ScriptTimeLockResult {
+	results := make([]btcjson.ScriptTimeLockResult, 0)
+	for _, s := range txInScripts(txIn, pkScript) {
+		// Scripts which don't parse can't impose time locks which can
+		// be satisfied, so they are skipped.
+		locks, err := txscript.ExtractTimeLocks(s.script)
+		if err != nil {
+			continue
+		}
+
+		for i := range locks {
+			lock := &locks[i]
+			result := btcjson.ScriptTimeLockResult{
+				Script: s.name,
+				Opcode: lock.OpcodeName(),
+				Type:   "unknown",
+			}
+			if !lock.HasValue || lock.Value < 0 {
+				results = append(results, result)
+				continue
+			}
+			value := lock.Value
+			result.Value = &value
+
+			switch lock.Opcode {
+			case txscript.OP_CHECKLOCKTIMEVERIFY:
+				result.Type = absoluteLock(value)
+				lockTime := int64(tx.LockTime)
+				result.Satisfied = absoluteLock(lockTime) == result.Type &&
+					lockTime >= value &&
+					txIn.Sequence != wire.MaxTxInSequenceNum
+
+			case txscript.OP_CHECKSEQUENCEVERIFY:
+				if uint32(value)&wire.SequenceLockTimeDisabled != 0 {
+					result.Type = "none"
+					result.Satisfied = true
+					break
+				}
+				var required int64
+				result.Type, required = relativeLock(2, uint32(value))
+				lockType, lock := relativeLock(tx.Version,
+					txIn.Sequence)
+				result.Satisfied = lockType == result.Type &&
+					lock >= required
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
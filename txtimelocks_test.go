@@ -0,0 +1,106 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestRelativeLock ensures the relative locks imposed by input sequence numbers
+// are interpreted as defined by BIP0068.
+func TestRelativeLock(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		version  int32
+		sequence uint32
+		lockType string
+		lock     int64
+	}{
+		{1, 10, "none", 0},
+		{2, wire.MaxTxInSequenceNum, "none", 0},
+		{2, 10, "blocks", 10},
+		{2, wire.SequenceLockTimeIsSeconds | 3, "seconds", 3 * 512},
+		{2, 0x00010000 | 5, "blocks", 5},
+	}
+
+	for i, test := range tests {
+		lockType, lock := relativeLock(test.version, test.sequence)
+		if lockType != test.lockType || lock != test.lock {
+			t.Errorf("#%d: got %s %d, want %s %d", i, lockType, lock,
+				test.lockType, test.lock)
+		}
+	}
+}
+
+// TestScriptTimeLockResults ensures the time locks in the scripts executed by
+// an input are found and checked against the spending transaction.
+func TestScriptTimeLockResults(t *testing.T) {
+	t.Parallel()
+
+	cltvScript, err := txscript.NewScriptBuilder().AddInt64(1000).
+		AddOp(txscript.OP_CHECKLOCKTIMEVERIFY).AddOp(txscript.OP_DROP).
+		AddOp(txscript.OP_TRUE).Script()
+	if err != nil {
+		t.Fatalf("unable to build script: %v", err)
+	}
+	csvScript, err := txscript.NewScriptBuilder().AddInt64(144).
+		AddOp(txscript.OP_CHECKSEQUENCEVERIFY).AddOp(txscript.OP_DROP).
+		AddOp(txscript.OP_TRUE).Script()
+	if err != nil {
+		t.Fatalf("unable to build script: %v", err)
+	}
+
+	// Pay-to-script-hash spending the absolute lock in its redeem script.
+	p2shScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_HASH160).AddData(btcutil.Hash160(cltvScript)).
+		AddOp(txscript.OP_EQUAL).Script()
+	if err != nil {
+		t.Fatalf("unable to build script: %v", err)
+	}
+	sigScript, err := txscript.NewScriptBuilder().AddData(cltvScript).Script()
+	if err != nil {
+		t.Fatalf("unable to build script: %v", err)
+	}
+	tx := wire.NewMsgTx(2)
+	tx.LockTime = 1000
+	txIn := wire.NewTxIn(&wire.OutPoint{}, sigScript, nil)
+	txIn.Sequence = 0
+	tx.AddTxIn(txIn)
+	results := scriptTimeLockResults(tx, txIn, p2shScript)
+	if len(results) != 1 || results[0].Script != "redeemscript" ||
+		results[0].Type != "height" || results[0].Value == nil ||
+		*results[0].Value != 1000 || !results[0].Satisfied {
+
+		t.Fatalf("unexpected redeem script results %+v", results)
+	}
+
+	// The lock-time does not apply when the input disables it.
+	txIn.Sequence = wire.MaxTxInSequenceNum
+	results = scriptTimeLockResults(tx, txIn, p2shScript)
+	if len(results) != 1 || results[0].Satisfied {
+		t.Fatalf("unexpected disabled lock-time results %+v", results)
+	}
+
+	// Bare relative lock which the sequence only satisfies once it is at
+	// least the required number of blocks.
+	txIn = wire.NewTxIn(&wire.OutPoint{}, nil, nil)
+	txIn.Sequence = 143
+	results = scriptTimeLockResults(tx, txIn, csvScript)
+	if len(results) != 1 || results[0].Script != "pkscript" ||
+		results[0].Type != "blocks" || results[0].Satisfied {
+
+		t.Fatalf("unexpected relative lock results %+v", results)
+	}
+	txIn.Sequence = 144
+	results = scriptTimeLockResults(tx, txIn, csvScript)
+	if len(results) != 1 || !results[0].Satisfied {
+		t.Fatalf("unexpected relative lock results %+v", results)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+const (
+	// uploadTargetTimeframe is the duration of the cycles the upload
+	// target applies to.
+	uploadTargetTimeframe = 24 * time.Hour
+
+	// historicalBlockAge is the age of the blocks which are considered
+	// historical and therefore are no longer served to peers which are not
+	// whitelisted once the upload target is about to be reached.
+	historicalBlockAge = 7 * 24 * time.Hour
+)
+
+// uploadTarget keeps track of the number of bytes sent to peers during the
+// current cycle in order to keep the outbound traffic under a target.  Since
+// new blocks must be relayed for the network to function, only the serving
+// of historical blocks is refused, and that happens early enough to leave
+// room in the target for the new blocks expected during the rest of the
+// cycle.
+type uploadTarget struct {
+	mtx        sync.Mutex
+	target     uint64
+	timeframe  time.Duration
+	cycleStart time.Time
+	sent       uint64
+}
+
+// newUploadTarget returns a new upload target which allows the passed number
+// of bytes to be sent during each cycle of the passed duration.  A target of
+// zero means the outbound traffic is not limited.
+func newUploadTarget(target uint64, timeframe time.Duration) *uploadTarget {
+	return &uploadTarget{
+		target:    target,
+		timeframe: timeframe,
+	}
+}
+
+// updateCycle starts a new cycle when the current one has ended.  It must be
+// called with the mutex held.
+func (u *uploadTarget) updateCycle(now time.Time) {
+	if u.cycleStart.IsZero() || now.Sub(u.cycleStart) >= u.timeframe {
+		u.cycleStart = now
+		u.sent = 0
+	}
+}
+
+// AddBytesSent records the passed number of bytes as sent at the passed time.
+//
+// This function is safe for concurrent access.
+func (u *uploadTarget) AddBytesSent(n uint64, now time.Time) {
+	u.mtx.Lock()
+	u.updateCycle(now)
+	u.sent += n
+	u.mtx.Unlock()
+}
+
+// timeLeft returns the time left in the current cycle.  It must be called
+// with the mutex held.
+func (u *uploadTarget) timeLeft(now time.Time) time.Duration {
+	u.updateCycle(now)
+	return u.timeframe - now.Sub(u.cycleStart)
+}
+
+// reached returns whether the target has been reached.  When historical is
+// set, room is left for a maximum size block every ten minutes for the rest
+// of the cycle.  It must be called with the mutex held.
+func (u *uploadTarget) reached(historical bool, now time.Time) bool {
+	if u.target == 0 {
+		return false
+	}
+	timeLeft := u.timeLeft(now)
+	if !historical {
+		return u.sent >= u.target
+	}
+	buffer := uint64(timeLeft/(10*time.Minute)) *
+		blockchain.MaxBlockWeight
+	return buffer >= u.target || u.sent >= u.target-buffer
+}
+
+// Reached returns whether the target has been reached at the passed time.
+// When historical is set, it returns whether historical blocks should no
+// longer be served, which is the case once serving them could prevent new
+// blocks from being relayed for the rest of the cycle.
+//
+// This function is safe for concurrent access.
+func (u *uploadTarget) Reached(historical bool, now time.Time) bool {
+	u.mtx.Lock()
+	reached := u.reached(historical, now)
+	u.mtx.Unlock()
+	return reached
+}
+
+// Result returns the state of the upload target at the passed time as
+// reported by the getnettotals RPC.
+//
+// This function is safe for concurrent access.
+func (u *uploadTarget) Result(now time.Time) btcjson.UploadTargetResult {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	result := btcjson.UploadTargetResult{
+		Timeframe:             int64(u.timeframe / time.Second),
+		Target:                u.target,
+		ServeHistoricalBlocks: true,
+	}
+	if u.target == 0 {
+		return result
+	}
+	result.TargetReached = u.reached(false, now)
+	result.ServeHistoricalBlocks = !u.reached(true, now)
+	if u.sent < u.target {
+		result.BytesLeftInCycle = u.target - u.sent
+	}
+	result.TimeLeftInCycle = int64(u.timeLeft(now) / time.Second)
+	return result
+}
+
+// isHistoricalBlock returns whether a block with the passed timestamp is old
+// enough to be considered historical compared to the passed best block
+// timestamp.
+func isHistoricalBlock(blockTime, bestTime time.Time) bool {
+	return bestTime.Sub(blockTime) > historicalBlockAge
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+)
+
+// TestUploadTarget ensures the upload target stops serving historical blocks
+// early enough to leave room for new blocks and starts a new cycle once the
+// timeframe has passed.
+func TestUploadTarget(t *testing.T) {
+	t.Parallel()
+
+	// Without a target nothing is ever refused.
+	start := time.Unix(1500000000, 0)
+	u := newUploadTarget(0, time.Hour)
+	u.AddBytesSent(1<<40, start)
+	if u.Reached(true, start) || u.Reached(false, start) {
+		t.Fatal("target reached without a target")
+	}
+	if result := u.Result(start); !result.ServeHistoricalBlocks ||
+		result.TargetReached {
+
+		t.Fatalf("unexpected result without a target %+v", result)
+	}
+
+	// A one hour cycle leaves room for six maximum size blocks, so
+	// historical blocks are refused once fewer bytes than that are left.
+	const buffer = 6 * blockchain.MaxBlockWeight
+	target := uint64(buffer + 1000)
+	u = newUploadTarget(target, time.Hour)
+	u.AddBytesSent(999, start)
+	if u.Reached(true, start) {
+		t.Fatal("historical target reached early")
+	}
+	u.AddBytesSent(1, start)
+	if !u.Reached(true, start) || u.Reached(false, start) {
+		t.Fatal("historical target not reached")
+	}
+
+	// Less room is needed as the cycle progresses.
+	if u.Reached(true, start.Add(50*time.Minute)) {
+		t.Fatal("historical target reached near the end of the cycle")
+	}
+	u.AddBytesSent(buffer, start.Add(50*time.Minute))
+	result := u.Result(start.Add(50 * time.Minute))
+	if !result.TargetReached || result.ServeHistoricalBlocks ||
+		result.BytesLeftInCycle != 0 || result.TimeLeftInCycle != 600 {
+
+		t.Fatalf("unexpected result once reached %+v", result)
+	}
+
+	// The target applies again from scratch in the next cycle.
+	next := start.Add(time.Hour)
+	if u.Reached(true, next) || u.Reached(false, next) {
+		t.Fatal("target reached in new cycle")
+	}
+	result = u.Result(next)
+	if result.BytesLeftInCycle != target || result.TimeLeftInCycle != 3600 {
+		t.Fatalf("unexpected result in new cycle %+v", result)
+	}
+}
+
+// TestIsHistoricalBlock ensures blocks are considered historical once they are
+// more than a week older than the best block.
+func TestIsHistoricalBlock(t *testing.T) {
+	t.Parallel()
+
+	best := time.Unix(1500000000, 0)
+	if isHistoricalBlock(best.Add(-historicalBlockAge), best) {
+		t.Fatal("week old block is historical")
+	}
+	if !isHistoricalBlock(best.Add(-historicalBlockAge-time.Second), best) {
+		t.Fatal("block older than a week is not historical")
+	}
+}
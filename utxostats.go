@@ -0,0 +1,136 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// utxoValueBucketBounds are the lower bounds, in satoshis, of the buckets the
+// unspent outputs are grouped into by value.  Each bucket extends up to the
+// lower bound of the next one, so the first buckets track dust.
+var utxoValueBucketBounds = []int64{0, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10}
+
+// utxoStats accumulates statistics about unspent transaction outputs grouped by
+// script type, value, and the height of the block which created them.
+type utxoStats struct {
+	heightBucketSize int32
+	transactions     int64
+	outputs          int64
+	amount           int64
+	scriptTypes      map[string]*btcjson.UtxoStatsBucket
+	values           []btcjson.UtxoStatsBucket
+	heights          map[int32]*btcjson.UtxoStatsBucket
+}
+
+// newUtxoStats returns a new utxo statistics accumulator which groups outputs
+// by creation height into buckets of the passed number of blocks.
+func newUtxoStats(heightBucketSize int32) *utxoStats {
+	values := make([]btcjson.UtxoStatsBucket, len(utxoValueBucketBounds))
+	for i, min := range utxoValueBucketBounds {
+		if i == len(utxoValueBucketBounds)-1 {
+			values[i].Bucket = fmt.Sprintf("%d+", min)
+			continue
+		}
+		values[i].Bucket = fmt.Sprintf("%d-%d", min,
+			utxoValueBucketBounds[i+1]-1)
+	}
+
+	return &utxoStats{
+		heightBucketSize: heightBucketSize,
+		scriptTypes:      make(map[string]*btcjson.UtxoStatsBucket),
+		values:           values,
+		heights:          make(map[int32]*btcjson.UtxoStatsBucket),
+	}
+}
+
+// addToBucket adds an output of the passed amount to the passed bucket.
+func addToBucket(bucket *btcjson.UtxoStatsBucket, amount int64) {
+	bucket.Outputs++
+	bucket.Amount += amount
+}
+
+// AddEntry adds the unspent outputs of the passed utxo entry to the statistics.
+func (s *utxoStats) AddEntry(entry *blockchain.UtxoEntry) {
+	s.transactions++
+
+	heightStart := entry.BlockHeight() - entry.BlockHeight()%s.heightBucketSize
+	heightBucket, ok := s.heights[heightStart]
+	if !ok {
+		heightBucket = &btcjson.UtxoStatsBucket{
+			Bucket: fmt.Sprintf("%d-%d", heightStart,
+				heightStart+s.heightBucketSize-1),
+		}
+		s.heights[heightStart] = heightBucket
+	}
+
+	for _, outputIndex := range entry.UnspentOutputs() {
+		amount := entry.AmountByIndex(outputIndex)
+		s.outputs++
+		s.amount += amount
+
+		class := txscript.GetScriptClass(entry.PkScriptByIndex(outputIndex))
+		scriptType := class.String()
+		scriptBucket, ok := s.scriptTypes[scriptType]
+		if !ok {
+			scriptBucket = &btcjson.UtxoStatsBucket{Bucket: scriptType}
+			s.scriptTypes[scriptType] = scriptBucket
+		}
+		addToBucket(scriptBucket, amount)
+
+		// The value buckets are few, so a linear search from the top
+		// is fine.
+		i := len(utxoValueBucketBounds) - 1
+		for i > 0 && amount < utxoValueBucketBounds[i] {
+			i--
+		}
+		addToBucket(&s.values[i], amount)
+
+		addToBucket(heightBucket, amount)
+	}
+}
+
+// Result returns the accumulated statistics as a getutxostats result.  The
+// script types are ordered by name and the value and height buckets in
+// ascending order.  Empty value buckets are included so every page has the
+// same value buckets.
+func (s *utxoStats) Result() *btcjson.GetUtxoStatsResult {
+	scriptTypes := make([]btcjson.UtxoStatsBucket, 0, len(s.scriptTypes))
+	for _, bucket := range s.scriptTypes {
+		scriptTypes = append(scriptTypes, *bucket)
+	}
+	sort.Slice(scriptTypes, func(i, j int) bool {
+		return scriptTypes[i].Bucket < scriptTypes[j].Bucket
+	})
+
+	heightStarts := make([]int32, 0, len(s.heights))
+	for heightStart := range s.heights {
+		heightStarts = append(heightStarts, heightStart)
+	}
+	sort.Slice(heightStarts, func(i, j int) bool {
+		return heightStarts[i] < heightStarts[j]
+	})
+	heights := make([]btcjson.UtxoStatsBucket, 0, len(heightStarts))
+	for _, heightStart := range heightStarts {
+		heights = append(heights, *s.heights[heightStart])
+	}
+
+	values := make([]btcjson.UtxoStatsBucket, len(s.values))
+	copy(values, s.values)
+
+	return &btcjson.GetUtxoStatsResult{
+		Transactions: s.transactions,
+		Outputs:      s.outputs,
+		Amount:       s.amount,
+		ScriptTypes:  scriptTypes,
+		Values:       values,
+		Heights:      heights,
+	}
+}
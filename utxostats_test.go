@@ -0,0 +1,87 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestUtxoStats ensures unspent outputs are grouped by script type, value, and
+// creation height.
+func TestUtxoStats(t *testing.T) {
+	t.Parallel()
+
+	p2pkh := append(append([]byte{0x76, 0xa9, 0x14}, make([]byte, 20)...),
+		0x88, 0xac)
+	p2sh := append(append([]byte{0xa9, 0x14}, make([]byte, 20)...), 0x87)
+
+	// newEntry returns a utxo entry created at the passed height for a
+	// transaction with outputs of the passed values and scripts.
+	newEntry := func(height int32, values []int64, scripts [][]byte) *blockchain.UtxoEntry {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(&wire.TxIn{})
+		for i := range values {
+			tx.AddTxOut(wire.NewTxOut(values[i], scripts[i]))
+		}
+		view := blockchain.NewUtxoViewpoint()
+		utx := btcutil.NewTx(tx)
+		view.AddTxOuts(utx, height)
+		return view.LookupEntry(utx.Hash())
+	}
+
+	stats := newUtxoStats(100)
+	stats.AddEntry(newEntry(5, []int64{500, 2e8},
+		[][]byte{p2pkh, p2sh}))
+	stats.AddEntry(newEntry(150, []int64{1e3}, [][]byte{p2pkh}))
+	result := stats.Result()
+
+	if result.Transactions != 2 || result.Outputs != 3 ||
+		result.Amount != 2e8+1500 {
+
+		t.Fatalf("unexpected totals %+v", result)
+	}
+	wantScriptTypes := []btcjson.UtxoStatsBucket{
+		{Bucket: "pubkeyhash", Outputs: 2, Amount: 1500},
+		{Bucket: "scripthash", Outputs: 1, Amount: 2e8},
+	}
+	if !reflect.DeepEqual(result.ScriptTypes, wantScriptTypes) {
+		t.Errorf("unexpected script types %+v", result.ScriptTypes)
+	}
+	wantHeights := []btcjson.UtxoStatsBucket{
+		{Bucket: "0-99", Outputs: 2, Amount: 2e8 + 500},
+		{Bucket: "100-199", Outputs: 1, Amount: 1e3},
+	}
+	if !reflect.DeepEqual(result.Heights, wantHeights) {
+		t.Errorf("unexpected heights %+v", result.Heights)
+	}
+
+	// Every value bucket is returned, including empty ones.
+	if len(result.Values) != len(utxoValueBucketBounds) {
+		t.Fatalf("got %d value buckets, want %d", len(result.Values),
+			len(utxoValueBucketBounds))
+	}
+	wantValues := map[string]btcjson.UtxoStatsBucket{
+		"0-999":               {Bucket: "0-999", Outputs: 1, Amount: 500},
+		"1000-9999":           {Bucket: "1000-9999", Outputs: 1, Amount: 1e3},
+		"100000000-999999999": {Bucket: "100000000-999999999", Outputs: 1, Amount: 2e8},
+		"10000000000+":        {Bucket: "10000000000+"},
+	}
+	for _, bucket := range result.Values {
+		want, ok := wantValues[bucket.Bucket]
+		if !ok {
+			want = btcjson.UtxoStatsBucket{Bucket: bucket.Bucket}
+		}
+		if bucket != want {
+			t.Errorf("unexpected value bucket %+v, want %+v", bucket,
+				want)
+		}
+	}
+}
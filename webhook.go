@@ -0,0 +1,227 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btclog"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// webhookOutboxDirName is the name of the directory within the data
+	// directory which houses the outboxes of the webhooks.
+	webhookOutboxDirName = "outbox"
+
+	// webhookTimeout is the maximum amount of time a webhook endpoint has
+	// to accept a notification.
+	webhookTimeout = time.Second * 30
+
+	// webhookMinRetryInterval and webhookMaxRetryInterval bound the time
+	// waited before retrying to deliver a notification to a webhook
+	// endpoint which is unavailable.  The interval doubles after every
+	// failed attempt.
+	webhookMinRetryInterval = time.Second
+	webhookMaxRetryInterval = time.Minute * 5
+)
+
+// webhookEvent is the JSON payload posted to webhook endpoints when a block is
+// connected to or disconnected from the main chain.
+type webhookEvent struct {
+	Type   string   `json:"type"`
+	Hash   string   `json:"hash"`
+	Height int32    `json:"height"`
+	Time   int64    `json:"time"`
+	Txs    []string `json:"txs"`
+}
+
+// newWebhookEvent returns the serialized webhook event of the passed type for
+// the passed block.
+func newWebhookEvent(eventType string, block *btcutil.Block) ([]byte, error) {
+	txns := block.Transactions()
+	txs := make([]string, 0, len(txns))
+	for _, tx := range txns {
+		txs = append(txs, tx.Hash().String())
+	}
+	return json.Marshal(&webhookEvent{
+		Type:   eventType,
+		Hash:   block.Hash().String(),
+		Height: block.Height(),
+		Time:   block.MsgBlock().Header.Timestamp.Unix(),
+		Txs:    txs,
+	})
+}
+
+// webhookOutboxPath returns the path of the outbox of the webhook with the
+// passed URL.  The name is derived from the URL so each webhook keeps its own
+// outbox when the configured webhooks change.
+func webhookOutboxPath(url string) string {
+	hash := sha256.Sum256([]byte(url))
+	name := "webhook-" + hex.EncodeToString(hash[:8])
+	return filepath.Join(cfg.DataDir, webhookOutboxDirName, name)
+}
+
+// webhookNotifier posts the blocks connected to and disconnected from the main
+// chain to a webhook endpoint.
+//
+// Notifications are queued in a disk outbox and delivered one at a time in the
+// order they occurred.  A notification is only removed from the outbox once the
+// endpoint accepted it with a 2xx response, so notifications which occur while
+// the endpoint is unavailable, or while btcd is not running after a shutdown,
+// are delivered once it is available again.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+	outbox *diskOutbox
+	log    btclog.Logger
+
+	// signal is notified when a notification is queued.
+	signal chan struct{}
+}
+
+// newWebhookNotifier returns a webhook notifier for the passed URL which queues
+// notifications in the passed outbox.
+func newWebhookNotifier(url string, outbox *diskOutbox) *webhookNotifier {
+	return &webhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+		outbox: outbox,
+		log:    srvrLog,
+		signal: make(chan struct{}, 1),
+	}
+}
+
+// queue adds the passed serialized event to the outbox and wakes up the
+// delivery.
+func (n *webhookNotifier) queue(payload []byte) {
+	if err := n.outbox.Push(payload, time.Now()); err != nil {
+		n.log.Errorf("Unable to queue notification for webhook %s: %v",
+			n.url, err)
+		return
+	}
+	select {
+	case n.signal <- struct{}{}:
+	default:
+	}
+}
+
+// handleBlockchainNotification queues a webhook event for the blocks connected
+// to and disconnected from the main chain.  It must be subscribed to the chain
+// notifications.
+func (n *webhookNotifier) handleBlockchainNotification(notification *blockchain.Notification) {
+	var eventType string
+	switch notification.Type {
+	case blockchain.NTBlockConnected:
+		eventType = "blockconnected"
+	case blockchain.NTBlockDisconnected:
+		eventType = "blockdisconnected"
+	default:
+		return
+	}
+
+	block, ok := notification.Data.(*btcutil.Block)
+	if !ok {
+		n.log.Warnf("Chain notification %v is not a block",
+			notification.Type)
+		return
+	}
+	payload, err := newWebhookEvent(eventType, block)
+	if err != nil {
+		n.log.Errorf("Unable to serialize webhook event: %v", err)
+		return
+	}
+	n.queue(payload)
+}
+
+// post delivers the passed payload to the webhook endpoint.
+func (n *webhookNotifier) post(payload []byte) error {
+	resp, err := n.client.Post(n.url, "application/json",
+		bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Run delivers the queued notifications until the passed quit channel is
+// closed.  Delivery is retried with an increasing interval while the endpoint
+// is unavailable.
+func (n *webhookNotifier) Run(quit <-chan struct{}) {
+	retryInterval := webhookMinRetryInterval
+	failing := false
+	for {
+		select {
+		case <-quit:
+			return
+		default:
+		}
+
+		payload, ok, err := n.outbox.Front(time.Now())
+		if err != nil {
+			n.log.Errorf("Unable to read outbox of webhook %s: %v",
+				n.url, err)
+		}
+		if !ok {
+			select {
+			case <-n.signal:
+				continue
+			case <-quit:
+				return
+			}
+		}
+
+		if err := n.post(payload); err != nil {
+			// Only warn about the first failure of an outage to
+			// avoid flooding the log.
+			if !failing {
+				n.log.Warnf("Unable to deliver notification to "+
+					"webhook %s, queueing notifications until "+
+					"it is available: %v", n.url, err)
+				failing = true
+			} else {
+				n.log.Debugf("Unable to deliver notification to "+
+					"webhook %s: %v", n.url, err)
+			}
+
+			select {
+			case <-time.After(retryInterval):
+			case <-quit:
+				return
+			}
+			retryInterval *= 2
+			if retryInterval > webhookMaxRetryInterval {
+				retryInterval = webhookMaxRetryInterval
+			}
+			continue
+		}
+
+		if err := n.outbox.Pop(); err != nil {
+			n.log.Errorf("Unable to update outbox of webhook %s: %v",
+				n.url, err)
+		}
+		if failing {
+			n.log.Infof("Webhook %s is available again, delivering "+
+				"%d queued notifications", n.url, n.outbox.Len())
+			failing = false
+		}
+		retryInterval = webhookMinRetryInterval
+	}
+}
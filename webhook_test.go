@@ -0,0 +1,107 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btclog"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestWebhookNotifier ensures chain notifications are delivered to a webhook
+// endpoint in order once it is available after an outage.
+func TestWebhookNotifier(t *testing.T) {
+	t.Parallel()
+
+	// Reject the first request to simulate an unavailable endpoint.
+	events := make(chan webhookEvent, 4)
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event webhookEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events <- event
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	outbox, err := openDiskOutbox(filepath.Join(dir, "outbox"), 1024*1024, 0)
+	if err != nil {
+		t.Fatalf("openDiskOutbox: unexpected error: %v", err)
+	}
+	defer outbox.Close()
+	outbox.log = btclog.Disabled
+	n := newWebhookNotifier(srv.URL, outbox)
+	n.log = btclog.Disabled
+
+	// Queue a connected and disconnected block along with a notification
+	// which is not delivered to webhooks.
+	block := btcutil.NewBlock(chaincfg.MainNetParams.GenesisBlock)
+	block.SetHeight(0)
+	n.handleBlockchainNotification(&blockchain.Notification{
+		Type: blockchain.NTBlockConnected,
+		Data: block,
+	})
+	n.handleBlockchainNotification(&blockchain.Notification{
+		Type: blockchain.NTBlockAccepted,
+		Data: block,
+	})
+	n.handleBlockchainNotification(&blockchain.Notification{
+		Type: blockchain.NTBlockDisconnected,
+		Data: block,
+	})
+	if outbox.Len() != 2 {
+		t.Fatalf("got %d queued notifications, want 2", outbox.Len())
+	}
+
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		n.Run(quit)
+		close(done)
+	}()
+	defer func() {
+		close(quit)
+		<-done
+	}()
+
+	for _, wantType := range []string{"blockconnected", "blockdisconnected"} {
+		select {
+		case event := <-events:
+			if event.Type != wantType {
+				t.Fatalf("got event type %q, want %q", event.Type,
+					wantType)
+			}
+			if event.Hash != block.Hash().String() || event.Height != 0 ||
+				len(event.Txs) != 1 {
+
+				t.Fatalf("unexpected event %+v", event)
+			}
+		case <-time.After(time.Second * 10):
+			t.Fatalf("timeout waiting for %s event", wantType)
+		}
+	}
+}
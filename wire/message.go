@@ -51,6 +51,7 @@ const (
 	CmdReject      = "reject"
 	CmdSendHeaders = "sendheaders"
 	CmdFeeFilter   = "feefilter"
+	CmdZBlock      = "zblock"
 )
 
 // MessageEncoding represents the wire message encoding format to be used.
@@ -156,6 +157,9 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdFeeFilter:
 		msg = &MsgFeeFilter{}
 
+	case CmdZBlock:
+		msg = &MsgZBlock{}
+
 	default:
 		return nil, fmt.Errorf("unhandled command [%s]", command)
 	}
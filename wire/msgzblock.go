@@ -0,0 +1,86 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MsgZBlock implements the Message interface and represents a zblock message.
+// It holds a serialized block compressed with zstd and is sent instead of a
+// block message to peers which advertise SFNodeZstdBlocks.  The block is
+// serialized with the encoding of the block message it replaces.
+//
+// This message is not part of the bitcoin protocol.  Compressing and
+// decompressing the block is left to the caller.
+type MsgZBlock struct {
+	// Size is the size of the serialized block before compression.
+	Size uint32
+
+	// Data is the serialized block compressed with zstd.
+	Data []byte
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgZBlock) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	err := readElement(r, &msg.Size)
+	if err != nil {
+		return err
+	}
+	if msg.Size > MaxBlockPayload {
+		str := fmt.Sprintf("block size too large [size %v, max %v]",
+			msg.Size, MaxBlockPayload)
+		return messageError("MsgZBlock.BtcDecode", str)
+	}
+
+	msg.Data, err = ReadVarBytes(r, pver, MaxBlockPayload,
+		"compressed block")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgZBlock) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if msg.Size > MaxBlockPayload {
+		str := fmt.Sprintf("block size too large [size %v, max %v]",
+			msg.Size, MaxBlockPayload)
+		return messageError("MsgZBlock.BtcEncode", str)
+	}
+	if len(msg.Data) > MaxBlockPayload {
+		str := fmt.Sprintf("compressed block too large [size %v, "+
+			"max %v]", len(msg.Data), MaxBlockPayload)
+		return messageError("MsgZBlock.BtcEncode", str)
+	}
+
+	err := writeElement(w, msg.Size)
+	if err != nil {
+		return err
+	}
+	return WriteVarBytes(w, pver, msg.Data)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgZBlock) Command() string {
+	return CmdZBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgZBlock) MaxPayloadLength(pver uint32) uint32 {
+	// Size 4 bytes + data length varint + compressed block.
+	return 4 + MaxVarIntPayload + MaxBlockPayload
+}
+
+// NewMsgZBlock returns a new zblock message that conforms to the Message
+// interface.  See MsgZBlock for details.
+func NewMsgZBlock(size uint32, data []byte) *MsgZBlock {
+	return &MsgZBlock{
+		Size: size,
+		Data: data,
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestZBlock tests the MsgZBlock API.
+func TestZBlock(t *testing.T) {
+	pver := ProtocolVersion
+
+	data := []byte{0x28, 0xb5, 0x2f, 0xfd}
+	msg := NewMsgZBlock(285, data)
+	if msg.Size != 285 || !bytes.Equal(msg.Data, data) {
+		t.Errorf("NewMsgZBlock: wrong fields - got %v", spew.Sdump(msg))
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "zblock"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgZBlock: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(4000013)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+}
+
+// TestZBlockWire tests the MsgZBlock wire encode and decode.
+func TestZBlockWire(t *testing.T) {
+	msg := MsgZBlock{Size: 285, Data: []byte{0x28, 0xb5, 0x2f, 0xfd}}
+	msgEncoded := []byte{
+		0x1d, 0x01, 0x00, 0x00, // Size
+		0x04,                   // Varint for data length
+		0x28, 0xb5, 0x2f, 0xfd, // Data
+	}
+
+	// Encode the message to wire format.
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, ProtocolVersion, WitnessEncoding)
+	if err != nil {
+		t.Fatalf("BtcEncode error %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), msgEncoded) {
+		t.Fatalf("BtcEncode\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(msgEncoded))
+	}
+
+	// Decode the message from wire format.
+	var readMsg MsgZBlock
+	rbuf := bytes.NewReader(msgEncoded)
+	err = readMsg.BtcDecode(rbuf, ProtocolVersion, WitnessEncoding)
+	if err != nil {
+		t.Fatalf("BtcDecode error %v", err)
+	}
+	if !reflect.DeepEqual(readMsg, msg) {
+		t.Fatalf("BtcDecode\n got: %s want: %s", spew.Sdump(readMsg),
+			spew.Sdump(msg))
+	}
+}
+
+// TestZBlockWireErrors performs negative tests against wire encode and decode
+// of MsgZBlock to confirm error paths work correctly.
+func TestZBlockWireErrors(t *testing.T) {
+	// Blocks which are too large are rejected.
+	tooLarge := MsgZBlock{Size: MaxBlockPayload + 1}
+	var buf bytes.Buffer
+	err := tooLarge.BtcEncode(&buf, ProtocolVersion, WitnessEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode: got error %v, want MessageError", err)
+	}
+	encoded := []byte{0x01, 0x09, 0x3d, 0x00, 0x00}
+	err = new(MsgZBlock).BtcDecode(bytes.NewReader(encoded),
+		ProtocolVersion, WitnessEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode: got error %v, want MessageError", err)
+	}
+
+	// Truncated messages are rejected.
+	msgEncoded := []byte{0x1d, 0x01, 0x00, 0x00, 0x04, 0x28, 0xb5}
+	for i := 0; i < len(msgEncoded); i++ {
+		err := new(MsgZBlock).BtcDecode(bytes.NewReader(msgEncoded[:i]),
+			ProtocolVersion, WitnessEncoding)
+		if err == nil {
+			t.Errorf("BtcDecode #%d: expected error", i)
+		}
+	}
+}
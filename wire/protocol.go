@@ -72,12 +72,19 @@ const (
 	SFNodeWitness
 )
 
+// SFNodeZstdBlocks is a flag used to indicate a peer supports receiving blocks
+// compressed with zstd in zblock messages.  It is not part of the bitcoin
+// protocol and uses one of the service bits reserved for temporary
+// experiments.
+const SFNodeZstdBlocks ServiceFlag = 1 << 24
+
 // Map of service flags back to their constant names for pretty printing.
 var sfStrings = map[ServiceFlag]string{
-	SFNodeNetwork: "SFNodeNetwork",
-	SFNodeGetUTXO: "SFNodeGetUTXO",
-	SFNodeBloom:   "SFNodeBloom",
-	SFNodeWitness: "SFNodeWitness",
+	SFNodeNetwork:    "SFNodeNetwork",
+	SFNodeGetUTXO:    "SFNodeGetUTXO",
+	SFNodeBloom:      "SFNodeBloom",
+	SFNodeWitness:    "SFNodeWitness",
+	SFNodeZstdBlocks: "SFNodeZstdBlocks",
 }
 
 // orderedSFStrings is an ordered list of service flags from highest to
@@ -87,6 +94,7 @@ var orderedSFStrings = []ServiceFlag{
 	SFNodeGetUTXO,
 	SFNodeBloom,
 	SFNodeWitness,
+	SFNodeZstdBlocks,
 }
 
 // String returns the ServiceFlag in human-readable form.
@@ -133,6 +141,14 @@ const (
 
 	// SimNet represents the simulation test network.
 	SimNet BitcoinNet = 0x12141c16
+
+	// SigNet represents the public default signet network.  Signets with a
+	// custom block challenge use a different value derived from their
+	// challenge as defined by BIP0325.
+	SigNet BitcoinNet = 0x40cf030a
+
+	// TestNet4 represents the test network (version 4).
+	TestNet4 BitcoinNet = 0x283f161c
 )
 
 // bnStrings is a map of bitcoin networks back to their constant names for
@@ -142,6 +158,8 @@ var bnStrings = map[BitcoinNet]string{
 	TestNet:  "TestNet",
 	TestNet3: "TestNet3",
 	SimNet:   "SimNet",
+	SigNet:   "SigNet",
+	TestNet4: "TestNet4",
 }
 
 // String returns the BitcoinNet in human-readable form.
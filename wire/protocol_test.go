@@ -17,7 +17,8 @@ func TestServiceFlagStringer(t *testing.T) {
 		{SFNodeGetUTXO, "SFNodeGetUTXO"},
 		{SFNodeBloom, "SFNodeBloom"},
 		{SFNodeWitness, "SFNodeWitness"},
-		{0xffffffff, "SFNodeNetwork|SFNodeGetUTXO|SFNodeBloom|SFNodeWitness|0xfffffff0"},
+		{SFNodeZstdBlocks, "SFNodeZstdBlocks"},
+		{0xffffffff, "SFNodeNetwork|SFNodeGetUTXO|SFNodeBloom|SFNodeWitness|SFNodeZstdBlocks|0xfefffff0"},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -41,6 +42,8 @@ func TestBitcoinNetStringer(t *testing.T) {
 		{TestNet, "TestNet"},
 		{TestNet3, "TestNet3"},
 		{SimNet, "SimNet"},
+		{SigNet, "SigNet"},
+		{TestNet4, "TestNet4"},
 		{0xffffffff, "Unknown BitcoinNet (4294967295)"},
 	}
 